@@ -35,6 +35,22 @@ func GetSessionDetails(db *sql.DB, sessionID string) (models.Session, string, er
 	return session, userName, nil
 }
 
+// sessionContext validates the session header of a request. It writes the error response itself
+// and returns ok=false when the request must stop.
+func sessionContext(c *gin.Context, db *sql.DB) (session models.Session, userName string, ok bool) {
+	sessionID := c.GetHeader("Authorization")
+	if sessionID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+		return session, "", false
+	}
+	session, userName, err := GetSessionDetails(db, sessionID)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+		return session, "", false
+	}
+	return session, userName, true
+}
+
 // Helper to save activity logs
 func SaveActivityLog(db *sql.DB, log models.ActivityLog) error {
 	query := `
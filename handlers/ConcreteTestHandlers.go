@@ -0,0 +1,929 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// SQL statements for the concrete cube test register
+const (
+	selectConcreteSampleSQL = `
+		SELECT s.id, s.project_id, s.sample_no, s.pour_ref, s.grade, s.specimen_type, s.characteristic_mpa,
+			s.release_strength_mpa, s.specimens_per_age, s.cast_at, s.status, s.released_at, s.released_by,
+			s.release_note, s.remarks, s.created_by, s.created_at,
+			(SELECT COUNT(*) FROM concrete_sample_element se WHERE se.sample_id = s.id),
+			(SELECT MIN(t.due_date) FROM concrete_test t WHERE t.sample_id = s.id AND t.tested_at IS NULL),
+			(SELECT t.mean_mpa FROM concrete_test t WHERE t.sample_id = s.id AND t.mean_mpa IS NOT NULL
+				ORDER BY t.age_days DESC LIMIT 1)
+		FROM concrete_sample s`
+)
+
+// concreteStrengthGain is the typical fraction of the 28-day strength reached at each age.
+var concreteStrengthGain = []struct {
+	days  int
+	ratio float64
+}{{0, 0}, {1, 0.16}, {3, 0.40}, {7, 0.65}, {14, 0.90}, {28, 1.0}}
+
+// defaultReleaseStrengthRatio sets the release strength when a sample doesn't give one.
+const defaultReleaseStrengthRatio = 0.7
+
+// concreteIndividualMargin is how far a single 28-day result may fall below the grade (IS 456).
+const concreteIndividualMargin = 4.0
+
+var concreteGradePattern = regexp.MustCompile(`^(?i)(M|C)\s*(\d+(?:\.\d+)?)(?:\s*/\s*(\d+(?:\.\d+)?))?$`)
+
+// concreteGradeStrength reads the characteristic strength from a grade such as M40, C40 or C30/37.
+// Two-number grades give the cylinder strength first and the cube strength second.
+func concreteGradeStrength(grade, specimenType string) (float64, bool) {
+	m := concreteGradePattern.FindStringSubmatch(strings.TrimSpace(grade))
+	if m == nil {
+		return 0, false
+	}
+	value := m[2]
+	if m[3] != "" && specimenType == models.ConcreteSpecimenCube {
+		value = m[3]
+	}
+	strength, err := strconv.ParseFloat(value, 64)
+	return strength, err == nil && strength > 0
+}
+
+// expectedConcreteRatio interpolates the strength gain curve at the given age.
+func expectedConcreteRatio(ageDays int) float64 {
+	last := concreteStrengthGain[len(concreteStrengthGain)-1]
+	if ageDays >= last.days {
+		return last.ratio
+	}
+	for i := 1; i < len(concreteStrengthGain); i++ {
+		lo, hi := concreteStrengthGain[i-1], concreteStrengthGain[i]
+		if ageDays <= hi.days {
+			return lo.ratio + (hi.ratio-lo.ratio)*float64(ageDays-lo.days)/float64(hi.days-lo.days)
+		}
+	}
+	return last.ratio
+}
+
+func roundMPa(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// evaluateConcreteTest computes the mean and result of a test. Early ages are judged against the
+// expected strength gain; 28 days and later against the grade, with a floor on single results.
+func evaluateConcreteTest(sample models.ConcreteSample, t *models.ConcreteTest) {
+	t.TargetMPa = roundMPa(sample.CharacteristicMPa * expectedConcreteRatio(t.AgeDays))
+	t.MeanMPa, t.PercentOfGrade = nil, nil
+	if len(t.StrengthsMPa) == 0 {
+		t.Result, t.Reason = models.ConcreteTestPending, ""
+		return
+	}
+	sum, lowest := 0.0, t.StrengthsMPa[0]
+	for _, s := range t.StrengthsMPa {
+		sum += s
+		lowest = math.Min(lowest, s)
+	}
+	mean := roundMPa(sum / float64(len(t.StrengthsMPa)))
+	percent := roundMPa(mean / sample.CharacteristicMPa * 100)
+	t.MeanMPa, t.PercentOfGrade = &mean, &percent
+
+	t.Result, t.Reason = models.ConcreteTestPass, ""
+	if mean < t.TargetMPa {
+		t.Result = models.ConcreteTestFail
+		t.Reason = fmt.Sprintf("mean %.2f MPa is below the expected %.2f MPa at %d days", mean, t.TargetMPa, t.AgeDays)
+	} else if t.AgeDays >= 28 && lowest < sample.CharacteristicMPa-concreteIndividualMargin {
+		t.Result = models.ConcreteTestFail
+		t.Reason = fmt.Sprintf("individual result %.2f MPa is below %.2f MPa", lowest, sample.CharacteristicMPa-concreteIndividualMargin)
+	}
+}
+
+// scanConcreteSample scans a row produced by selectConcreteSampleSQL.
+func scanConcreteSample(row interface{ Scan(...any) error }, s *models.ConcreteSample) error {
+	var releasedAt, nextDue sql.NullTime
+	var latest sql.NullFloat64
+	err := row.Scan(&s.ID, &s.ProjectID, &s.SampleNo, &s.PourRef, &s.Grade, &s.SpecimenType, &s.CharacteristicMPa,
+		&s.ReleaseStrengthMPa, &s.SpecimensPerAge, &s.CastAt, &s.Status, &releasedAt, &s.ReleasedBy,
+		&s.ReleaseNote, &s.Remarks, &s.CreatedBy, &s.CreatedAt, &s.ElementCount, &nextDue, &latest)
+	if err != nil {
+		return err
+	}
+	if releasedAt.Valid {
+		s.ReleasedAt = &releasedAt.Time
+	}
+	if nextDue.Valid {
+		s.NextTestDue = &nextDue.Time
+	}
+	s.LatestStrengthMPa = nullFloatPtr(latest)
+	return nil
+}
+
+// loadConcreteSample reads a sample with its tests and elements.
+func loadConcreteSample(q sqlQueryer, id int) (models.ConcreteSample, error) {
+	var s models.ConcreteSample
+	if err := scanConcreteSample(q.QueryRow(selectConcreteSampleSQL+` WHERE s.id = $1`, id), &s); err != nil {
+		return s, err
+	}
+
+	rows, err := q.Query(`
+		SELECT id, sample_id, age_days, due_date, strengths_mpa, target_mpa, result, reason, tested_at, tested_by, remarks
+		FROM concrete_test WHERE sample_id = $1 ORDER BY age_days`, id)
+	if err != nil {
+		return s, err
+	}
+	s.Tests = []models.ConcreteTest{}
+	for rows.Next() {
+		var t models.ConcreteTest
+		var testedAt sql.NullTime
+		if err := rows.Scan(&t.ID, &t.SampleID, &t.AgeDays, &t.DueDate, pq.Array(&t.StrengthsMPa), &t.TargetMPa,
+			&t.Result, &t.Reason, &testedAt, &t.TestedBy, &t.Remarks); err != nil {
+			rows.Close()
+			return s, err
+		}
+		if testedAt.Valid {
+			t.TestedAt = &testedAt.Time
+		}
+		if t.StrengthsMPa == nil {
+			t.StrengthsMPa = []float64{}
+		}
+		result, reason := t.Result, t.Reason
+		evaluateConcreteTest(s, &t)
+		t.Result, t.Reason = result, reason // keep the verdict recorded at the time of testing
+		s.Tests = append(s.Tests, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return s, err
+	}
+
+	rows, err = q.Query(`
+		SELECT se.element_id, COALESCE(e.element_name, '')
+		FROM concrete_sample_element se
+		LEFT JOIN element e ON e.id = se.element_id
+		WHERE se.sample_id = $1 ORDER BY e.element_name, se.element_id`, id)
+	if err != nil {
+		return s, err
+	}
+	defer rows.Close()
+	s.Elements = []models.SampleElement{}
+	for rows.Next() {
+		var el models.SampleElement
+		if err := rows.Scan(&el.ElementID, &el.ElementName); err != nil {
+			return s, err
+		}
+		s.Elements = append(s.Elements, el)
+	}
+	return s, rows.Err()
+}
+
+// nextConcreteSampleNo numbers samples per project: CS-0001, CS-0002, ...
+func nextConcreteSampleNo(tx *sql.Tx, projectID int) (string, error) {
+	// Serialise numbering per project
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('concrete_sample'), $1)`, projectID); err != nil {
+		return "", err
+	}
+	var last int
+	err := tx.QueryRow(`
+		SELECT COALESCE(MAX(NULLIF(regexp_replace(sample_no, '\D', '', 'g'), '')::INT), 0)
+		FROM concrete_sample WHERE project_id = $1`, projectID).Scan(&last)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("CS-%04d", last+1), nil
+}
+
+// lockConcreteHoldElements locks the element rows whose hold state is about to be checked or changed.
+func lockConcreteHoldElements(tx *sql.Tx, elementIDs []int) error {
+	ids := make([]int64, 0, len(elementIDs))
+	for _, id := range elementIDs {
+		ids = append(ids, int64(id))
+	}
+	_, err := tx.Exec(`SELECT id FROM element WHERE id = ANY($1) ORDER BY id FOR UPDATE`, pq.Array(ids))
+	return err
+}
+
+// linkSampleElements replaces the elements of a sample after checking they belong to its project.
+func linkSampleElements(tx *sql.Tx, sampleID, projectID int, elementIDs []int) error {
+	ids := make([]int64, 0, len(elementIDs))
+	for _, id := range elementIDs {
+		ids = append(ids, int64(id))
+	}
+	rows, err := tx.Query(`SELECT id FROM element WHERE project_id = $1 AND id = ANY($2) ORDER BY id FOR UPDATE`, projectID, pq.Array(ids))
+	if err != nil {
+		return err
+	}
+	found := make(map[int]bool)
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		found[id] = true
+	}
+	rows.Close()
+	for _, id := range elementIDs {
+		if !found[id] {
+			return fmt.Errorf("element %d does not belong to project %d", id, projectID)
+		}
+	}
+
+	if _, err := tx.Exec(`DELETE FROM concrete_sample_element WHERE sample_id = $1`, sampleID); err != nil {
+		return err
+	}
+	_, err = tx.Exec(`
+		INSERT INTO concrete_sample_element (sample_id, element_id)
+		SELECT $1, UNNEST($2::INT[]) ON CONFLICT DO NOTHING`, sampleID, pq.Array(ids))
+	return err
+}
+
+// ConcreteStrengthHolds lists the elements among elementIDs that are held by a sample that has not
+// reached its release strength or failed at 28 days.
+//
+// Elements without a linked sample are not held: cube testing is recorded per pour and not every
+// project tracks it, so a missing link means "not tracked" rather than "not tested". Callers that
+// move elements run this inside their transaction after lockConcreteHoldElements, so a sample
+// linked or failed at the same time either holds the move or waits for it.
+func ConcreteStrengthHolds(q sqlQueryer, elementIDs []int) ([]models.ConcreteHold, error) {
+	holds := []models.ConcreteHold{}
+	if len(elementIDs) == 0 {
+		return holds, nil
+	}
+	ids := make([]int64, 0, len(elementIDs))
+	for _, id := range elementIDs {
+		ids = append(ids, int64(id))
+	}
+	rows, err := q.Query(`
+		SELECT se.element_id, COALESCE(e.element_name, ''), s.id, s.sample_no, s.status, s.release_strength_mpa,
+			(SELECT MAX(t.mean_mpa) FROM concrete_test t WHERE t.sample_id = s.id)
+		FROM concrete_sample_element se
+		JOIN concrete_sample s ON s.id = se.sample_id
+		LEFT JOIN element e ON e.id = se.element_id
+		WHERE se.element_id = ANY($1) AND s.status IN ($2, $3)
+		ORDER BY se.element_id, s.id`,
+		pq.Array(ids), models.ConcreteSampleTesting, models.ConcreteSampleFailed)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var h models.ConcreteHold
+		var latest sql.NullFloat64
+		if err := rows.Scan(&h.ElementID, &h.ElementName, &h.SampleID, &h.SampleNo, &h.Status, &h.ReleaseStrengthMPa, &latest); err != nil {
+			return nil, err
+		}
+		h.LatestStrengthMPa = nullFloatPtr(latest)
+		holds = append(holds, h)
+	}
+	return holds, rows.Err()
+}
+
+// CreateConcreteSample registers the specimens taken from a pour and schedules their tests.
+// @Summary Register concrete sample
+// @Description Registers cube or cylinder specimens taken from a pour, links the elements cast from it and schedules tests at 1, 3, 7 and 28 days unless other ages are given. Linked elements are held until the release strength is reached.
+// @Tags Concrete Tests
+// @Accept json
+// @Produce json
+// @Param request body models.CreateConcreteSampleRequest true "Sample"
+// @Success 201 {object} models.ConcreteSample
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/concrete_samples [post]
+func CreateConcreteSample(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var req models.CreateConcreteSampleRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		if req.SpecimenType == "" {
+			req.SpecimenType = models.ConcreteSpecimenCube
+		}
+		if req.SpecimenType != models.ConcreteSpecimenCube && req.SpecimenType != models.ConcreteSpecimenCylinder {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "specimen_type must be cube or cylinder"})
+			return
+		}
+		if req.CharacteristicMPa <= 0 {
+			strength, ok := concreteGradeStrength(req.Grade, req.SpecimenType)
+			if !ok {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "characteristic_strength_mpa is required when the grade is not like M40 or C30/37"})
+				return
+			}
+			req.CharacteristicMPa = strength
+		}
+		if req.ReleaseStrengthMPa <= 0 {
+			req.ReleaseStrengthMPa = roundMPa(req.CharacteristicMPa * defaultReleaseStrengthRatio)
+		}
+		if req.ReleaseStrengthMPa > req.CharacteristicMPa {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "release_strength_mpa cannot exceed the characteristic strength"})
+			return
+		}
+		if req.SpecimensPerAge <= 0 {
+			req.SpecimensPerAge = 3
+		}
+		castAt := time.Now()
+		if req.CastAt != "" {
+			t, err := time.Parse(time.RFC3339, req.CastAt)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "cast_at must be an RFC3339 timestamp"})
+				return
+			}
+			castAt = t
+		}
+		ages := req.TestAges
+		if len(ages) == 0 {
+			ages = models.DefaultConcreteTestAges
+		}
+		for _, age := range ages {
+			if age <= 0 || age > 365 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "test_ages must be between 1 and 365 days"})
+				return
+			}
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+
+		sampleNo, err := nextConcreteSampleNo(tx, req.ProjectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to number sample", "details": err.Error()})
+			return
+		}
+		var sampleID int
+		err = tx.QueryRow(`
+			INSERT INTO concrete_sample (project_id, sample_no, pour_ref, grade, specimen_type, characteristic_mpa,
+				release_strength_mpa, specimens_per_age, cast_at, remarks, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			RETURNING id`,
+			req.ProjectID, sampleNo, strings.TrimSpace(req.PourRef), strings.ToUpper(strings.TrimSpace(req.Grade)), req.SpecimenType,
+			req.CharacteristicMPa, req.ReleaseStrengthMPa, req.SpecimensPerAge, castAt, req.Remarks, userName).Scan(&sampleID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create sample", "details": err.Error()})
+			return
+		}
+		sample := models.ConcreteSample{CharacteristicMPa: req.CharacteristicMPa}
+		for _, age := range ages {
+			t := models.ConcreteTest{AgeDays: age}
+			evaluateConcreteTest(sample, &t)
+			if _, err := tx.Exec(`
+				INSERT INTO concrete_test (sample_id, age_days, due_date, target_mpa)
+				VALUES ($1, $2, $3, $4) ON CONFLICT (sample_id, age_days) DO NOTHING`,
+				sampleID, age, castAt.AddDate(0, 0, age), t.TargetMPa); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to schedule test", "details": err.Error()})
+				return
+			}
+		}
+		// A sample taken from a recorded pour covers the elements cast in it
+		if len(req.ElementIDs) == 0 {
+			var pourElements []int64
+			err := tx.QueryRow(`
+				SELECT COALESCE(ARRAY_AGG(pe.element_id), '{}') FROM pour_element pe
+				JOIN pour p ON p.id = pe.pour_id
+				WHERE p.project_id = $1 AND p.pour_no = $2`, req.ProjectID, strings.TrimSpace(req.PourRef)).Scan(pq.Array(&pourElements))
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pour elements", "details": err.Error()})
+				return
+			}
+			for _, id := range pourElements {
+				req.ElementIDs = append(req.ElementIDs, int(id))
+			}
+		}
+		if len(req.ElementIDs) > 0 {
+			if err := linkSampleElements(tx, sampleID, req.ProjectID, req.ElementIDs); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		created, err := loadConcreteSample(db, sampleID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load sample", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, created)
+
+		activityLog := models.ActivityLog{
+			EventContext: "Concrete Test",
+			EventName:    "Create",
+			Description:  fmt.Sprintf("Registered sample %s (%s, pour %s) for %d elements", sampleNo, created.Grade, created.PourRef, created.ElementCount),
+			UserName:     userName,
+			HostName:     session.HostName,
+			IPAddress:    session.IPAddress,
+			CreatedAt:    time.Now(),
+			ProjectID:    req.ProjectID,
+		}
+		if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+			log.Printf("Failed to save activity log: %v", logErr)
+		}
+	}
+}
+
+// GetConcreteSamplesByProject lists the cube test register of a project.
+// @Summary List concrete samples
+// @Tags Concrete Tests
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param status query string false "Filter by status (testing, released, passed, failed)"
+// @Param pour_ref query string false "Filter by pour or batch reference"
+// @Param element_id query int false "Only samples linked to this element"
+// @Param due query bool false "Only samples with a test due today or earlier"
+// @Success 200 {array} models.ConcreteSample
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/concrete_samples [get]
+func GetConcreteSamplesByProject(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+
+		query := selectConcreteSampleSQL + ` WHERE s.project_id = $1`
+		args := []interface{}{projectID}
+		if status := c.Query("status"); status != "" {
+			args = append(args, status)
+			query += fmt.Sprintf(` AND s.status = $%d`, len(args))
+		}
+		if pourRef := c.Query("pour_ref"); pourRef != "" {
+			args = append(args, pourRef)
+			query += fmt.Sprintf(` AND s.pour_ref = $%d`, len(args))
+		}
+		if s := c.Query("element_id"); s != "" {
+			elementID, err := strconv.Atoi(s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "element_id must be a valid integer"})
+				return
+			}
+			args = append(args, elementID)
+			query += fmt.Sprintf(` AND EXISTS (SELECT 1 FROM concrete_sample_element se WHERE se.sample_id = s.id AND se.element_id = $%d)`, len(args))
+		}
+		if c.Query("due") == "true" {
+			query += ` AND EXISTS (SELECT 1 FROM concrete_test t WHERE t.sample_id = s.id AND t.tested_at IS NULL AND t.due_date <= CURRENT_DATE)`
+		}
+		query += ` ORDER BY s.cast_at DESC`
+
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch samples", "details": err.Error()})
+			return
+		}
+		defer rows.Close()
+		samples := []models.ConcreteSample{}
+		for rows.Next() {
+			var s models.ConcreteSample
+			if err := scanConcreteSample(rows, &s); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read sample", "details": err.Error()})
+				return
+			}
+			samples = append(samples, s)
+		}
+		c.JSON(http.StatusOK, samples)
+	}
+}
+
+// GetConcreteSample returns a sample with its strength gain and elements.
+// @Summary Get concrete sample
+// @Tags Concrete Tests
+// @Produce json
+// @Param id path int true "Sample ID"
+// @Success 200 {object} models.ConcreteSample
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/concrete_samples/{id} [get]
+func GetConcreteSample(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		sample, ok := concreteSampleParam(c, db)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, sample)
+	}
+}
+
+// concreteSampleParam loads the sample named by the :id parameter.
+func concreteSampleParam(c *gin.Context, db *sql.DB) (models.ConcreteSample, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a valid integer"})
+		return models.ConcreteSample{}, false
+	}
+	sample, err := loadConcreteSample(db, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Sample not found"})
+		return sample, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch sample", "details": err.Error()})
+		return sample, false
+	}
+	return sample, true
+}
+
+// UpdateConcreteSampleElements replaces the elements cast from a sampled pour.
+// @Summary Link elements to concrete sample
+// @Tags Concrete Tests
+// @Accept json
+// @Produce json
+// @Param id path int true "Sample ID"
+// @Param request body models.SampleElementsRequest true "Elements"
+// @Success 200 {object} models.ConcreteSample
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/concrete_samples/{id}/elements [put]
+func UpdateConcreteSampleElements(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var req models.SampleElementsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		sample, ok := concreteSampleParam(c, db)
+		if !ok {
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+		if err := linkSampleElements(tx, sample.ID, sample.ProjectID, req.ElementIDs); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		updated, err := loadConcreteSample(db, sample.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load sample", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+
+		activityLog := models.ActivityLog{
+			EventContext: "Concrete Test",
+			EventName:    "Update",
+			Description:  fmt.Sprintf("Linked %d elements to sample %s", len(updated.Elements), updated.SampleNo),
+			UserName:     userName,
+			HostName:     session.HostName,
+			IPAddress:    session.IPAddress,
+			CreatedAt:    time.Now(),
+			ProjectID:    updated.ProjectID,
+		}
+		if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+			log.Printf("Failed to save activity log: %v", logErr)
+		}
+	}
+}
+
+// RecordConcreteTestResult enters the crushing strengths of a sample at one age. Reaching the
+// release strength releases the sample's elements; a failed 28-day test holds them again and
+// raises an NCR against each element.
+// @Summary Record concrete test result
+// @Tags Concrete Tests
+// @Accept json
+// @Produce json
+// @Param id path int true "Sample ID"
+// @Param age_days path int true "Test age in days"
+// @Param request body models.ConcreteTestResultRequest true "Result"
+// @Success 200 {object} models.ConcreteSample
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/concrete_samples/{id}/tests/{age_days} [put]
+func RecordConcreteTestResult(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		ageDays, err := strconv.Atoi(c.Param("age_days"))
+		if err != nil || ageDays <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "age_days must be a positive integer"})
+			return
+		}
+		var req models.ConcreteTestResultRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		if len(req.StrengthsMPa) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "At least one strength is required"})
+			return
+		}
+		for _, s := range req.StrengthsMPa {
+			if s <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "strengths_mpa must be positive"})
+				return
+			}
+		}
+		testedAt := time.Now()
+		if req.TestedAt != "" {
+			t, err := time.Parse(time.RFC3339, req.TestedAt)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "tested_at must be an RFC3339 timestamp"})
+				return
+			}
+			testedAt = t
+		}
+
+		sample, ok := concreteSampleParam(c, db)
+		if !ok {
+			return
+		}
+		test := models.ConcreteTest{AgeDays: ageDays, StrengthsMPa: req.StrengthsMPa}
+		evaluateConcreteTest(sample, &test)
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+
+		_, err = tx.Exec(`
+			INSERT INTO concrete_test (sample_id, age_days, due_date, strengths_mpa, mean_mpa, target_mpa, result, reason, tested_at, tested_by, remarks)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)
+			ON CONFLICT (sample_id, age_days) DO UPDATE SET
+				strengths_mpa = EXCLUDED.strengths_mpa, mean_mpa = EXCLUDED.mean_mpa, target_mpa = EXCLUDED.target_mpa,
+				result = EXCLUDED.result, reason = EXCLUDED.reason, tested_at = EXCLUDED.tested_at,
+				tested_by = EXCLUDED.tested_by, remarks = EXCLUDED.remarks`,
+			sample.ID, ageDays, sample.CastAt.AddDate(0, 0, ageDays), pq.Array(req.StrengthsMPa), test.MeanMPa, test.TargetMPa,
+			test.Result, test.Reason, testedAt, userName, req.Remarks)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save test result", "details": err.Error()})
+			return
+		}
+
+		// Work out the sample status from the new result
+		status := sample.Status
+		var ncrs []string
+		switch {
+		case ageDays >= 28 && test.Result == models.ConcreteTestFail:
+			status = models.ConcreteSampleFailed
+			if ncrs, err = raiseCubeTestNCRs(tx, sample, test, userName); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to raise NCR", "details": err.Error()})
+				return
+			}
+		case ageDays >= 28:
+			status = models.ConcreteSamplePassed
+		case sample.Status == models.ConcreteSampleTesting && *test.MeanMPa >= sample.ReleaseStrengthMPa:
+			status = models.ConcreteSampleReleased
+		}
+		if status != sample.Status {
+			// Hold the sample's elements still while their hold state changes
+			if _, err := tx.Exec(`
+				SELECT e.id FROM element e JOIN concrete_sample_element se ON se.element_id = e.id
+				WHERE se.sample_id = $1 ORDER BY e.id FOR UPDATE OF e`, sample.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock sample elements", "details": err.Error()})
+				return
+			}
+			releasing := sample.ReleasedAt == nil && (status == models.ConcreteSampleReleased || status == models.ConcreteSamplePassed)
+			_, err = tx.Exec(`
+				UPDATE concrete_sample SET status = $1,
+					released_at = CASE WHEN $2 THEN $3::TIMESTAMPTZ ELSE released_at END,
+					released_by = CASE WHEN $2 THEN $4 ELSE released_by END,
+					release_note = CASE WHEN $2 THEN $5 ELSE release_note END
+				WHERE id = $6`,
+				status, releasing, testedAt, userName,
+				fmt.Sprintf("%d-day mean %.2f MPa reached release strength %.2f MPa", ageDays, *test.MeanMPa, sample.ReleaseStrengthMPa), sample.ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update sample", "details": err.Error()})
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		updated, err := loadConcreteSample(db, sample.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load sample", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+
+		action := fmt.Sprintf("https://precastezy.blueinvent.com/project/%d/concrete-tests/%d", sample.ProjectID, sample.ID)
+		switch {
+		case status == models.ConcreteSampleFailed && sample.Status != models.ConcreteSampleFailed:
+			sendProjectNotifications(db, sample.ProjectID,
+				fmt.Sprintf("Sample %s (pour %s) failed at %d days: %s. %d elements are on hold (%s)",
+					sample.SampleNo, sample.PourRef, ageDays, test.Reason, len(sample.Elements), strings.Join(ncrs, ", ")), action)
+		case status == models.ConcreteSampleReleased && sample.Status == models.ConcreteSampleTesting:
+			sendProjectNotifications(db, sample.ProjectID,
+				fmt.Sprintf("Sample %s (pour %s) reached release strength; %d elements released for dispatch",
+					sample.SampleNo, sample.PourRef, len(sample.Elements)), action)
+		}
+
+		activityLog := models.ActivityLog{
+			EventContext: "Concrete Test",
+			EventName:    "Result",
+			Description:  fmt.Sprintf("Recorded %d-day result of sample %s: %.2f MPa (%s)", ageDays, sample.SampleNo, *test.MeanMPa, test.Result),
+			UserName:     userName,
+			HostName:     session.HostName,
+			IPAddress:    session.IPAddress,
+			CreatedAt:    time.Now(),
+			ProjectID:    sample.ProjectID,
+		}
+		if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+			log.Printf("Failed to save activity log: %v", logErr)
+		}
+	}
+}
+
+// raiseCubeTestNCRs opens an NCR against every element cast from a sample that failed at 28 days.
+func raiseCubeTestNCRs(tx *sql.Tx, sample models.ConcreteSample, test models.ConcreteTest, raisedBy string) ([]string, error) {
+	strengths := make([]string, len(test.StrengthsMPa))
+	for i, s := range test.StrengthsMPa {
+		strengths[i] = strconv.FormatFloat(s, 'f', -1, 64)
+	}
+	var ncrNos []string
+	for _, el := range sample.Elements {
+		var elementTypeID sql.NullInt64
+		if err := tx.QueryRow(`SELECT element_type_id FROM element WHERE id = $1`, el.ElementID).Scan(&elementTypeID); err != nil && err != sql.ErrNoRows {
+			return nil, err
+		}
+		n := models.NCR{
+			ProjectID:         sample.ProjectID,
+			ElementID:         el.ElementID,
+			ElementTypeID:     int(elementTypeID.Int64),
+			Source:            models.NCRSourceCubeTest,
+			Title:             fmt.Sprintf("%s %d-day strength below %s", sample.SampleNo, test.AgeDays, sample.Grade),
+			Description:       fmt.Sprintf("Pour %s: %s MPa (%s)", sample.PourRef, strings.Join(strengths, ", "), test.Reason),
+			Severity:          models.QCSeverityCritical,
+			RootCauseCategory: "material",
+			RaisedBy:          raisedBy,
+		}
+		if err := insertNCR(tx, &n); err != nil {
+			return nil, err
+		}
+		ncrNos = append(ncrNos, n.NCRNo)
+	}
+	return ncrNos, nil
+}
+
+// ReleaseConcreteSample releases a held sample without the release strength, e.g. under a
+// concession. Only admins can release.
+// @Summary Release concrete sample
+// @Tags Concrete Tests
+// @Accept json
+// @Produce json
+// @Param id path int true "Sample ID"
+// @Param request body models.ConcreteReleaseRequest true "Reason"
+// @Success 200 {object} models.ConcreteSample
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/concrete_samples/{id}/release [put]
+func ReleaseConcreteSample(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var req models.ConcreteReleaseRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		admin, err := isGlobalAdmin(db, session.UserID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user role", "details": err.Error()})
+			return
+		}
+		if !admin {
+			c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can release a sample without its release strength"})
+			return
+		}
+		sample, ok := concreteSampleParam(c, db)
+		if !ok {
+			return
+		}
+		if sample.Status != models.ConcreteSampleTesting && sample.Status != models.ConcreteSampleFailed {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Sample %s is already %s", sample.SampleNo, sample.Status)})
+			return
+		}
+
+		if _, err := db.Exec(`
+			UPDATE concrete_sample SET status = $1, released_at = NOW(), released_by = $2, release_note = $3
+			WHERE id = $4`, models.ConcreteSampleReleased, userName, req.Reason, sample.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to release sample", "details": err.Error()})
+			return
+		}
+
+		updated, err := loadConcreteSample(db, sample.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load sample", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+
+		activityLog := models.ActivityLog{
+			EventContext: "Concrete Test",
+			EventName:    "Release",
+			Description:  fmt.Sprintf("Released sample %s (%s): %s", sample.SampleNo, sample.Status, req.Reason),
+			UserName:     userName,
+			HostName:     session.HostName,
+			IPAddress:    session.IPAddress,
+			CreatedAt:    time.Now(),
+			ProjectID:    sample.ProjectID,
+		}
+		if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+			log.Printf("Failed to save activity log: %v", logErr)
+		}
+	}
+}
+
+// GetConcreteHoldsByProject lists the stockyard elements held by concrete strength.
+// @Summary List elements on strength hold
+// @Tags Concrete Tests
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Success 200 {array} models.ConcreteHold
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/concrete_holds [get]
+func GetConcreteHoldsByProject(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+		rows, err := db.Query(`
+			SELECT DISTINCT ps.element_id FROM precast_stock ps
+			JOIN concrete_sample_element se ON se.element_id = ps.element_id
+			WHERE ps.project_id = $1 AND ps.stockyard = true AND COALESCE(ps.dispatch_status, false) = false`, projectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stockyard elements", "details": err.Error()})
+			return
+		}
+		var elementIDs []int
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read stockyard element", "details": err.Error()})
+				return
+			}
+			elementIDs = append(elementIDs, id)
+		}
+		rows.Close()
+
+		holds, err := ConcreteStrengthHolds(db, elementIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch strength holds", "details": err.Error()})
+			return
+		}
+		sort.Slice(holds, func(i, j int) bool { return holds[i].ElementName < holds[j].ElementName })
+		c.JSON(http.StatusOK, holds)
+	}
+}
@@ -2,6 +2,9 @@ package handlers
 
 import (
 	"backend/models"
+	"backend/storage"
+	"database/sql"
+	"errors"
 	"fmt"
 	"math"
 	"net/http"
@@ -305,7 +308,7 @@ func solve(req models.Request) (models.Response, error) {
 			count = 1
 		}
 		for i := 0; i < count; i++ {
-			allSheets = append(allSheets, models.StockSheet{Width: s.Width, Height: s.Height, Qty: 1})
+			allSheets = append(allSheets, models.StockSheet{Width: s.Width, Height: s.Height, Qty: 1, Name: s.Name})
 		}
 	}
 
@@ -477,6 +480,36 @@ func SolveHandler(c *gin.Context) {
 		return
 	}
 
+	// Remnant inventory needs a project and a session
+	var db *sql.DB
+	var userName string
+	if req.UseRemnants || req.SaveRemnants {
+		if req.ProjectID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id is required to use or save remnants"})
+			return
+		}
+		db = storage.GetDB()
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+			return
+		}
+		var err error
+		if _, userName, err = GetSessionDetails(db, sessionID); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+	}
+	if req.UseRemnants {
+		// Sheets are packed in order, so remnants go ahead of fresh stock
+		remnants, err := loadSheetRemnants(db, req.ProjectID, req.Material)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load remnants", "details": err.Error()})
+			return
+		}
+		req.StockSheets = append(remnants, req.StockSheets...)
+	}
+
 	normalize1D(&req)
 
 	resp, err := solve(req)
@@ -485,5 +518,16 @@ func SolveHandler(c *gin.Context) {
 		return
 	}
 
+	if req.SaveRemnants {
+		if err := saveSheetRemnantRun(db, req, &resp, userName); err != nil {
+			status := http.StatusInternalServerError
+			if errors.Is(err, errRemnantUnavailable) {
+				status = http.StatusConflict
+			}
+			c.JSON(status, gin.H{"error": "Failed to update remnant inventory", "details": err.Error()})
+			return
+		}
+	}
+
 	c.JSON(http.StatusOK, resp)
 }
@@ -0,0 +1,502 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+const (
+	defaultMinUsableOffcut = 1000.0  // mm
+	max1DStockLength       = 50000.0 // mm, keeps the pattern search bounded
+)
+
+// ---------- Internal 1D structs ----------
+type cut1D struct {
+	demand    models.CutDemand
+	units     int
+	remaining int
+}
+
+type stock1D struct {
+	bar       models.StockBar
+	units     int
+	remaining int // -1 => unlimited
+	offcut    bool
+}
+
+func (s *stock1D) fits(diameterMM int) bool {
+	return s.remaining != 0 && (s.bar.DiameterMM == 0 || s.bar.DiameterMM == diameterMM)
+}
+
+// ---------- Pattern search ----------
+
+// bestPattern1D packs as much length as possible into one bar (bounded knapsack over whole mm).
+// Every piece costs its length plus one kerf; the bar gets one kerf of credit because the last
+// piece needs no cut after it unless something is left over. Returns the count per cut and the
+// packed length.
+func bestPattern1D(capacity, kerf int, cuts []*cut1D) ([]int, int) {
+	type item struct{ cut, count, weight, value int }
+
+	counts := make([]int, len(cuts))
+	items := []item{}
+	for i, ct := range cuts {
+		if ct.remaining <= 0 || ct.units > capacity {
+			continue
+		}
+		n := min(ct.remaining, (capacity+kerf)/(ct.units+kerf))
+		// binary splitting keeps the item list small for large quantities
+		for k := 1; n > 0; k <<= 1 {
+			take := min(k, n)
+			items = append(items, item{cut: i, count: take, weight: take * (ct.units + kerf), value: take * ct.units})
+			n -= take
+		}
+	}
+	if len(items) == 0 {
+		return counts, 0
+	}
+
+	capK := capacity + kerf
+	dp := make([]int, capK+1)
+	keep := make([][]bool, len(items))
+	for j, it := range items {
+		keep[j] = make([]bool, capK+1)
+		for c := capK; c >= it.weight; c-- {
+			if v := dp[c-it.weight] + it.value; v > dp[c] {
+				dp[c] = v
+				keep[j][c] = true
+			}
+		}
+	}
+
+	c := capK
+	for j := len(items) - 1; j >= 0; j-- {
+		if keep[j][c] {
+			counts[items[j].cut] += items[j].count
+			c -= items[j].weight
+		}
+	}
+	return counts, dp[capK]
+}
+
+// applyPattern1D records a pattern cut repeat times from stock and updates the remaining demand.
+func applyPattern1D(resp *models.Response1D, req models.Request1D, minUsable float64, diameterMM int,
+	st *stock1D, cuts []*cut1D, counts []int, repeat int) {
+	pattern := models.CuttingPattern{
+		DiameterMM:  diameterMM,
+		StockLength: st.bar.Length,
+		FromOffcut:  st.offcut,
+		StockID:     st.bar.ID,
+		Repeat:      repeat,
+		Pieces:      []models.PatternPiece{},
+	}
+
+	pieces := 0
+	for i, n := range counts {
+		if n == 0 {
+			continue
+		}
+		pattern.Pieces = append(pattern.Pieces, models.PatternPiece{CutID: cuts[i].demand.ID, Length: cuts[i].demand.Length, Qty: n})
+		pattern.UsedLength += cuts[i].demand.Length * float64(n)
+		pieces += n
+		cuts[i].remaining -= n * repeat
+	}
+	sort.Slice(pattern.Pieces, func(i, j int) bool { return pattern.Pieces[i].Length > pattern.Pieces[j].Length })
+
+	leftover := st.bar.Length - pattern.UsedLength - req.CutThickness*float64(pieces-1)
+	if leftover > 0 {
+		// one more cut separates the leftover from the last piece
+		leftover = math.Max(0, leftover-req.CutThickness)
+	}
+	pattern.Leftover = round(math.Max(0, leftover))
+	pattern.KerfLoss = round(st.bar.Length - pattern.UsedLength - pattern.Leftover)
+	pattern.UsedLength = round(pattern.UsedLength)
+	pattern.LeftoverReusable = pattern.Leftover >= minUsable
+
+	if st.remaining > 0 {
+		st.remaining -= repeat
+	}
+
+	resp.Patterns = append(resp.Patterns, pattern)
+	resp.TotalCuts += pieces * repeat
+	resp.TotalStockLength += st.bar.Length * float64(repeat)
+	resp.TotalCutLength += pattern.UsedLength * float64(repeat)
+	resp.TotalKerfLoss += pattern.KerfLoss * float64(repeat)
+	if st.offcut {
+		resp.OffcutsUsed += repeat
+	} else {
+		resp.StockBarsUsed += repeat
+	}
+	if pattern.Leftover > 0 {
+		if pattern.LeftoverReusable {
+			resp.TotalReusableLeftover += pattern.Leftover * float64(repeat)
+		} else {
+			resp.TotalScrap += pattern.Leftover * float64(repeat)
+		}
+	}
+}
+
+// patternRepeat returns how many times a pattern can be cut before a cut's demand or the stock runs out.
+func patternRepeat(st *stock1D, cuts []*cut1D, counts []int) int {
+	repeat := math.MaxInt
+	for i, n := range counts {
+		if n > 0 {
+			repeat = min(repeat, cuts[i].remaining/n)
+		}
+	}
+	if st.remaining > 0 {
+		repeat = min(repeat, st.remaining)
+	}
+	return max(repeat, 1)
+}
+
+// ---------- 1D SOLVER ----------
+
+// solve1D runs a sequential pattern heuristic per bar diameter: offcuts are filled first (shortest first),
+// then each step cuts the best knapsack pattern from the stock length that scores highest for the chosen
+// priority and repeats it while demand allows.
+func solve1D(req models.Request1D) models.Response1D {
+	if req.OptimizationPriority == "" {
+		req.OptimizationPriority = "least_waste"
+	}
+	minUsable := req.MinUsableOffcut
+	if minUsable <= 0 {
+		minUsable = defaultMinUsableOffcut
+	}
+
+	resp := models.Response1D{
+		GeneratedAt:          time.Now().UTC(),
+		OptimizationPriority: req.OptimizationPriority,
+		CutThickness:         req.CutThickness,
+		Patterns:             []models.CuttingPattern{},
+		Leftovers:            []models.LeftoverPiece{},
+		UnableToFit:          []models.UnableToFitCut1D{},
+	}
+	kerf := int(math.Ceil(req.CutThickness - 1e-9))
+
+	// Stock: offcuts first, shortest first, so long remnants stay for long pieces
+	offcuts := []*stock1D{}
+	for _, b := range req.Offcuts {
+		qty := b.Qty
+		if qty <= 0 {
+			qty = 1
+		}
+		offcuts = append(offcuts, &stock1D{bar: b, units: int(math.Floor(b.Length + 1e-9)), remaining: qty, offcut: true})
+	}
+	sort.SliceStable(offcuts, func(i, j int) bool { return offcuts[i].units < offcuts[j].units })
+	stocks := []*stock1D{}
+	for _, b := range req.StockBars {
+		qty := b.Qty
+		if qty <= 0 {
+			qty = -1
+		}
+		stocks = append(stocks, &stock1D{bar: b, units: int(math.Floor(b.Length + 1e-9)), remaining: qty})
+	}
+
+	// Demand grouped by diameter
+	byDiameter := make(map[int][]*cut1D)
+	diameters := []int{}
+	for i, d := range req.Cuts {
+		if strings.TrimSpace(d.ID) == "" {
+			d.ID = fmt.Sprintf("%.0f", d.Length)
+		}
+		if d.Qty <= 0 {
+			continue
+		}
+		resp.TotalRequestedCuts += d.Qty
+		if _, ok := byDiameter[d.DiameterMM]; !ok {
+			diameters = append(diameters, d.DiameterMM)
+		}
+		byDiameter[d.DiameterMM] = append(byDiameter[d.DiameterMM], &cut1D{demand: d, units: int(math.Ceil(d.Length - 1e-9)), remaining: d.Qty})
+		req.Cuts[i] = d
+	}
+	sort.Ints(diameters)
+
+	for _, dia := range diameters {
+		cuts := byDiameter[dia]
+		sort.SliceStable(cuts, func(i, j int) bool { return cuts[i].units > cuts[j].units })
+
+		for _, st := range offcuts {
+			for st.fits(dia) {
+				counts, used := bestPattern1D(st.units, kerf, cuts)
+				if used == 0 {
+					break
+				}
+				applyPattern1D(&resp, req, minUsable, dia, st, cuts, counts, patternRepeat(st, cuts, counts))
+			}
+		}
+
+		for {
+			var best *stock1D
+			var bestCounts []int
+			bestScore, bestUsed := -1.0, 0
+			for _, st := range stocks {
+				if !st.fits(dia) {
+					continue
+				}
+				counts, used := bestPattern1D(st.units, kerf, cuts)
+				if used == 0 {
+					continue
+				}
+				score := float64(used) / float64(st.units)
+				if req.OptimizationPriority == "least_bars" {
+					score = float64(used)
+				}
+				if score > bestScore || (score == bestScore && used > bestUsed) {
+					best, bestCounts, bestScore, bestUsed = st, counts, score, used
+				}
+			}
+			if best == nil {
+				break
+			}
+			applyPattern1D(&resp, req, minUsable, dia, best, cuts, bestCounts, patternRepeat(best, cuts, bestCounts))
+		}
+
+		// Whatever is left could not be cut from the stock given
+		for _, ct := range cuts {
+			if ct.remaining <= 0 {
+				continue
+			}
+			reason := "stock bars exhausted"
+			longest := 0
+			for _, st := range append(append([]*stock1D{}, offcuts...), stocks...) {
+				if st.bar.DiameterMM == 0 || st.bar.DiameterMM == dia {
+					longest = max(longest, st.units)
+				}
+			}
+			if ct.units > longest {
+				reason = "longer than any stock bar of this diameter"
+			}
+			resp.UnableToFit = append(resp.UnableToFit, models.UnableToFitCut1D{Cut: ct.demand, Qty: ct.remaining, Reason: reason})
+		}
+	}
+
+	// Leftover pieces grouped by diameter and length
+	type leftoverKey struct {
+		dia    int
+		length float64
+	}
+	leftovers := make(map[leftoverKey]*models.LeftoverPiece)
+	for _, p := range resp.Patterns {
+		if p.Leftover <= 0 {
+			continue
+		}
+		key := leftoverKey{p.DiameterMM, p.Leftover}
+		if lp, ok := leftovers[key]; ok {
+			lp.Qty += p.Repeat
+			continue
+		}
+		leftovers[key] = &models.LeftoverPiece{DiameterMM: p.DiameterMM, Length: p.Leftover, Qty: p.Repeat, Reusable: p.LeftoverReusable}
+	}
+	for _, lp := range leftovers {
+		resp.Leftovers = append(resp.Leftovers, *lp)
+	}
+	sort.Slice(resp.Leftovers, func(i, j int) bool {
+		if resp.Leftovers[i].DiameterMM != resp.Leftovers[j].DiameterMM {
+			return resp.Leftovers[i].DiameterMM < resp.Leftovers[j].DiameterMM
+		}
+		return resp.Leftovers[i].Length > resp.Leftovers[j].Length
+	})
+
+	resp.TotalStockLength = round(resp.TotalStockLength)
+	resp.TotalCutLength = round(resp.TotalCutLength)
+	resp.TotalKerfLoss = round(resp.TotalKerfLoss)
+	resp.TotalReusableLeftover = round(resp.TotalReusableLeftover)
+	resp.TotalScrap = round(resp.TotalScrap)
+	if resp.TotalStockLength > 0 {
+		resp.WastePercent = round((resp.TotalKerfLoss + resp.TotalScrap) / resp.TotalStockLength * 100.0)
+	}
+	return resp
+}
+
+// ---------- Demand from rebar detail ----------
+
+// rebarCutDemand turns the rebar detail of element types into cut demand for the given element counts.
+func rebarCutDemand(db *sql.DB, elementCounts map[int]int) ([]models.CutDemand, error) {
+	elementTypeIDs := make([]int, 0, len(elementCounts))
+	for id := range elementCounts {
+		elementTypeIDs = append(elementTypeIDs, id)
+	}
+	sort.Ints(elementTypeIDs)
+
+	rebars, err := fetchElementTypeRebars(db, elementTypeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	demand := []models.CutDemand{}
+	for _, elementTypeID := range elementTypeIDs {
+		var code string
+		if err := db.QueryRow(`SELECT element_type FROM element_type WHERE element_type_id = $1`, elementTypeID).Scan(&code); err != nil {
+			code = fmt.Sprintf("ET%d", elementTypeID)
+		}
+		for _, bar := range rebars[elementTypeID] {
+			shape := rebarShapes[bar.ShapeCode]
+			demand = append(demand, models.CutDemand{
+				ID:         code + "/" + bar.BarMark,
+				Length:     math.Round(shape.cutLength(bar, float64(bar.DiameterMM))),
+				DiameterMM: bar.DiameterMM,
+				Qty:        bar.BarCount * elementCounts[elementTypeID],
+			})
+		}
+	}
+	return demand, nil
+}
+
+// taskElementCounts counts the elements of the given tasks that have not been cast yet, per element type.
+func taskElementCounts(db *sql.DB, taskIDs []int) (map[int]int, error) {
+	rows, err := db.Query(`
+		SELECT t.element_type_id, COUNT(a.id)
+		FROM task t
+		JOIN activity a ON a.task_id = t.task_id
+		WHERE t.task_id = ANY($1)
+			AND COALESCE(a.mesh_mold_status, '') <> 'completed'
+		GROUP BY t.element_type_id`, pq.Array(taskIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var elementTypeID, count int
+		if err := rows.Scan(&elementTypeID, &count); err != nil {
+			return nil, err
+		}
+		counts[elementTypeID] += count
+	}
+	return counts, rows.Err()
+}
+
+// Solve1DHandler godoc
+// @Summary      Solve 1D rebar cutting stock
+// @Description  Cuts the requested lengths from offcuts first and then stock bars, minimising waste (or bar count with optimization_priority=least_bars). Demand can come from cuts, an element type's bar bending schedule, or the unfinished elements of tasks. With project_id, use_remnants adds the project's remnant inventory to the offcuts and save_remnants books the run against it.
+// @Tags         calculator
+// @Accept       json
+// @Produce      json
+// @Param        body  body      models.Request1D  true  "Stock bars, offcuts, cuts and kerf"
+// @Success      200   {object}  models.Response1D
+// @Failure      400   {object}  models.ErrorResponse
+// @Failure      401   {object}  models.ErrorResponse
+// @Router       /api/solve-1d [post]
+func Solve1DHandler(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		var req models.Request1D
+
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid JSON: " + err.Error()})
+			return
+		}
+
+		if (req.UseRemnants || req.SaveRemnants) && req.ProjectID <= 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id is required to use or save remnants"})
+			return
+		}
+
+		// Demand and remnants taken from the database need a session
+		var userName string
+		if req.ElementTypeID > 0 || len(req.TaskIDs) > 0 || req.UseRemnants || req.SaveRemnants {
+			sessionID := c.GetHeader("Authorization")
+			if sessionID == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+				return
+			}
+			var err error
+			if _, userName, err = GetSessionDetails(db, sessionID); err != nil {
+				c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+				return
+			}
+		}
+
+		if req.UseRemnants || req.SaveRemnants {
+		}
+		if req.UseRemnants {
+			remnants, err := loadBarRemnants(db, req.ProjectID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load remnants", "details": err.Error()})
+				return
+			}
+			req.Offcuts = append(req.Offcuts, remnants...)
+		}
+
+		if req.ElementTypeID > 0 || len(req.TaskIDs) > 0 {
+			counts := make(map[int]int)
+			if req.ElementTypeID > 0 {
+				elements := req.Elements
+				if elements <= 0 {
+					elements = 1
+				}
+				counts[req.ElementTypeID] += elements
+			}
+			if len(req.TaskIDs) > 0 {
+				taskCounts, err := taskElementCounts(db, req.TaskIDs)
+				if err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch task elements", "details": err.Error()})
+					return
+				}
+				for id, n := range taskCounts {
+					counts[id] += n
+				}
+			}
+			demand, err := rebarCutDemand(db, counts)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to build demand from rebar detail", "details": err.Error()})
+				return
+			}
+			req.Cuts = append(req.Cuts, demand...)
+		}
+
+		// Basic validation
+		if len(req.StockBars) == 0 && len(req.Offcuts) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "stock_bars or offcuts must be provided"})
+			return
+		}
+		if len(req.Cuts) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cuts must be provided, or element_type_id / task_ids with rebar detail"})
+			return
+		}
+		if req.CutThickness < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "cut_thickness must be >= 0"})
+			return
+		}
+		if req.OptimizationPriority != "" && req.OptimizationPriority != "least_waste" && req.OptimizationPriority != "least_bars" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "optimization_priority must be least_waste or least_bars"})
+			return
+		}
+		for _, b := range append(append([]models.StockBar{}, req.StockBars...), req.Offcuts...) {
+			if b.Length <= 0 || b.Length > max1DStockLength {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("stock length must be between 0 and %.0f", max1DStockLength)})
+				return
+			}
+		}
+		for _, d := range req.Cuts {
+			if d.Length <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "cut lengths must be > 0"})
+				return
+			}
+		}
+
+		resp := solve1D(req)
+		if req.SaveRemnants {
+			if err := saveBarRemnantRun(db, req, &resp, userName); err != nil {
+				status := http.StatusInternalServerError
+				if errors.Is(err, errRemnantUnavailable) {
+					status = http.StatusConflict
+				}
+				c.JSON(status, gin.H{"error": "Failed to update remnant inventory", "details": err.Error()})
+				return
+			}
+		}
+
+		c.JSON(http.StatusOK, resp)
+	}
+}
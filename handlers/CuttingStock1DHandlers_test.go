@@ -0,0 +1,177 @@
+package handlers
+
+import (
+	"backend/models"
+	"math"
+	"testing"
+)
+
+func TestBestPattern1D(t *testing.T) {
+	tests := []struct {
+		name     string
+		capacity int
+		kerf     int
+		lengths  []int
+		qty      []int
+		wantUsed int
+	}{
+		{"exact fit without kerf", 10, 0, []int{3, 4}, []int{5, 5}, 10},
+		{"kerf between pieces", 6000, 5, []int{2000}, []int{3}, 4000},
+		{"kerf credit for the last piece", 6010, 5, []int{2000}, []int{3}, 6000},
+		{"limited by demand", 12000, 0, []int{3000}, []int{2}, 6000},
+		{"longer than the bar", 5000, 0, []int{6000}, []int{1}, 0},
+		{"mix beats greedy longest first", 100, 0, []int{60, 50}, []int{1, 2}, 100},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cuts := make([]*cut1D, len(tt.lengths))
+			for i, l := range tt.lengths {
+				cuts[i] = &cut1D{demand: models.CutDemand{Length: float64(l), Qty: tt.qty[i]}, units: l, remaining: tt.qty[i]}
+			}
+			counts, used := bestPattern1D(tt.capacity, tt.kerf, cuts)
+			if used != tt.wantUsed {
+				t.Fatalf("used = %d, want %d (counts %v)", used, tt.wantUsed, counts)
+			}
+			sum, pieces := 0, 0
+			for i, n := range counts {
+				if n > tt.qty[i] {
+					t.Errorf("cut %d used %d times, only %d wanted", i, n, tt.qty[i])
+				}
+				sum += n * tt.lengths[i]
+				pieces += n
+			}
+			if sum != used {
+				t.Errorf("counts %v add up to %d, reported %d", counts, sum, used)
+			}
+			if pieces > 0 && sum+tt.kerf*(pieces-1) > tt.capacity {
+				t.Errorf("pattern %v with kerf needs %d, bar is %d", counts, sum+tt.kerf*(pieces-1), tt.capacity)
+			}
+		})
+	}
+}
+
+func TestSolve1D(t *testing.T) {
+	tests := []struct {
+		name          string
+		req           models.Request1D
+		wantBars      int
+		wantOffcuts   int
+		wantCuts      int
+		wantUnable    int
+		wantReason    string
+		wantScrap     float64
+		wantReusable  float64
+		wantKerfTotal float64
+	}{
+		{
+			name: "exact fit",
+			req: models.Request1D{
+				StockBars: []models.StockBar{{Length: 12000, DiameterMM: 12}},
+				Cuts:      []models.CutDemand{{Length: 3000, DiameterMM: 12, Qty: 8}},
+			},
+			wantBars: 2, wantCuts: 8,
+		},
+		{
+			name: "kerf is charged between pieces and before the leftover",
+			req: models.Request1D{
+				CutThickness: 5,
+				StockBars:    []models.StockBar{{Length: 6000, DiameterMM: 12}},
+				Cuts:         []models.CutDemand{{Length: 2000, DiameterMM: 12, Qty: 2}},
+			},
+			wantBars: 1, wantCuts: 2, wantReusable: 1990, wantKerfTotal: 10,
+		},
+		{
+			name: "short leftover is scrap",
+			req: models.Request1D{
+				StockBars: []models.StockBar{{Length: 12000, DiameterMM: 12}},
+				Cuts:      []models.CutDemand{{Length: 5800, DiameterMM: 12, Qty: 2}},
+			},
+			wantBars: 1, wantCuts: 2, wantScrap: 400,
+		},
+		{
+			name: "offcuts are used before stock bars",
+			req: models.Request1D{
+				StockBars: []models.StockBar{{Length: 12000, DiameterMM: 12}},
+				Offcuts:   []models.StockBar{{Length: 3000, DiameterMM: 12, Qty: 1}},
+				Cuts:      []models.CutDemand{{Length: 3000, DiameterMM: 12, Qty: 1}},
+			},
+			wantOffcuts: 1, wantCuts: 1,
+		},
+		{
+			name: "offcut of another diameter is skipped",
+			req: models.Request1D{
+				StockBars: []models.StockBar{{Length: 12000, DiameterMM: 12}},
+				Offcuts:   []models.StockBar{{Length: 3000, DiameterMM: 16, Qty: 1}},
+				Cuts:      []models.CutDemand{{Length: 3000, DiameterMM: 12, Qty: 1}},
+			},
+			wantBars: 1, wantCuts: 1, wantReusable: 9000,
+		},
+		{
+			name: "limited stock runs out",
+			req: models.Request1D{
+				StockBars: []models.StockBar{{Length: 12000, DiameterMM: 12, Qty: 1}},
+				Cuts:      []models.CutDemand{{Length: 6000, DiameterMM: 12, Qty: 3}},
+			},
+			wantBars: 1, wantCuts: 2, wantUnable: 1, wantReason: "stock bars exhausted",
+		},
+		{
+			name: "cut longer than any bar",
+			req: models.Request1D{
+				StockBars: []models.StockBar{{Length: 12000, DiameterMM: 12}},
+				Cuts:      []models.CutDemand{{Length: 13000, DiameterMM: 12, Qty: 2}},
+			},
+			wantUnable: 2, wantReason: "longer than any stock bar of this diameter",
+		},
+		{
+			name: "no stock of the diameter",
+			req: models.Request1D{
+				StockBars: []models.StockBar{{Length: 12000, DiameterMM: 12}},
+				Cuts:      []models.CutDemand{{Length: 1000, DiameterMM: 16, Qty: 1}},
+			},
+			wantUnable: 1, wantReason: "longer than any stock bar of this diameter",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resp := solve1D(tt.req)
+
+			if resp.StockBarsUsed != tt.wantBars || resp.OffcutsUsed != tt.wantOffcuts {
+				t.Errorf("bars/offcuts used = %d/%d, want %d/%d", resp.StockBarsUsed, resp.OffcutsUsed, tt.wantBars, tt.wantOffcuts)
+			}
+			if resp.TotalCuts != tt.wantCuts {
+				t.Errorf("total cuts = %d, want %d", resp.TotalCuts, tt.wantCuts)
+			}
+			unable := 0
+			for _, u := range resp.UnableToFit {
+				unable += u.Qty
+				if u.Reason != tt.wantReason {
+					t.Errorf("unable to fit reason = %q, want %q", u.Reason, tt.wantReason)
+				}
+			}
+			if unable != tt.wantUnable {
+				t.Errorf("unable to fit = %d, want %d", unable, tt.wantUnable)
+			}
+			if resp.TotalCuts+unable != resp.TotalRequestedCuts {
+				t.Errorf("cut %d + unable %d != requested %d", resp.TotalCuts, unable, resp.TotalRequestedCuts)
+			}
+			if resp.TotalScrap != tt.wantScrap || resp.TotalReusableLeftover != tt.wantReusable || resp.TotalKerfLoss != tt.wantKerfTotal {
+				t.Errorf("scrap/reusable/kerf = %v/%v/%v, want %v/%v/%v", resp.TotalScrap, resp.TotalReusableLeftover,
+					resp.TotalKerfLoss, tt.wantScrap, tt.wantReusable, tt.wantKerfTotal)
+			}
+
+			// Every bar is accounted for: pieces, kerf and leftover add up to its length
+			pieces := 0
+			for _, p := range resp.Patterns {
+				if math.Abs(p.UsedLength+p.KerfLoss+p.Leftover-p.StockLength) > 1e-6 {
+					t.Errorf("pattern %+v: used %v + kerf %v + leftover %v != stock %v", p, p.UsedLength, p.KerfLoss, p.Leftover, p.StockLength)
+				}
+				for _, pc := range p.Pieces {
+					pieces += pc.Qty * p.Repeat
+				}
+			}
+			if pieces != resp.TotalCuts {
+				t.Errorf("patterns hold %d pieces, total cuts %d", pieces, resp.TotalCuts)
+			}
+		})
+	}
+}
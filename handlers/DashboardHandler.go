@@ -7603,11 +7603,15 @@ func GetStockyardReportsByStockyards(db *sql.DB) gin.HandlerFunc {
 				end := start.AddDate(0, 1, -1)
 
 				counts := fetchCountsForRangeViaManager(db, projectID, start, end, userID)
+				transfers := fetchTransferCountsViaManager(db, projectID, start, end, userID)
 				monthlyData = append(monthlyData, gin.H{
-					"name":        start.Month().String(),
-					"checkins":    counts.Casted,
-					"checkouts":   counts.Erected,
-					"adjustments": 0,
+					"name":          start.Month().String(),
+					"checkins":      counts.Casted,
+					"checkouts":     counts.Erected,
+					"adjustments":   0,
+					"transfers_out": transfers.Out,
+					"transfers_in":  transfers.In,
+					"in_transit":    transfers.InTransit,
 				})
 			}
 			c.JSON(http.StatusOK, monthlyData)
@@ -7627,11 +7631,15 @@ func GetStockyardReportsByStockyards(db *sql.DB) gin.HandlerFunc {
 				}
 
 				counts := fetchCountsForRangeViaManager(db, projectID, startRange, endRange, userID)
+				transfers := fetchTransferCountsViaManager(db, projectID, startRange, endRange, userID)
 				rangeData = append(rangeData, gin.H{
-					"name":        fmt.Sprintf("%s to %s", startRange.Format(layout), endRange.Format(layout)),
-					"checkins":    counts.Casted,
-					"checkouts":   counts.Erected,
-					"adjustments": 0,
+					"name":          fmt.Sprintf("%s to %s", startRange.Format(layout), endRange.Format(layout)),
+					"checkins":      counts.Casted,
+					"checkouts":     counts.Erected,
+					"adjustments":   0,
+					"transfers_out": transfers.Out,
+					"transfers_in":  transfers.In,
+					"in_transit":    transfers.InTransit,
 				})
 			}
 			c.JSON(http.StatusOK, rangeData)
@@ -7661,11 +7669,15 @@ func GetStockyardReportsByStockyards(db *sql.DB) gin.HandlerFunc {
 			for i := 0; i < 7; i++ {
 				currentDate := weekStart.AddDate(0, 0, i)
 				counts := fetchCountsForRangeViaManager(db, projectID, currentDate, currentDate, userID)
+				transfers := fetchTransferCountsViaManager(db, projectID, currentDate, currentDate, userID)
 				weekData = append(weekData, gin.H{
-					"name":        currentDate.Format(layout),
-					"checkins":    counts.Casted,
-					"checkouts":   counts.Erected,
-					"adjustments": 0,
+					"name":          currentDate.Format(layout),
+					"checkins":      counts.Casted,
+					"checkouts":     counts.Erected,
+					"adjustments":   0,
+					"transfers_out": transfers.Out,
+					"transfers_in":  transfers.In,
+					"in_transit":    transfers.InTransit,
 				})
 			}
 			c.JSON(http.StatusOK, weekData)
@@ -7772,6 +7784,45 @@ func fetchCountsForRangeViaManager(
 	}
 }
 
+// transferCounts are the elements moved between stockyards over a range
+type transferCounts struct {
+	Out       int
+	In        int
+	InTransit int
+}
+
+// fetchTransferCountsViaManager counts the elements the manager's stockyards sent and received by
+// stock transfer over the range, and those still on the road at its end.
+func fetchTransferCountsViaManager(db *sql.DB, projectID int, start, end time.Time, userID int) transferCounts {
+	var counts transferCounts
+
+	query := `
+		SELECT
+			COUNT(*) FILTER (
+				WHERE from_managed AND DATE(t.dispatched_at) BETWEEN DATE($3) AND DATE($4)
+				  AND i.status <> 'Returned'),
+			COUNT(*) FILTER (
+				WHERE to_managed AND i.status = 'Received'
+				  AND DATE(i.closed_at) BETWEEN DATE($3) AND DATE($4)),
+			COUNT(*) FILTER (
+				WHERE DATE(t.dispatched_at) <= DATE($4)
+				  AND (i.closed_at IS NULL OR DATE(i.closed_at) > DATE($4)))
+		FROM (
+			SELECT t.*,
+				EXISTS (SELECT 1 FROM project_stockyard psy
+					WHERE psy.project_id = t.project_id AND psy.stockyard_id = t.from_stockyard_id AND psy.user_id = $2) AS from_managed,
+				EXISTS (SELECT 1 FROM project_stockyard psy
+					WHERE psy.project_id = t.project_id AND psy.stockyard_id = t.to_stockyard_id AND psy.user_id = $2) AS to_managed
+			FROM stock_transfer t
+			WHERE t.project_id = $1
+		) t
+		JOIN stock_transfer_item i ON i.transfer_id = t.id
+		WHERE t.from_managed OR t.to_managed
+	`
+	_ = db.QueryRow(query, projectID, userID, start, end).Scan(&counts.Out, &counts.In, &counts.InTransit)
+	return counts
+}
+
 // GetElementTypeReportsviaManager godoc
 // @Summary      Get element type reports (assigned to manager)
 // @Tags         dashboard
@@ -44,8 +44,9 @@ func calculateMaterialUsageByGrade(db *sql.DB, projectID int, startDate, endDate
 	return calculateSteelUsageByBOM(db, projectID, startDate, endDate)
 }
 
-// getCompletedElementsCount gets the count of elements that completed Mesh & Mould stage in a specific date range
-func getCompletedElementsCount(db *sql.DB, projectID int, startDate, endDate time.Time) (int, error) {
+// getCompletionStageID returns the stage whose completion marks an element as cast (Mesh & Mould),
+// falling back to the first stage of the project when it isn't configured
+func getCompletionStageID(db *sql.DB, projectID int) (int, error) {
 	// First, get the Mesh & Mould stage ID
 	meshMouldStageID, err := getMeshMouldStageID(db, projectID)
 	if err != nil {
@@ -66,6 +67,15 @@ func getCompletedElementsCount(db *sql.DB, projectID int, startDate, endDate tim
 	} else {
 		log.Printf("DEBUG: Using Mesh & Mould stage ID: %d for element completion count in project %d", meshMouldStageID, projectID)
 	}
+	return meshMouldStageID, nil
+}
+
+// getCompletedElementsCount gets the count of elements that completed Mesh & Mould stage in a specific date range
+func getCompletedElementsCount(db *sql.DB, projectID int, startDate, endDate time.Time) (int, error) {
+	meshMouldStageID, err := getCompletionStageID(db, projectID)
+	if err != nil {
+		return 0, err
+	}
 
 	// Count elements that completed Mesh & Mould stage in the date range
 	query := `
@@ -88,7 +98,9 @@ func getCompletedElementsCount(db *sql.DB, projectID int, startDate, endDate tim
 	return count, nil
 }
 
-// calculateConcreteUsageByVolume calculates concrete usage based on elements that completed Mesh & Mould stage
+// calculateConcreteUsageByVolume calculates concrete usage based on elements that completed Mesh & Mould stage.
+// Pours with batch tickets report the concrete actually delivered; only elements cast outside them are
+// estimated from the BOM.
 func calculateConcreteUsageByVolume(db *sql.DB, projectID int, startDate, endDate time.Time) (map[string]float64, error) {
 	// First, get the count of elements that completed Mesh & Mould stage in this period
 	completedElements, err := getCompletedElementsCount(db, projectID, startDate, endDate)
@@ -97,8 +109,21 @@ func calculateConcreteUsageByVolume(db *sql.DB, projectID int, startDate, endDat
 		return map[string]float64{"M-40": 0.0, "M-60": 0.0}, err
 	}
 
+	ticketed, ticketedElements, err := ticketedPourUsage(db, projectID, startDate, endDate)
+	if err != nil {
+		log.Printf("Error getting batch ticket usage: %v", err)
+		ticketed, ticketedElements = nil, 0
+	}
+	completedElements -= ticketedElements
+	if completedElements < 0 {
+		completedElements = 0
+	}
+
 	// If no elements completed Mesh & Mould stage, return zero usage
 	if completedElements == 0 {
+		if len(ticketed) > 0 {
+			return ticketed, nil
+		}
 		log.Printf("No elements completed Mesh & Mould stage in this period, returning zero usage")
 		return map[string]float64{"M-40": 0.0, "M-60": 0.0}, nil
 	}
@@ -192,6 +217,10 @@ func calculateConcreteUsageByVolume(db *sql.DB, projectID int, startDate, endDat
 		}
 	}
 
+	for grade, volume := range ticketed {
+		usageByGrade[grade] += volume
+	}
+
 	// Ensure we have at least default grades
 	if len(usageByGrade) == 0 {
 		log.Printf("WARNING: No concrete materials found for project %d. Using default values.", projectID)
@@ -217,88 +246,48 @@ func calculateSteelUsageByBOM(db *sql.DB, projectID int, startDate, endDate time
 		return map[string]float64{"TMT Steel": 0.0}, nil
 	}
 
-	// Get steel usage per element from BOM data
-	steelQuery := `
-		SELECT 
-			etb.product_name AS material_name,
-			AVG(etb.quantity) AS avg_quantity_per_element
-		FROM element_type_bom etb
-		WHERE etb.project_id = $1 
-			AND (
-				etb.product_name ILIKE '%steel%' 
-				OR etb.product_name ILIKE '%bar%'
-				OR etb.product_name ILIKE '%rod%'
-				OR etb.product_name ILIKE '%TMT%'
-				OR etb.product_name ILIKE '%rebar%'
-				OR etb.product_name ILIKE '%mm%'
-				OR etb.product_name ILIKE '%Fe%'
-				OR etb.product_name ILIKE '%iron%'
-			)
-		GROUP BY etb.product_name
-		ORDER BY etb.product_name
-	`
-
-	rows, err := db.Query(steelQuery, projectID)
+	meshMouldStageID, err := getCompletionStageID(db, projectID)
 	if err != nil {
-		log.Printf("Error querying steel BOM data: %v", err)
 		return map[string]float64{"TMT Steel": 0.0}, err
 	}
-	defer rows.Close()
-
-	usageByGrade := make(map[string]float64)
-	rowCount := 0
-
-	for rows.Next() {
-		var materialName string
-		var avgQuantityPerElement float64
-		if err := rows.Scan(&materialName, &avgQuantityPerElement); err != nil {
-			log.Printf("Error scanning steel BOM row: %v", err)
-			continue
-		}
-
-		// Calculate total usage: average per element * number of completed elements
-		totalUsage := avgQuantityPerElement * float64(completedElements)
-		usageByGrade[materialName] = totalUsage
-		rowCount++
 
-		log.Printf("DEBUG: Steel material - Name: %s, Avg per element: %f, Completed elements: %d, Total usage: %f",
-			materialName, avgQuantityPerElement, completedElements, totalUsage)
+	// Element types detailed with a bar bending schedule report their rebar weight by diameter
+	bbsUsage, err := calculateSteelUsageByBBS(db, projectID, meshMouldStageID, startDate, endDate)
+	if err != nil {
+		log.Printf("Error calculating steel usage from BBS: %v", err)
+		return map[string]float64{"TMT Steel": 0.0}, err
 	}
 
-	log.Printf("DEBUG: Found %d steel materials from BOM, calculated usage for %d completed elements", rowCount, completedElements)
+	// Sum the steel of each cast element using the BOM it was actually cast with
+	usageByGrade, err := sumCastElementBOMUsage(db, projectID, meshMouldStageID, startDate, endDate, `
+		product_name ILIKE '%steel%'
+		OR product_name ILIKE '%bar%'
+		OR product_name ILIKE '%rod%'
+		OR product_name ILIKE '%TMT%'
+		OR product_name ILIKE '%rebar%'
+		OR product_name ILIKE '%mm%'
+		OR product_name ILIKE '%Fe%'
+		OR product_name ILIKE '%iron%'`)
+	if err != nil {
+		log.Printf("Error querying steel BOM data: %v", err)
+		return map[string]float64{"TMT Steel": 0.0}, err
+	}
 
 	// If no BOM data found, try broader search
 	if len(usageByGrade) == 0 {
 		log.Printf("No steel BOM data found, trying broader search...")
-		broadQuery := `
-			SELECT 
-				etb.product_name AS material_name,
-				AVG(etb.quantity) AS avg_quantity_per_element
-			FROM element_type_bom etb
-			WHERE etb.project_id = $1 
-			AND (
-				etb.product_name ILIKE '%mm%' 
-				OR etb.product_name ILIKE '%bar%'
-			)
-			GROUP BY etb.product_name
-			ORDER BY etb.product_name
-		`
-
-		broadRows, broadErr := db.Query(broadQuery, projectID)
+		broadUsage, broadErr := sumCastElementBOMUsage(db, projectID, meshMouldStageID, startDate, endDate, `
+			product_name ILIKE '%mm%'
+			OR product_name ILIKE '%bar%'`)
 		if broadErr == nil {
-			defer broadRows.Close()
-			for broadRows.Next() {
-				var materialName string
-				var avgQuantityPerElement float64
-				if err := broadRows.Scan(&materialName, &avgQuantityPerElement); err == nil {
-					totalUsage := avgQuantityPerElement * float64(completedElements)
-					usageByGrade[materialName] = totalUsage
-					log.Printf("DEBUG: Found potential steel material - Name: %s, Total usage: %f", materialName, totalUsage)
-				}
-			}
+			usageByGrade = broadUsage
 		}
 	}
 
+	for grade, weight := range bbsUsage {
+		usageByGrade[grade] += weight
+	}
+
 	// Ensure we have at least default steel type
 	if len(usageByGrade) == 0 {
 		log.Printf("WARNING: No steel materials found for project %d. Using default values.", projectID)
@@ -308,6 +297,73 @@ func calculateSteelUsageByBOM(db *sql.DB, projectID int, startDate, endDate time
 	return usageByGrade, nil
 }
 
+// sumCastElementBOMUsage totals BOM quantities per product for the elements cast in a date range.
+// Each element contributes the consumption recorded when it was cast; elements cast before consumption
+// was recorded fall back to the BOM version in force at casting, and then to the current BOM.
+// Element types with rebar detail are left out; their steel comes from the bar bending schedule.
+func sumCastElementBOMUsage(db *sql.DB, projectID, stageID int, startDate, endDate time.Time, productFilter string) (map[string]float64, error) {
+	query := fmt.Sprintf(`
+		WITH cast_elements AS (
+			SELECT cp.element_id, MIN(cp.element_type_id) AS element_type_id, MIN(cp.started_at) AS cast_at
+			FROM complete_production cp
+			WHERE cp.project_id = $1
+				AND cp.stage_id = $2
+				AND cp.started_at >= $3
+				AND cp.started_at <= $4
+				AND NOT EXISTS (SELECT 1 FROM element_type_rebar r WHERE r.element_type_id = cp.element_type_id)
+			GROUP BY cp.element_id
+		),
+		recorded AS (
+			SELECT ec.element_id, ec.product_name, ec.quantity
+			FROM element_bom_consumption ec
+			JOIN cast_elements ce ON ce.element_id = ec.element_id
+		),
+		versioned AS (
+			SELECT ce.element_id, vi.product_name, vi.quantity
+			FROM cast_elements ce
+			JOIN element_type_bom_version v ON v.element_type_id = ce.element_type_id
+				AND v.effective_from <= ce.cast_at
+				AND (v.effective_to IS NULL OR v.effective_to > ce.cast_at)
+			JOIN element_type_bom_version_item vi ON vi.bom_version_id = v.id
+			WHERE NOT EXISTS (SELECT 1 FROM recorded r WHERE r.element_id = ce.element_id)
+		),
+		bom_lines AS (
+			SELECT product_name, quantity FROM recorded
+			UNION ALL
+			SELECT product_name, quantity FROM versioned
+			UNION ALL
+			SELECT etb.product_name, etb.quantity
+			FROM cast_elements ce
+			JOIN element_type_bom etb ON etb.element_type_id = ce.element_type_id AND etb.project_id = $1
+			WHERE NOT EXISTS (SELECT 1 FROM recorded r WHERE r.element_id = ce.element_id)
+				AND NOT EXISTS (SELECT 1 FROM versioned vd WHERE vd.element_id = ce.element_id)
+		)
+		SELECT product_name AS material_name, SUM(quantity) AS total_usage
+		FROM bom_lines
+		WHERE %s
+		GROUP BY product_name
+		ORDER BY product_name
+	`, productFilter)
+
+	rows, err := db.Query(query, projectID, stageID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	usage := make(map[string]float64)
+	for rows.Next() {
+		var materialName string
+		var totalUsage float64
+		if err := rows.Scan(&materialName, &totalUsage); err != nil {
+			log.Printf("Error scanning BOM usage row: %v", err)
+			continue
+		}
+		usage[materialName] = totalUsage
+	}
+	return usage, rows.Err()
+}
+
 // calculateConcreteUsageByGradeRi calculates concrete usage by grade for a specific date range
 func calculateConcreteUsageByGradeRi(db *sql.DB, projectID int, startDate, endDate time.Time) (map[string]float64, error) {
 	return calculateMaterialUsageByGrade(db, projectID, startDate, endDate, MaterialTypeConcrete)
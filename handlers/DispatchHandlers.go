@@ -210,44 +210,64 @@ func logDispatchActivity(db *sql.DB, session models.Session, userName string, pr
 	}
 }
 
-// updatePrecastStockForDispatch updates precast stock records to mark them as dispatched.
-// It updates the dispatch_status to true and sets dispatch_start timestamp for items
-// that are available (dispatch_status = false, stockyard = true) in the specified project.
+// updatePrecastStockForDispatch marks precast stock records as dispatched.
+// It locks the items that are available (dispatch_status = false, stockyard = true, not on a stock transfer)
+// in the specified project and moves those elements to the dispatched lifecycle state, which sets
+// dispatch_status and dispatch_start.
 //
 // Parameters:
 //   - ctx: Request context for timeout and cancellation propagation
 //   - tx: Database transaction
 //   - projectID: Project ID to filter stock items
 //   - elementIDs: Array of element IDs to update
-//   - timestamp: Timestamp to set for dispatch_start
+//   - orderNumber: Order number recorded as the source of the state change
+//   - userID: User ID who is dispatching the items
 //
 // Returns:
 //   - successfullyUpdated: Map of element IDs that were successfully updated
-//   - err: Error if database query fails
-func updatePrecastStockForDispatch(ctx context.Context, tx *sql.Tx, projectID int, elementIDs []int, timestamp time.Time) (map[int]bool, error) {
-	updateStockQuery := `
-		UPDATE precast_stock
-		SET dispatch_status = true, dispatch_start = $1
-		WHERE project_id = $2
-		  AND dispatch_status = false
-		  AND stockyard = true
-		  AND element_id = ANY($3)
-		RETURNING element_id;`
-
-	rows, err := tx.QueryContext(ctx, updateStockQuery, timestamp, projectID, pq.Array(elementIDs))
+//   - err: Error if database query fails, or *models.ErrIllegalTransition if an element can't be dispatched
+func updatePrecastStockForDispatch(ctx context.Context, tx *sql.Tx, projectID int, elementIDs []int, orderNumber string, userID int) (map[int]bool, error) {
+	// Elements on their way to another stockyard can't be dispatched until they are received there
+	availableStockQuery := `
+		SELECT ps.element_id
+		FROM precast_stock ps
+		WHERE ps.project_id = $1
+		  AND ps.dispatch_status = false
+		  AND ps.stockyard = true
+		  AND ps.element_id = ANY($2)
+		  AND NOT ` + inTransitStockSQL + `
+		FOR UPDATE OF ps;`
+
+	rows, err := tx.QueryContext(ctx, availableStockQuery, projectID, pq.Array(elementIDs))
 	if err != nil {
-		return nil, fmt.Errorf("failed to update precast stock: %w", err)
+		return nil, fmt.Errorf("failed to fetch available precast stock: %w", err)
 	}
 	defer rows.Close()
 
 	successfullyUpdated := make(map[int]bool)
+	var available []int
 	for rows.Next() {
 		var itemID int
 		if err := rows.Scan(&itemID); err != nil {
-			return nil, fmt.Errorf("failed to scan updated item ID: %w", err)
+			return nil, fmt.Errorf("failed to scan available item ID: %w", err)
+		}
+		if !successfullyUpdated[itemID] {
+			available = append(available, itemID)
 		}
 		successfullyUpdated[itemID] = true
 	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read available precast stock: %w", err)
+	}
+	rows.Close()
+
+	var userName string
+	if err := tx.QueryRowContext(ctx, `SELECT COALESCE(first_name || ' ' || last_name, '') FROM users WHERE id = $1`, userID).Scan(&userName); err != nil && err != sql.ErrNoRows {
+		return nil, fmt.Errorf("failed to fetch user name: %w", err)
+	}
+	if err := TransitionElements(tx, available, models.LifecycleDispatched, "Dispatch order "+orderNumber, userName); err != nil {
+		return nil, err
+	}
 
 	return successfullyUpdated, nil
 }
@@ -485,6 +505,7 @@ func createOrUpdateVehicle(ctx context.Context, tx *sql.Tx, vehicleNumber, drive
 // Returns:
 //   - response: Success response containing order details
 //   - err: Error if any step fails, including ErrItemsUnavailable if items are not available
+//     and ErrConcreteStrengthHold if elements have not reached their release strength
 func createDispatchTransaction(ctx context.Context, db *sql.DB, req *models.DispatchOrderRequest, userID int) (gin.H, error) {
 	// Start a transaction
 	tx, err := db.BeginTx(ctx, nil)
@@ -517,8 +538,21 @@ func createDispatchTransaction(ctx context.Context, db *sql.DB, req *models.Disp
 			req.ProjectID, req.VehicleNumber, vehicleID, req.DriverName, req.Items)
 	}
 
+	// Elements cast from a pour that has not reached its release strength stay in the stockyard.
+	// The element rows stay locked until commit so a sample can't be linked or failed meanwhile.
+	if err := lockConcreteHoldElements(tx, req.Items); err != nil {
+		return nil, fmt.Errorf("failed to lock elements: %w", err)
+	}
+	holds, err := ConcreteStrengthHolds(tx, req.Items)
+	if err != nil {
+		return nil, fmt.Errorf("failed to check concrete strength holds: %w", err)
+	}
+	if len(holds) > 0 {
+		return nil, &models.ErrConcreteStrengthHold{Holds: holds}
+	}
+
 	// Step 1: Update precast stock and validate availability
-	successfullyUpdated, err := updatePrecastStockForDispatch(ctx, tx, req.ProjectID, req.Items, now)
+	successfullyUpdated, err := updatePrecastStockForDispatch(ctx, tx, req.ProjectID, req.Items, orderNumber, userID)
 	if err != nil {
 		log.Printf("Error updating precast stock: %v", err)
 		return nil, err
@@ -593,7 +627,7 @@ func createDispatchTransaction(ctx context.Context, db *sql.DB, req *models.Disp
 //
 // CreateAndSaveDispatchOrder godoc
 // @Summary      Create dispatch order
-// @Description  Create and save a new dispatch order with items and vehicle details
+// @Description  Create and save a new dispatch order with items and vehicle details. Elements linked to a concrete sample that has not reached its release strength, or failed at 28 days, are refused with 409; elements with no linked sample are not held.
 // @Tags         dispatch
 // @Accept       json
 // @Produce      json
@@ -601,6 +635,7 @@ func createDispatchTransaction(ctx context.Context, db *sql.DB, req *models.Disp
 // @Success      200   {object}  object
 // @Failure      400   {object}  models.ErrorResponse
 // @Failure      401   {object}  models.ErrorResponse
+// @Failure      409   {object}  models.ErrorResponse
 // @Failure      500   {object}  models.ErrorResponse
 // @Router       /api/dispatch_order [post]
 func CreateAndSaveDispatchOrder(db *sql.DB) gin.HandlerFunc {
@@ -675,6 +710,22 @@ func CreateAndSaveDispatchOrder(db *sql.DB) gin.HandlerFunc {
 				})
 				return
 			}
+			var illegalErr *models.ErrIllegalTransition
+			if errors.As(err, &illegalErr) {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":     "Some elements can't be dispatched from the state they are in",
+					"conflicts": illegalErr.Elements,
+				})
+				return
+			}
+			var holdErr *models.ErrConcreteStrengthHold
+			if errors.As(err, &holdErr) {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":         "Some elements have not reached their release strength",
+					"held_elements": holdErr.Holds,
+				})
+				return
+			}
 			// For all other errors, log the details and return a more specific error message
 			log.Printf("Transaction failed: %v", err)
 			c.JSON(http.StatusInternalServerError, gin.H{
@@ -1603,49 +1654,6 @@ func fetchElementIDsFromDispatchOrder(tx *sql.Tx, orderID string) ([]int, error)
 	return elementIDs, nil
 }
 
-// updatePrecastStockForReceipt updates precast stock records when a dispatch order is received.
-//
-// Parameters:
-//   - tx: Database transaction
-//   - elementIDs: Array of element IDs to update
-//
-// Returns:
-//   - err: Error if database update fails
-func updatePrecastStockForReceipt(tx *sql.Tx, elementIDs []int) error {
-	_, err := tx.Exec(`
-		UPDATE precast_stock 
-		SET recieve_in_erection = true,
-			updated_at = NOW(),
-			dispatch_end = NOW()
-		WHERE element_id = ANY($1)`,
-		pq.Array(elementIDs))
-	if err != nil {
-		return fmt.Errorf("bulk update failed for precast_stock: %w", err)
-	}
-	return nil
-}
-
-// updateStockErectedForReceipt updates stock_erected records when a dispatch order is received.
-//
-// Parameters:
-//   - tx: Database transaction
-//   - elementIDs: Array of element IDs to update
-//
-// Returns:
-//   - err: Error if database update fails
-func updateStockErectedForReceipt(tx *sql.Tx, elementIDs []int) error {
-	_, err := tx.Exec(`
-		UPDATE stock_erected 
-		SET recieve_in_erection = true,
-			action_approve_or_reject = NOW()
-		WHERE element_id = ANY($1)`,
-		pq.Array(elementIDs))
-	if err != nil {
-		return fmt.Errorf("bulk update failed for stock_erected: %w", err)
-	}
-	return nil
-}
-
 // updateStockErectedLogsForReceipt updates stock_erected_logs records when a dispatch order is received.
 //
 // Parameters:
@@ -1670,32 +1678,12 @@ func updateStockErectedLogsForReceipt(tx *sql.Tx, elementIDs []int) error {
 	return nil
 }
 
-// updateElementsForReceipt updates element status when a dispatch order is received.
-//
-// Parameters:
-//   - tx: Database transaction
-//   - elementIDs: Array of element IDs to update
-//
-// Returns:
-//   - err: Error if database update fails
-func updateElementsForReceipt(tx *sql.Tx, elementIDs []int) error {
-	_, err := tx.Exec(`
-		UPDATE element
-		SET status = 'In Erection'
-		WHERE id = ANY($1)`,
-		pq.Array(elementIDs))
-	if err != nil {
-		return fmt.Errorf("failed to update elements: %w", err)
-	}
-	return nil
-}
-
 // ReceiveDispatchOrderByErection handles the receipt of a dispatch order at the erection site.
 // This endpoint updates multiple database tables to reflect that the dispatch order has been received:
 //   - Updates dispatch_orders and dispatch_details status to "Accepted"
-//   - Updates precast_stock to mark items as received
-//   - Updates stock_erected and stock_erected_logs
-//   - Updates element status to "In Erection"
+//   - Moves the elements to the received lifecycle state, which marks precast_stock and stock_erected
+//     as received and sets element status to "In Erection"
+//   - Updates stock_erected_logs
 //   - Sends notifications to relevant users
 //   - Logs the activity
 //
@@ -1800,9 +1788,18 @@ func ReceiveDispatchOrderByErection(db *sql.DB) gin.HandlerFunc {
 
 		// Bulk update all related tables if there are elements
 		if len(elementIDs) > 0 {
-			// Update precast_stock
-			if err := updatePrecastStockForReceipt(tx, elementIDs); err != nil {
-				log.Printf("Bulk update failed for precast_stock: %v", err)
+			// Move the elements to received, which updates precast_stock, stock_erected and element status
+			if err := TransitionElements(tx, elementIDs, models.LifecycleReceived, "Dispatch order received", userName); err != nil {
+				var illegalErr *models.ErrIllegalTransition
+				if errors.As(err, &illegalErr) {
+					c.JSON(http.StatusConflict, gin.H{
+						"error":     "Some elements can't be received from the state they are in",
+						"conflicts": illegalErr.Elements,
+						"order_id":  orderID,
+					})
+					return
+				}
+				log.Printf("Lifecycle update failed for dispatch receipt: %v", err)
 				c.JSON(http.StatusInternalServerError, gin.H{
 					"error":    "Failed to update precast stock",
 					"details":  err.Error(),
@@ -1812,18 +1809,6 @@ func ReceiveDispatchOrderByErection(db *sql.DB) gin.HandlerFunc {
 				return
 			}
 
-			// Update stock_erected
-			if err := updateStockErectedForReceipt(tx, elementIDs); err != nil {
-				log.Printf("Bulk update failed for stock_erected: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error":    "Failed to update stock erected",
-					"details":  err.Error(),
-					"code":     "STOCK_ERECTED_BULK_UPDATE_ERROR",
-					"order_id": orderID,
-				})
-				return
-			}
-
 			// Update stock_erected_logs
 			if err := updateStockErectedLogsForReceipt(tx, elementIDs); err != nil {
 				log.Printf("Bulk update failed for stock_erected_logs: %v", err)
@@ -1835,13 +1820,6 @@ func ReceiveDispatchOrderByErection(db *sql.DB) gin.HandlerFunc {
 				})
 				return
 			}
-
-			// Update element status
-			if err := updateElementsForReceipt(tx, elementIDs); err != nil {
-				log.Printf("Failed to update elements: %v", err)
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update elements"})
-				return
-			}
 		}
 
 		// Get project ID and order number before commit
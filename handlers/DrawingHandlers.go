@@ -77,6 +77,10 @@ func CreateDrawing(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		if err := StartDrawingRevisionReview(db, drawing.DrawingsId, userName); err != nil {
+			log.Printf("Failed to open drawing review: %v", err)
+		}
+
 		c.JSON(http.StatusOK, drawing)
 
 		// Get project name for notification
@@ -242,6 +246,15 @@ func UpdateDrawing(c *gin.Context, drawing models.Drawings) (int, error) {
 		return 0, fmt.Errorf("failed to update drawing: %v", err)
 	}
 
+	// Every new version starts its review as a draft
+	reviewer := drawing.UpdatedBy
+	if reviewer == "" {
+		reviewer = drawing.CreatedBy
+	}
+	if err := StartDrawingRevisionReview(db, currentDrawing.DrawingsId, reviewer); err != nil {
+		log.Printf("Failed to open drawing review: %v", err)
+	}
+
 	return drawingRevision.DrawingsRevisionId, nil
 
 }
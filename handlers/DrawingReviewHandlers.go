@@ -0,0 +1,757 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// StartDrawingRevisionReview opens a draft review for the current version of a drawing.
+// Called whenever a drawing is created or gets a new version.
+func StartDrawingRevisionReview(q sqlQueryer, drawingID int, createdBy string) error {
+	_, err := q.Exec(`
+		INSERT INTO drawing_revision_review (drawing_id, version, project_id, element_type_id, drawing_type_id, file, status, created_by)
+		SELECT drawing_id, COALESCE(current_version, ''), project_id, COALESCE(element_type_id, 0), COALESCE(drawing_type_id, 0),
+		       COALESCE(file, ''), $2, $3
+		FROM drawings WHERE drawing_id = $1
+		ON CONFLICT (drawing_id, version) DO NOTHING`,
+		drawingID, models.DrawingRevisionDraft, createdBy)
+	return err
+}
+
+// drawingApproverRoles are the project roles allowed to approve and issue drawings,
+// from DRAWING_APPROVER_ROLES (comma separated, default "design lead")
+func drawingApproverRoles() []string {
+	roles := os.Getenv("DRAWING_APPROVER_ROLES")
+	if roles == "" {
+		roles = "design lead"
+	}
+	var out []string
+	for _, r := range strings.Split(roles, ",") {
+		if r = normaliseRoleName(r); r != "" {
+			out = append(out, r)
+		}
+	}
+	return out
+}
+
+func normaliseRoleName(role string) string {
+	return strings.Join(strings.Fields(strings.ToLower(strings.ReplaceAll(role, "_", " "))), " ")
+}
+
+// isDrawingApprover reports whether a user may approve drawings of a project: admins, or
+// project members holding a design lead role
+func isDrawingApprover(db *sql.DB, userID, projectID int) (bool, error) {
+	var globalRole string
+	err := db.QueryRow(`SELECT r.role_name FROM users u JOIN roles r ON u.role_id = r.role_id WHERE u.id = $1`, userID).Scan(&globalRole)
+	if err != nil && err != sql.ErrNoRows {
+		return false, err
+	}
+	if g := normaliseRoleName(globalRole); g == "superadmin" || g == "admin" {
+		return true, nil
+	}
+
+	rows, err := db.Query(`
+		SELECT r.role_name FROM project_members pm JOIN roles r ON pm.role_id = r.role_id
+		WHERE pm.project_id = $1 AND pm.user_id = $2`, projectID, userID)
+	if err != nil {
+		return false, err
+	}
+	defer rows.Close()
+	approvers := drawingApproverRoles()
+	for rows.Next() {
+		var role string
+		if err := rows.Scan(&role); err != nil {
+			return false, err
+		}
+		for _, a := range approvers {
+			if normaliseRoleName(role) == a {
+				return true, nil
+			}
+		}
+	}
+	return false, rows.Err()
+}
+
+const drawingRevisionReviewColumns = `
+	r.id, r.drawing_id, r.version, r.project_id, r.element_type_id, r.drawing_type_id, COALESCE(dt.drawing_type_name, ''),
+	r.file, r.status, r.created_by, r.created_at, r.submitted_by, r.submitted_at, r.approved_by, r.approved_at,
+	r.issued_by, r.issued_at`
+
+func scanDrawingRevisionReview(row interface{ Scan(...any) error }) (models.DrawingRevisionReview, error) {
+	var r models.DrawingRevisionReview
+	var submittedAt, approvedAt, issuedAt sql.NullTime
+	err := row.Scan(&r.ID, &r.DrawingID, &r.Version, &r.ProjectID, &r.ElementTypeID, &r.DrawingTypeID, &r.DrawingTypeName,
+		&r.File, &r.Status, &r.CreatedBy, &r.CreatedAt, &r.SubmittedBy, &submittedAt, &r.ApprovedBy, &approvedAt,
+		&r.IssuedBy, &issuedAt)
+	if submittedAt.Valid {
+		r.SubmittedAt = &submittedAt.Time
+	}
+	if approvedAt.Valid {
+		r.ApprovedAt = &approvedAt.Time
+	}
+	if issuedAt.Valid {
+		r.IssuedAt = &issuedAt.Time
+	}
+	return r, err
+}
+
+// loadDrawingRevisions returns every version of a drawing, newest first. The current
+// version comes from drawings, older ones from drawings_revision; versions without a
+// review record predate the workflow and are reported as legacy.
+func loadDrawingRevisions(db *sql.DB, drawingID int) ([]models.DrawingRevisionReview, error) {
+
+	var current models.DrawingRevisionReview
+	var version, file, createdBy sql.NullString
+	var elementTypeID, drawingTypeID sql.NullInt64
+	err := db.QueryRow(`
+		SELECT d.drawing_id, d.project_id, d.element_type_id, d.drawing_type_id, COALESCE(dt.drawing_type_name, ''),
+		       d.current_version, d.file, d.created_by, COALESCE(d.update_at, d.created_at)
+		FROM drawings d LEFT JOIN drawing_type dt ON dt.drawing_type_id = d.drawing_type_id
+		WHERE d.drawing_id = $1`, drawingID).
+		Scan(&current.DrawingID, &current.ProjectID, &elementTypeID, &drawingTypeID, &current.DrawingTypeName,
+			&version, &file, &createdBy, &current.CreatedAt)
+	if err != nil {
+		return nil, err
+	}
+	current.Version, current.File, current.CreatedBy = version.String, file.String, createdBy.String
+	current.ElementTypeID, current.DrawingTypeID = int(elementTypeID.Int64), int(drawingTypeID.Int64)
+	current.IsCurrent = true
+	current.Status = models.DrawingRevisionLegacy
+
+	revisions := []models.DrawingRevisionReview{current}
+	rows, err := db.Query(`
+		SELECT dr.version, COALESCE(dr.file, ''), COALESCE(dr.created_by, ''), dr.created_at,
+		       COALESCE(dr.drawing_type_id, 0), COALESCE(dt.drawing_type_name, '')
+		FROM drawings_revision dr LEFT JOIN drawing_type dt ON dt.drawing_type_id = dr.drawing_type_id
+		WHERE dr.parent_drawing_id = $1 AND dr.version IS NOT NULL AND dr.version <> $2
+		ORDER BY dr.created_at DESC`, drawingID, current.Version)
+	if err != nil {
+		return nil, err
+	}
+	for rows.Next() {
+		r := models.DrawingRevisionReview{DrawingID: drawingID, ProjectID: current.ProjectID, ElementTypeID: current.ElementTypeID,
+			Status: models.DrawingRevisionLegacy}
+		if err := rows.Scan(&r.Version, &r.File, &r.CreatedBy, &r.CreatedAt, &r.DrawingTypeID, &r.DrawingTypeName); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		revisions = append(revisions, r)
+	}
+	rows.Close()
+
+	// Overlay the review records
+	reviewRows, err := db.Query(`SELECT `+drawingRevisionReviewColumns+`
+		FROM drawing_revision_review r LEFT JOIN drawing_type dt ON dt.drawing_type_id = r.drawing_type_id
+		WHERE r.drawing_id = $1`, drawingID)
+	if err != nil {
+		return nil, err
+	}
+	defer reviewRows.Close()
+	for reviewRows.Next() {
+		review, err := scanDrawingRevisionReview(reviewRows)
+		if err != nil {
+			return nil, err
+		}
+		for i := range revisions {
+			if revisions[i].Version == review.Version {
+				review.IsCurrent = revisions[i].IsCurrent
+				review.CreatedAt = revisions[i].CreatedAt
+				revisions[i] = review
+			}
+		}
+	}
+	return revisions, reviewRows.Err()
+}
+
+// loadDrawingRevisionComments returns the comment trail of a review, oldest first
+func loadDrawingRevisionComments(db *sql.DB, reviewID int) ([]models.DrawingRevisionComment, error) {
+	rows, err := db.Query(`
+		SELECT id, review_id, action, comment, created_by, created_at
+		FROM drawing_revision_comment WHERE review_id = $1 ORDER BY created_at, id`, reviewID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	comments := []models.DrawingRevisionComment{}
+	for rows.Next() {
+		var cm models.DrawingRevisionComment
+		if err := rows.Scan(&cm.ID, &cm.ReviewID, &cm.Action, &cm.Comment, &cm.CreatedBy, &cm.CreatedAt); err != nil {
+			return nil, err
+		}
+		comments = append(comments, cm)
+	}
+	return comments, rows.Err()
+}
+
+// DrawingCastingBlockers returns the current drawing revisions of an element type that are not
+// yet approved. Casting must wait until the list is empty. Drawings whose current version
+// predates the review workflow do not block.
+func DrawingCastingBlockers(q sqlQueryer, elementTypeID int) ([]models.DrawingRevisionReview, error) {
+	rows, err := q.Query(`SELECT `+drawingRevisionReviewColumns+`
+		FROM drawings d
+		JOIN drawing_revision_review r ON r.drawing_id = d.drawing_id AND r.version = d.current_version
+		LEFT JOIN drawing_type dt ON dt.drawing_type_id = r.drawing_type_id
+		WHERE d.element_type_id = $1 AND r.status NOT IN ($2, $3)
+		ORDER BY r.drawing_id`, elementTypeID, models.DrawingRevisionApproved, models.DrawingRevisionIssued)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	blockers := []models.DrawingRevisionReview{}
+	for rows.Next() {
+		r, err := scanDrawingRevisionReview(rows)
+		if err != nil {
+			return nil, err
+		}
+		r.IsCurrent = true
+		blockers = append(blockers, r)
+	}
+	return blockers, rows.Err()
+}
+
+// drawingCastingBlockMessage describes why casting is blocked, or "" when it is not
+func drawingCastingBlockMessage(blockers []models.DrawingRevisionReview) string {
+	if len(blockers) == 0 {
+		return ""
+	}
+	parts := make([]string, 0, len(blockers))
+	for _, b := range blockers {
+		name := b.DrawingTypeName
+		if name == "" {
+			name = fmt.Sprintf("drawing %d", b.DrawingID)
+		}
+		parts = append(parts, fmt.Sprintf("%s %s is %s", name, b.Version, b.Status))
+	}
+	return "Casting is blocked until the latest drawing revisions are approved: " + strings.Join(parts, "; ")
+}
+
+// drawingRevisionTransition is one step of the review lifecycle
+type drawingRevisionTransition struct {
+	from, to       string
+	needsApprover  bool
+	needsComment   bool
+	pastTense      string
+	timestampField string // column prefix recording who/when, "" for none
+}
+
+var drawingRevisionTransitions = map[string]drawingRevisionTransition{
+	"submit":  {from: models.DrawingRevisionDraft, to: models.DrawingRevisionSubmitted, pastTense: "submitted", timestampField: "submitted"},
+	"approve": {from: models.DrawingRevisionSubmitted, to: models.DrawingRevisionApproved, needsApprover: true, pastTense: "approved", timestampField: "approved"},
+	"reject":  {from: models.DrawingRevisionSubmitted, to: models.DrawingRevisionDraft, needsApprover: true, needsComment: true, pastTense: "returned to draft"},
+	"issue":   {from: models.DrawingRevisionApproved, to: models.DrawingRevisionIssued, needsApprover: true, pastTense: "issued for production", timestampField: "issued"},
+}
+
+// transitionDrawingRevision moves the current version of a drawing through the review lifecycle
+func transitionDrawingRevision(db *sql.DB, action string) gin.HandlerFunc {
+	transition := drawingRevisionTransitions[action]
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+			return
+		}
+		session, userName, err := GetSessionDetails(db, sessionID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		drawingID, err := strconv.Atoi(c.Param("drawing_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid drawing id"})
+			return
+		}
+		version := c.Param("version")
+
+		var req models.DrawingRevisionActionRequest
+		if c.Request.ContentLength > 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+				return
+			}
+		}
+		req.Comment = strings.TrimSpace(req.Comment)
+		if transition.needsComment && req.Comment == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "A comment is required to " + action + " a revision"})
+			return
+		}
+
+		var currentVersion sql.NullString
+		var projectID int
+		err = db.QueryRow(`SELECT current_version, project_id FROM drawings WHERE drawing_id = $1`, drawingID).Scan(&currentVersion, &projectID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Drawing not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
+			return
+		}
+		if version != currentVersion.String {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Revision %s has been superseded by %s", version, currentVersion.String)})
+			return
+		}
+
+		if transition.needsApprover {
+			ok, err := isDrawingApprover(db, session.UserID, projectID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check approver role", "details": err.Error()})
+				return
+			}
+			if !ok {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Only the design lead can " + action + " drawing revisions"})
+				return
+			}
+		}
+
+		// Versions from before the workflow get their review record on first use
+		if err := StartDrawingRevisionReview(db, drawingID, userName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open drawing review", "details": err.Error()})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+
+		var reviewID int
+		var status string
+		err = tx.QueryRow(`SELECT id, status FROM drawing_revision_review WHERE drawing_id = $1 AND version = $2 FOR UPDATE`,
+			drawingID, version).Scan(&reviewID, &status)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load drawing review", "details": err.Error()})
+			return
+		}
+		if status != transition.from {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Cannot %s a revision that is %s", action, status)})
+			return
+		}
+
+		update := `UPDATE drawing_revision_review SET status = $2 WHERE id = $1`
+		args := []any{reviewID, transition.to}
+		if transition.timestampField != "" {
+			update = fmt.Sprintf(`UPDATE drawing_revision_review SET status = $2, %[1]s_by = $3, %[1]s_at = NOW() WHERE id = $1`, transition.timestampField)
+			args = append(args, userName)
+		}
+		if _, err := tx.Exec(update, args...); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update drawing review", "details": err.Error()})
+			return
+		}
+		if _, err := tx.Exec(`INSERT INTO drawing_revision_comment (review_id, action, comment, created_by) VALUES ($1, $2, $3, $4)`,
+			reviewID, action, req.Comment, userName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record review comment", "details": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		review, err := scanDrawingRevisionReview(db.QueryRow(`SELECT `+drawingRevisionReviewColumns+`
+			FROM drawing_revision_review r LEFT JOIN drawing_type dt ON dt.drawing_type_id = r.drawing_type_id
+			WHERE r.id = $1`, reviewID))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load drawing review", "details": err.Error()})
+			return
+		}
+		review.IsCurrent = true
+		c.JSON(http.StatusOK, review)
+
+		if action == "issue" {
+			notifyDrawingRevisionIssued(db, review)
+		}
+
+		activityLog := models.ActivityLog{
+			EventContext: "Drawing Revision",
+			EventName:    strings.ToUpper(action[:1]) + action[1:],
+			Description:  fmt.Sprintf("Drawing %d revision %s %s", drawingID, version, transition.pastTense),
+			UserName:     userName,
+			HostName:     session.HostName,
+			IPAddress:    session.IPAddress,
+			CreatedAt:    time.Now(),
+			ProjectID:    projectID,
+		}
+		if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+			log.Printf("Failed to save activity log: %v", logErr)
+		}
+	}
+}
+
+// notifyDrawingRevisionIssued tells the assignees of open activities of the element type that
+// a new revision is in force
+func notifyDrawingRevisionIssued(db *sql.DB, review models.DrawingRevisionReview) {
+	rows, err := db.Query(`
+		SELECT DISTINCT user_id FROM (
+			SELECT a.assigned_to AS user_id
+			FROM activity a JOIN task t ON t.task_id = a.task_id
+			WHERE t.element_type_id = $1 AND COALESCE(a.status, '') <> 'completed'
+			UNION
+			SELECT ps.assigned_to
+			FROM activity a
+			JOIN task t ON t.task_id = a.task_id
+			JOIN project_stages ps ON ps.id = a.stage_id
+			WHERE t.element_type_id = $1 AND COALESCE(a.status, '') <> 'completed'
+		) assignees
+		WHERE user_id IS NOT NULL AND user_id <> 0`, review.ElementTypeID)
+	if err != nil {
+		log.Printf("Failed to fetch activity assignees for element type %d: %v", review.ElementTypeID, err)
+		return
+	}
+	var userIDs []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err == nil {
+			userIDs = append(userIDs, id)
+		}
+	}
+	rows.Close()
+	if len(userIDs) == 0 {
+		return
+	}
+
+	elementTypeName, err := FetchElementTypeName(review.ElementTypeID)
+	if err != nil {
+		elementTypeName = fmt.Sprintf("element type %d", review.ElementTypeID)
+	}
+	drawingName := review.DrawingTypeName
+	if drawingName == "" {
+		drawingName = "Drawing"
+	}
+	message := fmt.Sprintf("%s %s issued for production for %s", drawingName, review.Version, elementTypeName)
+	action := fmt.Sprintf("https://precastezy.blueinvent.com/project/%d/drawing", review.ProjectID)
+
+	for _, id := range userIDs {
+		sendUserNotification(db, id, message, action)
+	}
+	SendNotificationToUsersHelper(db, userIDs, "Drawing revision issued", message, map[string]string{
+		"type":            "drawing_revision_issued",
+		"drawing_id":      strconv.Itoa(review.DrawingID),
+		"version":         review.Version,
+		"element_type_id": strconv.Itoa(review.ElementTypeID),
+	})
+}
+
+// GetDrawingRevisionReviews lists every version of a drawing with its review status and comments.
+// @Summary List drawing revisions with review status
+// @Tags Drawing Revisions
+// @Produce json
+// @Param drawing_id path int true "Drawing ID"
+// @Success 200 {array} models.DrawingRevisionReview
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/drawings/{drawing_id}/revisions [get]
+func GetDrawingRevisionReviews(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+			return
+		}
+		if _, _, err := GetSessionDetails(db, sessionID); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		drawingID, err := strconv.Atoi(c.Param("drawing_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid drawing id"})
+			return
+		}
+
+		revisions, err := loadDrawingRevisions(db, drawingID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Drawing not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch drawing revisions", "details": err.Error()})
+			return
+		}
+		for i := range revisions {
+			if revisions[i].ID == 0 {
+				continue
+			}
+			if revisions[i].Comments, err = loadDrawingRevisionComments(db, revisions[i].ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch review comments", "details": err.Error()})
+				return
+			}
+		}
+		c.JSON(http.StatusOK, revisions)
+	}
+}
+
+// AddDrawingRevisionComment adds a review comment to a drawing revision.
+// @Summary Comment on a drawing revision
+// @Tags Drawing Revisions
+// @Accept json
+// @Produce json
+// @Param drawing_id path int true "Drawing ID"
+// @Param version path string true "Revision, e.g. RV-02"
+// @Param request body models.DrawingRevisionActionRequest true "Comment"
+// @Success 201 {object} models.DrawingRevisionComment
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/drawings/{drawing_id}/revisions/{version}/comments [post]
+func AddDrawingRevisionComment(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+			return
+		}
+		_, userName, err := GetSessionDetails(db, sessionID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		drawingID, err := strconv.Atoi(c.Param("drawing_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid drawing id"})
+			return
+		}
+		version := c.Param("version")
+
+		var req models.DrawingRevisionActionRequest
+		if err := c.ShouldBindJSON(&req); err != nil || strings.TrimSpace(req.Comment) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "comment is required"})
+			return
+		}
+
+		revisions, err := loadDrawingRevisions(db, drawingID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Drawing not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch drawing revisions", "details": err.Error()})
+			return
+		}
+		var target *models.DrawingRevisionReview
+		for i := range revisions {
+			if revisions[i].Version == version {
+				target = &revisions[i]
+			}
+		}
+		if target == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+			return
+		}
+		if target.ID == 0 {
+			if !target.IsCurrent {
+				c.JSON(http.StatusConflict, gin.H{"error": "Revisions from before the review workflow cannot be commented on"})
+				return
+			}
+			if err := StartDrawingRevisionReview(db, drawingID, userName); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to open drawing review", "details": err.Error()})
+				return
+			}
+			db.QueryRow(`SELECT id FROM drawing_revision_review WHERE drawing_id = $1 AND version = $2`, drawingID, version).Scan(&target.ID)
+		}
+
+		comment := models.DrawingRevisionComment{ReviewID: target.ID, Action: "comment", Comment: strings.TrimSpace(req.Comment), CreatedBy: userName}
+		err = db.QueryRow(`
+			INSERT INTO drawing_revision_comment (review_id, action, comment, created_by)
+			VALUES ($1, $2, $3, $4) RETURNING id, created_at`,
+			comment.ReviewID, comment.Action, comment.Comment, comment.CreatedBy).Scan(&comment.ID, &comment.CreatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save comment", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, comment)
+	}
+}
+
+// SubmitDrawingRevision submits the current drawing revision for review.
+// @Summary Submit a drawing revision for review
+// @Tags Drawing Revisions
+// @Accept json
+// @Produce json
+// @Param drawing_id path int true "Drawing ID"
+// @Param version path string true "Revision, e.g. RV-02"
+// @Param request body models.DrawingRevisionActionRequest false "Optional comment"
+// @Success 200 {object} models.DrawingRevisionReview
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/drawings/{drawing_id}/revisions/{version}/submit [post]
+func SubmitDrawingRevision(db *sql.DB) gin.HandlerFunc {
+	return transitionDrawingRevision(db, "submit")
+}
+
+// ApproveDrawingRevision approves a submitted drawing revision. Design lead only.
+// @Summary Approve a drawing revision
+// @Tags Drawing Revisions
+// @Accept json
+// @Produce json
+// @Param drawing_id path int true "Drawing ID"
+// @Param version path string true "Revision, e.g. RV-02"
+// @Param request body models.DrawingRevisionActionRequest false "Optional comment"
+// @Success 200 {object} models.DrawingRevisionReview
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/drawings/{drawing_id}/revisions/{version}/approve [post]
+func ApproveDrawingRevision(db *sql.DB) gin.HandlerFunc {
+	return transitionDrawingRevision(db, "approve")
+}
+
+// RejectDrawingRevision returns a submitted drawing revision to draft. Design lead only.
+// @Summary Reject a drawing revision
+// @Tags Drawing Revisions
+// @Accept json
+// @Produce json
+// @Param drawing_id path int true "Drawing ID"
+// @Param version path string true "Revision, e.g. RV-02"
+// @Param request body models.DrawingRevisionActionRequest true "Reason"
+// @Success 200 {object} models.DrawingRevisionReview
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/drawings/{drawing_id}/revisions/{version}/reject [post]
+func RejectDrawingRevision(db *sql.DB) gin.HandlerFunc {
+	return transitionDrawingRevision(db, "reject")
+}
+
+// IssueDrawingRevision issues an approved drawing revision for production and notifies the
+// assignees of open activities of the element type. Design lead only.
+// @Summary Issue a drawing revision for production
+// @Tags Drawing Revisions
+// @Accept json
+// @Produce json
+// @Param drawing_id path int true "Drawing ID"
+// @Param version path string true "Revision, e.g. RV-02"
+// @Param request body models.DrawingRevisionActionRequest false "Optional comment"
+// @Success 200 {object} models.DrawingRevisionReview
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/drawings/{drawing_id}/revisions/{version}/issue [post]
+func IssueDrawingRevision(db *sql.DB) gin.HandlerFunc {
+	return transitionDrawingRevision(db, "issue")
+}
+
+// GetDrawingRevisionDiff compares two versions of a drawing.
+// @Summary Diff two drawing revisions
+// @Tags Drawing Revisions
+// @Produce json
+// @Param drawing_id path int true "Drawing ID"
+// @Param from query string true "Older revision, e.g. RV-01"
+// @Param to query string false "Newer revision (default: current)"
+// @Success 200 {object} models.DrawingRevisionDiff
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/drawings/{drawing_id}/revisions/diff [get]
+func GetDrawingRevisionDiff(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+			return
+		}
+		if _, _, err := GetSessionDetails(db, sessionID); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		drawingID, err := strconv.Atoi(c.Param("drawing_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid drawing id"})
+			return
+		}
+		fromVersion, toVersion := c.Query("from"), c.Query("to")
+		if fromVersion == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from is required"})
+			return
+		}
+
+		revisions, err := loadDrawingRevisions(db, drawingID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Drawing not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch drawing revisions", "details": err.Error()})
+			return
+		}
+		if toVersion == "" {
+			toVersion = revisions[0].Version
+		}
+		var from, to *models.DrawingRevisionReview
+		for i := range revisions {
+			if revisions[i].Version == fromVersion {
+				from = &revisions[i]
+			}
+			if revisions[i].Version == toVersion {
+				to = &revisions[i]
+			}
+		}
+		if from == nil || to == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Revision not found"})
+			return
+		}
+
+		diff := models.DrawingRevisionDiff{DrawingID: drawingID, From: *from, To: *to, Changes: []models.DrawingRevisionFieldChange{}}
+		compare := func(field, a, b string) {
+			if a != b {
+				diff.Changes = append(diff.Changes, models.DrawingRevisionFieldChange{Field: field, From: a, To: b})
+			}
+		}
+		compare("file", from.File, to.File)
+		compare("drawing_type", from.DrawingTypeName, to.DrawingTypeName)
+		compare("created_by", from.CreatedBy, to.CreatedBy)
+		compare("status", from.Status, to.Status)
+
+		// Content-addressed uploads tell whether a re-uploaded file actually changed
+		if from.File != "" && to.File != "" {
+			a, errA := resolveStoredFile(db, from.File)
+			b, errB := resolveStoredFile(db, to.File)
+			if errA == nil && errB == nil && a.SHA256 != "" && b.SHA256 != "" {
+				same := a.SHA256 == b.SHA256
+				diff.SameContent = &same
+			}
+		}
+		c.JSON(http.StatusOK, diff)
+	}
+}
+
+// GetElementTypeDrawingRelease tells whether casting is allowed for an element type.
+// @Summary Drawing release status of an element type
+// @Tags Drawing Revisions
+// @Produce json
+// @Param element_type_id path int true "Element type ID"
+// @Success 200 {object} models.DrawingReleaseStatus
+// @Failure 400 {object} models.ErrorResponse
+// @Router /api/element_types/{element_type_id}/drawing_release [get]
+func GetElementTypeDrawingRelease(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+			return
+		}
+		if _, _, err := GetSessionDetails(db, sessionID); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		elementTypeID, err := strconv.Atoi(c.Param("element_type_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid element type id"})
+			return
+		}
+		blockers, err := DrawingCastingBlockers(db, elementTypeID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check drawing release", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, models.DrawingReleaseStatus{ElementTypeID: elementTypeID, Released: len(blockers) == 0, Blocking: blockers})
+	}
+}
@@ -2,7 +2,6 @@ package handlers
 
 import (
 	"backend/models"
-	"backend/repository"
 	"backend/storage"
 	"database/sql"
 	"fmt"
@@ -12,7 +11,6 @@ import (
 	"strconv"
 	"strings"
 	"time"
-	"unicode"
 
 	"github.com/gin-gonic/gin"
 )
@@ -56,10 +54,24 @@ func CreateElements(c *gin.Context, jsondata models.ElementInput) {
 	}
 	defer stmtInsert.Close()
 
+	// The IDs are reserved in the transaction that inserts the elements, so a failed insert hands them back
+	tx, err := db.Begin()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to begin transaction"})
+		return
+	}
+	defer tx.Rollback()
+
+	elementIDs, err := GenerateElementIDs(tx, jsondata.ElementTypeID, jsondata.HierarchyId, jsondata.NamingConvention, jsondata.Quantity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate element IDs", "details": err.Error()})
+		return
+	}
+
 	// Insert elements
 	for i := 1; i <= jsondata.Quantity; i++ {
-		elementID := repository.GenerateElementID(jsondata.ElementType, jsondata.NamingConvention, jsondata.TotalCountElement+i)
-		_, err := stmtInsert.Exec(
+		elementID := elementIDs[i-1]
+		_, err := tx.Stmt(stmtInsert).Exec(
 			jsondata.ElementTypeID, elementID, jsondata.ElementTypeName, jsondata.ProjectID,
 			createdBy, time.Now(), 1, jsondata.ElementTypeVersion,
 			time.Now(), jsondata.HierarchyId, // Make sure the field name matches your struct
@@ -74,12 +86,17 @@ func CreateElements(c *gin.Context, jsondata models.ElementInput) {
 	}
 
 	// Update the total_count_element
-	_, err = db.Exec(`UPDATE element_type SET total_count_element = $1 WHERE element_type_id = $2`, jsondata.TotalCountElement+jsondata.Quantity, jsondata.ElementTypeID)
+	_, err = tx.Exec(`UPDATE element_type SET total_count_element = $1 WHERE element_type_id = $2`, jsondata.TotalCountElement+jsondata.Quantity, jsondata.ElementTypeID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update element_type count", "details": err.Error()})
 		return
 	}
 
+	if err := tx.Commit(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+		return
+	}
+
 	c.JSON(http.StatusOK, gin.H{"message": "Elements inserted successfully"})
 
 	// Get session ID from header for notifications
@@ -145,48 +162,6 @@ func UpdtateElements(c *gin.Context, jsondata models.ElementInput) {
 	}
 	defer stmtInsert.Close()
 
-	queryLastValue := `SELECT element_id FROM element WHERE element_type_id = $1 ORDER BY element_id DESC LIMIT 1`
-	rowlast := db.QueryRow(queryLastValue, jsondata.ElementTypeID)
-
-	var lastvalue string
-	var lastValue int
-
-	if err := rowlast.Scan(&lastvalue); err != nil {
-		if err == sql.ErrNoRows {
-			// Initialize to 0 for first-time insertion
-			lastValue = 0
-			fmt.Println("No previous elements found. Starting with element ID 1")
-		} else {
-			fmt.Println("Error querying element_type table:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retrieve element_type data"})
-			return
-		}
-	} else {
-		// Strip letters from lastvalue
-		lastvalue = strings.Map(func(r rune) rune {
-			if unicode.IsLetter(r) {
-				return -1 // Remove the letter
-			}
-			return r // Keep non-letters
-		}, lastvalue)
-
-		if len(lastvalue) >= 4 {
-			lastvalue = lastvalue[len(lastvalue)-4:]
-			fmt.Println("Last 4 digits:", lastvalue)
-		} else {
-			fmt.Println("Not enough digits available.")
-			lastValue = 0 // Initialize to 0 if not enough digits
-		}
-
-		var err error
-		lastValue, err = strconv.Atoi(lastvalue)
-		if err != nil {
-			fmt.Println("Error converting to integer:", err)
-			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to convert element_id to integer"})
-			return
-		}
-	}
-
 	query := `SELECT element_type,element_type_name, project_id, element_type_version, total_count_element FROM element_type WHERE element_type_id = $1`
 	row := db.QueryRow(query, jsondata.ElementTypeID)
 	fmt.Println("Element Type Id", jsondata.ElementTypeID)
@@ -212,8 +187,14 @@ func UpdtateElements(c *gin.Context, jsondata models.ElementInput) {
 	}
 	defer tx.Rollback()
 
+	elementIDs, err := GenerateElementIDs(tx, jsondata.ElementTypeID, jsondata.HierarchyId, jsondata.NamingConvention, jsondata.Quantity)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate element IDs", "details": err.Error()})
+		return
+	}
+
 	for i := 1; i <= jsondata.Quantity; i++ {
-		elementID := repository.GenerateElementID(ElementType, jsondata.NamingConvention, lastValue+i)
+		elementID := elementIDs[i-1]
 		TotalCountElement++
 		element := models.Element{
 
@@ -1713,6 +1694,22 @@ func GetElementLifecycleHandler(db *sql.DB) gin.HandlerFunc {
 			}
 		}
 
+		// Step 6: Non-conformance reports
+		ncrs, err := elementNCRs(db, elementID)
+		if err != nil {
+			log.Printf("Error fetching NCRs: %v", err)
+			ncrs = []models.NCR{}
+		}
+		for _, n := range ncrs {
+			lifecycle = append(lifecycle, Event{fmt.Sprintf("%s raised - %s", n.NCRNo, n.Title), n.RaisedAt, ""})
+			if n.DispositionedAt != nil {
+				lifecycle = append(lifecycle, Event{fmt.Sprintf("%s dispositioned - %s", n.NCRNo, n.Disposition), *n.DispositionedAt, ""})
+			}
+			if n.ClosedAt != nil {
+				lifecycle = append(lifecycle, Event{fmt.Sprintf("%s closed", n.NCRNo), *n.ClosedAt, n.ClosedAt.Sub(n.RaisedAt).String()})
+			}
+		}
+
 		// Sort lifecycle by timestamp
 		sort.Slice(lifecycle, func(i, j int) bool {
 			return lifecycle[i].Timestamp.Before(lifecycle[j].Timestamp)
@@ -1723,6 +1720,7 @@ func GetElementLifecycleHandler(db *sql.DB) gin.HandlerFunc {
 			"element_name": elementName,
 			"project_id":   projectID,
 			"lifecycle":    lifecycle,
+			"ncrs":         ncrs,
 		})
 
 		log := models.ActivityLog{
@@ -0,0 +1,600 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// LifecycleRepairSource is the source recorded for states set by a consistency repair
+const LifecycleRepairSource = "Consistency repair"
+
+// lifecycleElementStatus is the element.status an element carries in each state. The states before
+// the stockyard leave the status to production.
+var lifecycleElementStatus = map[string]string{
+	models.LifecycleInYard:     "In Stockyard",
+	models.LifecycleDispatched: "Dispatch",
+	models.LifecycleReceived:   "In Erection",
+	models.LifecycleErected:    "Erected",
+	models.LifecycleHandedOver: "Handed Over",
+}
+
+// SQL statements for the element lifecycle
+const (
+	// selectElementStateSQL reads everything the state of an element is derived from: its latest
+	// precast_stock and stock_erected rows, whether production started and the recorded state.
+	selectElementStateSQL = `
+		SELECT e.id, e.project_id, COALESCE(e.element_name, ''), COALESCE(e.status::text, ''),
+			COALESCE(e.disable, FALSE), COALESCE(l.state, ''),
+			EXISTS (SELECT 1 FROM activity a WHERE a.element_id = e.id),
+			(SELECT COUNT(*) FROM precast_stock x WHERE x.element_id = e.id),
+			COALESCE(ps.stockyard, FALSE), COALESCE(ps.dispatch_status, FALSE),
+			COALESCE(ps.recieve_in_erection, FALSE), COALESCE(ps.erected, FALSE),
+			se.id IS NOT NULL, COALESCE(se.recieve_in_erection, FALSE), COALESCE(se.erected, FALSE)
+		FROM element e
+		LEFT JOIN element_lifecycle l ON l.element_id = e.id
+		LEFT JOIN LATERAL (
+			SELECT * FROM precast_stock x WHERE x.element_id = e.id ORDER BY x.id DESC LIMIT 1
+		) ps ON TRUE
+		LEFT JOIN LATERAL (
+			SELECT * FROM stock_erected y WHERE y.element_id = e.id ORDER BY y.id DESC LIMIT 1
+		) se ON TRUE`
+)
+
+// elementState is an element with the rows its lifecycle state is read from.
+type elementState struct {
+	ElementID        int
+	ProjectID        int
+	ElementName      string
+	Status           string
+	Disabled         bool
+	StoredState      string
+	HasActivity      bool
+	StockRows        int
+	InYard           bool
+	Dispatched       bool
+	Received         bool
+	Erected          bool
+	HasErection      bool
+	ErectionReceived bool
+	ErectionErected  bool
+}
+
+// derived is the state the stock and erection rows show. Either table is enough to place an element
+// at the site.
+func (s elementState) derived() string {
+	switch {
+	case s.Erected || s.ErectionErected:
+		if s.Status == lifecycleElementStatus[models.LifecycleHandedOver] {
+			return models.LifecycleHandedOver
+		}
+		return models.LifecycleErected
+	case s.Received || s.ErectionReceived:
+		return models.LifecycleReceived
+	case s.Dispatched:
+		return models.LifecycleDispatched
+	case s.InYard:
+		return models.LifecycleInYard
+	case s.StockRows > 0:
+		return models.LifecycleQCPassed
+	case s.HasActivity:
+		return models.LifecycleInProduction
+	default:
+		return models.LifecyclePlanned
+	}
+}
+
+// current is the furthest of the recorded and derived states.
+func (s elementState) current() string {
+	derived := s.derived()
+	if lifecycleIndex(s.StoredState) > lifecycleIndex(derived) {
+		return s.StoredState
+	}
+	return derived
+}
+
+// lifecycleIndex is the position of a state in models.LifecycleStates, or -1.
+func lifecycleIndex(state string) int {
+	for i, s := range models.LifecycleStates {
+		if s == state {
+			return i
+		}
+	}
+	return -1
+}
+
+// elementLifecycleAdmin is sessionContext for the consistency endpoints, which only admins may use.
+func elementLifecycleAdmin(c *gin.Context, db *sql.DB) (session models.Session, userName string, ok bool) {
+	session, userName, ok = sessionContext(c, db)
+	if !ok {
+		return session, "", false
+	}
+	admin, err := isGlobalAdmin(db, session.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user role", "details": err.Error()})
+		return session, "", false
+	}
+	if !admin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can check element lifecycle consistency"})
+		return session, "", false
+	}
+	return session, userName, true
+}
+
+// loadElementStates reads the elements matching the given filter.
+func loadElementStates(q sqlQueryer, where string, args ...interface{}) ([]elementState, error) {
+	rows, err := q.Query(selectElementStateSQL+" "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var states []elementState
+	for rows.Next() {
+		var s elementState
+		if err := rows.Scan(&s.ElementID, &s.ProjectID, &s.ElementName, &s.Status,
+			&s.Disabled, &s.StoredState, &s.HasActivity, &s.StockRows,
+			&s.InYard, &s.Dispatched, &s.Received, &s.Erected,
+			&s.HasErection, &s.ErectionReceived, &s.ErectionErected); err != nil {
+			return nil, err
+		}
+		states = append(states, s)
+	}
+	return states, rows.Err()
+}
+
+// applyLifecycleState brings the precast_stock, stock_erected and element rows of the elements in line
+// with a state. Flags are only ever set, and timestamps are only stamped when their flag is, so
+// applying a state twice changes nothing.
+func applyLifecycleState(q sqlQueryer, elementIDs []int, state string) error {
+	idx := lifecycleIndex(state)
+	ids := pq.Array(elementIDs)
+
+	var stock []string
+	if idx >= lifecycleIndex(models.LifecycleInYard) {
+		stock = append(stock, "stockyard = TRUE")
+	}
+	if idx >= lifecycleIndex(models.LifecycleDispatched) {
+		stock = append(stock,
+			"dispatch_start = CASE WHEN COALESCE(dispatch_status, FALSE) THEN dispatch_start ELSE NOW() END",
+			"dispatch_status = TRUE")
+	}
+	if idx >= lifecycleIndex(models.LifecycleReceived) {
+		stock = append(stock,
+			"dispatch_end = CASE WHEN COALESCE(recieve_in_erection, FALSE) THEN dispatch_end ELSE NOW() END",
+			"recieve_in_erection = TRUE", "updated_at = NOW()")
+	}
+	if idx >= lifecycleIndex(models.LifecycleErected) {
+		stock = append(stock, "erected = TRUE")
+	}
+	if len(stock) > 0 {
+		if _, err := q.Exec(`UPDATE precast_stock SET `+strings.Join(stock, ", ")+` WHERE element_id = ANY($1)`, ids); err != nil {
+			return fmt.Errorf("failed to update precast_stock: %w", err)
+		}
+	}
+
+	var erection []string
+	if idx >= lifecycleIndex(models.LifecycleReceived) {
+		erection = append(erection,
+			"action_approve_or_reject = CASE WHEN COALESCE(recieve_in_erection, FALSE) THEN action_approve_or_reject ELSE NOW() END",
+			"recieve_in_erection = TRUE")
+	}
+	if idx >= lifecycleIndex(models.LifecycleErected) {
+		erection = append(erection, "erected = TRUE")
+	}
+	if len(erection) > 0 {
+		if _, err := q.Exec(`UPDATE stock_erected SET `+strings.Join(erection, ", ")+` WHERE element_id = ANY($1)`, ids); err != nil {
+			return fmt.Errorf("failed to update stock_erected: %w", err)
+		}
+	}
+
+	if status, ok := lifecycleElementStatus[state]; ok {
+		if _, err := q.Exec(`UPDATE element SET status = $2 WHERE id = ANY($1)`, ids, status); err != nil {
+			return fmt.Errorf("failed to update element status: %w", err)
+		}
+	}
+	return nil
+}
+
+// recordLifecycleState records the state of elements that were all in the same state before.
+func recordLifecycleState(q sqlQueryer, elementIDs []int, from, to, source, changedBy string) error {
+	ids := pq.Array(elementIDs)
+	if _, err := q.Exec(`
+		INSERT INTO element_lifecycle (element_id, project_id, state, changed_by, changed_at)
+		SELECT e.id, e.project_id, $2, $3, NOW() FROM element e WHERE e.id = ANY($1)
+		ON CONFLICT (element_id) DO UPDATE
+		SET state = EXCLUDED.state, changed_by = EXCLUDED.changed_by, changed_at = EXCLUDED.changed_at`,
+		ids, to, changedBy); err != nil {
+		return fmt.Errorf("failed to record lifecycle state: %w", err)
+	}
+	if _, err := q.Exec(`
+		INSERT INTO element_lifecycle_log (element_id, from_state, to_state, source, changed_by)
+		SELECT e.id, $2, $3, $4, $5 FROM element e WHERE e.id = ANY($1)`,
+		ids, from, to, source, changedBy); err != nil {
+		return fmt.Errorf("failed to record lifecycle log: %w", err)
+	}
+	return nil
+}
+
+// lifecycleMove is what a transition does to each element it was asked to move.
+type lifecycleMove struct {
+	moving     map[string][]int // elements one state behind, by the state they leave
+	unrecorded map[string][]int // elements already in the state but not recorded in it, by recorded state
+}
+
+// planLifecycleTransition applies the transition rules to the loaded states: an element may only move
+// one state forward, and one already in the state stays as it is. Disabled and unknown elements and
+// those in any other state make the whole transition illegal.
+func planLifecycleTransition(byID map[int]elementState, elementIDs []int, to string) (lifecycleMove, error) {
+	move := lifecycleMove{moving: map[string][]int{}, unrecorded: map[string][]int{}}
+	toIdx := lifecycleIndex(to)
+	if toIdx < 0 {
+		return move, fmt.Errorf("unknown lifecycle state %q", to)
+	}
+
+	var conflicts []models.ElementStateConflict
+	seen := map[int]bool{}
+	for _, id := range elementIDs {
+		if seen[id] {
+			continue
+		}
+		seen[id] = true
+		s, ok := byID[id]
+		switch {
+		case !ok:
+			conflicts = append(conflicts, models.ElementStateConflict{ElementID: id, State: "unknown"})
+			continue
+		case s.Disabled:
+			conflicts = append(conflicts, models.ElementStateConflict{ElementID: id, State: "disabled"})
+			continue
+		}
+		current := s.current()
+		switch lifecycleIndex(current) {
+		case toIdx:
+			if s.StoredState != to {
+				move.unrecorded[s.StoredState] = append(move.unrecorded[s.StoredState], id)
+			}
+		case toIdx - 1:
+			move.moving[current] = append(move.moving[current], id)
+		default:
+			conflicts = append(conflicts, models.ElementStateConflict{ElementID: id, State: current})
+		}
+	}
+	if len(conflicts) > 0 {
+		return move, &models.ErrIllegalTransition{To: to, Elements: conflicts}
+	}
+	return move, nil
+}
+
+// TransitionElements moves elements to a lifecycle state and updates every table that reflects it.
+// Elements may only move one state forward; elements already in the state are left as they are.
+// When any element can't make the move, nothing is changed and *models.ErrIllegalTransition is
+// returned. Run it in the transaction that makes the move so the element rows stay locked.
+func TransitionElements(q sqlQueryer, elementIDs []int, to, source, changedBy string) error {
+	if lifecycleIndex(to) < 0 {
+		return fmt.Errorf("unknown lifecycle state %q", to)
+	}
+	if len(elementIDs) == 0 {
+		return nil
+	}
+
+	states, err := loadElementStates(q, "WHERE e.id = ANY($1) FOR NO KEY UPDATE OF e", pq.Array(elementIDs))
+	if err != nil {
+		return fmt.Errorf("failed to read element states: %w", err)
+	}
+	byID := make(map[int]elementState, len(states))
+	for _, s := range states {
+		byID[s.ElementID] = s
+	}
+
+	move, err := planLifecycleTransition(byID, elementIDs, to)
+	if err != nil {
+		return err
+	}
+	var moving []int
+	for _, ids := range move.moving {
+		moving = append(moving, ids...)
+	}
+	if len(moving) > 0 {
+		if err := applyLifecycleState(q, moving, to); err != nil {
+			return err
+		}
+	}
+	for from, ids := range move.moving {
+		if err := recordLifecycleState(q, ids, from, to, source, changedBy); err != nil {
+			return err
+		}
+	}
+	for from, ids := range move.unrecorded {
+		if err := recordLifecycleState(q, ids, from, to, source, changedBy); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// checkElementState lists what is inconsistent about an element and the state a repair brings it to.
+// The target is the furthest state any of its rows show.
+func checkElementState(s elementState) models.LifecycleIssue {
+	derived := s.derived()
+	issue := models.LifecycleIssue{
+		ElementID:    s.ElementID,
+		ElementName:  s.ElementName,
+		StoredState:  s.StoredState,
+		DerivedState: derived,
+		TargetState:  s.current(),
+		Problems:     []string{},
+		Repairable:   true,
+	}
+	add := func(format string, args ...interface{}) {
+		issue.Problems = append(issue.Problems, fmt.Sprintf(format, args...))
+	}
+
+	needsStock := lifecycleIndex(models.LifecycleQCPassed)
+	if s.StockRows == 0 && lifecycleIndex(issue.TargetState) >= needsStock {
+		add("no precast_stock row for an element in state %s", issue.TargetState)
+		if lifecycleIndex(derived) >= needsStock {
+			issue.Repairable = false
+		} else {
+			issue.TargetState = derived
+		}
+	}
+	if s.StockRows > 1 {
+		add("%d precast_stock rows for the element", s.StockRows)
+		issue.Repairable = false
+	}
+	if s.StockRows > 0 {
+		if s.Dispatched && !s.InYard {
+			add("precast_stock is dispatched but was never received in the stockyard")
+		}
+		if s.Received && !s.Dispatched {
+			add("precast_stock is received at site but not dispatched")
+		}
+		if s.Erected && !s.Dispatched {
+			add("precast_stock is erected but not dispatched")
+		}
+		if s.HasErection && s.ErectionReceived != s.Received {
+			add("stock_erected and precast_stock disagree on receipt at site")
+		}
+		if s.HasErection && s.ErectionErected != s.Erected {
+			add("stock_erected and precast_stock disagree on erection")
+		}
+	}
+	if s.StoredState != "" && s.StoredState != derived {
+		add("recorded as %s but the stock and erection rows show %s", s.StoredState, derived)
+	}
+	if status, ok := lifecycleElementStatus[issue.TargetState]; ok && s.Status != status {
+		add("element status is %q, expected %q", s.Status, status)
+	}
+	return issue
+}
+
+// checkProjectLifecycle checks the elements of a project, or only those given.
+func checkProjectLifecycle(q sqlQueryer, projectID int, elementIDs []int) (models.LifecycleCheckReport, []elementState, error) {
+	report := models.LifecycleCheckReport{ProjectID: projectID, Issues: []models.LifecycleIssue{}}
+	where, args := "WHERE e.project_id = $1", []interface{}{projectID}
+	if len(elementIDs) > 0 {
+		where += " AND e.id = ANY($2)"
+		args = append(args, pq.Array(elementIDs))
+	}
+	states, err := loadElementStates(q, where+" ORDER BY e.id", args...)
+	if err != nil {
+		return report, nil, err
+	}
+	report.Checked = len(states)
+	var inconsistent []elementState
+	for _, s := range states {
+		issue := checkElementState(s)
+		if len(issue.Problems) == 0 {
+			continue
+		}
+		report.Issues = append(report.Issues, issue)
+		inconsistent = append(inconsistent, s)
+	}
+	report.Inconsistent = len(report.Issues)
+	return report, inconsistent, nil
+}
+
+// saveElementLifecycleActivity writes the activity log of a lifecycle check or repair.
+func saveElementLifecycleActivity(db *sql.DB, session models.Session, userName, eventName, description string, projectID int) {
+	activityLog := models.ActivityLog{
+		EventContext: "ElementLifecycle",
+		EventName:    eventName,
+		Description:  description,
+		UserName:     userName,
+		HostName:     session.HostName,
+		IPAddress:    session.IPAddress,
+		CreatedAt:    time.Now(),
+		ProjectID:    projectID,
+	}
+	if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+		log.Printf("Failed to save activity log: %v", logErr)
+	}
+}
+
+// GetElementLifecycle returns the lifecycle state of an element with its history.
+// @Summary Get element lifecycle
+// @Tags Element Lifecycle
+// @Produce json
+// @Param id path int true "Element ID"
+// @Success 200 {object} models.ElementLifecycle
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/elements/{id}/lifecycle [get]
+func GetElementLifecycle(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid element ID"})
+			return
+		}
+
+		states, err := loadElementStates(db, "WHERE e.id = $1", id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch element state", "details": err.Error()})
+			return
+		}
+		if len(states) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Element not found"})
+			return
+		}
+		s := states[0]
+		lifecycle := models.ElementLifecycle{
+			ElementID:    s.ElementID,
+			ProjectID:    s.ProjectID,
+			State:        s.current(),
+			StoredState:  s.StoredState,
+			DerivedState: s.derived(),
+			History:      []models.ElementLifecycleLog{},
+		}
+
+		rows, err := db.Query(`
+			SELECT from_state, to_state, source, changed_by, changed_at
+			FROM element_lifecycle_log WHERE element_id = $1 ORDER BY changed_at, id`, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch lifecycle history", "details": err.Error()})
+			return
+		}
+		defer rows.Close()
+		for rows.Next() {
+			var entry models.ElementLifecycleLog
+			if err := rows.Scan(&entry.FromState, &entry.ToState, &entry.Source, &entry.ChangedBy, &entry.ChangedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read lifecycle history", "details": err.Error()})
+				return
+			}
+			lifecycle.History = append(lifecycle.History, entry)
+		}
+		c.JSON(http.StatusOK, lifecycle)
+	}
+}
+
+// CheckElementLifecycle finds the elements of a project whose element, precast_stock and
+// stock_erected rows disagree about where the element is. Nothing is changed.
+// @Summary Check element lifecycle consistency
+// @Tags Element Lifecycle
+// @Produce json
+// @Param project_id query int true "Project ID"
+// @Success 200 {object} models.LifecycleCheckReport
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/admin/element_lifecycle/check [get]
+func CheckElementLifecycle(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := elementLifecycleAdmin(c, db)
+		if !ok {
+			return
+		}
+		projectID, err := strconv.Atoi(c.Query("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+
+		report, _, err := checkProjectLifecycle(db, projectID, nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check element lifecycle", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, report)
+
+		saveElementLifecycleActivity(db, session, userName, "Get",
+			fmt.Sprintf("Checked element lifecycle: %d of %d elements inconsistent", report.Inconsistent, report.Checked), projectID)
+	}
+}
+
+// RepairElementLifecycle brings the inconsistent elements of a project to the furthest state any of
+// their rows show, filling in the rows that lag behind. Elements with duplicate stock rows, or shown
+// at the site without a stock row, are reported but left for manual correction.
+// @Summary Repair element lifecycle consistency
+// @Tags Element Lifecycle
+// @Accept json
+// @Produce json
+// @Param request body models.LifecycleRepairRequest true "Repair"
+// @Success 200 {object} models.LifecycleCheckReport
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Router /api/admin/element_lifecycle/repair [post]
+func RepairElementLifecycle(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := elementLifecycleAdmin(c, db)
+		if !ok {
+			return
+		}
+		var req models.LifecycleRepairRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction", "details": err.Error()})
+			return
+		}
+		defer tx.Rollback()
+
+		report, states, err := checkProjectLifecycle(tx, req.ProjectID, req.ElementIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check element lifecycle", "details": err.Error()})
+			return
+		}
+
+		// Repair per target and previous state so each group is one set of statements
+		type repairGroup struct{ from, to string }
+		groups := map[repairGroup][]int{}
+		for i, issue := range report.Issues {
+			if !issue.Repairable {
+				continue
+			}
+			key := repairGroup{states[i].StoredState, issue.TargetState}
+			groups[key] = append(groups[key], issue.ElementID)
+		}
+		keys := make([]repairGroup, 0, len(groups))
+		for key := range groups {
+			keys = append(keys, key)
+		}
+		sort.Slice(keys, func(i, j int) bool {
+			if keys[i].to != keys[j].to {
+				return keys[i].to < keys[j].to
+			}
+			return keys[i].from < keys[j].from
+		})
+		for _, key := range keys {
+			ids := groups[key]
+			if err := applyLifecycleState(tx, ids, key.to); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to repair elements", "details": err.Error()})
+				return
+			}
+			if err := recordLifecycleState(tx, ids, key.from, key.to, LifecycleRepairSource, userName); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to repair elements", "details": err.Error()})
+				return
+			}
+			report.Repaired += len(ids)
+		}
+
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit repair", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, report)
+
+		saveElementLifecycleActivity(db, session, userName, "Repair",
+			fmt.Sprintf("Repaired element lifecycle of %d elements, %d left for manual correction",
+				report.Repaired, report.Inconsistent-report.Repaired), req.ProjectID)
+	}
+}
@@ -0,0 +1,171 @@
+package handlers
+
+import (
+	"backend/models"
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestElementStateDerived(t *testing.T) {
+	tests := []struct {
+		name  string
+		state elementState
+		want  string
+	}{
+		{"nothing yet", elementState{}, models.LifecyclePlanned},
+		{"production started", elementState{HasActivity: true}, models.LifecycleInProduction},
+		{"stock row outside the yard", elementState{HasActivity: true, StockRows: 1}, models.LifecycleQCPassed},
+		{"in the stockyard", elementState{StockRows: 1, InYard: true}, models.LifecycleInYard},
+		{"dispatched", elementState{StockRows: 1, InYard: true, Dispatched: true}, models.LifecycleDispatched},
+		{"received per precast_stock", elementState{StockRows: 1, Dispatched: true, Received: true}, models.LifecycleReceived},
+		{"received per stock_erected only", elementState{StockRows: 1, Dispatched: true, HasErection: true, ErectionReceived: true}, models.LifecycleReceived},
+		{"erected per stock_erected only", elementState{StockRows: 1, HasErection: true, ErectionErected: true}, models.LifecycleErected},
+		{"erected and handed over", elementState{StockRows: 1, Erected: true, Status: "Handed Over"}, models.LifecycleHandedOver},
+		{"handed over status without erection", elementState{StockRows: 1, InYard: true, Status: "Handed Over"}, models.LifecycleInYard},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.state.derived(); got != tt.want {
+				t.Errorf("derived = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestElementStateCurrent(t *testing.T) {
+	// The recorded state wins only when it is further along than the rows show
+	ahead := elementState{StockRows: 1, InYard: true, StoredState: models.LifecycleDispatched}
+	if got := ahead.current(); got != models.LifecycleDispatched {
+		t.Errorf("recorded ahead of rows: current = %s", got)
+	}
+	behind := elementState{StockRows: 1, InYard: true, Dispatched: true, StoredState: models.LifecycleInYard}
+	if got := behind.current(); got != models.LifecycleDispatched {
+		t.Errorf("recorded behind rows: current = %s", got)
+	}
+	unknown := elementState{HasActivity: true, StoredState: "scrapped"}
+	if got := unknown.current(); got != models.LifecycleInProduction {
+		t.Errorf("unknown recorded state: current = %s", got)
+	}
+}
+
+func TestPlanLifecycleTransition(t *testing.T) {
+	inYard := elementState{ElementID: 1, StockRows: 1, InYard: true, StoredState: models.LifecycleInYard}
+	dispatched := elementState{ElementID: 2, StockRows: 1, InYard: true, Dispatched: true, StoredState: models.LifecycleDispatched}
+	dispatchedUnrecorded := elementState{ElementID: 3, StockRows: 1, InYard: true, Dispatched: true}
+	inProduction := elementState{ElementID: 4, HasActivity: true}
+	disabled := elementState{ElementID: 5, StockRows: 1, InYard: true, Disabled: true}
+	yardUnrecorded := elementState{ElementID: 6, StockRows: 1, InYard: true}
+	byID := map[int]elementState{1: inYard, 2: dispatched, 3: dispatchedUnrecorded, 4: inProduction, 5: disabled, 6: yardUnrecorded}
+
+	tests := []struct {
+		name           string
+		ids            []int
+		to             string
+		wantMoving     map[string][]int
+		wantUnrecorded map[string][]int
+		wantConflicts  []models.ElementStateConflict
+	}{
+		{
+			name:           "one state forward",
+			ids:            []int{1, 6},
+			to:             models.LifecycleDispatched,
+			wantMoving:     map[string][]int{models.LifecycleInYard: {1, 6}},
+			wantUnrecorded: map[string][]int{},
+		},
+		{
+			name:           "already there is left as is, or recorded when missing",
+			ids:            []int{2, 3, 1},
+			to:             models.LifecycleDispatched,
+			wantMoving:     map[string][]int{models.LifecycleInYard: {1}},
+			wantUnrecorded: map[string][]int{"": {3}},
+		},
+		{
+			name:           "duplicates are moved once",
+			ids:            []int{1, 1},
+			to:             models.LifecycleDispatched,
+			wantMoving:     map[string][]int{models.LifecycleInYard: {1}},
+			wantUnrecorded: map[string][]int{},
+		},
+		{
+			name:          "skipping a state",
+			ids:           []int{1, 4},
+			to:            models.LifecycleDispatched,
+			wantConflicts: []models.ElementStateConflict{{ElementID: 4, State: models.LifecycleInProduction}},
+		},
+		{
+			name:          "moving backwards",
+			ids:           []int{2},
+			to:            models.LifecycleInYard,
+			wantConflicts: []models.ElementStateConflict{{ElementID: 2, State: models.LifecycleDispatched}},
+		},
+		{
+			name: "disabled and unknown elements",
+			ids:  []int{5, 99, 1},
+			to:   models.LifecycleDispatched,
+			wantConflicts: []models.ElementStateConflict{
+				{ElementID: 5, State: "disabled"},
+				{ElementID: 99, State: "unknown"},
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			move, err := planLifecycleTransition(byID, tt.ids, tt.to)
+			if tt.wantConflicts != nil {
+				var illegal *models.ErrIllegalTransition
+				if !errors.As(err, &illegal) {
+					t.Fatalf("err = %v, want an illegal transition", err)
+				}
+				if illegal.To != tt.to || !reflect.DeepEqual(illegal.Elements, tt.wantConflicts) {
+					t.Errorf("conflicts = %s %+v, want %s %+v", illegal.To, illegal.Elements, tt.to, tt.wantConflicts)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(move.moving, tt.wantMoving) {
+				t.Errorf("moving = %v, want %v", move.moving, tt.wantMoving)
+			}
+			if !reflect.DeepEqual(move.unrecorded, tt.wantUnrecorded) {
+				t.Errorf("unrecorded = %v, want %v", move.unrecorded, tt.wantUnrecorded)
+			}
+		})
+	}
+
+	if _, err := planLifecycleTransition(byID, []int{1}, "scrapped"); err == nil {
+		t.Error("unknown target state was accepted")
+	}
+}
+
+func TestCheckElementState(t *testing.T) {
+	tests := []struct {
+		name           string
+		state          elementState
+		wantTarget     string
+		wantRepairable bool
+		wantProblems   int
+	}{
+		{"consistent yard element", elementState{StockRows: 1, InYard: true, StoredState: models.LifecycleInYard, Status: "In Stockyard"},
+			models.LifecycleInYard, true, 0},
+		{"status lags the rows", elementState{StockRows: 1, InYard: true, Dispatched: true, Status: "In Stockyard"},
+			models.LifecycleDispatched, true, 1},
+		{"recorded ahead without a stock row", elementState{HasActivity: true, StoredState: models.LifecycleInYard},
+			models.LifecycleInProduction, true, 2},
+		{"two stock rows", elementState{StockRows: 2, InYard: true, Status: "In Stockyard"},
+			models.LifecycleInYard, false, 1},
+		{"erection rows disagree", elementState{StockRows: 1, InYard: true, Dispatched: true, Received: true, Status: "In Erection",
+			HasErection: true, ErectionReceived: true, ErectionErected: true},
+			models.LifecycleErected, true, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			issue := checkElementState(tt.state)
+			if issue.TargetState != tt.wantTarget || issue.Repairable != tt.wantRepairable || len(issue.Problems) != tt.wantProblems {
+				t.Errorf("target %s repairable %v problems %q, want %s %v %d problems",
+					issue.TargetState, issue.Repairable, issue.Problems, tt.wantTarget, tt.wantRepairable, tt.wantProblems)
+			}
+		})
+	}
+}
@@ -0,0 +1,637 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"gorm.io/gorm"
+)
+
+// maxElementIDTemplateLength caps the length of an element ID template
+const maxElementIDTemplateLength = 200
+
+// SQL statements for element ID templates
+const (
+	// seedElementSequenceSQL starts the counter of a scope after the highest number already used by
+	// element IDs of that shape, so IDs made before the counter existed are never handed out again
+	seedElementSequenceSQL = `
+		INSERT INTO element_id_sequence (project_id, scope, last_value)
+		SELECT $1, $2, COALESCE(MAX(substring(element_id from $3)::BIGINT), 0)
+		FROM element WHERE project_id = $1 AND element_id ~ $3
+		ON CONFLICT (project_id, scope) DO NOTHING`
+)
+
+// elementIDTokens documents the tokens of the template language
+var elementIDTokens = []models.ElementIDToken{
+	{Token: "{project}", Description: "Project abbreviation, or its name when it has none"},
+	{Token: "{tower}", Description: "Prefix of the top hierarchy level, e.g. T1"},
+	{Token: "{floor}", Description: "Prefix of the hierarchy level the element is placed on, e.g. F03"},
+	{Token: "{level:N}", Description: "Prefix of hierarchy level N counted from the top, starting at 1"},
+	{Token: "{naming}", Description: "Naming convention of the hierarchy level the element is placed on"},
+	{Token: "{type}", Description: "Element type code, e.g. C1"},
+	{Token: "{rev}", Description: "Element type version, e.g. RV-01"},
+	{Token: "{seq:N}", Description: "Running number padded to N digits (1-9, default 4). Required exactly once."},
+	{Token: ":upper / :lower", Description: "Case modifier for text tokens, e.g. {type:upper}"},
+}
+
+// elementIDPart is a literal run or a token of a parsed template
+type elementIDPart struct {
+	literal string
+	token   string
+	arg     string
+}
+
+// elementIDTemplate is a parsed element ID template
+type elementIDTemplate struct {
+	parts    []elementIDPart
+	seqWidth int
+}
+
+// elementIDContext holds the values the tokens of a template are filled from
+type elementIDContext struct {
+	projectID int
+	project   string
+	levels    []string // hierarchy prefixes from the top level down to the element's level
+	naming    string
+	typeCode  string
+	rev       string
+}
+
+// parseElementIDTemplate parses a template, returning the errors that make it unusable and warnings
+// about templates that work but are probably not what was meant.
+func parseElementIDTemplate(s string) (t elementIDTemplate, errs []string, warnings []string) {
+	errs, warnings = []string{}, []string{}
+	if strings.TrimSpace(s) == "" {
+		return t, append(errs, "Template is empty"), warnings
+	}
+	if len(s) > maxElementIDTemplateLength {
+		errs = append(errs, fmt.Sprintf("Template must be at most %d characters", maxElementIDTemplateLength))
+	}
+
+	seqCount := 0
+	used := map[string]bool{}
+	rest := s
+	for rest != "" {
+		open := strings.IndexAny(rest, "{}")
+		if open < 0 {
+			t.parts = append(t.parts, elementIDPart{literal: rest})
+			break
+		}
+		if open > 0 {
+			t.parts = append(t.parts, elementIDPart{literal: rest[:open]})
+		}
+		if rest[open] == '}' {
+			errs = append(errs, fmt.Sprintf("Unexpected '}' at position %d", len(s)-len(rest)+open+1))
+			rest = rest[open+1:]
+			continue
+		}
+		end := strings.IndexAny(rest[open+1:], "{}")
+		if end < 0 || rest[open+1+end] != '}' {
+			errs = append(errs, fmt.Sprintf("Unclosed '{' at position %d", len(s)-len(rest)+open+1))
+			break
+		}
+		spec := rest[open+1 : open+1+end]
+		rest = rest[open+1+end+1:]
+
+		name, arg, _ := strings.Cut(spec, ":")
+		part := elementIDPart{token: name, arg: arg}
+		switch name {
+		case "project", "tower", "floor", "naming", "type", "rev":
+			if arg != "" && arg != "upper" && arg != "lower" {
+				errs = append(errs, fmt.Sprintf("{%s}: unknown modifier %q, use upper or lower", spec, arg))
+			}
+		case "level":
+			n, err := strconv.Atoi(arg)
+			if err != nil || n < 1 {
+				errs = append(errs, fmt.Sprintf("{%s}: level must be a number from 1", spec))
+			}
+		case "seq":
+			seqCount++
+			t.seqWidth = 4
+			if arg != "" {
+				n, err := strconv.Atoi(arg)
+				if err != nil || n < 1 || n > 9 {
+					errs = append(errs, fmt.Sprintf("{%s}: width must be a number from 1 to 9", spec))
+				} else {
+					t.seqWidth = n
+				}
+			}
+		default:
+			errs = append(errs, fmt.Sprintf("Unknown token {%s}", spec))
+		}
+		used[name] = true
+		t.parts = append(t.parts, part)
+	}
+
+	switch {
+	case seqCount == 0:
+		errs = append(errs, "Template must contain a {seq} token")
+	case seqCount > 1:
+		errs = append(errs, "Template must contain only one {seq} token")
+	}
+	if !used["type"] {
+		warnings = append(warnings, "No {type} token: elements of all types on a level share one running number")
+	}
+	if !used["floor"] && !used["level"] && !used["naming"] {
+		warnings = append(warnings, "No {floor}, {level:N} or {naming} token: elements on all levels share one running number")
+	}
+	for i, p := range t.parts {
+		if p.token == "seq" && i > 0 && t.parts[i-1].token != "" {
+			warnings = append(warnings, fmt.Sprintf("{seq} directly follows {%s}: values like C1 and C12 can run into the same ID, which is then refused", t.parts[i-1].token))
+		}
+	}
+	if strings.IndexFunc(s, unicode.IsSpace) >= 0 {
+		warnings = append(warnings, "Template contains spaces")
+	}
+	return t, errs, warnings
+}
+
+// render fills the tokens of the template, returning the text before and after the running number.
+func (t elementIDTemplate) render(ctx elementIDContext) (prefix, suffix string, err error) {
+	var b strings.Builder
+	for _, p := range t.parts {
+		if p.token == "" {
+			b.WriteString(p.literal)
+			continue
+		}
+		var v string
+		switch p.token {
+		case "project":
+			v = ctx.project
+		case "tower", "floor", "level":
+			n := 1
+			switch p.token {
+			case "floor":
+				n = len(ctx.levels)
+			case "level":
+				n, _ = strconv.Atoi(p.arg)
+			}
+			if n < 1 || n > len(ctx.levels) {
+				return "", "", fmt.Errorf("the hierarchy has no level for {%s}", strings.TrimSuffix(p.token+":"+p.arg, ":"))
+			}
+			v = ctx.levels[n-1]
+		case "naming":
+			v = ctx.naming
+		case "type":
+			v = ctx.typeCode
+		case "rev":
+			v = ctx.rev
+		case "seq":
+			prefix = b.String()
+			b.Reset()
+			continue
+		}
+		if v == "" {
+			return "", "", fmt.Errorf("{%s} has no value for this element", p.token)
+		}
+		switch p.arg {
+		case "upper":
+			v = strings.ToUpper(v)
+		case "lower":
+			v = strings.ToLower(v)
+		}
+		b.WriteString(v)
+	}
+	return prefix, b.String(), nil
+}
+
+// loadElementIDTemplate reads the template of a project, falling back to the default scheme.
+func loadElementIDTemplate(q sqlQueryer, projectID int) (models.ElementIDTemplate, error) {
+	t := models.ElementIDTemplate{ProjectID: projectID, Tokens: elementIDTokens}
+	var updatedAt time.Time
+	err := q.QueryRow(`SELECT template, updated_by, updated_at FROM element_id_template WHERE project_id = $1`,
+		projectID).Scan(&t.Template, &t.UpdatedBy, &updatedAt)
+	if err == sql.ErrNoRows {
+		t.Template = models.DefaultElementIDTemplate
+		t.IsDefault = true
+		return t, nil
+	} else if err != nil {
+		return t, err
+	}
+	t.UpdatedAt = &updatedAt
+	return t, nil
+}
+
+// loadElementIDContext reads the values for the tokens of elements of a type placed on a hierarchy
+// level. A non-empty naming replaces the level's naming convention. The error wraps sql.ErrNoRows
+// when the element type or level doesn't exist.
+func loadElementIDContext(q sqlQueryer, elementTypeID, hierarchyID int, naming string) (elementIDContext, error) {
+	var ctx elementIDContext
+	err := q.QueryRow(`
+		SELECT et.project_id, COALESCE(NULLIF(p.abbreviation, ''), p.name, ''), et.element_type,
+			COALESCE(et.element_type_version, '')
+		FROM element_type et
+		LEFT JOIN project p ON p.project_id = et.project_id
+		WHERE et.element_type_id = $1`, elementTypeID).Scan(&ctx.projectID, &ctx.project, &ctx.typeCode, &ctx.rev)
+	if err == sql.ErrNoRows {
+		return ctx, fmt.Errorf("element type %d not found: %w", elementTypeID, err)
+	} else if err != nil {
+		return ctx, err
+	}
+
+	rows, err := q.Query(`
+		WITH RECURSIVE chain AS (
+			SELECT id, parent_id, COALESCE(NULLIF(prefix, ''), name) AS code,
+				COALESCE(naming_convention, '') AS naming, 0 AS depth
+			FROM precast WHERE id = $1 AND project_id = $2
+			UNION ALL
+			SELECT p.id, p.parent_id, COALESCE(NULLIF(p.prefix, ''), p.name), COALESCE(p.naming_convention, ''), c.depth + 1
+			FROM precast p JOIN chain c ON p.id = c.parent_id
+			WHERE c.depth < 32
+		)
+		SELECT code, naming FROM chain ORDER BY depth DESC`, hierarchyID, ctx.projectID)
+	if err != nil {
+		return ctx, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var code string
+		if err := rows.Scan(&code, &ctx.naming); err != nil {
+			return ctx, err
+		}
+		ctx.levels = append(ctx.levels, code)
+	}
+	if err := rows.Err(); err != nil {
+		return ctx, err
+	}
+	if len(ctx.levels) == 0 {
+		return ctx, fmt.Errorf("hierarchy %d not found in project %d: %w", hierarchyID, ctx.projectID, sql.ErrNoRows)
+	}
+	if naming != "" {
+		ctx.naming = naming
+	}
+	return ctx, nil
+}
+
+// elementSequenceScope names the counter of the element IDs sharing a prefix and suffix, and
+// gives the pattern matching those IDs with the running number as its group.
+func elementSequenceScope(prefix, suffix string) (scope, pattern string) {
+	return prefix + "{seq}" + suffix, "^" + regexp.QuoteMeta(prefix) + "([0-9]+)" + regexp.QuoteMeta(suffix) + "$"
+}
+
+// allocateElementSequence reserves count running numbers for a scope and returns the first. The
+// counter row stays locked until the caller's transaction ends, so concurrent imports numbering the
+// same scope wait for each other instead of handing out the same number, and a rolled back import
+// leaves no gap.
+func allocateElementSequence(q sqlQueryer, projectID int, prefix, suffix string, count int) (int, error) {
+	scope, pattern := elementSequenceScope(prefix, suffix)
+	if _, err := q.Exec(seedElementSequenceSQL, projectID, scope, pattern); err != nil {
+		return 0, err
+	}
+	var last int
+	err := q.QueryRow(`
+		UPDATE element_id_sequence SET last_value = last_value + $3
+		WHERE project_id = $1 AND scope = $2
+		RETURNING last_value`, projectID, scope, count).Scan(&last)
+	if err != nil {
+		return 0, err
+	}
+	return last - count + 1, nil
+}
+
+// peekElementSequence returns the next running number of a scope without reserving it.
+func peekElementSequence(q sqlQueryer, projectID int, prefix, suffix string) (int, error) {
+	scope, pattern := elementSequenceScope(prefix, suffix)
+	var last int
+	err := q.QueryRow(`
+		SELECT COALESCE(
+			(SELECT last_value FROM element_id_sequence WHERE project_id = $1 AND scope = $2),
+			(SELECT COALESCE(MAX(substring(element_id from $3)::BIGINT), 0)
+			 FROM element WHERE project_id = $1 AND element_id ~ $3))`, projectID, scope, pattern).Scan(&last)
+	return last + 1, err
+}
+
+// formatElementIDs builds count element IDs starting at running number first.
+func formatElementIDs(prefix, suffix string, width, first, count int) []string {
+	ids := make([]string, 0, count)
+	for i := 0; i < count; i++ {
+		ids = append(ids, fmt.Sprintf("%s%0*d%s", prefix, width, first+i, suffix))
+	}
+	return ids
+}
+
+// GenerateElementIDs hands out the IDs of count new elements of a type placed on a hierarchy level,
+// following the project's element ID template. A non-empty naming replaces the level's naming
+// convention for the {naming} token. Run it in the transaction that inserts the elements.
+func GenerateElementIDs(q sqlQueryer, elementTypeID, hierarchyID int, naming string, count int) ([]string, error) {
+	if count <= 0 {
+		return []string{}, nil
+	}
+	ctx, err := loadElementIDContext(q, elementTypeID, hierarchyID, naming)
+	if err != nil {
+		return nil, err
+	}
+	stored, err := loadElementIDTemplate(q, ctx.projectID)
+	if err != nil {
+		return nil, err
+	}
+	t, errs, _ := parseElementIDTemplate(stored.Template)
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("element ID template %q is invalid: %s", stored.Template, strings.Join(errs, "; "))
+	}
+	prefix, suffix, err := t.render(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("element ID template %q: %v", stored.Template, err)
+	}
+	next := func(n int) ([]string, error) {
+		first, err := allocateElementSequence(q, ctx.projectID, prefix, suffix, n)
+		if err != nil {
+			return nil, err
+		}
+		return formatElementIDs(prefix, suffix, t.seqWidth, first, n), nil
+	}
+	inUse := func(ids []string) (map[string]bool, error) {
+		return elementIDsInUse(q, ctx.projectID, ids)
+	}
+	return collectFreeElementIDs(count, next, inUse)
+}
+
+// collectFreeElementIDs draws IDs from next until count of them are free. IDs typed in by hand or
+// made under an older template can still match; their running numbers are skipped and more drawn.
+func collectFreeElementIDs(count int, next func(n int) ([]string, error), inUse func(ids []string) (map[string]bool, error)) ([]string, error) {
+	ids := make([]string, 0, count)
+	for len(ids) < count {
+		batch, err := next(count - len(ids))
+		if err != nil {
+			return nil, err
+		}
+		used, err := inUse(batch)
+		if err != nil {
+			return nil, err
+		}
+		for _, id := range batch {
+			if !used[id] {
+				ids = append(ids, id)
+			}
+		}
+	}
+	return ids, nil
+}
+
+// elementIDsInUse returns which of the IDs a project's elements already have.
+func elementIDsInUse(q sqlQueryer, projectID int, ids []string) (map[string]bool, error) {
+	rows, err := q.Query(`SELECT element_id FROM element WHERE project_id = $1 AND element_id = ANY($2)`,
+		projectID, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	used := map[string]bool{}
+	for rows.Next() {
+		var id string
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		used[id] = true
+	}
+	return used, rows.Err()
+}
+
+// gormSQLTx returns the database/sql transaction under a GORM transaction, for helpers written
+// against sqlQueryer.
+func gormSQLTx(tx *gorm.DB) (*sql.Tx, error) {
+	sqlTx, ok := tx.Statement.ConnPool.(*sql.Tx)
+	if !ok {
+		return nil, errors.New("not in a transaction")
+	}
+	return sqlTx, nil
+}
+
+// elementIDValidation checks a template for the API.
+func elementIDValidation(template string) (elementIDTemplate, models.ElementIDTemplateValidation) {
+	t, errs, warnings := parseElementIDTemplate(template)
+	return t, models.ElementIDTemplateValidation{
+		Template: template,
+		Valid:    len(errs) == 0,
+		Errors:   errs,
+		Warnings: warnings,
+	}
+}
+
+// saveElementNamingActivity writes the activity log of an element ID template change.
+func saveElementNamingActivity(db *sql.DB, session models.Session, userName, eventName, description string, projectID int) {
+	activityLog := models.ActivityLog{
+		EventContext: "Element Naming",
+		EventName:    eventName,
+		Description:  description,
+		UserName:     userName,
+		HostName:     session.HostName,
+		IPAddress:    session.IPAddress,
+		CreatedAt:    time.Now(),
+		ProjectID:    projectID,
+	}
+	if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+		log.Printf("Failed to save activity log: %v", logErr)
+	}
+}
+
+// GetElementIDTemplate returns the element ID template of a project with the tokens it can use.
+// @Summary Get element ID template
+// @Tags Element Naming
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Success 200 {object} models.ElementIDTemplate
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/element_id_template [get]
+func GetElementIDTemplate(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+		t, err := loadElementIDTemplate(db, projectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch element ID template", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, t)
+	}
+}
+
+// SetElementIDTemplate sets the element ID template of a project. Elements already created keep
+// their IDs; new elements are numbered on from the highest ID of the new shape.
+// @Summary Set element ID template
+// @Tags Element Naming
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param request body models.SetElementIDTemplateRequest true "Template"
+// @Success 200 {object} models.ElementIDTemplate
+// @Failure 400 {object} models.ElementIDTemplateValidation
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/element_id_template [put]
+func SetElementIDTemplate(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+		var req models.SetElementIDTemplateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		_, v := elementIDValidation(req.Template)
+		if !v.Valid {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid element ID template", "validation": v})
+			return
+		}
+
+		var exists bool
+		if err := db.QueryRow(`SELECT EXISTS(SELECT 1 FROM project WHERE project_id = $1)`, projectID).Scan(&exists); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch project", "details": err.Error()})
+			return
+		}
+		if !exists {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Project not found"})
+			return
+		}
+
+		_, err = db.Exec(`
+			INSERT INTO element_id_template (project_id, template, updated_by, updated_at)
+			VALUES ($1, $2, $3, NOW())
+			ON CONFLICT (project_id) DO UPDATE
+			SET template = EXCLUDED.template, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at`,
+			projectID, req.Template, userName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save element ID template", "details": err.Error()})
+			return
+		}
+		t, err := loadElementIDTemplate(db, projectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch element ID template", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"template": t, "warnings": v.Warnings})
+
+		saveElementNamingActivity(db, session, userName, "Update",
+			fmt.Sprintf("Set element ID template to %s", req.Template), projectID)
+	}
+}
+
+// ValidateElementIDTemplate checks a template without saving it.
+// @Summary Validate element ID template
+// @Tags Element Naming
+// @Accept json
+// @Produce json
+// @Param request body models.ValidateElementIDTemplateRequest true "Template"
+// @Success 200 {object} models.ElementIDTemplateValidation
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/element_id_template/validate [post]
+func ValidateElementIDTemplate(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+		var req models.ValidateElementIDTemplateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		_, v := elementIDValidation(req.Template)
+		c.JSON(http.StatusOK, v)
+	}
+}
+
+// PreviewElementIDs shows the IDs the next elements of a type on a hierarchy level would get, under
+// the project's template or one being drafted. No number is reserved.
+// @Summary Preview element IDs
+// @Tags Element Naming
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param request body models.ElementIDPreviewRequest true "Preview"
+// @Success 200 {object} models.ElementIDPreview
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/element_id_template/preview [post]
+func PreviewElementIDs(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+		var req models.ElementIDPreviewRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		if req.Count <= 0 {
+			req.Count = 5
+		}
+		if req.Count > 50 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "count must be at most 50"})
+			return
+		}
+		if req.Template == "" {
+			stored, err := loadElementIDTemplate(db, projectID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch element ID template", "details": err.Error()})
+				return
+			}
+			req.Template = stored.Template
+		}
+
+		ctx, err := loadElementIDContext(db, req.ElementTypeID, req.HierarchyID, "")
+		if errors.Is(err, sql.ErrNoRows) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Element type or hierarchy not found", "details": err.Error()})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch element details", "details": err.Error()})
+			return
+		}
+		if ctx.projectID != projectID {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Element type not found in this project"})
+			return
+		}
+
+		t, v := elementIDValidation(req.Template)
+		preview := models.ElementIDPreview{ElementIDTemplateValidation: v, ElementIDs: []string{}}
+		if !v.Valid {
+			c.JSON(http.StatusOK, preview)
+			return
+		}
+		prefix, suffix, err := t.render(ctx)
+		if err != nil {
+			preview.Valid = false
+			preview.Errors = append(preview.Errors, err.Error())
+			c.JSON(http.StatusOK, preview)
+			return
+		}
+		preview.NextSequence, err = peekElementSequence(db, projectID, prefix, suffix)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read element sequence", "details": err.Error()})
+			return
+		}
+		preview.ElementIDs = formatElementIDs(prefix, suffix, t.seqWidth, preview.NextSequence, req.Count)
+		c.JSON(http.StatusOK, preview)
+	}
+}
@@ -0,0 +1,238 @@
+package handlers
+
+import (
+	"backend/models"
+	"reflect"
+	"regexp"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestParseElementIDTemplate(t *testing.T) {
+	ctx := elementIDContext{
+		project:  "Skyline",
+		levels:   []string{"T1", "F03"},
+		naming:   "east",
+		typeCode: "c1",
+		rev:      "RV-01",
+	}
+
+	tests := []struct {
+		name         string
+		template     string
+		wantErrs     []string
+		wantWarnings []string
+		wantWidth    int
+		wantPrefix   string
+		wantSuffix   string
+	}{
+		{
+			name:       "default template",
+			template:   models.DefaultElementIDTemplate,
+			wantWidth:  4,
+			wantPrefix: "C1/east/",
+		},
+		{
+			name:       "levels, modifiers and a suffix",
+			template:   "{project:upper}-{tower}-{level:2}-{type}-{seq:3}-{rev:lower}",
+			wantWidth:  3,
+			wantPrefix: "SKYLINE-T1-F03-c1-",
+			wantSuffix: "-rv-01",
+		},
+		{
+			name:         "no type or level",
+			template:     "{project}-{seq}",
+			wantWidth:    4,
+			wantPrefix:   "Skyline-",
+			wantWarnings: []string{"No {type} token: elements of all types on a level share one running number", "No {floor}, {level:N} or {naming} token: elements on all levels share one running number"},
+		},
+		{
+			name:         "seq straight after a token",
+			template:     "{floor}{type}{seq:2}",
+			wantWidth:    2,
+			wantPrefix:   "F03c1",
+			wantWarnings: []string{"{seq} directly follows {type}: values like C1 and C12 can run into the same ID, which is then refused"},
+		},
+		{
+			name:         "spaces",
+			template:     "{type} {floor} {seq}",
+			wantWidth:    4,
+			wantPrefix:   "c1 F03 ",
+			wantWarnings: []string{"Template contains spaces"},
+		},
+		{
+			name:     "empty",
+			template: "  ",
+			wantErrs: []string{"Template is empty"},
+		},
+		{
+			name:     "no seq",
+			template: "{type}-{floor}",
+			wantErrs: []string{"Template must contain a {seq} token"},
+		},
+		{
+			name:     "two seqs",
+			template: "{type}-{floor}-{seq}-{seq}",
+			wantErrs: []string{"Template must contain only one {seq} token"},
+		},
+		{
+			name:     "bad arguments and unknown tokens",
+			template: "{type:title}-{level:0}-{floor}-{seq:12}-{colour}",
+			wantErrs: []string{
+				`{type:title}: unknown modifier "title", use upper or lower`,
+				"{level:0}: level must be a number from 1",
+				"{seq:12}: width must be a number from 1 to 9",
+				"Unknown token {colour}",
+			},
+		},
+		{
+			name:     "stray and unclosed braces",
+			template: "{type}}-{floor}-{seq",
+			wantErrs: []string{"Unexpected '}' at position 7", "Unclosed '{' at position 17", "Template must contain a {seq} token"},
+		},
+		{
+			name:     "too long",
+			template: "{type}-{floor}-{seq}-" + strings.Repeat("x", maxElementIDTemplateLength),
+			wantErrs: []string{"Template must be at most 200 characters"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tmpl, errs, warnings := parseElementIDTemplate(tt.template)
+			if tt.wantErrs == nil {
+				tt.wantErrs = []string{}
+			}
+			if !reflect.DeepEqual(errs, tt.wantErrs) {
+				t.Errorf("errors = %q, want %q", errs, tt.wantErrs)
+			}
+			if len(tt.wantErrs) > 0 {
+				return
+			}
+			if tt.wantWarnings == nil {
+				tt.wantWarnings = []string{}
+			}
+			if !reflect.DeepEqual(warnings, tt.wantWarnings) {
+				t.Errorf("warnings = %q, want %q", warnings, tt.wantWarnings)
+			}
+			if tmpl.seqWidth != tt.wantWidth {
+				t.Errorf("seq width = %d, want %d", tmpl.seqWidth, tt.wantWidth)
+			}
+			prefix, suffix, err := tmpl.render(ctx)
+			if err != nil {
+				t.Fatalf("render: %v", err)
+			}
+			if prefix != tt.wantPrefix || suffix != tt.wantSuffix {
+				t.Errorf("render = %q {seq} %q, want %q {seq} %q", prefix, suffix, tt.wantPrefix, tt.wantSuffix)
+			}
+		})
+	}
+}
+
+func TestElementIDTemplateRenderErrors(t *testing.T) {
+	tests := []struct {
+		template string
+		ctx      elementIDContext
+		wantErr  string
+	}{
+		{"{level:3}-{type}-{seq}", elementIDContext{levels: []string{"T1", "F03"}, typeCode: "C1"}, "the hierarchy has no level for {level:3}"},
+		{"{floor}-{type}-{seq}", elementIDContext{typeCode: "C1"}, "the hierarchy has no level for {floor}"},
+		{"{floor}-{naming}-{seq}", elementIDContext{levels: []string{"F03"}}, "{naming} has no value for this element"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.template, func(t *testing.T) {
+			tmpl, errs, _ := parseElementIDTemplate(tt.template)
+			if len(errs) > 0 {
+				t.Fatalf("parse: %q", errs)
+			}
+			if _, _, err := tmpl.render(tt.ctx); err == nil || err.Error() != tt.wantErr {
+				t.Errorf("render error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestFormatElementIDs(t *testing.T) {
+	tests := []struct {
+		name           string
+		prefix, suffix string
+		width          int
+		first, count   int
+		want           []string
+	}{
+		{"padded", "C1/east/", "", 4, 9, 3, []string{"C1/east/0009", "C1/east/0010", "C1/east/0011"}},
+		{"suffix", "T1-", "-RV1", 2, 1, 2, []string{"T1-01-RV1", "T1-02-RV1"}},
+		{"number wider than the padding", "C1-", "", 2, 99, 3, []string{"C1-99", "C1-100", "C1-101"}},
+		{"nothing", "C1-", "", 4, 1, 0, []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := formatElementIDs(tt.prefix, tt.suffix, tt.width, tt.first, tt.count)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ids = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCollectFreeElementIDs(t *testing.T) {
+	tests := []struct {
+		name   string
+		taken  []string
+		count  int
+		want   []string
+		rounds int
+	}{
+		{"all free", nil, 3, []string{"C1-01", "C1-02", "C1-03"}, 1},
+		{"taken numbers are skipped", []string{"C1-02", "C1-03"}, 3, []string{"C1-01", "C1-04", "C1-05"}, 2},
+		{"the top-up is taken too", []string{"C1-02", "C1-04"}, 3, []string{"C1-01", "C1-03", "C1-05"}, 3},
+		{"whole batch taken", []string{"C1-01", "C1-02"}, 2, []string{"C1-03", "C1-04"}, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			last, rounds := 0, 0
+			next := func(n int) ([]string, error) {
+				rounds++
+				ids := formatElementIDs("C1-", "", 2, last+1, n)
+				last += n
+				return ids, nil
+			}
+			inUse := func(ids []string) (map[string]bool, error) {
+				used := map[string]bool{}
+				for _, id := range ids {
+					used[id] = slices.Contains(tt.taken, id)
+				}
+				return used, nil
+			}
+			got, err := collectFreeElementIDs(tt.count, next, inUse)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reflect.DeepEqual(got, tt.want) || rounds != tt.rounds {
+				t.Errorf("ids = %q in %d rounds, want %q in %d", got, rounds, tt.want, tt.rounds)
+			}
+		})
+	}
+}
+
+func TestElementSequenceScope(t *testing.T) {
+	scope, pattern := elementSequenceScope("C1.(a)/", "+x")
+	if scope != "C1.(a)/{seq}+x" {
+		t.Errorf("scope = %q", scope)
+	}
+	re := regexp.MustCompile(pattern)
+	for id, want := range map[string]bool{
+		"C1.(a)/0042+x": true,
+		"C1.(a)/7+x":    true,
+		"C1x(a)/0042+x": false, // the dot is literal
+		"C1.(a)/0042+":  false,
+		"C1.(a)/ab+x":   false,
+	} {
+		if got := re.MatchString(id); got != want {
+			t.Errorf("%q matches = %v, want %v", id, got, want)
+		}
+	}
+	if m := re.FindStringSubmatch("C1.(a)/0042+x"); len(m) != 2 || m[1] != "0042" {
+		t.Errorf("running number group = %q", m)
+	}
+}
@@ -48,6 +48,18 @@ func UpdateBOMPro(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		elementTypeIDInt, err := strconv.Atoi(elementTypeID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "element_type_id must be a valid integer"})
+			return
+		}
+
+		// Make sure the BOM in force so far is versioned before it changes
+		if _, err := SnapshotElementTypeBOMVersion(db, elementTypeIDInt, projectID, userName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to snapshot BOM version", "details": err.Error()})
+			return
+		}
+
 		// Fetch data from BOMPro based on element_type_id
 		query := `SELECT id, element_type_id, project_id, created_at, created_by, product FROM element_type_bom WHERE element_type_id = $1`
 		rows, err := db.Query(query, elementTypeID)
@@ -126,6 +138,12 @@ func UpdateBOMPro(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Start a new BOM version, effective now, if the products changed
+		if _, err := SnapshotElementTypeBOMVersion(db, elementTypeIDInt, projectID, userName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save BOM version", "details": err.Error()})
+			return
+		}
+
 		c.JSON(http.StatusOK, gin.H{"message": "Data moved and updated successfully"})
 
 		log := models.ActivityLog{
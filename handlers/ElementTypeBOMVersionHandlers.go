@@ -0,0 +1,481 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// sqlQueryer is satisfied by both *sql.DB and *sql.Tx so the BOM version helpers can run inside a caller's transaction
+type sqlQueryer interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+// loadBOMVersionItems returns the product lines of a BOM version keyed by product ID.
+func loadBOMVersionItems(q sqlQueryer, bomVersionID int) (map[int]models.ElementTypeBOMVersionItem, error) {
+	rows, err := q.Query(`
+		SELECT product_id, product_name, quantity, unit, rate
+		FROM element_type_bom_version_item WHERE bom_version_id = $1`, bomVersionID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make(map[int]models.ElementTypeBOMVersionItem)
+	for rows.Next() {
+		var item models.ElementTypeBOMVersionItem
+		if err := rows.Scan(&item.ProductID, &item.ProductName, &item.Quantity, &item.Unit, &item.Rate); err != nil {
+			return nil, err
+		}
+		items[item.ProductID] = item
+	}
+	return items, rows.Err()
+}
+
+// loadCurrentElementTypeBOM returns the live element_type_bom lines of an element type keyed by product ID.
+func loadCurrentElementTypeBOM(q sqlQueryer, elementTypeID, projectID int) (map[int]models.ElementTypeBOMVersionItem, error) {
+	rows, err := q.Query(`
+		SELECT product_id, COALESCE(product_name, ''), COALESCE(quantity, 0), COALESCE(unit, ''), COALESCE(rate, 0)
+		FROM element_type_bom
+		WHERE element_type_id = $1 AND project_id = $2`, elementTypeID, projectID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := make(map[int]models.ElementTypeBOMVersionItem)
+	for rows.Next() {
+		var item models.ElementTypeBOMVersionItem
+		if err := rows.Scan(&item.ProductID, &item.ProductName, &item.Quantity, &item.Unit, &item.Rate); err != nil {
+			return nil, err
+		}
+		// The same product listed twice is one line of the BOM
+		if existing, ok := items[item.ProductID]; ok {
+			item.Quantity += existing.Quantity
+		}
+		items[item.ProductID] = item
+	}
+	return items, rows.Err()
+}
+
+// sameBOMLines reports whether two sets of BOM lines have the same products, quantities and units.
+func sameBOMLines(a, b map[int]models.ElementTypeBOMVersionItem) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for productID, itemA := range a {
+		itemB, ok := b[productID]
+		if !ok || math.Abs(itemA.Quantity-itemB.Quantity) > 1e-9 || itemA.Unit != itemB.Unit {
+			return false
+		}
+	}
+	return true
+}
+
+// SnapshotElementTypeBOMVersion records the current element_type_bom of an element type as a BOM version.
+// The first call for an element type creates a baseline version effective from the element type's creation;
+// later calls create a new version, effective now, only when the live BOM differs from the latest version.
+// Product names are copied into the version so renaming a product master does not rewrite history.
+// It returns the ID of the version in force after the call.
+func SnapshotElementTypeBOMVersion(q sqlQueryer, elementTypeID, projectID int, userName string) (int, error) {
+
+	current, err := loadCurrentElementTypeBOM(q, elementTypeID, projectID)
+	if err != nil {
+		return 0, fmt.Errorf("failed to fetch current BOM: %v", err)
+	}
+
+	var elementTypeVersion string
+	var elementTypeCreatedAt time.Time
+	if err := q.QueryRow(`SELECT COALESCE(element_type_version, ''), created_at FROM element_type WHERE element_type_id = $1`,
+		elementTypeID).Scan(&elementTypeVersion, &elementTypeCreatedAt); err != nil {
+		return 0, fmt.Errorf("failed to fetch element type %d: %v", elementTypeID, err)
+	}
+
+	var latestID, latestNo int
+	err = q.QueryRow(`
+		SELECT id, version_no FROM element_type_bom_version
+		WHERE element_type_id = $1
+		ORDER BY version_no DESC LIMIT 1`, elementTypeID).Scan(&latestID, &latestNo)
+	effectiveFrom := time.Now()
+	switch {
+	case err == sql.ErrNoRows:
+		effectiveFrom = elementTypeCreatedAt
+	case err != nil:
+		return 0, fmt.Errorf("failed to fetch latest BOM version: %v", err)
+	default:
+		latest, err := loadBOMVersionItems(q, latestID)
+		if err != nil {
+			return 0, fmt.Errorf("failed to fetch BOM version items: %v", err)
+		}
+		if sameBOMLines(latest, current) {
+			return latestID, nil
+		}
+		if _, err := q.Exec(`UPDATE element_type_bom_version SET effective_to = $2 WHERE id = $1`, latestID, effectiveFrom); err != nil {
+			return 0, fmt.Errorf("failed to close BOM version %d: %v", latestNo, err)
+		}
+	}
+
+	var versionID int
+	if err := q.QueryRow(`
+		INSERT INTO element_type_bom_version (element_type_id, project_id, version_no, element_type_version, effective_from, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		elementTypeID, projectID, latestNo+1, elementTypeVersion, effectiveFrom, userName).Scan(&versionID); err != nil {
+		return 0, fmt.Errorf("failed to create BOM version: %v", err)
+	}
+
+	for _, item := range current {
+		if _, err := q.Exec(`
+			INSERT INTO element_type_bom_version_item (bom_version_id, product_id, product_name, quantity, unit, rate)
+			VALUES ($1, $2, $3, $4, $5, $6)`,
+			versionID, item.ProductID, item.ProductName, item.Quantity, item.Unit, item.Rate); err != nil {
+			return 0, fmt.Errorf("failed to save BOM version item for product %d: %v", item.ProductID, err)
+		}
+	}
+
+	return versionID, nil
+}
+
+// bomVersionInForce returns the BOM version of an element type that was effective at the given time.
+// Element types that have never been versioned get their baseline version created first.
+func bomVersionInForce(q sqlQueryer, elementTypeID, projectID int, at time.Time) (int, error) {
+	var versionID int
+	err := q.QueryRow(`
+		SELECT id FROM element_type_bom_version
+		WHERE element_type_id = $1 AND effective_from <= $2 AND (effective_to IS NULL OR effective_to > $2)
+		ORDER BY version_no DESC LIMIT 1`, elementTypeID, at).Scan(&versionID)
+	if err == nil {
+		return versionID, nil
+	}
+	if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	// No version covers this time: either nothing is versioned yet, or the time predates the baseline
+	versionID, err = SnapshotElementTypeBOMVersion(q, elementTypeID, projectID, "system")
+	if err != nil {
+		return 0, err
+	}
+	err = q.QueryRow(`
+		SELECT id FROM element_type_bom_version
+		WHERE element_type_id = $1 AND effective_from <= $2
+		ORDER BY version_no DESC LIMIT 1`, elementTypeID, at).Scan(&versionID)
+	if err == sql.ErrNoRows {
+		err = q.QueryRow(`SELECT id FROM element_type_bom_version WHERE element_type_id = $1 ORDER BY version_no LIMIT 1`,
+			elementTypeID).Scan(&versionID)
+	}
+	return versionID, err
+}
+
+// RecordElementBOMConsumption books the material consumed by a cast element against the BOM version
+// in force at castAt. Recording is idempotent per element and product.
+func RecordElementBOMConsumption(q sqlQueryer, elementID int, castAt time.Time) error {
+	var elementTypeID, projectID int
+	if err := q.QueryRow(`SELECT element_type_id, project_id FROM element WHERE id = $1`, elementID).
+		Scan(&elementTypeID, &projectID); err != nil {
+		return fmt.Errorf("failed to fetch element %d: %v", elementID, err)
+	}
+
+	versionID, err := bomVersionInForce(q, elementTypeID, projectID, castAt)
+	if err != nil {
+		return fmt.Errorf("failed to resolve BOM version for element %d: %v", elementID, err)
+	}
+
+	_, err = q.Exec(`
+		INSERT INTO element_bom_consumption
+			(element_id, element_type_id, project_id, bom_version_id, product_id, product_name, quantity, unit, cast_at)
+		SELECT $1, $2, $3, $4, product_id, product_name, quantity, unit, $5
+		FROM element_type_bom_version_item
+		WHERE bom_version_id = $4
+		ON CONFLICT (element_id, product_id) DO NOTHING`,
+		elementID, elementTypeID, projectID, versionID, castAt)
+	if err != nil {
+		return fmt.Errorf("failed to record BOM consumption for element %d: %v", elementID, err)
+	}
+	return nil
+}
+
+// fetchBOMVersion loads a BOM version with its items. versionNo 0 loads the latest version.
+func fetchBOMVersion(db *sql.DB, elementTypeID, versionNo int) (models.ElementTypeBOMVersion, error) {
+	var version models.ElementTypeBOMVersion
+	var effectiveTo sql.NullTime
+
+	query := `
+		SELECT v.id, v.element_type_id, v.project_id, v.version_no, v.element_type_version, v.effective_from,
+			v.effective_to, v.created_by, v.created_at,
+			(SELECT COUNT(DISTINCT ec.element_id) FROM element_bom_consumption ec WHERE ec.bom_version_id = v.id)
+		FROM element_type_bom_version v
+		WHERE v.element_type_id = $1`
+	args := []interface{}{elementTypeID}
+	if versionNo > 0 {
+		query += ` AND v.version_no = $2`
+		args = append(args, versionNo)
+	}
+	query += ` ORDER BY v.version_no DESC LIMIT 1`
+
+	if err := db.QueryRow(query, args...).Scan(&version.ID, &version.ElementTypeID, &version.ProjectID, &version.VersionNo,
+		&version.ElementTypeVersion, &version.EffectiveFrom, &effectiveTo, &version.CreatedBy, &version.CreatedAt,
+		&version.ElementsCast); err != nil {
+		return version, err
+	}
+	if effectiveTo.Valid {
+		version.EffectiveTo = &effectiveTo.Time
+	}
+
+	items, err := loadBOMVersionItems(db, version.ID)
+	if err != nil {
+		return version, err
+	}
+	version.Items = sortedBOMVersionItems(items)
+	return version, nil
+}
+
+// sortedBOMVersionItems returns BOM lines ordered by product name for stable responses.
+func sortedBOMVersionItems(items map[int]models.ElementTypeBOMVersionItem) []models.ElementTypeBOMVersionItem {
+	list := make([]models.ElementTypeBOMVersionItem, 0, len(items))
+	for _, item := range items {
+		list = append(list, item)
+	}
+	sort.Slice(list, func(i, j int) bool {
+		if list[i].ProductName != list[j].ProductName {
+			return list[i].ProductName < list[j].ProductName
+		}
+		return list[i].ProductID < list[j].ProductID
+	})
+	return list
+}
+
+// diffBOMVersions compares two BOM versions product by product.
+func diffBOMVersions(from, to []models.ElementTypeBOMVersionItem) []models.BOMVersionDiffLine {
+	fromMap := make(map[int]models.ElementTypeBOMVersionItem, len(from))
+	for _, item := range from {
+		fromMap[item.ProductID] = item
+	}
+	toMap := make(map[int]models.ElementTypeBOMVersionItem, len(to))
+	for _, item := range to {
+		toMap[item.ProductID] = item
+	}
+
+	lines := []models.BOMVersionDiffLine{}
+	for _, item := range to {
+		line := models.BOMVersionDiffLine{
+			ProductID:   item.ProductID,
+			ProductName: item.ProductName,
+			ToQuantity:  item.Quantity,
+			ToUnit:      item.Unit,
+		}
+		if old, ok := fromMap[item.ProductID]; ok {
+			line.FromQuantity = old.Quantity
+			line.FromUnit = old.Unit
+			line.Change = "unchanged"
+			if math.Abs(old.Quantity-item.Quantity) > 1e-9 || old.Unit != item.Unit {
+				line.Change = "changed"
+			}
+		} else {
+			line.Change = "added"
+		}
+		line.Delta = line.ToQuantity - line.FromQuantity
+		lines = append(lines, line)
+	}
+	for _, item := range from {
+		if _, ok := toMap[item.ProductID]; ok {
+			continue
+		}
+		lines = append(lines, models.BOMVersionDiffLine{
+			ProductID:    item.ProductID,
+			ProductName:  item.ProductName,
+			Change:       "removed",
+			FromQuantity: item.Quantity,
+			FromUnit:     item.Unit,
+			Delta:        -item.Quantity,
+		})
+	}
+	return lines
+}
+
+// GetElementTypeBOMVersions lists the BOM versions of an element type.
+// @Summary List BOM versions of an element type
+// @Description Every version with its effective period, element type revision and the number of elements cast against it
+// @Tags ElementTypeBOM
+// @Produce json
+// @Param element_type_id path int true "Element Type ID"
+// @Success 200 {array} models.ElementTypeBOMVersion
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/element_type_bom_versions/{element_type_id} [get]
+func GetElementTypeBOMVersions(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		elementTypeID, err := strconv.Atoi(c.Param("element_type_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "element_type_id must be a valid integer"})
+			return
+		}
+
+		var projectID int
+		err = db.QueryRow(`SELECT project_id FROM element_type WHERE element_type_id = $1`, elementTypeID).Scan(&projectID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Element type not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
+			return
+		}
+
+		// Make sure the live BOM is represented, so unversioned element types get their baseline
+		if _, err := SnapshotElementTypeBOMVersion(db, elementTypeID, projectID, userName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to snapshot BOM version", "details": err.Error()})
+			return
+		}
+
+		rows, err := db.Query(`SELECT version_no FROM element_type_bom_version WHERE element_type_id = $1 ORDER BY version_no DESC`, elementTypeID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BOM versions", "details": err.Error()})
+			return
+		}
+		var versionNos []int
+		for rows.Next() {
+			var versionNo int
+			if err := rows.Scan(&versionNo); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan BOM version", "details": err.Error()})
+				return
+			}
+			versionNos = append(versionNos, versionNo)
+		}
+		rows.Close()
+
+		versions := []models.ElementTypeBOMVersion{}
+		for _, versionNo := range versionNos {
+			version, err := fetchBOMVersion(db, elementTypeID, versionNo)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BOM version", "details": err.Error()})
+				return
+			}
+			versions = append(versions, version)
+		}
+
+		c.JSON(http.StatusOK, versions)
+	}
+}
+
+// GetElementTypeBOMVersionDiff compares two BOM versions of an element type.
+// @Summary Diff two BOM versions of an element type
+// @Tags ElementTypeBOM
+// @Produce json
+// @Param element_type_id path int true "Element Type ID"
+// @Param from query int true "Version number to compare from"
+// @Param to query int false "Version number to compare to (default: latest)"
+// @Success 200 {object} models.BOMVersionDiff
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/element_type_bom_versions/{element_type_id}/diff [get]
+func GetElementTypeBOMVersionDiff(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		elementTypeID, err := strconv.Atoi(c.Param("element_type_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "element_type_id must be a valid integer"})
+			return
+		}
+		fromNo, err := strconv.Atoi(c.Query("from"))
+		if err != nil || fromNo < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a valid version number"})
+			return
+		}
+		toNo := 0
+		if toStr := c.Query("to"); toStr != "" {
+			if toNo, err = strconv.Atoi(toStr); err != nil || toNo < 1 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a valid version number"})
+				return
+			}
+		}
+
+		from, err := fetchBOMVersion(db, elementTypeID, fromNo)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("BOM version %d not found", fromNo)})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BOM version", "details": err.Error()})
+			return
+		}
+		to, err := fetchBOMVersion(db, elementTypeID, toNo)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": fmt.Sprintf("BOM version %d not found", toNo)})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BOM version", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, models.BOMVersionDiff{
+			ElementTypeID: elementTypeID,
+			From:          from,
+			To:            to,
+			Lines:         diffBOMVersions(from.Items, to.Items),
+		})
+	}
+}
+
+// GetElementBOMConsumption returns the material booked for a cast element and the BOM version it was cast with.
+// @Summary Get BOM consumption of an element
+// @Tags ElementTypeBOM
+// @Produce json
+// @Param element_id path int true "Element ID"
+// @Success 200 {array} models.ElementBOMConsumption
+// @Failure 400 {object} models.ErrorResponse
+// @Router /api/element_bom_consumption/{element_id} [get]
+func GetElementBOMConsumption(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		elementID, err := strconv.Atoi(c.Param("element_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "element_id must be a valid integer"})
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT ec.element_id, ec.element_type_id, ec.bom_version_id, v.version_no, ec.product_id,
+				ec.product_name, ec.quantity, ec.unit, ec.cast_at
+			FROM element_bom_consumption ec
+			JOIN element_type_bom_version v ON v.id = ec.bom_version_id
+			WHERE ec.element_id = $1
+			ORDER BY ec.product_name`, elementID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BOM consumption", "details": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		consumption := []models.ElementBOMConsumption{}
+		for rows.Next() {
+			var line models.ElementBOMConsumption
+			if err := rows.Scan(&line.ElementID, &line.ElementTypeID, &line.BOMVersionID, &line.VersionNo, &line.ProductID,
+				&line.ProductName, &line.Quantity, &line.Unit, &line.CastAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan BOM consumption", "details": err.Error()})
+				return
+			}
+			consumption = append(consumption, line)
+		}
+
+		c.JSON(http.StatusOK, consumption)
+	}
+}
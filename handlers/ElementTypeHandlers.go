@@ -580,6 +580,12 @@ func UpdateElementType(db *sql.DB) gin.HandlerFunc {
 		var fields []interface{}
 		placeholderIndex := 1
 
+		// Make sure the BOM in force so far is versioned under the current revision before anything changes
+		if _, err := SnapshotElementTypeBOMVersion(db, currentElementType.ElementTypeId, currentElementType.ProjectID, userName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to snapshot BOM version: %v", err)})
+			return
+		}
+
 		// Update the element type version
 		elementType.ElementTypeVersion = repository.GenerateVersionCode(currentElementType.ElementTypeVersion)
 
@@ -847,6 +853,11 @@ func UpdateElementType(db *sql.DB) gin.HandlerFunc {
 				}
 			}
 
+			// Step 5: Start a new BOM version, effective now, if the products changed
+			if _, err := SnapshotElementTypeBOMVersion(db, currentElementType.ElementTypeId, currentElementType.ProjectID, userName); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": fmt.Sprintf("Failed to save BOM version: %v", err)})
+				return
+			}
 		}
 
 		// Update related drawings and capture revision IDs
@@ -0,0 +1,733 @@
+package handlers
+
+import (
+	"backend/models"
+	"backend/repository"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// ElementTypeActionTemplate is the notification action URL of an element type
+const ElementTypeActionTemplate = "https://precastezy.blueinvent.com/project/%d/element-type/%d"
+
+// invoiceStageTerms maps the invoiced stages to their payment_term keys
+var invoiceStageTerms = []struct{ stage, term string }{
+	{"casted", "casted"},
+	{"dispatched", "dispatch"},
+	{"erection", "erection"},
+	{"handover", "handover"},
+}
+
+// elementTypeCurrent is an element type as it is now
+type elementTypeCurrent struct {
+	models.ElementTypeVersionDimensions
+	ProjectID   int
+	ElementType string
+}
+
+// loadElementTypeCurrent reads an element type. Pass forUpdate inside a transaction that changes it.
+func loadElementTypeCurrent(q sqlQueryer, elementTypeID int, forUpdate bool) (elementTypeCurrent, error) {
+	query := `
+		SELECT element_type_id, project_id, element_type, COALESCE(element_type_version, ''),
+			COALESCE(thickness, 0), COALESCE(length, 0), COALESCE(height, 0), COALESCE(width, 0),
+			COALESCE(volume, 0), COALESCE(mass, 0), COALESCE(area, 0), COALESCE(density, 0)
+		FROM element_type WHERE element_type_id = $1`
+	if forUpdate {
+		query += " FOR UPDATE"
+	}
+	var cur elementTypeCurrent
+	err := q.QueryRow(query, elementTypeID).Scan(&cur.ElementTypeID, &cur.ProjectID, &cur.ElementType,
+		&cur.ElementTypeVersion, &cur.Thickness, &cur.Length, &cur.Height, &cur.Width,
+		&cur.Volume, &cur.Mass, &cur.Area, &cur.Density)
+	return cur, err
+}
+
+// proposedRevision is the element type's dimensions with the change applied.
+func proposedRevision(cur models.ElementTypeVersionDimensions, change models.ElementTypeChange) models.ElementTypeVersionDimensions {
+	next := cur
+	next.ElementTypeVersion = repository.GenerateVersionCode(cur.ElementTypeVersion)
+	next.Comments = change.Comments
+	for _, f := range []struct {
+		to    *float64
+		value float64
+	}{
+		{&next.Thickness, change.Thickness}, {&next.Length, change.Length}, {&next.Height, change.Height},
+		{&next.Width, change.Width}, {&next.Volume, change.Volume}, {&next.Mass, change.Mass},
+		{&next.Area, change.Area}, {&next.Density, change.Density},
+	} {
+		if f.value != 0 {
+			*f.to = f.value
+		}
+	}
+	return next
+}
+
+// dimensionChanges lists the dimensions that differ between two revisions.
+func dimensionChanges(from, to models.ElementTypeVersionDimensions) []models.ElementTypeDimensionChange {
+	changes := []models.ElementTypeDimensionChange{}
+	for _, f := range []struct {
+		field    string
+		from, to float64
+	}{
+		{"thickness", from.Thickness, to.Thickness}, {"length", from.Length, to.Length},
+		{"height", from.Height, to.Height}, {"width", from.Width, to.Width},
+		{"volume", from.Volume, to.Volume}, {"mass", from.Mass, to.Mass},
+		{"area", from.Area, to.Area}, {"density", from.Density, to.Density},
+	} {
+		if math.Abs(f.from-f.to) > 1e-9 {
+			changes = append(changes, models.ElementTypeDimensionChange{Field: f.field, From: f.from, To: f.to})
+		}
+	}
+	return changes
+}
+
+// proposedBOM reads the product names of the proposed BOM lines. missing is the first product that
+// is not in the inventory.
+func proposedBOM(q sqlQueryer, products []models.Product) (items map[int]models.ElementTypeBOMVersionItem, missing int, err error) {
+	items = make(map[int]models.ElementTypeBOMVersionItem, len(products))
+	for _, p := range products {
+		item := models.ElementTypeBOMVersionItem{ProductID: p.ProductID, Quantity: p.Quantity, Unit: p.Unit, Rate: p.Rate}
+		err := q.QueryRow(`SELECT product_name FROM inv_bom WHERE id = $1`, p.ProductID).Scan(&item.ProductName)
+		if err == sql.ErrNoRows {
+			return nil, p.ProductID, nil
+		} else if err != nil {
+			return nil, 0, err
+		}
+		if existing, ok := items[item.ProductID]; ok {
+			item.Quantity += existing.Quantity
+		}
+		items[item.ProductID] = item
+	}
+	return items, 0, nil
+}
+
+// revisionGetsChange reports whether an element in the given state takes a new revision. Once cast
+// an element keeps the revision it was made to.
+func revisionGetsChange(state string) bool {
+	return lifecycleIndex(state) < lifecycleIndex(models.LifecycleQCPassed)
+}
+
+// elementTypeImpact works out what a change of an element type does to its elements, material,
+// open tasks and invoices. The uncast elements, which would get the change, are returned with it.
+func elementTypeImpact(q sqlQueryer, cur elementTypeCurrent, next models.ElementTypeVersionDimensions,
+	current, proposed map[int]models.ElementTypeBOMVersionItem, drawingTypeIDs []int) (models.ElementTypeImpact, []int, error) {
+	impact := models.ElementTypeImpact{
+		ElementTypeID:   cur.ElementTypeID,
+		ProjectID:       cur.ProjectID,
+		CurrentVersion:  cur.ElementTypeVersion,
+		NewVersion:      next.ElementTypeVersion,
+		Dimensions:      dimensionChanges(cur.ElementTypeVersionDimensions, next),
+		BOMChanged:      !sameBOMLines(current, proposed),
+		DrawingsChanged: drawingTypeIDs,
+		States:          []models.ElementTypeImpactState{},
+		Inventory:       []models.ElementTypeInventoryDelta{},
+		TasksToReissue:  []models.ElementTypeImpactTask{},
+		Invoices:        []models.ElementTypeInvoiceImpact{},
+	}
+	if impact.DrawingsChanged == nil {
+		impact.DrawingsChanged = []int{}
+	}
+
+	// Elements by lifecycle state
+	states, err := loadElementStates(q, `WHERE e.element_type_id = $1 ORDER BY e.id`, cur.ElementTypeID)
+	if err != nil {
+		return impact, nil, fmt.Errorf("failed to fetch element states: %v", err)
+	}
+	counts := make(map[string]int)
+	uncast := make(map[int]bool)
+	var uncastIDs []int
+	for _, s := range states {
+		state := s.current()
+		counts[state]++
+		if revisionGetsChange(state) {
+			uncast[s.ElementID] = true
+			uncastIDs = append(uncastIDs, s.ElementID)
+		}
+	}
+	for _, state := range models.LifecycleStates {
+		gets := revisionGetsChange(state)
+		impact.States = append(impact.States, models.ElementTypeImpactState{State: state, Elements: counts[state], GetsChange: gets})
+		if gets {
+			impact.ElementsChanged += counts[state]
+		} else {
+			impact.ElementsKept += counts[state]
+		}
+	}
+
+	// Material for the elements still to be cast
+	if impact.BOMChanged {
+		n := float64(len(uncastIDs))
+		for _, line := range diffBOMVersions(sortedBOMVersionItems(current), sortedBOMVersionItems(proposed)) {
+			if line.Change == "unchanged" {
+				continue
+			}
+			delta := models.ElementTypeInventoryDelta{
+				BOMVersionDiffLine: line,
+				Elements:           len(uncastIDs),
+				RequiredBefore:     line.FromQuantity * n,
+				RequiredAfter:      line.ToQuantity * n,
+			}
+			delta.RequiredDelta = delta.RequiredAfter - delta.RequiredBefore
+			if err := q.QueryRow(`SELECT COALESCE(SUM(bom_qty), 0) FROM inv_track WHERE project_id = $1 AND bom_id = $2`,
+				cur.ProjectID, line.ProductID).Scan(&delta.OnHand); err != nil {
+				return impact, nil, fmt.Errorf("failed to fetch stock on hand: %v", err)
+			}
+			delta.Shortfall = math.Max(0, delta.RequiredAfter-delta.OnHand)
+			impact.Inventory = append(impact.Inventory, delta)
+		}
+	}
+
+	// Open tasks of the elements that get the change
+	if len(uncastIDs) > 0 {
+		rows, err := q.Query(`
+			SELECT a.id, e.id, COALESCE(e.element_name, ''), COALESCE(a.stage_id, 0), COALESCE(ps.name, ''),
+				COALESCE(a.status, ''), COALESCE(a.assigned_to, 0)
+			FROM activity a
+			JOIN element e ON e.id = a.element_id
+			LEFT JOIN project_stages ps ON ps.id = a.stage_id
+			WHERE a.element_id = ANY($1) AND COALESCE(a.completed, FALSE) = FALSE
+			ORDER BY e.id, a.id`, pq.Array(uncastIDs))
+		if err != nil {
+			return impact, nil, fmt.Errorf("failed to fetch open tasks: %v", err)
+		}
+		for rows.Next() {
+			var t models.ElementTypeImpactTask
+			if err := rows.Scan(&t.ActivityID, &t.ElementID, &t.ElementName, &t.StageID, &t.StageName, &t.Status, &t.AssignedTo); err != nil {
+				rows.Close()
+				return impact, nil, fmt.Errorf("failed to read open task: %v", err)
+			}
+			impact.TasksToReissue = append(impact.TasksToReissue, t)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return impact, nil, fmt.Errorf("failed to read open tasks: %v", err)
+		}
+	}
+
+	if math.Abs(cur.Volume-next.Volume) > 1e-9 {
+		invoices, err := elementTypeInvoiceImpact(q, cur, next.Volume, uncast)
+		if err != nil {
+			return impact, nil, err
+		}
+		impact.Invoices = invoices
+		for _, inv := range invoices {
+			impact.InvoiceDelta += inv.Delta
+			impact.InvoiceDeltaInPlace += inv.DeltaInPlace
+		}
+	}
+	return impact, uncastIDs, nil
+}
+
+// elementTypeInvoiceImpact works out how the amounts still to be invoiced change with the volume of an
+// element type. Elements are matched to work order lines and priced per stage as the invoice cron
+// does; stages an element was already invoiced for don't change.
+func elementTypeInvoiceImpact(q sqlQueryer, cur elementTypeCurrent, newVolume float64, uncast map[int]bool) ([]models.ElementTypeInvoiceImpact, error) {
+	// Elements are billed by the volume of the revision they carry
+	versionVolume := make(map[string]float64)
+	rows, err := q.Query(`SELECT element_type_version, volume FROM element_type_revision WHERE element_type_id = $1`, cur.ElementTypeID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch element type revisions: %v", err)
+	}
+	for rows.Next() {
+		var version string
+		var volume float64
+		if err := rows.Scan(&version, &volume); err != nil {
+			rows.Close()
+			return nil, fmt.Errorf("failed to read element type revision: %v", err)
+		}
+		versionVolume[version] = volume
+	}
+	rows.Close()
+
+	// Stages already invoiced per work order and element
+	billed := make(map[string]bool)
+	var hasHistory bool
+	if err := q.QueryRow(`SELECT to_regclass('element_invoice_history') IS NOT NULL`).Scan(&hasHistory); err != nil {
+		return nil, fmt.Errorf("failed to check invoice history: %v", err)
+	}
+	if hasHistory {
+		rows, err := q.Query(`
+			SELECT h.work_order_id, h.element_id, h.stage
+			FROM element_invoice_history h
+			JOIN element e ON e.id = h.element_id
+			WHERE e.element_type_id = $1`, cur.ElementTypeID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to fetch invoice history: %v", err)
+		}
+		for rows.Next() {
+			var workOrderID, elementID int
+			var stage string
+			if err := rows.Scan(&workOrderID, &elementID, &stage); err != nil {
+				rows.Close()
+				return nil, fmt.Errorf("failed to read invoice history: %v", err)
+			}
+			billed[fmt.Sprintf("%d/%d/%s", workOrderID, elementID, stage)] = true
+		}
+		rows.Close()
+	}
+
+	rows, err = q.Query(`
+		SELECT wo.id, COALESCE(wo.wo_number, ''), COALESCE(wo.payment_term::text, ''), wom.id, wom.item_name,
+			COALESCE(wom.unit_rate, 0), COALESCE(wom.tax, 0), e.id, COALESCE(e.element_type_version, '')
+		FROM work_order wo
+		JOIN work_order_material wom ON wom.work_order_id = wo.id
+		JOIN element e ON e.element_type_id = $1
+			AND LOWER(wom.item_name) = LOWER($2)
+			AND (
+				wom.floor_id IS NULL
+				OR array_length(wom.floor_id, 1) = 0
+				OR e.target_location = ANY(wom.floor_id)
+			)
+		WHERE wo.project_id = $3 AND e.billable = true
+		ORDER BY wo.id, wom.id, e.id`, cur.ElementTypeID, cur.ElementType, cur.ProjectID)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch work order lines: %v", err)
+	}
+	defer rows.Close()
+
+	lines := make(map[string]*models.ElementTypeInvoiceImpact)
+	var order []string
+	for rows.Next() {
+		var workOrderID, materialID, elementID int
+		var woNumber, paymentTermJSON, itemName, version string
+		var unitRate, tax float64
+		if err := rows.Scan(&workOrderID, &woNumber, &paymentTermJSON, &materialID, &itemName,
+			&unitRate, &tax, &elementID, &version); err != nil {
+			return nil, fmt.Errorf("failed to read work order line: %v", err)
+		}
+		paymentTerms := map[string]float64{}
+		if paymentTermJSON != "" {
+			if err := json.Unmarshal([]byte(paymentTermJSON), &paymentTerms); err != nil {
+				log.Printf("Invalid payment_term on work order %d: %v", workOrderID, err)
+			}
+		}
+		volume, ok := versionVolume[version]
+		if !ok {
+			volume = cur.Volume
+		}
+
+		for _, st := range invoiceStageTerms {
+			if billed[fmt.Sprintf("%d/%d/%s", workOrderID, elementID, st.stage)] {
+				continue
+			}
+			// A stage without a payment term is billed in full, as the invoice cron does
+			pct := 100.0
+			if p, ok := paymentTerms[st.term]; ok && p >= 0 {
+				pct = p
+			}
+			factor := unitRate * pct / 100 * (1 + tax/100)
+
+			key := fmt.Sprintf("%d/%s", materialID, st.stage)
+			line, ok := lines[key]
+			if !ok {
+				line = &models.ElementTypeInvoiceImpact{
+					WorkOrderID: workOrderID,
+					WONumber:    woNumber,
+					MaterialID:  materialID,
+					ItemName:    itemName,
+					Stage:       st.stage,
+					Percentage:  pct,
+				}
+				lines[key] = line
+				order = append(order, key)
+			}
+			line.DeltaInPlace += factor * (newVolume - volume)
+			if uncast[elementID] {
+				line.Elements++
+				line.CurrentAmount += factor * volume
+				line.NewAmount += factor * newVolume
+			}
+		}
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read work order lines: %v", err)
+	}
+
+	impacts := []models.ElementTypeInvoiceImpact{}
+	for _, key := range order {
+		line := lines[key]
+		line.Delta = line.NewAmount - line.CurrentAmount
+		if math.Abs(line.Delta) < 1e-9 && math.Abs(line.DeltaInPlace) < 1e-9 {
+			continue
+		}
+		impacts = append(impacts, *line)
+	}
+	return impacts, nil
+}
+
+// insertElementTypeRevision records the dimensions of an element type version. A version is only
+// recorded once.
+func insertElementTypeRevision(q sqlQueryer, projectID int, rev models.ElementTypeVersionDimensions, userName string) error {
+	_, err := q.Exec(`
+		INSERT INTO element_type_revision (element_type_id, project_id, element_type_version, thickness, length,
+			height, width, volume, mass, area, density, comments, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		ON CONFLICT (element_type_id, element_type_version) DO NOTHING`,
+		rev.ElementTypeID, projectID, rev.ElementTypeVersion, rev.Thickness, rev.Length,
+		rev.Height, rev.Width, rev.Volume, rev.Mass, rev.Area, rev.Density, rev.Comments, userName)
+	return err
+}
+
+// reissueElementTasks moves the open tasks of the elements back to the first stage of the element
+// type's path, so the work is redone to the new revision. It returns the number of tasks reissued.
+func reissueElementTasks(q sqlQueryer, elementTypeID int, elementIDs []int) (int, error) {
+	if len(elementIDs) == 0 {
+		return 0, nil
+	}
+	var stagePathStr string
+	err := q.QueryRow(`SELECT stage_path FROM element_type_path WHERE element_type_id = $1`, elementTypeID).Scan(&stagePathStr)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	} else if err != nil {
+		return 0, err
+	}
+	stagePath := parseStagePath(stagePathStr)
+	if len(stagePath) == 0 {
+		return 0, nil
+	}
+
+	var assignedTo, qcID, paperID int
+	if err := q.QueryRow(`SELECT COALESCE(assigned_to, 0), COALESCE(qc_id, 0), COALESCE(paper_id, 0) FROM project_stages WHERE id = $1 LIMIT 1`,
+		stagePath[0]).Scan(&assignedTo, &qcID, &paperID); err != nil && err != sql.ErrNoRows {
+		return 0, err
+	}
+	res, err := q.Exec(`
+		UPDATE activity
+		SET stage_id = $1,
+			status = 'Inprogress',
+			qc_status = 'Inprogress',
+			mesh_mold_status = 'Inprogress',
+			reinforcement_status = 'Inprogress',
+			meshmold_qc_status = 'Inprogress',
+			reinforcement_qc_status = 'Inprogress',
+			assigned_to = $2,
+			qc_id = $3,
+			paper_id = $4
+		WHERE element_id = ANY($5) AND COALESCE(completed, FALSE) = FALSE`,
+		stagePath[0], assignedTo, qcID, paperID, pq.Array(elementIDs))
+	if err != nil {
+		return 0, err
+	}
+	n, _ := res.RowsAffected()
+	return int(n), nil
+}
+
+// saveElementTypeImpactActivity writes the activity log of an element type revision.
+func saveElementTypeImpactActivity(db *sql.DB, session models.Session, userName, eventName, description string, projectID int) {
+	activityLog := models.ActivityLog{
+		EventContext: "ElementType",
+		EventName:    eventName,
+		Description:  description,
+		UserName:     userName,
+		HostName:     session.HostName,
+		IPAddress:    session.IPAddress,
+		CreatedAt:    time.Now(),
+		ProjectID:    projectID,
+	}
+	if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+		log.Printf("Failed to save activity log: %v", logErr)
+	}
+}
+
+// elementTypeChangeParams reads the element type ID and the proposed change, writing the error
+// response when it can't.
+func elementTypeChangeParams(c *gin.Context) (elementTypeID int, change models.ElementTypeChange, ok bool) {
+	elementTypeID, err := strconv.Atoi(c.Param("element_type_id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "element_type_id must be a valid integer"})
+		return 0, change, false
+	}
+	if err := c.ShouldBindJSON(&change); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+		return 0, change, false
+	}
+	for _, p := range change.Products {
+		if p.Quantity < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Quantity of product %d can't be negative", p.ProductID)})
+			return 0, change, false
+		}
+	}
+	return elementTypeID, change, true
+}
+
+// prepareElementTypeChange reads the element type and the BOM before and after the change, writing
+// the error response when it can't.
+func prepareElementTypeChange(c *gin.Context, q sqlQueryer, elementTypeID int, change models.ElementTypeChange, forUpdate bool) (
+	cur elementTypeCurrent, next models.ElementTypeVersionDimensions, current, proposed map[int]models.ElementTypeBOMVersionItem, ok bool) {
+	cur, err := loadElementTypeCurrent(q, elementTypeID, forUpdate)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Element type not found"})
+		return cur, next, nil, nil, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch element type", "details": err.Error()})
+		return cur, next, nil, nil, false
+	}
+	next = proposedRevision(cur.ElementTypeVersionDimensions, change)
+
+	current, err = loadCurrentElementTypeBOM(q, elementTypeID, cur.ProjectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BOM", "details": err.Error()})
+		return cur, next, nil, nil, false
+	}
+	proposed = current
+	if len(change.Products) > 0 {
+		var missing int
+		proposed, missing, err = proposedBOM(q, change.Products)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch products", "details": err.Error()})
+			return cur, next, nil, nil, false
+		}
+		if missing != 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Product ID %d not found in inventory", missing)})
+			return cur, next, nil, nil, false
+		}
+	}
+
+	if len(dimensionChanges(cur.ElementTypeVersionDimensions, next)) == 0 && sameBOMLines(current, proposed) && len(change.Drawings) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "The change leaves the element type as it is"})
+		return cur, next, nil, nil, false
+	}
+	return cur, next, current, proposed, true
+}
+
+// GetElementTypeChangeImpact works out what a proposed element type change would do before it is made:
+// the elements by lifecycle state, the material needed for the elements still to be cast against the
+// stock on hand, the open tasks that would be re-issued and the invoice amounts that would change.
+// Nothing is changed.
+// @Summary Element type change impact
+// @Description What-if report of a proposed element type change. Elements from qc_passed on keep their revision.
+// @Tags ElementTypes
+// @Accept json
+// @Produce json
+// @Param element_type_id path int true "Element Type ID"
+// @Param request body models.ElementTypeChange true "Proposed change"
+// @Success 200 {object} models.ElementTypeImpact
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/elementtype/{element_type_id}/impact [post]
+func GetElementTypeChangeImpact(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+		elementTypeID, change, ok := elementTypeChangeParams(c)
+		if !ok {
+			return
+		}
+		cur, next, current, proposed, ok := prepareElementTypeChange(c, db, elementTypeID, change, false)
+		if !ok {
+			return
+		}
+
+		impact, _, err := elementTypeImpact(db, cur, next, current, proposed, change.Drawings)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to work out the impact", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, impact)
+	}
+}
+
+// ApplyElementTypeRevision applies a change to an element type as a new version that only the elements
+// not yet cast take. Cast elements keep the version, dimensions and BOM they were made to, and are
+// still invoiced by the volume of that version. Open tasks of the changed elements are re-issued from
+// the first stage.
+// @Summary Apply element type revision
+// @Tags ElementTypes
+// @Accept json
+// @Produce json
+// @Param element_type_id path int true "Element Type ID"
+// @Param request body models.ElementTypeChange true "Change to apply"
+// @Success 200 {object} models.ElementTypeRevisionResult
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/elementtype/{element_type_id}/revisions [post]
+func ApplyElementTypeRevision(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+		elementTypeID, change, ok := elementTypeChangeParams(c)
+		if !ok {
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction", "details": err.Error()})
+			return
+		}
+		defer tx.Rollback()
+
+		cur, next, current, proposed, ok := prepareElementTypeChange(c, tx, elementTypeID, change, true)
+		if !ok {
+			return
+		}
+		impact, uncastIDs, err := elementTypeImpact(tx, cur, next, current, proposed, change.Drawings)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to work out the impact", "details": err.Error()})
+			return
+		}
+
+		// The BOM and dimensions in force so far stay with the current version
+		if _, err := SnapshotElementTypeBOMVersion(tx, elementTypeID, cur.ProjectID, userName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to snapshot BOM version", "details": err.Error()})
+			return
+		}
+		if err := insertElementTypeRevision(tx, cur.ProjectID, cur.ElementTypeVersionDimensions, userName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save current revision", "details": err.Error()})
+			return
+		}
+
+		if _, err := tx.Exec(`
+			UPDATE element_type
+			SET thickness = $1, length = $2, height = $3, width = $4, volume = $5, mass = $6, area = $7,
+				density = $8, element_type_version = $9, update_at = NOW()
+			WHERE element_type_id = $10`,
+			next.Thickness, next.Length, next.Height, next.Width, next.Volume, next.Mass, next.Area,
+			next.Density, next.ElementTypeVersion, elementTypeID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update element type", "details": err.Error()})
+			return
+		}
+		if err := insertElementTypeRevision(tx, cur.ProjectID, next, userName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save new revision", "details": err.Error()})
+			return
+		}
+
+		if impact.BOMChanged {
+			if _, err := tx.Exec(`DELETE FROM element_type_bom WHERE element_type_id = $1 AND project_id = $2`, elementTypeID, cur.ProjectID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete existing BOM records", "details": err.Error()})
+				return
+			}
+			now := time.Now()
+			for _, item := range sortedBOMVersionItems(proposed) {
+				if _, err := tx.Exec(`
+					INSERT INTO element_type_bom (element_type_id, project_id, product_id, product_name, quantity, unit, rate, created_at, created_by, updated_at, updated_by)
+					VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11)`,
+					elementTypeID, cur.ProjectID, item.ProductID, item.ProductName, item.Quantity, item.Unit, item.Rate,
+					now, userName, now, userName); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to insert BOM product", "details": err.Error()})
+					return
+				}
+			}
+		}
+		// Elements cast from now on are costed by the new BOM version
+		bomVersionID, err := SnapshotElementTypeBOMVersion(tx, elementTypeID, cur.ProjectID, userName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save BOM version", "details": err.Error()})
+			return
+		}
+
+		if len(uncastIDs) > 0 {
+			if _, err := tx.Exec(`UPDATE element SET element_type_version = $1, update_at = NOW() WHERE id = ANY($2)`,
+				next.ElementTypeVersion, pq.Array(uncastIDs)); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update elements", "details": err.Error()})
+				return
+			}
+		}
+		reissued, err := reissueElementTasks(tx, elementTypeID, uncastIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to re-issue tasks", "details": err.Error()})
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit revision", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, models.ElementTypeRevisionResult{
+			ElementTypeID:   elementTypeID,
+			PreviousVersion: cur.ElementTypeVersion,
+			NewVersion:      next.ElementTypeVersion,
+			ElementsChanged: impact.ElementsChanged,
+			ElementsKept:    impact.ElementsKept,
+			TasksReissued:   reissued,
+			BOMVersionID:    bomVersionID,
+		})
+
+		var changed []string
+		for _, d := range impact.Dimensions {
+			changed = append(changed, d.Field)
+		}
+		if impact.BOMChanged {
+			changed = append(changed, "BOM")
+		}
+		if len(change.Drawings) > 0 {
+			changed = append(changed, "drawings")
+		}
+		sort.Strings(changed)
+		sendProjectNotifications(db, cur.ProjectID,
+			fmt.Sprintf("Element type %s revised to %s (%s): %d elements not yet cast take the revision, %d keep %s",
+				cur.ElementType, next.ElementTypeVersion, strings.Join(changed, ", "), impact.ElementsChanged,
+				impact.ElementsKept, cur.ElementTypeVersion),
+			fmt.Sprintf(ElementTypeActionTemplate, cur.ProjectID, elementTypeID))
+		saveElementTypeImpactActivity(db, session, userName, "Revise",
+			fmt.Sprintf("Revised element type %s from %s to %s (%s), %d elements changed, %d tasks re-issued",
+				cur.ElementType, cur.ElementTypeVersion, next.ElementTypeVersion, strings.Join(changed, ", "),
+				impact.ElementsChanged, reissued), cur.ProjectID)
+	}
+}
+
+// GetElementTypeRevisions lists the revisions applied to an element type with the number of elements
+// carrying each.
+// @Summary List element type revisions
+// @Tags ElementTypes
+// @Produce json
+// @Param element_type_id path int true "Element Type ID"
+// @Success 200 {array} models.ElementTypeVersionDimensions
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/elementtype/{element_type_id}/revisions [get]
+func GetElementTypeRevisions(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+		elementTypeID, err := strconv.Atoi(c.Param("element_type_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "element_type_id must be a valid integer"})
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT r.element_type_id, r.element_type_version, r.thickness, r.length, r.height, r.width, r.volume,
+				r.mass, r.area, r.density, r.comments, r.created_by, r.created_at,
+				(SELECT COUNT(*) FROM element e WHERE e.element_type_id = r.element_type_id
+					AND e.element_type_version = r.element_type_version)
+			FROM element_type_revision r
+			WHERE r.element_type_id = $1
+			ORDER BY r.created_at DESC, r.id DESC`, elementTypeID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch revisions", "details": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		revisions := []models.ElementTypeVersionDimensions{}
+		for rows.Next() {
+			var r models.ElementTypeVersionDimensions
+			if err := rows.Scan(&r.ElementTypeID, &r.ElementTypeVersion, &r.Thickness, &r.Length, &r.Height, &r.Width,
+				&r.Volume, &r.Mass, &r.Area, &r.Density, &r.Comments, &r.CreatedBy, &r.CreatedAt, &r.Elements); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read revision", "details": err.Error()})
+				return
+			}
+			revisions = append(revisions, r)
+		}
+		c.JSON(http.StatusOK, revisions)
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"backend/models"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -12,6 +13,7 @@ import (
 	"time"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
 )
 
 type StockErectionRequest map[string][]struct {
@@ -1269,6 +1271,38 @@ func UpdateErectedStatus(db *sql.DB) gin.HandlerFunc {
 		}
 		defer tx.Rollback()
 
+		// Only elements approved for erection are received, as before
+		var approvedIDs []int
+		rows, err := tx.Query(`
+			SELECT element_id FROM stock_erected
+			WHERE element_id = ANY($1) AND project_id = $2 AND approved_status = true`,
+			pq.Array(req.ElementIDs), req.ProjectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check erection approval", "details": err.Error()})
+			return
+		}
+		for rows.Next() {
+			var id int
+			if err := rows.Scan(&id); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check erection approval", "details": err.Error()})
+				return
+			}
+			approvedIDs = append(approvedIDs, id)
+		}
+		rows.Close()
+
+		// Move the elements to received, which updates precast_stock, stock_erected and element status
+		if err = TransitionElements(tx, approvedIDs, models.LifecycleReceived, "Received in erection site", userName); err != nil {
+			var illegalErr *models.ErrIllegalTransition
+			if errors.As(err, &illegalErr) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Some elements can't be received from the state they are in", "conflicts": illegalErr.Elements})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update erection status", "details": err.Error()})
+			return
+		}
+
 		// Process each element ID
 		for _, elementID := range req.ElementIDs {
 			// Get dispatch order number for the element
@@ -1283,21 +1317,6 @@ func UpdateErectedStatus(db *sql.DB) gin.HandlerFunc {
 				return
 			}
 
-			// Update stock_erected table (remove non-existent erected_at/erected_by)
-			_, err = tx.Exec(`
-				UPDATE stock_erected 
-				SET recieve_in_erection = true
-				WHERE element_id = $1 
-				AND project_id = $2
-				AND approved_status = true`,
-				elementID,
-				req.ProjectID,
-			)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update erection status"})
-				return
-			}
-
 			// Insert into stock_erected_logs
 			_, err = tx.Exec(`
 				INSERT INTO stock_erected_logs 
@@ -1341,15 +1360,6 @@ func UpdateErectedStatus(db *sql.DB) gin.HandlerFunc {
 					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update dispatch tracking log"})
 					return
 				}
-
-				_, err = tx.Exec(`
-					UPDATE element
-					SET status = 'Dispatch'
-					WHERE id = $1`, elementID)
-				if err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update dispatch order status"})
-					return
-				}
 			}
 		}
 
@@ -1573,6 +1583,23 @@ func UpdateStockErectedWhenErected(db *sql.DB) gin.HandlerFunc {
 		}
 		defer tx.Rollback()
 
+		// Move the elements to erected, which updates precast_stock, stock_erected and element status
+		if err = TransitionElements(tx, req.ElementIDs, models.LifecycleErected, "Erected at site", userName); err != nil {
+			var illegalErr *models.ErrIllegalTransition
+			if errors.As(err, &illegalErr) {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":     "Some elements can't be erected from the state they are in",
+					"conflicts": illegalErr.Elements,
+				})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to update element lifecycle",
+				"details": err.Error(),
+			})
+			return
+		}
+
 		// Process each element ID
 		for _, elementID := range req.ElementIDs {
 			// First, check if the record exists and is in the correct state
@@ -1643,78 +1670,6 @@ func UpdateStockErectedWhenErected(db *sql.DB) gin.HandlerFunc {
 				return
 			}
 
-			// Update precast_stock table (columns erected_at/erected_by likely do not exist)
-			result2, err := tx.Exec(`
-				UPDATE precast_stock 
-				SET erected = true
-				WHERE element_id = $1 
-				AND project_id = $2`,
-				elementID,
-				req.ProjectID,
-			)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error":      "Failed to update precast_stock status",
-					"details":    err.Error(),
-					"element_id": elementID,
-				})
-				return
-			}
-
-			// Check if precast_stock was updated
-			rowsAffected2, err := result2.RowsAffected()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error":      "Failed to get precast_stock rows affected",
-					"details":    err.Error(),
-					"element_id": elementID,
-				})
-				return
-			}
-
-			if rowsAffected2 == 0 {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error":      "No precast_stock record found for element",
-					"element_id": elementID,
-					"project_id": req.ProjectID,
-				})
-				return
-			}
-
-			// Update element status
-			result3, err := tx.Exec(`
-				UPDATE element
-				SET status = 'Erected'
-				WHERE id = $1`, elementID,
-			)
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error":      "Failed to update element status",
-					"details":    err.Error(),
-					"element_id": elementID,
-				})
-				return
-			}
-
-			// Check if element was updated
-			rowsAffected3, err := result3.RowsAffected()
-			if err != nil {
-				c.JSON(http.StatusInternalServerError, gin.H{
-					"error":      "Failed to get element rows affected",
-					"details":    err.Error(),
-					"element_id": elementID,
-				})
-				return
-			}
-
-			if rowsAffected3 == 0 {
-				c.JSON(http.StatusBadRequest, gin.H{
-					"error":      "No element record found",
-					"element_id": elementID,
-				})
-				return
-			}
-
 			// Insert into stock_erected_logs
 			_, err = tx.Exec(`
 				INSERT INTO stock_erected_logs 
@@ -0,0 +1,420 @@
+package handlers
+
+import (
+	"backend/services"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Global object store - set from main.go. Falls back to the local upload directory.
+var GlobalObjectStore services.ObjectStore
+
+// SetObjectStore sets the global object store used for uploads
+func SetObjectStore(store services.ObjectStore) {
+	GlobalObjectStore = store
+}
+
+var localFallbackStore struct {
+	once  sync.Once
+	store services.ObjectStore
+}
+
+// objectStore returns the configured store, or a local store on imageDir when none was set
+func objectStore() services.ObjectStore {
+	if GlobalObjectStore != nil {
+		return GlobalObjectStore
+	}
+	localFallbackStore.once.Do(func() {
+		store, err := services.NewLocalStore(imageDir)
+		if err != nil {
+			log.Printf("Warning: local object store unavailable: %v", err)
+			return
+		}
+		localFallbackStore.store = store
+	})
+	return localFallbackStore.store
+}
+
+const (
+	defaultFileURLExpiry = 15 * time.Minute
+	maxFileURLExpiry     = 7 * 24 * time.Hour
+)
+
+// StoredFile is the catalogue entry of an uploaded object
+type StoredFile struct {
+	FileName     string
+	SHA256       string
+	StorageKey   string
+	Size         int64
+	ContentType  string
+	OriginalName string
+	Backend      string
+}
+
+// contentKey is the content-addressed storage key of a hash
+func contentKey(sum string) string {
+	return fmt.Sprintf("objects/%s/%s/%s", sum[:2], sum[2:4], sum)
+}
+
+// StoreUpload streams r into the object store addressed by its SHA-256. Identical content is
+// stored once; the returned bool reports whether the content was already present.
+func StoreUpload(db *sql.DB, r io.Reader, originalName, contentType, uploadedBy string) (StoredFile, bool, error) {
+	tmp, _, err := spoolUpload(r, 0)
+	if err != nil {
+		return StoredFile{}, false, err
+	}
+	defer os.Remove(tmp.Name())
+	defer tmp.Close()
+
+	ext := strings.ToLower(filepath.Ext(filepath.Base(originalName)))
+	return storeSpooledFile(db, tmp, ext, originalName, contentType, uploadedBy)
+}
+
+// spoolUpload copies r to a temp file. With limit > 0 it stops after limit+1 bytes so the
+// caller can tell an oversized upload without reading all of it.
+func spoolUpload(r io.Reader, limit int64) (*os.File, int64, error) {
+	tmp, err := os.CreateTemp("", "upload-*")
+	if err != nil {
+		return nil, 0, err
+	}
+	if limit > 0 {
+		r = io.LimitReader(r, limit+1)
+	}
+	size, err := io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return nil, 0, fmt.Errorf("unable to read upload: %w", err)
+	}
+	return tmp, size, nil
+}
+
+// hashFile returns the hex SHA-256 and size of an open file
+func hashFile(f *os.File) (string, int64, error) {
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+	h := sha256.New()
+	size, err := io.Copy(h, f)
+	if err != nil {
+		return "", 0, err
+	}
+	return hex.EncodeToString(h.Sum(nil)), size, nil
+}
+
+// storeSpooledFile puts a spooled upload into the object store under its content hash and
+// records it in the catalogue as <sha256><ext>
+func storeSpooledFile(db *sql.DB, tmp *os.File, ext, originalName, contentType, uploadedBy string) (StoredFile, bool, error) {
+	var sf StoredFile
+	store := objectStore()
+	if store == nil {
+		return sf, false, fmt.Errorf("no object store configured")
+	}
+
+	sum, size, err := hashFile(tmp)
+	if err != nil {
+		return sf, false, err
+	}
+
+	if contentType == "" || contentType == "application/octet-stream" {
+		head := make([]byte, 512)
+		n, _ := tmp.ReadAt(head, 0)
+		contentType = http.DetectContentType(head[:n])
+	}
+
+	sf = StoredFile{
+		FileName:     sum + ext,
+		SHA256:       sum,
+		StorageKey:   contentKey(sum),
+		Size:         size,
+		ContentType:  contentType,
+		OriginalName: filepath.Base(originalName),
+		Backend:      store.Name(),
+	}
+
+	ctx := context.Background()
+	duplicate := false
+	if _, err := store.Stat(ctx, sf.StorageKey); err == nil {
+		duplicate = true
+	} else if !errors.Is(err, services.ErrObjectNotFound) {
+		return sf, false, err
+	} else {
+		if _, err := tmp.Seek(0, io.SeekStart); err != nil {
+			return sf, false, err
+		}
+		if err := store.Put(ctx, sf.StorageKey, tmp, size, contentType); err != nil {
+			return sf, false, fmt.Errorf("unable to store file: %w", err)
+		}
+	}
+
+	_, err = db.Exec(`
+		INSERT INTO stored_file (file_name, sha256, storage_key, size, content_type, original_name, backend, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8)
+		ON CONFLICT (file_name) DO NOTHING`,
+		sf.FileName, sf.SHA256, sf.StorageKey, sf.Size, sf.ContentType, sf.OriginalName, sf.Backend, uploadedBy)
+	if err != nil {
+		return sf, duplicate, err
+	}
+	return sf, duplicate, nil
+}
+
+// errFileNotServed is returned for names that are neither catalogued nor legacy uploads
+var errFileNotServed = errors.New("file is not served")
+
+// internalKeyPrefixes are object store areas that are never served by name
+var internalKeyPrefixes = []string{"quarantine/", "derived/", "imports/"}
+
+// isInternalFileName tells whether a name points into one of the internal object store areas
+func isInternalFileName(fileName string) bool {
+	for _, prefix := range internalKeyPrefixes {
+		if strings.HasPrefix(fileName, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// resolveStoredFile maps a file name to its storage key. Names not in the catalogue are
+// legacy uploads kept flat in the upload directory and use the name itself as the key; any
+// other name, such as a raw key in a subdirectory, returns errFileNotServed.
+func resolveStoredFile(db *sql.DB, fileName string) (StoredFile, error) {
+	sf := StoredFile{FileName: fileName, StorageKey: fileName}
+	if isInternalFileName(fileName) {
+		return sf, errFileNotServed
+	}
+	err := db.QueryRow(`
+		SELECT sha256, storage_key, size, content_type, original_name, backend
+		FROM stored_file WHERE file_name = $1`, fileName).
+		Scan(&sf.SHA256, &sf.StorageKey, &sf.Size, &sf.ContentType, &sf.OriginalName, &sf.Backend)
+	if err == sql.ErrNoRows {
+		if strings.ContainsAny(fileName, `/\`) {
+			return sf, errFileNotServed
+		}
+		return sf, nil
+	}
+	return sf, err
+}
+
+// openStoredFile opens a file from the object store, falling back to the legacy upload
+// directory for files written before the store was switched to another backend
+func openStoredFile(sf StoredFile) (io.ReadCloser, services.ObjectInfo, error) {
+	store := objectStore()
+	if store == nil {
+		return nil, services.ObjectInfo{}, fmt.Errorf("no object store configured")
+	}
+	rc, info, err := store.Get(context.Background(), sf.StorageKey)
+	if errors.Is(err, services.ErrObjectNotFound) && sf.SHA256 == "" && store.Name() != "local" {
+		if legacy, lerr := services.NewLocalStore(imageDir); lerr == nil {
+			return legacy.Get(context.Background(), sf.StorageKey)
+		}
+	}
+	return rc, info, err
+}
+
+var fileURLKey []byte
+
+// SetFileURLSigningKey sets the key that signs /api/get-file URLs. It must be the same on every
+// instance and across restarts, so a missing key is a configuration error.
+func SetFileURLSigningKey(key string) error {
+	if key == "" {
+		return errors.New("FILE_URL_SIGNING_KEY is required")
+	}
+	fileURLKey = []byte(key)
+	return nil
+}
+
+// fileURLSignature signs a file name, image variant ("" for the original) and expiry
+func fileURLSignature(fileName, variant string, expires int64) (string, error) {
+	if len(fileURLKey) == 0 {
+		return "", errors.New("file URL signing key is not set")
+	}
+	mac := hmac.New(sha256.New, fileURLKey)
+	fmt.Fprintf(mac, "%s\n%s\n%d", fileName, variant, expires)
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// SignedFileURL returns a time-limited download URL for a stored file, or for one of its image
+// derivatives when variant is set. S3 backends return a presigned bucket URL for catalogued
+// files; everything else goes through /api/get-file.
+func SignedFileURL(db *sql.DB, fileName, variant string, ttl time.Duration) (string, time.Time, error) {
+	if ttl <= 0 {
+		ttl = defaultFileURLExpiry
+	}
+	if ttl > maxFileURLExpiry {
+		ttl = maxFileURLExpiry
+	}
+	expiresAt := time.Now().Add(ttl)
+
+	sf, err := resolveStoredFile(db, fileName)
+	if err != nil {
+		return "", expiresAt, err
+	}
+	if store := objectStore(); store != nil && sf.SHA256 != "" && sf.Backend == store.Name() {
+		key := sf.StorageKey
+		if variant != "" && isDerivableImage(sf) {
+			if derived, err := ensureImageDerivative(db, sf, variant); err == nil {
+				key = derived
+			}
+		}
+		presigned, err := store.PresignedURL(context.Background(), key, ttl)
+		if err != nil {
+			return "", expiresAt, err
+		}
+		if presigned != "" {
+			return presigned, expiresAt, nil
+		}
+	}
+
+	sig, err := fileURLSignature(fileName, variant, expiresAt.Unix())
+	if err != nil {
+		return "", expiresAt, err
+	}
+	q := url.Values{}
+	q.Set("file", fileName)
+	q.Set("expires", strconv.FormatInt(expiresAt.Unix(), 10))
+	q.Set("sig", sig)
+	if variant != "" {
+		q.Set("size", variant)
+	}
+	return "/api/get-file?" + q.Encode(), expiresAt, nil
+}
+
+// verifyFileURLSignature checks the expires/sig query parameters of a signed file URL for the
+// requested variant, so a thumbnail link can't be turned into one for the original
+func verifyFileURLSignature(fileName, variant, expiresParam, sig string) bool {
+	expires, err := strconv.ParseInt(expiresParam, 10, 64)
+	if err != nil || time.Now().Unix() > expires {
+		return false
+	}
+	expected, err := fileURLSignature(fileName, variant, expires)
+	if err != nil {
+		return false
+	}
+	return hmac.Equal([]byte(sig), []byte(expected))
+}
+
+// GetSignedFileURL godoc
+// @Summary      Get signed file URL
+// @Description  Returns a time-limited download URL for an uploaded file
+// @Tags         upload
+// @Produce      json
+// @Param        Authorization  header  string  true   "Session ID"
+// @Param        file           query   string  true   "File name returned by /api/upload"
+// @Param        expires_in     query   int     false  "Validity in seconds (default 900, max 604800)"
+// @Param        size           query   string  false  "original (default), thumb or web"
+// @Success      200  {object}  object
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      401  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Failure      500  {object}  models.ErrorResponse
+// @Router       /api/files/url [get]
+func GetSignedFileURL(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+			return
+		}
+		if _, _, err := GetSessionDetails(db, sessionID); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		fileName := c.Query("file")
+		if fileName == "" || filepath.Clean(fileName) != fileName || strings.Contains(fileName, "..") {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file parameter"})
+			return
+		}
+		variant := c.Query("size")
+		if variant == "original" {
+			variant = ""
+		}
+		if _, ok := services.ImageVariants[variant]; variant != "" && !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "size must be original, thumb or web"})
+			return
+		}
+		ttl := defaultFileURLExpiry
+		if s := c.Query("expires_in"); s != "" {
+			secs, err := strconv.Atoi(s)
+			if err != nil || secs <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in must be a positive number of seconds"})
+				return
+			}
+			ttl = time.Duration(secs) * time.Second
+		}
+
+		signed, expiresAt, err := SignedFileURL(db, fileName, variant, ttl)
+		if errors.Is(err, errFileNotServed) {
+			c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to sign file URL", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"file_name": fileName, "url": signed, "expires_at": expiresAt})
+	}
+}
+
+// ServeStoredFile streams a file after the caller has been authorised
+func ServeStoredFile(c *gin.Context, db *sql.DB, fileName string) {
+	sf, err := resolveStoredFile(db, fileName)
+	if errors.Is(err, errFileNotServed) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+		return
+	}
+	rc, info, err := openStoredFile(sf)
+	if errors.Is(err, services.ErrObjectNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error", "details": err.Error()})
+		return
+	}
+	defer rc.Close()
+
+	contentType := sf.ContentType
+	if contentType == "" {
+		contentType = info.ContentType
+	}
+	var body io.Reader = rc
+	if contentType == "" || contentType == "application/octet-stream" {
+		head := make([]byte, 512)
+		n, _ := io.ReadFull(rc, head)
+		contentType = http.DetectContentType(head[:n])
+		body = io.MultiReader(strings.NewReader(string(head[:n])), rc)
+	}
+
+	if sf.SHA256 != "" {
+		// Content-addressed objects never change
+		c.Header("ETag", `"`+sf.SHA256+`"`)
+		c.Header("Cache-Control", "private, max-age=86400, immutable")
+		if sf.OriginalName != "" {
+			c.Header("Content-Disposition", fmt.Sprintf("inline; filename=%q", sf.OriginalName))
+		}
+	}
+	size := info.Size
+	if size == 0 {
+		size = -1
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, body, nil)
+}
@@ -3,10 +3,12 @@ package handlers
 import (
 	"backend/models"
 	"backend/repository"
+	"backend/services"
 	"backend/storage"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -1056,15 +1058,13 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPath(ctx context.
 		return
 	}
 
-	// Check if file exists
-	if !FileExists(filePath) {
-		errorMsg := fmt.Sprintf("File not found at path: %s", filePath)
+	// Open the Excel file from the object store
+	f, err := openImportWorkbook(filePath)
+	if errors.Is(err, services.ErrObjectNotFound) {
+		errorMsg := fmt.Sprintf("File not found: %s", filePath)
 		gjm.UpdateJobStatus(jobID, "failed", 0, 0, &errorMsg, nil)
 		return
 	}
-
-	// Open Excel file from server path
-	f, err := excelize.OpenFile(filePath)
 	if err != nil {
 		errorMsg := fmt.Sprintf("Unable to open Excel file: %v", err)
 		gjm.UpdateJobStatus(jobID, "failed", 0, 0, &errorMsg, nil)
@@ -1134,7 +1134,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPath(ctx context.
 		elementTypes, parseErr = gjm.parseExcelDataToElementTypes(rows, projectID, userName)
 	} else {
 		// Use new parsing method with summary sheet ranges
-		elementTypes, parseErr = gjm.parseExcelDataToElementTypesWithRanges(rows, ranges, projectID, userName)
+		elementTypes, parseErr = gjm.parseExcelDataToElementTypesWithRanges(rows, ranges, projectID, userName, nil)
 	}
 
 	if parseErr != nil {
@@ -1501,8 +1501,14 @@ func parseColumnIndex(colStr string) (int, error) {
 // parseExcelDataToElementTypesWithRanges parses Excel rows into ElementType models using summary sheet ranges
 // Structure is FIXED: Row 1=Main Headers, Row 2=Sub Headers, Row 3=Sample Data, Row 4+=Data
 // Only ranges can change (e.g., L1-R1, M1-S1, etc.)
-func (gjm *GormJobManager) parseExcelDataToElementTypesWithRanges(rows [][]string, ranges *SummarySheetRanges, projectID int, userName string) ([]models.ElementType, error) {
+// A non-nil report is told about every cell the import would skip or misread, by 0-based row and column.
+func (gjm *GormJobManager) parseExcelDataToElementTypesWithRanges(rows [][]string, ranges *SummarySheetRanges, projectID int, userName string, report importIssueReporter) ([]models.ElementType, error) {
 	var elementTypes []models.ElementType
+	issue := func(row, col int, code, message string) {
+		if report != nil {
+			report(row, col, code, message)
+		}
+	}
 
 	if len(rows) < 4 {
 		return nil, fmt.Errorf("excel file must have at least 3 header rows (main header, sub header, sample data) and one data row")
@@ -1560,8 +1566,21 @@ func (gjm *GormJobManager) parseExcelDataToElementTypesWithRanges(rows [][]strin
 	for i := 3; i < len(rows); i++ {
 		row := rows[i]
 		if len(row) < baseColumnsEnd { // Minimum required columns
+			if strings.TrimSpace(strings.Join(row, "")) != "" {
+				issue(i, len(row), models.ImportIssueMissingValue, fmt.Sprintf("Row has %d of the %d base columns and is skipped", len(row), baseColumnsEnd))
+			}
 			continue
 		}
+		if strings.TrimSpace(row[0]) == "" {
+			issue(i, 0, models.ImportIssueMissingValue, "Element type is empty")
+		}
+		for j := 2; j <= 8 && j < len(row); j++ {
+			if value := strings.TrimSpace(row[j]); value != "" {
+				if _, err := strconv.ParseFloat(value, 64); err != nil {
+					issue(i, j, models.ImportIssueNonNumeric, fmt.Sprintf("Must be a number, %q is read as 0", value))
+				}
+			}
+		}
 
 		// Parse basic element type data (base columns) per template image
 		elementType := models.ElementType{
@@ -1622,6 +1641,8 @@ func (gjm *GormJobManager) parseExcelDataToElementTypesWithRanges(rows [][]strin
 						var stageID int
 						if err := gjm.db.Raw("SELECT id FROM project_stages WHERE name = ? AND project_id = ?", stageName, projectID).Scan(&stageID).Error; err == nil && stageID > 0 {
 							allStageIDs = append(allStageIDs, stageID)
+						} else {
+							issue(i, j, models.ImportIssueUnknownStage, fmt.Sprintf("Stage %q is not a stage of the project", stageName))
 						}
 					}
 				}
@@ -1656,6 +1677,8 @@ func (gjm *GormJobManager) parseExcelDataToElementTypesWithRanges(rows [][]strin
 							DrawingTypeName: drawingTypeName,
 							ElementTypeID:   0, // To be set later
 						})
+					} else {
+						issue(i, j, models.ImportIssueMissingDrawingType, fmt.Sprintf("Drawing type %q is not a drawing type of the project", drawingTypeName))
 					}
 				}
 			}
@@ -1684,6 +1707,9 @@ func (gjm *GormJobManager) parseExcelDataToElementTypesWithRanges(rows [][]strin
 
 			for j := hierarchyStart; j <= hierarchyEnd && j < len(row); j++ {
 				quantity, err := strconv.Atoi(row[j])
+				if err != nil && strings.TrimSpace(row[j]) != "" {
+					issue(i, j, models.ImportIssueNonNumeric, fmt.Sprintf("Quantity must be a whole number, %q is skipped", row[j]))
+				}
 				if err != nil || quantity == 0 {
 					continue // Skip invalid or zero values
 				}
@@ -1744,6 +1770,7 @@ func (gjm *GormJobManager) parseExcelDataToElementTypesWithRanges(rows [][]strin
 
 				if !found {
 					log.Printf("Warning: Could not find hierarchy ID for naming convention '%s' in precast table", hierarchyName)
+					issue(i, j, models.ImportIssueUnknownHierarchy, fmt.Sprintf("Hierarchy %q is not in the project", hierarchyName))
 					continue // Skip this hierarchy entry if not found in precast table
 				}
 
@@ -1777,6 +1804,9 @@ func (gjm *GormJobManager) parseExcelDataToElementTypesWithRanges(rows [][]strin
 			log.Printf("Processing BOM from columns %d to %d (count: %d)", bomStart, bomEnd, ranges.BOMTypes.Count)
 			for j := bomStart; j <= bomEnd && j < len(row); j++ {
 				quantity, err := strconv.ParseFloat(row[j], 64)
+				if err != nil && strings.TrimSpace(row[j]) != "" {
+					issue(i, j, models.ImportIssueNonNumeric, fmt.Sprintf("BOM quantity must be a number, %q is skipped", row[j]))
+				}
 				if err != nil || quantity == 0 {
 					continue
 				}
@@ -1818,6 +1848,8 @@ func (gjm *GormJobManager) parseExcelDataToElementTypesWithRanges(rows [][]strin
 						ProductName: productName,
 						Quantity:    quantity,
 					})
+				} else {
+					issue(i, j, models.ImportIssueUnknownProduct, fmt.Sprintf("BOM product %q is not in the project's product master", productName))
 				}
 			}
 		}
@@ -2425,9 +2457,18 @@ func (gjm *GormJobManager) createElementsWithGorm(tx *gorm.DB, elementData model
 		return fmt.Errorf("failed to get current total count: %v", err)
 	}
 
+	sqlTx, err := gormSQLTx(tx)
+	if err != nil {
+		return fmt.Errorf("failed to generate element IDs: %v", err)
+	}
+	elementIDs, err := GenerateElementIDs(sqlTx, elementData.ElementTypeID, elementData.HierarchyId, elementData.NamingConvention, elementData.Quantity)
+	if err != nil {
+		return fmt.Errorf("failed to generate element IDs: %v", err)
+	}
+
 	// Create elements
 	for i := 1; i <= elementData.Quantity; i++ {
-		elementID := repository.GenerateElementID(elementData.ElementType, elementData.NamingConvention, currentTotalCount+i)
+		elementID := elementIDs[i-1]
 
 		element := models.ElementGorm{
 			ElementTypeID:      elementData.ElementTypeID,
@@ -2456,7 +2497,8 @@ func (gjm *GormJobManager) createElementsWithGorm(tx *gorm.DB, elementData model
 	return nil
 }
 
-// ImportElementTypeExcelHandlerGorm handles Excel import using GORM
+// ImportElementTypeExcelHandlerGorm handles Excel import using GORM. With dry_run=true it only validates
+// the sheet and returns the report, or with format=xlsx the annotated workbook.
 func (gjm *GormJobManager) ImportElementTypeExcelHandlerGorm(c *gin.Context) {
 	// Check if job creation is blocked (inspired by reference code)
 	if gjm.IsJobCreationBlocked() {
@@ -2504,16 +2546,27 @@ func (gjm *GormJobManager) ImportElementTypeExcelHandlerGorm(c *gin.Context) {
 		return
 	}
 
-	// Upload file to server
-	importDir := "./imports/"
-	filePath, err := UploadFileToDirectory(file, importDir, 10<<20) // 10 MB limit
+	// Reject anything that is not a real spreadsheet before it reaches the importer
+	if rejection := ValidateUploadFile(sqlDB, file, models.UploadPurposeImport, userName); rejection != nil {
+		c.JSON(rejection.Status, rejection.JSON())
+		return
+	}
+
+	// A dry run only validates the sheet; nothing is stored or imported
+	if c.Query("dry_run") == "true" {
+		gjm.respondImportValidation(c, sqlDB, file, projectID, userName)
+		return
+	}
+
+	// Store the workbook where any instance picking up the job can read it
+	storageKey, err := UploadFileToDirectory(file, "imports", 10<<20) // 10 MB limit
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to upload file to server"})
 		return
 	}
 
 	// Create a new import job and get job ID with file path
-	jobID, err := gjm.CreateImportJobAndGetID(c, &filePath)
+	jobID, err := gjm.CreateImportJobAndGetID(c, &storageKey)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create job"})
 		return
@@ -2540,6 +2593,21 @@ func (gjm *GormJobManager) ImportElementTypeExcelHandlerGorm(c *gin.Context) {
 		concurrentBatches = 15 // Default to 15 if invalid
 	}
 
+	// Queue the import; any server picks it up and a restart resumes it
+	if err := enqueueImportJob(sqlDB, ElementTypeExcelImportJob, importJobPayload{
+		ImportJobID:       jobID,
+		ProjectID:         projectID,
+		StorageKey:        storageKey,
+		BatchSize:         batchSize,
+		ConcurrentBatches: concurrentBatches,
+		UserName:          userName,
+		HostName:          session.HostName,
+		IPAddress:         session.IPAddress,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue job", "details": err.Error()})
+		return
+	}
+
 	// Return job ID immediately to prevent timeout
 	c.JSON(http.StatusOK, gin.H{
 		"message":            "Import job started successfully",
@@ -2547,7 +2615,7 @@ func (gjm *GormJobManager) ImportElementTypeExcelHandlerGorm(c *gin.Context) {
 		"status":             "pending",
 		"batch_size":         batchSize,
 		"concurrent_batches": concurrentBatches,
-		"file_path":          filePath,
+		"file_path":          storageKey,
 		"handler":            "gorm",
 	})
 
@@ -2568,53 +2636,6 @@ func (gjm *GormJobManager) ImportElementTypeExcelHandlerGorm(c *gin.Context) {
 			log.Printf("Failed to log activity: %v", logErr)
 		}
 	}()
-
-	// Create a unified job management approach
-	// First, register the job BEFORE starting any processing
-	ctx, cancel := context.WithCancel(context.Background())
-
-	// Register job immediately with proper tracking
-	gjm.registerJob(jobID, cancel)
-	log.Printf("Job %d registered for unified management", jobID)
-
-	// Verify registration immediately
-	if gjm.IsJobRunning(jobID) {
-		log.Printf("Job %d successfully registered and is running", jobID)
-	} else {
-		log.Printf("ERROR: Job %d registration failed - not found in running jobs", jobID)
-	}
-
-	// Start background processing with unified management
-	go func() {
-		defer func() {
-			gjm.unregisterJob(jobID)
-			log.Printf("Job %d completed and unregistered", jobID)
-		}()
-
-		// Check if job was terminated before starting
-		if gjm.IsJobTerminated(jobID) {
-			log.Printf("Job %d was terminated, not starting processing", jobID)
-			return
-		}
-
-		// Check if job creation is blocked
-		if gjm.IsJobCreationBlocked() {
-			log.Printf("Job creation blocked - not starting processing for job %d", jobID)
-			return
-		}
-
-		// Check if context is already cancelled
-		select {
-		case <-ctx.Done():
-			log.Printf("Job %d context already cancelled, not starting processing", jobID)
-			return
-		default:
-			// Continue processing
-		}
-
-		// Use enhanced cancellation method for better goroutine cleanup
-		gjm.ProcessElementTypeExcelImportJobFromPathWithEnhancedCancellation(ctx, jobID, projectID, filePath, batchSize, concurrentBatches, userName, session)
-	}()
 }
 
 // TerminateJobAndRollback terminates a job and rolls back its data using GORM
@@ -2745,6 +2766,11 @@ func (gjm *GormJobManager) TerminateJobAndRollback(c *gin.Context) {
 		log.Printf("Job %d status immediately updated to cancelled", jobID)
 	}
 
+	// The import may be queued or running on another server; the queue stops it there
+	if err := CancelQueuedJobs(storage.GetDB(), jobID); err != nil {
+		log.Printf("Failed to cancel queued job for import %d: %v", jobID, err)
+	}
+
 	// Send immediate response to prevent timeout
 	c.JSON(http.StatusOK, gin.H{
 		"message":      "Job termination initiated",
@@ -3526,13 +3552,164 @@ func RollbackAllElementTypeDataWithGorm(db *sql.DB) gin.HandlerFunc {
 	}
 }
 
+// Kinds of queued import jobs
+const (
+	ElementTypeExcelImportJob = "element_type_excel_import" // element types from an uploaded Excel file
+	ElementTypeImportJob      = "element_type_import"       // element types parsed from a CSV upload
+)
+
+// importJobPayload is what a queued import needs to run on any server
+type importJobPayload struct {
+	ImportJobID       int                  `json:"import_job_id"`
+	ProjectID         int                  `json:"project_id"`
+	StorageKey        string               `json:"storage_key,omitempty"` // object store key of the uploaded file
+	ElementTypes      []models.ElementType `json:"element_types,omitempty"`
+	BatchSize         int                  `json:"batch_size"`
+	ConcurrentBatches int                  `json:"concurrent_batches"`
+	UserName          string               `json:"user_name"`
+	HostName          string               `json:"host_name"`
+	IPAddress         string               `json:"ip_address"`
+}
+
+// importCheckpoint is how far a queued import got, saved with every batch
+type importCheckpoint struct {
+	NextIndex  int      `json:"next_index"`
+	Processed  int      `json:"processed"`
+	Success    int      `json:"success"`
+	ErrorCount int      `json:"error_count"`
+	Errors     []string `json:"errors"`
+}
+
+// enqueueImportJob queues an import job. When it can't, the import job is failed so it doesn't stay
+// pending.
+func enqueueImportJob(db *sql.DB, kind string, payload importJobPayload) error {
+	importJobID := payload.ImportJobID
+	_, err := EnqueueJob(db, JobSpec{
+		Kind:        kind,
+		ProjectID:   payload.ProjectID,
+		Payload:     payload,
+		ImportJobID: &importJobID,
+		CreatedBy:   payload.UserName,
+	})
+	if err != nil {
+		failImportJob(db, importJobID, fmt.Sprintf("Failed to queue the import: %v", err))
+	}
+	return err
+}
+
+// resumeImport returns where an import continues: its checkpoint when it is a resumed queued job, the
+// start otherwise.
+func resumeImport(run *JobRun) importCheckpoint {
+	var checkpoint importCheckpoint
+	if run == nil {
+		return checkpoint
+	}
+	resumed, err := run.LoadCheckpoint(&checkpoint)
+	if err != nil {
+		log.Printf("Job queue: ignoring unreadable checkpoint of job %d: %v", run.ID, err)
+		return importCheckpoint{}
+	}
+	if resumed {
+		log.Printf("Job queue: job %d resumes at item %d", run.ID, checkpoint.NextIndex)
+	}
+	return checkpoint
+}
+
+// importBatchCheckpoint saves, with the batch ending at end, the progress of a queued import. It is nil
+// when the import isn't queued.
+func importBatchCheckpoint(run *JobRun, done importCheckpoint, end int) func(tx *gorm.DB, batchErrors []string) error {
+	if run == nil {
+		return nil
+	}
+	return func(tx *gorm.DB, batchErrors []string) error {
+		sqlTx, err := gormSQLTx(tx)
+		if err != nil {
+			return err
+		}
+		return run.SaveCheckpoint(sqlTx, nextImportCheckpoint(done, end, batchErrors))
+	}
+}
+
+// nextImportCheckpoint adds the batch from done.NextIndex to end, one error per failed element, to
+// the progress in done.
+func nextImportCheckpoint(done importCheckpoint, end int, batchErrors []string) importCheckpoint {
+	return importCheckpoint{
+		NextIndex:  end,
+		Processed:  done.Processed + end - done.NextIndex,
+		Success:    done.Success + end - done.NextIndex - len(batchErrors),
+		ErrorCount: done.ErrorCount + len(batchErrors),
+		Errors:     append(append([]string{}, done.Errors...), batchErrors...),
+	}
+}
+
+// RegisterJobHandlers lets the job queue run the imports of this job manager.
+func (gjm *GormJobManager) RegisterJobHandlers(jq *JobQueue) {
+	jq.Register(ElementTypeExcelImportJob, gjm.importJobHandler(func(ctx context.Context, run *JobRun, p importJobPayload) error {
+		session := models.Session{HostName: p.HostName, IPAddress: p.IPAddress}
+		return gjm.ProcessElementTypeExcelImportJobFromPathWithEnhancedCancellation(ctx, p.ImportJobID, p.ProjectID, p.StorageKey, p.BatchSize, p.ConcurrentBatches, p.UserName, session, run)
+	}))
+	jq.Register(ElementTypeImportJob, gjm.importJobHandler(func(ctx context.Context, run *JobRun, p importJobPayload) error {
+		return gjm.ProcessElementTypeImportJobWithEnhancedCancellation(ctx, p.ImportJobID, p.ProjectID, p.ElementTypes, p.BatchSize, p.ConcurrentBatches, run)
+	}))
+}
+
+// importJobHandler runs an import as a queued job. An error from the import goes to the queue as it is;
+// otherwise how the import job ended tells the queue whether the job is done, stopped or worth another
+// attempt.
+func (gjm *GormJobManager) importJobHandler(process func(ctx context.Context, run *JobRun, p importJobPayload) error) JobHandler {
+	return func(ctx context.Context, run *JobRun) error {
+		var p importJobPayload
+		if err := json.Unmarshal(run.Payload, &p); err != nil {
+			return PermanentJobError(fmt.Errorf("invalid import job payload: %v", err))
+		}
+
+		// Registered like any running import, so termination and shutdown reach it
+		jobCtx, cancel := context.WithCancel(ctx)
+		defer cancel()
+		gjm.jobWG.Add(1)
+		defer gjm.jobWG.Done()
+		gjm.registerJob(p.ImportJobID, cancel)
+		defer gjm.unregisterJob(p.ImportJobID)
+
+		if err := process(jobCtx, run, p); err != nil {
+			return err
+		}
+
+		var status string
+		var errorMsg sql.NullString
+		if err := gjm.db.Model(&models.ImportJobGorm{}).Select("status, error").Where("id = ?", p.ImportJobID).Row().Scan(&status, &errorMsg); err != nil {
+			return fmt.Errorf("failed to read import job %d: %v", p.ImportJobID, err)
+		}
+		switch {
+		case status == "completed" || status == "completed_with_errors":
+			return nil
+		case status == "failed":
+			return fmt.Errorf("import job %d failed: %s", p.ImportJobID, errorMsg.String)
+		case gjm.IsShuttingDown() || ctx.Err() != nil:
+			return ErrJobInterrupted
+		case status == "cancelled" || status == "terminated":
+			return ErrJobCancelled
+		}
+		return fmt.Errorf("import job %d stopped while %s", p.ImportJobID, status)
+	}
+}
+
+// failImportFile fails an import job whose file can't be imported, which no further attempt changes.
+func (gjm *GormJobManager) failImportFile(jobID int, message string) error {
+	gjm.UpdateJobStatus(jobID, "failed", 0, 0, &message, nil)
+	return PermanentJobError(errors.New(message))
+}
+
 // ProcessElementTypeImportJobWithEnhancedCancellation processes the element type import with enhanced cancellation support
-func (gjm *GormJobManager) ProcessElementTypeImportJobWithEnhancedCancellation(ctx context.Context, jobID int, projectID int, elementTypes []models.ElementType, batchSize int, concurrentBatches int) {
+// run is the queued job the import runs in, nil when it isn't queued; a queued import resumes from its
+// checkpoint. The error tells the queue why the import stopped short; a PermanentJobError when another
+// attempt would end the same way.
+func (gjm *GormJobManager) ProcessElementTypeImportJobWithEnhancedCancellation(ctx context.Context, jobID int, projectID int, elementTypes []models.ElementType, batchSize int, concurrentBatches int, run *JobRun) error {
 	// Update job status to processing
 	err := gjm.UpdateJobStatus(jobID, "processing", 0, 0, nil, nil)
 	if err != nil {
 		log.Printf("Error updating job status to processing: %v", err)
-		return
+		return fmt.Errorf("failed to start import job %d: %v", jobID, err)
 	}
 
 	totalElements := len(elementTypes)
@@ -3541,14 +3718,15 @@ func (gjm *GormJobManager) ProcessElementTypeImportJobWithEnhancedCancellation(c
 	err = gjm.UpdateJobTotalItems(jobID, totalElements)
 	if err != nil {
 		log.Printf("Error updating job total items: %v", err)
-		return
+		return fmt.Errorf("failed to start import job %d: %v", jobID, err)
 	}
 
 	// Process elements in batches with enhanced cancellation
-	processedItems := 0
-	successCount := 0
-	errorCount := 0
-	var errors []string
+	resume := resumeImport(run)
+	processedItems := resume.Processed
+	successCount := resume.Success
+	errorCount := resume.ErrorCount
+	errors := resume.Errors
 
 	// Create a ticker for periodic progress updates
 	progressTicker := time.NewTicker(5 * time.Second)
@@ -3576,14 +3754,14 @@ func (gjm *GormJobManager) ProcessElementTypeImportJobWithEnhancedCancellation(c
 		}
 	}()
 
-	for i := 0; i < totalElements; i += batchSize {
+	for i := resume.NextIndex; i < totalElements; i += batchSize {
 		// Enhanced cancellation check before each batch
 		select {
 		case <-ctx.Done():
 			log.Printf("Job %d cancelled during processing", jobID)
 			errorMsg := "Job cancelled by user"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		default:
 			// Continue processing
 		}
@@ -3596,25 +3774,30 @@ func (gjm *GormJobManager) ProcessElementTypeImportJobWithEnhancedCancellation(c
 		batch := elementTypes[i:end]
 
 		// Process batch with enhanced cancellation
-		batchErrors := gjm.processBatchWithEnhancedCancellation(ctx, batch, jobID)
+		batchErrors, batchErr := gjm.processBatchWithEnhancedCancellation(ctx, batch, jobID, importBatchCheckpoint(run, importCheckpoint{
+			NextIndex: i, Processed: processedItems, Success: successCount, ErrorCount: errorCount, Errors: errors,
+		}, end))
+
+		// The checkpoint failed, the queue decides what happens to the job
+		if batchErr != nil && batchErr != errBatchCancelled {
+			log.Printf("Job %d interrupted at item %d: %v", jobID, i, batchErr)
+			return fmt.Errorf("import job %d could not save its progress at item %d: %v", jobID, i, batchErr)
+		}
 
 		// Check if batch was cancelled
-		if len(batchErrors) > 0 && batchErrors[0] == "cancelled" {
+		if batchErr == errBatchCancelled {
 			log.Printf("Job %d cancelled during batch processing", jobID)
 			errorMsg := "Job cancelled by user"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
 			// Reset global flags when job is cancelled
 			gjm.ResetGlobalTerminationFlag()
 			gjm.UnblockJobCreation()
-			return
+			return nil
 		}
 
-		if len(batchErrors) > 0 {
-			errors = append(errors, batchErrors...)
-			errorCount += len(batchErrors)
-		} else {
-			successCount += len(batch)
-		}
+		errors = append(errors, batchErrors...)
+		errorCount += len(batchErrors)
+		successCount += len(batch) - len(batchErrors)
 
 		processedItems += len(batch)
 		progress := (processedItems * 100) / totalElements
@@ -3638,7 +3821,7 @@ func (gjm *GormJobManager) ProcessElementTypeImportJobWithEnhancedCancellation(c
 			// Reset global flags when job is cancelled
 			gjm.ResetGlobalTerminationFlag()
 			gjm.UnblockJobCreation()
-			return
+			return nil
 		default:
 			// Continue processing
 		}
@@ -3651,7 +3834,7 @@ func (gjm *GormJobManager) ProcessElementTypeImportJobWithEnhancedCancellation(c
 			// Reset global flags when job is cancelled
 			gjm.ResetGlobalTerminationFlag()
 			gjm.UnblockJobCreation()
-			return
+			return nil
 		}
 
 		// Check if job was terminated after batch
@@ -3662,7 +3845,7 @@ func (gjm *GormJobManager) ProcessElementTypeImportJobWithEnhancedCancellation(c
 			// Reset global flags when job is cancelled
 			gjm.ResetGlobalTerminationFlag()
 			gjm.UnblockJobCreation()
-			return
+			return nil
 		}
 	}
 
@@ -3687,17 +3870,28 @@ func (gjm *GormJobManager) ProcessElementTypeImportJobWithEnhancedCancellation(c
 
 	// Update final job status
 	gjm.UpdateJobStatus(jobID, finalStatus, 100, processedItems, errorMsg, &resultMsg)
+	if finalStatus == "failed" {
+		// Every element failed and its error is in the checkpoint, so another attempt would skip them all
+		return PermanentJobError(fmt.Errorf("%s", *errorMsg))
+	}
+	return nil
 }
 
-// processBatchWithEnhancedCancellation processes a batch with enhanced cancellation support
-func (gjm *GormJobManager) processBatchWithEnhancedCancellation(ctx context.Context, batch []models.ElementType, jobID int) []string {
+// errBatchCancelled is returned when the job was stopped during a batch, which is then rolled back.
+var errBatchCancelled = errors.New("batch cancelled")
+
+// processBatchWithEnhancedCancellation processes a batch with enhanced cancellation support and returns
+// the errors of the elements that failed. When the job is stopped the batch is rolled back and
+// errBatchCancelled is returned. A non-nil checkpoint runs in the batch transaction before it commits;
+// if it fails the batch is rolled back and its error returned.
+func (gjm *GormJobManager) processBatchWithEnhancedCancellation(ctx context.Context, batch []models.ElementType, jobID int, checkpoint func(tx *gorm.DB, batchErrors []string) error) ([]string, error) {
 	var errList []string
 
 	// Check for cancellation before starting batch
 	select {
 	case <-ctx.Done():
 		log.Printf("Job %d cancelled before batch processing", jobID)
-		return []string{"cancelled"}
+		return nil, errBatchCancelled
 	default:
 		// Continue processing
 	}
@@ -3705,19 +3899,19 @@ func (gjm *GormJobManager) processBatchWithEnhancedCancellation(ctx context.Cont
 	// Check if job was terminated
 	if gjm.IsJobTerminated(jobID) {
 		log.Printf("Job %d terminated before batch processing", jobID)
-		return []string{"cancelled"}
+		return nil, errBatchCancelled
 	}
 
 	// Check if global termination is set
 	if gjm.IsGlobalTerminationSet() {
 		log.Printf("Job %d global termination before batch processing", jobID)
-		return []string{"cancelled"}
+		return nil, errBatchCancelled
 	}
 
 	// Start a transaction
 	tx := gjm.db.Begin()
 	if tx.Error != nil {
-		return []string{fmt.Sprintf("Failed to start transaction: %v", tx.Error)}
+		return []string{fmt.Sprintf("Failed to start transaction: %v", tx.Error)}, nil
 	}
 
 	defer func() {
@@ -3733,7 +3927,7 @@ func (gjm *GormJobManager) processBatchWithEnhancedCancellation(ctx context.Cont
 		case <-ctx.Done():
 			log.Printf("Job %d cancelled during batch processing", jobID)
 			tx.Rollback()
-			return append(errList, "cancelled")
+			return errList, errBatchCancelled
 		default:
 			// Continue processing
 		}
@@ -3742,29 +3936,29 @@ func (gjm *GormJobManager) processBatchWithEnhancedCancellation(ctx context.Cont
 		if gjm.IsJobTerminated(jobID) {
 			log.Printf("Job %d terminated during batch processing", jobID)
 			tx.Rollback()
-			return append(errList, "cancelled")
+			return errList, errBatchCancelled
 		}
 
 		// Check if global termination is set
 		if gjm.IsGlobalTerminationSet() {
 			log.Printf("Job %d global termination during batch processing", jobID)
 			tx.Rollback()
-			return append(errList, "cancelled")
+			return errList, errBatchCancelled
 		}
 
 		// Check if job is still running (verification)
 		if !gjm.IsJobRunning(jobID) {
 			log.Printf("Job %d is not running during batch processing", jobID)
 			tx.Rollback()
-			return append(errList, "cancelled")
+			return errList, errBatchCancelled
 		}
 
 		// Process individual element with cancellation check
 		if err := gjm.processElementWithCancellation(ctx, tx, elementType, jobID); err != nil {
-			if err.Error() == "cancelled" {
+			if err == errBatchCancelled {
 				log.Printf("Job %d cancelled during element processing", jobID)
 				tx.Rollback()
-				return append(errList, "cancelled")
+				return errList, errBatchCancelled
 			}
 			errList = append(errList, fmt.Sprintf("Element %d: %v", i, err))
 		}
@@ -3774,7 +3968,7 @@ func (gjm *GormJobManager) processBatchWithEnhancedCancellation(ctx context.Cont
 		case <-ctx.Done():
 			log.Printf("Job %d cancelled after element processing", jobID)
 			tx.Rollback()
-			return append(errList, "cancelled")
+			return errList, errBatchCancelled
 		default:
 			// Continue processing
 		}
@@ -3785,17 +3979,26 @@ func (gjm *GormJobManager) processBatchWithEnhancedCancellation(ctx context.Cont
 	case <-ctx.Done():
 		log.Printf("Job %d cancelled before commit", jobID)
 		tx.Rollback()
-		return append(errList, "cancelled")
+		return errList, errBatchCancelled
 	default:
 		// Continue processing
 	}
 
+	// Record the progress of a queued job together with the batch
+	if checkpoint != nil {
+		if err := checkpoint(tx, errList); err != nil {
+			log.Printf("Job %d failed to save its checkpoint: %v", jobID, err)
+			tx.Rollback()
+			return nil, fmt.Errorf("failed to save checkpoint: %w", err)
+		}
+	}
+
 	// Commit transaction if no cancellation occurred
 	if err := tx.Commit().Error; err != nil {
-		return append(errList, fmt.Sprintf("Failed to commit transaction: %v", err))
+		return append(errList, fmt.Sprintf("Failed to commit transaction: %v", err)), nil
 	}
 
-	return errList
+	return errList, nil
 }
 
 // processElementWithCancellation processes a single element with cancellation support
@@ -3803,17 +4006,17 @@ func (gjm *GormJobManager) processElementWithCancellation(ctx context.Context, t
 	// Check for cancellation
 	select {
 	case <-ctx.Done():
-		return fmt.Errorf("cancelled")
+		return errBatchCancelled
 	default:
 		// Continue processing
 	}
 	// Check if job was terminated
 	if gjm.IsJobTerminated(jobID) {
-		return fmt.Errorf("cancelled")
+		return errBatchCancelled
 	}
 	// Check if global termination is set
 	if gjm.IsGlobalTerminationSet() {
-		return fmt.Errorf("cancelled")
+		return errBatchCancelled
 	}
 
 	// Convert to GORM model
@@ -3840,7 +4043,7 @@ func (gjm *GormJobManager) processElementWithCancellation(ctx context.Context, t
 	// Check for cancellation before database operations
 	select {
 	case <-ctx.Done():
-		return fmt.Errorf("cancelled")
+		return errBatchCancelled
 	default:
 		// Continue processing
 	}
@@ -3848,12 +4051,12 @@ func (gjm *GormJobManager) processElementWithCancellation(ctx context.Context, t
 	// 🚨 IMMEDIATE TERMINATION CHECKS - Before element creation
 	if gjm.IsJobTerminated(jobID) {
 		log.Printf("🚨 IMMEDIATE TERMINATION: Job %d terminated - stopping element creation", jobID)
-		return fmt.Errorf("cancelled")
+		return errBatchCancelled
 	}
 
 	if gjm.IsGlobalTerminationSet() {
 		log.Printf("🚨 IMMEDIATE TERMINATION: Global termination set - stopping element creation for job %d", jobID)
-		return fmt.Errorf("cancelled")
+		return errBatchCancelled
 	}
 
 	// Check database status for immediate termination
@@ -3861,7 +4064,7 @@ func (gjm *GormJobManager) processElementWithCancellation(ctx context.Context, t
 	if err := gjm.db.Model(&models.ImportJobGorm{}).Where("id = ?", jobID).Select("status").Scan(&dbJobStatus).Error; err == nil {
 		if dbJobStatus == "cancelled" || dbJobStatus == "terminated" {
 			log.Printf("🚨 IMMEDIATE TERMINATION: Job %d status is %s in database, stopping element creation", jobID, dbJobStatus)
-			return fmt.Errorf("cancelled")
+			return errBatchCancelled
 		}
 	}
 
@@ -4217,12 +4420,19 @@ func (gjm *GormJobManager) processElementRelatedData(ctx context.Context, tx *go
 				// Continue processing
 			}
 
-			// Get naming convention from precast table
-			var namingConvention string
-			err := tx.Raw("SELECT naming_convention FROM precast WHERE id = ?", hq.HierarchyId).Scan(&namingConvention).Error
+			// Number the elements of this hierarchy from the project's element ID template. The
+			// sequence row stays locked until this transaction ends, so concurrent batches can't
+			// hand out the same ID.
+			sqlTx, err := gormSQLTx(tx)
 			if err != nil {
-				log.Printf("Error fetching naming convention for hierarchy_id %d: %v", hq.HierarchyId, err)
+				return fmt.Errorf("failed to generate element IDs: %v", err)
+			}
+			elementIDs, err := GenerateElementIDs(sqlTx, int(elementTypeID), hq.HierarchyId, "", hq.Quantity)
+			if errors.Is(err, sql.ErrNoRows) {
+				log.Printf("Error generating element IDs for hierarchy_id %d: %v", hq.HierarchyId, err)
 				continue
+			} else if err != nil {
+				return fmt.Errorf("failed to generate element IDs: %v", err)
 			}
 
 			// Create elements for this hierarchy
@@ -4235,8 +4445,7 @@ func (gjm *GormJobManager) processElementRelatedData(ctx context.Context, tx *go
 					// Continue processing
 				}
 
-				// Generate element ID using the naming convention
-				elementID := repository.GenerateElementID(elementType.ElementType, namingConvention, i)
+				elementID := elementIDs[i-1]
 
 				element := models.ElementGorm{
 					ElementTypeID:      elementTypeID,
@@ -4307,7 +4516,7 @@ func (gjm *GormJobManager) processElementRelatedData(ctx context.Context, tx *go
 // ProcessJobWithHybridApproach combines enhanced cancellation with fallback
 func (gjm *GormJobManager) ProcessJobWithHybridApproach(ctx context.Context, jobID int, projectID int, elementTypes []models.ElementType, batchSize int, concurrentBatches int) {
 	// Use enhanced cancellation for better reliability
-	gjm.ProcessElementTypeImportJobWithEnhancedCancellation(ctx, jobID, projectID, elementTypes, batchSize, concurrentBatches)
+	gjm.ProcessElementTypeImportJobWithEnhancedCancellation(ctx, jobID, projectID, elementTypes, batchSize, concurrentBatches, nil)
 }
 
 // CancelJobWithFallback provides multiple cancellation strategies
@@ -4371,7 +4580,7 @@ func (gjm *GormJobManager) ProcessJobWithConfig(ctx context.Context, jobID int,
 
 	if config.UseEnhancedCancellation {
 		// Use enhanced cancellation for better reliability
-		gjm.ProcessElementTypeImportJobWithEnhancedCancellation(ctx, jobID, projectID, elementTypes, config.BatchSize, config.ConcurrentBatches)
+		gjm.ProcessElementTypeImportJobWithEnhancedCancellation(ctx, jobID, projectID, elementTypes, config.BatchSize, config.ConcurrentBatches, nil)
 	} else {
 		// Use basic processing for simple jobs
 		gjm.ProcessElementTypeImportJob(ctx, jobID, projectID, elementTypes, config.BatchSize, config.ConcurrentBatches)
@@ -4379,25 +4588,28 @@ func (gjm *GormJobManager) ProcessJobWithConfig(ctx context.Context, jobID int,
 }
 
 // ProcessElementTypeExcelImportJobFromPathWithEnhancedCancellation processes Excel import with enhanced cancellation support
-func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedCancellation(ctx context.Context, jobID int, projectID int, filePath string, batchSize int, concurrentBatches int, userName string, session models.Session) {
+// run is the queued job the import runs in, nil when it isn't queued; a queued import resumes from its
+// checkpoint. The error tells the queue why the import stopped short; a PermanentJobError when the file
+// can't be imported.
+func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedCancellation(ctx context.Context, jobID int, projectID int, storageKey string, batchSize int, concurrentBatches int, userName string, session models.Session, run *JobRun) error {
 	log.Printf("🚨 JOB START: Processing job %d with enhanced cancellation", jobID)
 
 	// Check if job was terminated before starting
 	if gjm.IsJobTerminated(jobID) {
 		log.Printf("🚨 PREEMPTIVE TERMINATION: Job %d was terminated, stopping processing", jobID)
-		return
+		return nil
 	}
 
 	// Check if job creation is blocked
 	if gjm.IsJobCreationBlocked() {
 		log.Printf("Job creation blocked - stopping processing for job %d", jobID)
-		return
+		return nil
 	}
 
 	// Check if global termination is set
 	if gjm.IsGlobalTerminationSet() {
 		log.Printf("Global termination set - stopping processing for job %d", jobID)
-		return
+		return nil
 	}
 
 	// Check if job status is already cancelled in database
@@ -4405,7 +4617,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 	if err := gjm.db.Model(&models.ImportJobGorm{}).Select("status").Where("id = ?", jobID).Scan(&jobStatus).Error; err == nil {
 		if jobStatus == "cancelled" || jobStatus == "terminated" {
 			log.Printf("Job %d status is %s, stopping processing", jobID, jobStatus)
-			return
+			return nil
 		}
 	}
 
@@ -4413,7 +4625,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 	select {
 	case <-ctx.Done():
 		log.Printf("Job %d context already cancelled, stopping processing", jobID)
-		return
+		return nil
 	default:
 		// Continue processing
 	}
@@ -4421,37 +4633,37 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 	// Check if job was terminated (double-check)
 	if gjm.IsJobTerminated(jobID) {
 		log.Printf("Job %d was terminated, stopping processing", jobID)
-		return
+		return nil
 	}
 
 	// Check if global termination is set (double-check)
 	if gjm.IsGlobalTerminationSet() {
 		log.Printf("Global termination set - stopping processing for job %d", jobID)
-		return
+		return nil
 	}
 
 	// Check if job is still running (verification)
 	if !gjm.IsJobRunning(jobID) {
 		log.Printf("Job %d is not running, stopping processing", jobID)
-		return
+		return nil
 	}
 
 	// Check if job was terminated (immediate check)
 	if gjm.IsJobTerminated(jobID) {
 		log.Printf("Job %d was terminated, stopping processing", jobID)
-		return
+		return nil
 	}
 
 	// Check if global termination is set (immediate check)
 	if gjm.IsGlobalTerminationSet() {
 		log.Printf("Global termination set - stopping processing for job %d", jobID)
-		return
+		return nil
 	}
 
 	// Check if context has error
 	if ctx.Err() != nil {
 		log.Printf("Job %d context has error: %v, stopping processing", jobID, ctx.Err())
-		return
+		return nil
 	}
 
 	// Start timing the entire process
@@ -4471,7 +4683,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 	err := gjm.UpdateJobStatus(jobID, "processing", 0, 0, nil, nil)
 	if err != nil {
 		log.Printf("Error updating job status to processing: %v", err)
-		return
+		return fmt.Errorf("failed to start import job %d: %v", jobID, err)
 	}
 
 	// Check for cancellation before starting
@@ -4480,7 +4692,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 		log.Printf("Job %d cancelled before starting", jobID)
 		errorMsg := "Job cancelled by user"
 		gjm.UpdateJobStatus(jobID, "terminated", 0, 0, &errorMsg, nil)
-		return
+		return nil
 	default:
 		// Continue processing
 	}
@@ -4496,19 +4708,17 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 	}()
 	defer close(cancellationMonitor)
 
-	// Check if file exists
-	if !FileExists(filePath) {
-		errorMsg := fmt.Sprintf("File not found at path: %s", filePath)
-		gjm.UpdateJobStatus(jobID, "failed", 0, 0, &errorMsg, nil)
-		return
+	// Open the Excel file from the object store
+	f, err := openImportWorkbook(storageKey)
+	if errors.Is(err, services.ErrObjectNotFound) {
+		return gjm.failImportFile(jobID, fmt.Sprintf("File not found: %s", storageKey))
+	}
+	if errors.Is(err, errUnreadableWorkbook) {
+		return gjm.failImportFile(jobID, fmt.Sprintf("Unable to open Excel file: %v", err))
 	}
-
-	// Open Excel file from server path
-	f, err := excelize.OpenFile(filePath)
 	if err != nil {
-		errorMsg := fmt.Sprintf("Unable to open Excel file: %v", err)
-		gjm.UpdateJobStatus(jobID, "failed", 0, 0, &errorMsg, nil)
-		return
+		// The object store may be back by the next attempt
+		return fmt.Errorf("unable to read Excel file %s: %v", storageKey, err)
 	}
 	defer f.Close()
 
@@ -4518,7 +4728,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 		log.Printf("Job %d cancelled after file operations", jobID)
 		errorMsg := "Job cancelled by user"
 		gjm.UpdateJobStatus(jobID, "terminated", 0, 0, &errorMsg, nil)
-		return
+		return nil
 	default:
 		// Continue processing
 	}
@@ -4581,9 +4791,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 	// Get all sheets
 	sheets := f.GetSheetList()
 	if len(sheets) == 0 {
-		errorMsg := "No sheets found in Excel file"
-		gjm.UpdateJobStatus(jobID, "failed", 0, 0, &errorMsg, nil)
-		return
+		return gjm.failImportFile(jobID, "No sheets found in Excel file")
 	}
 
 	// Look for "Element Types" sheet
@@ -4597,23 +4805,17 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 	}
 
 	if !sheetFound {
-		errorMsg := fmt.Sprintf("Sheet '%s' not found in Excel file", elementTypesSheet)
-		gjm.UpdateJobStatus(jobID, "failed", 0, 0, &errorMsg, nil)
-		return
+		return gjm.failImportFile(jobID, fmt.Sprintf("Sheet '%s' not found in Excel file", elementTypesSheet))
 	}
 
 	// Read data from Excel sheet
 	rows, err := f.GetRows(elementTypesSheet)
 	if err != nil {
-		errorMsg := fmt.Sprintf("Error reading Excel sheet: %v", err)
-		gjm.UpdateJobStatus(jobID, "failed", 0, 0, &errorMsg, nil)
-		return
+		return gjm.failImportFile(jobID, fmt.Sprintf("Error reading Excel sheet: %v", err))
 	}
 
 	if len(rows) < 2 {
-		errorMsg := "Excel file must have at least a header row and one data row"
-		gjm.UpdateJobStatus(jobID, "failed", 0, 0, &errorMsg, nil)
-		return
+		return gjm.failImportFile(jobID, "Excel file must have at least a header row and one data row")
 	}
 
 	// Check for cancellation before parsing
@@ -4622,7 +4824,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 		log.Printf("Job %d cancelled before parsing", jobID)
 		errorMsg := "Job cancelled by user"
 		gjm.UpdateJobStatus(jobID, "terminated", 0, 0, &errorMsg, nil)
-		return
+		return nil
 	default:
 		// Continue processing
 	}
@@ -4638,13 +4840,11 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 		elementTypes, parseErr = gjm.parseExcelDataToElementTypes(rows, projectID, userName)
 	} else {
 		// Use new parsing method with summary sheet ranges
-		elementTypes, parseErr = gjm.parseExcelDataToElementTypesWithRanges(rows, ranges, projectID, userName)
+		elementTypes, parseErr = gjm.parseExcelDataToElementTypesWithRanges(rows, ranges, projectID, userName, nil)
 	}
 
 	if parseErr != nil {
-		errorMsg := fmt.Sprintf("Error parsing Excel data: %v", parseErr)
-		gjm.UpdateJobStatus(jobID, "failed", 0, 0, &errorMsg, nil)
-		return
+		return gjm.failImportFile(jobID, fmt.Sprintf("Error parsing Excel data: %v", parseErr))
 	}
 
 	totalElements := len(elementTypes)
@@ -4657,10 +4857,11 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 	}
 
 	// Process elements in batches with enhanced cancellation
-	processedItems := 0
-	successCount := 0
-	errorCount := 0
-	var errors []string
+	resume := resumeImport(run)
+	processedItems := resume.Processed
+	successCount := resume.Success
+	errorCount := resume.ErrorCount
+	errors := resume.Errors
 
 	// Create a ticker for periodic progress updates
 	progressTicker := time.NewTicker(5 * time.Second)
@@ -4688,7 +4889,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 		}
 	}()
 
-	for i := 0; i < totalElements; i += batchSize {
+	for i := resume.NextIndex; i < totalElements; i += batchSize {
 		// Enhanced cancellation check before each batch
 		select {
 		case <-ctx.Done():
@@ -4699,7 +4900,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 			gjm.ResetGlobalTerminationFlag()
 			gjm.UnblockJobCreation()
 			// Don't close progressChan here - let defer handle it
-			return
+			return nil
 		default:
 			// Continue processing
 		}
@@ -4709,7 +4910,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 			log.Printf("Job %d context error detected: %v", jobID, ctx.Err())
 			errorMsg := "Job cancelled due to context error"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		}
 
 		// Check if context is done
@@ -4718,7 +4919,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 			log.Printf("Job %d context cancelled during processing", jobID)
 			errorMsg := "Job cancelled by context"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		default:
 			// Continue processing
 		}
@@ -4728,7 +4929,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 			log.Printf("Job %d termination detected during processing", jobID)
 			errorMsg := "Job terminated by user"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		}
 
 		// Check if global termination is set (immediate check)
@@ -4736,7 +4937,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 			log.Printf("Job %d global termination detected during processing", jobID)
 			errorMsg := "Job terminated by global flag"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		}
 
 		// Check if job is still running (verification)
@@ -4744,7 +4945,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 			log.Printf("Job %d is not running, stopping processing", jobID)
 			errorMsg := "Job stopped - not running"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		}
 
 		// IMMEDIATE TERMINATION CHECKS - Check every iteration
@@ -4752,21 +4953,21 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 			log.Printf("🚨 IMMEDIATE TERMINATION: Job %d was terminated, stopping processing", jobID)
 			errorMsg := "Job terminated"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		}
 
 		if gjm.IsGlobalTerminationSet() {
 			log.Printf("🚨 IMMEDIATE TERMINATION: Global termination set - stopping processing for job %d", jobID)
 			errorMsg := "Global termination"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		}
 
 		if !gjm.IsJobRunning(jobID) {
 			log.Printf("🚨 IMMEDIATE TERMINATION: Job %d is not running, stopping processing", jobID)
 			errorMsg := "Job stopped - not running"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		}
 
 		// Check database status for immediate termination
@@ -4776,7 +4977,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 				log.Printf("🚨 IMMEDIATE TERMINATION: Job %d status is %s in database, stopping processing", jobID, dbJobStatus)
 				errorMsg := "Job terminated in database"
 				gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-				return
+				return nil
 			}
 		}
 
@@ -4785,7 +4986,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 			log.Printf("JOB LIFECYCLE: Job %d was terminated, stopping processing", jobID)
 			errorMsg := "Job terminated"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		}
 
 		// Check if global termination is set (immediate check)
@@ -4793,7 +4994,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 			log.Printf("JOB LIFECYCLE: Global termination set - stopping processing for job %d", jobID)
 			errorMsg := "Global termination"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		}
 
 		// Check for global termination
@@ -4804,7 +5005,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 			// Reset global flags when job is cancelled
 			gjm.ResetGlobalTerminationFlag()
 			gjm.UnblockJobCreation()
-			return
+			return nil
 		}
 
 		// Check if job was terminated (immediate check)
@@ -4812,7 +5013,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 			log.Printf("Job %d was terminated, stopping processing", jobID)
 			errorMsg := "Job terminated"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		}
 
 		// Check if job was terminated (immediate check)
@@ -4820,7 +5021,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 			log.Printf("Job %d was terminated, stopping processing", jobID)
 			errorMsg := "Job terminated"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		}
 
 		// Check if job was terminated
@@ -4831,7 +5032,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 			// Reset global flags when job is cancelled
 			gjm.ResetGlobalTerminationFlag()
 			gjm.UnblockJobCreation()
-			return
+			return nil
 		}
 
 		// Check job status in database
@@ -4841,7 +5042,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 				log.Printf("Job %d database status is %s, stopping processing", jobID, jobStatus)
 				errorMsg := "Job cancelled in database"
 				gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-				return
+				return nil
 			}
 		}
 
@@ -4857,14 +5058,14 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 			log.Printf("🚨 IMMEDIATE TERMINATION: Job %d was terminated before batch processing", jobID)
 			errorMsg := "Job terminated"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		}
 
 		if gjm.IsGlobalTerminationSet() {
 			log.Printf("🚨 IMMEDIATE TERMINATION: Global termination set before batch processing for job %d", jobID)
 			errorMsg := "Global termination"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		}
 
 		// Check database status for immediate termination
@@ -4874,27 +5075,32 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 				log.Printf("🚨 IMMEDIATE TERMINATION: Job %d status is %s in database before batch processing", jobID, batchDbJobStatus)
 				errorMsg := "Job terminated in database"
 				gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-				return
+				return nil
 			}
 		}
 
 		// Process batch with enhanced cancellation
-		batchErrors := gjm.processBatchWithEnhancedCancellation(ctx, batch, jobID)
+		batchErrors, batchErr := gjm.processBatchWithEnhancedCancellation(ctx, batch, jobID, importBatchCheckpoint(run, importCheckpoint{
+			NextIndex: i, Processed: processedItems, Success: successCount, ErrorCount: errorCount, Errors: errors,
+		}, end))
+
+		// The checkpoint failed, the queue decides what happens to the job
+		if batchErr != nil && batchErr != errBatchCancelled {
+			log.Printf("Job %d interrupted at item %d: %v", jobID, i, batchErr)
+			return fmt.Errorf("import job %d could not save its progress at item %d: %v", jobID, i, batchErr)
+		}
 
 		// Check if batch was cancelled
-		if len(batchErrors) > 0 && batchErrors[0] == "cancelled" {
+		if batchErr == errBatchCancelled {
 			log.Printf("Job %d cancelled during batch processing", jobID)
 			errorMsg := "Job cancelled by user"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		}
 
-		if len(batchErrors) > 0 {
-			errors = append(errors, batchErrors...)
-			errorCount += len(batchErrors)
-		} else {
-			successCount += len(batch)
-		}
+		errors = append(errors, batchErrors...)
+		errorCount += len(batchErrors)
+		successCount += len(batch) - len(batchErrors)
 
 		processedItems += len(batch)
 		progress := (processedItems * 100) / totalElements
@@ -4914,14 +5120,14 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 			log.Printf("Job %d was terminated after batch processing", jobID)
 			errorMsg := "Job terminated"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		}
 
 		if gjm.IsGlobalTerminationSet() {
 			log.Printf("Global termination set after batch processing for job %d", jobID)
 			errorMsg := "Global termination"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		}
 
 		// Additional cancellation check after each batch
@@ -4930,7 +5136,7 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 			log.Printf("Job %d cancelled after batch processing", jobID)
 			errorMsg := "Job cancelled by user"
 			gjm.UpdateJobStatus(jobID, "terminated", 0, processedItems, &errorMsg, nil)
-			return
+			return nil
 		default:
 			// Continue processing
 		}
@@ -5017,6 +5223,11 @@ func (gjm *GormJobManager) ProcessElementTypeExcelImportJobFromPathWithEnhancedC
 		gjm.UnblockJobCreation()
 		log.Printf("🚨 JOB SUCCESS: Job %d completed successfully - resetting global flags", jobID)
 	}
+	if finalStatus == "failed" {
+		// Every element failed and its error is in the checkpoint, so another attempt would skip them all
+		return PermanentJobError(fmt.Errorf("%s", *errorMsg))
+	}
+	return nil
 }
 
 // createHierarchyName creates a properly formatted hierarchy name from header and sub-header
@@ -0,0 +1,860 @@
+package handlers
+
+import (
+	"backend/models"
+	"bytes"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/lib/pq"
+)
+
+// HandoverActionTemplate is the notification action URL of the handovers of a project
+const HandoverActionTemplate = "https://precastezy.blueinvent.com/project/%d/handovers"
+
+// maxSignatureBytes caps the size of an acceptance signature image
+const maxSignatureBytes = 512 * 1024
+
+// SQL statements for floor handovers
+const (
+	selectHandoverSQL = `
+		SELECT h.id, h.project_id, COALESCE(p.name, ''), h.certificate_no, h.floor_id, COALESCE(f.name, ''),
+			COALESCE(t.name, ''), h.status, h.remarks, h.created_by, h.created_at, h.inspected_by, h.inspected_at,
+			h.accepted_by, h.accepted_designation, h.accepted_at, h.recorded_by, h.certificate_digest,
+			(SELECT COUNT(*) FROM handover_element he WHERE he.handover_id = h.id),
+			(SELECT COUNT(*) FROM handover_snag s WHERE s.handover_id = h.id AND s.status = 'open')
+		FROM handover h
+		LEFT JOIN project p ON p.project_id = h.project_id
+		LEFT JOIN precast f ON f.id = h.floor_id
+		LEFT JOIN precast t ON t.id = f.parent_id`
+)
+
+// scanHandover scans a row produced by selectHandoverSQL.
+func scanHandover(row interface{ Scan(...any) error }, h *models.Handover) error {
+	var inspectedAt, acceptedAt sql.NullTime
+	err := row.Scan(&h.ID, &h.ProjectID, &h.ProjectName, &h.CertificateNo, &h.FloorID, &h.FloorName,
+		&h.TowerName, &h.Status, &h.Remarks, &h.CreatedBy, &h.CreatedAt, &h.InspectedBy, &inspectedAt,
+		&h.AcceptedBy, &h.AcceptedDesignation, &acceptedAt, &h.RecordedBy, &h.CertificateDigest,
+		&h.ElementCount, &h.OpenSnags)
+	if err != nil {
+		return err
+	}
+	if inspectedAt.Valid {
+		h.InspectedAt = &inspectedAt.Time
+	}
+	if acceptedAt.Valid {
+		h.AcceptedAt = &acceptedAt.Time
+	}
+	return nil
+}
+
+// loadHandover reads a handover with its elements and snags.
+func loadHandover(q sqlQueryer, id int) (models.Handover, error) {
+	var h models.Handover
+	if err := scanHandover(q.QueryRow(selectHandoverSQL+" WHERE h.id = $1", id), &h); err != nil {
+		return h, err
+	}
+
+	rows, err := q.Query(`
+		SELECT he.element_id, COALESCE(e.element_name, ''), COALESCE(et.element_type, '')
+		FROM handover_element he
+		LEFT JOIN element e ON e.id = he.element_id
+		LEFT JOIN element_type et ON et.element_type_id = e.element_type_id
+		WHERE he.handover_id = $1
+		ORDER BY e.element_name, he.element_id`, id)
+	if err != nil {
+		return h, err
+	}
+	h.Elements = []models.HandoverElement{}
+	for rows.Next() {
+		var el models.HandoverElement
+		if err := rows.Scan(&el.ElementID, &el.ElementName, &el.ElementType); err != nil {
+			rows.Close()
+			return h, err
+		}
+		h.Elements = append(h.Elements, el)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return h, err
+	}
+
+	rows, err = q.Query(`
+		SELECT s.id, s.element_id, COALESCE(e.element_name, ''), s.description, s.status, s.raised_by, s.raised_at,
+			s.closed_by, s.closed_at, s.closing_remarks
+		FROM handover_snag s
+		LEFT JOIN element e ON e.id = s.element_id
+		WHERE s.handover_id = $1
+		ORDER BY s.id`, id)
+	if err != nil {
+		return h, err
+	}
+	defer rows.Close()
+	h.Snags = []models.HandoverSnag{}
+	for rows.Next() {
+		var s models.HandoverSnag
+		var elementID sql.NullInt64
+		var closedAt sql.NullTime
+		if err := rows.Scan(&s.ID, &elementID, &s.ElementName, &s.Description, &s.Status, &s.RaisedBy, &s.RaisedAt,
+			&s.ClosedBy, &closedAt, &s.ClosingRemarks); err != nil {
+			return h, err
+		}
+		s.ElementID = nullIntPtr(elementID)
+		if closedAt.Valid {
+			s.ClosedAt = &closedAt.Time
+		}
+		h.Snags = append(h.Snags, s)
+	}
+	return h, rows.Err()
+}
+
+// handoverParam reads the handover named by the :id path parameter, writing the error response when
+// it can't.
+func handoverParam(c *gin.Context, db *sql.DB) (models.Handover, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a valid integer"})
+		return models.Handover{}, false
+	}
+	h, err := loadHandover(db, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Handover not found"})
+		return h, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch handover", "details": err.Error()})
+		return h, false
+	}
+	return h, true
+}
+
+// nextHandoverNo returns the next certificate number of a project.
+func nextHandoverNo(tx *sql.Tx, projectID int) (string, error) {
+	// Serialise numbering per project
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('handover'), $1)`, projectID); err != nil {
+		return "", err
+	}
+	var last int
+	err := tx.QueryRow(`
+		SELECT COALESCE(MAX(NULLIF(regexp_replace(certificate_no, '\D', '', 'g'), '')::INT), 0)
+		FROM handover WHERE project_id = $1`, projectID).Scan(&last)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("HO-%04d", last+1), nil
+}
+
+// refreshHandoverStatus moves an inspected handover between snagging and ready as snags are raised
+// and closed.
+func refreshHandoverStatus(q sqlQueryer, id int) error {
+	_, err := q.Exec(`
+		UPDATE handover SET status = CASE
+			WHEN EXISTS (SELECT 1 FROM handover_snag s WHERE s.handover_id = handover.id AND s.status = $2) THEN $3
+			ELSE $4 END
+		WHERE id = $1 AND inspected_at IS NOT NULL AND status <> $5`,
+		id, models.SnagStatusOpen, models.HandoverStatusSnagging, models.HandoverStatusReady, models.HandoverStatusAccepted)
+	return err
+}
+
+// decodeSignature reads a base64 signature image, with or without a data URL prefix. Only PNG and
+// JPEG can be placed on the certificate.
+func decodeSignature(s string) ([]byte, string, error) {
+	if i := strings.Index(s, ","); strings.HasPrefix(s, "data:") && i > 0 {
+		s = s[i+1:]
+	}
+	data, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, "", fmt.Errorf("signature is not valid base64")
+	}
+	if len(data) > maxSignatureBytes {
+		return nil, "", fmt.Errorf("signature must be at most %d KB", maxSignatureBytes/1024)
+	}
+	switch http.DetectContentType(data) {
+	case "image/png":
+		return data, "PNG", nil
+	case "image/jpeg":
+		return data, "JPG", nil
+	}
+	return nil, "", fmt.Errorf("signature must be a PNG or JPEG image")
+}
+
+// handoverDigest fingerprints what the client accepted so a printed certificate can be checked against
+// the record.
+func handoverDigest(h models.Handover, acceptedBy, designation string, acceptedAt time.Time, signature []byte) string {
+	ids := make([]string, 0, len(h.Elements))
+	for _, el := range h.Elements {
+		ids = append(ids, strconv.Itoa(el.ElementID))
+	}
+	sum := sha256.New()
+	fmt.Fprintf(sum, "%s|%d|%d|%s|%s|%s|%s|", h.CertificateNo, h.ProjectID, h.FloorID, acceptedBy, designation,
+		acceptedAt.UTC().Format(time.RFC3339), strings.Join(ids, ","))
+	sum.Write(signature)
+	return hex.EncodeToString(sum.Sum(nil))
+}
+
+// saveHandoverActivity writes the activity log of a handover change.
+func saveHandoverActivity(db *sql.DB, session models.Session, userName, eventName, description string, projectID int) {
+	activityLog := models.ActivityLog{
+		EventContext: "Handover",
+		EventName:    eventName,
+		Description:  description,
+		UserName:     userName,
+		HostName:     session.HostName,
+		IPAddress:    session.IPAddress,
+		CreatedAt:    time.Now(),
+		ProjectID:    projectID,
+	}
+	if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+		log.Printf("Failed to save activity log: %v", logErr)
+	}
+}
+
+// CreateHandover offers the erected elements of a floor to the client for acceptance. Elements not
+// yet erected stay out and can go on a later handover of the same floor.
+// @Summary Create floor handover
+// @Tags Handovers
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param request body models.CreateHandoverRequest true "Handover"
+// @Success 201 {object} models.Handover
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/handovers [post]
+func CreateHandover(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+		var req models.CreateHandoverRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+
+		var floorName string
+		err = db.QueryRow(`SELECT name FROM precast WHERE id = $1 AND project_id = $2`, req.FloorID, projectID).Scan(&floorName)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Floor not found in this project"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch floor", "details": err.Error()})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction", "details": err.Error()})
+			return
+		}
+		defer tx.Rollback()
+
+		certificateNo, err := nextHandoverNo(tx, projectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to number handover", "details": err.Error()})
+			return
+		}
+		states, err := loadElementStates(tx, `
+			WHERE e.project_id = $1 AND e.target_location = $2
+			  AND NOT EXISTS (SELECT 1 FROM handover_element he WHERE he.element_id = e.id)
+			ORDER BY e.id`, projectID, req.FloorID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch floor elements", "details": err.Error()})
+			return
+		}
+		var erected []int
+		pending := 0
+		for _, s := range states {
+			if s.Disabled {
+				continue
+			}
+			if s.current() == models.LifecycleErected {
+				erected = append(erected, s.ElementID)
+			} else if s.current() != models.LifecycleHandedOver {
+				pending++
+			}
+		}
+		if len(erected) == 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":            "No erected elements on this floor are waiting for handover",
+				"pending_elements": pending,
+			})
+			return
+		}
+
+		var id int
+		err = tx.QueryRow(`
+			INSERT INTO handover (project_id, certificate_no, floor_id, status, remarks, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+			projectID, certificateNo, req.FloorID, models.HandoverStatusInspection, req.Remarks, userName).Scan(&id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create handover", "details": err.Error()})
+			return
+		}
+		if _, err := tx.Exec(`
+			INSERT INTO handover_element (handover_id, element_id)
+			SELECT $1, UNNEST($2::INT[])`, id, pq.Array(erected)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add handover elements", "details": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit handover", "details": err.Error()})
+			return
+		}
+
+		h, err := loadHandover(db, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch handover", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, gin.H{"handover": h, "pending_elements": pending})
+
+		sendProjectNotifications(db, projectID,
+			fmt.Sprintf("%s: %d erected elements of %s offered for handover", certificateNo, len(erected), floorName),
+			fmt.Sprintf(HandoverActionTemplate, projectID))
+		saveHandoverActivity(db, session, userName, "Create",
+			fmt.Sprintf("Created handover %s for floor %s with %d elements", certificateNo, floorName, len(erected)), projectID)
+	}
+}
+
+// GetHandoversByProject lists the handovers of a project, newest first.
+// @Summary List handovers of a project
+// @Tags Handovers
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param status query string false "inspection, snagging, ready or accepted"
+// @Param floor_id query int false "Floor ID"
+// @Success 200 {array} models.Handover
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/handovers [get]
+func GetHandoversByProject(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+		where := []string{"h.project_id = $1"}
+		args := []interface{}{projectID}
+		if status := c.Query("status"); status != "" {
+			args = append(args, status)
+			where = append(where, fmt.Sprintf("h.status = $%d", len(args)))
+		}
+		if f := c.Query("floor_id"); f != "" {
+			floorID, err := strconv.Atoi(f)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "floor_id must be a valid integer"})
+				return
+			}
+			args = append(args, floorID)
+			where = append(where, fmt.Sprintf("h.floor_id = $%d", len(args)))
+		}
+
+		rows, err := db.Query(selectHandoverSQL+" WHERE "+strings.Join(where, " AND ")+" ORDER BY h.created_at DESC, h.id DESC", args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch handovers", "details": err.Error()})
+			return
+		}
+		defer rows.Close()
+		handovers := []models.Handover{}
+		for rows.Next() {
+			var h models.Handover
+			if err := scanHandover(rows, &h); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read handover", "details": err.Error()})
+				return
+			}
+			handovers = append(handovers, h)
+		}
+		c.JSON(http.StatusOK, handovers)
+	}
+}
+
+// GetHandover returns a handover with its elements and snags.
+// @Summary Get handover
+// @Tags Handovers
+// @Produce json
+// @Param id path int true "Handover ID"
+// @Success 200 {object} models.Handover
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/handovers/{id} [get]
+func GetHandover(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+		h, ok := handoverParam(c, db)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, h)
+	}
+}
+
+// RecordHandoverInspection records the client's inspection of a handover and the snags found. A
+// handover with open snags is in snagging until they are closed; one without is ready for acceptance.
+// @Summary Record handover inspection
+// @Tags Handovers
+// @Accept json
+// @Produce json
+// @Param id path int true "Handover ID"
+// @Param request body models.HandoverInspectionRequest true "Inspection"
+// @Success 200 {object} models.Handover
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/handovers/{id}/inspection [post]
+func RecordHandoverInspection(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+		h, ok := handoverParam(c, db)
+		if !ok {
+			return
+		}
+		var req models.HandoverInspectionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		if h.Status == models.HandoverStatusAccepted {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Handover %s is already accepted", h.CertificateNo)})
+			return
+		}
+		onHandover := make(map[int]bool, len(h.Elements))
+		for _, el := range h.Elements {
+			onHandover[el.ElementID] = true
+		}
+		for _, snag := range req.Snags {
+			if strings.TrimSpace(snag.Description) == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Every snag needs a description"})
+				return
+			}
+			if snag.ElementID != 0 && !onHandover[snag.ElementID] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Element %d is not on handover %s", snag.ElementID, h.CertificateNo)})
+				return
+			}
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction", "details": err.Error()})
+			return
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`
+			UPDATE handover SET inspected_by = $2, inspected_at = NOW(),
+				remarks = CASE WHEN $3 = '' THEN remarks ELSE $3 END
+			WHERE id = $1`, h.ID, req.InspectedBy, req.Remarks); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record inspection", "details": err.Error()})
+			return
+		}
+		for _, snag := range req.Snags {
+			var elementID interface{}
+			if snag.ElementID != 0 {
+				elementID = snag.ElementID
+			}
+			if _, err := tx.Exec(`
+				INSERT INTO handover_snag (handover_id, element_id, description, status, raised_by)
+				VALUES ($1, $2, $3, $4, $5)`,
+				h.ID, elementID, snag.Description, models.SnagStatusOpen, req.InspectedBy); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record snag", "details": err.Error()})
+				return
+			}
+		}
+		if err := refreshHandoverStatus(tx, h.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update handover status", "details": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit inspection", "details": err.Error()})
+			return
+		}
+
+		h, err = loadHandover(db, h.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch handover", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, h)
+
+		if len(req.Snags) > 0 {
+			sendProjectNotifications(db, h.ProjectID,
+				fmt.Sprintf("%s: %d snags raised on inspection of %s", h.CertificateNo, len(req.Snags), h.FloorName),
+				fmt.Sprintf(HandoverActionTemplate, h.ProjectID))
+		}
+		saveHandoverActivity(db, session, userName, "Inspect",
+			fmt.Sprintf("Recorded inspection of handover %s by %s with %d snags", h.CertificateNo, req.InspectedBy, len(req.Snags)), h.ProjectID)
+	}
+}
+
+// CloseHandoverSnag records that a snag was put right.
+// @Summary Close handover snag
+// @Tags Handovers
+// @Accept json
+// @Produce json
+// @Param id path int true "Handover ID"
+// @Param snag_id path int true "Snag ID"
+// @Param request body models.CloseSnagRequest true "Closing remarks"
+// @Success 200 {object} models.Handover
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/handovers/{id}/snags/{snag_id}/close [post]
+func CloseHandoverSnag(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+		h, ok := handoverParam(c, db)
+		if !ok {
+			return
+		}
+		snagID, err := strconv.Atoi(c.Param("snag_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "snag_id must be a valid integer"})
+			return
+		}
+		var req models.CloseSnagRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		var snag *models.HandoverSnag
+		for i := range h.Snags {
+			if h.Snags[i].ID == snagID {
+				snag = &h.Snags[i]
+			}
+		}
+		if snag == nil {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Snag not found on this handover"})
+			return
+		}
+		if snag.Status != models.SnagStatusOpen {
+			c.JSON(http.StatusConflict, gin.H{"error": "Snag is already closed"})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction", "details": err.Error()})
+			return
+		}
+		defer tx.Rollback()
+		if _, err := tx.Exec(`
+			UPDATE handover_snag SET status = $2, closed_by = $3, closed_at = NOW(), closing_remarks = $4
+			WHERE id = $1`, snagID, models.SnagStatusClosed, userName, req.Remarks); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to close snag", "details": err.Error()})
+			return
+		}
+		if err := refreshHandoverStatus(tx, h.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update handover status", "details": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit snag", "details": err.Error()})
+			return
+		}
+
+		h, err = loadHandover(db, h.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch handover", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, h)
+
+		if h.Status == models.HandoverStatusReady {
+			sendProjectNotifications(db, h.ProjectID,
+				fmt.Sprintf("%s: all snags on %s closed, ready for acceptance", h.CertificateNo, h.FloorName),
+				fmt.Sprintf(HandoverActionTemplate, h.ProjectID))
+		}
+		saveHandoverActivity(db, session, userName, "Update",
+			fmt.Sprintf("Closed snag %d on handover %s", snagID, h.CertificateNo), h.ProjectID)
+	}
+}
+
+// AcceptHandover records the client engineer's signed acceptance of an inspected handover with no open
+// snags. The elements are handed over, which makes them billable at the handover milestone.
+// @Summary Accept handover
+// @Tags Handovers
+// @Accept json
+// @Produce json
+// @Param id path int true "Handover ID"
+// @Param request body models.AcceptHandoverRequest true "Acceptance"
+// @Success 200 {object} models.Handover
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/handovers/{id}/accept [post]
+func AcceptHandover(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+		h, ok := handoverParam(c, db)
+		if !ok {
+			return
+		}
+		var req models.AcceptHandoverRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		signature, signatureType, err := decodeSignature(req.Signature)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction", "details": err.Error()})
+			return
+		}
+		defer tx.Rollback()
+
+		var status string
+		if err := tx.QueryRow(`SELECT status FROM handover WHERE id = $1 FOR UPDATE`, h.ID).Scan(&status); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock handover", "details": err.Error()})
+			return
+		}
+		if status != models.HandoverStatusReady {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Handover %s is %s; only an inspected handover with no open snags can be accepted", h.CertificateNo, status)})
+			return
+		}
+
+		elementIDs := make([]int, 0, len(h.Elements))
+		for _, el := range h.Elements {
+			elementIDs = append(elementIDs, el.ElementID)
+		}
+		if err := TransitionElements(tx, elementIDs, models.LifecycleHandedOver, "Handover "+h.CertificateNo, userName); err != nil {
+			var illegalErr *models.ErrIllegalTransition
+			if errors.As(err, &illegalErr) {
+				c.JSON(http.StatusConflict, gin.H{"error": "Some elements can't be handed over from the state they are in", "conflicts": illegalErr.Elements})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to hand over elements", "details": err.Error()})
+			return
+		}
+
+		acceptedAt := time.Now().UTC().Truncate(time.Second)
+		digest := handoverDigest(h, req.AcceptedBy, req.Designation, acceptedAt, signature)
+		if _, err := tx.Exec(`
+			UPDATE handover SET status = $2, accepted_by = $3, accepted_designation = $4, accepted_at = $5,
+				recorded_by = $6, signature = $7, signature_type = $8, certificate_digest = $9
+			WHERE id = $1`,
+			h.ID, models.HandoverStatusAccepted, req.AcceptedBy, req.Designation, acceptedAt,
+			userName, signature, signatureType, digest); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to accept handover", "details": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit acceptance", "details": err.Error()})
+			return
+		}
+
+		h, err = loadHandover(db, h.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch handover", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, h)
+
+		sendProjectNotifications(db, h.ProjectID,
+			fmt.Sprintf("%s: %s accepted by %s, %d elements handed over", h.CertificateNo, h.FloorName, req.AcceptedBy, h.ElementCount),
+			fmt.Sprintf(HandoverActionTemplate, h.ProjectID))
+		saveHandoverActivity(db, session, userName, "Accept",
+			fmt.Sprintf("Handover %s accepted by %s", h.CertificateNo, req.AcceptedBy), h.ProjectID)
+	}
+}
+
+// GetHandoverCertificate returns the signed handover certificate of an accepted handover as a PDF.
+// @Summary Get handover certificate
+// @Tags Handovers
+// @Produce application/pdf
+// @Param id path int true "Handover ID"
+// @Success 200 {file} file
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/handovers/{id}/certificate [get]
+func GetHandoverCertificate(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+		h, ok := handoverParam(c, db)
+		if !ok {
+			return
+		}
+		if h.Status != models.HandoverStatusAccepted {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Handover %s has not been accepted yet", h.CertificateNo)})
+			return
+		}
+		var signature []byte
+		var signatureType string
+		if err := db.QueryRow(`SELECT signature, signature_type FROM handover WHERE id = $1`, h.ID).Scan(&signature, &signatureType); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch signature", "details": err.Error()})
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := writeHandoverCertificatePDF(&buf, h, signature, signatureType); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate PDF", "details": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.pdf", h.CertificateNo))
+		c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+	}
+}
+
+// writeHandoverCertificatePDF lays out the certificate as an A4 document listing the elements and
+// closed snags, signed by the client engineer.
+func writeHandoverCertificatePDF(buf *bytes.Buffer, h models.Handover, signature []byte, signatureType string) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(12, 12, 12)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	// Header band
+	pdf.SetFont("Arial", "B", 18)
+	pdf.SetFillColor(240, 240, 240)
+	pdf.Rect(12, 12, 186, 12, "F")
+	pdf.SetXY(12, 13)
+	pdf.Cell(120, 10, "Handover Certificate")
+	pdf.SetFont("Arial", "B", 12)
+	pdf.CellFormat(66, 10, h.CertificateNo, "", 0, "R", false, 0, "")
+	pdf.Ln(16)
+
+	field := func(label, value string) {
+		pdf.SetFont("Arial", "", 10)
+		pdf.Cell(40, 6, label)
+		pdf.SetFont("Arial", "B", 10)
+		pdf.Cell(146, 6, tr(value))
+		pdf.Ln(6)
+	}
+	field("Project:", h.ProjectName)
+	location := h.FloorName
+	if h.TowerName != "" {
+		location = h.TowerName + " / " + h.FloorName
+	}
+	field("Location:", location)
+	field("Elements:", strconv.Itoa(h.ElementCount))
+	if h.InspectedAt != nil {
+		field("Inspected:", h.InspectedAt.Format("2006-01-02")+" by "+h.InspectedBy)
+	}
+	if h.AcceptedAt != nil {
+		field("Accepted:", h.AcceptedAt.Format("2006-01-02 15:04 MST"))
+	}
+	pdf.Ln(2)
+	pdf.SetFont("Arial", "", 10)
+	pdf.MultiCell(186, 5, tr(fmt.Sprintf(
+		"The precast elements listed below, erected at %s, have been inspected and are accepted as complete. "+
+			"All snags raised on inspection have been closed.", location)), "", "L", false)
+	pdf.Ln(4)
+
+	headers := []string{"#", "Element", "Element Type"}
+	widths := []float64{12, 87, 87}
+	tableHeader := func() {
+		pdf.SetFont("Arial", "B", 9)
+		pdf.SetFillColor(230, 230, 230)
+		for i, title := range headers {
+			pdf.CellFormat(widths[i], 8, title, "1", 0, "C", true, 0, "")
+		}
+		pdf.Ln(8)
+		pdf.SetFont("Arial", "", 9)
+	}
+	tableHeader()
+	for i, el := range h.Elements {
+		if pdf.GetY() > 260 {
+			pdf.AddPage()
+			tableHeader()
+		}
+		pdf.CellFormat(widths[0], 6, strconv.Itoa(i+1), "1", 0, "C", false, 0, "")
+		pdf.CellFormat(widths[1], 6, tr(el.ElementName), "1", 0, "L", false, 0, "")
+		pdf.CellFormat(widths[2], 6, tr(el.ElementType), "1", 0, "L", false, 0, "")
+		pdf.Ln(6)
+	}
+
+	if len(h.Snags) > 0 {
+		pdf.Ln(6)
+		pdf.SetFont("Arial", "B", 11)
+		pdf.Cell(186, 7, "Snags Closed")
+		pdf.Ln(8)
+		pdf.SetFont("Arial", "", 9)
+		for _, s := range h.Snags {
+			if pdf.GetY() > 260 {
+				pdf.AddPage()
+			}
+			line := s.Description
+			if s.ElementName != "" {
+				line = s.ElementName + ": " + line
+			}
+			if s.ClosingRemarks != "" {
+				line += " - " + s.ClosingRemarks
+			}
+			pdf.MultiCell(186, 5, tr("- "+line), "", "L", false)
+		}
+	}
+
+	// Signature block
+	if pdf.GetY() > 220 {
+		pdf.AddPage()
+	}
+	pdf.Ln(10)
+	pdf.SetFont("Arial", "B", 11)
+	pdf.Cell(186, 7, "Accepted on behalf of the client")
+	pdf.Ln(9)
+	y := pdf.GetY()
+	pdf.Rect(12, y, 80, 30, "D")
+	if len(signature) > 0 {
+		pdf.RegisterImageOptionsReader("signature", gofpdf.ImageOptions{ImageType: signatureType}, bytes.NewReader(signature))
+		pdf.ImageOptions("signature", 14, y+2, 76, 26, false, gofpdf.ImageOptions{ImageType: signatureType}, 0, "")
+	}
+	for i, line := range [][2]string{
+		{"Name:", h.AcceptedBy},
+		{"Designation:", h.AcceptedDesignation},
+		{"Recorded by:", h.RecordedBy},
+	} {
+		pdf.SetXY(96, y+4+float64(i)*7)
+		pdf.SetFont("Arial", "", 10)
+		pdf.Cell(28, 6, line[0])
+		pdf.SetFont("Arial", "B", 10)
+		pdf.Cell(74, 6, tr(line[1]))
+	}
+	pdf.SetXY(12, y+34)
+	pdf.SetFont("Arial", "", 7)
+	pdf.Cell(186, 4, "Certificate digest (SHA-256): "+h.CertificateDigest)
+
+	return pdf.Output(buf)
+}
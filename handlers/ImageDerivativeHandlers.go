@@ -0,0 +1,301 @@
+package handlers
+
+import (
+	"backend/services"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// maxDerivativeSource caps how much of an original is read to render derivatives
+const maxDerivativeSource = 100 << 20
+
+// errNoDerivative means the original could not be rendered, so it is served as is
+var errNoDerivative = errors.New("image derivative unavailable")
+
+// derivativeKey stores derivatives next to their original
+func derivativeKey(sourceKey, variant string) string {
+	return "derived/" + sourceKey + "/" + variant + ".jpg"
+}
+
+var derivableImageTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp"}
+var derivableImageExtensions = []string{".jpg", ".jpeg", ".png", ".gif", ".webp"}
+
+// isDerivableImage reports whether thumbnails can be made for a file. Legacy files carry no
+// content type, so their extension decides.
+func isDerivableImage(sf StoredFile) bool {
+	if sf.ContentType != "" {
+		for _, t := range derivableImageTypes {
+			if strings.HasPrefix(sf.ContentType, t) {
+				return true
+			}
+		}
+		return false
+	}
+	ext := strings.ToLower(filepath.Ext(sf.FileName))
+	for _, e := range derivableImageExtensions {
+		if ext == e {
+			return true
+		}
+	}
+	return false
+}
+
+// generateImageDerivatives renders and stores every variant of an image. A file that cannot be
+// decoded is recorded so it is not retried on every request.
+func generateImageDerivatives(db *sql.DB, sf StoredFile) error {
+	store := objectStore()
+	if store == nil {
+		return fmt.Errorf("no object store configured")
+	}
+
+	rc, _, err := openStoredFile(sf)
+	if err != nil {
+		return err
+	}
+	data, err := io.ReadAll(io.LimitReader(rc, maxDerivativeSource+1))
+	rc.Close()
+	if err != nil {
+		return err
+	}
+
+	var renderErr error
+	if len(data) > maxDerivativeSource {
+		renderErr = fmt.Errorf("original exceeds %d MB", maxDerivativeSource>>20)
+	}
+	for name, variant := range services.ImageVariants {
+		if renderErr != nil {
+			break
+		}
+		out, w, h, err := services.RenderImageVariant(data, variant)
+		if err != nil {
+			renderErr = err
+			break
+		}
+		key := derivativeKey(sf.StorageKey, name)
+		if err := store.Put(context.Background(), key, bytes.NewReader(out), int64(len(out)), "image/jpeg"); err != nil {
+			return err
+		}
+		if _, err := db.Exec(`
+			INSERT INTO image_derivative (source_key, variant, storage_key, width, height, size, error, created_at)
+			VALUES ($1, $2, $3, $4, $5, $6, '', NOW())
+			ON CONFLICT (source_key, variant) DO UPDATE
+			SET storage_key = EXCLUDED.storage_key, width = EXCLUDED.width, height = EXCLUDED.height,
+			    size = EXCLUDED.size, error = '', created_at = NOW()`,
+			sf.StorageKey, name, key, w, h, len(out)); err != nil {
+			return err
+		}
+	}
+
+	if renderErr != nil {
+		for name := range services.ImageVariants {
+			db.Exec(`
+				INSERT INTO image_derivative (source_key, variant, error) VALUES ($1, $2, $3)
+				ON CONFLICT (source_key, variant) DO UPDATE SET error = EXCLUDED.error, created_at = NOW()`,
+				sf.StorageKey, name, renderErr.Error())
+		}
+		return renderErr
+	}
+	return nil
+}
+
+// ensureImageDerivative returns the storage key of a variant, rendering it on first use
+func ensureImageDerivative(db *sql.DB, sf StoredFile, variant string) (string, error) {
+	var key, renderErr string
+	err := db.QueryRow(`SELECT storage_key, error FROM image_derivative WHERE source_key = $1 AND variant = $2`,
+		sf.StorageKey, variant).Scan(&key, &renderErr)
+	if err == nil && key != "" && renderErr == "" {
+		return key, nil
+	}
+	if err == nil && renderErr != "" {
+		return "", errNoDerivative
+	}
+	if err != nil && err != sql.ErrNoRows {
+		return "", err
+	}
+
+	if err := generateImageDerivatives(db, sf); err != nil {
+		log.Printf("Failed to render derivatives of %s: %v", sf.FileName, err)
+		return "", errNoDerivative
+	}
+	return derivativeKey(sf.StorageKey, variant), nil
+}
+
+// ServeImageVariant streams a derivative of an image. Files that are not images, or that
+// could not be rendered, are served in their original form.
+func ServeImageVariant(c *gin.Context, db *sql.DB, fileName, variant string) {
+	sf, err := resolveStoredFile(db, fileName)
+	if errors.Is(err, errFileNotServed) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+		return
+	}
+	if !isDerivableImage(sf) {
+		ServeStoredFile(c, db, fileName)
+		return
+	}
+	key, err := ensureImageDerivative(db, sf, variant)
+	if err != nil {
+		ServeStoredFile(c, db, fileName)
+		return
+	}
+
+	rc, info, err := objectStore().Get(context.Background(), key)
+	if errors.Is(err, services.ErrObjectNotFound) {
+		// The cache entry outlived its object; render again next time
+		db.Exec(`DELETE FROM image_derivative WHERE source_key = $1`, sf.StorageKey)
+		ServeStoredFile(c, db, fileName)
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error", "details": err.Error()})
+		return
+	}
+	defer rc.Close()
+
+	if sf.SHA256 != "" {
+		c.Header("ETag", `"`+sf.SHA256+"-"+variant+`"`)
+		c.Header("Cache-Control", "private, max-age=86400, immutable")
+	}
+	size := info.Size
+	if size == 0 {
+		size = -1
+	}
+	c.DataFromReader(http.StatusOK, size, "image/jpeg", rc, nil)
+}
+
+// derivativeBackfillRunning prevents overlapping backfill runs
+var derivativeBackfillRunning int32
+
+// errBackfillRunning is returned when a backfill is already in progress
+var errBackfillRunning = errors.New("image derivative backfill is already running")
+
+// BackfillImageDerivatives renders missing derivatives for up to limit images: catalogued
+// uploads plus legacy QC photos and drawing files stored before derivatives existed.
+// It returns the number of images processed.
+func BackfillImageDerivatives(ctx context.Context, db *sql.DB, limit int) (int, error) {
+	if !atomic.CompareAndSwapInt32(&derivativeBackfillRunning, 0, 1) {
+		return 0, errBackfillRunning
+	}
+	defer atomic.StoreInt32(&derivativeBackfillRunning, 0)
+
+	if limit <= 0 {
+		limit = 200
+	}
+
+	rows, err := db.QueryContext(ctx, `
+		SELECT name FROM (
+			SELECT sf.file_name AS name, sf.created_at AS at
+			FROM stored_file sf
+			WHERE sf.content_type ~ '^image/(jpeg|png|gif|webp)'
+			  AND NOT EXISTS (SELECT 1 FROM image_derivative d WHERE d.source_key = sf.storage_key)
+			UNION
+			SELECT legacy.name, NULL FROM (
+				SELECT image_path AS name FROM qc_answers WHERE image_path IS NOT NULL
+				UNION SELECT file FROM drawings WHERE file IS NOT NULL
+				UNION SELECT file FROM drawings_revision WHERE file IS NOT NULL
+			) legacy
+			WHERE lower(legacy.name) ~ '\.(jpe?g|png|gif|webp)$'
+			  AND NOT EXISTS (SELECT 1 FROM stored_file sf WHERE sf.file_name = legacy.name)
+			  AND NOT EXISTS (SELECT 1 FROM image_derivative d WHERE d.source_key = legacy.name)
+		) candidates
+		ORDER BY at DESC NULLS LAST
+		LIMIT $1`, limit)
+	if err != nil {
+		return 0, err
+	}
+	var names []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			rows.Close()
+			return 0, err
+		}
+		names = append(names, name)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return 0, err
+	}
+
+	processed := 0
+	for _, name := range names {
+		if err := ctx.Err(); err != nil {
+			return processed, err
+		}
+		if filepath.Clean(name) != name || strings.Contains(name, "..") || filepath.IsAbs(name) {
+			continue
+		}
+		sf, err := resolveStoredFile(db, name)
+		if errors.Is(err, errFileNotServed) {
+			continue
+		} else if err != nil {
+			return processed, err
+		}
+		if err := generateImageDerivatives(db, sf); err != nil {
+			log.Printf("Derivative backfill: %s: %v", name, err)
+		}
+		processed++
+	}
+	log.Printf("Derivative backfill processed %d images", processed)
+	return processed, nil
+}
+
+// StartImageDerivativeBackfill runs a derivative backfill in the background.
+// @Summary Backfill image thumbnails
+// @Description Renders thumbnails and web-size copies for images uploaded before derivatives existed. Runs in the background.
+// @Tags upload
+// @Produce json
+// @Param Authorization header string true "Session ID"
+// @Param limit query int false "Maximum images to process (default 200)"
+// @Success 202 {object} models.MessageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/files/derivatives/backfill [post]
+func StartImageDerivativeBackfill(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+			return
+		}
+		if _, _, err := GetSessionDetails(db, sessionID); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		limit := 200
+		if s := c.Query("limit"); s != "" {
+			n, err := strconv.Atoi(s)
+			if err != nil || n <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a positive number"})
+				return
+			}
+			limit = n
+		}
+		if atomic.LoadInt32(&derivativeBackfillRunning) == 1 {
+			c.JSON(http.StatusConflict, gin.H{"error": errBackfillRunning.Error()})
+			return
+		}
+
+		go func() {
+			if _, err := BackfillImageDerivatives(context.Background(), db, limit); err != nil {
+				log.Printf("Derivative backfill failed: %v", err)
+			}
+		}()
+		c.JSON(http.StatusAccepted, gin.H{"message": "Image derivative backfill started"})
+	}
+}
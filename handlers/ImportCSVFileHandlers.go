@@ -4,7 +4,6 @@ import (
 	"backend/models"
 	"backend/repository"
 	"backend/storage"
-	"context"
 	"database/sql"
 	"encoding/csv"
 	"encoding/json"
@@ -35,11 +34,14 @@ func contains(slice []string, value string) bool {
 
 // ImportCSVBOM godoc
 // @Summary      Import BOM from CSV
+// @Description  With dry_run=true the file is only validated and the report returned, or with format=xlsx the file as an annotated workbook.
 // @Tags         import
 // @Accept       multipart/form-data
 // @Produce      json
 // @Param        project_id  path    int   true  "Project ID"
 // @Param        file        formData  file  true  "CSV file"
+// @Param        dry_run     query   bool    false  "Only validate the file"
+// @Param        format      query   string  false  "xlsx for the annotated workbook of a dry run"
 // @Success      200  {object}  object
 // @Failure      400  {object}  object
 // @Failure      401  {object}  object
@@ -76,6 +78,12 @@ func ImportCSVBOM(c *gin.Context) {
 		return
 	}
 
+	// A dry run only validates the file; nothing is imported
+	if c.Query("dry_run") == "true" {
+		respondBOMImportValidation(c, db, file, ProjectID)
+		return
+	}
+
 	// Open the file
 	src, err := file.Open()
 	if err != nil {
@@ -281,11 +289,14 @@ func ImportCSVBOM(c *gin.Context) {
 
 // ImportCSVPrecast godoc
 // @Summary      Import precast from CSV
+// @Description  With dry_run=true the file is only validated and the report returned, or with format=xlsx the file as an annotated workbook.
 // @Tags         import
 // @Accept       multipart/form-data
 // @Produce      json
 // @Param        project_id  path    int   true  "Project ID"
 // @Param        file        formData  file  true  "CSV file"
+// @Param        dry_run     query   bool    false  "Only validate the file"
+// @Param        format      query   string  false  "xlsx for the annotated workbook of a dry run"
 // @Success      200  {object}  object
 // @Failure      400  {object}  object
 // @Failure      401  {object}  object
@@ -311,6 +322,13 @@ func ImportCSVPrecast(c *gin.Context) {
 		return
 	}
 
+	// A dry run only validates the file; nothing is imported
+	if c.Query("dry_run") == "true" {
+		projectID, _ := strconv.Atoi(c.Param("project_id"))
+		respondPrecastImportValidation(c, db, file, projectID)
+		return
+	}
+
 	// Open the file
 	src, err := file.Open()
 	if err != nil {
@@ -756,6 +774,12 @@ func processBatch(c *gin.Context, batch []models.ElementType) error {
 			return fmt.Errorf("failed to insert BOM: %w", err)
 		}
 
+		// The imported BOM is the baseline version of the new element type
+		if _, err := SnapshotElementTypeBOMVersion(tx, element.ElementTypeId, element.ProjectID, element.CreatedBy); err != nil {
+			log.Printf("Failed to snapshot BOM version. ElementTypeID: %d, Error: %v", element.ElementTypeId, err)
+			return fmt.Errorf("failed to snapshot BOM version: %w", err)
+		}
+
 		// Commit transaction
 		if err := tx.Commit(); err != nil {
 			log.Printf("Failed to commit transaction. ElementTypeID: %d, Error: %v", element.ElementTypeId, err)
@@ -1176,20 +1200,20 @@ func ImportElementTypeCSVHandler(c *gin.Context) {
 		return
 	}
 
-	// Start background processing
-	go func() {
-		// Create a cancellable context for this job
-		ctx, cancel := context.WithCancel(context.Background())
-		jobManager.registerJob(jobID, cancel)
-
-		// Ensure job is unregistered when it completes
-		defer func() {
-			jobManager.unregisterJob(jobID)
-		}()
-
-		// Use enhanced cancellation method for better goroutine cleanup
-		jobManager.ProcessElementTypeImportJobWithEnhancedCancellation(ctx, jobID, projectID, elementTypes, batchSize, concurrentBatches)
-	}()
+	// Queue the import; any server picks it up and a restart resumes it
+	if err := enqueueImportJob(db, ElementTypeImportJob, importJobPayload{
+		ImportJobID:       jobID,
+		ProjectID:         projectID,
+		ElementTypes:      elementTypes,
+		BatchSize:         batchSize,
+		ConcurrentBatches: concurrentBatches,
+		UserName:          userName,
+		HostName:          session.HostName,
+		IPAddress:         session.IPAddress,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue job", "details": err.Error()})
+		return
+	}
 
 	// Return job ID immediately
 	c.JSON(http.StatusOK, gin.H{
@@ -1262,7 +1286,8 @@ func ImportElementTypeCSVHandler(c *gin.Context) {
 	}
 }
 
-// ImportElementTypeExcelHandler handles the import of element types from Excel.
+// ImportElementTypeExcelHandler handles the import of element types from Excel. With dry_run=true it
+// only validates the sheet and returns the report, or with format=xlsx the annotated workbook.
 func ImportElementTypeExcelHandler(c *gin.Context) {
 	db := storage.GetDB()
 
@@ -1299,31 +1324,24 @@ func ImportElementTypeExcelHandler(c *gin.Context) {
 		return
 	}
 
-	// Upload file to server first using existing upload function
-	// Try multiple directory options for better compatibility
-	importDir := "/var/www/dataprecast/"
+	// Reject anything that is not a real spreadsheet before it reaches the importer
+	if rejection := ValidateUploadFile(db, file, models.UploadPurposeImport, userName); rejection != nil {
+		c.JSON(rejection.Status, rejection.JSON())
+		return
+	}
 
-	// Ensure the import directory exists with proper permissions
-	if err := EnsureDirectoryExists(importDir); err != nil {
-		// Fallback to local directory if server directory fails
-		fallbackDir := "./imports/"
-		if err := EnsureDirectoryExists(fallbackDir); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":          "Failed to create import directories",
-				"details":        err.Error(),
-				"attempted_dirs": []string{importDir, fallbackDir},
-			})
-			return
-		}
-		importDir = fallbackDir
+	// A dry run only validates the sheet; nothing is stored or imported
+	if c.Query("dry_run") == "true" {
+		NewGormJobManager().respondImportValidation(c, db, file, projectID, userName)
+		return
 	}
 
-	filePath, err := UploadFileToDirectory(file, importDir, 10<<20) // 10 MB limit
+	// Store the workbook where any instance picking up the job can read it
+	storageKey, err := UploadFileToDirectory(file, "imports", 10<<20) // 10 MB limit
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":     "Failed to upload file to server",
-			"details":   err.Error(),
-			"directory": importDir,
+			"error":   "Failed to upload file to server",
+			"details": err.Error(),
 		})
 		return
 	}
@@ -1332,7 +1350,7 @@ func ImportElementTypeExcelHandler(c *gin.Context) {
 	jobManager := NewGormJobManager()
 
 	// Create a new import job and get job ID with file path
-	jobID, err := jobManager.CreateImportJobAndGetID(c, &filePath)
+	jobID, err := jobManager.CreateImportJobAndGetID(c, &storageKey)
 	if err != nil {
 		// Clean up uploaded file if job creation fails
 		//CleanupFile(filePath)
@@ -1354,6 +1372,21 @@ func ImportElementTypeExcelHandler(c *gin.Context) {
 		concurrentBatches = 15 // Default to 15 if invalid
 	}
 
+	// Queue the import; any server picks it up and a restart resumes it
+	if err := enqueueImportJob(db, ElementTypeExcelImportJob, importJobPayload{
+		ImportJobID:       jobID,
+		ProjectID:         projectID,
+		StorageKey:        storageKey,
+		BatchSize:         batchSize,
+		ConcurrentBatches: concurrentBatches,
+		UserName:          userName,
+		HostName:          session.HostName,
+		IPAddress:         session.IPAddress,
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to queue job", "details": err.Error()})
+		return
+	}
+
 	// Return job ID immediately to prevent timeout
 	c.JSON(http.StatusOK, gin.H{
 		"message":            "Import job started successfully",
@@ -1361,7 +1394,7 @@ func ImportElementTypeExcelHandler(c *gin.Context) {
 		"status":             "pending",
 		"batch_size":         batchSize,
 		"concurrent_batches": concurrentBatches,
-		"file_path":          filePath,
+		"file_path":          storageKey,
 	})
 
 	// Get userID from session
@@ -1420,19 +1453,4 @@ func ImportElementTypeExcelHandler(c *gin.Context) {
 			log.Printf("Failed to log activity: %v", logErr)
 		}
 	}()
-
-	// Start background processing after response is sent
-	go func() {
-		// Create a cancellable context for this job
-		ctx, cancel := context.WithCancel(context.Background())
-		jobManager.registerJob(jobID, cancel)
-
-		// Ensure job is unregistered when it completes
-		defer func() {
-			jobManager.unregisterJob(jobID)
-		}()
-
-		// Use enhanced cancellation method for better goroutine cleanup
-		jobManager.ProcessElementTypeExcelImportJobFromPathWithEnhancedCancellation(ctx, jobID, projectID, filePath, batchSize, concurrentBatches, userName, session)
-	}()
 }
@@ -0,0 +1,633 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+	"github.com/xuri/excelize/v2"
+)
+
+const (
+	elementTypesImportSheet = "Element Types"
+	importValidationSheet   = "Validation"
+	importValidationAuthor  = "Import validation"
+)
+
+// importIssueReporter is told about a problem the import parser finds, by 0-based row and column
+type importIssueReporter func(row, col int, code, message string)
+
+// importIssueSeverity is how bad a problem is; everything the import would skip or misread is an error.
+func importIssueSeverity(code string) string {
+	if code == models.ImportIssueNoHierarchy {
+		return models.ImportIssueWarning
+	}
+	return models.ImportIssueError
+}
+
+// positionedIssue is an issue with the 0-based position it was found at, for sorting
+type positionedIssue struct {
+	row, col int
+	issue    models.ImportValidationIssue
+}
+
+// importIssues collects the issues of a dry run, filling in the cell, its header and its value from the
+// rows of the sheet.
+type importIssues struct {
+	rows   [][]string
+	header func(col int) string
+	list   []positionedIssue
+}
+
+// add records a problem at a 0-based row and column; -1 for the whole row or file.
+func (l *importIssues) add(row, col int, code, message string) {
+	issue := models.ImportValidationIssue{Code: code, Severity: importIssueSeverity(code), Message: message}
+	if row >= 0 {
+		issue.Row = row + 1
+	}
+	if row >= 0 && col >= 0 {
+		issue.Column, _ = excelize.ColumnNumberToName(col + 1)
+		issue.Cell, _ = excelize.CoordinatesToCellName(col+1, row+1)
+		issue.Header = l.header(col)
+		if row < len(l.rows) && col < len(l.rows[row]) {
+			issue.Value = l.rows[row][col]
+		}
+	}
+	l.list = append(l.list, positionedIssue{row: row, col: col, issue: issue})
+}
+
+// validateElementTypeWorkbook dry runs the element type import of a workbook. It parses the sheet as the
+// import does and reports every cell the import would skip or misread, element types repeated in the
+// sheet or already in the project, and rows that would create no elements.
+func (gjm *GormJobManager) validateElementTypeWorkbook(db *sql.DB, f *excelize.File, projectID int, fileName, userName string) (models.ImportValidationReport, error) {
+	report := models.ImportValidationReport{
+		ProjectID: projectID,
+		FileName:  fileName,
+		Sheet:     elementTypesImportSheet,
+		Issues:    []models.ImportValidationIssue{},
+	}
+	issues := &importIssues{}
+	issues.header = func(col int) string { return importColumnHeader(issues.rows, col) }
+	add := issues.add
+	fileIssue := func(message string) (models.ImportValidationReport, error) {
+		add(-1, -1, models.ImportIssueFile, message)
+		return finishImportValidation(report, issues.list), nil
+	}
+
+	rows, err := f.GetRows(elementTypesImportSheet)
+	if err != nil {
+		return fileIssue(fmt.Sprintf("Sheet '%s' not found in Excel file", elementTypesImportSheet))
+	}
+	issues.rows = rows
+	ranges, err := gjm.parseSummarySheetRanges(f, projectID)
+	if err != nil {
+		return fileIssue(fmt.Sprintf("Error parsing summary sheet: %v", err))
+	}
+	elementTypes, err := gjm.parseExcelDataToElementTypesWithRanges(rows, ranges, projectID, userName, add)
+	if err != nil {
+		return fileIssue(err.Error())
+	}
+	report.ElementTypes = len(elementTypes)
+
+	existing := make(map[string]bool)
+	existingRows, err := db.Query(`SELECT LOWER(TRIM(element_type)) FROM element_type WHERE project_id = $1`, projectID)
+	if err != nil {
+		return report, fmt.Errorf("failed to fetch element types: %v", err)
+	}
+	for existingRows.Next() {
+		var code string
+		if err := existingRows.Scan(&code); err != nil {
+			existingRows.Close()
+			return report, fmt.Errorf("failed to read element types: %v", err)
+		}
+		existing[code] = true
+	}
+	existingRows.Close()
+
+	// The parser makes one element type of every row with the base columns, in order
+	seen := make(map[string]int)
+	next := 0
+	for i := 3; i < len(rows); i++ {
+		row := rows[i]
+		if strings.TrimSpace(strings.Join(row, "")) == "" {
+			continue
+		}
+		report.Rows++
+		if len(row) < ranges.BaseColumns.End || next >= len(elementTypes) {
+			continue
+		}
+		elementType := elementTypes[next]
+		next++
+
+		code := strings.ToLower(strings.TrimSpace(row[0]))
+		if code != "" {
+			if first, ok := seen[code]; ok {
+				add(i, 0, models.ImportIssueDuplicateElement, fmt.Sprintf("Element type %q is repeated from row %d", row[0], first+1))
+			} else if existing[code] {
+				add(i, 0, models.ImportIssueDuplicateElement, fmt.Sprintf("Element type %q already exists in the project", row[0]))
+			} else {
+				seen[code] = i
+			}
+		}
+		if len(elementType.HierarchyQ) == 0 {
+			add(i, -1, models.ImportIssueNoHierarchy, "No hierarchy quantities, the element type would have no elements")
+		}
+	}
+
+	return finishImportValidation(report, issues.list), nil
+}
+
+// finishImportValidation sorts the issues by position and counts them.
+func finishImportValidation(report models.ImportValidationReport, issues []positionedIssue) models.ImportValidationReport {
+	sort.SliceStable(issues, func(a, b int) bool {
+		if issues[a].row != issues[b].row {
+			return issues[a].row < issues[b].row
+		}
+		return issues[a].col < issues[b].col
+	})
+	rowsWithErrors := make(map[int]bool)
+	for _, p := range issues {
+		if p.issue.Severity == models.ImportIssueError {
+			report.Errors++
+			if p.row >= 0 {
+				rowsWithErrors[p.row] = true
+			}
+		} else {
+			report.Warnings++
+		}
+		report.Issues = append(report.Issues, p.issue)
+	}
+	report.RowsWithErrors = len(rowsWithErrors)
+	report.ValidRows = report.Rows - report.RowsWithErrors
+	if report.ValidRows < 0 {
+		report.ValidRows = 0
+	}
+	report.Valid = report.Errors == 0
+	return report
+}
+
+// importColumnHeader is the header of a column of the import sheet: its main header, which may span
+// several columns, joined to its sub header.
+func importColumnHeader(rows [][]string, col int) string {
+	var mainHeader, subHeader string
+	if len(rows) > 0 {
+		for k := col; k >= 0; k-- {
+			if k < len(rows[0]) && strings.TrimSpace(rows[0][k]) != "" {
+				mainHeader = strings.TrimSpace(rows[0][k])
+				break
+			}
+		}
+	}
+	if len(rows) > 1 && col < len(rows[1]) {
+		subHeader = strings.TrimSpace(rows[1][col])
+	}
+	switch {
+	case mainHeader != "" && subHeader != "":
+		return mainHeader + "_" + subHeader
+	case subHeader != "":
+		return subHeader
+	}
+	return mainHeader
+}
+
+// annotateImportWorkbook highlights the cells of every issue in the sheet of the report, with the problems
+// as a cell comment, and lists all issues in a Validation sheet linking to their cells.
+func annotateImportWorkbook(f *excelize.File, report models.ImportValidationReport) error {
+	errorStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Color: "9C0006"},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"FFC7CE"}, Pattern: 1},
+	})
+	if err != nil {
+		return err
+	}
+	warningStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Color: "9C5700"},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"FFEB9C"}, Pattern: 1},
+	})
+	if err != nil {
+		return err
+	}
+	headerStyle, err := f.NewStyle(&excelize.Style{
+		Font: &excelize.Font{Bold: true, Color: "FFFFFF"},
+		Fill: excelize.Fill{Type: "pattern", Color: []string{"4F81BD"}, Pattern: 1},
+	})
+	if err != nil {
+		return err
+	}
+
+	// One highlight and comment per cell; a row issue marks the first cell of the row
+	var cells []string
+	messages := make(map[string][]string)
+	isError := make(map[string]bool)
+	for _, issue := range report.Issues {
+		if issue.Row == 0 {
+			continue
+		}
+		cell := issue.Cell
+		if cell == "" {
+			cell = fmt.Sprintf("A%d", issue.Row)
+		}
+		if _, ok := messages[cell]; !ok {
+			cells = append(cells, cell)
+		}
+		messages[cell] = append(messages[cell], issue.Message)
+		if issue.Severity == models.ImportIssueError {
+			isError[cell] = true
+		}
+	}
+	if index, _ := f.GetSheetIndex(report.Sheet); index >= 0 {
+		for _, cell := range cells {
+			style := warningStyle
+			if isError[cell] {
+				style = errorStyle
+			}
+			if err := f.SetCellStyle(report.Sheet, cell, cell, style); err != nil {
+				return err
+			}
+			if err := f.AddComment(report.Sheet, excelize.Comment{
+				Author: importValidationAuthor,
+				Cell:   cell,
+				Text:   strings.Join(messages[cell], "\n"),
+			}); err != nil {
+				log.Printf("Failed to add validation comment to %s: %v", cell, err)
+			}
+		}
+	}
+
+	if index, _ := f.GetSheetIndex(importValidationSheet); index >= 0 {
+		if err := f.DeleteSheet(importValidationSheet); err != nil {
+			return err
+		}
+	}
+	if _, err := f.NewSheet(importValidationSheet); err != nil {
+		return err
+	}
+	summary := fmt.Sprintf("%d rows, %d with errors: %d errors, %d warnings", report.Rows, report.RowsWithErrors, report.Errors, report.Warnings)
+	f.SetCellValue(importValidationSheet, "A1", summary)
+	headers := []string{"Row", "Cell", "Column", "Value", "Severity", "Problem"}
+	for i, header := range headers {
+		cell, _ := excelize.CoordinatesToCellName(i+1, 3)
+		f.SetCellValue(importValidationSheet, cell, header)
+	}
+	f.SetCellStyle(importValidationSheet, "A3", "F3", headerStyle)
+	for i, issue := range report.Issues {
+		r := i + 4
+		if issue.Row > 0 {
+			f.SetCellValue(importValidationSheet, fmt.Sprintf("A%d", r), issue.Row)
+		}
+		f.SetCellValue(importValidationSheet, fmt.Sprintf("B%d", r), issue.Cell)
+		f.SetCellValue(importValidationSheet, fmt.Sprintf("C%d", r), issue.Header)
+		f.SetCellValue(importValidationSheet, fmt.Sprintf("D%d", r), issue.Value)
+		f.SetCellValue(importValidationSheet, fmt.Sprintf("E%d", r), issue.Severity)
+		f.SetCellValue(importValidationSheet, fmt.Sprintf("F%d", r), issue.Message)
+		if issue.Cell != "" {
+			f.SetCellHyperLink(importValidationSheet, fmt.Sprintf("B%d", r), fmt.Sprintf("'%s'!%s", report.Sheet, issue.Cell), "Location")
+		}
+		style := warningStyle
+		if issue.Severity == models.ImportIssueError {
+			style = errorStyle
+		}
+		f.SetCellStyle(importValidationSheet, fmt.Sprintf("E%d", r), fmt.Sprintf("E%d", r), style)
+	}
+	f.SetColWidth(importValidationSheet, "A", "B", 8)
+	f.SetColWidth(importValidationSheet, "C", "D", 24)
+	f.SetColWidth(importValidationSheet, "E", "E", 10)
+	f.SetColWidth(importValidationSheet, "F", "F", 70)
+	return nil
+}
+
+// respondImportValidation answers a dry run of an element type import: the validation report, or with
+// format=xlsx the uploaded workbook annotated with it. Nothing is stored or imported.
+func (gjm *GormJobManager) respondImportValidation(c *gin.Context, db *sql.DB, file *multipart.FileHeader, projectID int, userName string) {
+	src, err := file.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to read uploaded file", "details": err.Error()})
+		return
+	}
+	defer src.Close()
+	f, err := excelize.OpenReader(src)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to open Excel file", "details": err.Error()})
+		return
+	}
+	defer f.Close()
+
+	report, err := gjm.validateElementTypeWorkbook(db, f, projectID, file.Filename, userName)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to validate import", "details": err.Error()})
+		return
+	}
+
+	writeImportValidation(c, f, report)
+}
+
+// writeImportValidation writes the report of a dry run, or with format=xlsx the workbook f annotated
+// with it.
+func writeImportValidation(c *gin.Context, f *excelize.File, report models.ImportValidationReport) {
+	if c.Query("format") != "xlsx" {
+		c.JSON(http.StatusOK, report)
+		return
+	}
+	if err := annotateImportWorkbook(f, report); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to annotate Excel file", "details": err.Error()})
+		return
+	}
+	filename := strings.TrimSuffix(filepath.Base(report.FileName), filepath.Ext(report.FileName)) + "_validation.xlsx"
+	filename = strings.ReplaceAll(filename, "\"", "")
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"; filename*=UTF-8''%s", filename, url.PathEscape(filename)))
+	if err := f.Write(c.Writer); err != nil {
+		log.Printf("Failed to write validation workbook: %v", err)
+	}
+}
+
+// Sheets of the annotated workbooks of CSV dry runs
+const (
+	bomImportSheet     = "BOM"
+	precastImportSheet = "Precast"
+)
+
+// Columns the CSV imports require
+var (
+	bomCSVRequiredColumns     = []string{"ProductName", "ProductType", "Unit", "Rate"}
+	precastCSVRequiredColumns = []string{"ID", "ProjectID", "Name", "Description", "ParentID", "Prefix", "Path", "NamingConvention"}
+)
+
+// readImportCSV reads an uploaded CSV for a dry run, decoding the header as the imports do. Rows may have
+// any number of fields, so a row the import would refuse for its width is reported with the others; a
+// line that can't be parsed ends the rows and is returned as the error.
+func readImportCSV(r io.Reader) ([][]string, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	var rows [][]string
+	for {
+		row, err := reader.Read()
+		if err == io.EOF {
+			return rows, nil
+		}
+		if err != nil {
+			return rows, err
+		}
+		if len(rows) == 0 {
+			for i := range row {
+				row[i] = decodeWeirdEncodedText(row[i])
+			}
+		}
+		rows = append(rows, row)
+	}
+}
+
+// csvImportIssues starts the issues of a CSV dry run, whose headers are the first row. It reports an
+// unreadable line and any required column the header lacks; ok is false when no row can be checked.
+func csvImportIssues(rows [][]string, readErr error, required []string) (issues *importIssues, ok bool) {
+	issues = &importIssues{rows: rows}
+	issues.header = func(col int) string {
+		if col < len(rows[0]) {
+			return rows[0][col]
+		}
+		return ""
+	}
+	if readErr != nil {
+		issues.add(-1, -1, models.ImportIssueFile, fmt.Sprintf("Unable to read CSV: %v", readErr))
+	}
+	if len(rows) == 0 {
+		issues.add(-1, -1, models.ImportIssueFile, "CSV file has no header row")
+		return issues, false
+	}
+	ok = true
+	for _, col := range required {
+		if !contains(rows[0], col) {
+			issues.add(-1, -1, models.ImportIssueFile, fmt.Sprintf("missing required column: %s", col))
+			ok = false
+		}
+	}
+	return issues, ok
+}
+
+// validateBOMCSV dry runs the BOM import of a CSV. existing holds the name IDs already in the project's
+// BOM. It reports rows not as wide as the header, empty required fields and products repeated in the
+// file or already in the BOM, all of which fail the import.
+func validateBOMCSV(report models.ImportValidationReport, rows [][]string, readErr error, existing map[string]bool) models.ImportValidationReport {
+	issues, ok := csvImportIssues(rows, readErr, bomCSVRequiredColumns)
+	if !ok {
+		return finishImportValidation(report, issues.list)
+	}
+	columns := make(map[string]int)
+	for i, col := range rows[0] {
+		columns[col] = i
+	}
+
+	seen := make(map[string]int)
+	for i := 1; i < len(rows); i++ {
+		row := rows[i]
+		report.Rows++
+		if len(row) != len(rows[0]) {
+			issues.add(i, -1, models.ImportIssueMissingValue, fmt.Sprintf("Row has %d fields, the header has %d", len(row), len(rows[0])))
+			continue
+		}
+		empty := false
+		for _, col := range []string{"ProductName", "ProductType", "Unit"} {
+			if strings.TrimSpace(row[columns[col]]) == "" {
+				issues.add(i, columns[col], models.ImportIssueMissingValue, col+" is empty")
+				empty = true
+			}
+		}
+		if empty {
+			continue
+		}
+
+		name, productType := strings.TrimSpace(row[columns["ProductName"]]), strings.TrimSpace(row[columns["ProductType"]])
+		nameID := fmt.Sprintf("%s_%s", name, productType)
+		if first, ok := seen[nameID]; ok {
+			issues.add(i, columns["ProductName"], models.ImportIssueDuplicateRecord, fmt.Sprintf("Product %q of type %q is repeated from row %d", name, productType, first+1))
+		} else if existing[nameID] {
+			issues.add(i, columns["ProductName"], models.ImportIssueDuplicateRecord, fmt.Sprintf("Product %q of type %q is already in the project's BOM", name, productType))
+		} else {
+			seen[nameID] = i
+		}
+	}
+	return finishImportValidation(report, issues.list)
+}
+
+// precastCSVIDs are the IDs and parent IDs of a precast CSV, to look up which are already stored.
+func precastCSVIDs(rows [][]string) []int64 {
+	var ids []int64
+	for i := 1; i < len(rows); i++ {
+		for _, col := range []int{0, 4} {
+			if col < len(rows[i]) {
+				if id, err := strconv.ParseInt(rows[i][col], 10, 64); err == nil {
+					ids = append(ids, id)
+				}
+			}
+		}
+	}
+	return ids
+}
+
+// validatePrecastCSV dry runs the precast import of a CSV, which reads the columns by position. existing
+// holds the IDs of precastCSVIDs already stored. It reports rows not as wide as the header, IDs that
+// aren't whole numbers, IDs repeated in the file or already stored, and parents neither in the file nor
+// stored.
+func validatePrecastCSV(report models.ImportValidationReport, rows [][]string, readErr error, existing map[int64]bool) models.ImportValidationReport {
+	issues, ok := csvImportIssues(rows, readErr, precastCSVRequiredColumns)
+	if !ok {
+		return finishImportValidation(report, issues.list)
+	}
+	width := len(rows[0])
+
+	inFile := make(map[int64]bool)
+	for i := 1; i < len(rows); i++ {
+		if len(rows[i]) == width {
+			if id, err := strconv.ParseInt(rows[i][0], 10, 64); err == nil {
+				inFile[id] = true
+			}
+		}
+	}
+
+	seen := make(map[int64]int)
+	for i := 1; i < len(rows); i++ {
+		row := rows[i]
+		report.Rows++
+		if len(row) != width {
+			issues.add(i, -1, models.ImportIssueMissingValue, fmt.Sprintf("Row has %d fields, the header has %d", len(row), width))
+			continue
+		}
+
+		if id, err := strconv.ParseInt(row[0], 10, 64); err != nil {
+			issues.add(i, 0, models.ImportIssueNonNumeric, fmt.Sprintf("ID must be a whole number, not %q", row[0]))
+		} else if first, ok := seen[id]; ok {
+			issues.add(i, 0, models.ImportIssueDuplicateRecord, fmt.Sprintf("ID %d is repeated from row %d", id, first+1))
+		} else if existing[id] {
+			issues.add(i, 0, models.ImportIssueDuplicateRecord, fmt.Sprintf("ID %d is already in the precast structure", id))
+		} else {
+			seen[id] = i
+		}
+
+		if _, err := strconv.Atoi(row[1]); err != nil {
+			issues.add(i, 1, models.ImportIssueNonNumeric, fmt.Sprintf("ProjectID must be a whole number, not %q", row[1]))
+		}
+
+		if row[4] != "" {
+			parentID, err := strconv.ParseInt(row[4], 10, 64)
+			if err != nil {
+				issues.add(i, 4, models.ImportIssueNonNumeric, fmt.Sprintf("ParentID must be a whole number, not %q", row[4]))
+			} else if !inFile[parentID] && !existing[parentID] {
+				issues.add(i, 4, models.ImportIssueUnknownHierarchy, fmt.Sprintf("Parent %d is neither in the file nor in the precast structure", parentID))
+			}
+		}
+	}
+	return finishImportValidation(report, issues.list)
+}
+
+// openImportCSV reads an uploaded CSV for a dry run and copies it into a workbook sheet, for the
+// annotated output. readErr is a line the CSV couldn't be parsed at; err means the upload can't be read.
+func openImportCSV(file *multipart.FileHeader, sheet string) (rows [][]string, readErr error, f *excelize.File, err error) {
+	src, err := file.Open()
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	defer src.Close()
+	rows, readErr = readImportCSV(src)
+
+	f = excelize.NewFile()
+	if err := f.SetSheetName(f.GetSheetName(0), sheet); err != nil {
+		f.Close()
+		return nil, nil, nil, err
+	}
+	for i, row := range rows {
+		cell, _ := excelize.CoordinatesToCellName(1, i+1)
+		values := make([]interface{}, len(row))
+		for j, value := range row {
+			values[j] = value
+		}
+		if err := f.SetSheetRow(sheet, cell, &values); err != nil {
+			f.Close()
+			return nil, nil, nil, err
+		}
+	}
+	return rows, readErr, f, nil
+}
+
+// respondBOMImportValidation answers a dry run of a BOM CSV import: the validation report, or with
+// format=xlsx the CSV as a workbook annotated with it. Nothing is imported.
+func respondBOMImportValidation(c *gin.Context, db *sql.DB, file *multipart.FileHeader, projectID int) {
+	rows, readErr, f, err := openImportCSV(file, bomImportSheet)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to read uploaded file", "details": err.Error()})
+		return
+	}
+	defer f.Close()
+
+	existing := make(map[string]bool)
+	existingRows, err := db.Query(`SELECT name_id FROM inv_bom WHERE project_id = $1`, projectID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch BOM", "details": err.Error()})
+		return
+	}
+	defer existingRows.Close()
+	for existingRows.Next() {
+		var nameID sql.NullString
+		if err := existingRows.Scan(&nameID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read BOM", "details": err.Error()})
+			return
+		}
+		existing[nameID.String] = true
+	}
+
+	report := validateBOMCSV(models.ImportValidationReport{
+		ProjectID: projectID,
+		FileName:  file.Filename,
+		Sheet:     bomImportSheet,
+		Issues:    []models.ImportValidationIssue{},
+	}, rows, readErr, existing)
+	writeImportValidation(c, f, report)
+}
+
+// respondPrecastImportValidation answers a dry run of a precast CSV import: the validation report, or
+// with format=xlsx the CSV as a workbook annotated with it. Nothing is imported.
+func respondPrecastImportValidation(c *gin.Context, db *sql.DB, file *multipart.FileHeader, projectID int) {
+	rows, readErr, f, err := openImportCSV(file, precastImportSheet)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "Unable to read uploaded file", "details": err.Error()})
+		return
+	}
+	defer f.Close()
+
+	existing := make(map[int64]bool)
+	if ids := precastCSVIDs(rows); len(ids) > 0 {
+		existingRows, err := db.Query(`SELECT id FROM precast WHERE id = ANY($1)`, pq.Array(ids))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch precast structure", "details": err.Error()})
+			return
+		}
+		defer existingRows.Close()
+		for existingRows.Next() {
+			var id int64
+			if err := existingRows.Scan(&id); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read precast structure", "details": err.Error()})
+				return
+			}
+			existing[id] = true
+		}
+	}
+
+	report := validatePrecastCSV(models.ImportValidationReport{
+		ProjectID: projectID,
+		FileName:  file.Filename,
+		Sheet:     precastImportSheet,
+		Issues:    []models.ImportValidationIssue{},
+	}, rows, readErr, existing)
+	writeImportValidation(c, f, report)
+}
@@ -0,0 +1,131 @@
+package handlers
+
+import (
+	"backend/models"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// issueSummary is an issue as "cell code", "#row code" for the whole row, or "code" for the file
+func issueSummary(issues []models.ImportValidationIssue) []string {
+	out := []string{}
+	for _, issue := range issues {
+		switch {
+		case issue.Cell != "":
+			out = append(out, issue.Cell+" "+issue.Code)
+		case issue.Row > 0:
+			out = append(out, fmt.Sprintf("#%d %s", issue.Row, issue.Code))
+		default:
+			out = append(out, issue.Code)
+		}
+	}
+	return out
+}
+
+func TestReadImportCSV(t *testing.T) {
+	rows, err := readImportCSV(strings.NewReader("ProductName,ProductType,Unit,Rate\nBolt,Steel,nos\n\nNut,Steel,nos,2\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := [][]string{{"ProductName", "ProductType", "Unit", "Rate"}, {"Bolt", "Steel", "nos"}, {"Nut", "Steel", "nos", "2"}}
+	if !reflect.DeepEqual(rows, want) {
+		t.Errorf("rows = %q, want %q", rows, want)
+	}
+
+	rows, err = readImportCSV(strings.NewReader("ID,Name\n1,\"open\n"))
+	if err == nil {
+		t.Error("an unterminated quote was accepted")
+	}
+	if len(rows) != 1 {
+		t.Errorf("rows before the bad line = %q", rows)
+	}
+}
+
+func TestValidateBOMCSV(t *testing.T) {
+	header := []string{"ProductName", "ProductType", "Unit", "Rate"}
+	tests := []struct {
+		name      string
+		rows      [][]string
+		want      []string
+		wantRows  int
+		wantValid int
+	}{
+		{
+			name:      "valid",
+			rows:      [][]string{header, {"Bolt", "Steel", "nos", "4"}, {"Bolt", "Galvanised", "nos", "5"}},
+			want:      []string{},
+			wantRows:  2,
+			wantValid: 2,
+		},
+		{
+			name: "row of another width, empty fields and duplicates",
+			rows: [][]string{header,
+				{"Bolt", "Steel", "nos", "4"},
+				{"Nut", "Steel"},
+				{" ", "Steel", "", "1"},
+				{" Bolt ", "Steel", "nos", "4"},
+				{"Anchor", "Cast-in", "nos", "9"},
+			},
+			want:      []string{"#3 missing_value", "A4 missing_value", "C4 missing_value", "A5 duplicate_record", "A6 duplicate_record"},
+			wantRows:  5,
+			wantValid: 1,
+		},
+		{
+			name: "missing column",
+			rows: [][]string{{"ProductName", "ProductType", "Unit"}, {"Bolt", "Steel", "nos"}},
+			want: []string{"file"},
+		},
+	}
+	existing := map[string]bool{"Anchor_Cast-in": true}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			report := validateBOMCSV(models.ImportValidationReport{Sheet: bomImportSheet}, tt.rows, nil, existing)
+			if got := issueSummary(report.Issues); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("issues = %q, want %q", got, tt.want)
+			}
+			if report.Rows != tt.wantRows || report.ValidRows != tt.wantValid || report.Valid != (len(tt.want) == 0) {
+				t.Errorf("rows %d valid %d (%v), want %d valid %d", report.Rows, report.ValidRows, report.Valid, tt.wantRows, tt.wantValid)
+			}
+		})
+	}
+}
+
+func TestValidatePrecastCSV(t *testing.T) {
+	header := precastCSVRequiredColumns
+	rows := [][]string{header,
+		{"10", "1", "Tower A", "", "", "TA", "10", "TA"},
+		{"11", "1", "Floor 1", "", "10", "F1", "10.11", "F1"},
+		{"12", "1", "Floor 2", "", "5", "F2", "10.12", "F2"},
+		{"11", "1", "Floor 1", "", "10", "F1", "10.11", "F1"},
+		{"x", "one", "Floor 3", "", "ten", "F3", "10.13", "F3"},
+		{"13", "1", "Floor 4", "", "99", "F4", "10.14", "F4"},
+		{"7", "1", "Tower B", "", ""},
+	}
+	if got, want := precastCSVIDs(rows), []int64{10, 11, 10, 12, 5, 11, 10, 13, 99, 7}; !reflect.DeepEqual(got, want) {
+		t.Errorf("ids = %v, want %v", got, want)
+	}
+
+	report := validatePrecastCSV(models.ImportValidationReport{Sheet: precastImportSheet}, rows, nil, map[int64]bool{5: true, 10: false, 13: true})
+	want := []string{"A5 duplicate_record", "A6 non_numeric", "B6 non_numeric", "E6 non_numeric", "A7 duplicate_record", "E7 unknown_hierarchy", "#8 missing_value"}
+	if got := issueSummary(report.Issues); !reflect.DeepEqual(got, want) {
+		t.Errorf("issues = %q, want %q", got, want)
+	}
+	if report.Rows != 7 || report.RowsWithErrors != 4 || report.Valid {
+		t.Errorf("rows %d with errors %d valid %v", report.Rows, report.RowsWithErrors, report.Valid)
+	}
+}
+
+func TestValidateCSVFileIssues(t *testing.T) {
+	report := validatePrecastCSV(models.ImportValidationReport{}, nil, nil, nil)
+	if got := issueSummary(report.Issues); !reflect.DeepEqual(got, []string{"file"}) || report.Valid {
+		t.Errorf("empty file: issues = %q, valid %v", got, report.Valid)
+	}
+
+	rows, readErr := readImportCSV(strings.NewReader("ProductName,ProductType,Unit,Rate\nBolt,Steel,nos,4\nNut,\"Steel\n"))
+	report = validateBOMCSV(models.ImportValidationReport{}, rows, readErr, nil)
+	if got := issueSummary(report.Issues); !reflect.DeepEqual(got, []string{"file"}) || report.Rows != 1 {
+		t.Errorf("unreadable line: issues = %q, rows %d", got, report.Rows)
+	}
+}
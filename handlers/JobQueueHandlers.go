@@ -0,0 +1,784 @@
+package handlers
+
+import (
+	"backend/models"
+	"context"
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// Timings of the job queue
+const (
+	jobLeaseDuration      = 2 * time.Minute  // a job whose lease runs out is taken over by another worker
+	jobHeartbeatInterval  = 20 * time.Second // how often a running job renews its lease
+	jobPollInterval       = 5 * time.Second
+	jobRecoverInterval    = time.Minute
+	jobRetryBaseDelay     = 30 * time.Second // first retry, doubled for every further attempt
+	jobRetryMaxDelay      = 30 * time.Minute
+	defaultJobMaxAttempts = 3
+	maxJobQueueList       = 200
+)
+
+// Errors a job handler returns to tell the queue how the job ended
+var (
+	// ErrJobCancelled ends the job as cancelled
+	ErrJobCancelled = errors.New("job cancelled")
+	// ErrJobInterrupted hands the job back to the queue without using up an attempt, e.g. on shutdown
+	ErrJobInterrupted = errors.New("job interrupted")
+	// ErrJobLeaseLost is returned by checkpoints once another worker has taken the job over
+	ErrJobLeaseLost = errors.New("job lease lost")
+)
+
+// permanentJobError is an error retrying won't fix
+type permanentJobError struct{ err error }
+
+func (e permanentJobError) Error() string { return e.err.Error() }
+func (e permanentJobError) Unwrap() error { return e.err }
+
+// PermanentJobError marks an error as one retrying won't fix, so the job fails straight away.
+func PermanentJobError(err error) error {
+	return permanentJobError{err}
+}
+
+const selectQueuedJobSQL = `
+	SELECT id, kind, project_id, status, attempts, max_attempts, run_after, COALESCE(lease_owner, ''),
+		lease_expires_at, heartbeat_at, cancel_requested, import_job_id, payload, checkpoint, last_error,
+		created_by, created_at, updated_at, started_at, finished_at
+	FROM job_queue`
+
+// scanQueuedJob reads a row of selectQueuedJobSQL.
+func scanQueuedJob(scan func(dest ...interface{}) error) (models.QueuedJob, error) {
+	var job models.QueuedJob
+	var importJobID sql.NullInt64
+	var payload, checkpoint []byte
+	err := scan(&job.ID, &job.Kind, &job.ProjectID, &job.Status, &job.Attempts, &job.MaxAttempts, &job.RunAfter,
+		&job.LeaseOwner, &job.LeaseExpiresAt, &job.HeartbeatAt, &job.CancelRequested, &importJobID, &payload,
+		&checkpoint, &job.LastError, &job.CreatedBy, &job.CreatedAt, &job.UpdatedAt, &job.StartedAt, &job.FinishedAt)
+	if importJobID.Valid {
+		id := int(importJobID.Int64)
+		job.ImportJobID = &id
+	}
+	job.Payload = payload
+	if len(checkpoint) > 0 {
+		job.Checkpoint = checkpoint
+	}
+	return job, err
+}
+
+// JobSpec describes a job to enqueue
+type JobSpec struct {
+	Kind        string
+	ProjectID   int
+	Payload     interface{}
+	MaxAttempts int  // default 3
+	ImportJobID *int // import_jobs row the job reports to, if any
+	CreatedBy   string
+}
+
+// EnqueueJob adds a job to the queue. Pass a transaction to enqueue together with other changes; the job
+// only becomes visible to workers when it commits.
+func EnqueueJob(q sqlQueryer, spec JobSpec) (int64, error) {
+	payload, err := json.Marshal(spec.Payload)
+	if err != nil {
+		return 0, fmt.Errorf("failed to encode job payload: %v", err)
+	}
+	if spec.MaxAttempts <= 0 {
+		spec.MaxAttempts = defaultJobMaxAttempts
+	}
+	var id int64
+	err = q.QueryRow(`
+		INSERT INTO job_queue (kind, project_id, max_attempts, import_job_id, payload, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+		spec.Kind, spec.ProjectID, spec.MaxAttempts, spec.ImportJobID, payload, spec.CreatedBy).Scan(&id)
+	if err != nil {
+		return 0, err
+	}
+	wakeJobQueue()
+	return id, nil
+}
+
+// CancelQueuedJobs asks the jobs reporting to an import job to stop. Jobs that haven't started are
+// cancelled at once; running ones stop at their next heartbeat or checkpoint.
+func CancelQueuedJobs(q sqlQueryer, importJobID int) error {
+	_, err := q.Exec(`
+		UPDATE job_queue
+		SET cancel_requested = TRUE,
+			status = CASE WHEN status = 'queued' THEN 'cancelled' ELSE status END,
+			finished_at = CASE WHEN status = 'queued' THEN NOW() ELSE finished_at END,
+			updated_at = NOW()
+		WHERE import_job_id = $1 AND status IN ('queued', 'running')`, importJobID)
+	return err
+}
+
+// JobHandler runs a job. It returns nil when the job is done, ErrJobCancelled or ErrJobInterrupted to
+// say it stopped, a PermanentJobError when retrying is pointless, and any other error to be retried.
+type JobHandler func(ctx context.Context, run *JobRun) error
+
+// JobRun is a job leased by this server
+type JobRun struct {
+	models.QueuedJob
+	queue *JobQueue
+}
+
+// LoadCheckpoint decodes the last saved checkpoint into v. It reports false when the job starts fresh.
+func (r *JobRun) LoadCheckpoint(v interface{}) (bool, error) {
+	if len(r.Checkpoint) == 0 {
+		return false, nil
+	}
+	return true, json.Unmarshal(r.Checkpoint, v)
+}
+
+// SaveCheckpoint records how far the job got and renews its lease. Pass the transaction that did the
+// work, so the work and the checkpoint commit together. It returns ErrJobLeaseLost when the job has
+// been taken over, in which case the work must be rolled back.
+func (r *JobRun) SaveCheckpoint(q sqlQueryer, v interface{}) error {
+	checkpoint, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Errorf("failed to encode checkpoint: %v", err)
+	}
+	res, err := q.Exec(`
+		UPDATE job_queue
+		SET checkpoint = $1, heartbeat_at = NOW(), lease_expires_at = NOW() + $2 * INTERVAL '1 second', updated_at = NOW()
+		WHERE id = $3 AND lease_owner = $4 AND status = 'running'`,
+		checkpoint, jobLeaseDuration.Seconds(), r.ID, r.queue.owner)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrJobLeaseLost
+	}
+	r.Checkpoint = checkpoint
+	return nil
+}
+
+// JobQueue runs the jobs of the durable queue. Every server runs one; they share the work through
+// leases on the job rows.
+type JobQueue struct {
+	db       *sql.DB
+	owner    string
+	workers  int
+	handlers map[string]JobHandler
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+	wake   chan struct{}
+}
+
+// defaultJobQueue is the queue of this server, woken when a job is enqueued
+var (
+	defaultJobQueue   *JobQueue
+	defaultJobQueueMu sync.RWMutex
+)
+
+// NewJobQueue creates a job queue running up to workers jobs at a time.
+func NewJobQueue(db *sql.DB, workers int) *JobQueue {
+	if workers < 1 {
+		workers = 1
+	}
+	host, err := os.Hostname()
+	if err != nil || host == "" {
+		host = "server"
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	return &JobQueue{
+		db:       db,
+		owner:    fmt.Sprintf("%s:%d:%d", host, os.Getpid(), time.Now().UnixNano()),
+		workers:  workers,
+		handlers: make(map[string]JobHandler),
+		ctx:      ctx,
+		cancel:   cancel,
+		wake:     make(chan struct{}, 1),
+	}
+}
+
+// Register sets the handler of a kind of job. Only registered kinds are picked up, so register before
+// Start.
+func (jq *JobQueue) Register(kind string, handler JobHandler) {
+	jq.handlers[kind] = handler
+}
+
+// wakeJobQueue makes the queue look for work now rather than at its next poll.
+func wakeJobQueue() {
+	defaultJobQueueMu.RLock()
+	defer defaultJobQueueMu.RUnlock()
+	if defaultJobQueue == nil {
+		return
+	}
+	select {
+	case defaultJobQueue.wake <- struct{}{}:
+	default:
+	}
+}
+
+// Start recovers the jobs left behind by stopped servers and starts taking jobs. A job held by a server
+// that died is taken over once its lease runs out: owners are unique to a process, and a live process
+// on the same host looks no different from a dead one.
+func (jq *JobQueue) Start() error {
+	jq.recover()
+
+	defaultJobQueueMu.Lock()
+	defaultJobQueue = jq
+	defaultJobQueueMu.Unlock()
+
+	jq.wg.Add(1)
+	go jq.loop()
+	log.Printf("Job queue started as %s with %d workers", jq.owner, jq.workers)
+	return nil
+}
+
+// Stop stops taking jobs and hands the running ones back to the queue, waiting up to timeout for them.
+func (jq *JobQueue) Stop(timeout time.Duration) error {
+	jq.cancel()
+	done := make(chan struct{})
+	go func() {
+		jq.wg.Wait()
+		close(done)
+	}()
+	select {
+	case <-done:
+		log.Printf("Job queue stopped")
+		return nil
+	case <-time.After(timeout):
+		return fmt.Errorf("job queue stop timed out after %v", timeout)
+	}
+}
+
+// recover ends the jobs whose lease ran out while they were being cancelled or on their last attempt,
+// and fails the import jobs left processing with nothing in the queue to finish them.
+func (jq *JobQueue) recover() {
+	if _, err := jq.db.Exec(`
+		UPDATE job_queue
+		SET status = 'cancelled', lease_owner = NULL, lease_expires_at = NULL, finished_at = NOW(), updated_at = NOW()
+		WHERE status = 'running' AND cancel_requested AND lease_expires_at < NOW()`); err != nil {
+		log.Printf("Job queue: failed to recover cancelled jobs: %v", err)
+	}
+
+	rows, err := jq.db.Query(`
+		UPDATE job_queue
+		SET status = 'failed', last_error = 'The job stopped responding on every attempt',
+			lease_owner = NULL, lease_expires_at = NULL, finished_at = NOW(), updated_at = NOW()
+		WHERE status = 'running' AND lease_expires_at < NOW() AND attempts >= max_attempts
+		RETURNING import_job_id`)
+	if err != nil {
+		log.Printf("Job queue: failed to recover expired jobs: %v", err)
+		return
+	}
+	for rows.Next() {
+		var importJobID sql.NullInt64
+		if err := rows.Scan(&importJobID); err == nil && importJobID.Valid {
+			failImportJob(jq.db, int(importJobID.Int64), "The import stopped responding on every attempt")
+		}
+	}
+	rows.Close()
+
+	res, err := jq.db.Exec(`
+		UPDATE import_jobs
+		SET status = 'failed', error = 'The server stopped while the import was running and it could not be resumed',
+			completed_at = NOW(), updated_at = NOW()
+		WHERE status = 'processing'
+			AND updated_at < NOW() - $1 * INTERVAL '1 second'
+			AND NOT EXISTS (
+				SELECT 1 FROM job_queue q WHERE q.import_job_id = import_jobs.id AND q.status IN ('queued', 'running')
+			)`, jobLeaseDuration.Seconds())
+	if err != nil {
+		log.Printf("Job queue: failed to recover import jobs: %v", err)
+	} else if n, _ := res.RowsAffected(); n > 0 {
+		log.Printf("Job queue: failed %d import jobs left processing", n)
+	}
+}
+
+// failImportJob marks an import job failed unless it already ended.
+func failImportJob(q sqlQueryer, importJobID int, message string) {
+	if _, err := q.Exec(`
+		UPDATE import_jobs SET status = 'failed', error = $1, completed_at = NOW(), updated_at = NOW()
+		WHERE id = $2 AND status IN ('pending', 'processing')`, message, importJobID); err != nil {
+		log.Printf("Job queue: failed to update import job %d: %v", importJobID, err)
+	}
+}
+
+// loop takes jobs while there are free workers.
+func (jq *JobQueue) loop() {
+	defer jq.wg.Done()
+	poll := time.NewTicker(jobPollInterval)
+	defer poll.Stop()
+	recoverTicker := time.NewTicker(jobRecoverInterval)
+	defer recoverTicker.Stop()
+
+	slots := make(chan struct{}, jq.workers)
+	for {
+		for {
+			if jq.ctx.Err() != nil {
+				return
+			}
+			select {
+			case slots <- struct{}{}:
+			default:
+				goto wait
+			}
+			run, err := jq.claim()
+			if err != nil {
+				log.Printf("Job queue: failed to claim job: %v", err)
+			}
+			if run == nil {
+				<-slots
+				break
+			}
+			jq.wg.Add(1)
+			go func() {
+				defer func() {
+					<-slots
+					jq.wg.Done()
+					// A finished job frees a worker for the next one
+					select {
+					case jq.wake <- struct{}{}:
+					default:
+					}
+				}()
+				jq.run(run)
+			}()
+		}
+	wait:
+		select {
+		case <-jq.ctx.Done():
+			return
+		case <-jq.wake:
+		case <-poll.C:
+		case <-recoverTicker.C:
+			jq.recover()
+		}
+	}
+}
+
+// claim leases the next job that is due, or a running job whose lease ran out.
+func (jq *JobQueue) claim() (*JobRun, error) {
+	kinds := make([]string, 0, len(jq.handlers))
+	for kind := range jq.handlers {
+		kinds = append(kinds, kind)
+	}
+	if len(kinds) == 0 {
+		return nil, nil
+	}
+	row := jq.db.QueryRow(`
+		UPDATE job_queue
+		SET status = 'running', lease_owner = $1, lease_expires_at = NOW() + $2 * INTERVAL '1 second',
+			heartbeat_at = NOW(), attempts = attempts + 1, started_at = COALESCE(started_at, NOW()), updated_at = NOW()
+		WHERE id = (
+			SELECT id FROM job_queue
+			WHERE kind = ANY($3) AND NOT cancel_requested
+				AND ((status = 'queued' AND run_after <= NOW()) OR (status = 'running' AND lease_expires_at < NOW()))
+			ORDER BY run_after, id
+			FOR UPDATE SKIP LOCKED
+			LIMIT 1
+		)
+		RETURNING id`, jq.owner, jobLeaseDuration.Seconds(), pq.Array(kinds))
+	var id int64
+	if err := row.Scan(&id); err == sql.ErrNoRows {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	job, err := scanQueuedJob(jq.db.QueryRow(selectQueuedJobSQL+` WHERE id = $1`, id).Scan)
+	if err != nil {
+		return nil, err
+	}
+	return &JobRun{QueuedJob: job, queue: jq}, nil
+}
+
+// run runs a leased job, keeping its lease alive, and records how it ended.
+func (jq *JobQueue) run(r *JobRun) {
+	if r.Attempts > r.MaxAttempts {
+		// Taken over after its lease ran out once too often, most likely because it brings the server down
+		jq.finish(r, PermanentJobError(errors.New("the job stopped responding on every attempt")), false)
+		return
+	}
+
+	ctx, cancel := context.WithCancel(jq.ctx)
+	defer cancel()
+	var mu sync.Mutex
+	cancelled, leaseLost := false, false
+
+	heartbeatDone := make(chan struct{})
+	go func() {
+		defer close(heartbeatDone)
+		ticker := time.NewTicker(jobHeartbeatInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+			var cancelRequested bool
+			err := jq.db.QueryRow(`
+				UPDATE job_queue SET heartbeat_at = NOW(), lease_expires_at = NOW() + $1 * INTERVAL '1 second', updated_at = NOW()
+				WHERE id = $2 AND lease_owner = $3 AND status = 'running'
+				RETURNING cancel_requested`, jobLeaseDuration.Seconds(), r.ID, jq.owner).Scan(&cancelRequested)
+			mu.Lock()
+			switch {
+			case err == sql.ErrNoRows:
+				leaseLost = true
+			case err != nil:
+				log.Printf("Job queue: heartbeat of job %d failed: %v", r.ID, err)
+			case cancelRequested:
+				cancelled = true
+			}
+			stop := leaseLost || cancelled
+			mu.Unlock()
+			if stop {
+				cancel()
+				return
+			}
+		}
+	}()
+
+	log.Printf("Job queue: running job %d (%s), attempt %d of %d", r.ID, r.Kind, r.Attempts, r.MaxAttempts)
+	err := jq.call(ctx, r)
+	cancel()
+	<-heartbeatDone
+
+	mu.Lock()
+	defer mu.Unlock()
+	if leaseLost || errors.Is(err, ErrJobLeaseLost) {
+		log.Printf("Job queue: job %d was taken over by another worker", r.ID)
+		return
+	}
+	if cancelled && err != nil {
+		err = ErrJobCancelled
+	}
+	jq.finish(r, err, jq.ctx.Err() != nil)
+}
+
+// call runs the handler of a job, turning a panic into an error.
+func (jq *JobQueue) call(ctx context.Context, r *JobRun) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			log.Printf("Job queue: job %d panicked: %v\n%s", r.ID, p, debug.Stack())
+			err = fmt.Errorf("job panicked: %v", p)
+		}
+	}()
+	handler, ok := jq.handlers[r.Kind]
+	if !ok {
+		return PermanentJobError(fmt.Errorf("no handler for job kind %s", r.Kind))
+	}
+	return handler(ctx, r)
+}
+
+// retryDelay is the backoff before the next attempt after the given number of attempts.
+func retryDelay(attempts int) time.Duration {
+	delay := jobRetryBaseDelay
+	for i := 1; i < attempts && delay < jobRetryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > jobRetryMaxDelay {
+		delay = jobRetryMaxDelay
+	}
+	return delay
+}
+
+// Ways a run ends, as decided by jobOutcome
+const (
+	jobOutcomeCompleted  = "completed"
+	jobOutcomeCancelled  = "cancelled"
+	jobOutcomeHandedBack = "handed_back" // queued again at once, the attempt doesn't count
+	jobOutcomeRetry      = "retry"       // queued again after retryDelay
+	jobOutcomeFailed     = "failed"
+)
+
+// jobOutcome decides how a run that returned err ends, given the attempts made so far.
+func jobOutcome(err error, stopping bool, attempts, maxAttempts int) string {
+	var permanent permanentJobError
+	switch {
+	case err == nil:
+		return jobOutcomeCompleted
+	case errors.Is(err, ErrJobCancelled):
+		return jobOutcomeCancelled
+	case errors.Is(err, ErrJobInterrupted) || (stopping && !errors.As(err, &permanent)):
+		return jobOutcomeHandedBack
+	case errors.As(err, &permanent) || attempts >= maxAttempts:
+		return jobOutcomeFailed
+	}
+	return jobOutcomeRetry
+}
+
+// finish records how a run ended: done, cancelled, handed back, retried later or failed.
+func (jq *JobQueue) finish(r *JobRun, err error, stopping bool) {
+	var res sql.Result
+	var dbErr error
+	switch jobOutcome(err, stopping, r.Attempts, r.MaxAttempts) {
+	case jobOutcomeCompleted:
+		res, dbErr = jq.db.Exec(`
+			UPDATE job_queue SET status = 'completed', last_error = '', lease_owner = NULL, lease_expires_at = NULL,
+				finished_at = NOW(), updated_at = NOW()
+			WHERE id = $1 AND lease_owner = $2`, r.ID, jq.owner)
+		log.Printf("Job queue: job %d completed", r.ID)
+	case jobOutcomeCancelled:
+		res, dbErr = jq.db.Exec(`
+			UPDATE job_queue SET status = 'cancelled', lease_owner = NULL, lease_expires_at = NULL,
+				finished_at = NOW(), updated_at = NOW()
+			WHERE id = $1 AND lease_owner = $2`, r.ID, jq.owner)
+		log.Printf("Job queue: job %d cancelled", r.ID)
+	case jobOutcomeHandedBack:
+		res, dbErr = jq.db.Exec(`
+			UPDATE job_queue SET status = 'queued', attempts = GREATEST(attempts - 1, 0), lease_owner = NULL,
+				lease_expires_at = NULL, run_after = NOW(), updated_at = NOW()
+			WHERE id = $1 AND lease_owner = $2`, r.ID, jq.owner)
+		log.Printf("Job queue: job %d handed back to the queue", r.ID)
+	case jobOutcomeFailed:
+		res, dbErr = jq.db.Exec(`
+			UPDATE job_queue SET status = 'failed', last_error = $3, lease_owner = NULL, lease_expires_at = NULL,
+				finished_at = NOW(), updated_at = NOW()
+			WHERE id = $1 AND lease_owner = $2`, r.ID, jq.owner, err.Error())
+		if r.ImportJobID != nil {
+			failImportJob(jq.db, *r.ImportJobID, err.Error())
+		}
+		log.Printf("Job queue: job %d failed after %d attempts: %v", r.ID, r.Attempts, err)
+	default:
+		delay := retryDelay(r.Attempts)
+		res, dbErr = jq.db.Exec(`
+			UPDATE job_queue SET status = 'queued', last_error = $3, lease_owner = NULL, lease_expires_at = NULL,
+				run_after = NOW() + $4 * INTERVAL '1 second', updated_at = NOW()
+			WHERE id = $1 AND lease_owner = $2`, r.ID, jq.owner, err.Error(), delay.Seconds())
+		log.Printf("Job queue: job %d failed on attempt %d, retrying in %v: %v", r.ID, r.Attempts, delay, err)
+	}
+	if dbErr != nil {
+		log.Printf("Job queue: failed to record the end of job %d: %v", r.ID, dbErr)
+	} else if n, _ := res.RowsAffected(); n == 0 {
+		log.Printf("Job queue: job %d was taken over before it ended", r.ID)
+	}
+}
+
+// queuedJobParam reads the job named by the :id path parameter, writing the error response when it
+// can't.
+func queuedJobParam(c *gin.Context, db *sql.DB) (job models.QueuedJob, ok bool) {
+	id, err := strconv.ParseInt(c.Param("id"), 10, 64)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a valid integer"})
+		return job, false
+	}
+	job, err = scanQueuedJob(db.QueryRow(selectQueuedJobSQL+` WHERE id = $1`, id).Scan)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Job not found"})
+		return job, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch job", "details": err.Error()})
+		return job, false
+	}
+	return job, true
+}
+
+// saveJobQueueActivity writes the activity log of a job queue action.
+func saveJobQueueActivity(db *sql.DB, session models.Session, userName, eventName, description string, projectID int) {
+	activityLog := models.ActivityLog{
+		EventContext: "JobQueue",
+		EventName:    eventName,
+		Description:  description,
+		UserName:     userName,
+		HostName:     session.HostName,
+		IPAddress:    session.IPAddress,
+		CreatedAt:    time.Now(),
+		ProjectID:    projectID,
+	}
+	if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+		log.Printf("Failed to save activity log: %v", logErr)
+	}
+}
+
+// GetQueuedJobs lists the jobs of the durable job queue, newest first.
+// @Summary List queued jobs
+// @Tags Jobs
+// @Produce json
+// @Param project_id query int false "Project ID"
+// @Param status query string false "queued, running, completed, failed or cancelled"
+// @Param kind query string false "Job kind"
+// @Success 200 {array} models.QueuedJob
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/job_queue [get]
+func GetQueuedJobs(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+		where := " WHERE TRUE"
+		var args []interface{}
+		if projectID := c.Query("project_id"); projectID != "" {
+			id, err := strconv.Atoi(projectID)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+				return
+			}
+			args = append(args, id)
+			where += fmt.Sprintf(" AND project_id = $%d", len(args))
+		}
+		if status := c.Query("status"); status != "" {
+			args = append(args, status)
+			where += fmt.Sprintf(" AND status = $%d", len(args))
+		}
+		if kind := c.Query("kind"); kind != "" {
+			args = append(args, kind)
+			where += fmt.Sprintf(" AND kind = $%d", len(args))
+		}
+
+		rows, err := db.Query(selectQueuedJobSQL+where+fmt.Sprintf(" ORDER BY id DESC LIMIT %d", maxJobQueueList), args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch jobs", "details": err.Error()})
+			return
+		}
+		defer rows.Close()
+		jobs := []models.QueuedJob{}
+		for rows.Next() {
+			job, err := scanQueuedJob(rows.Scan)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read job", "details": err.Error()})
+				return
+			}
+			jobs = append(jobs, job)
+		}
+		c.JSON(http.StatusOK, jobs)
+	}
+}
+
+// GetQueuedJob returns a job of the durable job queue with its checkpoint.
+// @Summary Get queued job
+// @Tags Jobs
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} models.QueuedJob
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/job_queue/{id} [get]
+func GetQueuedJob(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+		job, ok := queuedJobParam(c, db)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, job)
+	}
+}
+
+// CancelQueuedJob cancels a job. A job that hasn't started is cancelled at once; a running job stops at
+// its next heartbeat or checkpoint.
+// @Summary Cancel queued job
+// @Tags Jobs
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} models.QueuedJob
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/job_queue/{id}/cancel [post]
+func CancelQueuedJob(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+		job, ok := queuedJobParam(c, db)
+		if !ok {
+			return
+		}
+		if job.Status != models.JobStatusQueued && job.Status != models.JobStatusRunning {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Job is already %s", job.Status)})
+			return
+		}
+
+		if _, err := db.Exec(`
+			UPDATE job_queue
+			SET cancel_requested = TRUE,
+				status = CASE WHEN status = 'queued' THEN 'cancelled' ELSE status END,
+				finished_at = CASE WHEN status = 'queued' THEN NOW() ELSE finished_at END,
+				updated_at = NOW()
+			WHERE id = $1 AND status IN ('queued', 'running')`, job.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel job", "details": err.Error()})
+			return
+		}
+		if job.Status == models.JobStatusQueued && job.ImportJobID != nil {
+			if _, err := db.Exec(`
+				UPDATE import_jobs SET status = 'cancelled', error = 'Job cancelled by user', completed_at = NOW(), updated_at = NOW()
+				WHERE id = $1 AND status IN ('pending', 'processing')`, *job.ImportJobID); err != nil {
+				log.Printf("Failed to cancel import job %d: %v", *job.ImportJobID, err)
+			}
+		}
+
+		job, ok = queuedJobParam(c, db)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, job)
+
+		saveJobQueueActivity(db, session, userName, "Cancel",
+			fmt.Sprintf("Cancelled %s job %d", job.Kind, job.ID), job.ProjectID)
+	}
+}
+
+// RetryQueuedJob queues a failed job again with fresh attempts. It resumes from its last checkpoint.
+// @Summary Retry failed job
+// @Tags Jobs
+// @Produce json
+// @Param id path int true "Job ID"
+// @Success 200 {object} models.QueuedJob
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/job_queue/{id}/retry [post]
+func RetryQueuedJob(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+		job, ok := queuedJobParam(c, db)
+		if !ok {
+			return
+		}
+		if job.Status != models.JobStatusFailed {
+			c.JSON(http.StatusConflict, gin.H{"error": "Only failed jobs can be retried"})
+			return
+		}
+
+		if _, err := db.Exec(`
+			UPDATE job_queue
+			SET status = 'queued', attempts = 0, run_after = NOW(), cancel_requested = FALSE, finished_at = NULL,
+				updated_at = NOW()
+			WHERE id = $1 AND status = 'failed'`, job.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to retry job", "details": err.Error()})
+			return
+		}
+		if job.ImportJobID != nil {
+			if _, err := db.Exec(`UPDATE import_jobs SET status = 'pending', error = NULL, completed_at = NULL, updated_at = NOW() WHERE id = $1`,
+				*job.ImportJobID); err != nil {
+				log.Printf("Failed to reset import job %d: %v", *job.ImportJobID, err)
+			}
+		}
+		wakeJobQueue()
+
+		job, ok = queuedJobParam(c, db)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, job)
+
+		saveJobQueueActivity(db, session, userName, "Retry",
+			fmt.Sprintf("Retried %s job %d", job.Kind, job.ID), job.ProjectID)
+	}
+}
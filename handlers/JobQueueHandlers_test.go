@@ -0,0 +1,98 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestRetryDelay(t *testing.T) {
+	tests := []struct {
+		attempts int
+		want     time.Duration
+	}{
+		{0, 30 * time.Second},
+		{1, 30 * time.Second},
+		{2, time.Minute},
+		{3, 2 * time.Minute},
+		{6, 16 * time.Minute},
+		{7, 30 * time.Minute},
+		{50, 30 * time.Minute},
+	}
+	for _, tt := range tests {
+		if got := retryDelay(tt.attempts); got != tt.want {
+			t.Errorf("retryDelay(%d) = %v, want %v", tt.attempts, got, tt.want)
+		}
+	}
+}
+
+func TestJobOutcome(t *testing.T) {
+	transient := errors.New("connection reset")
+	permanent := PermanentJobError(errors.New("Sheet 'Element Types' not found in Excel file"))
+
+	tests := []struct {
+		name     string
+		err      error
+		stopping bool
+		attempts int
+		want     string
+	}{
+		{"done", nil, false, 1, jobOutcomeCompleted},
+		{"done while stopping", nil, true, 3, jobOutcomeCompleted},
+		{"cancelled", ErrJobCancelled, false, 1, jobOutcomeCancelled},
+		{"cancelled while stopping", ErrJobCancelled, true, 1, jobOutcomeCancelled},
+		{"interrupted", ErrJobInterrupted, false, 3, jobOutcomeHandedBack},
+		{"wrapped interruption", fmt.Errorf("batch 4: %w", ErrJobInterrupted), false, 1, jobOutcomeHandedBack},
+		{"transient error while stopping", transient, true, 3, jobOutcomeHandedBack},
+		{"transient error", transient, false, 1, jobOutcomeRetry},
+		{"transient error on the last attempt", transient, false, 3, jobOutcomeFailed},
+		{"permanent error", permanent, false, 1, jobOutcomeFailed},
+		{"permanent error while stopping", permanent, true, 1, jobOutcomeFailed},
+		{"wrapped permanent error", fmt.Errorf("import: %w", permanent), false, 1, jobOutcomeFailed},
+		{"taken over once too often", PermanentJobError(errors.New("the job stopped responding on every attempt")), false, 4, jobOutcomeFailed},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := jobOutcome(tt.err, tt.stopping, tt.attempts, 3); got != tt.want {
+				t.Errorf("jobOutcome = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPermanentJobErrorUnwraps(t *testing.T) {
+	cause := errors.New("bad file")
+	err := PermanentJobError(cause)
+	if !errors.Is(err, cause) || err.Error() != "bad file" {
+		t.Errorf("PermanentJobError(%v) = %v, does not wrap its cause", cause, err)
+	}
+}
+
+func TestNextImportCheckpoint(t *testing.T) {
+	done := importCheckpoint{NextIndex: 10, Processed: 10, Success: 9, ErrorCount: 1, Errors: []string{"Element 3: bad"}}
+	tests := []struct {
+		name        string
+		end         int
+		batchErrors []string
+		want        importCheckpoint
+	}{
+		{"clean batch", 20, nil,
+			importCheckpoint{NextIndex: 20, Processed: 20, Success: 19, ErrorCount: 1, Errors: []string{"Element 3: bad"}}},
+		{"some elements failed", 20, []string{"Element 2: bad", "Element 7: bad"},
+			importCheckpoint{NextIndex: 20, Processed: 20, Success: 17, ErrorCount: 3, Errors: []string{"Element 3: bad", "Element 2: bad", "Element 7: bad"}}},
+		{"short last batch", 13, []string{"Element 0: bad"},
+			importCheckpoint{NextIndex: 13, Processed: 13, Success: 11, ErrorCount: 2, Errors: []string{"Element 3: bad", "Element 0: bad"}}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := nextImportCheckpoint(done, tt.end, tt.batchErrors); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("checkpoint = %+v, want %+v", got, tt.want)
+			}
+		})
+	}
+	if len(done.Errors) != 1 {
+		t.Errorf("the earlier checkpoint's errors were changed: %q", done.Errors)
+	}
+}
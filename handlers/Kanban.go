@@ -2183,6 +2183,7 @@ func getTaskByID(db *sql.DB, taskID int) (models.Task, error) {
 // @Success      200  {object}  object
 // @Failure      400  {object}  object
 // @Failure      401  {object}  object
+// @Failure      409  {object}  object
 // @Router       /api/update_activity_status [put]
 func UpdateActivityStatusHandler(db *sql.DB) gin.HandlerFunc {
 	return func(c *gin.Context) {
@@ -2205,9 +2206,11 @@ func UpdateActivityStatusHandler(db *sql.DB) gin.HandlerFunc {
 		userID := user.ID
 
 		var req struct {
-			ActivityID int    `json:"activity_id"`
-			Status     string `json:"status"`
-			QCStatus   string `json:"qc_status,omitempty"`
+			ActivityID int                      `json:"activity_id"`
+			Status     string                   `json:"status"`
+			QCStatus   string                   `json:"qc_status,omitempty"`
+			Pour       *models.PourCastingInput `json:"pour,omitempty"`     // pour the element is cast in, when completing casting
+			MouldID    int                      `json:"mould_id,omitempty"` // mould the element is cast in, when not assigned earlier
 		}
 		if err := c.ShouldBindJSON(&req); err != nil {
 			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
@@ -2321,6 +2324,19 @@ func UpdateActivityStatusHandler(db *sql.DB) gin.HandlerFunc {
 		}
 		defer tx.Rollback()
 
+		// Casting may only go ahead against approved drawings, whichever stage it is in
+		if strings.ToLower(req.Status) != "pending" {
+			blockers, err := DrawingCastingBlockers(tx, task.ElementTypeID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check drawing approval", "details": err.Error()})
+				return
+			}
+			if len(blockers) > 0 {
+				c.JSON(http.StatusConflict, gin.H{"error": drawingCastingBlockMessage(blockers), "blocking_drawings": blockers})
+				return
+			}
+		}
+
 		// Update Reinforcement status (only if not already completed)
 		if userID == ReinforcementAssignedTo && strings.ToLower(activity.ReinforcementStatus) != "completed" {
 			_, err = tx.Exec(`UPDATE activity SET reinforcement_status = $1 WHERE id = $2`, req.Status, req.ActivityID)
@@ -2374,7 +2390,7 @@ func UpdateActivityStatusHandler(db *sql.DB) gin.HandlerFunc {
 
 						if nextStageID == 0 {
 							// Last stage, move to stockyard
-							if _, err = CreatePrecastStock(db, activity.ElementID, activity.ProjectID, activity.StockyardID); err != nil {
+							if _, err = CreatePrecastStock(db, tx, activity.ElementID, activity.ProjectID, activity.StockyardID); err != nil {
 								c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move to stockyard", "details": err.Error()})
 								return
 							}
@@ -2428,6 +2444,30 @@ func UpdateActivityStatusHandler(db *sql.DB) gin.HandlerFunc {
 			}
 
 			if req.Status == "completed" {
+				// Casting is done: book the element's material against the BOM version in force now
+				if err := RecordElementBOMConsumption(tx, activity.ElementID, time.Now()); err != nil {
+					tx.Rollback()
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record BOM consumption", "details": err.Error()})
+					return
+				}
+				if req.MouldID > 0 {
+					if status, err := assignMould(tx, req.MouldID, activity.ID, userName); err != nil {
+						tx.Rollback()
+						c.JSON(status, gin.H{"error": "Failed to assign mould", "details": err.Error()})
+						return
+					}
+				}
+				mouldCode, err := CompleteMouldCycle(tx, activity.ID, time.Now(), userName)
+				if err != nil {
+					tx.Rollback()
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to complete mould cycle", "details": err.Error()})
+					return
+				}
+				if _, err := RecordElementPour(tx, activity.ProjectID, activity.ElementID, &activity.ID, time.Now(), withMouldBed(req.Pour, mouldCode), userName); err != nil {
+					tx.Rollback()
+					c.JSON(http.StatusBadRequest, gin.H{"error": "Failed to record pour", "details": err.Error()})
+					return
+				}
 
 				if activity.ReinforcementStatus == "completed" && activity.ReinforcementQCStatus == "completed" && ReinforcementQC != 0 {
 					if activity.QCID == 0 {
@@ -2439,7 +2479,7 @@ func UpdateActivityStatusHandler(db *sql.DB) gin.HandlerFunc {
 
 						if nextStageID == 0 {
 							// Last stage, move to stockyard
-							if _, err = CreatePrecastStock(db, activity.ElementID, activity.ProjectID, activity.StockyardID); err != nil {
+							if _, err = CreatePrecastStock(db, tx, activity.ElementID, activity.ProjectID, activity.StockyardID); err != nil {
 								c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move to stockyard", "details": err.Error()})
 								return
 							}
@@ -2505,7 +2545,7 @@ func UpdateActivityStatusHandler(db *sql.DB) gin.HandlerFunc {
 
 					if nextStageID == 0 {
 						// Last stage, move to stockyard
-						if _, err = CreatePrecastStock(db, activity.ElementID, activity.ProjectID, activity.StockyardID); err != nil {
+						if _, err = CreatePrecastStock(db, tx, activity.ElementID, activity.ProjectID, activity.StockyardID); err != nil {
 							c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move to stockyard", "details": err.Error()})
 							return
 						}
@@ -0,0 +1,886 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// SQL statements for the mould / casting bed registry
+const (
+	selectMouldSQL = `
+		SELECT m.id, m.project_id, m.code, m.name, m.length_mm, m.width_mm, m.height_mm, m.element_type_ids,
+			m.location, m.status, m.reuse_count, m.cycles_since_maintenance, m.maintenance_threshold,
+			m.last_maintained_at, m.current_activity_id,
+			(SELECT a.element_id FROM activity a WHERE a.id = m.current_activity_id),
+			m.remarks, m.created_by, m.created_at, m.updated_at
+		FROM mould m`
+)
+
+// mouldMaintenanceDue reports whether a mould has reached its maintenance threshold.
+func mouldMaintenanceDue(m models.Mould) bool {
+	return m.MaintenanceThreshold > 0 && m.CyclesSinceMaintenance >= m.MaintenanceThreshold
+}
+
+// scanMould scans a row produced by selectMouldSQL.
+func scanMould(row interface{ Scan(...any) error }, m *models.Mould) error {
+	var typeIDs []int64
+	var lastMaintained sql.NullTime
+	var activityID, elementID sql.NullInt64
+	err := row.Scan(&m.ID, &m.ProjectID, &m.Code, &m.Name, &m.LengthMM, &m.WidthMM, &m.HeightMM, pq.Array(&typeIDs),
+		&m.Location, &m.Status, &m.ReuseCount, &m.CyclesSinceMaintenance, &m.MaintenanceThreshold,
+		&lastMaintained, &activityID, &elementID, &m.Remarks, &m.CreatedBy, &m.CreatedAt, &m.UpdatedAt)
+	if err != nil {
+		return err
+	}
+	m.ElementTypeIDs = make([]int, len(typeIDs))
+	for i, id := range typeIDs {
+		m.ElementTypeIDs[i] = int(id)
+	}
+	if lastMaintained.Valid {
+		m.LastMaintainedAt = &lastMaintained.Time
+	}
+	m.CurrentActivityID = nullIntPtr(activityID)
+	m.CurrentElementID = nullIntPtr(elementID)
+	m.MaintenanceDue = mouldMaintenanceDue(*m)
+	return nil
+}
+
+// loadMould reads a mould with its recent cycles and status changes.
+func loadMould(q sqlQueryer, id int) (models.Mould, error) {
+	var m models.Mould
+	if err := scanMould(q.QueryRow(selectMouldSQL+` WHERE m.id = $1`, id), &m); err != nil {
+		return m, err
+	}
+
+	rows, err := q.Query(`
+		SELECT c.id, c.mould_id, c.activity_id, c.element_id, COALESCE(e.element_name, ''), c.assigned_at, c.assigned_by, c.cast_at
+		FROM mould_cycle c
+		LEFT JOIN element e ON e.id = c.element_id
+		WHERE c.mould_id = $1 ORDER BY c.assigned_at DESC LIMIT 50`, id)
+	if err != nil {
+		return m, err
+	}
+	m.Cycles = []models.MouldCycle{}
+	for rows.Next() {
+		var cy models.MouldCycle
+		var castAt sql.NullTime
+		if err := rows.Scan(&cy.ID, &cy.MouldID, &cy.ActivityID, &cy.ElementID, &cy.ElementName, &cy.AssignedAt, &cy.AssignedBy, &castAt); err != nil {
+			rows.Close()
+			return m, err
+		}
+		if castAt.Valid {
+			cy.CastAt = &castAt.Time
+		}
+		m.Cycles = append(m.Cycles, cy)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return m, err
+	}
+
+	m.StatusLog, err = mouldStatusLog(q, `WHERE mould_id = $1 ORDER BY changed_at DESC, id DESC LIMIT 50`, id)
+	return m, err
+}
+
+// mouldStatusLog reads status changes with the given filter.
+func mouldStatusLog(q sqlQueryer, where string, args ...interface{}) ([]models.MouldStatusLog, error) {
+	entries, _, err := mouldStatusLogByMould(q, where, args...)
+	return entries, err
+}
+
+// mouldStatusLogByMould reads status changes with the given filter and also returns the mould of each.
+func mouldStatusLogByMould(q sqlQueryer, where string, args ...interface{}) ([]models.MouldStatusLog, []int, error) {
+	rows, err := q.Query(`SELECT mould_id, status, activity_id, note, changed_by, changed_at FROM mould_status_log `+where, args...)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	entries := []models.MouldStatusLog{}
+	var mouldIDs []int
+	for rows.Next() {
+		var e models.MouldStatusLog
+		var mouldID int
+		var activityID sql.NullInt64
+		if err := rows.Scan(&mouldID, &e.Status, &activityID, &e.Note, &e.ChangedBy, &e.ChangedAt); err != nil {
+			return nil, nil, err
+		}
+		e.ActivityID = nullIntPtr(activityID)
+		entries = append(entries, e)
+		mouldIDs = append(mouldIDs, mouldID)
+	}
+	return entries, mouldIDs, rows.Err()
+}
+
+// setMouldStatus changes the status of a mould and logs the change.
+func setMouldStatus(tx *sql.Tx, mouldID int, status string, activityID *int, note, userName string, at time.Time) error {
+	if _, err := tx.Exec(`UPDATE mould SET status = $2, updated_at = $3 WHERE id = $1`, mouldID, status, at); err != nil {
+		return err
+	}
+	_, err := tx.Exec(`
+		INSERT INTO mould_status_log (mould_id, status, activity_id, note, changed_by, changed_at)
+		VALUES ($1, $2, $3, $4, $5, $6)`, mouldID, status, activityID, note, userName, at)
+	return err
+}
+
+// releaseActivityMould frees the mould assigned to an activity that has not been cast yet.
+func releaseActivityMould(tx *sql.Tx, activityID int, note, userName string) (bool, error) {
+	var mouldID int
+	err := tx.QueryRow(`DELETE FROM mould_cycle WHERE activity_id = $1 AND cast_at IS NULL RETURNING mould_id`, activityID).Scan(&mouldID)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`UPDATE mould SET current_activity_id = NULL WHERE id = $1 AND current_activity_id = $2`, mouldID, activityID); err != nil {
+		return false, err
+	}
+	return true, setMouldStatus(tx, mouldID, models.MouldAvailable, &activityID, note, userName, time.Now())
+}
+
+// assignMould puts a mould in use for a casting activity, releasing any other mould the activity
+// held. It returns the HTTP status to report with the error.
+func assignMould(tx *sql.Tx, mouldID, activityID int, userName string) (int, error) {
+	// Lock the mould so two activities can't take it at once
+	var m models.Mould
+	err := scanMould(tx.QueryRow(selectMouldSQL+` WHERE m.id = $1 FOR UPDATE OF m`, mouldID), &m)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, fmt.Errorf("mould %d not found", mouldID)
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	var projectID, elementID, elementTypeID int
+	var meshMouldStatus string
+	err = tx.QueryRow(`
+		SELECT a.project_id, a.element_id, COALESCE(a.mesh_mold_status, ''), COALESCE(e.element_type_id, 0)
+		FROM activity a
+		LEFT JOIN element e ON e.id = a.element_id
+		WHERE a.id = $1`, activityID).Scan(&projectID, &elementID, &meshMouldStatus, &elementTypeID)
+	if err == sql.ErrNoRows {
+		return http.StatusNotFound, fmt.Errorf("activity %d not found", activityID)
+	} else if err != nil {
+		return http.StatusInternalServerError, err
+	}
+
+	switch {
+	case projectID != m.ProjectID:
+		return http.StatusBadRequest, fmt.Errorf("mould %s does not belong to the activity's project", m.Code)
+	case strings.EqualFold(meshMouldStatus, "completed"):
+		return http.StatusConflict, fmt.Errorf("casting of activity %d is already completed", activityID)
+	case m.CurrentActivityID != nil && *m.CurrentActivityID == activityID:
+		return http.StatusOK, nil
+	case m.Status != models.MouldAvailable:
+		return http.StatusConflict, fmt.Errorf("mould %s is %s", m.Code, strings.ReplaceAll(m.Status, "_", " "))
+	case m.MaintenanceDue:
+		return http.StatusConflict, fmt.Errorf("mould %s is due for maintenance after %d cycles", m.Code, m.CyclesSinceMaintenance)
+	}
+	if len(m.ElementTypeIDs) > 0 {
+		compatible := false
+		for _, id := range m.ElementTypeIDs {
+			compatible = compatible || id == elementTypeID
+		}
+		if !compatible {
+			return http.StatusBadRequest, fmt.Errorf("mould %s is not compatible with the element type of activity %d", m.Code, activityID)
+		}
+	}
+
+	if _, err := releaseActivityMould(tx, activityID, fmt.Sprintf("Activity %d moved to mould %s", activityID, m.Code), userName); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	now := time.Now()
+	if _, err := tx.Exec(`
+		INSERT INTO mould_cycle (mould_id, activity_id, element_id, assigned_at, assigned_by)
+		VALUES ($1, $2, $3, $4, $5)`, mouldID, activityID, elementID, now, userName); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if _, err := tx.Exec(`UPDATE mould SET current_activity_id = $2 WHERE id = $1`, mouldID, activityID); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	if err := setMouldStatus(tx, mouldID, models.MouldInUse, &activityID, "", userName, now); err != nil {
+		return http.StatusInternalServerError, err
+	}
+	return http.StatusOK, nil
+}
+
+// CompleteMouldCycle closes the mould cycle of an activity whose casting was completed: the reuse
+// counters go up and the mould goes to cleaning. It returns the mould code, or "" when the activity
+// had no mould.
+func CompleteMouldCycle(tx *sql.Tx, activityID int, castAt time.Time, userName string) (string, error) {
+	var mouldID int
+	var code string
+	err := tx.QueryRow(`
+		UPDATE mould_cycle c SET cast_at = $2
+		FROM mould m
+		WHERE m.id = c.mould_id AND c.activity_id = $1 AND c.cast_at IS NULL
+		RETURNING m.id, m.code`, activityID, castAt).Scan(&mouldID, &code)
+	if err == sql.ErrNoRows {
+		return "", nil
+	} else if err != nil {
+		return "", err
+	}
+	if _, err := tx.Exec(`
+		UPDATE mould SET reuse_count = reuse_count + 1, cycles_since_maintenance = cycles_since_maintenance + 1,
+			current_activity_id = NULL
+		WHERE id = $1`, mouldID); err != nil {
+		return "", err
+	}
+	return code, setMouldStatus(tx, mouldID, models.MouldCleaning, &activityID, "Casting completed", userName, castAt)
+}
+
+// withMouldBed fills the bed of a casting's pour from its mould when none was given.
+func withMouldBed(input *models.PourCastingInput, mouldCode string) *models.PourCastingInput {
+	if mouldCode == "" || (input != nil && (input.PourID > 0 || strings.TrimSpace(input.Bed) != "")) {
+		return input
+	}
+	withBed := models.PourCastingInput{}
+	if input != nil {
+		withBed = *input
+	}
+	withBed.Bed = mouldCode
+	return &withBed
+}
+
+// mouldParam loads the mould named by the :id parameter.
+func mouldParam(c *gin.Context, db *sql.DB) (models.Mould, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a valid integer"})
+		return models.Mould{}, false
+	}
+	m, err := loadMould(db, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Mould not found"})
+		return m, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch mould", "details": err.Error()})
+		return m, false
+	}
+	return m, true
+}
+
+// saveMouldActivity writes the activity log of a mould change.
+func saveMouldActivity(db *sql.DB, session models.Session, userName, eventName, description string, projectID int) {
+	activityLog := models.ActivityLog{
+		EventContext: "Mould",
+		EventName:    eventName,
+		Description:  description,
+		UserName:     userName,
+		HostName:     session.HostName,
+		IPAddress:    session.IPAddress,
+		CreatedAt:    time.Now(),
+		ProjectID:    projectID,
+	}
+	if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+		log.Printf("Failed to save activity log: %v", logErr)
+	}
+}
+
+func mouldTypeIDs(ids []int) interface{} {
+	typeIDs := make([]int64, len(ids))
+	for i, id := range ids {
+		typeIDs[i] = int64(id)
+	}
+	return pq.Array(typeIDs)
+}
+
+// CreateMould registers a mould or casting bed.
+// @Summary Create mould
+// @Tags Moulds
+// @Accept json
+// @Produce json
+// @Param request body models.CreateMouldRequest true "Mould"
+// @Success 201 {object} models.Mould
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/moulds [post]
+func CreateMould(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var req models.CreateMouldRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		req.Code = strings.TrimSpace(req.Code)
+		if req.Code == "" || req.ReuseCount < 0 || req.MaintenanceThreshold < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "code is required and counts can't be negative"})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+
+		var id int
+		var createdAt time.Time
+		err = tx.QueryRow(`
+			INSERT INTO mould (project_id, code, name, length_mm, width_mm, height_mm, element_type_ids, location,
+				reuse_count, maintenance_threshold, remarks, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id, created_at`,
+			req.ProjectID, req.Code, req.Name, req.LengthMM, req.WidthMM, req.HeightMM, mouldTypeIDs(req.ElementTypeIDs),
+			req.Location, req.ReuseCount, req.MaintenanceThreshold, req.Remarks, userName).Scan(&id, &createdAt)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Mould %s already exists in this project", req.Code)})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create mould", "details": err.Error()})
+			return
+		}
+		if err := setMouldStatus(tx, id, models.MouldAvailable, nil, "Registered", userName, createdAt); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log mould status", "details": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		created, err := loadMould(db, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load mould", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, created)
+
+		saveMouldActivity(db, session, userName, "Create", fmt.Sprintf("Registered mould %s", created.Code), req.ProjectID)
+	}
+}
+
+// GetMouldsByProject lists the moulds of a project.
+// @Summary List moulds
+// @Tags Moulds
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param status query string false "Filter by status (available, in_use, cleaning, repair)"
+// @Param element_type_id query int false "Moulds compatible with this element type"
+// @Param maintenance_due query bool false "Only moulds due for maintenance"
+// @Success 200 {array} models.Mould
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/moulds [get]
+func GetMouldsByProject(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+		query := selectMouldSQL + ` WHERE m.project_id = $1`
+		args := []interface{}{projectID}
+		if status := c.Query("status"); status != "" {
+			args = append(args, status)
+			query += fmt.Sprintf(` AND m.status = $%d`, len(args))
+		}
+		if s := c.Query("element_type_id"); s != "" {
+			typeID, err := strconv.Atoi(s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "element_type_id must be a valid integer"})
+				return
+			}
+			args = append(args, typeID)
+			query += fmt.Sprintf(` AND (cardinality(m.element_type_ids) = 0 OR $%d = ANY(m.element_type_ids))`, len(args))
+		}
+		if c.Query("maintenance_due") == "true" {
+			query += ` AND m.maintenance_threshold > 0 AND m.cycles_since_maintenance >= m.maintenance_threshold`
+		}
+		query += ` ORDER BY m.code`
+
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch moulds", "details": err.Error()})
+			return
+		}
+		defer rows.Close()
+		moulds := []models.Mould{}
+		for rows.Next() {
+			var m models.Mould
+			if err := scanMould(rows, &m); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read mould", "details": err.Error()})
+				return
+			}
+			moulds = append(moulds, m)
+		}
+		c.JSON(http.StatusOK, moulds)
+	}
+}
+
+// GetMould returns a mould with its recent cycles and status changes.
+// @Summary Get mould
+// @Tags Moulds
+// @Produce json
+// @Param id path int true "Mould ID"
+// @Success 200 {object} models.Mould
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/moulds/{id} [get]
+func GetMould(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+		if m, ok := mouldParam(c, db); ok {
+			c.JSON(http.StatusOK, m)
+		}
+	}
+}
+
+// UpdateMould edits the details of a mould. Status and counters change through use and maintenance.
+// @Summary Update mould
+// @Tags Moulds
+// @Accept json
+// @Produce json
+// @Param id path int true "Mould ID"
+// @Param request body models.UpdateMouldRequest true "Mould"
+// @Success 200 {object} models.Mould
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/moulds/{id} [put]
+func UpdateMould(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var req models.UpdateMouldRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		req.Code = strings.TrimSpace(req.Code)
+		if req.Code == "" || req.MaintenanceThreshold < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "code is required and maintenance_threshold can't be negative"})
+			return
+		}
+		m, ok := mouldParam(c, db)
+		if !ok {
+			return
+		}
+
+		_, err := db.Exec(`
+			UPDATE mould SET code = $2, name = $3, length_mm = $4, width_mm = $5, height_mm = $6, element_type_ids = $7,
+				location = $8, maintenance_threshold = $9, remarks = $10, updated_at = NOW()
+			WHERE id = $1`,
+			m.ID, req.Code, req.Name, req.LengthMM, req.WidthMM, req.HeightMM, mouldTypeIDs(req.ElementTypeIDs),
+			req.Location, req.MaintenanceThreshold, req.Remarks)
+		if err != nil {
+			if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Mould %s already exists in this project", req.Code)})
+				return
+			}
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update mould", "details": err.Error()})
+			return
+		}
+
+		updated, err := loadMould(db, m.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load mould", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+
+		saveMouldActivity(db, session, userName, "Update", fmt.Sprintf("Updated mould %s", updated.Code), m.ProjectID)
+	}
+}
+
+// UpdateMouldStatus sets a mould to available, cleaning or repair. A mould due for maintenance has
+// to go through repair before it is available again, which resets its maintenance counter.
+// @Summary Update mould status
+// @Tags Moulds
+// @Accept json
+// @Produce json
+// @Param id path int true "Mould ID"
+// @Param request body models.MouldStatusRequest true "Status"
+// @Success 200 {object} models.Mould
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/moulds/{id}/status [put]
+func UpdateMouldStatus(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var req models.MouldStatusRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		switch req.Status {
+		case models.MouldAvailable, models.MouldCleaning, models.MouldRepair:
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "status must be available, cleaning or repair; moulds are put in use by assigning them to a casting"})
+			return
+		}
+		m, ok := mouldParam(c, db)
+		if !ok {
+			return
+		}
+		switch {
+		case m.Status == models.MouldInUse:
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Mould %s is in use by activity %d; unassign it first", m.Code, *m.CurrentActivityID)})
+			return
+		case m.Status == req.Status:
+			c.JSON(http.StatusOK, m)
+			return
+		case req.Status == models.MouldAvailable && m.Status != models.MouldRepair && m.MaintenanceDue:
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Mould %s is due for maintenance after %d cycles; set it to repair first", m.Code, m.CyclesSinceMaintenance)})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+		now := time.Now()
+		if req.Status == models.MouldAvailable && m.Status == models.MouldRepair {
+			if _, err := tx.Exec(`UPDATE mould SET cycles_since_maintenance = 0, last_maintained_at = $2 WHERE id = $1`, m.ID, now); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reset maintenance counter", "details": err.Error()})
+				return
+			}
+		}
+		if err := setMouldStatus(tx, m.ID, req.Status, nil, req.Note, userName, now); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update mould status", "details": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		updated, err := loadMould(db, m.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load mould", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+
+		saveMouldActivity(db, session, userName, "Status",
+			fmt.Sprintf("Mould %s changed from %s to %s", m.Code, m.Status, req.Status), m.ProjectID)
+	}
+}
+
+// AssignActivityMould assigns a mould to a casting activity and puts it in use.
+// @Summary Assign mould to casting
+// @Tags Moulds
+// @Accept json
+// @Produce json
+// @Param activity_id path int true "Activity ID"
+// @Param request body models.MouldAssignmentRequest true "Mould"
+// @Success 200 {object} models.Mould
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/activities/{activity_id}/mould [put]
+func AssignActivityMould(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		activityID, err := strconv.Atoi(c.Param("activity_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "activity_id must be a valid integer"})
+			return
+		}
+		var req models.MouldAssignmentRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+		if status, err := assignMould(tx, req.MouldID, activityID, userName); err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		m, err := loadMould(db, req.MouldID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load mould", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, m)
+
+		saveMouldActivity(db, session, userName, "Assign",
+			fmt.Sprintf("Assigned mould %s to activity %d", m.Code, activityID), m.ProjectID)
+	}
+}
+
+// UnassignActivityMould frees the mould of a casting activity that has not been cast yet.
+// @Summary Unassign mould from casting
+// @Tags Moulds
+// @Produce json
+// @Param activity_id path int true "Activity ID"
+// @Success 200 {object} models.MessageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/activities/{activity_id}/mould [delete]
+func UnassignActivityMould(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		activityID, err := strconv.Atoi(c.Param("activity_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "activity_id must be a valid integer"})
+			return
+		}
+		var projectID int
+		if err := db.QueryRow(`SELECT project_id FROM activity WHERE id = $1`, activityID).Scan(&projectID); err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Activity not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch activity", "details": err.Error()})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+		released, err := releaseActivityMould(tx, activityID, "Unassigned", userName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to unassign mould", "details": err.Error()})
+			return
+		}
+		if !released {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Activity has no mould waiting to be cast"})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Mould unassigned"})
+
+		saveMouldActivity(db, session, userName, "Unassign", fmt.Sprintf("Unassigned the mould of activity %d", activityID), projectID)
+	}
+}
+
+// mouldStatusHours adds up the hours a mould spent in each status between start and end, given its
+// status changes in time order.
+func mouldStatusHours(entries []models.MouldStatusLog, start, end time.Time) map[string]float64 {
+	hours := make(map[string]float64)
+	for i, e := range entries {
+		from := e.ChangedAt
+		to := end
+		if i+1 < len(entries) {
+			to = entries[i+1].ChangedAt
+		}
+		if from.Before(start) {
+			from = start
+		}
+		if to.After(end) {
+			to = end
+		}
+		if to.After(from) {
+			hours[e.Status] += to.Sub(from).Hours()
+		}
+	}
+	return hours
+}
+
+func roundHours(v float64) float64 {
+	return math.Round(v*100) / 100
+}
+
+// GetMouldUtilisation reports per mould the cycles cast per day and the time spent in use, cleaning,
+// under repair and idle over a period. Idle time is time the mould was available but unassigned.
+// @Summary Mould utilisation
+// @Tags Moulds
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param from_date query string false "From date (YYYY-MM-DD), defaults to 30 days ago"
+// @Param to_date query string false "To date (YYYY-MM-DD), defaults to today"
+// @Success 200 {object} models.MouldUtilisationReport
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/moulds/utilisation [get]
+func GetMouldUtilisation(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+		now := time.Now()
+		to := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		from := to.AddDate(0, 0, -30)
+		if s := c.Query("from_date"); s != "" {
+			if from, err = time.Parse("2006-01-02", s); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "from_date must be in YYYY-MM-DD format"})
+				return
+			}
+		}
+		if s := c.Query("to_date"); s != "" {
+			if to, err = time.Parse("2006-01-02", s); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "to_date must be in YYYY-MM-DD format"})
+				return
+			}
+		}
+		// The period runs to the end of to_date, or to now for today
+		end := to.AddDate(0, 0, 1)
+		if end.After(now) {
+			end = now
+		}
+		if !end.After(from) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from_date must be before to_date"})
+			return
+		}
+
+		rows, err := db.Query(selectMouldSQL+` WHERE m.project_id = $1 AND m.created_at < $2 ORDER BY m.code`, projectID, end)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch moulds", "details": err.Error()})
+			return
+		}
+		var moulds []models.Mould
+		for rows.Next() {
+			var m models.Mould
+			if err := scanMould(rows, &m); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read mould", "details": err.Error()})
+				return
+			}
+			moulds = append(moulds, m)
+		}
+		rows.Close()
+
+		type cycleStats struct {
+			count    int
+			avgHours float64
+		}
+		cycles := make(map[int]cycleStats)
+		rows, err = db.Query(`
+			SELECT c.mould_id, COUNT(*), COALESCE(AVG(EXTRACT(EPOCH FROM c.cast_at - c.assigned_at)) / 3600, 0)
+			FROM mould_cycle c
+			JOIN mould m ON m.id = c.mould_id
+			WHERE m.project_id = $1 AND c.cast_at >= $2 AND c.cast_at < $3
+			GROUP BY c.mould_id`, projectID, from, end)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch mould cycles", "details": err.Error()})
+			return
+		}
+		for rows.Next() {
+			var mouldID int
+			var s cycleStats
+			if err := rows.Scan(&mouldID, &s.count, &s.avgHours); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read mould cycles", "details": err.Error()})
+				return
+			}
+			cycles[mouldID] = s
+		}
+		rows.Close()
+
+		entries, mouldIDs, err := mouldStatusLogByMould(db, `
+			WHERE mould_id IN (SELECT id FROM mould WHERE project_id = $1) AND changed_at < $2
+			ORDER BY mould_id, changed_at, id`, projectID, end)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch mould status log", "details": err.Error()})
+			return
+		}
+		logs := make(map[int][]models.MouldStatusLog)
+		for i, e := range entries {
+			logs[mouldIDs[i]] = append(logs[mouldIDs[i]], e)
+		}
+
+		days := end.Sub(from).Hours() / 24
+		report := models.MouldUtilisationReport{
+			ProjectID: projectID,
+			From:      from.Format("2006-01-02"),
+			To:        to.Format("2006-01-02"),
+			Days:      roundHours(days),
+			Moulds:    []models.MouldUtilisation{},
+		}
+		var totalHours, totalInUse float64
+		for _, m := range moulds {
+			start := from
+			if m.CreatedAt.After(start) {
+				start = m.CreatedAt
+			}
+			hours := mouldStatusHours(logs[m.ID], start, end)
+			available := end.Sub(start).Hours()
+			u := models.MouldUtilisation{
+				MouldID:           m.ID,
+				Code:              m.Code,
+				Name:              m.Name,
+				Status:            m.Status,
+				Cycles:            cycles[m.ID].count,
+				AverageCycleHours: roundHours(cycles[m.ID].avgHours),
+				InUseHours:        roundHours(hours[models.MouldInUse]),
+				CleaningHours:     roundHours(hours[models.MouldCleaning]),
+				RepairHours:       roundHours(hours[models.MouldRepair]),
+				IdleHours:         roundHours(hours[models.MouldAvailable]),
+				MaintenanceDue:    m.MaintenanceDue,
+			}
+			if available > 0 {
+				u.CyclesPerDay = roundHours(float64(u.Cycles) / (available / 24))
+				u.UtilisationPercent = roundHours(hours[models.MouldInUse] / available * 100)
+				u.IdlePercent = roundHours(hours[models.MouldAvailable] / available * 100)
+			}
+			report.Moulds = append(report.Moulds, u)
+			report.Cycles += u.Cycles
+			totalHours += available
+			totalInUse += hours[models.MouldInUse]
+		}
+		if days > 0 {
+			report.CyclesPerDay = roundHours(float64(report.Cycles) / days)
+		}
+		if totalHours > 0 {
+			report.UtilisationPercent = roundHours(totalInUse / totalHours * 100)
+		}
+		c.JSON(http.StatusOK, report)
+	}
+}
@@ -0,0 +1,1046 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
+)
+
+// SQL statements for non-conformance reports
+const (
+	selectNCRSQL = `
+		SELECT n.id, n.project_id, n.ncr_no, n.element_id, COALESCE(e.element_name, ''), n.element_type_id,
+			n.activity_id, n.stage_id, COALESCE(ps.name, ''), n.inspection_id, n.source, n.title, n.description,
+			n.severity, n.status, n.root_cause_category, n.disposition, n.concession_ref, n.corrective_action,
+			n.action_owner_id, COALESCE(TRIM(u.first_name || ' ' || u.last_name), ''), n.action_due_date,
+			n.raised_by, n.raised_at, n.dispositioned_by, n.dispositioned_at, n.action_completed_by,
+			n.action_completed_at, n.closed_by, n.closed_at
+		FROM ncr n
+		LEFT JOIN element e ON e.id = n.element_id
+		LEFT JOIN project_stages ps ON ps.id = n.stage_id
+		LEFT JOIN users u ON u.id = n.action_owner_id`
+)
+
+// scanNCR scans a row produced by selectNCRSQL.
+func scanNCR(row interface{ Scan(...any) error }, n *models.NCR) error {
+	var activityID, stageID, inspectionID, ownerID sql.NullInt64
+	var dueDate, dispositionedAt, completedAt, closedAt sql.NullTime
+	err := row.Scan(&n.ID, &n.ProjectID, &n.NCRNo, &n.ElementID, &n.ElementName, &n.ElementTypeID,
+		&activityID, &stageID, &n.StageName, &inspectionID, &n.Source, &n.Title, &n.Description,
+		&n.Severity, &n.Status, &n.RootCauseCategory, &n.Disposition, &n.ConcessionRef, &n.CorrectiveAction,
+		&ownerID, &n.ActionOwnerName, &dueDate,
+		&n.RaisedBy, &n.RaisedAt, &n.DispositionedBy, &dispositionedAt, &n.ActionCompletedBy,
+		&completedAt, &n.ClosedBy, &closedAt)
+	if err != nil {
+		return err
+	}
+	n.ActivityID = nullIntPtr(activityID)
+	n.StageID = nullIntPtr(stageID)
+	n.InspectionID = nullIntPtr(inspectionID)
+	n.ActionOwnerID = nullIntPtr(ownerID)
+	if dueDate.Valid {
+		n.ActionDueDate = &dueDate.Time
+	}
+	if dispositionedAt.Valid {
+		n.DispositionedAt = &dispositionedAt.Time
+	}
+	if completedAt.Valid {
+		n.ActionCompletedAt = &completedAt.Time
+	}
+	if closedAt.Valid {
+		n.ClosedAt = &closedAt.Time
+	}
+	return nil
+}
+
+// nullIntPtr converts a nullable column into an optional int.
+func nullIntPtr(v sql.NullInt64) *int {
+	if !v.Valid {
+		return nil
+	}
+	i := int(v.Int64)
+	return &i
+}
+
+// queryNCRs runs selectNCRSQL with the given filter.
+func queryNCRs(q sqlQueryer, where string, args ...interface{}) ([]models.NCR, error) {
+	rows, err := q.Query(selectNCRSQL+" "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	ncrs := []models.NCR{}
+	for rows.Next() {
+		var n models.NCR
+		if err := scanNCR(rows, &n); err != nil {
+			return nil, err
+		}
+		ncrs = append(ncrs, n)
+	}
+	return ncrs, rows.Err()
+}
+
+// loadNCR reads an NCR with its history.
+func loadNCR(q sqlQueryer, id int) (models.NCR, error) {
+	var n models.NCR
+	if err := scanNCR(q.QueryRow(selectNCRSQL+` WHERE n.id = $1`, id), &n); err != nil {
+		return n, err
+	}
+	rows, err := q.Query(`
+		SELECT id, ncr_id, action, comments, acted_by, created_at
+		FROM ncr_event WHERE ncr_id = $1 ORDER BY created_at, id`, id)
+	if err != nil {
+		return n, err
+	}
+	defer rows.Close()
+	n.Events = []models.NCREvent{}
+	for rows.Next() {
+		var ev models.NCREvent
+		if err := rows.Scan(&ev.ID, &ev.NCRID, &ev.Action, &ev.Comments, &ev.ActedBy, &ev.CreatedAt); err != nil {
+			return n, err
+		}
+		n.Events = append(n.Events, ev)
+	}
+	return n, rows.Err()
+}
+
+// addNCREvent appends a step to the NCR history.
+func addNCREvent(q sqlQueryer, ncrID int, action, comments, actedBy string) error {
+	_, err := q.Exec(`INSERT INTO ncr_event (ncr_id, action, comments, acted_by) VALUES ($1, $2, $3, $4)`,
+		ncrID, action, comments, actedBy)
+	return err
+}
+
+// nextNCRNo numbers NCRs per project: NCR-0001, NCR-0002, ...
+func nextNCRNo(q sqlQueryer, projectID int) (string, error) {
+	// Serialise numbering per project
+	if _, err := q.Exec(`SELECT pg_advisory_xact_lock(hashtext('ncr'), $1)`, projectID); err != nil {
+		return "", err
+	}
+	var last int
+	err := q.QueryRow(`
+		SELECT COALESCE(MAX(NULLIF(regexp_replace(ncr_no, '\D', '', 'g'), '')::INT), 0)
+		FROM ncr WHERE project_id = $1`, projectID).Scan(&last)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("NCR-%04d", last+1), nil
+}
+
+// insertNCR numbers and stores a new NCR. It must run inside a transaction.
+func insertNCR(tx *sql.Tx, n *models.NCR) error {
+	ncrNo, err := nextNCRNo(tx, n.ProjectID)
+	if err != nil {
+		return err
+	}
+	n.NCRNo = ncrNo
+	err = tx.QueryRow(`
+		INSERT INTO ncr (project_id, ncr_no, element_id, element_type_id, activity_id, stage_id, inspection_id,
+			source, title, description, severity, root_cause_category, raised_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)
+		RETURNING id, raised_at`,
+		n.ProjectID, n.NCRNo, n.ElementID, n.ElementTypeID, n.ActivityID, n.StageID, n.InspectionID,
+		n.Source, n.Title, n.Description, n.Severity, n.RootCauseCategory, n.RaisedBy).
+		Scan(&n.ID, &n.RaisedAt)
+	if err != nil {
+		return err
+	}
+	n.Status = models.NCRStatusOpen
+	return addNCREvent(tx, n.ID, "raised", n.Description, n.RaisedBy)
+}
+
+// qcNCR is what a rejected QC submission records against an element.
+type qcNCR struct {
+	ProjectID    int
+	ElementID    int
+	StageID      int
+	ActivityID   int
+	InspectionID *int
+	Summary      string // appended to the stage name in the title
+	Description  string
+}
+
+// RaiseNCRForQCFailure opens an NCR for a failed QC inspection, listing the failed checks.
+// An element already holding an unresolved NCR for the same stage gets the failure added
+// to that NCR instead of a duplicate.
+func RaiseNCRForQCFailure(tx *sql.Tx, inspection *models.QCInspectionResult, projectID int, raisedBy string) (*models.NCR, error) {
+
+	var failed []string
+	for _, a := range inspection.Answers {
+		if a.Passed {
+			continue
+		}
+		line := fmt.Sprintf("[%s] %s", a.Severity, a.QuestionText)
+		if a.Reason != "" {
+			line += ": " + a.Reason
+		}
+		if a.Comment != "" {
+			line += " (" + a.Comment + ")"
+		}
+		failed = append(failed, line)
+	}
+	summary := fmt.Sprintf("(%d critical, %d minor)", inspection.CriticalFailures, inspection.MinorFailures)
+	if len(failed) == 0 {
+		// QC rejected an element whose typed checks all passed
+		failed = append(failed, "Rejected by QC although every typed check passed")
+		summary = "(rejected by QC)"
+	}
+	inspectionID := inspection.ID
+	return raiseQCNCR(tx, qcNCR{
+		ProjectID:    projectID,
+		ElementID:    inspection.ElementID,
+		StageID:      inspection.StageID,
+		ActivityID:   inspection.ActivityID,
+		InspectionID: &inspectionID,
+		Summary:      summary,
+		Description:  strings.Join(failed, "\n"),
+	}, raisedBy)
+}
+
+// RaiseNCRForQCRejection opens an NCR for a rejected submission of a plain multiple-choice
+// checklist, listing the answers given.
+func RaiseNCRForQCRejection(tx *sql.Tx, projectID, elementID, stageID, activityID int, answers []models.QCAnswerInput, raisedBy string) (*models.NCR, error) {
+	var lines []string
+	for _, a := range answers {
+		var questionText, optionText string
+		err := tx.QueryRow(`
+			SELECT q.question_text, COALESCE(o.option_text, '')
+			FROM questions q LEFT JOIN options o ON o.id = $2 AND o.question_id = q.id
+			WHERE q.id = $1`, a.QuestionID, a.OptionID).Scan(&questionText, &optionText)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		line := questionText
+		if optionText != "" {
+			line += ": " + optionText
+		}
+		if c := strings.TrimSpace(a.Comment); c != "" {
+			line += " (" + c + ")"
+		}
+		lines = append(lines, line)
+	}
+	if len(lines) == 0 {
+		lines = append(lines, "Rejected by QC")
+	}
+	return raiseQCNCR(tx, qcNCR{
+		ProjectID:   projectID,
+		ElementID:   elementID,
+		StageID:     stageID,
+		ActivityID:  activityID,
+		Summary:     "(rejected by QC)",
+		Description: strings.Join(lines, "\n"),
+	}, raisedBy)
+}
+
+func raiseQCNCR(tx *sql.Tx, r qcNCR, raisedBy string) (*models.NCR, error) {
+	var existingID int
+	err := tx.QueryRow(`
+		SELECT id FROM ncr
+		WHERE element_id = $1 AND stage_id = $2 AND source = $3 AND status = $4
+		ORDER BY id DESC LIMIT 1
+		FOR UPDATE`,
+		r.ElementID, r.StageID, models.NCRSourceQCFailure, models.NCRStatusOpen).Scan(&existingID)
+	if err == nil {
+		if _, err := tx.Exec(`UPDATE ncr SET inspection_id = COALESCE($1, inspection_id), description = description || E'\n' || $2 WHERE id = $3`,
+			r.InspectionID, r.Description, existingID); err != nil {
+			return nil, err
+		}
+		if err := addNCREvent(tx, existingID, "qc_failed_again", r.Description, raisedBy); err != nil {
+			return nil, err
+		}
+		n, err := loadNCR(tx, existingID)
+		return &n, err
+	} else if err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	var stageName string
+	var elementTypeID sql.NullInt64
+	if err := tx.QueryRow(`SELECT COALESCE(name, '') FROM project_stages WHERE id = $1`, r.StageID).Scan(&stageName); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+	if err := tx.QueryRow(`SELECT element_type_id FROM element WHERE id = $1`, r.ElementID).Scan(&elementTypeID); err != nil && err != sql.ErrNoRows {
+		return nil, err
+	}
+
+	activityID, stageID := r.ActivityID, r.StageID
+	n := models.NCR{
+		ProjectID:     r.ProjectID,
+		ElementID:     r.ElementID,
+		ElementTypeID: int(elementTypeID.Int64),
+		ActivityID:    &activityID,
+		StageID:       &stageID,
+		StageName:     stageName,
+		InspectionID:  r.InspectionID,
+		Source:        models.NCRSourceQCFailure,
+		Title:         fmt.Sprintf("QC failed at %s %s", stageName, r.Summary),
+		Description:   r.Description,
+		Severity:      models.QCSeverityCritical,
+		RaisedBy:      raisedBy,
+	}
+	if err := insertNCR(tx, &n); err != nil {
+		return nil, err
+	}
+	return &n, nil
+}
+
+// completeNCRActionsForRectification marks the repair or reject action of an element's NCRs as done
+// once its rectification has been recorded.
+func completeNCRActionsForRectification(tx *sql.Tx, elementID int, status, comments, userName string) error {
+	rows, err := tx.Query(`
+		UPDATE ncr SET status = $1, action_completed_by = $2, action_completed_at = NOW()
+		WHERE element_id = $3 AND status = $4 AND disposition IN ($5, $6)
+		RETURNING id`,
+		models.NCRStatusActionCompleted, userName, elementID, models.NCRStatusDispositioned,
+		models.NCRDispositionRepair, models.NCRDispositionReject)
+	if err != nil {
+		return err
+	}
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			rows.Close()
+			return err
+		}
+		ids = append(ids, id)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return err
+	}
+	for _, id := range ids {
+		if err := addNCREvent(tx, id, "action_completed", fmt.Sprintf("Rectification %s: %s", strings.ToLower(status), comments), userName); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// CreateNCR raises a non-conformance report manually.
+// @Summary Raise NCR
+// @Description Raises a non-conformance report against an element. NCRs are also raised automatically when a typed QC checklist fails.
+// @Tags NCR
+// @Accept json
+// @Produce json
+// @Param request body models.CreateNCRRequest true "NCR"
+// @Success 201 {object} models.NCR
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/ncrs [post]
+func CreateNCR(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var req models.CreateNCRRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		if req.Severity == "" {
+			req.Severity = models.QCSeverityCritical
+		}
+		if req.Severity != models.QCSeverityCritical && req.Severity != models.QCSeverityMinor {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "severity must be critical or minor"})
+			return
+		}
+		if _, ok := models.NCRRootCauseCategories[req.RootCauseCategory]; req.RootCauseCategory != "" && !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown root_cause_category"})
+			return
+		}
+
+		n := models.NCR{
+			ElementID:         req.ElementID,
+			Source:            models.NCRSourceManual,
+			Title:             strings.TrimSpace(req.Title),
+			Description:       req.Description,
+			Severity:          req.Severity,
+			RootCauseCategory: req.RootCauseCategory,
+			RaisedBy:          userName,
+		}
+		err := db.QueryRow(`SELECT project_id, element_type_id FROM element WHERE id = $1`, req.ElementID).
+			Scan(&n.ProjectID, &n.ElementTypeID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Element not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch element", "details": err.Error()})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+		if err := insertNCR(tx, &n); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to raise NCR", "details": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		created, err := loadNCR(db, n.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load NCR", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, created)
+
+		activityLog := models.ActivityLog{
+			EventContext: "NCR",
+			EventName:    "Create",
+			Description:  fmt.Sprintf("Raised %s on element %d: %s", created.NCRNo, created.ElementID, created.Title),
+			UserName:     userName,
+			HostName:     session.HostName,
+			IPAddress:    session.IPAddress,
+			CreatedAt:    time.Now(),
+			ProjectID:    created.ProjectID,
+		}
+		if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+			log.Printf("Failed to save activity log: %v", logErr)
+		}
+	}
+}
+
+// ncrFilter builds the WHERE clause shared by the NCR list and register.
+func ncrFilter(c *gin.Context, projectID int) (string, []interface{}, error) {
+	where := `WHERE n.project_id = $1`
+	args := []interface{}{projectID}
+	if status := c.Query("status"); status != "" {
+		args = append(args, status)
+		where += fmt.Sprintf(` AND n.status = $%d`, len(args))
+	}
+	if category := c.Query("root_cause_category"); category != "" {
+		args = append(args, category)
+		where += fmt.Sprintf(` AND n.root_cause_category = $%d`, len(args))
+	}
+	if disposition := c.Query("disposition"); disposition != "" {
+		args = append(args, disposition)
+		where += fmt.Sprintf(` AND n.disposition = $%d`, len(args))
+	}
+	if s := c.Query("element_id"); s != "" {
+		elementID, err := strconv.Atoi(s)
+		if err != nil {
+			return "", nil, fmt.Errorf("element_id must be a valid integer")
+		}
+		args = append(args, elementID)
+		where += fmt.Sprintf(` AND n.element_id = $%d`, len(args))
+	}
+	for _, bound := range []struct{ param, op string }{{"from_date", ">="}, {"to_date", "<"}} {
+		s := c.Query(bound.param)
+		if s == "" {
+			continue
+		}
+		d, err := time.Parse("2006-01-02", s)
+		if err != nil {
+			return "", nil, fmt.Errorf("%s must be in YYYY-MM-DD format", bound.param)
+		}
+		if bound.op == "<" {
+			d = d.AddDate(0, 0, 1)
+		}
+		args = append(args, d)
+		where += fmt.Sprintf(` AND n.raised_at %s $%d`, bound.op, len(args))
+	}
+	return where, args, nil
+}
+
+// GetNCRsByProject lists the NCRs of a project.
+// @Summary List NCRs
+// @Tags NCR
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param status query string false "Filter by status"
+// @Param root_cause_category query string false "Filter by root-cause category"
+// @Param disposition query string false "Filter by disposition"
+// @Param element_id query int false "Filter by element"
+// @Success 200 {array} models.NCR
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/ncrs [get]
+func GetNCRsByProject(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+		where, args, err := ncrFilter(c, projectID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		ncrs, err := queryNCRs(db, where+` ORDER BY n.raised_at DESC`, args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch NCRs", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, ncrs)
+	}
+}
+
+// GetNCR returns one NCR with its history.
+// @Summary Get NCR
+// @Tags NCR
+// @Produce json
+// @Param id path int true "NCR ID"
+// @Success 200 {object} models.NCR
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/ncrs/{id} [get]
+func GetNCR(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a valid integer"})
+			return
+		}
+		n, err := loadNCR(db, id)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "NCR not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch NCR", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, n)
+	}
+}
+
+// ncrForUpdate loads an NCR and checks that it is in one of the expected statuses.
+func ncrForUpdate(c *gin.Context, db *sql.DB, statuses ...string) (models.NCR, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a valid integer"})
+		return models.NCR{}, false
+	}
+	n, err := loadNCR(db, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "NCR not found"})
+		return n, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch NCR", "details": err.Error()})
+		return n, false
+	}
+	for _, s := range statuses {
+		if n.Status == s {
+			return n, true
+		}
+	}
+	c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s is %s", n.NCRNo, n.Status)})
+	return n, false
+}
+
+// ncrUpdated checks a status change made with an "AND status = <loaded status>" guard. No row
+// means another request moved the NCR on since it was loaded.
+func ncrUpdated(c *gin.Context, res sql.Result, err error, n models.NCR) bool {
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update NCR", "details": err.Error()})
+		return false
+	}
+	if affected, err := res.RowsAffected(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update NCR", "details": err.Error()})
+		return false
+	} else if affected == 0 {
+		c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s is no longer %s", n.NCRNo, n.Status)})
+		return false
+	}
+	return true
+}
+
+// DispositionNCR records the root cause and what happens to the element. Repair and reject put the
+// element into rectification and need a corrective action owner; accepting as is needs a concession.
+// @Summary Disposition NCR
+// @Tags NCR
+// @Accept json
+// @Produce json
+// @Param id path int true "NCR ID"
+// @Param request body models.NCRDispositionRequest true "Disposition"
+// @Success 200 {object} models.NCR
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/ncrs/{id}/disposition [put]
+func DispositionNCR(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var req models.NCRDispositionRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		if _, ok := models.NCRRootCauseCategories[req.RootCauseCategory]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Unknown root_cause_category"})
+			return
+		}
+		var ownerID *int
+		var dueDate *time.Time
+		switch req.Disposition {
+		case models.NCRDispositionRepair, models.NCRDispositionReject:
+			if req.ActionOwnerID == 0 || strings.TrimSpace(req.CorrectiveAction) == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "corrective_action and action_owner_id are required to repair or reject"})
+				return
+			}
+			ownerID = &req.ActionOwnerID
+			if req.ActionDueDate != "" {
+				d, err := time.Parse("2006-01-02", req.ActionDueDate)
+				if err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": "action_due_date must be in YYYY-MM-DD format"})
+					return
+				}
+				dueDate = &d
+			}
+		case models.NCRDispositionAcceptAsIs:
+			if strings.TrimSpace(req.ConcessionRef) == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "concession_ref is required to accept as is"})
+				return
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "disposition must be repair, reject or accept_as_is"})
+			return
+		}
+
+		n, ok := ncrForUpdate(c, db, models.NCRStatusOpen)
+		if !ok {
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+
+		res, err := tx.Exec(`
+			UPDATE ncr SET status = $1, disposition = $2, root_cause_category = $3, corrective_action = $4,
+				action_owner_id = $5, action_due_date = $6, concession_ref = $7, dispositioned_by = $8, dispositioned_at = NOW()
+			WHERE id = $9 AND status = $10`,
+			models.NCRStatusDispositioned, req.Disposition, req.RootCauseCategory, strings.TrimSpace(req.CorrectiveAction),
+			ownerID, dueDate, strings.TrimSpace(req.ConcessionRef), userName, n.ID, n.Status)
+		if !ncrUpdated(c, res, err, n) {
+			return
+		}
+		// Repaired and rejected elements go through rectification before they can move on
+		if req.Disposition != models.NCRDispositionAcceptAsIs {
+			if _, err := tx.Exec(`UPDATE element SET disable = true WHERE id = $1`, n.ElementID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to send element to rectification", "details": err.Error()})
+				return
+			}
+		}
+		comments := fmt.Sprintf("%s (%s)", req.Disposition, req.RootCauseCategory)
+		if req.Comments != "" {
+			comments += ": " + req.Comments
+		}
+		if err := addNCREvent(tx, n.ID, "dispositioned", comments, userName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record NCR history", "details": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		updated, err := loadNCR(db, n.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load NCR", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+
+		if ownerID != nil {
+			message := fmt.Sprintf("%s on %s: %s - %s", updated.NCRNo, updated.ElementName, req.Disposition, updated.CorrectiveAction)
+			if dueDate != nil {
+				message += fmt.Sprintf(" (due %s)", dueDate.Format("2006-01-02"))
+			}
+			sendUserNotification(db, *ownerID, message,
+				fmt.Sprintf("https://precastezy.blueinvent.com/project/%d/ncr/%d", updated.ProjectID, updated.ID))
+		}
+
+		activityLog := models.ActivityLog{
+			EventContext: "NCR",
+			EventName:    "Disposition",
+			Description:  fmt.Sprintf("Dispositioned %s as %s (%s)", updated.NCRNo, req.Disposition, req.RootCauseCategory),
+			UserName:     userName,
+			HostName:     session.HostName,
+			IPAddress:    session.IPAddress,
+			CreatedAt:    time.Now(),
+			ProjectID:    updated.ProjectID,
+		}
+		if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+			log.Printf("Failed to save activity log: %v", logErr)
+		}
+	}
+}
+
+// isGlobalAdmin reports whether the user holds the admin or superadmin role.
+func isGlobalAdmin(db *sql.DB, userID int) (bool, error) {
+	var role string
+	err := db.QueryRow(`SELECT r.role_name FROM users u JOIN roles r ON u.role_id = r.role_id WHERE u.id = $1`, userID).Scan(&role)
+	if err == sql.ErrNoRows {
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	r := normaliseRoleName(role)
+	return r == "superadmin" || r == "admin", nil
+}
+
+// CompleteNCRAction records that the corrective action has been carried out. Rectification of the
+// element completes it automatically.
+// @Summary Complete NCR corrective action
+// @Tags NCR
+// @Accept json
+// @Produce json
+// @Param id path int true "NCR ID"
+// @Param request body models.NCRCommentRequest false "Remarks"
+// @Success 200 {object} models.NCR
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/ncrs/{id}/action-complete [put]
+func CompleteNCRAction(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var req models.NCRCommentRequest
+		_ = c.ShouldBindJSON(&req)
+
+		n, ok := ncrForUpdate(c, db, models.NCRStatusDispositioned)
+		if !ok {
+			return
+		}
+		if n.Disposition == models.NCRDispositionAcceptAsIs {
+			c.JSON(http.StatusConflict, gin.H{"error": "NCRs accepted as is have no corrective action; close them instead"})
+			return
+		}
+		if n.ActionOwnerID != nil && *n.ActionOwnerID != session.UserID {
+			admin, err := isGlobalAdmin(db, session.UserID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user role", "details": err.Error()})
+				return
+			}
+			if !admin {
+				c.JSON(http.StatusForbidden, gin.H{"error": "Only the action owner can complete the corrective action"})
+				return
+			}
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+		res, err := tx.Exec(`UPDATE ncr SET status = $1, action_completed_by = $2, action_completed_at = NOW() WHERE id = $3 AND status = $4`,
+			models.NCRStatusActionCompleted, userName, n.ID, n.Status)
+		if !ncrUpdated(c, res, err, n) {
+			return
+		}
+		if err := addNCREvent(tx, n.ID, "action_completed", req.Comments, userName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record NCR history", "details": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		updated, err := loadNCR(db, n.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load NCR", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+
+		activityLog := models.ActivityLog{
+			EventContext: "NCR",
+			EventName:    "Action Complete",
+			Description:  fmt.Sprintf("Completed corrective action of %s", updated.NCRNo),
+			UserName:     userName,
+			HostName:     session.HostName,
+			IPAddress:    session.IPAddress,
+			CreatedAt:    time.Now(),
+			ProjectID:    updated.ProjectID,
+		}
+		if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+			log.Printf("Failed to save activity log: %v", logErr)
+		}
+	}
+}
+
+// CloseNCR signs off an NCR. The sign-off must come from someone other than the action owner.
+// @Summary Close NCR
+// @Description Signs off an NCR whose corrective action is complete, or one accepted as is under a concession.
+// @Tags NCR
+// @Accept json
+// @Produce json
+// @Param id path int true "NCR ID"
+// @Param request body models.NCRCommentRequest false "Closure remarks"
+// @Success 200 {object} models.NCR
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/ncrs/{id}/close [put]
+func CloseNCR(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var req models.NCRCommentRequest
+		_ = c.ShouldBindJSON(&req)
+
+		n, ok := ncrForUpdate(c, db, models.NCRStatusDispositioned, models.NCRStatusActionCompleted)
+		if !ok {
+			return
+		}
+		if n.Status == models.NCRStatusDispositioned && n.Disposition != models.NCRDispositionAcceptAsIs {
+			c.JSON(http.StatusConflict, gin.H{"error": "The corrective action must be completed before the NCR can be closed"})
+			return
+		}
+		if n.ActionOwnerID != nil && *n.ActionOwnerID == session.UserID {
+			c.JSON(http.StatusForbidden, gin.H{"error": "The action owner cannot sign off their own NCR"})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+		res, err := tx.Exec(`UPDATE ncr SET status = $1, closed_by = $2, closed_by_user_id = $3, closed_at = NOW() WHERE id = $4 AND status = $5`,
+			models.NCRStatusClosed, userName, session.UserID, n.ID, n.Status)
+		if !ncrUpdated(c, res, err, n) {
+			return
+		}
+		if err := addNCREvent(tx, n.ID, "closed", req.Comments, userName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record NCR history", "details": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		updated, err := loadNCR(db, n.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load NCR", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+
+		activityLog := models.ActivityLog{
+			EventContext: "NCR",
+			EventName:    "Close",
+			Description:  fmt.Sprintf("Closed %s", updated.NCRNo),
+			UserName:     userName,
+			HostName:     session.HostName,
+			IPAddress:    session.IPAddress,
+			CreatedAt:    time.Now(),
+			ProjectID:    updated.ProjectID,
+		}
+		if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+			log.Printf("Failed to save activity log: %v", logErr)
+		}
+	}
+}
+
+// buildNCRRegister counts the NCRs by root-cause category. NCRs not yet dispositioned are
+// counted under "unclassified" unless they were raised with a category.
+func buildNCRRegister(projectID int, ncrs []models.NCR) models.NCRRegister {
+	register := models.NCRRegister{ProjectID: projectID, NCRs: ncrs, ByCategory: []models.NCRRegisterRow{}}
+	rows := make(map[string]*models.NCRRegisterRow)
+	for _, n := range ncrs {
+		category := n.RootCauseCategory
+		if category == "" {
+			category = "unclassified"
+		}
+		row, ok := rows[category]
+		if !ok {
+			label, known := models.NCRRootCauseCategories[category]
+			if !known {
+				label = "Unclassified"
+			}
+			row = &models.NCRRegisterRow{RootCauseCategory: category, Label: label}
+			rows[category] = row
+		}
+		row.Total++
+		register.Total++
+		if n.Status == models.NCRStatusClosed {
+			row.Closed++
+			register.Closed++
+		} else {
+			row.Open++
+			register.Open++
+		}
+		switch n.Disposition {
+		case models.NCRDispositionRepair:
+			row.Repair++
+		case models.NCRDispositionReject:
+			row.Reject++
+		case models.NCRDispositionAcceptAsIs:
+			row.AcceptAsIs++
+		}
+	}
+	for _, row := range rows {
+		register.ByCategory = append(register.ByCategory, *row)
+	}
+	sort.Slice(register.ByCategory, func(i, j int) bool {
+		if register.ByCategory[i].Total != register.ByCategory[j].Total {
+			return register.ByCategory[i].Total > register.ByCategory[j].Total
+		}
+		return register.ByCategory[i].RootCauseCategory < register.ByCategory[j].RootCauseCategory
+	})
+	return register
+}
+
+// GetNCRRegister reports the NCRs of a project by root-cause category.
+// @Summary NCR register
+// @Description Counts NCRs by root-cause category, status and disposition. format=excel downloads the register as a workbook.
+// @Tags NCR
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param status query string false "Filter by status"
+// @Param root_cause_category query string false "Filter by root-cause category"
+// @Param from_date query string false "Raised on or after (YYYY-MM-DD)"
+// @Param to_date query string false "Raised on or before (YYYY-MM-DD)"
+// @Param format query string false "json (default) or excel"
+// @Success 200 {object} models.NCRRegister
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/ncrs/register [get]
+func GetNCRRegister(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+		where, args, err := ncrFilter(c, projectID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		ncrs, err := queryNCRs(db, where+` ORDER BY n.ncr_no`, args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch NCRs", "details": err.Error()})
+			return
+		}
+
+		register := buildNCRRegister(projectID, ncrs)
+		if c.Query("format") == "excel" {
+			writeNCRRegisterExcel(c, register)
+			return
+		}
+		c.JSON(http.StatusOK, register)
+	}
+}
+
+var ncrRegisterHeader = []string{"NCR No", "Raised", "Element", "Stage", "Source", "Severity", "Title", "Root Cause",
+	"Disposition", "Concession", "Corrective Action", "Action Owner", "Action Due", "Status", "Closed By", "Closed"}
+
+// writeNCRRegisterExcel streams the register as a workbook with a register and a summary sheet.
+func writeNCRRegisterExcel(c *gin.Context, register models.NCRRegister) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := "NCR Register"
+	index, err := f.NewSheet(sheet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating register sheet"})
+		return
+	}
+	f.SetActiveSheet(index)
+	f.DeleteSheet("Sheet1")
+
+	date := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format("2006-01-02")
+	}
+	header := make([]interface{}, len(ncrRegisterHeader))
+	for i, title := range ncrRegisterHeader {
+		header[i] = title
+	}
+	f.SetSheetRow(sheet, "A1", &header)
+	for i, n := range register.NCRs {
+		category := models.NCRRootCauseCategories[n.RootCauseCategory]
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		f.SetSheetRow(sheet, cell, &[]interface{}{n.NCRNo, n.RaisedAt.Format("2006-01-02"), n.ElementName, n.StageName,
+			n.Source, n.Severity, n.Title, category, n.Disposition, n.ConcessionRef, n.CorrectiveAction,
+			n.ActionOwnerName, date(n.ActionDueDate), n.Status, n.ClosedBy, date(n.ClosedAt)})
+	}
+
+	summary := "By Category"
+	if _, err := f.NewSheet(summary); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating summary sheet"})
+		return
+	}
+	f.SetSheetRow(summary, "A1", &[]interface{}{"Root Cause", "Total", "Open", "Closed", "Repair", "Reject", "Accept As Is"})
+	for i, row := range register.ByCategory {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		f.SetSheetRow(summary, cell, &[]interface{}{row.Label, row.Total, row.Open, row.Closed, row.Repair, row.Reject, row.AcceptAsIs})
+	}
+	cell, _ := excelize.CoordinatesToCellName(1, len(register.ByCategory)+2)
+	f.SetSheetRow(summary, cell, &[]interface{}{"Total", register.Total, register.Open, register.Closed})
+
+	filename := fmt.Sprintf("ncr_register_project_%d.xlsx", register.ProjectID)
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"; filename*=UTF-8''%s", filename, url.PathEscape(filename)))
+	if err := f.Write(c.Writer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error writing Excel file"})
+		return
+	}
+}
+
+// elementNCRs lists the NCRs raised against an element for its lifecycle view.
+func elementNCRs(db *sql.DB, elementID int) ([]models.NCR, error) {
+	return queryNCRs(db, `WHERE n.element_id = $1 ORDER BY n.raised_at`, elementID)
+}
@@ -0,0 +1,732 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// SQL statements for pour / batch records
+const (
+	selectPourSQL = `
+		SELECT p.id, p.project_id, p.pour_no, p.pour_date, p.bed, p.grade, p.remarks, p.created_by, p.created_at,
+			(SELECT COUNT(*) FROM pour_element pe WHERE pe.pour_id = p.id),
+			(SELECT COALESCE(SUM(pe.volume_m3), 0) FROM pour_element pe WHERE pe.pour_id = p.id),
+			(SELECT COALESCE(SUM(t.volume_m3), 0) FROM pour_ticket t WHERE t.pour_id = p.id),
+			(SELECT COUNT(*) FROM pour_ticket t WHERE t.pour_id = p.id)
+		FROM pour p`
+
+	// elementVolumeSQL is the cast volume of an element in m³, from its type
+	elementVolumeSQL = `COALESCE(NULLIF(et.volume, 0), et.thickness * et.length * et.height / 1000000000.0, 0)`
+)
+
+// scanPour scans a row produced by selectPourSQL and works out the ticket variance.
+func scanPour(row interface{ Scan(...any) error }, p *models.Pour) error {
+	var tickets int
+	err := row.Scan(&p.ID, &p.ProjectID, &p.PourNo, &p.PourDate, &p.Bed, &p.Grade, &p.Remarks, &p.CreatedBy, &p.CreatedAt,
+		&p.ElementCount, &p.TheoreticalVolumeM3, &p.TicketVolumeM3, &tickets)
+	if err != nil {
+		return err
+	}
+	p.TheoreticalVolumeM3 = roundVolume(p.TheoreticalVolumeM3)
+	p.TicketVolumeM3 = roundVolume(p.TicketVolumeM3)
+	if tickets > 0 {
+		variance := roundVolume(p.TicketVolumeM3 - p.TheoreticalVolumeM3)
+		p.VarianceM3 = &variance
+		if p.TheoreticalVolumeM3 > 0 {
+			percent := math.Round(variance/p.TheoreticalVolumeM3*10000) / 100
+			p.VariancePercent = &percent
+		}
+	}
+	return nil
+}
+
+func roundVolume(v float64) float64 {
+	return math.Round(v*1000) / 1000
+}
+
+// loadPour reads a pour with its elements, batch tickets and concrete samples.
+func loadPour(q sqlQueryer, id int) (models.Pour, error) {
+	var p models.Pour
+	if err := scanPour(q.QueryRow(selectPourSQL+` WHERE p.id = $1`, id), &p); err != nil {
+		return p, err
+	}
+
+	rows, err := q.Query(`
+		SELECT pe.element_id, COALESCE(e.element_name, ''), COALESCE(et.element_type_name, ''), pe.activity_id, pe.volume_m3, pe.added_at
+		FROM pour_element pe
+		LEFT JOIN element e ON e.id = pe.element_id
+		LEFT JOIN element_type et ON et.element_type_id = e.element_type_id
+		WHERE pe.pour_id = $1 ORDER BY pe.added_at, pe.element_id`, id)
+	if err != nil {
+		return p, err
+	}
+	p.Elements = []models.PourElement{}
+	for rows.Next() {
+		var el models.PourElement
+		var activityID sql.NullInt64
+		if err := rows.Scan(&el.ElementID, &el.ElementName, &el.ElementType, &activityID, &el.VolumeM3, &el.AddedAt); err != nil {
+			rows.Close()
+			return p, err
+		}
+		el.ActivityID = nullIntPtr(activityID)
+		p.Elements = append(p.Elements, el)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return p, err
+	}
+
+	rows, err = q.Query(`
+		SELECT id, pour_id, ticket_no, volume_m3, grade, delivered_at, created_by
+		FROM pour_ticket WHERE pour_id = $1 ORDER BY COALESCE(delivered_at, created_at), id`, id)
+	if err != nil {
+		return p, err
+	}
+	p.Tickets = []models.PourTicket{}
+	for rows.Next() {
+		var t models.PourTicket
+		var deliveredAt sql.NullTime
+		if err := rows.Scan(&t.ID, &t.PourID, &t.TicketNo, &t.VolumeM3, &t.Grade, &deliveredAt, &t.CreatedBy); err != nil {
+			rows.Close()
+			return p, err
+		}
+		if deliveredAt.Valid {
+			t.DeliveredAt = &deliveredAt.Time
+		}
+		p.Tickets = append(p.Tickets, t)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return p, err
+	}
+
+	rows, err = q.Query(`SELECT sample_no FROM concrete_sample WHERE project_id = $1 AND pour_ref = $2 ORDER BY sample_no`, p.ProjectID, p.PourNo)
+	if err != nil {
+		return p, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var sampleNo string
+		if err := rows.Scan(&sampleNo); err != nil {
+			return p, err
+		}
+		p.SampleNos = append(p.SampleNos, sampleNo)
+	}
+	return p, rows.Err()
+}
+
+// nextPourNo numbers pours per project: PR-0001, PR-0002, ...
+func nextPourNo(tx *sql.Tx, projectID int) (string, error) {
+	// Serialise numbering per project
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('pour'), $1)`, projectID); err != nil {
+		return "", err
+	}
+	var last int
+	err := tx.QueryRow(`
+		SELECT COALESCE(MAX(NULLIF(regexp_replace(pour_no, '\D', '', 'g'), '')::INT), 0)
+		FROM pour WHERE project_id = $1`, projectID).Scan(&last)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("PR-%04d", last+1), nil
+}
+
+// insertPour numbers and stores a new pour.
+func insertPour(tx *sql.Tx, projectID int, pourDate time.Time, bed, grade, remarks, createdBy string) (int, error) {
+	pourNo, err := nextPourNo(tx, projectID)
+	if err != nil {
+		return 0, err
+	}
+	var id int
+	err = tx.QueryRow(`
+		INSERT INTO pour (project_id, pour_no, pour_date, bed, grade, remarks, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7) RETURNING id`,
+		projectID, pourNo, pourDate, strings.TrimSpace(bed), strings.ToUpper(strings.TrimSpace(grade)), remarks, createdBy).Scan(&id)
+	return id, err
+}
+
+// addPourTickets records batch-plant tickets against a pour. Tickets default to the pour grade and
+// a ticket number already on the pour is updated rather than counted twice.
+func addPourTickets(tx *sql.Tx, pourID int, tickets []models.PourTicketInput, createdBy string) error {
+	for _, t := range tickets {
+		if strings.TrimSpace(t.TicketNo) == "" || t.VolumeM3 <= 0 {
+			return fmt.Errorf("batch tickets need a ticket_no and a positive volume_m3")
+		}
+		var deliveredAt *time.Time
+		if t.DeliveredAt != "" {
+			d, err := time.Parse(time.RFC3339, t.DeliveredAt)
+			if err != nil {
+				return fmt.Errorf("delivered_at of ticket %s must be an RFC3339 timestamp", t.TicketNo)
+			}
+			deliveredAt = &d
+		}
+		_, err := tx.Exec(`
+			INSERT INTO pour_ticket (pour_id, ticket_no, volume_m3, grade, delivered_at, created_by)
+			SELECT $1, $2, $3, COALESCE(NULLIF($4, ''), grade), $5, $6 FROM pour WHERE id = $1
+			ON CONFLICT (pour_id, ticket_no) DO UPDATE SET
+				volume_m3 = EXCLUDED.volume_m3, grade = EXCLUDED.grade, delivered_at = EXCLUDED.delivered_at`,
+			pourID, strings.TrimSpace(t.TicketNo), t.VolumeM3, strings.ToUpper(strings.TrimSpace(t.Grade)), deliveredAt, createdBy)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// addPourElement puts an element in a pour with its volume. With move set an element already in
+// another pour is moved; otherwise it stays where it is.
+func addPourElement(tx *sql.Tx, pourID, elementID int, activityID *int, move bool) error {
+	conflict := `DO NOTHING`
+	if move {
+		conflict = `DO UPDATE SET pour_id = EXCLUDED.pour_id`
+	}
+	_, err := tx.Exec(`
+		INSERT INTO pour_element (pour_id, element_id, activity_id, volume_m3)
+		SELECT $1, e.id, $3, `+elementVolumeSQL+`
+		FROM element e
+		LEFT JOIN element_type et ON et.element_type_id = e.element_type_id
+		WHERE e.id = $2
+		ON CONFLICT (element_id) `+conflict, pourID, elementID, activityID)
+	return err
+}
+
+// RecordElementPour puts an element whose casting was completed in a pour. An explicit pour_id is
+// used as given; otherwise the element joins the project's pour of the day on the same bed and grade,
+// starting one if there is none. Elements already in a pour are left alone.
+func RecordElementPour(tx *sql.Tx, projectID, elementID int, activityID *int, castAt time.Time, input *models.PourCastingInput, userName string) (int, error) {
+	if input == nil {
+		input = &models.PourCastingInput{}
+	}
+
+	var pourID int
+	err := tx.QueryRow(`SELECT pour_id FROM pour_element WHERE element_id = $1`, elementID).Scan(&pourID)
+	if err == nil {
+		return pourID, nil
+	} else if err != sql.ErrNoRows {
+		return 0, err
+	}
+
+	bed, grade := strings.TrimSpace(input.Bed), strings.ToUpper(strings.TrimSpace(input.Grade))
+	if input.PourID > 0 {
+		var pourProjectID int
+		if err := tx.QueryRow(`SELECT project_id FROM pour WHERE id = $1`, input.PourID).Scan(&pourProjectID); err == sql.ErrNoRows {
+			return 0, fmt.Errorf("pour %d not found", input.PourID)
+		} else if err != nil {
+			return 0, err
+		}
+		if pourProjectID != projectID {
+			return 0, fmt.Errorf("pour %d does not belong to project %d", input.PourID, projectID)
+		}
+		pourID = input.PourID
+	} else {
+		// Serialise with other castings of the project so the day's pour is created once
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('pour'), $1)`, projectID); err != nil {
+			return 0, err
+		}
+		err := tx.QueryRow(`
+			SELECT id FROM pour
+			WHERE project_id = $1 AND pour_date = $2::DATE AND bed = $3 AND (grade = $4 OR $4 = '')
+			ORDER BY id DESC LIMIT 1`, projectID, castAt, bed, grade).Scan(&pourID)
+		if err == sql.ErrNoRows {
+			if pourID, err = insertPour(tx, projectID, castAt, bed, grade, "", userName); err != nil {
+				return 0, err
+			}
+		} else if err != nil {
+			return 0, err
+		}
+	}
+
+	if err := addPourElement(tx, pourID, elementID, activityID, false); err != nil {
+		return 0, err
+	}
+	if len(input.Tickets) > 0 {
+		if err := addPourTickets(tx, pourID, input.Tickets, userName); err != nil {
+			return 0, err
+		}
+	}
+	return pourID, nil
+}
+
+// ticketedPourUsage totals the batch-ticket volume per grade of the pours in a date range, and
+// counts the elements in those pours.
+func ticketedPourUsage(db *sql.DB, projectID int, startDate, endDate time.Time) (map[string]float64, int, error) {
+	rows, err := db.Query(`
+		SELECT COALESCE(NULLIF(t.grade, ''), NULLIF(p.grade, ''), 'Concrete'), SUM(t.volume_m3)
+		FROM pour_ticket t
+		JOIN pour p ON p.id = t.pour_id
+		WHERE p.project_id = $1 AND p.pour_date BETWEEN $2::DATE AND $3::DATE
+		GROUP BY 1`, projectID, startDate, endDate)
+	if err != nil {
+		return nil, 0, err
+	}
+	defer rows.Close()
+	usage := make(map[string]float64)
+	for rows.Next() {
+		var grade string
+		var volume float64
+		if err := rows.Scan(&grade, &volume); err != nil {
+			return nil, 0, err
+		}
+		usage[grade] = roundVolume(volume)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	var elements int
+	err = db.QueryRow(`
+		SELECT COUNT(*) FROM pour_element pe
+		JOIN pour p ON p.id = pe.pour_id
+		WHERE p.project_id = $1 AND p.pour_date BETWEEN $2::DATE AND $3::DATE
+			AND EXISTS (SELECT 1 FROM pour_ticket t WHERE t.pour_id = p.id)`, projectID, startDate, endDate).Scan(&elements)
+	return usage, elements, err
+}
+
+// pourParam loads the pour named by the :id parameter.
+func pourParam(c *gin.Context, db *sql.DB) (models.Pour, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a valid integer"})
+		return models.Pour{}, false
+	}
+	p, err := loadPour(db, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Pour not found"})
+		return p, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pour", "details": err.Error()})
+		return p, false
+	}
+	return p, true
+}
+
+// savePourActivity writes the activity log of a pour change.
+func savePourActivity(db *sql.DB, session models.Session, userName, eventName, description string, projectID int) {
+	activityLog := models.ActivityLog{
+		EventContext: "Pour",
+		EventName:    eventName,
+		Description:  description,
+		UserName:     userName,
+		HostName:     session.HostName,
+		IPAddress:    session.IPAddress,
+		CreatedAt:    time.Now(),
+		ProjectID:    projectID,
+	}
+	if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+		log.Printf("Failed to save activity log: %v", logErr)
+	}
+}
+
+// CreatePour opens a pour ahead of casting so elements can be completed against it.
+// @Summary Create pour
+// @Tags Pours
+// @Accept json
+// @Produce json
+// @Param request body models.CreatePourRequest true "Pour"
+// @Success 201 {object} models.Pour
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/pours [post]
+func CreatePour(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var req models.CreatePourRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		pourDate := time.Now()
+		if req.PourDate != "" {
+			d, err := time.Parse("2006-01-02", req.PourDate)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "pour_date must be in YYYY-MM-DD format"})
+				return
+			}
+			pourDate = d
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+
+		pourID, err := insertPour(tx, req.ProjectID, pourDate, req.Bed, req.Grade, req.Remarks, userName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create pour", "details": err.Error()})
+			return
+		}
+		if err := addPourTickets(tx, pourID, req.Tickets, userName); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		created, err := loadPour(db, pourID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load pour", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, created)
+
+		savePourActivity(db, session, userName, "Create",
+			fmt.Sprintf("Created pour %s (%s) on %s", created.PourNo, created.Grade, created.PourDate.Format("2006-01-02")), req.ProjectID)
+	}
+}
+
+// GetPoursByProject lists the pours of a project.
+// @Summary List pours
+// @Tags Pours
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param from_date query string false "Poured on or after (YYYY-MM-DD)"
+// @Param to_date query string false "Poured on or before (YYYY-MM-DD)"
+// @Param bed query string false "Filter by bed or mould"
+// @Param grade query string false "Filter by concrete grade"
+// @Param element_id query int false "The pour this element was cast in"
+// @Success 200 {array} models.Pour
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/pours [get]
+func GetPoursByProject(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+		query := selectPourSQL + ` WHERE p.project_id = $1`
+		args := []interface{}{projectID}
+		for _, bound := range []struct{ param, op string }{{"from_date", ">="}, {"to_date", "<="}} {
+			s := c.Query(bound.param)
+			if s == "" {
+				continue
+			}
+			d, err := time.Parse("2006-01-02", s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": bound.param + " must be in YYYY-MM-DD format"})
+				return
+			}
+			args = append(args, d)
+			query += fmt.Sprintf(` AND p.pour_date %s $%d`, bound.op, len(args))
+		}
+		if bed := c.Query("bed"); bed != "" {
+			args = append(args, bed)
+			query += fmt.Sprintf(` AND p.bed = $%d`, len(args))
+		}
+		if grade := c.Query("grade"); grade != "" {
+			args = append(args, strings.ToUpper(grade))
+			query += fmt.Sprintf(` AND p.grade = $%d`, len(args))
+		}
+		if s := c.Query("element_id"); s != "" {
+			elementID, err := strconv.Atoi(s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "element_id must be a valid integer"})
+				return
+			}
+			args = append(args, elementID)
+			query += fmt.Sprintf(` AND EXISTS (SELECT 1 FROM pour_element pe WHERE pe.pour_id = p.id AND pe.element_id = $%d)`, len(args))
+		}
+		query += ` ORDER BY p.pour_date DESC, p.id DESC`
+
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pours", "details": err.Error()})
+			return
+		}
+		defer rows.Close()
+		pours := []models.Pour{}
+		for rows.Next() {
+			var p models.Pour
+			if err := scanPour(rows, &p); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read pour", "details": err.Error()})
+				return
+			}
+			pours = append(pours, p)
+		}
+		c.JSON(http.StatusOK, pours)
+	}
+}
+
+// GetPour returns a pour with everything cast in it.
+// @Summary Get pour
+// @Tags Pours
+// @Produce json
+// @Param id path int true "Pour ID"
+// @Success 200 {object} models.Pour
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/pours/{id} [get]
+func GetPour(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+		if p, ok := pourParam(c, db); ok {
+			c.JSON(http.StatusOK, p)
+		}
+	}
+}
+
+// AddPourTickets records batch-plant tickets against a pour.
+// @Summary Add batch tickets to pour
+// @Tags Pours
+// @Accept json
+// @Produce json
+// @Param id path int true "Pour ID"
+// @Param request body models.PourTicketsRequest true "Tickets"
+// @Success 200 {object} models.Pour
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/pours/{id}/tickets [post]
+func AddPourTickets(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var req models.PourTicketsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		p, ok := pourParam(c, db)
+		if !ok {
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+		if err := addPourTickets(tx, p.ID, req.Tickets, userName); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		updated, err := loadPour(db, p.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load pour", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+
+		savePourActivity(db, session, userName, "Tickets",
+			fmt.Sprintf("Added %d batch tickets to pour %s", len(req.Tickets), p.PourNo), p.ProjectID)
+	}
+}
+
+// MovePourElements moves elements into a pour, e.g. when a day's castings were split across pours.
+// @Summary Move elements into pour
+// @Tags Pours
+// @Accept json
+// @Produce json
+// @Param id path int true "Pour ID"
+// @Param request body models.PourElementsRequest true "Elements"
+// @Success 200 {object} models.Pour
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/pours/{id}/elements [put]
+func MovePourElements(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var req models.PourElementsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		p, ok := pourParam(c, db)
+		if !ok {
+			return
+		}
+
+		ids := make([]int64, len(req.ElementIDs))
+		for i, id := range req.ElementIDs {
+			ids[i] = int64(id)
+		}
+		var found int
+		if err := db.QueryRow(`SELECT COUNT(*) FROM element WHERE project_id = $1 AND id = ANY($2)`, p.ProjectID, pq.Array(ids)).Scan(&found); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check elements", "details": err.Error()})
+			return
+		}
+		if found != len(req.ElementIDs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "All elements must belong to the pour's project"})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+		var previous []int64
+		if err := tx.QueryRow(`SELECT COALESCE(ARRAY_AGG(DISTINCT pour_id), '{}') FROM pour_element WHERE element_id = ANY($1) AND pour_id <> $2`,
+			pq.Array(ids), p.ID).Scan(pq.Array(&previous)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch current pours", "details": err.Error()})
+			return
+		}
+		for _, elementID := range req.ElementIDs {
+			if err := addPourElement(tx, p.ID, elementID, nil, true); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move element", "details": err.Error()})
+				return
+			}
+		}
+		// Pours emptied by the move were only started by these castings
+		if _, err := tx.Exec(`
+			DELETE FROM pour p WHERE p.id = ANY($1)
+				AND NOT EXISTS (SELECT 1 FROM pour_element pe WHERE pe.pour_id = p.id)
+				AND NOT EXISTS (SELECT 1 FROM pour_ticket t WHERE t.pour_id = p.id)`, pq.Array(previous)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to tidy empty pours", "details": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		updated, err := loadPour(db, p.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load pour", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, updated)
+
+		savePourActivity(db, session, userName, "Update",
+			fmt.Sprintf("Moved %d elements into pour %s", len(req.ElementIDs), p.PourNo), p.ProjectID)
+	}
+}
+
+// GetConcreteReconciliation compares the theoretical volume of the elements cast against the
+// batch tickets delivered, per pour and per grade. Variance only counts pours with tickets.
+// @Summary Concrete reconciliation
+// @Tags Pours
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param from_date query string false "From date (YYYY-MM-DD), defaults to 30 days ago"
+// @Param to_date query string false "To date (YYYY-MM-DD), defaults to today"
+// @Success 200 {object} models.ConcreteReconciliation
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/pours/reconciliation [get]
+func GetConcreteReconciliation(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+		to := time.Now()
+		from := to.AddDate(0, 0, -30)
+		if s := c.Query("from_date"); s != "" {
+			if from, err = time.Parse("2006-01-02", s); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "from_date must be in YYYY-MM-DD format"})
+				return
+			}
+		}
+		if s := c.Query("to_date"); s != "" {
+			if to, err = time.Parse("2006-01-02", s); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "to_date must be in YYYY-MM-DD format"})
+				return
+			}
+		}
+
+		rows, err := db.Query(selectPourSQL+`
+			WHERE p.project_id = $1 AND p.pour_date BETWEEN $2::DATE AND $3::DATE
+			ORDER BY p.pour_date, p.id`, projectID, from, to)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch pours", "details": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		report := models.ConcreteReconciliation{
+			ProjectID: projectID,
+			From:      from.Format("2006-01-02"),
+			To:        to.Format("2006-01-02"),
+			ByGrade:   []models.ConcreteReconciliationRow{},
+			Pours:     []models.Pour{},
+		}
+		byGrade := make(map[string]*models.ConcreteReconciliationRow)
+		ticketedTheoretical := make(map[string]float64)
+		for rows.Next() {
+			var p models.Pour
+			if err := scanPour(rows, &p); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read pour", "details": err.Error()})
+				return
+			}
+			report.Pours = append(report.Pours, p)
+
+			grade := p.Grade
+			if grade == "" {
+				grade = "Unspecified"
+			}
+			row, ok := byGrade[grade]
+			if !ok {
+				row = &models.ConcreteReconciliationRow{Grade: grade}
+				byGrade[grade] = row
+			}
+			row.Pours++
+			row.TheoreticalVolumeM3 += p.TheoreticalVolumeM3
+			if p.VarianceM3 != nil {
+				row.TicketedPours++
+				row.TicketVolumeM3 += p.TicketVolumeM3
+				row.VarianceM3 += *p.VarianceM3
+				ticketedTheoretical[grade] += p.TheoreticalVolumeM3
+			}
+		}
+		for grade, row := range byGrade {
+			row.TheoreticalVolumeM3 = roundVolume(row.TheoreticalVolumeM3)
+			row.TicketVolumeM3 = roundVolume(row.TicketVolumeM3)
+			row.VarianceM3 = roundVolume(row.VarianceM3)
+			if base := ticketedTheoretical[grade]; base > 0 {
+				percent := math.Round(row.VarianceM3/base*10000) / 100
+				row.VariancePercent = &percent
+			}
+			report.ByGrade = append(report.ByGrade, *row)
+		}
+		sort.Slice(report.ByGrade, func(i, j int) bool { return report.ByGrade[i].Grade < report.ByGrade[j].Grade })
+		c.JSON(http.StatusOK, report)
+	}
+}
@@ -0,0 +1,859 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// HierarchyActionTemplate is the notification action URL of the structure of a project
+const HierarchyActionTemplate = "https://precastezy.blueinvent.com/project/%d/structure"
+
+// Limits on generated hierarchies
+const (
+	maxHierarchyLevels       = 6
+	maxHierarchyLevelCount   = 500
+	maxHierarchyNodes        = 5000
+	maxHierarchyBlockersList = 50
+)
+
+// hierarchyPatternToken matches the tokens of a level name or prefix pattern
+var hierarchyPatternToken = regexp.MustCompile(`\{(n(?::[1-9])?|a|name)\}`)
+
+// precastSubtreeNode is a node of a subtree as stored
+type precastSubtreeNode struct {
+	ID          int
+	ParentID    sql.NullInt64
+	Name        string
+	Prefix      string
+	Description string
+	Others      bool
+}
+
+// precastNodeParam reads the project and parent of the node named by the :id path parameter, writing
+// the error response when it can't.
+func precastNodeParam(c *gin.Context, db *sql.DB) (node precastSubtreeNode, projectID int, ok bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a valid integer"})
+		return node, 0, false
+	}
+	err = db.QueryRow(`
+		SELECT id, project_id, parent_id, name, COALESCE(prefix, ''), COALESCE(description, ''), COALESCE(others, FALSE)
+		FROM precast WHERE id = $1`, id).Scan(&node.ID, &projectID, &node.ParentID, &node.Name, &node.Prefix,
+		&node.Description, &node.Others)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Precast record not found"})
+		return node, 0, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch precast record", "details": err.Error()})
+		return node, 0, false
+	}
+	return node, projectID, true
+}
+
+// lockPrecastTree serialises structure changes of a project, so two moves can't make a cycle between
+// them.
+func lockPrecastTree(tx *sql.Tx, projectID int) error {
+	_, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('precast'), $1)`, projectID)
+	return err
+}
+
+// precastChain reads the names and prefixes from the top of the hierarchy down to a node of a
+// project, returning sql.ErrNoRows when the node isn't in the project.
+func precastChain(q sqlQueryer, projectID int, id int64) (names, prefixes []string, err error) {
+	rows, err := q.Query(`
+		WITH RECURSIVE chain AS (
+			SELECT id, parent_id, name, COALESCE(prefix, '') AS prefix, 0 AS depth
+			FROM precast WHERE id = $1 AND project_id = $2
+			UNION ALL
+			SELECT p.id, p.parent_id, p.name, COALESCE(p.prefix, ''), c.depth + 1
+			FROM precast p JOIN chain c ON p.id = c.parent_id
+			WHERE c.depth < 32
+		)
+		SELECT name, prefix FROM chain ORDER BY depth DESC`, id, projectID)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var name, prefix string
+		if err := rows.Scan(&name, &prefix); err != nil {
+			return nil, nil, err
+		}
+		names = append(names, name)
+		prefixes = append(prefixes, prefix)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, nil, err
+	}
+	if len(names) == 0 {
+		return nil, nil, sql.ErrNoRows
+	}
+	return names, prefixes, nil
+}
+
+// parentChain is precastChain for an optional parent; the top of the hierarchy has an empty chain.
+func parentChain(q sqlQueryer, projectID int, parentID *int64) (names, prefixes []string, err error) {
+	if parentID == nil {
+		return nil, nil, nil
+	}
+	return precastChain(q, projectID, *parentID)
+}
+
+// precastPath builds the ltree path of a node from the names from the top down to it, the same way
+// InsertPrecast does.
+func precastPath(names []string) (string, error) {
+	segments := make([]string, 0, len(names))
+	for _, name := range names {
+		segment := sanitizePathForLtree(name)
+		if strings.Contains(name, ".") || !pathRegex.MatchString(segment) {
+			return "", fmt.Errorf("name %q can't be used in a hierarchy path", name)
+		}
+		segments = append(segments, segment)
+	}
+	return strings.Join(segments, "."), nil
+}
+
+// siblingNameTaken reports whether another node under the same parent already has the name.
+func siblingNameTaken(q sqlQueryer, projectID int, parentID *int64, name string, exceptID int) (bool, error) {
+	var taken bool
+	err := q.QueryRow(`
+		SELECT EXISTS (
+			SELECT 1 FROM precast
+			WHERE project_id = $1 AND parent_id IS NOT DISTINCT FROM $2 AND LOWER(name) = LOWER($3) AND id <> $4
+		)`, projectID, parentID, name, exceptID).Scan(&taken)
+	return taken, err
+}
+
+// insertPrecastNode adds a node. names and prefixes run from the top of the hierarchy down to and
+// including the new node.
+func insertPrecastNode(tx *sql.Tx, projectID int, parentID *int64, description string, others bool, names, prefixes []string) (models.HierarchyNode, error) {
+	node := models.HierarchyNode{
+		Name:             names[len(names)-1],
+		Prefix:           prefixes[len(prefixes)-1],
+		NamingConvention: strings.Join(prefixes, "_"),
+	}
+	path, err := precastPath(names)
+	if err != nil {
+		return node, err
+	}
+	node.Path = path
+	err = tx.QueryRow(`
+		INSERT INTO precast (project_id, name, description, parent_id, prefix, path, naming_convention, others)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		projectID, node.Name, description, parentID, node.Prefix, node.Path, node.NamingConvention, others).Scan(&node.ID)
+	return node, err
+}
+
+// loadPrecastSubtree reads a node and everything under it, parents before children.
+func loadPrecastSubtree(q sqlQueryer, rootID int) ([]precastSubtreeNode, error) {
+	rows, err := q.Query(`
+		WITH RECURSIVE subtree AS (
+			SELECT id, parent_id, name, COALESCE(prefix, '') AS prefix, COALESCE(description, '') AS description,
+				COALESCE(others, FALSE) AS others, 0 AS depth
+			FROM precast WHERE id = $1
+			UNION ALL
+			SELECT p.id, p.parent_id, p.name, COALESCE(p.prefix, ''), COALESCE(p.description, ''),
+				COALESCE(p.others, FALSE), s.depth + 1
+			FROM precast p JOIN subtree s ON p.parent_id = s.id
+			WHERE s.depth < 32
+		)
+		SELECT id, parent_id, name, prefix, description, others FROM subtree ORDER BY depth, id`, rootID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var nodes []precastSubtreeNode
+	for rows.Next() {
+		var n precastSubtreeNode
+		if err := rows.Scan(&n.ID, &n.ParentID, &n.Name, &n.Prefix, &n.Description, &n.Others); err != nil {
+			return nil, err
+		}
+		nodes = append(nodes, n)
+	}
+	return nodes, rows.Err()
+}
+
+// nestHierarchy assembles the node with the given ID and its descendants from a flat list.
+func nestHierarchy(id int, nodes map[int]models.HierarchyNode, children map[int][]int) models.HierarchyNode {
+	node := nodes[id]
+	for _, childID := range children[id] {
+		node.Children = append(node.Children, nestHierarchy(childID, nodes, children))
+	}
+	return node
+}
+
+// letterSequence turns 1, 2, ... 26, 27 into A, B, ... Z, AA.
+func letterSequence(n int) string {
+	var s []byte
+	for n > 0 {
+		n--
+		s = append([]byte{byte('A' + n%26)}, s...)
+		n /= 26
+	}
+	return string(s)
+}
+
+// renderHierarchyPattern fills a level name or prefix pattern for the node with running number n.
+func renderHierarchyPattern(pattern string, n int, name string) (string, error) {
+	out := hierarchyPatternToken.ReplaceAllStringFunc(pattern, func(token string) string {
+		switch spec := token[1 : len(token)-1]; {
+		case spec == "n":
+			return strconv.Itoa(n)
+		case spec == "a":
+			return letterSequence(n)
+		case spec == "name":
+			return name
+		default:
+			width, _ := strconv.Atoi(spec[2:])
+			return fmt.Sprintf("%0*d", width, n)
+		}
+	})
+	if strings.ContainsAny(out, "{}") {
+		return "", fmt.Errorf("pattern %q has an unknown token, use {n}, {n:2}, {a} or {name}", pattern)
+	}
+	return strings.TrimSpace(out), nil
+}
+
+// hierarchyLevelNodes renders the names and prefixes of the nodes of one level of a template.
+func hierarchyLevelNodes(level models.HierarchyLevelTemplate) (names, prefixes []string, err error) {
+	count := level.Count
+	if len(level.Names) > 0 {
+		count = len(level.Names)
+	}
+	if count < 1 || count > maxHierarchyLevelCount {
+		return nil, nil, fmt.Errorf("count must be from 1 to %d", maxHierarchyLevelCount)
+	}
+	start := 1
+	if level.Start != nil {
+		start = *level.Start
+	}
+	if start < 0 || (start == 0 && (strings.Contains(level.Name, "{a}") || strings.Contains(level.Prefix, "{a}"))) {
+		return nil, nil, fmt.Errorf("start must not be negative, or below 1 with {a}")
+	}
+	namePattern := level.Name
+	if namePattern == "" && len(level.Names) > 0 {
+		namePattern = "{name}"
+	}
+	if namePattern == "" || level.Prefix == "" {
+		return nil, nil, fmt.Errorf("name and prefix patterns are required")
+	}
+
+	seen := map[string]bool{}
+	for i := 0; i < count; i++ {
+		given := ""
+		if len(level.Names) > 0 {
+			given = strings.TrimSpace(level.Names[i])
+		}
+		name, err := renderHierarchyPattern(namePattern, start+i, given)
+		if err != nil {
+			return nil, nil, err
+		}
+		prefix, err := renderHierarchyPattern(level.Prefix, start+i, given)
+		if err != nil {
+			return nil, nil, err
+		}
+		if name == "" {
+			return nil, nil, fmt.Errorf("node %d has an empty name", i+1)
+		}
+		if seen[strings.ToLower(name)] {
+			return nil, nil, fmt.Errorf("name %q comes out more than once", name)
+		}
+		if _, err := precastPath([]string{name}); err != nil {
+			return nil, nil, err
+		}
+		seen[strings.ToLower(name)] = true
+		names = append(names, name)
+		prefixes = append(prefixes, prefix)
+	}
+	return names, prefixes, nil
+}
+
+// savePrecastBulkActivity writes the activity log of a structure change.
+func savePrecastBulkActivity(db *sql.DB, session models.Session, userName, eventName, description string, projectID int) {
+	activityLog := models.ActivityLog{
+		EventContext: "Precast",
+		EventName:    eventName,
+		Description:  description,
+		UserName:     userName,
+		HostName:     session.HostName,
+		IPAddress:    session.IPAddress,
+		CreatedAt:    time.Now(),
+		ProjectID:    projectID,
+	}
+	if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+		log.Printf("Failed to save activity log: %v", logErr)
+	}
+}
+
+// GenerateHierarchy builds a hierarchy from a template in one go, e.g. 4 towers x 40 floors x 2 zones,
+// naming each node from its level's patterns. Set preview to see the tree without saving it.
+// @Summary Generate hierarchy from template
+// @Tags Precast
+// @Accept json
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param request body models.GenerateHierarchyRequest true "Hierarchy template"
+// @Success 201 {array} models.HierarchyNode
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/precast/generate [post]
+func GenerateHierarchy(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+		var req models.GenerateHierarchyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		if len(req.Levels) == 0 || len(req.Levels) > maxHierarchyLevels {
+			c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("levels must have 1 to %d entries", maxHierarchyLevels)})
+			return
+		}
+
+		levelNames := make([][]string, len(req.Levels))
+		levelPrefixes := make([][]string, len(req.Levels))
+		total, perParent := 0, 1
+		for i, level := range req.Levels {
+			levelNames[i], levelPrefixes[i], err = hierarchyLevelNodes(level)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Level %d: %v", i+1, err)})
+				return
+			}
+			perParent *= len(levelNames[i])
+			total += perParent
+			if total > maxHierarchyNodes {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("The template makes more than %d nodes", maxHierarchyNodes)})
+				return
+			}
+		}
+
+		baseNames, basePrefixes, err := parentChain(db, projectID, req.ParentID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Parent not found in this project"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch parent", "details": err.Error()})
+			return
+		}
+		if len(baseNames)+len(req.Levels) > 32 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "The hierarchy would be too deep"})
+			return
+		}
+		for _, name := range levelNames[0] {
+			taken, err := siblingNameTaken(db, projectID, req.ParentID, name, 0)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing nodes", "details": err.Error()})
+				return
+			}
+			if taken {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%q already exists under this parent", name)})
+				return
+			}
+		}
+
+		// build lays out the nodes of level i under a parent, inserting them when tx is set
+		var build func(tx *sql.Tx, i int, parentID *int64, names, prefixes []string) ([]models.HierarchyNode, error)
+		build = func(tx *sql.Tx, i int, parentID *int64, names, prefixes []string) ([]models.HierarchyNode, error) {
+			if i == len(req.Levels) {
+				return nil, nil
+			}
+			nodes := make([]models.HierarchyNode, 0, len(levelNames[i]))
+			for j := range levelNames[i] {
+				chainNames := append(append([]string{}, names...), levelNames[i][j])
+				chainPrefixes := append(append([]string{}, prefixes...), levelPrefixes[i][j])
+				var node models.HierarchyNode
+				if tx != nil {
+					node, err = insertPrecastNode(tx, projectID, parentID, req.Levels[i].Description, false, chainNames, chainPrefixes)
+				} else {
+					node = models.HierarchyNode{Name: levelNames[i][j], Prefix: levelPrefixes[i][j],
+						NamingConvention: strings.Join(chainPrefixes, "_")}
+					node.Path, err = precastPath(chainNames)
+				}
+				if err != nil {
+					return nil, err
+				}
+				id := int64(node.ID)
+				if node.Children, err = build(tx, i+1, &id, chainNames, chainPrefixes); err != nil {
+					return nil, err
+				}
+				nodes = append(nodes, node)
+			}
+			return nodes, nil
+		}
+
+		if req.Preview {
+			nodes, err := build(nil, 0, req.ParentID, baseNames, basePrefixes)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			c.JSON(http.StatusOK, gin.H{"nodes_to_create": total, "nodes": nodes})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction", "details": err.Error()})
+			return
+		}
+		defer tx.Rollback()
+		if err := lockPrecastTree(tx, projectID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock hierarchy", "details": err.Error()})
+			return
+		}
+		for _, name := range levelNames[0] {
+			taken, err := siblingNameTaken(tx, projectID, req.ParentID, name, 0)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing nodes", "details": err.Error()})
+				return
+			}
+			if taken {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%q already exists under this parent", name)})
+				return
+			}
+		}
+		nodes, err := build(tx, 0, req.ParentID, baseNames, basePrefixes)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create hierarchy", "details": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit hierarchy", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"created": total, "nodes": nodes})
+
+		sendProjectNotifications(db, projectID,
+			fmt.Sprintf("%d structure nodes generated for the project", total),
+			fmt.Sprintf(HierarchyActionTemplate, projectID))
+		savePrecastBulkActivity(db, session, userName, "Generate",
+			fmt.Sprintf("Generated %d precast nodes from a %d-level template", total, len(req.Levels)), projectID)
+	}
+}
+
+// CloneHierarchy copies a node with everything under it, e.g. one finished tower as the next. The
+// copy takes the given name and prefix; the nodes under it keep theirs. Elements are not copied.
+// @Summary Clone hierarchy subtree
+// @Tags Precast
+// @Accept json
+// @Produce json
+// @Param id path int true "Precast ID"
+// @Param request body models.CloneHierarchyRequest true "Copy"
+// @Success 201 {object} models.HierarchyNode
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/precast/{id}/clone [post]
+func CloneHierarchy(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+		root, projectID, ok := precastNodeParam(c, db)
+		if !ok {
+			return
+		}
+		var req models.CloneHierarchyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		req.Name = strings.TrimSpace(req.Name)
+		if _, err := precastPath([]string{req.Name}); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction", "details": err.Error()})
+			return
+		}
+		defer tx.Rollback()
+		if err := lockPrecastTree(tx, projectID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock hierarchy", "details": err.Error()})
+			return
+		}
+
+		baseNames, basePrefixes, err := parentChain(tx, projectID, req.ParentID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Parent not found in this project"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch parent", "details": err.Error()})
+			return
+		}
+		taken, err := siblingNameTaken(tx, projectID, req.ParentID, req.Name, 0)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing nodes", "details": err.Error()})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%q already exists under this parent", req.Name)})
+			return
+		}
+		subtree, err := loadPrecastSubtree(tx, root.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch hierarchy", "details": err.Error()})
+			return
+		}
+
+		// Parents come before children, so every copy's parent is already made
+		type copied struct {
+			id       int64
+			names    []string
+			prefixes []string
+		}
+		copies := map[int]copied{}
+		nodes := map[int]models.HierarchyNode{}
+		children := map[int][]int{}
+		for _, n := range subtree {
+			parentID, names, prefixes := req.ParentID, baseNames, basePrefixes
+			name, prefix := n.Name, n.Prefix
+			if n.ID == root.ID {
+				name, prefix = req.Name, req.Prefix
+			} else {
+				parent := copies[int(n.ParentID.Int64)]
+				parentID, names, prefixes = &parent.id, parent.names, parent.prefixes
+				children[int(n.ParentID.Int64)] = append(children[int(n.ParentID.Int64)], n.ID)
+			}
+			names = append(append([]string{}, names...), name)
+			prefixes = append(append([]string{}, prefixes...), prefix)
+			node, err := insertPrecastNode(tx, projectID, parentID, n.Description, n.Others, names, prefixes)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to copy hierarchy", "details": err.Error()})
+				return
+			}
+			copies[n.ID] = copied{id: int64(node.ID), names: names, prefixes: prefixes}
+			nodes[n.ID] = node
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit hierarchy", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, gin.H{"created": len(subtree), "node": nestHierarchy(root.ID, nodes, children)})
+
+		sendProjectNotifications(db, projectID,
+			fmt.Sprintf("%s copied as %s (%d structure nodes)", root.Name, req.Name, len(subtree)),
+			fmt.Sprintf(HierarchyActionTemplate, projectID))
+		savePrecastBulkActivity(db, session, userName, "Clone",
+			fmt.Sprintf("Cloned precast %s (%d nodes) as %s", root.Name, len(subtree), req.Name), projectID)
+	}
+}
+
+// MoveHierarchy moves a node with everything under it to a new parent. The paths and naming
+// conventions of the moved nodes are rebuilt and the tower of their element quantities follows the
+// move. Elements keep their hierarchy and their IDs.
+// @Summary Move hierarchy subtree
+// @Tags Precast
+// @Accept json
+// @Produce json
+// @Param id path int true "Precast ID"
+// @Param request body models.MoveHierarchyRequest true "New parent"
+// @Success 200 {object} models.HierarchyNode
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/precast/{id}/move [post]
+func MoveHierarchy(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+		root, projectID, ok := precastNodeParam(c, db)
+		if !ok {
+			return
+		}
+		var req models.MoveHierarchyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction", "details": err.Error()})
+			return
+		}
+		defer tx.Rollback()
+		if err := lockPrecastTree(tx, projectID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock hierarchy", "details": err.Error()})
+			return
+		}
+
+		subtree, err := loadPrecastSubtree(tx, root.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch hierarchy", "details": err.Error()})
+			return
+		}
+		ids := make([]int, 0, len(subtree))
+		for _, n := range subtree {
+			ids = append(ids, n.ID)
+			if req.ParentID != nil && int64(n.ID) == *req.ParentID {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "A node can't be moved under itself or its own children"})
+				return
+			}
+		}
+		baseNames, basePrefixes, err := parentChain(tx, projectID, req.ParentID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Parent not found in this project"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch parent", "details": err.Error()})
+			return
+		}
+		taken, err := siblingNameTaken(tx, projectID, req.ParentID, root.Name, root.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check existing nodes", "details": err.Error()})
+			return
+		}
+		if taken {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%q already exists under the new parent", root.Name)})
+			return
+		}
+
+		if _, err := tx.Exec(`UPDATE precast SET parent_id = $1 WHERE id = $2`, req.ParentID, root.ID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move node", "details": err.Error()})
+			return
+		}
+		type chain struct{ names, prefixes []string }
+		chains := map[int]chain{}
+		nodes := map[int]models.HierarchyNode{}
+		children := map[int][]int{}
+		for _, n := range subtree {
+			names, prefixes := baseNames, basePrefixes
+			if n.ID != root.ID {
+				parent := chains[int(n.ParentID.Int64)]
+				names, prefixes = parent.names, parent.prefixes
+				children[int(n.ParentID.Int64)] = append(children[int(n.ParentID.Int64)], n.ID)
+			}
+			names = append(append([]string{}, names...), n.Name)
+			prefixes = append(append([]string{}, prefixes...), n.Prefix)
+			chains[n.ID] = chain{names, prefixes}
+
+			node := models.HierarchyNode{ID: n.ID, Name: n.Name, Prefix: n.Prefix, NamingConvention: strings.Join(prefixes, "_")}
+			if node.Path, err = precastPath(names); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if _, err := tx.Exec(`UPDATE precast SET path = $1, naming_convention = $2 WHERE id = $3`,
+				node.Path, node.NamingConvention, n.ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update hierarchy paths", "details": err.Error()})
+				return
+			}
+			nodes[n.ID] = node
+		}
+
+		// element_type_quantity keeps the tower of each floor
+		if _, err := tx.Exec(`
+			UPDATE element_type_quantity q SET tower = p.parent_id
+			FROM precast p
+			WHERE p.id = q.floor AND q.floor = ANY($1) AND p.parent_id IS NOT NULL AND q.tower <> p.parent_id`,
+			pq.Array(ids)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update element quantities", "details": err.Error()})
+			return
+		}
+		var elementCount int
+		if err := tx.QueryRow(`SELECT COUNT(*) FROM element WHERE target_location = ANY($1)`, pq.Array(ids)).Scan(&elementCount); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to count elements", "details": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit move", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":  "Hierarchy moved successfully",
+			"moved":    len(subtree),
+			"elements": elementCount,
+			"node":     nestHierarchy(root.ID, nodes, children),
+		})
+
+		savePrecastBulkActivity(db, session, userName, "Move",
+			fmt.Sprintf("Moved precast %s (%d nodes, %d elements)", root.Name, len(subtree), elementCount), projectID)
+	}
+}
+
+// DeleteHierarchy deletes a node with everything under it. It is refused while any element placed
+// there is in production or beyond; elements not yet started are archived to deleted_element and
+// removed with their planned quantities.
+// @Summary Delete hierarchy subtree
+// @Tags Precast
+// @Produce json
+// @Param id path int true "Precast ID"
+// @Success 200 {object} models.MessageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/precast/{id}/subtree [delete]
+func DeleteHierarchy(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+		root, projectID, ok := precastNodeParam(c, db)
+		if !ok {
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction", "details": err.Error()})
+			return
+		}
+		defer tx.Rollback()
+		if err := lockPrecastTree(tx, projectID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock hierarchy", "details": err.Error()})
+			return
+		}
+
+		subtree, err := loadPrecastSubtree(tx, root.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch hierarchy", "details": err.Error()})
+			return
+		}
+		ids := make([]int, 0, len(subtree))
+		for _, n := range subtree {
+			ids = append(ids, n.ID)
+		}
+
+		rows, err := tx.Query(`SELECT id, target_location FROM element WHERE target_location = ANY($1) FOR UPDATE`, pq.Array(ids))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch elements", "details": err.Error()})
+			return
+		}
+		locations := map[int]int{}
+		for rows.Next() {
+			var id, location int
+			if err := rows.Scan(&id, &location); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch elements", "details": err.Error()})
+				return
+			}
+			locations[id] = location
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch elements", "details": err.Error()})
+			return
+		}
+
+		states, err := loadElementStates(tx, `WHERE e.target_location = ANY($1) ORDER BY e.id`, pq.Array(ids))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch element states", "details": err.Error()})
+			return
+		}
+		blockers := []models.HierarchyBlocker{}
+		blocked := 0
+		var planned []int
+		for _, s := range states {
+			if state := s.current(); state != models.LifecyclePlanned {
+				blocked++
+				if len(blockers) < maxHierarchyBlockersList {
+					blockers = append(blockers, models.HierarchyBlocker{
+						ElementID: s.ElementID, ElementName: s.ElementName, HierarchyID: locations[s.ElementID], State: state,
+					})
+				}
+				continue
+			}
+			planned = append(planned, s.ElementID)
+		}
+		if blocked > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":            fmt.Sprintf("%d elements under %s are already in production or cast", blocked, root.Name),
+				"blocked_elements": blocked,
+				"blockers":         blockers,
+			})
+			return
+		}
+
+		if len(planned) > 0 {
+			plannedIDs := pq.Array(planned)
+			if _, err := tx.Exec(`
+				INSERT INTO deleted_element (id, element_type_id, element_id, element_name, project_id, created_by, created_at,
+					status, element_type_version, update_at, target_location, deleted_by)
+				SELECT id, element_type_id, element_id, element_name, project_id, created_by, created_at,
+					status, element_type_version, update_at, target_location, $2
+				FROM element WHERE id = ANY($1)`, plannedIDs, session.UserID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to archive elements", "details": err.Error()})
+				return
+			}
+			if _, err := tx.Exec(`
+				UPDATE element_type et SET total_count_element = GREATEST(COALESCE(et.total_count_element, 0) - d.n, 0)
+				FROM (SELECT element_type_id, COUNT(*) AS n FROM element WHERE id = ANY($1) GROUP BY element_type_id) d
+				WHERE et.element_type_id = d.element_type_id`, plannedIDs); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update element type counts", "details": err.Error()})
+				return
+			}
+			if _, err := tx.Exec(`DELETE FROM element_lifecycle WHERE element_id = ANY($1)`, plannedIDs); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete element lifecycle", "details": err.Error()})
+				return
+			}
+			if _, err := tx.Exec(`DELETE FROM element WHERE id = ANY($1)`, plannedIDs); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete elements", "details": err.Error()})
+				return
+			}
+		}
+		if _, err := tx.Exec(`DELETE FROM element_type_hierarchy_quantity WHERE hierarchy_id = ANY($1)`, pq.Array(ids)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete element quantities", "details": err.Error()})
+			return
+		}
+		if _, err := tx.Exec(`DELETE FROM element_type_quantity WHERE floor = ANY($1) OR tower = ANY($1)`, pq.Array(ids)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete element quantities", "details": err.Error()})
+			return
+		}
+		// Children first
+		for i := len(subtree) - 1; i >= 0; i-- {
+			if _, err := tx.Exec(`DELETE FROM precast WHERE id = $1`, subtree[i].ID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete precast", "details": err.Error()})
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit delete", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":          "Hierarchy deleted successfully",
+			"deleted_nodes":    len(subtree),
+			"deleted_elements": len(planned),
+		})
+
+		sendProjectNotifications(db, projectID,
+			fmt.Sprintf("%s deleted from the structure with %d nodes and %d planned elements", root.Name, len(subtree), len(planned)),
+			fmt.Sprintf(HierarchyActionTemplate, projectID))
+		savePrecastBulkActivity(db, session, userName, "Delete",
+			fmt.Sprintf("Deleted precast %s (%d nodes, %d planned elements)", root.Name, len(subtree), len(planned)), projectID)
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"backend/utils"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
@@ -17,14 +18,16 @@ import (
 	"github.com/lib/pq"
 )
 
-func CreatePrecastStock(db *sql.DB, elementID int, projectID int, stockyardID int) (int, error) {
+// CreatePrecastStock moves a finished element into stock within the caller's transaction, so a
+// failed completion also undoes its lifecycle move.
+func CreatePrecastStock(db *sql.DB, tx *sql.Tx, elementID int, projectID int, stockyardID int) (int, error) {
 	var elementTypeID, targetLocation int
 	var elementName string
 	var disable bool
 
 	// Fetch element details from the element table
 	query := "SELECT element_type_id, element_name, target_location, disable FROM element WHERE id = $1 AND project_id = $2 LIMIT 1"
-	err := db.QueryRow(query, elementID, projectID).Scan(&elementTypeID, &elementName, &targetLocation, &disable)
+	err := tx.QueryRow(query, elementID, projectID).Scan(&elementTypeID, &elementName, &targetLocation, &disable)
 	if err != nil {
 		return 0, fmt.Errorf("element not found or database error: %w", err)
 	}
@@ -33,7 +36,7 @@ func CreatePrecastStock(db *sql.DB, elementID int, projectID int, stockyardID in
 	var thickness, length, height, weight, density float32
 	var elementTypeName, elementType string
 	query = "SELECT element_type, element_type_name, thickness, length, height, mass, density FROM element_type WHERE element_type_id = $1 AND project_id = $2 LIMIT 1"
-	err = db.QueryRow(query, elementTypeID, projectID).Scan(&elementType, &elementTypeName, &thickness, &length, &height, &weight, &density)
+	err = tx.QueryRow(query, elementTypeID, projectID).Scan(&elementType, &elementTypeName, &thickness, &length, &height, &weight, &density)
 	if err != nil {
 		return 0, fmt.Errorf("element type not found or database error: %w", err)
 	}
@@ -50,6 +53,11 @@ func CreatePrecastStock(db *sql.DB, elementID int, projectID int, stockyardID in
 	storageLocation := "default_location"
 	dispatchStatus := false
 
+	// Production is complete, so the element has passed QC and waits for the stockyard to receive it
+	if err := TransitionElements(tx, []int{elementID}, models.LifecycleQCPassed, "Production completed", "system"); err != nil {
+		return 0, fmt.Errorf("failed to move element to %s: %w", models.LifecycleQCPassed, err)
+	}
+
 	// Insert into precast_stock table
 	query = `
 			INSERT INTO precast_stock (
@@ -61,7 +69,7 @@ func CreatePrecastStock(db *sql.DB, elementID int, projectID int, stockyardID in
 		`
 
 	var insertedID int
-	err = db.QueryRow(query, elementID, elementType, elementTypeID, stockyardID,
+	err = tx.QueryRow(query, elementID, elementType, elementTypeID, stockyardID,
 		dimensions, elementWeight, productionDate, storageLocation,
 		dispatchStatus, projectID, targetLocation).Scan(&insertedID)
 
@@ -70,26 +78,26 @@ func CreatePrecastStock(db *sql.DB, elementID int, projectID int, stockyardID in
 	}
 
 	var projectName string
-	err = db.QueryRow("SELECT name FROM project WHERE project_id = $1", projectID).Scan(&projectName)
+	err = tx.QueryRow("SELECT name FROM project WHERE project_id = $1", projectID).Scan(&projectName)
 	if err != nil {
 		log.Printf("Failed to fetch project name: %v", err)
 		projectName = fmt.Sprintf("Project %d", projectID)
 	}
 
 	var endclientId int
-	err = db.QueryRow("SELECT client_id FROM project WHERE project_id = $1", projectID).Scan(&endclientId)
+	err = tx.QueryRow("SELECT client_id FROM project WHERE project_id = $1", projectID).Scan(&endclientId)
 	if err != nil {
 		log.Printf("Failed to fetch client_id for notification: %v", err)
 	}
 
 	var clientId int
-	err = db.QueryRow("SELECT client_id FROM end_client WHERE id = $1", endclientId).Scan(&clientId)
+	err = tx.QueryRow("SELECT client_id FROM end_client WHERE id = $1", endclientId).Scan(&clientId)
 	if err != nil {
 		log.Printf("Failed to fetch client_id for notification: %v", err)
 	}
 
 	var clientUserId int
-	err = db.QueryRow("SELECT user_id FROM client WHERE client_id = $1", clientId).Scan(&clientUserId)
+	err = tx.QueryRow("SELECT user_id FROM client WHERE client_id = $1", clientId).Scan(&clientUserId)
 	if err != nil {
 		log.Printf("Failed to fetch client_user_id for notification: %v", err)
 	}
@@ -436,6 +444,24 @@ func UpdateStockyardReceived(db *sql.DB) gin.HandlerFunc {
 			}
 		}()
 
+		// Move the elements into the stockyard, which sets precast_stock.stockyard and element status
+		if err = TransitionElements(tx, req.ElementIDs, models.LifecycleInYard, "Received in stockyard", userName); err != nil {
+			var illegalErr *models.ErrIllegalTransition
+			if errors.As(err, &illegalErr) {
+				c.JSON(http.StatusConflict, gin.H{
+					"error":     "Some elements can't be received in the stockyard from the state they are in",
+					"conflicts": illegalErr.Elements,
+				})
+				return
+			}
+			log.Printf("Failed to update element lifecycle: %v", err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error":   "Failed to update element lifecycle",
+				"details": err.Error(),
+			})
+			return
+		}
+
 		// Update precast stock and collect updated records
 		updateQuery := `
 			UPDATE precast_stock
@@ -494,23 +520,23 @@ func UpdateStockyardReceived(db *sql.DB) gin.HandlerFunc {
 		}
 		rows.Close()
 
-		_, err = tx.Exec(`
-				UPDATE element
-				SET status = 'In Stockyard'
-				WHERE id = ANY($1) 
-				`, pq.Array(req.ElementIDs))
+		log.Printf("Successfully collected %d updated records", len(updatedRecords))
+
+		// Record the slots the pieces were put in and suggest one for the rest
+		receivedIDs := make([]int, 0, len(updatedRecords))
+		for _, record := range updatedRecords {
+			receivedIDs = append(receivedIDs, record.ElementID)
+		}
+		putAway, putAwayStatus, err := putAwayStock(tx, req.ProjectID, receivedIDs, req.Slots, req.ForceSlots, userName)
 		if err != nil {
-			log.Printf("Failed to update element status: %v", err)
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error":   "Failed to update element status",
+			log.Printf("Failed to put away received stock: %v", err)
+			c.JSON(putAwayStatus, gin.H{
+				"error":   "Failed to put away received stock",
 				"details": err.Error(),
-				"context": "Element status update error",
 			})
 			return
 		}
 
-		log.Printf("Successfully collected %d updated records", len(updatedRecords))
-
 		// Now process the collected records
 		logQuery := `
 			INSERT INTO precast_stock_approval_logs (
@@ -558,11 +584,12 @@ func UpdateStockyardReceived(db *sql.DB) gin.HandlerFunc {
 			c.JSON(http.StatusOK, gin.H{
 				"message":    "Stockyard received status updated with some log creation errors",
 				"log_errors": logErrors,
+				"put_away":   putAway,
 			})
 			return
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Stockyard received status updated successfully"})
+		c.JSON(http.StatusOK, gin.H{"message": "Stockyard received status updated successfully", "put_away": putAway})
 
 		// Get project name for notification
 		var projectName string
@@ -0,0 +1,655 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// normaliseQCQuestionSpec fills defaults and rejects specs that cannot be evaluated.
+func normaliseQCQuestionSpec(spec *models.QCQuestionSpec) error {
+	if spec.AnswerType == "" {
+		spec.AnswerType = models.QCAnswerChoice
+	}
+	if spec.Severity == "" {
+		spec.Severity = models.QCSeverityMinor
+	}
+	if spec.Severity != models.QCSeverityCritical && spec.Severity != models.QCSeverityMinor {
+		return fmt.Errorf("severity must be critical or minor")
+	}
+	switch spec.AnswerType {
+	case models.QCAnswerNumeric:
+		if spec.ReferenceField != "" {
+			if _, ok := models.QCReferenceFields[spec.ReferenceField]; !ok {
+				return fmt.Errorf("reference_field must be one of length, width, height, thickness, mass, volume")
+			}
+		}
+		hasTarget := spec.ReferenceField != "" || spec.TargetValue != nil
+		if !hasTarget && spec.MinValue == nil && spec.MaxValue == nil {
+			return fmt.Errorf("numeric questions need a reference_field, a target_value or min/max bounds")
+		}
+		if (spec.ToleranceMinus != nil && *spec.ToleranceMinus < 0) || (spec.TolerancePlus != nil && *spec.TolerancePlus < 0) {
+			return fmt.Errorf("tolerances must not be negative")
+		}
+		if spec.MinValue != nil && spec.MaxValue != nil && *spec.MinValue > *spec.MaxValue {
+			return fmt.Errorf("min_value must not exceed max_value")
+		}
+	case models.QCAnswerChoice, models.QCAnswerYesNo, models.QCAnswerText:
+	default:
+		return fmt.Errorf("answer_type must be one of choice, numeric, yes_no, text")
+	}
+	if spec.AnswerType != models.QCAnswerChoice {
+		spec.FailOptions = nil
+	}
+	if spec.ExpectedYes == nil {
+		yes := true
+		spec.ExpectedYes = &yes
+	}
+	return nil
+}
+
+// saveQCQuestionSpec upserts the spec of a question.
+func saveQCQuestionSpec(q sqlQueryer, spec models.QCQuestionSpec) error {
+	_, err := q.Exec(`
+		INSERT INTO qc_question_spec (question_id, answer_type, severity, unit, reference_field, target_value,
+			tolerance_minus, tolerance_plus, min_value, max_value, expected_yes, fail_options, photo_required, comment_on_fail)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14)
+		ON CONFLICT (question_id) DO UPDATE SET
+			answer_type = EXCLUDED.answer_type, severity = EXCLUDED.severity, unit = EXCLUDED.unit,
+			reference_field = EXCLUDED.reference_field, target_value = EXCLUDED.target_value,
+			tolerance_minus = EXCLUDED.tolerance_minus, tolerance_plus = EXCLUDED.tolerance_plus,
+			min_value = EXCLUDED.min_value, max_value = EXCLUDED.max_value, expected_yes = EXCLUDED.expected_yes,
+			fail_options = EXCLUDED.fail_options, photo_required = EXCLUDED.photo_required,
+			comment_on_fail = EXCLUDED.comment_on_fail`,
+		spec.QuestionID, spec.AnswerType, spec.Severity, spec.Unit, spec.ReferenceField, spec.TargetValue,
+		spec.ToleranceMinus, spec.TolerancePlus, spec.MinValue, spec.MaxValue, spec.ExpectedYes == nil || *spec.ExpectedYes,
+		pq.Array(nonNilStrings(spec.FailOptions)), spec.PhotoRequired, spec.CommentOnFail)
+	return err
+}
+
+func nonNilStrings(s []string) []string {
+	if s == nil {
+		return []string{}
+	}
+	return s
+}
+
+const selectQCQuestionSpecSQL = `
+	SELECT question_id, answer_type, severity, unit, reference_field, target_value, tolerance_minus, tolerance_plus,
+		min_value, max_value, expected_yes, fail_options, photo_required, comment_on_fail
+	FROM qc_question_spec`
+
+func scanQCQuestionSpec(row interface{ Scan(...any) error }) (models.QCQuestionSpec, error) {
+	var spec models.QCQuestionSpec
+	var target, tolMinus, tolPlus, minValue, maxValue sql.NullFloat64
+	var expectedYes bool
+	err := row.Scan(&spec.QuestionID, &spec.AnswerType, &spec.Severity, &spec.Unit, &spec.ReferenceField, &target,
+		&tolMinus, &tolPlus, &minValue, &maxValue, &expectedYes, pq.Array(&spec.FailOptions),
+		&spec.PhotoRequired, &spec.CommentOnFail)
+	spec.ExpectedYes = &expectedYes
+	spec.TargetValue = nullFloatPtr(target)
+	spec.ToleranceMinus = nullFloatPtr(tolMinus)
+	spec.TolerancePlus = nullFloatPtr(tolPlus)
+	spec.MinValue = nullFloatPtr(minValue)
+	spec.MaxValue = nullFloatPtr(maxValue)
+	return spec, err
+}
+
+func nullFloatPtr(v sql.NullFloat64) *float64 {
+	if !v.Valid {
+		return nil
+	}
+	return &v.Float64
+}
+
+// loadQCQuestionSpecs returns the specs of the given questions keyed by question id.
+func loadQCQuestionSpecs(q sqlQueryer, questionIDs []int) (map[int]models.QCQuestionSpec, error) {
+	specs := make(map[int]models.QCQuestionSpec)
+	if len(questionIDs) == 0 {
+		return specs, nil
+	}
+	rows, err := q.Query(selectQCQuestionSpecSQL+` WHERE question_id = ANY($1)`, pq.Array(questionIDs))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		spec, err := scanQCQuestionSpec(rows)
+		if err != nil {
+			return nil, err
+		}
+		specs[spec.QuestionID] = spec
+	}
+	return specs, rows.Err()
+}
+
+// expectedQCRange returns the accepted range of a numeric check; nil bounds are open.
+func expectedQCRange(spec models.QCQuestionSpec, dims map[string]float64) (min, max *float64, err error) {
+	var target *float64
+	if spec.ReferenceField != "" {
+		v := dims[spec.ReferenceField]
+		if v == 0 {
+			return nil, nil, fmt.Errorf("element type has no %s to measure against", spec.ReferenceField)
+		}
+		target = &v
+	} else if spec.TargetValue != nil {
+		target = spec.TargetValue
+	}
+	if target == nil {
+		return spec.MinValue, spec.MaxValue, nil
+	}
+	lo, hi := *target, *target
+	if spec.ToleranceMinus != nil {
+		lo -= *spec.ToleranceMinus
+	}
+	if spec.TolerancePlus != nil {
+		hi += *spec.TolerancePlus
+	}
+	return &lo, &hi, nil
+}
+
+// evaluateQCAnswer decides whether one typed answer passes. Problems are answers that are
+// incomplete for their type (missing measurement, photo or failure comment).
+func evaluateQCAnswer(spec models.QCQuestionSpec, answer models.QCAnswerInput, optionText string, dims map[string]float64) (models.QCAnswerResult, []string) {
+	result := models.QCAnswerResult{
+		QuestionID: answer.QuestionID,
+		AnswerType: spec.AnswerType,
+		Severity:   spec.Severity,
+		OptionText: optionText,
+		Comment:    strings.TrimSpace(answer.Comment),
+		ImagePath:  strings.TrimSpace(answer.ImagePath),
+		Passed:     true,
+	}
+	var problems []string
+
+	switch spec.AnswerType {
+	case models.QCAnswerNumeric:
+		if answer.NumericValue == nil {
+			problems = append(problems, "a measurement is required")
+			break
+		}
+		result.NumericValue = answer.NumericValue
+		min, max, err := expectedQCRange(spec, dims)
+		if err != nil {
+			problems = append(problems, err.Error())
+			break
+		}
+		result.ExpectedMin, result.ExpectedMax = min, max
+		v := *answer.NumericValue
+		// Round away floating point noise before comparing against the bounds
+		const eps = 1e-9
+		if min != nil && v < *min-eps {
+			result.Passed = false
+			result.Reason = fmt.Sprintf("%s%s is below the minimum %s%s", formatQCValue(v), spec.Unit, formatQCValue(*min), spec.Unit)
+		} else if max != nil && v > *max+eps {
+			result.Passed = false
+			result.Reason = fmt.Sprintf("%s%s is above the maximum %s%s", formatQCValue(v), spec.Unit, formatQCValue(*max), spec.Unit)
+		}
+	case models.QCAnswerYesNo:
+		value := answer.BoolValue
+		if value == nil && optionText != "" {
+			switch strings.ToLower(strings.TrimSpace(optionText)) {
+			case "yes", "y", "true", "ok":
+				v := true
+				value = &v
+			case "no", "n", "false", "not ok":
+				v := false
+				value = &v
+			}
+		}
+		if value == nil {
+			problems = append(problems, "a yes/no answer is required")
+			break
+		}
+		result.BoolValue = value
+		expected := spec.ExpectedYes == nil || *spec.ExpectedYes
+		if *value != expected {
+			result.Passed = false
+			result.Reason = "answered " + yesNo(*value) + ", expected " + yesNo(expected)
+		}
+	case models.QCAnswerChoice:
+		if answer.OptionID == 0 {
+			problems = append(problems, "an option must be selected")
+			break
+		}
+		for _, fail := range spec.FailOptions {
+			if strings.EqualFold(strings.TrimSpace(fail), strings.TrimSpace(optionText)) {
+				result.Passed = false
+				result.Reason = "option " + optionText + " fails the check"
+			}
+		}
+	case models.QCAnswerText:
+		if result.Comment == "" {
+			problems = append(problems, "a written answer is required")
+		}
+	}
+
+	if spec.PhotoRequired && result.ImagePath == "" {
+		problems = append(problems, "a photo is required")
+	}
+	if !result.Passed && spec.CommentOnFail && result.Comment == "" {
+		problems = append(problems, "a comment is required when the check fails")
+	}
+	return result, problems
+}
+
+func yesNo(v bool) string {
+	if v {
+		return "yes"
+	}
+	return "no"
+}
+
+func formatQCValue(v float64) string {
+	if v == math.Trunc(v) {
+		return strconv.FormatFloat(v, 'f', 0, 64)
+	}
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
+
+// qcInspectionResult sums the evaluated answers: any failed critical check fails the inspection.
+func qcInspectionResult(answers []models.QCAnswerResult) (result string, critical, minor int) {
+	for _, a := range answers {
+		if a.Passed {
+			continue
+		}
+		if a.Severity == models.QCSeverityCritical {
+			critical++
+		} else {
+			minor++
+		}
+	}
+	switch {
+	case critical > 0:
+		return models.QCResultFail, critical, minor
+	case minor > 0:
+		return models.QCResultPassWithMinor, critical, minor
+	}
+	return models.QCResultPass, 0, 0
+}
+
+// qcSubmissionPaper returns the checklist paper of an activity, falling back to its stage's paper.
+func qcSubmissionPaper(tx *sql.Tx, activityID int) (int, error) {
+	var paperID int
+	err := tx.QueryRow(`
+		SELECT COALESCE(NULLIF(a.paper_id, 0), ps.paper_id, 0)
+		FROM activity a LEFT JOIN project_stages ps ON ps.id = a.stage_id
+		WHERE a.id = $1`, activityID).Scan(&paperID)
+	if err == sql.ErrNoRows {
+		return 0, nil
+	}
+	return paperID, err
+}
+
+// evaluateQCSubmission evaluates the typed questions of a QC submission against the checklist
+// paper of the activity. It returns nil when neither the paper nor the answers have typed
+// questions, so plain multiple-choice papers behave as before. Problems list incomplete
+// answers, answers to questions of another paper and unanswered critical checks.
+func evaluateQCSubmission(tx *sql.Tx, qcID int, activityID, taskID, elementID, stageID int, answers []models.QCAnswerInput) (*models.QCInspectionResult, []string, error) {
+	paperID, err := qcSubmissionPaper(tx, activityID)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to fetch QC paper of activity: %v", err)
+	}
+
+	// The paper's questions decide which checks are due, whatever was answered
+	paperQuestions := make(map[int]string)
+	questionIDs := make([]int, 0, len(answers))
+	if paperID != 0 {
+		rows, err := tx.Query(`SELECT id, question_text FROM questions WHERE paper_id = $1`, paperID)
+		if err != nil {
+			return nil, nil, fmt.Errorf("failed to fetch QC paper questions: %v", err)
+		}
+		for rows.Next() {
+			var id int
+			var text string
+			if err := rows.Scan(&id, &text); err != nil {
+				rows.Close()
+				return nil, nil, err
+			}
+			paperQuestions[id] = text
+			questionIDs = append(questionIDs, id)
+		}
+		rows.Close()
+		if err := rows.Err(); err != nil {
+			return nil, nil, err
+		}
+	}
+	for _, a := range answers {
+		if _, ok := paperQuestions[a.QuestionID]; !ok {
+			questionIDs = append(questionIDs, a.QuestionID)
+		}
+	}
+	specs, err := loadQCQuestionSpecs(tx, questionIDs)
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(specs) == 0 {
+		return nil, nil, nil
+	}
+
+	var l, w, h, t, m, v sql.NullFloat64
+	err = tx.QueryRow(`
+		SELECT et.length, et.width, et.height, et.thickness, et.mass, et.volume
+		FROM task tk JOIN element_type et ON et.element_type_id = tk.element_type_id
+		WHERE tk.task_id = $1`, taskID).Scan(&l, &w, &h, &t, &m, &v)
+	if err != nil && err != sql.ErrNoRows {
+		return nil, nil, fmt.Errorf("failed to fetch element type dimensions: %v", err)
+	}
+	dims := map[string]float64{"length": l.Float64, "width": w.Float64, "height": h.Float64,
+		"thickness": t.Float64, "mass": m.Float64, "volume": v.Float64}
+
+	inspection := &models.QCInspectionResult{
+		ActivityID: activityID,
+		ElementID:  elementID,
+		StageID:    stageID,
+		PaperID:    paperID,
+		QCID:       qcID,
+		Answers:    []models.QCAnswerResult{},
+	}
+	var problems []string
+	answered := make(map[int]bool)
+	for _, a := range answers {
+		spec, ok := specs[a.QuestionID]
+		if !ok {
+			continue
+		}
+		answered[a.QuestionID] = true
+
+		questionText, onPaper := paperQuestions[a.QuestionID]
+		if !onPaper {
+			var answerPaperID int
+			err := tx.QueryRow(`SELECT question_text, paper_id FROM questions WHERE id = $1`, a.QuestionID).Scan(&questionText, &answerPaperID)
+			if err == sql.ErrNoRows {
+				problems = append(problems, fmt.Sprintf("question %d does not exist", a.QuestionID))
+				continue
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to fetch QC question %d: %v", a.QuestionID, err)
+			}
+			if paperID != 0 {
+				problems = append(problems, fmt.Sprintf("%s: not a question of this stage's checklist", questionText))
+				continue
+			}
+			if inspection.PaperID == 0 {
+				inspection.PaperID = answerPaperID
+			}
+		}
+
+		var optionText string
+		if a.OptionID != 0 {
+			err := tx.QueryRow(`SELECT option_text FROM options WHERE id = $1 AND question_id = $2`, a.OptionID, a.QuestionID).Scan(&optionText)
+			if err == sql.ErrNoRows {
+				problems = append(problems, fmt.Sprintf("%s: option %d is not an option of this question", questionText, a.OptionID))
+				continue
+			}
+			if err != nil {
+				return nil, nil, fmt.Errorf("failed to fetch QC option %d: %v", a.OptionID, err)
+			}
+		}
+
+		result, answerProblems := evaluateQCAnswer(spec, a, optionText, dims)
+		result.QuestionText = questionText
+		for _, p := range answerProblems {
+			problems = append(problems, fmt.Sprintf("%s: %s", questionText, p))
+		}
+		inspection.Answers = append(inspection.Answers, result)
+	}
+
+	// Every critical check of the paper must be answered
+	problems = append(problems, unansweredCriticalChecks(specs, paperQuestions, answered)...)
+
+	inspection.Result, inspection.CriticalFailures, inspection.MinorFailures = qcInspectionResult(inspection.Answers)
+	return inspection, problems, nil
+}
+
+// unansweredCriticalChecks lists the critical questions of a paper missing from a submission.
+func unansweredCriticalChecks(specs map[int]models.QCQuestionSpec, paperQuestions map[int]string, answered map[int]bool) []string {
+	ids := make([]int, 0, len(paperQuestions))
+	for id := range paperQuestions {
+		ids = append(ids, id)
+	}
+	sort.Ints(ids)
+	var problems []string
+	for _, id := range ids {
+		if spec, ok := specs[id]; ok && spec.Severity == models.QCSeverityCritical && !answered[id] {
+			problems = append(problems, fmt.Sprintf("%s: critical check not answered", paperQuestions[id]))
+		}
+	}
+	return problems
+}
+
+// qcStatusForResult applies the computed result to the status requested by QC: a failed
+// inspection can never complete the activity; a passing one completes it unless QC held it.
+func qcStatusForResult(result, requested string) string {
+	if result == models.QCResultFail {
+		if requested == "" || strings.EqualFold(requested, "completed") {
+			return "rejected"
+		}
+		return requested
+	}
+	if requested == "" {
+		return "completed"
+	}
+	return requested
+}
+
+// saveQCInspection stores an evaluated submission.
+func saveQCInspection(tx *sql.Tx, inspection *models.QCInspectionResult) error {
+	err := tx.QueryRow(`
+		INSERT INTO qc_inspection_result (activity_id, element_id, stage_id, paper_id, qc_id, result,
+			critical_failures, minor_failures, status, created_at)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, NOW())
+		RETURNING id, created_at`,
+		inspection.ActivityID, inspection.ElementID, inspection.StageID, inspection.PaperID, inspection.QCID,
+		inspection.Result, inspection.CriticalFailures, inspection.MinorFailures, inspection.Status).
+		Scan(&inspection.ID, &inspection.CreatedAt)
+	if err != nil {
+		return fmt.Errorf("failed to save QC inspection: %v", err)
+	}
+	for _, a := range inspection.Answers {
+		if _, err := tx.Exec(`
+			INSERT INTO qc_answer_result (inspection_id, question_id, answer_type, severity, numeric_value, bool_value,
+				option_text, expected_min, expected_max, passed, reason, comment, image_path)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13)`,
+			inspection.ID, a.QuestionID, a.AnswerType, a.Severity, a.NumericValue, a.BoolValue, a.OptionText,
+			a.ExpectedMin, a.ExpectedMax, a.Passed, a.Reason, a.Comment, a.ImagePath); err != nil {
+			return fmt.Errorf("failed to save QC answer result: %v", err)
+		}
+	}
+	return nil
+}
+
+// SaveQCQuestionSpec godoc
+// @Summary      Set the answer type and pass criteria of a QC question
+// @Description  Numeric questions pass within a tolerance of an element type dimension or a fixed target, or within min/max bounds. Critical failures fail the inspection.
+// @Tags         questions
+// @Accept       json
+// @Produce      json
+// @Param        question_id  path  int                    true  "Question ID"
+// @Param        body         body  models.QCQuestionSpec  true  "Question spec"
+// @Success      200  {object}  models.QCQuestionSpec
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      401  {object}  models.ErrorResponse
+// @Failure      404  {object}  models.ErrorResponse
+// @Router       /api/questions/spec/{question_id} [put]
+func SaveQCQuestionSpec(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session-id header is required"})
+			return
+		}
+		session, userName, err := GetSessionDetails(db, sessionID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		questionID, err := strconv.Atoi(c.Param("question_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID"})
+			return
+		}
+		var projectID int
+		if err := db.QueryRow(`SELECT project_id FROM questions WHERE id = $1`, questionID).Scan(&projectID); err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Question not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch question", "details": err.Error()})
+			return
+		}
+
+		var spec models.QCQuestionSpec
+		if err := c.ShouldBindJSON(&spec); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		spec.QuestionID = questionID
+		if err := normaliseQCQuestionSpec(&spec); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		if err := saveQCQuestionSpec(db, spec); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save question spec", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, spec)
+
+		activityLog := models.ActivityLog{
+			EventContext: "Questions",
+			EventName:    "Update",
+			Description:  fmt.Sprintf("Set %s %s check on question %d", spec.Severity, spec.AnswerType, questionID),
+			UserName:     userName,
+			HostName:     session.HostName,
+			IPAddress:    session.IPAddress,
+			CreatedAt:    time.Now(),
+			ProjectID:    projectID,
+		}
+		if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+			log.Printf("Failed to save activity log: %v", logErr)
+		}
+	}
+}
+
+// DeleteQCQuestionSpec godoc
+// @Summary      Make a QC question plain multiple choice again
+// @Tags         questions
+// @Param        question_id  path  int  true  "Question ID"
+// @Success      200  {object}  models.MessageResponse
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      401  {object}  models.ErrorResponse
+// @Router       /api/questions/spec/{question_id} [delete]
+func DeleteQCQuestionSpec(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session-id header is required"})
+			return
+		}
+		if _, _, err := GetSessionDetails(db, sessionID); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		questionID, err := strconv.Atoi(c.Param("question_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid question ID"})
+			return
+		}
+		if _, err := db.Exec(`DELETE FROM qc_question_spec WHERE question_id = $1`, questionID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete question spec", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Question spec removed"})
+	}
+}
+
+// GetQCInspectionResults godoc
+// @Summary      Get computed QC results of an activity
+// @Tags         questions
+// @Param        activity_id  path  int  true  "Activity ID"
+// @Success      200  {array}   models.QCInspectionResult
+// @Failure      400  {object}  models.ErrorResponse
+// @Failure      401  {object}  models.ErrorResponse
+// @Router       /api/questions/results/{activity_id} [get]
+func GetQCInspectionResults(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session-id header is required"})
+			return
+		}
+		if _, _, err := GetSessionDetails(db, sessionID); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		activityID, err := strconv.Atoi(c.Param("activity_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid activity ID"})
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT id, activity_id, element_id, stage_id, paper_id, qc_id, result, critical_failures, minor_failures, status, created_at
+			FROM qc_inspection_result WHERE activity_id = $1 ORDER BY created_at DESC`, activityID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch QC results", "details": err.Error()})
+			return
+		}
+		results := []models.QCInspectionResult{}
+		for rows.Next() {
+			var r models.QCInspectionResult
+			if err := rows.Scan(&r.ID, &r.ActivityID, &r.ElementID, &r.StageID, &r.PaperID, &r.QCID, &r.Result,
+				&r.CriticalFailures, &r.MinorFailures, &r.Status, &r.CreatedAt); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan QC result", "details": err.Error()})
+				return
+			}
+			results = append(results, r)
+		}
+		rows.Close()
+
+		for i := range results {
+			answerRows, err := db.Query(`
+				SELECT ar.question_id, COALESCE(q.question_text, ''), ar.answer_type, ar.severity, ar.numeric_value, ar.bool_value,
+					ar.option_text, ar.expected_min, ar.expected_max, ar.passed, ar.reason, ar.comment, ar.image_path
+				FROM qc_answer_result ar LEFT JOIN questions q ON q.id = ar.question_id
+				WHERE ar.inspection_id = $1 ORDER BY ar.id`, results[i].ID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch QC answers", "details": err.Error()})
+				return
+			}
+			results[i].Answers = []models.QCAnswerResult{}
+			for answerRows.Next() {
+				var a models.QCAnswerResult
+				var numeric, expMin, expMax sql.NullFloat64
+				var boolValue sql.NullBool
+				if err := answerRows.Scan(&a.QuestionID, &a.QuestionText, &a.AnswerType, &a.Severity, &numeric, &boolValue,
+					&a.OptionText, &expMin, &expMax, &a.Passed, &a.Reason, &a.Comment, &a.ImagePath); err != nil {
+					answerRows.Close()
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan QC answer", "details": err.Error()})
+					return
+				}
+				a.NumericValue, a.ExpectedMin, a.ExpectedMax = nullFloatPtr(numeric), nullFloatPtr(expMin), nullFloatPtr(expMax)
+				if boolValue.Valid {
+					a.BoolValue = &boolValue.Bool
+				}
+				results[i].Answers = append(results[i].Answers, a)
+			}
+			answerRows.Close()
+		}
+
+		c.JSON(http.StatusOK, results)
+	}
+}
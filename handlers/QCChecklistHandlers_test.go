@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"backend/models"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func qcFloat(v float64) *float64 { return &v }
+
+func qcBool(v bool) *bool { return &v }
+
+func TestEvaluateQCAnswerNumeric(t *testing.T) {
+	dims := map[string]float64{"length": 3000, "width": 1200}
+	lengthSpec := models.QCQuestionSpec{AnswerType: models.QCAnswerNumeric, Severity: models.QCSeverityCritical, Unit: "mm",
+		ReferenceField: "length", ToleranceMinus: qcFloat(5), TolerancePlus: qcFloat(3)}
+	// Computed at run time so the bound carries the same rounding as the evaluation
+	noisyTarget, noisyTolerance := 0.3, 0.1
+
+	tests := []struct {
+		name         string
+		spec         models.QCQuestionSpec
+		value        *float64
+		wantPassed   bool
+		wantMin      *float64
+		wantMax      *float64
+		wantReason   string
+		wantProblems []string
+	}{
+		{"on the reference dimension", lengthSpec, qcFloat(3000), true, qcFloat(2995), qcFloat(3003), "", nil},
+		{"at the minus tolerance", lengthSpec, qcFloat(2995), true, qcFloat(2995), qcFloat(3003), "", nil},
+		{"at the plus tolerance", lengthSpec, qcFloat(3003), true, qcFloat(2995), qcFloat(3003), "", nil},
+		{"below the minus tolerance", lengthSpec, qcFloat(2994.5), false, qcFloat(2995), qcFloat(3003),
+			"2994.5mm is below the minimum 2995mm", nil},
+		{"above the plus tolerance", lengthSpec, qcFloat(3004), false, qcFloat(2995), qcFloat(3003),
+			"3004mm is above the maximum 3003mm", nil},
+		{"floating point noise at the bound", models.QCQuestionSpec{AnswerType: models.QCAnswerNumeric,
+			TargetValue: qcFloat(noisyTarget), ToleranceMinus: qcFloat(noisyTolerance)}, qcFloat(0.2), true,
+			qcFloat(noisyTarget - noisyTolerance), qcFloat(noisyTarget), "", nil},
+		{"fixed target with one-sided tolerance", models.QCQuestionSpec{AnswerType: models.QCAnswerNumeric, Unit: "mm",
+			TargetValue: qcFloat(50), TolerancePlus: qcFloat(10)}, qcFloat(49), false, qcFloat(50), qcFloat(60),
+			"49mm is below the minimum 50mm", nil},
+		{"open upper bound", models.QCQuestionSpec{AnswerType: models.QCAnswerNumeric, Unit: "MPa",
+			MinValue: qcFloat(25)}, qcFloat(1000), true, qcFloat(25), nil, "", nil},
+		{"missing measurement", lengthSpec, nil, true, nil, nil, "", []string{"a measurement is required"}},
+		{"element type without the dimension", models.QCQuestionSpec{AnswerType: models.QCAnswerNumeric,
+			ReferenceField: "thickness", TolerancePlus: qcFloat(2)}, qcFloat(150), true, nil, nil, "",
+			[]string{"element type has no thickness to measure against"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, problems := evaluateQCAnswer(tt.spec, models.QCAnswerInput{QuestionID: 1, NumericValue: tt.value}, "", dims)
+			if result.Passed != tt.wantPassed || result.Reason != tt.wantReason {
+				t.Errorf("passed/reason = %v %q, want %v %q", result.Passed, result.Reason, tt.wantPassed, tt.wantReason)
+			}
+			if !reflect.DeepEqual(result.ExpectedMin, tt.wantMin) || !reflect.DeepEqual(result.ExpectedMax, tt.wantMax) {
+				t.Errorf("expected range = %v..%v, want %v..%v", deref(result.ExpectedMin), deref(result.ExpectedMax), deref(tt.wantMin), deref(tt.wantMax))
+			}
+			if !reflect.DeepEqual(problems, tt.wantProblems) {
+				t.Errorf("problems = %q, want %q", problems, tt.wantProblems)
+			}
+		})
+	}
+}
+
+func deref(v *float64) any {
+	if v == nil {
+		return "open"
+	}
+	return *v
+}
+
+func TestEvaluateQCAnswerOtherTypes(t *testing.T) {
+	tests := []struct {
+		name         string
+		spec         models.QCQuestionSpec
+		answer       models.QCAnswerInput
+		optionText   string
+		wantPassed   bool
+		wantProblems []string
+	}{
+		{"yes expected", models.QCQuestionSpec{AnswerType: models.QCAnswerYesNo},
+			models.QCAnswerInput{BoolValue: qcBool(true)}, "", true, nil},
+		{"no when yes expected", models.QCQuestionSpec{AnswerType: models.QCAnswerYesNo, ExpectedYes: qcBool(true)},
+			models.QCAnswerInput{BoolValue: qcBool(false), Comment: "chipped"}, "", false, nil},
+		{"no expected", models.QCQuestionSpec{AnswerType: models.QCAnswerYesNo, ExpectedYes: qcBool(false)},
+			models.QCAnswerInput{BoolValue: qcBool(false)}, "", true, nil},
+		{"yes/no read from the option text", models.QCQuestionSpec{AnswerType: models.QCAnswerYesNo},
+			models.QCAnswerInput{OptionID: 4}, " Not OK ", false, nil},
+		{"yes/no missing", models.QCQuestionSpec{AnswerType: models.QCAnswerYesNo},
+			models.QCAnswerInput{OptionID: 4}, "maybe", true, []string{"a yes/no answer is required"}},
+		{"failing option", models.QCQuestionSpec{AnswerType: models.QCAnswerChoice, FailOptions: []string{"Damaged"}},
+			models.QCAnswerInput{OptionID: 2}, "damaged ", false, nil},
+		{"passing option", models.QCQuestionSpec{AnswerType: models.QCAnswerChoice, FailOptions: []string{"Damaged"}},
+			models.QCAnswerInput{OptionID: 1}, "Good", true, nil},
+		{"no option selected", models.QCQuestionSpec{AnswerType: models.QCAnswerChoice},
+			models.QCAnswerInput{}, "", true, []string{"an option must be selected"}},
+		{"empty text answer", models.QCQuestionSpec{AnswerType: models.QCAnswerText},
+			models.QCAnswerInput{Comment: "  "}, "", true, []string{"a written answer is required"}},
+		{"photo and failure comment required", models.QCQuestionSpec{AnswerType: models.QCAnswerYesNo, PhotoRequired: true, CommentOnFail: true},
+			models.QCAnswerInput{BoolValue: qcBool(false)}, "", false, []string{"a photo is required", "a comment is required when the check fails"}},
+		{"failure comment not needed on a pass", models.QCQuestionSpec{AnswerType: models.QCAnswerYesNo, CommentOnFail: true},
+			models.QCAnswerInput{BoolValue: qcBool(true)}, "", true, nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, problems := evaluateQCAnswer(tt.spec, tt.answer, tt.optionText, nil)
+			if result.Passed != tt.wantPassed {
+				t.Errorf("passed = %v (%s), want %v", result.Passed, result.Reason, tt.wantPassed)
+			}
+			if !reflect.DeepEqual(problems, tt.wantProblems) {
+				t.Errorf("problems = %q, want %q", problems, tt.wantProblems)
+			}
+		})
+	}
+}
+
+func TestQCInspectionResult(t *testing.T) {
+	critical := func(passed bool) models.QCAnswerResult {
+		return models.QCAnswerResult{Severity: models.QCSeverityCritical, Passed: passed}
+	}
+	minor := func(passed bool) models.QCAnswerResult {
+		return models.QCAnswerResult{Severity: models.QCSeverityMinor, Passed: passed}
+	}
+	tests := []struct {
+		name         string
+		answers      []models.QCAnswerResult
+		wantResult   string
+		wantCritical int
+		wantMinor    int
+		requested    string
+		wantStatus   string
+	}{
+		{"all pass", []models.QCAnswerResult{critical(true), minor(true)}, models.QCResultPass, 0, 0, "", "completed"},
+		{"minor failure", []models.QCAnswerResult{critical(true), minor(false), minor(false)}, models.QCResultPassWithMinor, 0, 2, "completed", "completed"},
+		{"critical failure", []models.QCAnswerResult{critical(false), minor(false)}, models.QCResultFail, 1, 1, "completed", "rejected"},
+		{"critical failure without a requested status", []models.QCAnswerResult{critical(false)}, models.QCResultFail, 1, 0, "", "rejected"},
+		{"QC holds a failed element", []models.QCAnswerResult{critical(false)}, models.QCResultFail, 1, 0, "hold", "hold"},
+		{"QC holds a passed element", []models.QCAnswerResult{critical(true)}, models.QCResultPass, 0, 0, "hold", "hold"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, crit, min := qcInspectionResult(tt.answers)
+			if result != tt.wantResult || crit != tt.wantCritical || min != tt.wantMinor {
+				t.Errorf("result = %s %d/%d, want %s %d/%d", result, crit, min, tt.wantResult, tt.wantCritical, tt.wantMinor)
+			}
+			if got := qcStatusForResult(result, tt.requested); got != tt.wantStatus {
+				t.Errorf("status for %s requested %q = %q, want %q", result, tt.requested, got, tt.wantStatus)
+			}
+		})
+	}
+}
+
+func TestUnansweredCriticalChecks(t *testing.T) {
+	specs := map[int]models.QCQuestionSpec{
+		1: {QuestionID: 1, Severity: models.QCSeverityCritical},
+		2: {QuestionID: 2, Severity: models.QCSeverityMinor},
+		3: {QuestionID: 3, Severity: models.QCSeverityCritical},
+		9: {QuestionID: 9, Severity: models.QCSeverityCritical}, // answered from another paper
+	}
+	paper := map[int]string{1: "Overall length", 2: "Surface finish", 3: "Cover to rebar", 4: "Untyped remark"}
+
+	got := unansweredCriticalChecks(specs, paper, map[int]bool{2: true, 9: true})
+	want := []string{"Overall length: critical check not answered", "Cover to rebar: critical check not answered"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("unanswered = %q, want %q", got, want)
+	}
+	if got := unansweredCriticalChecks(specs, paper, map[int]bool{1: true, 3: true}); len(got) != 0 {
+		t.Errorf("all critical checks answered, got %s", strings.Join(got, "; "))
+	}
+}
@@ -45,8 +45,9 @@ func CreateQuestions(db *sql.DB) gin.HandlerFunc {
 			PaperName string `json:"paper_name"` // Paper name for the set
 			ProjectID int    `json:"project_id"` // Project ID for the paper
 			Questions []struct {
-				QuestionText string   `json:"question_text"`
-				Options      []string `json:"options"`
+				QuestionText string                 `json:"question_text"`
+				Options      []string               `json:"options"`
+				Spec         *models.QCQuestionSpec `json:"spec"` // optional answer type and pass criteria
 			} `json:"questions"`
 		}
 
@@ -54,6 +55,14 @@ func CreateQuestions(db *sql.DB) gin.HandlerFunc {
 			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
 			return
 		}
+		for _, question := range req.Questions {
+			if question.Spec != nil {
+				if err := normaliseQCQuestionSpec(question.Spec); err != nil {
+					c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s: %v", question.QuestionText, err)})
+					return
+				}
+			}
+		}
 
 		// Begin a transaction
 		tx, err := db.Begin()
@@ -96,6 +105,16 @@ func CreateQuestions(db *sql.DB) gin.HandlerFunc {
 					return
 				}
 			}
+
+			// Typed questions carry their pass criteria
+			if question.Spec != nil {
+				question.Spec.QuestionID = questionID
+				if err = saveQCQuestionSpec(tx, *question.Spec); err != nil {
+					tx.Rollback()
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save question spec", "details": err.Error()})
+					return
+				}
+			}
 		}
 
 		// Commit the transaction
@@ -241,6 +260,21 @@ func GetQuestions(db *sql.DB) gin.HandlerFunc {
 			questions = append(questions, question)
 		}
 
+		// Attach answer types and pass criteria of typed questions
+		questionIDs := make([]int, 0, len(questions))
+		for _, q := range questions {
+			questionIDs = append(questionIDs, q.ID)
+		}
+		if specs, err := loadQCQuestionSpecs(db, questionIDs); err == nil {
+			for i := range questions {
+				if spec, ok := specs[questions[i].ID]; ok {
+					questions[i].Spec = &spec
+				}
+			}
+		} else {
+			log.Printf("Failed to load question specs: %v", err)
+		}
+
 		// Return the paper along with its questions and options
 		c.JSON(http.StatusOK, gin.H{
 			"paper":     paper,
@@ -319,16 +353,8 @@ func SubmitAnswers(db *sql.DB) gin.HandlerFunc {
 		log.Printf("3")
 
 		var requestBody struct {
-			Answers []struct {
-				ProjectID  int    `json:"project_id"`
-				QuestionID int    `json:"question_id"`
-				OptionID   int    `json:"option_id"`
-				TaskID     int    `json:"task_id"`
-				StageID    int    `json:"stage_id"`
-				Comment    string `json:"comment"`
-				ImagePath  string `json:"image_path"`
-			} `json:"answers"`
-			Status struct {
+			Answers []models.QCAnswerInput `json:"answers"`
+			Status  struct {
 				ActivityID int    `json:"activity_id"`
 				Status     string `json:"status"`
 			} `json:"status"`
@@ -353,11 +379,46 @@ func SubmitAnswers(db *sql.DB) gin.HandlerFunc {
 			return
 		}
 
+		// Typed checklists compute pass/fail from the answers; a failed inspection cannot complete the activity
+		inspection, problems, err := evaluateQCSubmission(tx, userID, activity.ID, activity.TaskID, activity.ElementID, activity.StageID, requestBody.Answers)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"details": "error in evaluating QC checklist", "error": err.Error()})
+			return
+		}
+		if len(problems) > 0 {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "QC checklist is incomplete", "details": problems})
+			return
+		}
+		if inspection != nil {
+			requestBody.Status.Status = qcStatusForResult(inspection.Result, requestBody.Status.Status)
+			inspection.Status = requestBody.Status.Status
+		}
+
 		// Insert answers with element_id from activity
 		if err = insertAnswers(tx, userID, requestBody.Answers, activity.ElementID); err != nil {
 			c.JSON(http.StatusInternalServerError, gin.H{"details": "error in get InsertAnswers", "error": err.Error()})
 			return
 		}
+		var ncr *models.NCR
+		if inspection != nil {
+			if err = saveQCInspection(tx, inspection); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"details": "error in saving QC result", "error": err.Error()})
+				return
+			}
+		}
+		// Failed inspections and every rejected submission raise a non-conformance report against the element
+		failed := inspection != nil && inspection.Result == models.QCResultFail
+		if failed || strings.EqualFold(requestBody.Status.Status, "rejected") {
+			if inspection != nil {
+				ncr, err = RaiseNCRForQCFailure(tx, inspection, activity.ProjectID, userName)
+			} else {
+				ncr, err = RaiseNCRForQCRejection(tx, activity.ProjectID, activity.ElementID, activity.StageID, activity.ID, requestBody.Answers, userName)
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"details": "error in raising NCR", "error": err.Error()})
+				return
+			}
+		}
 
 		log.Printf("6")
 
@@ -500,7 +561,14 @@ func SubmitAnswers(db *sql.DB) gin.HandlerFunc {
 			}
 		}
 
-		c.JSON(http.StatusOK, gin.H{"message": "Answers submitted successfully and status updated"})
+		response := gin.H{"message": "Answers submitted successfully and status updated"}
+		if inspection != nil {
+			response["qc_result"] = inspection
+		}
+		if ncr != nil {
+			response["ncr"] = ncr
+		}
+		c.JSON(http.StatusOK, response)
 
 		log := models.ActivityLog{
 			EventContext: "Answers",
@@ -535,15 +603,7 @@ func handleDBError(c *gin.Context, resource string, err error) {
 	}
 }
 
-func insertAnswers(tx *sql.Tx, userID int, answers []struct {
-	ProjectID  int    `json:"project_id"`
-	QuestionID int    `json:"question_id"`
-	OptionID   int    `json:"option_id"`
-	TaskID     int    `json:"task_id"`
-	StageID    int    `json:"stage_id"`
-	Comment    string `json:"comment"`
-	ImagePath  string `json:"image_path"`
-}, elementID int) error {
+func insertAnswers(tx *sql.Tx, userID int, answers []models.QCAnswerInput, elementID int) error {
 	stmt, err := tx.Prepare(`
         INSERT INTO qc_answers (
             qc_id, project_id, question_id, option_id, task_id, stage_id, 
@@ -560,8 +620,10 @@ func insertAnswers(tx *sql.Tx, userID int, answers []struct {
 		log.Printf("Inserting answer: %+v", answer)
 		log.Printf("15")
 
+		// Numeric and yes/no answers may have no option
+		optionID := sql.NullInt64{Int64: int64(answer.OptionID), Valid: answer.OptionID != 0}
 		if _, err = stmt.Exec(
-			userID, answer.ProjectID, answer.QuestionID, answer.OptionID,
+			userID, answer.ProjectID, answer.QuestionID, optionID,
 			answer.TaskID, answer.StageID, answer.Comment, answer.ImagePath, time.Now(), elementID,
 		); err != nil {
 			log.Printf("Failed to insert answer: %+v, error: %v", answer, err)
@@ -899,7 +961,7 @@ func handleRegularStageTransition(tx *sql.Tx, activity struct {
 	db := storage.GetDB()
 
 	if nextStageID == 0 {
-		if _, err = CreatePrecastStock(db, activity.ElementID, activity.ProjectID, activity.StockyardID); err != nil {
+		if _, err = CreatePrecastStock(db, tx, activity.ElementID, activity.ProjectID, activity.StockyardID); err != nil {
 			return fmt.Errorf("failed to move to stockyard: %v", err)
 		}
 
@@ -0,0 +1,846 @@
+package handlers
+
+import (
+	"backend/models"
+	"bytes"
+	"database/sql"
+	"encoding/csv"
+	"fmt"
+	"math"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+	"github.com/xuri/excelize/v2"
+)
+
+// rebarShape is a bending shape with its cut length formula. Dimensions are out-to-out in mm and
+// bend deductions follow IS 2502 practice: 2d per 90° bend, 3d per 135° hook, 9d per 180° hook.
+type rebarShape struct {
+	models.RebarShapeCode
+	cutLength func(bar models.ElementTypeRebar, d float64) float64
+}
+
+var rebarShapes = map[string]rebarShape{
+	"00": {models.RebarShapeCode{Code: "00", Name: "Straight bar", Dimensions: []string{"A"}, Formula: "A"},
+		func(bar models.ElementTypeRebar, d float64) float64 { return bar.A }},
+	"11": {models.RebarShapeCode{Code: "11", Name: "L-bar", Dimensions: []string{"A", "B"}, Formula: "A + B - 2d"},
+		func(bar models.ElementTypeRebar, d float64) float64 { return bar.A + bar.B - 2*d }},
+	"21": {models.RebarShapeCode{Code: "21", Name: "U-bar", Dimensions: []string{"A", "B", "C"}, Formula: "A + B + C - 4d"},
+		func(bar models.ElementTypeRebar, d float64) float64 { return bar.A + bar.B + bar.C - 4*d }},
+	"31": {models.RebarShapeCode{Code: "31", Name: "Straight bar with 180° hooks", Dimensions: []string{"A"}, Formula: "A + 18d"},
+		func(bar models.ElementTypeRebar, d float64) float64 { return bar.A + 18*d }},
+	"41": {models.RebarShapeCode{Code: "41", Name: "Z-bar / crank", Dimensions: []string{"A", "B", "C"}, Formula: "A + B + C - 4d"},
+		func(bar models.ElementTypeRebar, d float64) float64 { return bar.A + bar.B + bar.C - 4*d }},
+	"51": {models.RebarShapeCode{Code: "51", Name: "Closed stirrup with 135° hooks", Dimensions: []string{"A", "B"}, Formula: "2(A + B) + 24d - 3(2d) - 2(3d)"},
+		func(bar models.ElementTypeRebar, d float64) float64 { return 2*(bar.A+bar.B) + 12*d }},
+	"77": {models.RebarShapeCode{Code: "77", Name: "Helix", Dimensions: []string{"A", "B", "C"}, Formula: "C x sqrt((pi(A - d))^2 + B^2), A = outer diameter, B = pitch, C = turns"},
+		func(bar models.ElementTypeRebar, d float64) float64 {
+			return bar.C * math.Sqrt(math.Pow(math.Pi*(bar.A-d), 2)+bar.B*bar.B)
+		}},
+	"99": {models.RebarShapeCode{Code: "99", Name: "Special shape (cut length given as A)", Dimensions: []string{"A"}, Formula: "A"},
+		func(bar models.ElementTypeRebar, d float64) float64 { return bar.A }},
+}
+
+// rebarUnitWeight returns the weight of a bar in kg per metre (d²/162)
+func rebarUnitWeight(diameterMM int) float64 {
+	return float64(diameterMM*diameterMM) / 162.0
+}
+
+// validateRebar checks a bar against its shape code and returns its cut length in mm.
+func validateRebar(bar models.ElementTypeRebar) (float64, error) {
+	shape, ok := rebarShapes[bar.ShapeCode]
+	if !ok {
+		return 0, fmt.Errorf("bar %s: unknown shape code %q", bar.BarMark, bar.ShapeCode)
+	}
+	if strings.TrimSpace(bar.BarMark) == "" {
+		return 0, fmt.Errorf("bar_mark is required")
+	}
+	if bar.DiameterMM <= 0 || bar.DiameterMM > 50 {
+		return 0, fmt.Errorf("bar %s: diameter_mm must be between 1 and 50", bar.BarMark)
+	}
+	if bar.BarCount <= 0 {
+		return 0, fmt.Errorf("bar %s: bar_count must be greater than 0", bar.BarMark)
+	}
+	dims := map[string]float64{"A": bar.A, "B": bar.B, "C": bar.C, "D": bar.D, "E": bar.E}
+	for _, dim := range shape.Dimensions {
+		if dims[dim] <= 0 {
+			return 0, fmt.Errorf("bar %s: dimension %s is required for shape %s", bar.BarMark, dim, bar.ShapeCode)
+		}
+	}
+	cutLength := math.Round(shape.cutLength(bar, float64(bar.DiameterMM)))
+	if cutLength <= 0 {
+		return 0, fmt.Errorf("bar %s: dimensions give a non-positive cut length", bar.BarMark)
+	}
+	return cutLength, nil
+}
+
+// fetchElementTypeRebars returns the rebar detail of the given element types keyed by element type ID.
+func fetchElementTypeRebars(db *sql.DB, elementTypeIDs []int) (map[int][]models.ElementTypeRebar, error) {
+	result := make(map[int][]models.ElementTypeRebar)
+	if len(elementTypeIDs) == 0 {
+		return result, nil
+	}
+
+	placeholders := make([]string, len(elementTypeIDs))
+	args := make([]interface{}, len(elementTypeIDs))
+	for i, id := range elementTypeIDs {
+		placeholders[i] = fmt.Sprintf("$%d", i+1)
+		args[i] = id
+	}
+
+	rows, err := db.Query(fmt.Sprintf(`
+		SELECT id, element_type_id, project_id, bar_mark, diameter_mm, shape_code, dim_a, dim_b, dim_c, dim_d, dim_e,
+			bar_count, remarks, created_by, created_at, updated_at
+		FROM element_type_rebar
+		WHERE element_type_id IN (%s)
+		ORDER BY element_type_id, bar_mark`, strings.Join(placeholders, ",")), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var bar models.ElementTypeRebar
+		if err := rows.Scan(&bar.ID, &bar.ElementTypeID, &bar.ProjectID, &bar.BarMark, &bar.DiameterMM, &bar.ShapeCode,
+			&bar.A, &bar.B, &bar.C, &bar.D, &bar.E, &bar.BarCount, &bar.Remarks, &bar.CreatedBy, &bar.CreatedAt,
+			&bar.UpdatedAt); err != nil {
+			return nil, err
+		}
+		result[bar.ElementTypeID] = append(result[bar.ElementTypeID], bar)
+	}
+	return result, rows.Err()
+}
+
+// castElementTypeCounts returns how many elements of each element type completed the casting stage in a date range.
+func castElementTypeCounts(db *sql.DB, projectID, stageID int, startDate, endDate time.Time) (map[int]int, error) {
+	rows, err := db.Query(`
+		SELECT cp.element_type_id, COUNT(DISTINCT cp.element_id)
+		FROM complete_production cp
+		WHERE cp.project_id = $1
+			AND cp.stage_id = $2
+			AND cp.started_at >= $3
+			AND cp.started_at <= $4
+		GROUP BY cp.element_type_id`, projectID, stageID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	counts := make(map[int]int)
+	for rows.Next() {
+		var elementTypeID, count int
+		if err := rows.Scan(&elementTypeID, &count); err != nil {
+			return nil, err
+		}
+		counts[elementTypeID] = count
+	}
+	return counts, rows.Err()
+}
+
+// buildBBS builds the schedule lines and totals for a set of element types and element counts.
+// elementTypeCodes maps element type IDs to the code printed on the schedule.
+func buildBBS(bbs *models.BarBendingSchedule, rebars map[int][]models.ElementTypeRebar, elementCounts map[int]int, elementTypeCodes map[int]string) {
+	bbs.Lines = []models.BBSLine{}
+	diameters := make(map[int]*models.BBSDiameterTotal)
+	shapes := make(map[string]*models.BBSShapeTotal)
+
+	elementTypeIDs := make([]int, 0, len(elementCounts))
+	for id := range elementCounts {
+		elementTypeIDs = append(elementTypeIDs, id)
+	}
+	sort.Slice(elementTypeIDs, func(i, j int) bool {
+		return elementTypeCodes[elementTypeIDs[i]] < elementTypeCodes[elementTypeIDs[j]]
+	})
+
+	for _, elementTypeID := range elementTypeIDs {
+		elements := elementCounts[elementTypeID]
+		bbs.Elements += elements
+		for _, bar := range rebars[elementTypeID] {
+			shape := rebarShapes[bar.ShapeCode]
+			cutLength := math.Round(shape.cutLength(bar, float64(bar.DiameterMM)))
+			totalBars := bar.BarCount * elements
+			totalLength := cutLength * float64(totalBars) / 1000
+			unitWeight := rebarUnitWeight(bar.DiameterMM)
+			weight := totalLength * unitWeight
+
+			bbs.Lines = append(bbs.Lines, models.BBSLine{
+				ElementTypeID:    elementTypeID,
+				ElementType:      elementTypeCodes[elementTypeID],
+				BarMark:          bar.BarMark,
+				DiameterMM:       bar.DiameterMM,
+				ShapeCode:        bar.ShapeCode,
+				ShapeName:        shape.Name,
+				A:                bar.A,
+				B:                bar.B,
+				C:                bar.C,
+				D:                bar.D,
+				E:                bar.E,
+				BarsPerElement:   bar.BarCount,
+				Elements:         elements,
+				TotalBars:        totalBars,
+				CutLengthMM:      cutLength,
+				TotalLengthM:     math.Round(totalLength*100) / 100,
+				UnitWeightKgPerM: math.Round(unitWeight*1000) / 1000,
+				WeightKg:         math.Round(weight*100) / 100,
+				Remarks:          bar.Remarks,
+			})
+
+			dia, ok := diameters[bar.DiameterMM]
+			if !ok {
+				dia = &models.BBSDiameterTotal{DiameterMM: bar.DiameterMM}
+				diameters[bar.DiameterMM] = dia
+			}
+			dia.TotalBars += totalBars
+			dia.TotalLengthM += totalLength
+			dia.WeightKg += weight
+
+			sh, ok := shapes[bar.ShapeCode]
+			if !ok {
+				sh = &models.BBSShapeTotal{ShapeCode: bar.ShapeCode, ShapeName: shape.Name, CutLengthsMM: []float64{}}
+				shapes[bar.ShapeCode] = sh
+			}
+			if !containsFloat(sh.CutLengthsMM, cutLength) {
+				sh.CutLengthsMM = append(sh.CutLengthsMM, cutLength)
+			}
+			sh.TotalBars += totalBars
+			sh.TotalLengthM += totalLength
+			sh.WeightKg += weight
+
+			bbs.TotalWeightKg += weight
+		}
+	}
+
+	bbs.ByDiameter = []models.BBSDiameterTotal{}
+	for _, dia := range diameters {
+		dia.TotalLengthM = math.Round(dia.TotalLengthM*100) / 100
+		dia.WeightKg = math.Round(dia.WeightKg*100) / 100
+		bbs.ByDiameter = append(bbs.ByDiameter, *dia)
+	}
+	sort.Slice(bbs.ByDiameter, func(i, j int) bool { return bbs.ByDiameter[i].DiameterMM < bbs.ByDiameter[j].DiameterMM })
+
+	bbs.ByShape = []models.BBSShapeTotal{}
+	for _, sh := range shapes {
+		sort.Float64s(sh.CutLengthsMM)
+		sh.TotalLengthM = math.Round(sh.TotalLengthM*100) / 100
+		sh.WeightKg = math.Round(sh.WeightKg*100) / 100
+		bbs.ByShape = append(bbs.ByShape, *sh)
+	}
+	sort.Slice(bbs.ByShape, func(i, j int) bool { return bbs.ByShape[i].ShapeCode < bbs.ByShape[j].ShapeCode })
+
+	bbs.TotalWeightKg = math.Round(bbs.TotalWeightKg*100) / 100
+}
+
+func containsFloat(values []float64, v float64) bool {
+	for _, existing := range values {
+		if existing == v {
+			return true
+		}
+	}
+	return false
+}
+
+// calculateSteelUsageByBBS returns the rebar weight (kg) by diameter of the elements cast in a date range,
+// for element types that carry rebar detail.
+func calculateSteelUsageByBBS(db *sql.DB, projectID, stageID int, startDate, endDate time.Time) (map[string]float64, error) {
+	counts, err := castElementTypeCounts(db, projectID, stageID, startDate, endDate)
+	if err != nil {
+		return nil, err
+	}
+	elementTypeIDs := make([]int, 0, len(counts))
+	for id := range counts {
+		elementTypeIDs = append(elementTypeIDs, id)
+	}
+	rebars, err := fetchElementTypeRebars(db, elementTypeIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	var bbs models.BarBendingSchedule
+	buildBBS(&bbs, rebars, counts, map[int]string{})
+
+	usage := make(map[string]float64)
+	for _, dia := range bbs.ByDiameter {
+		usage[fmt.Sprintf("Rebar %dmm (BBS)", dia.DiameterMM)] = dia.WeightKg
+	}
+	return usage, nil
+}
+
+// writeBBS sends a bar bending schedule as JSON, CSV, Excel or PDF depending on format
+func writeBBS(c *gin.Context, bbs models.BarBendingSchedule, format, filenameBase string) {
+	switch strings.ToLower(format) {
+	case "", "json":
+		c.JSON(http.StatusOK, bbs)
+	case "csv":
+		writeBBSCSV(c, bbs, filenameBase)
+	case "xlsx", "excel":
+		writeBBSExcel(c, bbs, filenameBase)
+	case "pdf":
+		writeBBSPDF(c, bbs, filenameBase)
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{"error": "format must be one of json, csv, xlsx, pdf"})
+	}
+}
+
+func bbsLineRow(line models.BBSLine) []string {
+	return []string{
+		line.ElementType, line.BarMark, strconv.Itoa(line.DiameterMM), line.ShapeCode,
+		fmt.Sprintf("%.0f", line.A), fmt.Sprintf("%.0f", line.B), fmt.Sprintf("%.0f", line.C),
+		fmt.Sprintf("%.0f", line.D), fmt.Sprintf("%.0f", line.E),
+		strconv.Itoa(line.BarsPerElement), strconv.Itoa(line.Elements), strconv.Itoa(line.TotalBars),
+		fmt.Sprintf("%.0f", line.CutLengthMM), fmt.Sprintf("%.2f", line.TotalLengthM), fmt.Sprintf("%.2f", line.WeightKg),
+	}
+}
+
+var bbsLineHeader = []string{"Element Type", "Bar Mark", "Dia (mm)", "Shape", "A", "B", "C", "D", "E",
+	"No./Element", "Elements", "Total Bars", "Cut Length (mm)", "Total Length (m)", "Weight (kg)"}
+
+func writeBBSCSV(c *gin.Context, bbs models.BarBendingSchedule, filenameBase string) {
+	var buf bytes.Buffer
+	writer := csv.NewWriter(&buf)
+
+	writer.Write([]string{bbs.Title})
+	writer.Write([]string{"Project", bbs.ProjectName})
+	writer.Write([]string{"Elements", strconv.Itoa(bbs.Elements)})
+	writer.Write([]string{})
+	writer.Write(bbsLineHeader)
+	for _, line := range bbs.Lines {
+		writer.Write(bbsLineRow(line))
+	}
+
+	writer.Write([]string{})
+	writer.Write([]string{"Dia (mm)", "Total Bars", "Total Length (m)", "Weight (kg)"})
+	for _, dia := range bbs.ByDiameter {
+		writer.Write([]string{strconv.Itoa(dia.DiameterMM), strconv.Itoa(dia.TotalBars),
+			fmt.Sprintf("%.2f", dia.TotalLengthM), fmt.Sprintf("%.2f", dia.WeightKg)})
+	}
+	writer.Write([]string{"Total", "", "", fmt.Sprintf("%.2f", bbs.TotalWeightKg)})
+	writer.Flush()
+
+	if err := writer.Error(); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error writing CSV", "details": err.Error()})
+		return
+	}
+
+	c.Header("Content-Disposition", fmt.Sprintf("attachment;filename=%s.csv", filenameBase))
+	c.Data(http.StatusOK, "text/csv", buf.Bytes())
+}
+
+func writeBBSExcel(c *gin.Context, bbs models.BarBendingSchedule, filenameBase string) {
+	f := excelize.NewFile()
+	defer f.Close()
+
+	sheet := "BBS"
+	index, err := f.NewSheet(sheet)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating BBS sheet"})
+		return
+	}
+	f.SetActiveSheet(index)
+	f.DeleteSheet("Sheet1")
+
+	f.SetCellValue(sheet, "A1", bbs.Title)
+	f.SetCellValue(sheet, "A2", "Project")
+	f.SetCellValue(sheet, "B2", bbs.ProjectName)
+	f.SetCellValue(sheet, "A3", "Elements")
+	f.SetCellValue(sheet, "B3", bbs.Elements)
+	for col, title := range bbsLineHeader {
+		cell, _ := excelize.CoordinatesToCellName(col+1, 5)
+		f.SetCellValue(sheet, cell, title)
+	}
+	for i, line := range bbs.Lines {
+		values := []interface{}{line.ElementType, line.BarMark, line.DiameterMM, line.ShapeCode, line.A, line.B, line.C,
+			line.D, line.E, line.BarsPerElement, line.Elements, line.TotalBars, line.CutLengthMM, line.TotalLengthM, line.WeightKg}
+		for col, value := range values {
+			cell, _ := excelize.CoordinatesToCellName(col+1, i+6)
+			f.SetCellValue(sheet, cell, value)
+		}
+	}
+
+	summary := "By Diameter"
+	if _, err := f.NewSheet(summary); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating summary sheet"})
+		return
+	}
+	f.SetSheetRow(summary, "A1", &[]interface{}{"Dia (mm)", "Total Bars", "Total Length (m)", "Weight (kg)"})
+	for i, dia := range bbs.ByDiameter {
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		f.SetSheetRow(summary, cell, &[]interface{}{dia.DiameterMM, dia.TotalBars, dia.TotalLengthM, dia.WeightKg})
+	}
+	cell, _ := excelize.CoordinatesToCellName(1, len(bbs.ByDiameter)+2)
+	f.SetSheetRow(summary, cell, &[]interface{}{"Total", "", "", bbs.TotalWeightKg})
+
+	shapes := "By Shape"
+	if _, err := f.NewSheet(shapes); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error creating shape sheet"})
+		return
+	}
+	f.SetSheetRow(shapes, "A1", &[]interface{}{"Shape Code", "Shape", "Cut Lengths (mm)", "Total Bars", "Total Length (m)", "Weight (kg)"})
+	for i, sh := range bbs.ByShape {
+		lengths := make([]string, len(sh.CutLengthsMM))
+		for j, l := range sh.CutLengthsMM {
+			lengths[j] = fmt.Sprintf("%.0f", l)
+		}
+		cell, _ := excelize.CoordinatesToCellName(1, i+2)
+		f.SetSheetRow(shapes, cell, &[]interface{}{sh.ShapeCode, sh.ShapeName, strings.Join(lengths, ", "), sh.TotalBars, sh.TotalLengthM, sh.WeightKg})
+	}
+
+	filename := filenameBase + ".xlsx"
+	c.Header("Content-Type", "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet")
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s\"; filename*=UTF-8''%s", filename, url.PathEscape(filename)))
+	if err := f.Write(c.Writer); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Error writing Excel file"})
+		return
+	}
+}
+
+func writeBBSPDF(c *gin.Context, bbs models.BarBendingSchedule, filenameBase string) {
+	pdf := gofpdf.New("L", "mm", "A4", "")
+	pdf.SetMargins(10, 10, 10)
+	pdf.AddPage()
+
+	// Header band
+	pdf.SetFont("Arial", "B", 18)
+	pdf.SetFillColor(240, 240, 240)
+	pdf.Rect(10, 10, 277, 12, "F")
+	pdf.SetXY(10, 11)
+	pdf.Cell(277, 10, bbs.Title)
+	pdf.Ln(14)
+
+	pdf.SetFont("Arial", "", 10)
+	pdf.Cell(30, 6, "Project:")
+	pdf.SetFont("Arial", "B", 10)
+	pdf.Cell(100, 6, bbs.ProjectName)
+	pdf.SetFont("Arial", "", 10)
+	pdf.Cell(30, 6, "Elements:")
+	pdf.SetFont("Arial", "B", 10)
+	pdf.Cell(30, 6, strconv.Itoa(bbs.Elements))
+	pdf.SetFont("Arial", "", 10)
+	pdf.Cell(30, 6, "Generated on:")
+	pdf.SetFont("Arial", "B", 10)
+	pdf.Cell(57, 6, bbs.GeneratedAt.Format("2006-01-02 15:04"))
+	pdf.Ln(6)
+	if bbs.From != nil && bbs.To != nil {
+		pdf.SetFont("Arial", "", 10)
+		pdf.Cell(30, 6, "Cast between:")
+		pdf.SetFont("Arial", "B", 10)
+		pdf.Cell(100, 6, fmt.Sprintf("%s and %s", bbs.From.Format("2006-01-02"), bbs.To.Format("2006-01-02")))
+		pdf.Ln(6)
+	}
+	pdf.Ln(4)
+
+	widths := []float64{22, 18, 14, 13, 16, 16, 16, 16, 16, 20, 18, 18, 24, 24, 26}
+	tableHeader := func() {
+		pdf.SetFont("Arial", "B", 8)
+		pdf.SetFillColor(230, 230, 230)
+		for i, title := range bbsLineHeader {
+			pdf.CellFormat(widths[i], 8, title, "1", 0, "C", true, 0, "")
+		}
+		pdf.Ln(8)
+		pdf.SetFont("Arial", "", 8)
+	}
+	tableHeader()
+	for _, line := range bbs.Lines {
+		if pdf.GetY() > 185 {
+			pdf.AddPage()
+			tableHeader()
+		}
+		for i, value := range bbsLineRow(line) {
+			align := "R"
+			if i < 2 {
+				align = "L"
+			}
+			pdf.CellFormat(widths[i], 6, value, "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(6)
+	}
+
+	// Weight by diameter
+	if pdf.GetY() > 150 {
+		pdf.AddPage()
+	}
+	pdf.Ln(6)
+	pdf.SetFont("Arial", "B", 12)
+	pdf.Cell(277, 8, "Weight by Diameter")
+	pdf.Ln(9)
+	pdf.SetFont("Arial", "B", 9)
+	pdf.SetFillColor(230, 230, 230)
+	for _, title := range []string{"Dia (mm)", "Total Bars", "Total Length (m)", "Weight (kg)"} {
+		pdf.CellFormat(35, 7, title, "1", 0, "C", true, 0, "")
+	}
+	pdf.Ln(7)
+	pdf.SetFont("Arial", "", 9)
+	for _, dia := range bbs.ByDiameter {
+		pdf.CellFormat(35, 6, strconv.Itoa(dia.DiameterMM), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(35, 6, strconv.Itoa(dia.TotalBars), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(35, 6, fmt.Sprintf("%.2f", dia.TotalLengthM), "1", 0, "R", false, 0, "")
+		pdf.CellFormat(35, 6, fmt.Sprintf("%.2f", dia.WeightKg), "1", 0, "R", false, 0, "")
+		pdf.Ln(6)
+	}
+	pdf.SetFont("Arial", "B", 9)
+	pdf.CellFormat(105, 6, "Total", "1", 0, "L", false, 0, "")
+	pdf.CellFormat(35, 6, fmt.Sprintf("%.2f", bbs.TotalWeightKg), "1", 0, "R", false, 0, "")
+	pdf.Ln(6)
+
+	var buf bytes.Buffer
+	if err := pdf.Output(&buf); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate PDF", "details": err.Error()})
+		return
+	}
+	c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.pdf", filenameBase))
+	c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+}
+
+// GetRebarShapeCodes lists the supported bending shape codes.
+// @Summary List rebar shape codes
+// @Tags BBS
+// @Produce json
+// @Success 200 {array} models.RebarShapeCode
+// @Router /api/rebar/shape-codes [get]
+func GetRebarShapeCodes(c *gin.Context) {
+	codes := make([]models.RebarShapeCode, 0, len(rebarShapes))
+	for _, shape := range rebarShapes {
+		codes = append(codes, shape.RebarShapeCode)
+	}
+	sort.Slice(codes, func(i, j int) bool { return codes[i].Code < codes[j].Code })
+	c.JSON(http.StatusOK, codes)
+}
+
+// GetElementTypeRebar returns the rebar detail of an element type.
+// @Summary Get rebar detail of an element type
+// @Tags BBS
+// @Produce json
+// @Param element_type_id path int true "Element Type ID"
+// @Success 200 {array} models.ElementTypeRebar
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/element_type_rebar/{element_type_id} [get]
+func GetElementTypeRebar(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+			return
+		}
+		if _, _, err := GetSessionDetails(db, sessionID); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		elementTypeID, err := strconv.Atoi(c.Param("element_type_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "element_type_id must be a valid integer"})
+			return
+		}
+
+		rebars, err := fetchElementTypeRebars(db, []int{elementTypeID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rebar detail", "details": err.Error()})
+			return
+		}
+		bars := rebars[elementTypeID]
+		if bars == nil {
+			bars = []models.ElementTypeRebar{}
+		}
+		c.JSON(http.StatusOK, bars)
+	}
+}
+
+// SaveElementTypeRebar replaces the rebar detail of an element type.
+// @Summary Save rebar detail of an element type
+// @Description Replaces all bar marks of the element type. Each bar needs the dimensions its shape code uses.
+// @Tags BBS
+// @Accept json
+// @Produce json
+// @Param element_type_id path int true "Element Type ID"
+// @Param request body models.ElementTypeRebarRequest true "Bars"
+// @Success 200 {array} models.ElementTypeRebar
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/element_type_rebar/{element_type_id} [put]
+func SaveElementTypeRebar(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+			return
+		}
+		session, userName, err := GetSessionDetails(db, sessionID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		elementTypeID, err := strconv.Atoi(c.Param("element_type_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "element_type_id must be a valid integer"})
+			return
+		}
+
+		var req models.ElementTypeRebarRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+
+		seen := make(map[string]bool)
+		for _, bar := range req.Bars {
+			if _, err := validateRebar(bar); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			if seen[bar.BarMark] {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("bar mark %s is listed twice", bar.BarMark)})
+				return
+			}
+			seen[bar.BarMark] = true
+		}
+
+		var projectID int
+		var elementTypeCode string
+		err = db.QueryRow(`SELECT project_id, element_type FROM element_type WHERE element_type_id = $1`, elementTypeID).
+			Scan(&projectID, &elementTypeCode)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Element type not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction", "details": err.Error()})
+			return
+		}
+		defer tx.Rollback()
+
+		if _, err := tx.Exec(`DELETE FROM element_type_rebar WHERE element_type_id = $1`, elementTypeID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear rebar detail", "details": err.Error()})
+			return
+		}
+		now := time.Now()
+		for _, bar := range req.Bars {
+			_, err := tx.Exec(`
+				INSERT INTO element_type_rebar (element_type_id, project_id, bar_mark, diameter_mm, shape_code,
+					dim_a, dim_b, dim_c, dim_d, dim_e, bar_count, remarks, created_by, created_at, updated_at)
+				VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12, $13, $14, $14)`,
+				elementTypeID, projectID, strings.TrimSpace(bar.BarMark), bar.DiameterMM, bar.ShapeCode,
+				bar.A, bar.B, bar.C, bar.D, bar.E, bar.BarCount, bar.Remarks, userName, now)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save bar " + bar.BarMark, "details": err.Error()})
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit rebar detail", "details": err.Error()})
+			return
+		}
+
+		log := models.ActivityLog{
+			EventContext: "Element Type",
+			EventName:    "Rebar Detail",
+			Description:  fmt.Sprintf("Saved %d bar marks for element type %s", len(req.Bars), elementTypeCode),
+			UserName:     userName,
+			HostName:     session.HostName,
+			IPAddress:    session.IPAddress,
+			CreatedAt:    now,
+			ProjectID:    projectID,
+		}
+		if logErr := SaveActivityLog(db, log); logErr != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to log activity", "details": logErr.Error()})
+			return
+		}
+
+		rebars, err := fetchElementTypeRebars(db, []int{elementTypeID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rebar detail", "details": err.Error()})
+			return
+		}
+		bars := rebars[elementTypeID]
+		if bars == nil {
+			bars = []models.ElementTypeRebar{}
+		}
+		c.JSON(http.StatusOK, bars)
+	}
+}
+
+// GetElementTypeBBS generates the bar bending schedule of an element type.
+// @Summary Bar bending schedule of an element type
+// @Tags BBS
+// @Produce json
+// @Param element_type_id path int true "Element Type ID"
+// @Param elements query int false "Number of elements to schedule (default 1)"
+// @Param format query string false "json, csv, xlsx or pdf"
+// @Success 200 {object} models.BarBendingSchedule
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/element_type_bbs/{element_type_id} [get]
+func GetElementTypeBBS(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+			return
+		}
+		if _, _, err := GetSessionDetails(db, sessionID); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		elementTypeID, err := strconv.Atoi(c.Param("element_type_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "element_type_id must be a valid integer"})
+			return
+		}
+		elements, err := strconv.Atoi(c.DefaultQuery("elements", "1"))
+		if err != nil || elements < 1 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "elements must be a positive integer"})
+			return
+		}
+
+		var bbs models.BarBendingSchedule
+		var elementTypeCode string
+		err = db.QueryRow(`
+			SELECT et.project_id, COALESCE(p.name, ''), et.element_type
+			FROM element_type et
+			LEFT JOIN project p ON p.project_id = et.project_id
+			WHERE et.element_type_id = $1`, elementTypeID).Scan(&bbs.ProjectID, &bbs.ProjectName, &elementTypeCode)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Element type not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
+			return
+		}
+
+		rebars, err := fetchElementTypeRebars(db, []int{elementTypeID})
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rebar detail", "details": err.Error()})
+			return
+		}
+
+		bbs.Title = "Bar Bending Schedule - " + elementTypeCode
+		bbs.GeneratedAt = time.Now()
+		buildBBS(&bbs, rebars, map[int]int{elementTypeID: elements}, map[int]string{elementTypeID: elementTypeCode})
+
+		writeBBS(c, bbs, c.Query("format"), fmt.Sprintf("bbs_%s_%d", strings.ReplaceAll(elementTypeCode, " ", "_"), elementTypeID))
+	}
+}
+
+// GetCastingBatchBBS generates the bar bending schedule of the elements cast in a date range.
+// @Summary Bar bending schedule of a casting batch
+// @Description Schedules the rebar of every element that completed the Mesh & Mould stage between from and to
+// @Tags BBS
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param from query string true "Start date (YYYY-MM-DD)"
+// @Param to query string true "End date (YYYY-MM-DD)"
+// @Param element_type_id query int false "Restrict to one element type"
+// @Param format query string false "json, csv, xlsx or pdf"
+// @Success 200 {object} models.BarBendingSchedule
+// @Failure 400 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/bbs [get]
+func GetCastingBatchBBS(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+			return
+		}
+		if _, _, err := GetSessionDetails(db, sessionID); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project_id"})
+			return
+		}
+		from, err := time.Parse("2006-01-02", c.Query("from"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from must be a date in YYYY-MM-DD format"})
+			return
+		}
+		to, err := time.Parse("2006-01-02", c.Query("to"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must be a date in YYYY-MM-DD format"})
+			return
+		}
+		if to.Before(from) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "to must not be before from"})
+			return
+		}
+		endOfDay := to.Add(24*time.Hour - time.Nanosecond)
+
+		stageID, err := getCompletionStageID(db, projectID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Casting stage not configured for project", "details": err.Error()})
+			return
+		}
+		counts, err := castElementTypeCounts(db, projectID, stageID, from, endOfDay)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch cast elements", "details": err.Error()})
+			return
+		}
+		if filter := c.Query("element_type_id"); filter != "" {
+			elementTypeID, err := strconv.Atoi(filter)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "element_type_id must be a valid integer"})
+				return
+			}
+			counts = map[int]int{elementTypeID: counts[elementTypeID]}
+			if counts[elementTypeID] == 0 {
+				delete(counts, elementTypeID)
+			}
+		}
+
+		elementTypeIDs := make([]int, 0, len(counts))
+		for id := range counts {
+			elementTypeIDs = append(elementTypeIDs, id)
+		}
+		rebars, err := fetchElementTypeRebars(db, elementTypeIDs)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch rebar detail", "details": err.Error()})
+			return
+		}
+
+		codes := make(map[int]string)
+		if len(elementTypeIDs) > 0 {
+			rows, err := db.Query(`SELECT element_type_id, element_type FROM element_type WHERE project_id = $1`, projectID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch element types", "details": err.Error()})
+				return
+			}
+			for rows.Next() {
+				var id int
+				var code string
+				if err := rows.Scan(&id, &code); err == nil {
+					codes[id] = code
+				}
+			}
+			rows.Close()
+		}
+
+		bbs := models.BarBendingSchedule{
+			ProjectID:   projectID,
+			Title:       fmt.Sprintf("Bar Bending Schedule - cast %s to %s", from.Format("2006-01-02"), to.Format("2006-01-02")),
+			From:        &from,
+			To:          &to,
+			GeneratedAt: time.Now(),
+		}
+		if err := db.QueryRow(`SELECT name FROM project WHERE project_id = $1`, projectID).Scan(&bbs.ProjectName); err != nil {
+			bbs.ProjectName = "project"
+		}
+		buildBBS(&bbs, rebars, counts, codes)
+
+		writeBBS(c, bbs, c.Query("format"), fmt.Sprintf("bbs_%d_%s_%s", projectID, from.Format("20060102"), to.Format("20060102")))
+	}
+}
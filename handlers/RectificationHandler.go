@@ -198,6 +198,10 @@ func UpdateRectificationHandler(db *sql.DB) gin.HandlerFunc {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save rectification", "details": err.Error()})
 				return
 			}
+			if err := completeNCRActionsForRectification(tx, el.ElementID, el.Status, el.Comments, userName); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update NCRs", "details": err.Error()})
+				return
+			}
 
 			if strings.ToLower(el.Status) == "approved" {
 				_, err := tx.Exec("UPDATE element SET disable = false WHERE id = $1", el.ElementID)
@@ -237,24 +241,12 @@ func UpdateRectificationHandler(db *sql.DB) gin.HandlerFunc {
 				}
 
 				// Step 4: Generate new element_id
-				var elementType, namingConvention string
-
-				err = tx.QueryRow("SELECT naming_convention FROM precast WHERE id = $1",
-					oldElement.TargetLocation).Scan(&namingConvention)
-				if err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch element type", "details": err.Error()})
-					return
-				}
-
-				var totalCount int
-				err = tx.QueryRow("SELECT element_type, total_count_element FROM element_type WHERE element_type_id = $1",
-					oldElement.ElementTypeID).Scan(&elementType, &totalCount)
+				newElementIDs, err := GenerateElementIDs(tx, oldElement.ElementTypeID, oldElement.TargetLocation, "", 1)
 				if err != nil {
-					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch element type", "details": err.Error()})
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate element ID", "details": err.Error()})
 					return
 				}
-
-				newElementID := repository.GenerateElementID(elementType, namingConvention, totalCount+1)
+				newElementID := newElementIDs[0]
 				newElement := oldElement
 				newElement.Id = repository.GenerateRandomNumber()
 				newElement.ElementId = newElementID
@@ -0,0 +1,513 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	defaultMinRemnantSide = 300.0      // mm, 2D leftovers smaller than this on either side are not stored
+	remnantIDPrefix       = "remnant:" // stock names/IDs the solvers carry for inventory remnants
+)
+
+// errRemnantUnavailable is returned when a remnant used by a run was consumed or scrapped meanwhile
+var errRemnantUnavailable = errors.New("remnant is no longer available, run the optimisation again")
+
+// loadBarRemnants returns the available bar remnants of a project as offcuts for the 1D solver.
+func loadBarRemnants(db *sql.DB, projectID int) ([]models.StockBar, error) {
+	rows, err := db.Query(`
+		SELECT id, length, diameter_mm FROM cutting_remnant
+		WHERE project_id = $1 AND kind = $2 AND status = $3
+		ORDER BY length`, projectID, models.RemnantKindBar, models.RemnantStatusAvailable)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	bars := []models.StockBar{}
+	for rows.Next() {
+		var id int
+		var bar models.StockBar
+		if err := rows.Scan(&id, &bar.Length, &bar.DiameterMM); err != nil {
+			return nil, err
+		}
+		bar.ID = fmt.Sprintf("%s%d", remnantIDPrefix, id)
+		bar.Qty = 1
+		bars = append(bars, bar)
+	}
+	return bars, rows.Err()
+}
+
+// loadSheetRemnants returns the available sheet remnants of a project as stock sheets for the 2D solver.
+func loadSheetRemnants(db *sql.DB, projectID int, material string) ([]models.StockSheet, error) {
+	rows, err := db.Query(`
+		SELECT id, width, height FROM cutting_remnant
+		WHERE project_id = $1 AND kind = $2 AND status = $3 AND material = $4
+		ORDER BY width * height`, projectID, models.RemnantKindSheet, models.RemnantStatusAvailable, material)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	sheets := []models.StockSheet{}
+	for rows.Next() {
+		var id int
+		var sheet models.StockSheet
+		if err := rows.Scan(&id, &sheet.Width, &sheet.Height); err != nil {
+			return nil, err
+		}
+		sheet.Name = fmt.Sprintf("%s%d", remnantIDPrefix, id)
+		sheet.Qty = 1
+		sheets = append(sheets, sheet)
+	}
+	return sheets, rows.Err()
+}
+
+// consumeRemnant marks a remnant as used by a run, recording how much of it was cut into parts.
+func consumeRemnant(tx *sql.Tx, id int, reusedSize float64, userName string) error {
+	res, err := tx.Exec(`
+		UPDATE cutting_remnant SET status = $2, reused_size = $3, consumed_by = $4, consumed_at = NOW()
+		WHERE id = $1 AND status = $5`,
+		id, models.RemnantStatusConsumed, reusedSize, userName, models.RemnantStatusAvailable)
+	if err != nil {
+		return err
+	}
+	if n, _ := res.RowsAffected(); n == 0 {
+		return errRemnantUnavailable
+	}
+	return nil
+}
+
+// insertRemnant stores a leftover in inventory and returns its ID.
+func insertRemnant(tx *sql.Tx, r models.Remnant) (int, error) {
+	var id int
+	err := tx.QueryRow(`
+		INSERT INTO cutting_remnant (project_id, kind, diameter_mm, material, length, width, height, location,
+			warehouse_id, status, source, created_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12) RETURNING id`,
+		r.ProjectID, r.Kind, r.DiameterMM, r.Material, r.Length, r.Width, r.Height, r.Location,
+		r.WarehouseID, models.RemnantStatusAvailable, r.Source, r.CreatedBy).Scan(&id)
+	return id, err
+}
+
+// saveBarRemnantRun consumes the remnants a 1D run cut from and stores its reusable leftovers.
+func saveBarRemnantRun(db *sql.DB, req models.Request1D, resp *models.Response1D, userName string) error {
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, p := range resp.Patterns {
+		if !p.FromOffcut || !strings.HasPrefix(p.StockID, remnantIDPrefix) {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(p.StockID, remnantIDPrefix))
+		if err != nil {
+			continue
+		}
+		if err := consumeRemnant(tx, id, p.UsedLength, userName); err != nil {
+			return err
+		}
+		resp.ConsumedRemnants = append(resp.ConsumedRemnants, id)
+	}
+
+	for _, p := range resp.Patterns {
+		if !p.LeftoverReusable {
+			continue
+		}
+		for i := 0; i < p.Repeat; i++ {
+			id, err := insertRemnant(tx, models.Remnant{
+				ProjectID:  req.ProjectID,
+				Kind:       models.RemnantKindBar,
+				DiameterMM: p.DiameterMM,
+				Length:     p.Leftover,
+				Location:   req.RemnantLocation,
+				Source:     "solve-1d",
+				CreatedBy:  userName,
+			})
+			if err != nil {
+				return err
+			}
+			resp.SavedRemnants = append(resp.SavedRemnants, id)
+		}
+	}
+
+	return tx.Commit()
+}
+
+// saveSheetRemnantRun consumes the remnant sheets a 2D run placed panels on and stores leftovers
+// at least MinRemnantWidth x MinRemnantHeight (in either orientation).
+func saveSheetRemnantRun(db *sql.DB, req models.Request, resp *models.Response, userName string) error {
+	minW, minH := req.MinRemnantWidth, req.MinRemnantHeight
+	if minW <= 0 {
+		minW = defaultMinRemnantSide
+	}
+	if minH <= 0 {
+		minH = defaultMinRemnantSide
+	}
+	minShort, minLong := math.Min(minW, minH), math.Max(minW, minH)
+
+	tx, err := db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	for _, us := range resp.UsedStockSheets {
+		if us.Panels == 0 || !strings.HasPrefix(us.StockSheet.Name, remnantIDPrefix) {
+			continue
+		}
+		id, err := strconv.Atoi(strings.TrimPrefix(us.StockSheet.Name, remnantIDPrefix))
+		if err != nil {
+			continue
+		}
+		if err := consumeRemnant(tx, id, us.UsedArea, userName); err != nil {
+			return err
+		}
+		resp.ConsumedRemnants = append(resp.ConsumedRemnants, id)
+	}
+
+	// Leftovers of sheets that were not cut at all are still the whole sheet, don't store them again
+	cutSheets := make(map[int]bool)
+	for _, pl := range resp.Placements {
+		cutSheets[pl.StockID] = true
+	}
+	for _, lo := range resp.Leftovers {
+		if !cutSheets[lo.StockID] {
+			continue
+		}
+		if math.Min(lo.Width, lo.Height) < minShort || math.Max(lo.Width, lo.Height) < minLong {
+			continue
+		}
+		id, err := insertRemnant(tx, models.Remnant{
+			ProjectID: req.ProjectID,
+			Kind:      models.RemnantKindSheet,
+			Material:  req.Material,
+			Width:     lo.Width,
+			Height:    lo.Height,
+			Location:  req.RemnantLocation,
+			Source:    "solve",
+			CreatedBy: userName,
+		})
+		if err != nil {
+			return err
+		}
+		resp.SavedRemnants = append(resp.SavedRemnants, id)
+	}
+
+	return tx.Commit()
+}
+
+// CreateRemnant adds a remnant to inventory by hand.
+// @Summary Add a remnant to inventory
+// @Tags Remnants
+// @Accept json
+// @Produce json
+// @Param request body models.CreateRemnantRequest true "Remnant"
+// @Success 201 {object} models.Remnant
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/remnants [post]
+func CreateRemnant(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+			return
+		}
+		_, userName, err := GetSessionDetails(db, sessionID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		var req models.CreateRemnantRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request body", "details": err.Error()})
+			return
+		}
+		switch req.Kind {
+		case models.RemnantKindBar:
+			if req.Length <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "length must be > 0 for bar remnants"})
+				return
+			}
+			req.Width, req.Height = 0, 0
+		case models.RemnantKindSheet:
+			if req.Width <= 0 || req.Height <= 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "width and height must be > 0 for sheet remnants"})
+				return
+			}
+			req.Length = 0
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "kind must be bar or sheet"})
+			return
+		}
+
+		remnant := models.Remnant{
+			ProjectID:   req.ProjectID,
+			Kind:        req.Kind,
+			DiameterMM:  req.DiameterMM,
+			Material:    req.Material,
+			Length:      req.Length,
+			Width:       req.Width,
+			Height:      req.Height,
+			Location:    req.Location,
+			WarehouseID: req.WarehouseID,
+			Status:      models.RemnantStatusAvailable,
+			Source:      "manual",
+			CreatedBy:   userName,
+			CreatedAt:   time.Now(),
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction", "details": err.Error()})
+			return
+		}
+		defer tx.Rollback()
+		if remnant.ID, err = insertRemnant(tx, remnant); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save remnant", "details": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save remnant", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, remnant)
+	}
+}
+
+// GetProjectRemnants lists the remnants of a project.
+// @Summary List remnants of a project
+// @Tags Remnants
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param status query string false "available (default), consumed, scrapped or all"
+// @Param kind query string false "bar or sheet"
+// @Success 200 {array} models.Remnant
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/remnants [get]
+func GetProjectRemnants(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+			return
+		}
+		if _, _, err := GetSessionDetails(db, sessionID); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project_id"})
+			return
+		}
+
+		query := `
+			SELECT id, project_id, kind, diameter_mm, material, length, width, height, location, warehouse_id,
+				status, source, created_by, created_at, reused_size, consumed_by, consumed_at
+			FROM cutting_remnant
+			WHERE project_id = $1`
+		args := []interface{}{projectID}
+		if status := c.DefaultQuery("status", models.RemnantStatusAvailable); status != "all" {
+			args = append(args, status)
+			query += fmt.Sprintf(" AND status = $%d", len(args))
+		}
+		if kind := c.Query("kind"); kind != "" {
+			args = append(args, kind)
+			query += fmt.Sprintf(" AND kind = $%d", len(args))
+		}
+		query += " ORDER BY kind, diameter_mm, length DESC, width * height DESC"
+
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch remnants", "details": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		remnants := []models.Remnant{}
+		for rows.Next() {
+			var r models.Remnant
+			var warehouseID sql.NullInt64
+			var consumedAt sql.NullTime
+			if err := rows.Scan(&r.ID, &r.ProjectID, &r.Kind, &r.DiameterMM, &r.Material, &r.Length, &r.Width, &r.Height,
+				&r.Location, &warehouseID, &r.Status, &r.Source, &r.CreatedBy, &r.CreatedAt, &r.ReusedSize,
+				&r.ConsumedBy, &consumedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan remnant", "details": err.Error()})
+				return
+			}
+			if warehouseID.Valid {
+				id := int(warehouseID.Int64)
+				r.WarehouseID = &id
+			}
+			if consumedAt.Valid {
+				r.ConsumedAt = &consumedAt.Time
+			}
+			remnants = append(remnants, r)
+		}
+
+		c.JSON(http.StatusOK, remnants)
+	}
+}
+
+// ScrapRemnant takes a remnant out of inventory without reusing it.
+// @Summary Scrap a remnant
+// @Tags Remnants
+// @Produce json
+// @Param id path int true "Remnant ID"
+// @Success 200 {object} models.MessageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/remnants/{id}/scrap [put]
+func ScrapRemnant(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+			return
+		}
+		_, userName, err := GetSessionDetails(db, sessionID)
+		if err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid remnant id"})
+			return
+		}
+
+		res, err := db.Exec(`
+			UPDATE cutting_remnant SET status = $2, consumed_by = $3, consumed_at = NOW()
+			WHERE id = $1 AND status = $4`, id, models.RemnantStatusScrapped, userName, models.RemnantStatusAvailable)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scrap remnant", "details": err.Error()})
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Available remnant not found"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Remnant scrapped"})
+	}
+}
+
+// GetRemnantSavingsReport shows how much material remnant reuse has saved for a project.
+// @Summary Remnant reuse savings of a project
+// @Tags Remnants
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Success 200 {object} models.RemnantSavingsReport
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/remnants/savings [get]
+func GetRemnantSavingsReport(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "session_id header is missing"})
+			return
+		}
+		if _, _, err := GetSessionDetails(db, sessionID); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
+
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid project_id"})
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT kind, status, diameter_mm, COUNT(*), COALESCE(SUM(length), 0), COALESCE(SUM(reused_size), 0)
+			FROM cutting_remnant
+			WHERE project_id = $1
+			GROUP BY kind, status, diameter_mm`, projectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch remnants", "details": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		report := models.RemnantSavingsReport{ProjectID: projectID, ByDiameter: []models.RemnantDiameterSaving{}}
+		byDiameter := make(map[int]*models.RemnantDiameterSaving)
+		for rows.Next() {
+			var kind, status string
+			var diameter, count int
+			var length, reused float64
+			if err := rows.Scan(&kind, &status, &diameter, &count, &length, &reused); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan remnants", "details": err.Error()})
+				return
+			}
+
+			if kind == models.RemnantKindSheet {
+				report.SheetsStored += count
+				switch status {
+				case models.RemnantStatusAvailable:
+					report.SheetsAvailable += count
+				case models.RemnantStatusConsumed:
+					report.SheetsConsumed += count
+					report.SheetsReusedArea += reused
+				case models.RemnantStatusScrapped:
+					report.SheetsScrapped += count
+				}
+				continue
+			}
+
+			report.BarsStored += count
+			switch status {
+			case models.RemnantStatusAvailable:
+				report.BarsAvailable += count
+				report.BarsAvailableM += length / 1000
+			case models.RemnantStatusConsumed:
+				report.BarsConsumed += count
+				saving, ok := byDiameter[diameter]
+				if !ok {
+					saving = &models.RemnantDiameterSaving{DiameterMM: diameter}
+					byDiameter[diameter] = saving
+				}
+				saving.ReusedLengthM += reused / 1000
+				if diameter > 0 {
+					saving.ReusedWeightKg += reused / 1000 * rebarUnitWeight(diameter)
+				}
+			case models.RemnantStatusScrapped:
+				report.BarsScrapped += count
+			}
+		}
+
+		for _, saving := range byDiameter {
+			report.BarsReusedM += saving.ReusedLengthM
+			report.BarsReusedWeightKg += saving.ReusedWeightKg
+			saving.ReusedLengthM = round(saving.ReusedLengthM)
+			saving.ReusedWeightKg = round(saving.ReusedWeightKg)
+			report.ByDiameter = append(report.ByDiameter, *saving)
+		}
+		sort.Slice(report.ByDiameter, func(i, j int) bool { return report.ByDiameter[i].DiameterMM < report.ByDiameter[j].DiameterMM })
+		report.BarsAvailableM = round(report.BarsAvailableM)
+		report.BarsReusedM = round(report.BarsReusedM)
+		report.BarsReusedWeightKg = round(report.BarsReusedWeightKg)
+		report.SheetsReusedArea = round(report.SheetsReusedArea)
+
+		c.JSON(http.StatusOK, report)
+	}
+}
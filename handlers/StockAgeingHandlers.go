@@ -0,0 +1,413 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// defaultAgeingAlertDays is the age at which managers hear about a piece when its stockyard has no
+// threshold of its own
+const defaultAgeingAlertDays = 90
+
+// SQL statements for stock ageing
+const (
+	// selectYardStockSQL lists the pieces lying in a stockyard with the storage rate of the yard. A
+	// piece without a production date is aged from when it was recorded.
+	selectYardStockSQL = `
+		SELECT ps.id, ps.element_id, COALESCE(e.element_name, ''), COALESCE(ps.element_type, ''),
+			ps.project_id, COALESCE(p.name, ''), ps.stockyard_id, COALESCE(s.yard_name, ''),
+			COALESCE(ps.production_date, ps.created_at), COALESCE(ps.weight, 0), COALESCE(ps.storage_location, ''),
+			COALESCE(r.rate_per_piece_day, 0), COALESCE(r.rate_per_tonne_day, 0), COALESCE(r.currency, ''),
+			COALESCE(r.alert_after_days, 90)
+		FROM precast_stock ps
+		LEFT JOIN element e ON e.id = ps.element_id
+		LEFT JOIN project p ON p.project_id = ps.project_id
+		LEFT JOIN stockyard s ON s.id = ps.stockyard_id
+		LEFT JOIN stockyard_storage_rate r ON r.stockyard_id = ps.stockyard_id
+		WHERE ps.stockyard = TRUE AND COALESCE(ps.dispatch_status, FALSE) = FALSE
+		  AND COALESCE(ps.erected, FALSE) = FALSE`
+)
+
+// yardStockPiece is a piece lying in a stockyard, aged on the day it was read.
+type yardStockPiece struct {
+	models.AgedStockPiece
+	ProjectName    string
+	Currency       string
+	AlertAfterDays int
+}
+
+// loadYardStock reads the pieces lying in stockyards with the given extra filter and ages them to
+// today. Storage is charged for every day started, at the rates of the stockyard the piece is in.
+func loadYardStock(q sqlQueryer, where string, args ...interface{}) ([]yardStockPiece, error) {
+	rows, err := q.Query(selectYardStockSQL+" "+where+" ORDER BY ps.stockyard_id, ps.project_id, ps.element_type, ps.element_id", args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	now := time.Now()
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	var pieces []yardStockPiece
+	for rows.Next() {
+		var p yardStockPiece
+		var ratePerPiece, ratePerTonne float64
+		if err := rows.Scan(&p.PrecastStockID, &p.ElementID, &p.ElementName, &p.ElementType,
+			&p.ProjectID, &p.ProjectName, &p.StockyardID, &p.YardName,
+			&p.ProductionDate, &p.WeightKg, &p.Location,
+			&ratePerPiece, &ratePerTonne, &p.Currency, &p.AlertAfterDays); err != nil {
+			return nil, err
+		}
+		produced := time.Date(p.ProductionDate.Year(), p.ProductionDate.Month(), p.ProductionDate.Day(), 0, 0, 0, 0, time.UTC)
+		p.AgeDays = int(today.Sub(produced).Hours() / 24)
+		if p.AgeDays < 0 {
+			p.AgeDays = 0
+		}
+		cost := float64(p.AgeDays) * (ratePerPiece + ratePerTonne*p.WeightKg/1000)
+		p.StorageCost = math.Round(cost*100) / 100
+		pieces = append(pieces, p)
+	}
+	return pieces, rows.Err()
+}
+
+// ageingBucket is the index in models.StockAgeingBuckets of an age.
+func ageingBucket(days int) int {
+	switch {
+	case days <= 30:
+		return 0
+	case days <= 60:
+		return 1
+	case days <= 90:
+		return 2
+	default:
+		return 3
+	}
+}
+
+// newAgeingBuckets returns empty buckets for every age band.
+func newAgeingBuckets() []models.StockAgeingBucket {
+	buckets := make([]models.StockAgeingBucket, len(models.StockAgeingBuckets))
+	for i, label := range models.StockAgeingBuckets {
+		buckets[i].Label = label
+	}
+	return buckets
+}
+
+// buildStockAgeingReport groups pieces by stockyard, project and element type.
+func buildStockAgeingReport(pieces []yardStockPiece, overDays int) models.StockAgeingReport {
+	report := models.StockAgeingReport{
+		AsOf:   time.Now().Format("2006-01-02"),
+		Rows:   []models.StockAgeingRow{},
+		Totals: newAgeingBuckets(),
+	}
+	type rowKey struct {
+		stockyardID, projectID int
+		elementType            string
+	}
+	index := make(map[rowKey]int)
+	ageSums := make(map[int]int)
+	currencies := make(map[string]bool)
+	for _, p := range pieces {
+		key := rowKey{p.StockyardID, p.ProjectID, p.ElementType}
+		i, ok := index[key]
+		if !ok {
+			i = len(report.Rows)
+			index[key] = i
+			report.Rows = append(report.Rows, models.StockAgeingRow{
+				StockyardID: p.StockyardID,
+				YardName:    p.YardName,
+				ProjectID:   p.ProjectID,
+				ProjectName: p.ProjectName,
+				ElementType: p.ElementType,
+				Buckets:     newAgeingBuckets(),
+			})
+		}
+		row := &report.Rows[i]
+		b := ageingBucket(p.AgeDays)
+		row.Buckets[b].Pieces++
+		row.Buckets[b].WeightKg += p.WeightKg
+		row.Pieces++
+		row.WeightKg += p.WeightKg
+		row.StorageCost += p.StorageCost
+		if p.AgeDays > row.OldestAgeDays {
+			row.OldestAgeDays = p.AgeDays
+		}
+		ageSums[i] += p.AgeDays
+
+		report.Totals[b].Pieces++
+		report.Totals[b].WeightKg += p.WeightKg
+		report.Pieces++
+		report.StorageCost += p.StorageCost
+		if p.Currency != "" {
+			currencies[p.Currency] = true
+		}
+		if overDays > 0 && p.AgeDays > overDays {
+			report.AgedPieces = append(report.AgedPieces, p.AgedStockPiece)
+		}
+	}
+	for i := range report.Rows {
+		row := &report.Rows[i]
+		row.AverageAgeDays = math.Round(float64(ageSums[i])/float64(row.Pieces)*10) / 10
+		row.StorageCost = math.Round(row.StorageCost*100) / 100
+	}
+	report.StorageCost = math.Round(report.StorageCost*100) / 100
+	if len(currencies) == 1 {
+		for currency := range currencies {
+			report.Currency = currency
+		}
+	}
+	sort.SliceStable(report.AgedPieces, func(i, j int) bool {
+		return report.AgedPieces[i].AgeDays > report.AgedPieces[j].AgeDays
+	})
+	return report
+}
+
+// GetStockAgeingReport reports how long the stock lying in stockyards has been there, per stockyard,
+// project and element type, with the storage cost to date.
+// @Summary Stock ageing report
+// @Tags Stock Ageing
+// @Produce json
+// @Param project_id query int false "Project ID"
+// @Param stockyard_id query int false "Stockyard ID"
+// @Param element_type query string false "Element type"
+// @Param over_days query int false "List the pieces older than this many days"
+// @Success 200 {object} models.StockAgeingReport
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/stock_ageing [get]
+func GetStockAgeingReport(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var where []string
+		var args []interface{}
+		for _, param := range []string{"project_id", "stockyard_id"} {
+			v := c.Query(param)
+			if v == "" {
+				continue
+			}
+			id, err := strconv.Atoi(v)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": param + " must be a valid integer"})
+				return
+			}
+			args = append(args, id)
+			where = append(where, fmt.Sprintf("ps.%s = $%d", param, len(args)))
+		}
+		if elementType := c.Query("element_type"); elementType != "" {
+			args = append(args, elementType)
+			where = append(where, fmt.Sprintf("ps.element_type = $%d", len(args)))
+		}
+		overDays := 0
+		if v := c.Query("over_days"); v != "" {
+			d, err := strconv.Atoi(v)
+			if err != nil || d < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "over_days must be a non-negative integer"})
+				return
+			}
+			overDays = d
+		}
+		filter := ""
+		if len(where) > 0 {
+			filter = "AND " + strings.Join(where, " AND ")
+		}
+
+		pieces, err := loadYardStock(db, filter, args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, buildStockAgeingReport(pieces, overDays))
+
+		projectID, _ := strconv.Atoi(c.Query("project_id"))
+		saveStockyardSlotActivity(db, session, userName, "Get", "Fetched stock ageing report", projectID)
+	}
+}
+
+// GetStockyardStorageRate returns the storage cost and alert threshold of a stockyard.
+// @Summary Get stockyard storage rate
+// @Tags Stock Ageing
+// @Produce json
+// @Param id path int true "Stockyard ID"
+// @Success 200 {object} models.StockyardStorageRate
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/stockyards/{id}/storage_rate [get]
+func GetStockyardStorageRate(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+		stockyardID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a valid integer"})
+			return
+		}
+
+		rate := models.StockyardStorageRate{StockyardID: stockyardID, AlertAfterDays: defaultAgeingAlertDays}
+		var updatedAt sql.NullTime
+		err = db.QueryRow(`
+			SELECT s.yard_name, COALESCE(r.rate_per_piece_day, 0), COALESCE(r.rate_per_tonne_day, 0),
+				COALESCE(r.currency, ''), COALESCE(r.alert_after_days, 90), COALESCE(r.updated_by, ''), r.updated_at
+			FROM stockyard s
+			LEFT JOIN stockyard_storage_rate r ON r.stockyard_id = s.id
+			WHERE s.id = $1`, stockyardID).Scan(&rate.YardName, &rate.RatePerPieceDay, &rate.RatePerTonneDay,
+			&rate.Currency, &rate.AlertAfterDays, &rate.UpdatedBy, &updatedAt)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Stockyard not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch storage rate", "details": err.Error()})
+			return
+		}
+		if updatedAt.Valid {
+			rate.UpdatedAt = updatedAt.Time
+		}
+		c.JSON(http.StatusOK, rate)
+	}
+}
+
+// SetStockyardStorageRate sets the storage cost and alert threshold of a stockyard.
+// @Summary Set stockyard storage rate
+// @Tags Stock Ageing
+// @Accept json
+// @Produce json
+// @Param id path int true "Stockyard ID"
+// @Param request body models.StorageRateRequest true "Storage rate"
+// @Success 200 {object} models.StockyardStorageRate
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/stockyards/{id}/storage_rate [put]
+func SetStockyardStorageRate(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+		stockyardID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a valid integer"})
+			return
+		}
+		var req models.StorageRateRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		if req.RatePerPieceDay < 0 || req.RatePerTonneDay < 0 || req.AlertAfterDays < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Rates and alert_after_days must not be negative"})
+			return
+		}
+		if req.AlertAfterDays == 0 {
+			req.AlertAfterDays = defaultAgeingAlertDays
+		}
+
+		rate := models.StockyardStorageRate{
+			StockyardID:     stockyardID,
+			RatePerPieceDay: req.RatePerPieceDay,
+			RatePerTonneDay: req.RatePerTonneDay,
+			Currency:        strings.ToUpper(strings.TrimSpace(req.Currency)),
+			AlertAfterDays:  req.AlertAfterDays,
+			UpdatedBy:       userName,
+		}
+		err = db.QueryRow(`SELECT yard_name FROM stockyard WHERE id = $1`, stockyardID).Scan(&rate.YardName)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Stockyard not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stockyard", "details": err.Error()})
+			return
+		}
+		err = db.QueryRow(`
+			INSERT INTO stockyard_storage_rate (stockyard_id, rate_per_piece_day, rate_per_tonne_day, currency,
+				alert_after_days, updated_by, updated_at)
+			VALUES ($1, $2, $3, $4, $5, $6, NOW())
+			ON CONFLICT (stockyard_id) DO UPDATE SET rate_per_piece_day = EXCLUDED.rate_per_piece_day,
+				rate_per_tonne_day = EXCLUDED.rate_per_tonne_day, currency = EXCLUDED.currency,
+				alert_after_days = EXCLUDED.alert_after_days, updated_by = EXCLUDED.updated_by, updated_at = EXCLUDED.updated_at
+			RETURNING updated_at`,
+			stockyardID, rate.RatePerPieceDay, rate.RatePerTonneDay, rate.Currency, rate.AlertAfterDays, userName).Scan(&rate.UpdatedAt)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save storage rate", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, rate)
+
+		saveStockyardSlotActivity(db, session, userName, "Update",
+			fmt.Sprintf("Set storage rate of %s to %.2f per piece and %.2f per tonne a day, alert after %d days",
+				rate.YardName, rate.RatePerPieceDay, rate.RatePerTonneDay, rate.AlertAfterDays), 0)
+	}
+}
+
+// SendStockAgeingAlerts tells the managers of each stockyard about pieces that have passed the
+// stockyard's alert threshold. A piece is reported once per stockyard it ages in.
+func SendStockAgeingAlerts(db *sql.DB, cronLogger *log.Logger) error {
+	pieces, err := loadYardStock(db, `
+		AND CURRENT_DATE - DATE(COALESCE(ps.production_date, ps.created_at)) > COALESCE(r.alert_after_days, 90)
+		AND NOT EXISTS (SELECT 1 FROM stock_ageing_alert a WHERE a.precast_stock_id = ps.id AND a.stockyard_id = ps.stockyard_id)`)
+	if err != nil {
+		return fmt.Errorf("failed to fetch aged stock: %v", err)
+	}
+
+	type yardKey struct{ projectID, stockyardID int }
+	groups := make(map[yardKey][]yardStockPiece)
+	var keys []yardKey
+	for _, p := range pieces {
+		key := yardKey{p.ProjectID, p.StockyardID}
+		if _, ok := groups[key]; !ok {
+			keys = append(keys, key)
+		}
+		groups[key] = append(groups[key], p)
+	}
+
+	alerted := 0
+	for _, key := range keys {
+		group := groups[key]
+		managers, err := stockyardManagers(db, key.projectID, key.stockyardID)
+		if err != nil {
+			return err
+		}
+		if len(managers) == 0 {
+			continue
+		}
+		oldest := 0
+		for _, p := range group {
+			if p.AgeDays > oldest {
+				oldest = p.AgeDays
+			}
+		}
+		first := group[0]
+		message := fmt.Sprintf("%d pieces of %s have been in %s for more than %d days (oldest %d days)",
+			len(group), first.ProjectName, first.YardName, first.AlertAfterDays, oldest)
+		action := fmt.Sprintf("https://precastezy.blueinvent.com/project/%d/stock-ageing", key.projectID)
+		for _, userID := range managers {
+			sendUserNotification(db, userID, message, action)
+		}
+		for _, p := range group {
+			if _, err := db.Exec(`
+				INSERT INTO stock_ageing_alert (precast_stock_id, stockyard_id) VALUES ($1, $2)
+				ON CONFLICT DO NOTHING`, p.PrecastStockID, p.StockyardID); err != nil {
+				return err
+			}
+		}
+		alerted += len(group)
+	}
+	if cronLogger != nil {
+		cronLogger.Printf("Sent stock ageing alerts for %d pieces", alerted)
+	}
+	return nil
+}
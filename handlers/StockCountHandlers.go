@@ -0,0 +1,963 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// SQL statements for the stock count (cycle count) workflow
+const (
+	// selectStockCountSQL reads a stock count header together with item progress
+	selectStockCountSQL = `
+		SELECT sc.id, sc.project_id, sc.warehouse_id, COALESCE(w.name, ''), sc.title, sc.status, sc.notes,
+			sc.created_by, sc.created_at, sc.frozen_at, sc.closed_at,
+			(SELECT COUNT(*) FROM stock_count_item i WHERE i.stock_count_id = sc.id),
+			(SELECT COUNT(*) FROM stock_count_item i WHERE i.stock_count_id = sc.id AND i.counted_qty IS NOT NULL)
+		FROM stock_count sc
+		LEFT JOIN inv_warehouse w ON w.id = sc.warehouse_id`
+
+	// recomputeStockCountItemSQL sets the item to its latest entry, whoever counted it, and marks
+	// it disputed when the latest figures of the counters disagree
+	recomputeStockCountItemSQL = `
+		WITH per_counter AS (
+			SELECT DISTINCT ON (counted_by) counted_by, counted_qty
+			FROM stock_count_entry
+			WHERE stock_count_id = $1 AND bom_id = $2
+			ORDER BY counted_by, counted_at DESC, id DESC
+		), latest AS (
+			SELECT counted_qty
+			FROM stock_count_entry
+			WHERE stock_count_id = $1 AND bom_id = $2
+			ORDER BY counted_at DESC, id DESC
+			LIMIT 1
+		)
+		UPDATE stock_count_item
+		SET counted_qty = (SELECT counted_qty FROM latest),
+			counters = (SELECT COUNT(*) FROM per_counter),
+			disputed = (SELECT COUNT(DISTINCT counted_qty) > 1 FROM per_counter)
+		WHERE stock_count_id = $1 AND bom_id = $2`
+)
+
+// scanStockCount scans a row produced by selectStockCountSQL.
+func scanStockCount(row interface{ Scan(...any) error }, sc *models.StockCount) error {
+	var closedAt sql.NullTime
+	err := row.Scan(&sc.ID, &sc.ProjectID, &sc.WarehouseID, &sc.WarehouseName, &sc.Title, &sc.Status, &sc.Notes,
+		&sc.CreatedBy, &sc.CreatedAt, &sc.FrozenAt, &closedAt, &sc.ItemCount, &sc.CountedItems)
+	if err != nil {
+		return err
+	}
+	if closedAt.Valid {
+		sc.ClosedAt = &closedAt.Time
+	}
+	return nil
+}
+
+// fetchStockCountItems loads the items of a stock count with their variances.
+func fetchStockCountItems(db *sql.DB, stockCountID int) ([]models.StockCountItem, error) {
+	rows, err := db.Query(`
+		SELECT id, stock_count_id, bom_id, bom_name, system_qty, counted_qty, counters, disputed, status,
+			reason_code, comments, decided_by, decided_at, adjustment_id
+		FROM stock_count_item
+		WHERE stock_count_id = $1
+		ORDER BY bom_name, bom_id`, stockCountID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	items := []models.StockCountItem{}
+	for rows.Next() {
+		var item models.StockCountItem
+		var countedQty sql.NullFloat64
+		var decidedAt sql.NullTime
+		var adjustmentID sql.NullInt64
+		if err := rows.Scan(&item.ID, &item.StockCountID, &item.BomID, &item.BomName, &item.SystemQty, &countedQty,
+			&item.Counters, &item.Disputed, &item.Status, &item.ReasonCode, &item.Comments, &item.DecidedBy, &decidedAt, &adjustmentID); err != nil {
+			return nil, err
+		}
+		if countedQty.Valid {
+			counted := countedQty.Float64
+			variance := counted - item.SystemQty
+			item.CountedQty = &counted
+			item.Variance = &variance
+		}
+		if decidedAt.Valid {
+			item.DecidedAt = &decidedAt.Time
+		}
+		if adjustmentID.Valid {
+			id := int(adjustmentID.Int64)
+			item.AdjustmentID = &id
+		}
+		items = append(items, item)
+	}
+	return items, rows.Err()
+}
+
+// insertStockCountLog appends an entry to the approval trail of a stock count.
+func insertStockCountLog(tx *sql.Tx, stockCountID int, itemID *int, action, actedBy, comments string) error {
+	_, err := tx.Exec(`
+		INSERT INTO stock_count_log (stock_count_id, item_id, action, acted_by, comments)
+		VALUES ($1, $2, $3, $4, $5)`, stockCountID, itemID, action, actedBy, comments)
+	return err
+}
+
+// CreateStockCount freezes the system quantities of a warehouse and opens a stock count.
+// @Summary Start a stock count
+// @Description Snapshot inv_track quantities of a warehouse so counted quantities can be collected against them
+// @Tags StockCount
+// @Accept json
+// @Produce json
+// @Param request body models.CreateStockCountRequest true "Stock count request"
+// @Success 201 {object} models.StockCount
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/stock-counts [post]
+func CreateStockCount(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var request models.CreateStockCountRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+
+		var warehouseProject int
+		err := db.QueryRow(`SELECT COALESCE(project_id, 0) FROM inv_warehouse WHERE id = $1`, request.WarehouseID).Scan(&warehouseProject)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Warehouse does not exist"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
+			return
+		}
+		if warehouseProject != 0 && warehouseProject != request.ProjectID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Warehouse does not belong to this project"})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+
+		// Only one count may be active per warehouse, otherwise two snapshots would post the same variance
+		var activeID int
+		err = tx.QueryRow(`
+			SELECT id FROM stock_count
+			WHERE warehouse_id = $1 AND project_id = $2 AND status IN ($3, $4)
+			LIMIT 1 FOR UPDATE`,
+			request.WarehouseID, request.ProjectID, models.StockCountStatusOpen, models.StockCountStatusReview).Scan(&activeID)
+		if err == nil {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Stock count #%d is still active for this warehouse", activeID)})
+			return
+		} else if err != sql.ErrNoRows {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
+			return
+		}
+
+		title := strings.TrimSpace(request.Title)
+		if title == "" {
+			title = "Stock count " + time.Now().Format("02 Jan 2006")
+		}
+
+		var stockCountID int
+		err = tx.QueryRow(`
+			INSERT INTO stock_count (project_id, warehouse_id, title, status, notes, created_by)
+			VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`,
+			request.ProjectID, request.WarehouseID, title, models.StockCountStatusOpen, request.Notes, userName).Scan(&stockCountID)
+		if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+			// Another count was started for the warehouse since the check above
+			c.JSON(http.StatusConflict, gin.H{"error": "A stock count is still active for this warehouse"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock count", "details": err.Error()})
+			return
+		}
+
+		// Freeze the current system quantities of the warehouse
+		snapshotQuery := `
+			INSERT INTO stock_count_item (stock_count_id, bom_id, bom_name, system_qty)
+			SELECT $1, t.bom_id, COALESCE(b.product_name, ''), SUM(COALESCE(t.bom_qty, 0))
+			FROM inv_track t
+			LEFT JOIN inv_bom b ON b.id = t.bom_id
+			WHERE t.project_id = $2 AND t.warehouse_id = $3`
+		args := []interface{}{stockCountID, request.ProjectID, request.WarehouseID}
+		if len(request.BomIDs) > 0 {
+			snapshotQuery += ` AND t.bom_id = ANY($4)`
+			args = append(args, pq.Array(request.BomIDs))
+		}
+		snapshotQuery += ` GROUP BY t.bom_id, b.product_name`
+		result, err := tx.Exec(snapshotQuery, args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to snapshot system quantities", "details": err.Error()})
+			return
+		}
+
+		// Requested products without an inv_track row are counted against a system quantity of zero
+		if len(request.BomIDs) > 0 {
+			if _, err := tx.Exec(`
+				INSERT INTO stock_count_item (stock_count_id, bom_id, bom_name, system_qty)
+				SELECT $1, b.id, b.product_name, 0
+				FROM inv_bom b
+				WHERE b.id = ANY($2)
+				ON CONFLICT (stock_count_id, bom_id) DO NOTHING`, stockCountID, pq.Array(request.BomIDs)); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to snapshot system quantities", "details": err.Error()})
+				return
+			}
+		} else if n, _ := result.RowsAffected(); n == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Warehouse has no tracked stock to count"})
+			return
+		}
+
+		if err := insertStockCountLog(tx, stockCountID, nil, "created", userName, request.Notes); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write stock count log", "details": err.Error()})
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+
+		var stockCount models.StockCount
+		if err := scanStockCount(db.QueryRow(selectStockCountSQL+` WHERE sc.id = $1`, stockCountID), &stockCount); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock count", "details": err.Error()})
+			return
+		}
+		if stockCount.Items, err = fetchStockCountItems(db, stockCountID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock count items", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusCreated, stockCount)
+
+		activityLog := models.ActivityLog{
+			EventContext: "Stock Count",
+			EventName:    "Create",
+			Description:  fmt.Sprintf("Started stock count #%d for warehouse %s", stockCountID, stockCount.WarehouseName),
+			UserName:     userName,
+			HostName:     session.HostName,
+			IPAddress:    session.IPAddress,
+			CreatedAt:    time.Now(),
+			ProjectID:    request.ProjectID,
+		}
+		_ = SaveActivityLog(db, activityLog)
+	}
+}
+
+// GetStockCountsByProject lists the stock counts of a project.
+// @Summary List stock counts
+// @Tags StockCount
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param status query string false "Filter by status (open, review, closed, cancelled)"
+// @Success 200 {array} models.StockCount
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/stock-counts [get]
+func GetStockCountsByProject(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+
+		query := selectStockCountSQL + ` WHERE sc.project_id = $1`
+		args := []interface{}{projectID}
+		if status := c.Query("status"); status != "" {
+			query += ` AND sc.status = $2`
+			args = append(args, status)
+		}
+		query += ` ORDER BY sc.created_at DESC`
+
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock counts", "details": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		stockCounts := []models.StockCount{}
+		for rows.Next() {
+			var sc models.StockCount
+			if err := scanStockCount(rows, &sc); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan stock count", "details": err.Error()})
+				return
+			}
+			stockCounts = append(stockCounts, sc)
+		}
+
+		c.JSON(http.StatusOK, stockCounts)
+	}
+}
+
+// GetStockCount returns a stock count with its items and variances.
+// @Summary Get stock count with variances
+// @Tags StockCount
+// @Produce json
+// @Param id path int true "Stock count ID"
+// @Param variance_only query bool false "Only return counted items whose quantity differs from the snapshot"
+// @Success 200 {object} models.StockCount
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/stock-counts/{id} [get]
+func GetStockCount(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		stockCountID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock count id"})
+			return
+		}
+
+		var stockCount models.StockCount
+		err = scanStockCount(db.QueryRow(selectStockCountSQL+` WHERE sc.id = $1`, stockCountID), &stockCount)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Stock count not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock count", "details": err.Error()})
+			return
+		}
+
+		items, err := fetchStockCountItems(db, stockCountID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock count items", "details": err.Error()})
+			return
+		}
+
+		if c.Query("variance_only") == "true" {
+			filtered := []models.StockCountItem{}
+			for _, item := range items {
+				if item.Variance != nil && math.Abs(*item.Variance) > 1e-9 {
+					filtered = append(filtered, item)
+				}
+			}
+			items = filtered
+		}
+		stockCount.Items = items
+
+		c.JSON(http.StatusOK, stockCount)
+	}
+}
+
+// SubmitStockCountEntries records quantities counted by the calling user.
+// Several counters may recount the same product; the counted quantity of an item is its latest
+// entry, and the item is marked disputed while the counters' latest figures disagree.
+// @Summary Submit counted quantities
+// @Description Used by the mobile app; the latest entry per product is the counted quantity, and disagreeing counters mark it disputed
+// @Tags StockCount
+// @Accept json
+// @Produce json
+// @Param id path int true "Stock count ID"
+// @Param request body models.StockCountEntryRequest true "Counted quantities"
+// @Success 200 {object} models.StockCount
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/stock-counts/{id}/entries [post]
+func SubmitStockCountEntries(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		stockCountID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock count id"})
+			return
+		}
+
+		var request models.StockCountEntryRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		if len(request.Entries) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "At least one entry is required"})
+			return
+		}
+		source := request.Source
+		if source == "" {
+			source = "web"
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+
+		var status string
+		err = tx.QueryRow(`SELECT status FROM stock_count WHERE id = $1 FOR UPDATE`, stockCountID).Scan(&status)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Stock count not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
+			return
+		}
+		if status != models.StockCountStatusOpen {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Stock count is %s and no longer accepts counts", status)})
+			return
+		}
+
+		for _, entry := range request.Entries {
+			if entry.CountedQty < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Counted quantity for BOM ID %d cannot be negative", entry.BomID)})
+				return
+			}
+
+			var exists bool
+			if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM stock_count_item WHERE stock_count_id = $1 AND bom_id = $2)`,
+				stockCountID, entry.BomID).Scan(&exists); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
+				return
+			}
+			if !exists {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("BOM ID %d is not part of this stock count", entry.BomID)})
+				return
+			}
+
+			if _, err := tx.Exec(`
+				INSERT INTO stock_count_entry (stock_count_id, bom_id, counted_qty, counted_by, counter_name, source)
+				VALUES ($1, $2, $3, $4, $5, $6)`,
+				stockCountID, entry.BomID, entry.CountedQty, session.UserID, userName, source); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to save count entry", "details": err.Error()})
+				return
+			}
+
+			if _, err := tx.Exec(recomputeStockCountItemSQL, stockCountID, entry.BomID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update counted quantity", "details": err.Error()})
+				return
+			}
+		}
+
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+
+		var stockCount models.StockCount
+		if err := scanStockCount(db.QueryRow(selectStockCountSQL+` WHERE sc.id = $1`, stockCountID), &stockCount); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock count", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":     fmt.Sprintf("%d count entries saved", len(request.Entries)),
+			"stock_count": stockCount,
+		})
+	}
+}
+
+// CloseStockCountCounting stops accepting counts and moves the stock count to review.
+// @Summary Close counting and start variance review
+// @Tags StockCount
+// @Produce json
+// @Param id path int true "Stock count ID"
+// @Success 200 {object} object
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/stock-counts/{id}/review [put]
+func CloseStockCountCounting(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		stockCountID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock count id"})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+
+		var status string
+		err = tx.QueryRow(`SELECT status FROM stock_count WHERE id = $1 FOR UPDATE`, stockCountID).Scan(&status)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Stock count not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
+			return
+		}
+		if status != models.StockCountStatusOpen {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Only open stock counts can be moved to review (current: %s)", status)})
+			return
+		}
+
+		// Items nobody counted, and undisputed items without a variance, need no approval
+		if _, err := tx.Exec(`
+			UPDATE stock_count_item
+			SET status = $2, decided_by = $3, decided_at = NOW(),
+				comments = CASE WHEN counted_qty IS NULL THEN 'Not counted' ELSE 'No variance' END
+			WHERE stock_count_id = $1 AND (counted_qty IS NULL OR (counted_qty = system_qty AND NOT disputed))`,
+			stockCountID, models.StockCountItemApproved, userName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to settle items without variance", "details": err.Error()})
+			return
+		}
+
+		if _, err := tx.Exec(`UPDATE stock_count SET status = $2 WHERE id = $1`, stockCountID, models.StockCountStatusReview); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock count", "details": err.Error()})
+			return
+		}
+		if err := closeStockCountIfDecided(tx, stockCountID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock count", "details": err.Error()})
+			return
+		}
+		if err := insertStockCountLog(tx, stockCountID, nil, "counting_closed", userName, ""); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write stock count log", "details": err.Error()})
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Counting closed, variances are ready for review"})
+	}
+}
+
+// closeStockCountIfDecided closes a stock count under review once no item is pending.
+func closeStockCountIfDecided(tx *sql.Tx, stockCountID int) error {
+	_, err := tx.Exec(`
+		UPDATE stock_count SET status = $2, closed_at = NOW()
+		WHERE id = $1 AND status = $3
+			AND NOT EXISTS (SELECT 1 FROM stock_count_item WHERE stock_count_id = $1 AND status = $4)`,
+		stockCountID, models.StockCountStatusClosed, models.StockCountStatusReview, models.StockCountItemPending)
+	return err
+}
+
+// ApproveStockCountVariances approves or rejects variances and posts approved ones to inventory.
+// The variance (counted - snapshot) is applied to the current warehouse quantity, so stock
+// movements recorded between the snapshot and the approval are preserved. Nothing is approved
+// while a line is disputed; disputed lines can only be rejected.
+// @Summary Approve or reject stock count variances
+// @Tags StockCount
+// @Accept json
+// @Produce json
+// @Param id path int true "Stock count ID"
+// @Param request body models.StockCountApprovalRequest true "Decisions"
+// @Success 200 {object} models.StockCount
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/stock-counts/{id}/approve [post]
+func ApproveStockCountVariances(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		stockCountID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock count id"})
+			return
+		}
+
+		var request models.StockCountApprovalRequest
+		if err := c.ShouldBindJSON(&request); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+
+		var status string
+		var projectID, warehouseID int
+		err = tx.QueryRow(`SELECT status, project_id, warehouse_id FROM stock_count WHERE id = $1 FOR UPDATE`, stockCountID).
+			Scan(&status, &projectID, &warehouseID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Stock count not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
+			return
+		}
+		if status != models.StockCountStatusReview {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Variances can only be decided while the stock count is in review (current: %s)", status)})
+			return
+		}
+
+		// Counters who disagree leave no figure to post; such lines can only be rejected
+		var disputedIDs []int64
+		err = tx.QueryRow(`
+			SELECT COALESCE(array_agg(id ORDER BY id), '{}') FROM stock_count_item
+			WHERE stock_count_id = $1 AND disputed AND status = $2`,
+			stockCountID, models.StockCountItemPending).Scan(pq.Array(&disputedIDs))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
+			return
+		}
+		if len(disputedIDs) > 0 {
+			for _, decision := range request.Decisions {
+				if decision.Approve {
+					c.JSON(http.StatusConflict, gin.H{"error": "Variances can't be approved while items are disputed", "disputed_items": disputedIDs})
+					return
+				}
+			}
+		}
+
+		posted := 0
+		for _, decision := range request.Decisions {
+			var item models.StockCountItem
+			var countedQty sql.NullFloat64
+			err := tx.QueryRow(`
+				SELECT id, bom_id, system_qty, counted_qty, status
+				FROM stock_count_item WHERE id = $1 AND stock_count_id = $2 FOR UPDATE`,
+				decision.ItemID, stockCountID).Scan(&item.ID, &item.BomID, &item.SystemQty, &countedQty, &item.Status)
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Item %d is not part of this stock count", decision.ItemID)})
+				return
+			} else if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Database error", "details": err.Error()})
+				return
+			}
+			if item.Status != models.StockCountItemPending {
+				c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Item %d has already been %s", item.ID, item.Status)})
+				return
+			}
+
+			itemID := item.ID
+			if !decision.Approve {
+				if _, err := tx.Exec(`
+					UPDATE stock_count_item SET status = $2, comments = $3, decided_by = $4, decided_at = NOW()
+					WHERE id = $1`, item.ID, models.StockCountItemRejected, decision.Comments, userName); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to reject variance", "details": err.Error()})
+					return
+				}
+				if err := insertStockCountLog(tx, stockCountID, &itemID, "rejected", userName, decision.Comments); err != nil {
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write stock count log", "details": err.Error()})
+					return
+				}
+				continue
+			}
+
+			if _, valid := models.StockCountReasonCodes[decision.ReasonCode]; !valid {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Invalid reason_code '%s' for item %d", decision.ReasonCode, item.ID), "reason_codes": models.StockCountReasonCodes})
+				return
+			}
+			if decision.ReasonCode == "other" && strings.TrimSpace(decision.Comments) == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Comments are required for reason 'other' on item %d", item.ID)})
+				return
+			}
+
+			variance := countedQty.Float64 - item.SystemQty
+
+			// Apply the variance to the current warehouse quantity. The snapshot summed every
+			// inv_track row of the product, so every one of them is locked and the variance is
+			// booked on the oldest.
+			trackRows, err := tx.Query(`
+				SELECT id, COALESCE(bom_qty, 0) FROM inv_track
+				WHERE project_id = $1 AND bom_id = $2 AND warehouse_id = $3
+				ORDER BY id
+				FOR UPDATE`, projectID, item.BomID, warehouseID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current inventory", "details": err.Error()})
+				return
+			}
+			var trackIDs []int
+			var trackQtys []float64
+			var currentQty float64
+			for trackRows.Next() {
+				var trackID int
+				var qty float64
+				if err := trackRows.Scan(&trackID, &qty); err != nil {
+					trackRows.Close()
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current inventory", "details": err.Error()})
+					return
+				}
+				trackIDs = append(trackIDs, trackID)
+				trackQtys = append(trackQtys, qty)
+				currentQty += qty
+			}
+			trackRows.Close()
+			if err := trackRows.Err(); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to get current inventory", "details": err.Error()})
+				return
+			}
+			newQty := currentQty + variance
+			if newQty < 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("Posting item %d would make inventory negative (current: %.2f, variance: %.2f)", item.ID, currentQty, variance)})
+				return
+			}
+			if len(trackIDs) == 0 {
+				_, err = tx.Exec(`
+					INSERT INTO inv_track (project_id, bom_id, bom_qty, warehouse_id, last_updated)
+					VALUES ($1, $2, $3, $4, $5)`, projectID, item.BomID, newQty, warehouseID, time.Now())
+			} else {
+				// A shortage larger than the oldest row is taken from the following rows in order
+				remaining := variance
+				for i, trackID := range trackIDs {
+					qty := trackQtys[i] + remaining
+					if qty < 0 && i < len(trackIDs)-1 {
+						remaining = qty
+						qty = 0
+					} else {
+						remaining = 0
+					}
+					if qty == trackQtys[i] {
+						continue
+					}
+					if _, err = tx.Exec(`UPDATE inv_track SET bom_qty = $1, last_updated = $2 WHERE id = $3`,
+						qty, time.Now(), trackID); err != nil {
+						break
+					}
+					if remaining == 0 {
+						break
+					}
+				}
+			}
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update inventory", "details": err.Error()})
+				return
+			}
+
+			operation := "add"
+			if variance < 0 {
+				operation = "subtract"
+			}
+			var adjustmentID int
+			err = tx.QueryRow(`
+				INSERT INTO inv_adjustment (
+					element_type_id, product_id, quantity, reason,
+					adjusted_by, adjusted_at, project_id, element_caunt
+				) VALUES (NULL, $1, $2, $3, $4, $5, $6, 0)
+				RETURNING id`,
+				item.BomID, math.Abs(variance),
+				fmt.Sprintf("Stock count #%d %s (%s)", stockCountID, operation, decision.ReasonCode),
+				userName, time.Now(), projectID).Scan(&adjustmentID)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create adjustment log", "details": err.Error()})
+				return
+			}
+
+			if _, err := tx.Exec(`
+				UPDATE stock_count_item
+				SET status = $2, reason_code = $3, comments = $4, decided_by = $5, decided_at = NOW(), adjustment_id = $6
+				WHERE id = $1`,
+				item.ID, models.StockCountItemApproved, decision.ReasonCode, decision.Comments, userName, adjustmentID); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to approve variance", "details": err.Error()})
+				return
+			}
+			if err := insertStockCountLog(tx, stockCountID, &itemID, "approved",
+				userName, strings.TrimSpace(decision.ReasonCode+" "+decision.Comments)); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write stock count log", "details": err.Error()})
+				return
+			}
+			posted++
+		}
+
+		if err := closeStockCountIfDecided(tx, stockCountID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock count", "details": err.Error()})
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+
+		var stockCount models.StockCount
+		if err := scanStockCount(db.QueryRow(selectStockCountSQL+` WHERE sc.id = $1`, stockCountID), &stockCount); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock count", "details": err.Error()})
+			return
+		}
+		if stockCount.Items, err = fetchStockCountItems(db, stockCountID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock count items", "details": err.Error()})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"message":          fmt.Sprintf("%d adjustments posted", posted),
+			"stock_count":      stockCount,
+			"adjustments_made": posted,
+		})
+
+		activityLog := models.ActivityLog{
+			EventContext: "Stock Count",
+			EventName:    "Approve",
+			Description:  fmt.Sprintf("Decided %d variances on stock count #%d, %d adjustments posted", len(request.Decisions), stockCountID, posted),
+			UserName:     userName,
+			HostName:     session.HostName,
+			IPAddress:    session.IPAddress,
+			CreatedAt:    time.Now(),
+			ProjectID:    projectID,
+		}
+		_ = SaveActivityLog(db, activityLog)
+	}
+}
+
+// CancelStockCount abandons a stock count that has not been closed. Nothing is posted.
+// @Summary Cancel stock count
+// @Tags StockCount
+// @Produce json
+// @Param id path int true "Stock count ID"
+// @Success 200 {object} object
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/stock-counts/{id}/cancel [put]
+func CancelStockCount(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		_, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		stockCountID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock count id"})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+
+		// Approved variances are already posted, so a count with decisions can no longer be cancelled
+		result, err := tx.Exec(`
+			UPDATE stock_count SET status = $2, closed_at = NOW()
+			WHERE id = $1 AND status IN ($3, $4)
+				AND NOT EXISTS (SELECT 1 FROM stock_count_item WHERE stock_count_id = $1 AND adjustment_id IS NOT NULL)`,
+			stockCountID, models.StockCountStatusCancelled, models.StockCountStatusOpen, models.StockCountStatusReview)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel stock count", "details": err.Error()})
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": "Stock count not found, already closed, or has posted adjustments"})
+			return
+		}
+		if err := insertStockCountLog(tx, stockCountID, nil, "cancelled", userName, c.Query("comments")); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to write stock count log", "details": err.Error()})
+			return
+		}
+
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction"})
+			return
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Stock count cancelled"})
+	}
+}
+
+// GetStockCountLogs returns the approval trail and the raw count entries of a stock count.
+// @Summary Get stock count approval trail
+// @Tags StockCount
+// @Produce json
+// @Param id path int true "Stock count ID"
+// @Success 200 {object} object
+// @Router /api/stock-counts/{id}/logs [get]
+func GetStockCountLogs(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		stockCountID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid stock count id"})
+			return
+		}
+
+		logRows, err := db.Query(`
+			SELECT id, stock_count_id, item_id, action, acted_by, comments, created_at
+			FROM stock_count_log WHERE stock_count_id = $1 ORDER BY created_at, id`, stockCountID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock count logs", "details": err.Error()})
+			return
+		}
+		defer logRows.Close()
+
+		logs := []models.StockCountLog{}
+		for logRows.Next() {
+			var entry models.StockCountLog
+			var itemID sql.NullInt64
+			if err := logRows.Scan(&entry.ID, &entry.StockCountID, &itemID, &entry.Action, &entry.ActedBy, &entry.Comments, &entry.CreatedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan stock count log", "details": err.Error()})
+				return
+			}
+			if itemID.Valid {
+				id := int(itemID.Int64)
+				entry.ItemID = &id
+			}
+			logs = append(logs, entry)
+		}
+
+		entryRows, err := db.Query(`
+			SELECT id, stock_count_id, bom_id, counted_qty, counted_by, counter_name, source, counted_at
+			FROM stock_count_entry WHERE stock_count_id = $1 ORDER BY counted_at, id`, stockCountID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch count entries", "details": err.Error()})
+			return
+		}
+		defer entryRows.Close()
+
+		entries := []models.StockCountEntry{}
+		for entryRows.Next() {
+			var entry models.StockCountEntry
+			if err := entryRows.Scan(&entry.ID, &entry.StockCountID, &entry.BomID, &entry.CountedQty, &entry.CountedBy,
+				&entry.CounterName, &entry.Source, &entry.CountedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan count entry", "details": err.Error()})
+				return
+			}
+			entries = append(entries, entry)
+		}
+
+		c.JSON(http.StatusOK, gin.H{
+			"logs":    logs,
+			"entries": entries,
+		})
+	}
+}
+
+// GetStockCountReasonCodes returns the reason codes accepted for variance approval.
+// @Summary List stock count reason codes
+// @Tags StockCount
+// @Produce json
+// @Success 200 {object} object
+// @Router /api/stock-counts/reason-codes [get]
+func GetStockCountReasonCodes(c *gin.Context) {
+	c.JSON(http.StatusOK, models.StockCountReasonCodes)
+}
@@ -0,0 +1,880 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// Stock transfer statuses, in addition to the dispatch ones. An item is Returned when its transfer is
+// cancelled.
+const (
+	StatusPartiallyReceived = "Partially Received"
+	StatusCancelled         = "Cancelled"
+	StatusReturned          = "Returned"
+)
+
+// StockTransferActionTemplate is the notification action URL of the transfers of a project
+const StockTransferActionTemplate = "https://precastezy.blueinvent.com/project/%d/stock-transfers"
+
+// SQL statements for stock transfers
+const (
+	selectStockTransferSQL = `
+		SELECT t.id, t.project_id, t.transfer_no, t.from_stockyard_id, COALESCE(fy.yard_name, ''),
+			t.to_stockyard_id, COALESCE(ty.yard_name, ''), COALESCE(t.vehicle_id, 0), COALESCE(v.vehicle_number, ''),
+			t.driver_name, t.status, t.remarks, t.created_by, t.dispatched_at, t.in_transit_at, t.received_at, t.cancelled_at,
+			(SELECT COUNT(*) FROM stock_transfer_item i WHERE i.transfer_id = t.id),
+			(SELECT COUNT(*) FROM stock_transfer_item i WHERE i.transfer_id = t.id AND i.status = 'Received')
+		FROM stock_transfer t
+		LEFT JOIN stockyard fy ON fy.id = t.from_stockyard_id
+		LEFT JOIN stockyard ty ON ty.id = t.to_stockyard_id
+		LEFT JOIN vehicle_details v ON v.id = t.vehicle_id`
+
+	// inTransitStockSQL is the condition for a precast_stock row (ps) that is on a transfer not yet
+	// received or cancelled.
+	inTransitStockSQL = `EXISTS (SELECT 1 FROM stock_transfer_item sti WHERE sti.precast_stock_id = ps.id AND sti.status = 'In Transit')`
+)
+
+// scanStockTransfer scans a row produced by selectStockTransferSQL.
+func scanStockTransfer(row interface{ Scan(...any) error }, t *models.StockTransfer) error {
+	var inTransitAt, receivedAt, cancelledAt sql.NullTime
+	err := row.Scan(&t.ID, &t.ProjectID, &t.TransferNo, &t.FromStockyardID, &t.FromYardName,
+		&t.ToStockyardID, &t.ToYardName, &t.VehicleID, &t.VehicleNumber,
+		&t.DriverName, &t.Status, &t.Remarks, &t.CreatedBy, &t.DispatchedAt, &inTransitAt, &receivedAt, &cancelledAt,
+		&t.ItemCount, &t.ReceivedCount)
+	if err != nil {
+		return err
+	}
+	if inTransitAt.Valid {
+		t.InTransitAt = &inTransitAt.Time
+	}
+	if receivedAt.Valid {
+		t.ReceivedAt = &receivedAt.Time
+	}
+	if cancelledAt.Valid {
+		t.CancelledAt = &cancelledAt.Time
+	}
+	return nil
+}
+
+// loadStockTransfer reads a transfer with its items and tracking log.
+func loadStockTransfer(q sqlQueryer, id int) (models.StockTransfer, error) {
+	var t models.StockTransfer
+	if err := scanStockTransfer(q.QueryRow(selectStockTransferSQL+" WHERE t.id = $1", id), &t); err != nil {
+		return t, err
+	}
+
+	rows, err := q.Query(`
+		SELECT i.precast_stock_id, i.element_id, COALESCE(e.element_name, ''), COALESCE(ps.element_type, ''),
+			i.status, i.closed_at, i.closed_by
+		FROM stock_transfer_item i
+		LEFT JOIN element e ON e.id = i.element_id
+		LEFT JOIN precast_stock ps ON ps.id = i.precast_stock_id
+		WHERE i.transfer_id = $1
+		ORDER BY i.element_id`, id)
+	if err != nil {
+		return t, err
+	}
+	t.Items = []models.StockTransferItem{}
+	for rows.Next() {
+		var item models.StockTransferItem
+		var closedAt sql.NullTime
+		if err := rows.Scan(&item.PrecastStockID, &item.ElementID, &item.ElementName, &item.ElementType,
+			&item.Status, &closedAt, &item.ClosedBy); err != nil {
+			rows.Close()
+			return t, err
+		}
+		if closedAt.Valid {
+			item.ClosedAt = &closedAt.Time
+		}
+		t.Items = append(t.Items, item)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil {
+		return t, err
+	}
+
+	rows, err = q.Query(`
+		SELECT id, order_number, status, location, remarks, status_timestamp, created_at, updated_at
+		FROM dispatch_tracking_logs
+		WHERE order_number = $1
+		ORDER BY status_timestamp, id`, t.TransferNo)
+	if err != nil {
+		return t, err
+	}
+	defer rows.Close()
+	t.TrackingLog = []models.DispatchTrackingLog{}
+	for rows.Next() {
+		var l models.DispatchTrackingLog
+		if err := rows.Scan(&l.ID, &l.OrderNumber, &l.Status, &l.Location, &l.Remarks, &l.StatusTimestamp,
+			&l.CreatedAt, &l.UpdatedAt); err != nil {
+			return t, err
+		}
+		t.TrackingLog = append(t.TrackingLog, l)
+	}
+	return t, rows.Err()
+}
+
+// stockTransferParam reads the transfer named by the :id path parameter, writing the error response
+// when it can't.
+func stockTransferParam(c *gin.Context, db *sql.DB) (models.StockTransfer, bool) {
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a valid integer"})
+		return models.StockTransfer{}, false
+	}
+	t, err := loadStockTransfer(db, id)
+	if err == sql.ErrNoRows {
+		c.JSON(http.StatusNotFound, gin.H{"error": "Stock transfer not found"})
+		return t, false
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock transfer", "details": err.Error()})
+		return t, false
+	}
+	return t, true
+}
+
+// stockyardManagers lists the users managing a stockyard of a project.
+func stockyardManagers(db *sql.DB, projectID, stockyardID int) ([]int, error) {
+	rows, err := db.Query(`
+		SELECT DISTINCT user_id FROM project_stockyard
+		WHERE project_id = $1 AND stockyard_id = $2 AND user_id IS NOT NULL`, projectID, stockyardID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	var ids []int
+	for rows.Next() {
+		var id int
+		if err := rows.Scan(&id); err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	return ids, rows.Err()
+}
+
+// canActAtStockyard tells whether a user may confirm movements at a stockyard: its managers can, and
+// admins. A stockyard without a manager is open to every user of the project.
+func canActAtStockyard(db *sql.DB, projectID, stockyardID, userID int) (bool, error) {
+	managers, err := stockyardManagers(db, projectID, stockyardID)
+	if err != nil {
+		return false, err
+	}
+	if len(managers) == 0 {
+		return true, nil
+	}
+	for _, id := range managers {
+		if id == userID {
+			return true, nil
+		}
+	}
+	return isGlobalAdmin(db, userID)
+}
+
+// stockTransferActor checks the session user may confirm movements at a stockyard, writing the error
+// response when they may not.
+func stockTransferActor(c *gin.Context, db *sql.DB, projectID, stockyardID int) bool {
+	userID, err := getUserIDFromSession(c.Request.Context(), db, c.GetHeader("Authorization"))
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, gin.H{"error": err.Error()})
+		return false
+	}
+	allowed, err := canActAtStockyard(db, projectID, stockyardID, userID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check stockyard access", "details": err.Error()})
+		return false
+	}
+	if !allowed {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only a manager of the stockyard can do this"})
+		return false
+	}
+	return true
+}
+
+// saveStockTransferActivity writes the activity log of a stock transfer change.
+func saveStockTransferActivity(db *sql.DB, session models.Session, userName, eventName, description string, projectID int) {
+	activityLog := models.ActivityLog{
+		EventContext: "StockTransfer",
+		EventName:    eventName,
+		Description:  description,
+		UserName:     userName,
+		HostName:     session.HostName,
+		IPAddress:    session.IPAddress,
+		CreatedAt:    time.Now(),
+		ProjectID:    projectID,
+	}
+	if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+		log.Printf("Failed to save activity log: %v", logErr)
+	}
+}
+
+// notifyStockyardManagers notifies the managers of a stockyard, or the project when it has none.
+func notifyStockyardManagers(db *sql.DB, projectID, stockyardID int, message string) {
+	action := fmt.Sprintf(StockTransferActionTemplate, projectID)
+	managers, err := stockyardManagers(db, projectID, stockyardID)
+	if err != nil {
+		log.Printf("Failed to fetch stockyard managers: %v", err)
+	}
+	if len(managers) == 0 {
+		sendProjectNotifications(db, projectID, message, action)
+		return
+	}
+	for _, id := range managers {
+		sendUserNotification(db, id, message, action)
+	}
+}
+
+// CreateStockTransfer dispatches elements from one stockyard of a project to another. The elements
+// stay on the books of the source stockyard until the destination confirms receipt.
+// @Summary Create stock transfer
+// @Tags Stock Transfers
+// @Accept json
+// @Produce json
+// @Param request body models.CreateStockTransferRequest true "Transfer"
+// @Success 201 {object} models.StockTransfer
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/stock_transfers [post]
+func CreateStockTransfer(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var req models.CreateStockTransferRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		if req.FromStockyardID == req.ToStockyardID {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "from_stockyard_id and to_stockyard_id must be different"})
+			return
+		}
+		if req.VehicleID == 0 && strings.TrimSpace(req.VehicleNumber) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "vehicle_id or vehicle_number is required"})
+			return
+		}
+		seen := make(map[int]bool)
+		items := make([]int, 0, len(req.Items))
+		for _, id := range req.Items {
+			if !seen[id] {
+				seen[id] = true
+				items = append(items, id)
+			}
+		}
+		if len(items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "items must not be empty"})
+			return
+		}
+
+		var linked int
+		err := db.QueryRow(`
+			SELECT COUNT(DISTINCT stockyard_id) FROM project_stockyard
+			WHERE project_id = $1 AND stockyard_id IN ($2, $3)`,
+			req.ProjectID, req.FromStockyardID, req.ToStockyardID).Scan(&linked)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check project stockyards", "details": err.Error()})
+			return
+		}
+		if linked != 2 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Both stockyards must be assigned to the project"})
+			return
+		}
+		if !stockTransferActor(c, db, req.ProjectID, req.FromStockyardID) {
+			return
+		}
+
+		ctx := c.Request.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+		now := time.Now()
+
+		// Green elements are not moved any more than they are dispatched to site
+		if err := lockConcreteHoldElements(tx, items); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock elements", "details": err.Error()})
+			return
+		}
+		holds, err := ConcreteStrengthHolds(tx, items)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check concrete strength holds", "details": err.Error()})
+			return
+		}
+		if len(holds) > 0 {
+			c.JSON(http.StatusConflict, gin.H{
+				"error":         "Some elements have not reached their release strength",
+				"held_elements": holds,
+			})
+			return
+		}
+
+		// Only pieces lying in the source stockyard and not already on another transfer can go
+		rows, err := tx.Query(`
+			SELECT ps.id, ps.element_id
+			FROM precast_stock ps
+			WHERE ps.project_id = $1 AND ps.element_id = ANY($2) AND ps.stockyard_id = $3
+			  AND ps.stockyard = TRUE AND COALESCE(ps.dispatch_status, FALSE) = FALSE
+			  AND COALESCE(ps.erected, FALSE) = FALSE
+			  AND NOT `+inTransitStockSQL+`
+			FOR UPDATE OF ps`, req.ProjectID, pq.Array(items), req.FromStockyardID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock", "details": err.Error()})
+			return
+		}
+		stockIDs := make(map[int]int)
+		for rows.Next() {
+			var stockID, elementID int
+			if err := rows.Scan(&stockID, &elementID); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read stock", "details": err.Error()})
+				return
+			}
+			stockIDs[elementID] = stockID
+		}
+		rows.Close()
+		var unavailable []int
+		for _, id := range items {
+			if _, ok := stockIDs[id]; !ok {
+				unavailable = append(unavailable, id)
+			}
+		}
+		if len(unavailable) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":                "Some elements are not available in the source stockyard",
+				"unavailable_elements": unavailable,
+			})
+			return
+		}
+
+		vehicleID := req.VehicleID
+		if vehicleID == 0 {
+			vehicleID, err = createOrUpdateVehicle(ctx, tx, req.VehicleNumber, req.DriverName, req.DriverPhoneNo,
+				req.EmergencyContactPhoneNo, req.Capacity, req.TransporterID, req.TruckType, now)
+			if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create or update vehicle", "details": err.Error()})
+				return
+			}
+		}
+
+		if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('stock_transfer'))`); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to number transfer", "details": err.Error()})
+			return
+		}
+		var seq int
+		if err := tx.QueryRow(`SELECT COUNT(*) + 1 FROM stock_transfer`).Scan(&seq); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to number transfer", "details": err.Error()})
+			return
+		}
+		transferNo := fmt.Sprintf("TRF-%05d", seq)
+
+		var transferID int
+		err = tx.QueryRow(`
+			INSERT INTO stock_transfer (project_id, transfer_no, from_stockyard_id, to_stockyard_id, vehicle_id,
+				driver_name, status, remarks, created_by, dispatched_at)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10)
+			RETURNING id`,
+			req.ProjectID, transferNo, req.FromStockyardID, req.ToStockyardID, vehicleID,
+			req.DriverName, StatusDispatched, req.Remarks, userName, now).Scan(&transferID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create stock transfer", "details": err.Error()})
+			return
+		}
+		moved := make([]int64, 0, len(items))
+		for _, elementID := range items {
+			stockID := stockIDs[elementID]
+			if _, err := tx.Exec(`
+				INSERT INTO stock_transfer_item (transfer_id, precast_stock_id, element_id, status)
+				VALUES ($1, $2, $3, $4)`, transferID, stockID, elementID, StatusInTransit); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add transfer item", "details": err.Error()})
+				return
+			}
+			moved = append(moved, int64(stockID))
+		}
+
+		// The pieces leave their slots when they are loaded
+		if _, err := tx.Exec(`DELETE FROM stock_slot_placement WHERE precast_stock_id = ANY($1)`, pq.Array(moved)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to clear slots", "details": err.Error()})
+			return
+		}
+		if _, err := tx.Exec(`UPDATE precast_stock SET storage_location = $2, updated_at = NOW() WHERE id = ANY($1)`,
+			pq.Array(moved), LocationTruck); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock", "details": err.Error()})
+			return
+		}
+
+		if err := insertDispatchTrackingLog(ctx, tx, transferNo, StatusDispatched, LocationStockyard,
+			fmt.Sprintf("%d elements dispatched for transfer", len(items)), now); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		t, err := loadStockTransfer(db, transferID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock transfer", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, t)
+
+		notifyStockyardManagers(db, t.ProjectID, t.ToStockyardID,
+			fmt.Sprintf("Stock transfer %s of %d elements dispatched from %s to %s", t.TransferNo, t.ItemCount, t.FromYardName, t.ToYardName))
+		saveStockTransferActivity(db, session, userName, "Create",
+			fmt.Sprintf("Created stock transfer %s of %d elements from %s to %s", t.TransferNo, t.ItemCount, t.FromYardName, t.ToYardName), t.ProjectID)
+	}
+}
+
+// GetStockTransfersByProject lists the transfers of a project, optionally by status or stockyard.
+// @Summary List stock transfers
+// @Tags Stock Transfers
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param status query string false "Status"
+// @Param stockyard_id query int false "Source or destination stockyard"
+// @Success 200 {array} models.StockTransfer
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/stock_transfers [get]
+func GetStockTransfersByProject(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+		where := []string{"t.project_id = $1"}
+		args := []interface{}{projectID}
+		if status := c.Query("status"); status != "" {
+			args = append(args, status)
+			where = append(where, fmt.Sprintf("t.status = $%d", len(args)))
+		}
+		if s := c.Query("stockyard_id"); s != "" {
+			stockyardID, err := strconv.Atoi(s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "stockyard_id must be a valid integer"})
+				return
+			}
+			args = append(args, stockyardID)
+			where = append(where, fmt.Sprintf("(t.from_stockyard_id = $%d OR t.to_stockyard_id = $%d)", len(args), len(args)))
+		}
+
+		rows, err := db.Query(selectStockTransferSQL+" WHERE "+strings.Join(where, " AND ")+" ORDER BY t.dispatched_at DESC, t.id DESC", args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock transfers", "details": err.Error()})
+			return
+		}
+		defer rows.Close()
+		transfers := []models.StockTransfer{}
+		for rows.Next() {
+			var t models.StockTransfer
+			if err := scanStockTransfer(rows, &t); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read stock transfer", "details": err.Error()})
+				return
+			}
+			transfers = append(transfers, t)
+		}
+		c.JSON(http.StatusOK, transfers)
+	}
+}
+
+// GetStockTransfer returns a transfer with its items and tracking log.
+// @Summary Get stock transfer
+// @Tags Stock Transfers
+// @Produce json
+// @Param id path int true "Transfer ID"
+// @Success 200 {object} models.StockTransfer
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/stock_transfers/{id} [get]
+func GetStockTransfer(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+		t, ok := stockTransferParam(c, db)
+		if !ok {
+			return
+		}
+		c.JSON(http.StatusOK, t)
+	}
+}
+
+// MarkStockTransferInTransit records that the loaded truck has left the source stockyard.
+// @Summary Mark stock transfer in transit
+// @Tags Stock Transfers
+// @Produce json
+// @Param id path int true "Transfer ID"
+// @Success 200 {object} models.StockTransfer
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/stock_transfers/{id}/in_transit [post]
+func MarkStockTransferInTransit(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+		t, ok := stockTransferParam(c, db)
+		if !ok {
+			return
+		}
+		if !stockTransferActor(c, db, t.ProjectID, t.FromStockyardID) {
+			return
+		}
+
+		ctx := c.Request.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+		now := time.Now()
+		res, err := tx.Exec(`UPDATE stock_transfer SET status = $2, in_transit_at = $3 WHERE id = $1 AND status = $4`,
+			t.ID, StatusInTransit, now, StatusDispatched)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock transfer", "details": err.Error()})
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Stock transfer is %s, only a dispatched transfer can go in transit", t.Status)})
+			return
+		}
+		if err := insertDispatchTrackingLog(ctx, tx, t.TransferNo, StatusInTransit, LocationTruck,
+			fmt.Sprintf(DispatchLogRemarksInTransit, userName), now); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		t, err = loadStockTransfer(db, t.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock transfer", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, t)
+
+		saveStockTransferActivity(db, session, userName, "Update", fmt.Sprintf("Stock transfer %s in transit", t.TransferNo), t.ProjectID)
+	}
+}
+
+// ReceiveStockTransfer confirms receipt of all or some elements of a transfer at the destination.
+// Received elements move to the destination stockyard and are put away there.
+// @Summary Receive stock transfer
+// @Tags Stock Transfers
+// @Accept json
+// @Produce json
+// @Param id path int true "Transfer ID"
+// @Param request body models.ReceiveStockTransferRequest false "Elements received"
+// @Success 200 {object} object
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/stock_transfers/{id}/receive [post]
+func ReceiveStockTransfer(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+		var req models.ReceiveStockTransferRequest
+		if c.Request.ContentLength != 0 {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+				return
+			}
+		}
+		t, ok := stockTransferParam(c, db)
+		if !ok {
+			return
+		}
+		if !stockTransferActor(c, db, t.ProjectID, t.ToStockyardID) {
+			return
+		}
+
+		ctx := c.Request.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+		var status string
+		if err := tx.QueryRow(`SELECT status FROM stock_transfer WHERE id = $1 FOR UPDATE`, t.ID).Scan(&status); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to lock stock transfer", "details": err.Error()})
+			return
+		}
+		if status != StatusDispatched && status != StatusInTransit && status != StatusPartiallyReceived {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Stock transfer is %s", status)})
+			return
+		}
+
+		pending := make(map[int]int)
+		for _, item := range t.Items {
+			if item.Status == StatusInTransit {
+				pending[item.ElementID] = item.PrecastStockID
+			}
+		}
+		received := req.Items
+		if len(received) == 0 {
+			for _, item := range t.Items {
+				if item.Status == StatusInTransit {
+					received = append(received, item.ElementID)
+				}
+			}
+		}
+		var notPending, elementIDs []int
+		stockIDs := make([]int64, 0, len(received))
+		seen := make(map[int]bool)
+		for _, id := range received {
+			stockID, ok := pending[id]
+			if !ok {
+				notPending = append(notPending, id)
+				continue
+			}
+			if !seen[id] {
+				seen[id] = true
+				elementIDs = append(elementIDs, id)
+				stockIDs = append(stockIDs, int64(stockID))
+			}
+		}
+		if len(notPending) > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"error":    "Some elements are not awaiting receipt on this transfer",
+				"elements": notPending,
+			})
+			return
+		}
+		if len(stockIDs) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "No elements to receive"})
+			return
+		}
+
+		now := time.Now()
+		if _, err := tx.Exec(`
+			UPDATE precast_stock SET stockyard_id = $2, storage_location = '', updated_at = NOW()
+			WHERE id = ANY($1)`, pq.Array(stockIDs), t.ToStockyardID); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to move stock", "details": err.Error()})
+			return
+		}
+		if _, err := tx.Exec(`
+			UPDATE stock_transfer_item SET status = $3, closed_at = $4, closed_by = $5
+			WHERE transfer_id = $1 AND precast_stock_id = ANY($2)`,
+			t.ID, pq.Array(stockIDs), StatusReceived, now, userName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transfer items", "details": err.Error()})
+			return
+		}
+
+		putAway, code, err := putAwayStock(tx, t.ProjectID, elementIDs, req.Slots, req.ForceSlots, userName)
+		if err != nil {
+			c.JSON(code, gin.H{"error": err.Error()})
+			return
+		}
+
+		remaining := len(pending) - len(stockIDs)
+		newStatus := StatusReceived
+		if remaining > 0 {
+			newStatus = StatusPartiallyReceived
+		}
+		if _, err := tx.Exec(`
+			UPDATE stock_transfer SET status = $2, received_at = CASE WHEN $2 = 'Received' THEN $3::timestamptz END
+			WHERE id = $1`, t.ID, newStatus, now); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock transfer", "details": err.Error()})
+			return
+		}
+		remarks := fmt.Sprintf("%d of %d elements received by %s", len(stockIDs), len(pending), userName)
+		if req.Remarks != "" {
+			remarks += ": " + req.Remarks
+		}
+		if err := insertDispatchTrackingLog(ctx, tx, t.TransferNo, newStatus, t.ToYardName, remarks, now); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		t, err = loadStockTransfer(db, t.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock transfer", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"transfer": t, "put_away": putAway})
+
+		notifyStockyardManagers(db, t.ProjectID, t.FromStockyardID,
+			fmt.Sprintf("Stock transfer %s: %d elements received at %s", t.TransferNo, len(stockIDs), t.ToYardName))
+		saveStockTransferActivity(db, session, userName, "Receive",
+			fmt.Sprintf("Received %d elements of stock transfer %s at %s", len(stockIDs), t.TransferNo, t.ToYardName), t.ProjectID)
+	}
+}
+
+// CancelStockTransfer cancels a transfer nothing has been received from. The elements go back to the
+// source stockyard, with a slot suggested for each.
+// @Summary Cancel stock transfer
+// @Tags Stock Transfers
+// @Accept json
+// @Produce json
+// @Param id path int true "Transfer ID"
+// @Param request body models.StockTransferCancelRequest true "Reason"
+// @Success 200 {object} object
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/stock_transfers/{id}/cancel [post]
+func CancelStockTransfer(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+		var req models.StockTransferCancelRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		t, ok := stockTransferParam(c, db)
+		if !ok {
+			return
+		}
+		if !stockTransferActor(c, db, t.ProjectID, t.FromStockyardID) {
+			return
+		}
+
+		ctx := c.Request.Context()
+		tx, err := db.BeginTx(ctx, nil)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+		now := time.Now()
+		res, err := tx.Exec(`
+			UPDATE stock_transfer SET status = $2, cancelled_at = $3
+			WHERE id = $1 AND status IN ($4, $5)`, t.ID, StatusCancelled, now, StatusDispatched, StatusInTransit)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to cancel stock transfer", "details": err.Error()})
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Stock transfer is %s, only a transfer nothing has been received from can be cancelled", t.Status)})
+			return
+		}
+
+		elementIDs := make([]int, 0, len(t.Items))
+		stockIDs := make([]int64, 0, len(t.Items))
+		for _, item := range t.Items {
+			elementIDs = append(elementIDs, item.ElementID)
+			stockIDs = append(stockIDs, int64(item.PrecastStockID))
+		}
+		if _, err := tx.Exec(`
+			UPDATE stock_transfer_item SET status = $2, closed_at = $3, closed_by = $4
+			WHERE transfer_id = $1`, t.ID, StatusReturned, now, userName); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update transfer items", "details": err.Error()})
+			return
+		}
+		if _, err := tx.Exec(`UPDATE precast_stock SET storage_location = '', updated_at = NOW() WHERE id = ANY($1)`,
+			pq.Array(stockIDs)); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update stock", "details": err.Error()})
+			return
+		}
+		putAway, code, err := putAwayStock(tx, t.ProjectID, elementIDs, nil, false, userName)
+		if err != nil {
+			c.JSON(code, gin.H{"error": err.Error()})
+			return
+		}
+		if err := insertDispatchTrackingLog(ctx, tx, t.TransferNo, StatusCancelled, t.FromYardName, req.Reason, now); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		t, err = loadStockTransfer(db, t.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock transfer", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"transfer": t, "put_away": putAway})
+
+		notifyStockyardManagers(db, t.ProjectID, t.ToStockyardID, fmt.Sprintf("Stock transfer %s was cancelled: %s", t.TransferNo, req.Reason))
+		saveStockTransferActivity(db, session, userName, "Cancel", fmt.Sprintf("Cancelled stock transfer %s: %s", t.TransferNo, req.Reason), t.ProjectID)
+	}
+}
+
+// GetInTransitStock sums the elements in transit between the stockyards of a project.
+// @Summary Stock in transit by route
+// @Tags Stock Transfers
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Success 200 {array} models.InTransitRoute
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/stock_transfers/in_transit [get]
+func GetInTransitStock(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT t.from_stockyard_id, COALESCE(fy.yard_name, ''), t.to_stockyard_id, COALESCE(ty.yard_name, ''),
+				COUNT(DISTINCT t.id), COUNT(*)
+			FROM stock_transfer t
+			JOIN stock_transfer_item i ON i.transfer_id = t.id AND i.status = 'In Transit'
+			LEFT JOIN stockyard fy ON fy.id = t.from_stockyard_id
+			LEFT JOIN stockyard ty ON ty.id = t.to_stockyard_id
+			WHERE t.project_id = $1
+			GROUP BY t.from_stockyard_id, fy.yard_name, t.to_stockyard_id, ty.yard_name
+			ORDER BY fy.yard_name, ty.yard_name`, projectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stock in transit", "details": err.Error()})
+			return
+		}
+		defer rows.Close()
+		routes := []models.InTransitRoute{}
+		for rows.Next() {
+			var r models.InTransitRoute
+			if err := rows.Scan(&r.FromStockyardID, &r.FromYardName, &r.ToStockyardID, &r.ToYardName, &r.Transfers, &r.Elements); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read stock in transit", "details": err.Error()})
+				return
+			}
+			routes = append(routes, r)
+		}
+		c.JSON(http.StatusOK, routes)
+	}
+}
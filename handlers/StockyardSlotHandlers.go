@@ -0,0 +1,861 @@
+package handlers
+
+import (
+	"backend/models"
+	"database/sql"
+	"fmt"
+	"log"
+	"math"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lib/pq"
+)
+
+// SQL statements for the stockyard slot map
+const (
+	selectStockyardSlotSQL = `
+		SELECT id, stockyard_id, zone, row_label, bay, code, max_weight_kg, max_stack, length_mm, width_mm, active, created_at
+		FROM stockyard_slot`
+
+	// selectSlotPieceSQL lists the pieces still in the yard that are placed in a slot. A piece already
+	// requested by erection is needed now, whatever date was planned.
+	selectSlotPieceSQL = `
+		SELECT sp.slot_id, ps.id, ps.element_id, COALESCE(e.element_name, ''), COALESCE(ps.element_type, ''), ps.project_id,
+			COALESCE(ps.weight, 0), sp.tier,
+			CASE WHEN COALESCE(ps.order_by_erection, FALSE) THEN CURRENT_DATE ELSE sp.needed_by END,
+			sp.placed_at, sp.placed_by
+		FROM stock_slot_placement sp
+		JOIN precast_stock ps ON ps.id = sp.precast_stock_id
+		LEFT JOIN element e ON e.id = ps.element_id
+		WHERE sp.slot_id IS NOT NULL AND COALESCE(ps.dispatch_status, FALSE) = FALSE`
+)
+
+// slotStockPiece is a piece of precast stock being put away, with what the stacking rules need.
+type slotStockPiece struct {
+	PrecastStockID int
+	ElementID      int
+	ProjectID      int
+	StockyardID    int
+	WeightKg       float64
+	LengthMM       float64 // the two largest dimensions, as pieces are stored flat
+	WidthMM        float64
+	NeededBy       *time.Time
+}
+
+// loadStockyardSlots reads slots with the given filter, each with the pieces stacked in it.
+func loadStockyardSlots(q sqlQueryer, where string, args ...interface{}) ([]models.StockyardSlot, error) {
+	rows, err := q.Query(selectStockyardSlotSQL+" "+where, args...)
+	if err != nil {
+		return nil, err
+	}
+	slots := []models.StockyardSlot{}
+	index := make(map[int]int)
+	for rows.Next() {
+		var s models.StockyardSlot
+		if err := rows.Scan(&s.ID, &s.StockyardID, &s.Zone, &s.Row, &s.Bay, &s.Code, &s.MaxWeightKg, &s.MaxStack,
+			&s.LengthMM, &s.WidthMM, &s.Active, &s.CreatedAt); err != nil {
+			rows.Close()
+			return nil, err
+		}
+		index[s.ID] = len(slots)
+		slots = append(slots, s)
+	}
+	rows.Close()
+	if err := rows.Err(); err != nil || len(slots) == 0 {
+		return slots, err
+	}
+
+	ids := make([]int64, 0, len(slots))
+	for _, s := range slots {
+		ids = append(ids, int64(s.ID))
+	}
+	rows, err = q.Query(selectSlotPieceSQL+` AND sp.slot_id = ANY($1) ORDER BY sp.slot_id, sp.tier, sp.placed_at`, pq.Array(ids))
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+	for rows.Next() {
+		var slotID int
+		var p models.StockSlotPiece
+		var neededBy sql.NullTime
+		if err := rows.Scan(&slotID, &p.PrecastStockID, &p.ElementID, &p.ElementName, &p.ElementType, &p.ProjectID,
+			&p.WeightKg, &p.Tier, &neededBy, &p.PlacedAt, &p.PlacedBy); err != nil {
+			return nil, err
+		}
+		if neededBy.Valid {
+			p.NeededBy = &neededBy.Time
+		}
+		s := &slots[index[slotID]]
+		s.Pieces = append(s.Pieces, p)
+		s.StackCount++
+		s.UsedWeightKg += p.WeightKg
+	}
+	return slots, rows.Err()
+}
+
+// loadSlotStockPiece reads a piece of precast stock still in the yard for put-away.
+func loadSlotStockPiece(q sqlQueryer, projectID, elementID int) (slotStockPiece, error) {
+	var p slotStockPiece
+	var dims [3]float64
+	var neededBy sql.NullTime
+	err := q.QueryRow(`
+		SELECT ps.id, ps.element_id, ps.project_id, COALESCE(ps.stockyard_id, 0), COALESCE(ps.weight, 0),
+			COALESCE(et.length, 0), COALESCE(et.thickness, 0), COALESCE(et.height, 0),
+			CASE WHEN COALESCE(ps.order_by_erection, FALSE) THEN CURRENT_DATE ELSE sp.needed_by END
+		FROM precast_stock ps
+		LEFT JOIN element_type et ON et.element_type_id = ps.element_type_id
+		LEFT JOIN stock_slot_placement sp ON sp.precast_stock_id = ps.id
+		WHERE ps.project_id = $1 AND ps.element_id = $2 AND COALESCE(ps.dispatch_status, FALSE) = FALSE
+		ORDER BY ps.id DESC LIMIT 1`, projectID, elementID).Scan(
+		&p.PrecastStockID, &p.ElementID, &p.ProjectID, &p.StockyardID, &p.WeightKg, &dims[0], &dims[1], &dims[2], &neededBy)
+	if err != nil {
+		return p, err
+	}
+	sort.Sort(sort.Reverse(sort.Float64Slice(dims[:])))
+	p.LengthMM, p.WidthMM = dims[0], dims[1]
+	if neededBy.Valid {
+		p.NeededBy = &neededBy.Time
+	}
+	return p, nil
+}
+
+// neededBefore reports whether a is needed before b. Pieces without a date are needed last.
+func neededBefore(a, b *time.Time) bool {
+	if a == nil {
+		return false
+	}
+	return b == nil || a.Before(*b)
+}
+
+// checkSlot applies the capacity limits and stacking rules for putting a piece on top of a slot's
+// stack. Capacity problems can't be stacked around; rule breaks only make the piece awkward to pick.
+func checkSlot(slot models.StockyardSlot, piece slotStockPiece) (limits, rules []string) {
+	var stack []models.StockSlotPiece
+	used := 0.0
+	for _, p := range slot.Pieces {
+		if p.PrecastStockID != piece.PrecastStockID {
+			stack = append(stack, p)
+			used += p.WeightKg
+		}
+	}
+
+	if !slot.Active {
+		limits = append(limits, fmt.Sprintf("slot %s is out of use", slot.Code))
+	}
+	if len(stack) >= slot.MaxStack {
+		limits = append(limits, fmt.Sprintf("slot %s is stacked to its limit of %d", slot.Code, slot.MaxStack))
+	}
+	if slot.MaxWeightKg > 0 && used+piece.WeightKg > slot.MaxWeightKg {
+		limits = append(limits, fmt.Sprintf("slot %s would carry %.0f kg, over its %.0f kg limit", slot.Code, used+piece.WeightKg, slot.MaxWeightKg))
+	}
+	if slot.LengthMM > 0 && slot.WidthMM > 0 {
+		fits := (piece.LengthMM <= slot.LengthMM && piece.WidthMM <= slot.WidthMM) ||
+			(piece.LengthMM <= slot.WidthMM && piece.WidthMM <= slot.LengthMM)
+		if !fits {
+			limits = append(limits, fmt.Sprintf("a %.0fx%.0f mm piece doesn't fit the %.0fx%.0f mm footprint of slot %s",
+				piece.LengthMM, piece.WidthMM, slot.LengthMM, slot.WidthMM, slot.Code))
+		}
+	}
+
+	if len(stack) > 0 {
+		top := stack[len(stack)-1]
+		if top.WeightKg < piece.WeightKg {
+			rules = append(rules, fmt.Sprintf("element %d on top of slot %s is lighter than this piece", top.ElementID, slot.Code))
+		}
+		if neededBefore(top.NeededBy, piece.NeededBy) {
+			rules = append(rules, fmt.Sprintf("element %d on top of slot %s is needed before this piece", top.ElementID, slot.Code))
+		}
+	}
+	return limits, rules
+}
+
+// suggestSlots ranks the slots of the piece's stockyard it can be put away in. Slots where no rule is
+// broken come first: topping up a stack before opening a new bay, and on a stack preferring the one
+// whose top piece is needed closest after this one.
+func suggestSlots(q sqlQueryer, piece slotStockPiece, limit int) ([]models.SlotSuggestion, error) {
+	slots, err := loadStockyardSlots(q, `WHERE stockyard_id = $1 AND active ORDER BY zone, row_label, bay`, piece.StockyardID)
+	if err != nil {
+		return nil, err
+	}
+
+	type candidate struct {
+		suggestion models.SlotSuggestion
+		broken     int
+		stacked    bool
+		gapDays    float64
+		area       float64
+	}
+	var candidates []candidate
+	for _, slot := range slots {
+		limits, rules := checkSlot(slot, piece)
+		if len(limits) > 0 {
+			continue
+		}
+		cand := candidate{broken: len(rules), area: slot.LengthMM * slot.WidthMM}
+		stack := 0
+		used := 0.0
+		var top *models.StockSlotPiece
+		for i, p := range slot.Pieces {
+			if p.PrecastStockID != piece.PrecastStockID {
+				stack++
+				used += p.WeightKg
+				top = &slot.Pieces[i]
+			}
+		}
+		cand.suggestion = models.SlotSuggestion{SlotID: slot.ID, Code: slot.Code, Tier: stack + 1, StackCount: stack}
+		if slot.MaxWeightKg > 0 {
+			cand.suggestion.FreeWeight = math.Round(slot.MaxWeightKg - used - piece.WeightKg)
+		}
+		switch {
+		case len(rules) > 0:
+			cand.suggestion.Reason = "Breaks stacking rules: " + strings.Join(rules, "; ")
+		case top != nil:
+			cand.stacked = true
+			cand.gapDays = math.Inf(1)
+			if top.NeededBy != nil && piece.NeededBy != nil {
+				cand.gapDays = top.NeededBy.Sub(*piece.NeededBy).Hours() / 24
+			}
+			cand.suggestion.Reason = fmt.Sprintf("Stacks on element %d, which is heavier and not needed sooner", top.ElementID)
+		default:
+			cand.suggestion.Reason = "Empty bay"
+		}
+		candidates = append(candidates, cand)
+	}
+
+	sort.SliceStable(candidates, func(i, j int) bool {
+		a, b := candidates[i], candidates[j]
+		if a.broken != b.broken {
+			return a.broken < b.broken
+		}
+		if a.stacked != b.stacked {
+			return a.stacked
+		}
+		if a.stacked && a.gapDays != b.gapDays {
+			return a.gapDays < b.gapDays
+		}
+		return a.area < b.area
+	})
+	suggestions := []models.SlotSuggestion{}
+	for i := 0; i < len(candidates) && i < limit; i++ {
+		suggestions = append(suggestions, candidates[i].suggestion)
+	}
+	return suggestions, nil
+}
+
+// placeStockInSlot records the slot a piece was put in, on top of the stack, and shows it as the
+// piece's storage location. It returns the HTTP status to report with the error.
+func placeStockInSlot(tx *sql.Tx, piece slotStockPiece, slotID int, neededBy *time.Time, force bool, userName string) (models.PutAwayResult, int, error) {
+	result := models.PutAwayResult{ElementID: piece.ElementID}
+	// Lock the slot so two pieces don't take the same tier
+	if _, err := tx.Exec(`SELECT id FROM stockyard_slot WHERE id = $1 FOR UPDATE`, slotID); err != nil {
+		return result, http.StatusInternalServerError, err
+	}
+	slots, err := loadStockyardSlots(tx, `WHERE id = $1`, slotID)
+	if err != nil {
+		return result, http.StatusInternalServerError, err
+	}
+	if len(slots) == 0 {
+		return result, http.StatusNotFound, fmt.Errorf("slot %d not found", slotID)
+	}
+	slot := slots[0]
+	if piece.StockyardID != 0 && slot.StockyardID != piece.StockyardID {
+		return result, http.StatusBadRequest, fmt.Errorf("slot %s is not in the stockyard of element %d", slot.Code, piece.ElementID)
+	}
+
+	if neededBy != nil {
+		piece.NeededBy = neededBy
+	}
+	limits, rules := checkSlot(slot, piece)
+	if len(limits) > 0 && !force {
+		return result, http.StatusConflict, fmt.Errorf("element %d can't go in slot %s: %s", piece.ElementID, slot.Code, strings.Join(limits, "; "))
+	}
+
+	tier := 1
+	for _, p := range slot.Pieces {
+		if p.PrecastStockID != piece.PrecastStockID && p.Tier >= tier {
+			tier = p.Tier + 1
+		}
+	}
+	_, err = tx.Exec(`
+		INSERT INTO stock_slot_placement (precast_stock_id, slot_id, tier, needed_by, placed_at, placed_by)
+		VALUES ($1, $2, $3, $4, NOW(), $5)
+		ON CONFLICT (precast_stock_id) DO UPDATE SET slot_id = EXCLUDED.slot_id, tier = EXCLUDED.tier,
+			needed_by = COALESCE(EXCLUDED.needed_by, stock_slot_placement.needed_by),
+			placed_at = EXCLUDED.placed_at, placed_by = EXCLUDED.placed_by`,
+		piece.PrecastStockID, slot.ID, tier, neededBy, userName)
+	if err != nil {
+		return result, http.StatusInternalServerError, err
+	}
+	if _, err := tx.Exec(`UPDATE precast_stock SET storage_location = $2, updated_at = NOW() WHERE id = $1`, piece.PrecastStockID, slot.Code); err != nil {
+		return result, http.StatusInternalServerError, err
+	}
+
+	result.SlotID, result.Code, result.Tier = &slot.ID, slot.Code, tier
+	result.Warnings = append(limits, rules...)
+	return result, http.StatusOK, nil
+}
+
+// putAwayStock records the slots given for received elements and suggests one for the rest. The
+// suggestion is kept so the map shows where the piece is expected until its slot is recorded.
+func putAwayStock(tx *sql.Tx, projectID int, elementIDs []int, slots []models.StockSlotInput, force bool, userName string) ([]models.PutAwayResult, int, error) {
+	given := make(map[int]models.StockSlotInput)
+	for _, s := range slots {
+		given[s.ElementID] = s
+	}
+
+	results := []models.PutAwayResult{}
+	for _, elementID := range elementIDs {
+		piece, err := loadSlotStockPiece(tx, projectID, elementID)
+		if err == sql.ErrNoRows {
+			continue
+		} else if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+
+		input, ok := given[elementID]
+		var neededBy *time.Time
+		if ok && input.NeededBy != "" {
+			d, err := time.Parse("2006-01-02", input.NeededBy)
+			if err != nil {
+				return nil, http.StatusBadRequest, fmt.Errorf("needed_by of element %d must be in YYYY-MM-DD format", elementID)
+			}
+			neededBy = &d
+		}
+		if ok && input.SlotID > 0 {
+			result, status, err := placeStockInSlot(tx, piece, input.SlotID, neededBy, force, userName)
+			if err != nil {
+				return nil, status, err
+			}
+			results = append(results, result)
+			continue
+		}
+
+		if neededBy != nil {
+			piece.NeededBy = neededBy
+		}
+		suggestions, err := suggestSlots(tx, piece, 3)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		var suggested *int
+		if len(suggestions) > 0 {
+			suggested = &suggestions[0].SlotID
+		}
+		_, err = tx.Exec(`
+			INSERT INTO stock_slot_placement (precast_stock_id, needed_by, suggested_slot_id, placed_by)
+			VALUES ($1, $2, $3, $4)
+			ON CONFLICT (precast_stock_id) DO UPDATE SET suggested_slot_id = EXCLUDED.suggested_slot_id,
+				needed_by = COALESCE(EXCLUDED.needed_by, stock_slot_placement.needed_by)`,
+			piece.PrecastStockID, neededBy, suggested, userName)
+		if err != nil {
+			return nil, http.StatusInternalServerError, err
+		}
+		results = append(results, models.PutAwayResult{ElementID: elementID, Suggestions: suggestions})
+	}
+	return results, http.StatusOK, nil
+}
+
+// saveStockyardSlotActivity writes the activity log of a slot map change.
+func saveStockyardSlotActivity(db *sql.DB, session models.Session, userName, eventName, description string, projectID int) {
+	activityLog := models.ActivityLog{
+		EventContext: "Stockyard",
+		EventName:    eventName,
+		Description:  description,
+		UserName:     userName,
+		HostName:     session.HostName,
+		IPAddress:    session.IPAddress,
+		CreatedAt:    time.Now(),
+		ProjectID:    projectID,
+	}
+	if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+		log.Printf("Failed to save activity log: %v", logErr)
+	}
+}
+
+// CreateStockyardSlots lays out the rows and bays of a stockyard zone.
+// @Summary Create stockyard slots
+// @Tags Stockyard Slots
+// @Accept json
+// @Produce json
+// @Param id path int true "Stockyard ID"
+// @Param request body models.CreateStockyardSlotsRequest true "Zone layout"
+// @Success 201 {array} models.StockyardSlot
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/stockyards/{id}/slots [post]
+func CreateStockyardSlots(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		stockyardID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a valid integer"})
+			return
+		}
+		var req models.CreateStockyardSlotsRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		req.Zone = strings.TrimSpace(req.Zone)
+		if req.Zone == "" || req.Bays <= 0 || req.MaxStack < 0 || req.MaxWeightKg < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "zone and a positive number of bays are required, and limits can't be negative"})
+			return
+		}
+		if req.FirstBay <= 0 {
+			req.FirstBay = 1
+		}
+		if req.MaxStack == 0 {
+			req.MaxStack = 1
+		}
+		var yardName string
+		if err := db.QueryRow(`SELECT yard_name FROM stockyard WHERE id = $1`, stockyardID).Scan(&yardName); err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Stockyard not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch stockyard", "details": err.Error()})
+			return
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+		var ids []int64
+		for _, row := range req.Rows {
+			row = strings.TrimSpace(row)
+			if row == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "rows can't be blank"})
+				return
+			}
+			for bay := req.FirstBay; bay < req.FirstBay+req.Bays; bay++ {
+				var id int64
+				err := tx.QueryRow(`
+					INSERT INTO stockyard_slot (stockyard_id, zone, row_label, bay, code, max_weight_kg, max_stack, length_mm, width_mm)
+					VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9) RETURNING id`,
+					stockyardID, req.Zone, row, bay, fmt.Sprintf("%s-%s-%02d", req.Zone, row, bay),
+					req.MaxWeightKg, req.MaxStack, req.LengthMM, req.WidthMM).Scan(&id)
+				if err != nil {
+					if pqErr, ok := err.(*pq.Error); ok && pqErr.Code == "23505" {
+						c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Slot %s-%s-%02d already exists", req.Zone, row, bay)})
+						return
+					}
+					c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create slot", "details": err.Error()})
+					return
+				}
+				ids = append(ids, id)
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		slots, err := loadStockyardSlots(db, `WHERE id = ANY($1) ORDER BY zone, row_label, bay`, pq.Array(ids))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load slots", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, slots)
+
+		saveStockyardSlotActivity(db, session, userName, "Create",
+			fmt.Sprintf("Laid out %d slots in zone %s of stockyard %s", len(ids), req.Zone, yardName), 0)
+	}
+}
+
+// GetStockyardSlots returns the slot map of a stockyard with the pieces stacked in each slot.
+// @Summary Stockyard slot map
+// @Tags Stockyard Slots
+// @Produce json
+// @Param id path int true "Stockyard ID"
+// @Param zone query string false "Filter by zone"
+// @Param project_id query int false "Only show pieces of this project"
+// @Success 200 {array} models.StockyardSlot
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/stockyards/{id}/slots [get]
+func GetStockyardSlots(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		stockyardID, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a valid integer"})
+			return
+		}
+		where := `WHERE stockyard_id = $1`
+		args := []interface{}{stockyardID}
+		if zone := c.Query("zone"); zone != "" {
+			args = append(args, zone)
+			where += fmt.Sprintf(` AND zone = $%d`, len(args))
+		}
+		projectID := 0
+		if s := c.Query("project_id"); s != "" {
+			if projectID, err = strconv.Atoi(s); err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+				return
+			}
+		}
+
+		slots, err := loadStockyardSlots(db, where+` ORDER BY zone, row_label, bay`, args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch slots", "details": err.Error()})
+			return
+		}
+		// Stacks are shared between projects; capacity stays as a whole, only the pieces are filtered
+		if projectID > 0 {
+			for i := range slots {
+				var pieces []models.StockSlotPiece
+				for _, p := range slots[i].Pieces {
+					if p.ProjectID == projectID {
+						pieces = append(pieces, p)
+					}
+				}
+				slots[i].Pieces = pieces
+			}
+		}
+		c.JSON(http.StatusOK, slots)
+	}
+}
+
+// UpdateStockyardSlot changes the capacity of a slot or takes it out of use.
+// @Summary Update stockyard slot
+// @Tags Stockyard Slots
+// @Accept json
+// @Produce json
+// @Param id path int true "Slot ID"
+// @Param request body models.UpdateStockyardSlotRequest true "Slot"
+// @Success 200 {object} models.StockyardSlot
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/stockyard_slots/{id} [put]
+func UpdateStockyardSlot(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a valid integer"})
+			return
+		}
+		var req models.UpdateStockyardSlotRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		if req.MaxStack <= 0 || req.MaxWeightKg < 0 || req.LengthMM < 0 || req.WidthMM < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "max_stack must be positive and limits can't be negative"})
+			return
+		}
+
+		res, err := db.Exec(`
+			UPDATE stockyard_slot SET max_weight_kg = $2, max_stack = $3, length_mm = $4, width_mm = $5, active = $6
+			WHERE id = $1`, id, req.MaxWeightKg, req.MaxStack, req.LengthMM, req.WidthMM, req.Active)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to update slot", "details": err.Error()})
+			return
+		}
+		if n, _ := res.RowsAffected(); n == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Slot not found"})
+			return
+		}
+		slots, err := loadStockyardSlots(db, `WHERE id = $1`, id)
+		if err != nil || len(slots) == 0 {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load slot"})
+			return
+		}
+		c.JSON(http.StatusOK, slots[0])
+
+		saveStockyardSlotActivity(db, session, userName, "Update", fmt.Sprintf("Updated stockyard slot %s", slots[0].Code), 0)
+	}
+}
+
+// DeleteStockyardSlot removes an empty slot from the map.
+// @Summary Delete stockyard slot
+// @Tags Stockyard Slots
+// @Produce json
+// @Param id path int true "Slot ID"
+// @Success 200 {object} models.MessageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/stockyard_slots/{id} [delete]
+func DeleteStockyardSlot(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "id must be a valid integer"})
+			return
+		}
+		slots, err := loadStockyardSlots(db, `WHERE id = $1`, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch slot", "details": err.Error()})
+			return
+		}
+		if len(slots) == 0 {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Slot not found"})
+			return
+		}
+		if slots[0].StackCount > 0 {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("Slot %s still holds %d pieces; take it out of use instead", slots[0].Code, slots[0].StackCount)})
+			return
+		}
+		if _, err := db.Exec(`DELETE FROM stockyard_slot WHERE id = $1`, id); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete slot", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, gin.H{"message": "Slot deleted"})
+
+		saveStockyardSlotActivity(db, session, userName, "Delete", fmt.Sprintf("Deleted stockyard slot %s", slots[0].Code), 0)
+	}
+}
+
+// SuggestStockyardSlot suggests where to put away a piece in its stockyard.
+// @Summary Suggest put-away slot
+// @Tags Stockyard Slots
+// @Produce json
+// @Param project_id query int true "Project ID"
+// @Param element_id query int true "Element ID"
+// @Param needed_by query string false "Planned dispatch date (YYYY-MM-DD)"
+// @Success 200 {array} models.SlotSuggestion
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/stockyard_slots/suggest [get]
+func SuggestStockyardSlot(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		projectID, err1 := strconv.Atoi(c.Query("project_id"))
+		elementID, err2 := strconv.Atoi(c.Query("element_id"))
+		if err1 != nil || err2 != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id and element_id must be valid integers"})
+			return
+		}
+		piece, err := loadSlotStockPiece(db, projectID, elementID)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Element is not in a stockyard"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch precast stock", "details": err.Error()})
+			return
+		}
+		if s := c.Query("needed_by"); s != "" {
+			d, err := time.Parse("2006-01-02", s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "needed_by must be in YYYY-MM-DD format"})
+				return
+			}
+			piece.NeededBy = &d
+		}
+
+		suggestions, err := suggestSlots(db, piece, 5)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to suggest slots", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, suggestions)
+	}
+}
+
+// PutAwayStock records the slots pieces in the stockyard were actually put in.
+// @Summary Record put-away slots
+// @Tags Stockyard Slots
+// @Accept json
+// @Produce json
+// @Param request body models.PutAwayRequest true "Slots"
+// @Success 200 {array} models.PutAwayResult
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/stockyard_slots/put_away [post]
+func PutAwayStock(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var req models.PutAwayRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		elementIDs := make([]int, 0, len(req.Slots))
+		for _, s := range req.Slots {
+			elementIDs = append(elementIDs, s.ElementID)
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+		results, status, err := putAwayStock(tx, req.ProjectID, elementIDs, req.Slots, req.Force, userName)
+		if err != nil {
+			c.JSON(status, gin.H{"error": err.Error()})
+			return
+		}
+		if len(results) != len(elementIDs) {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "All elements must be in a stockyard of the project"})
+			return
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, results)
+
+		saveStockyardSlotActivity(db, session, userName, "Put away", fmt.Sprintf("Recorded the slots of %d pieces", len(results)), req.ProjectID)
+	}
+}
+
+// GetStockPickList orders the pieces of a dispatch by slot so the yard crew walks the yard once,
+// taking the top of each stack first, and lists the pieces to move aside to reach buried ones.
+// @Summary Dispatch pick list
+// @Tags Stockyard Slots
+// @Accept json
+// @Produce json
+// @Param request body models.PickListRequest true "Elements"
+// @Success 200 {array} models.PickListItem
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/stockyard_slots/pick_list [post]
+func GetStockPickList(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		var req models.PickListRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		ids := make([]int64, len(req.ElementIDs))
+		picked := make(map[int]bool)
+		for i, id := range req.ElementIDs {
+			ids[i] = int64(id)
+			picked[id] = true
+		}
+
+		rows, err := db.Query(`
+			SELECT ps.id, ps.element_id, COALESCE(e.element_name, ''), COALESCE(ps.stockyard_id, 0),
+				COALESCE(ps.storage_location, ''), sp.slot_id, COALESCE(s.code, ''), COALESCE(s.zone, ''),
+				COALESCE(s.row_label, ''), COALESCE(s.bay, 0), COALESCE(sp.tier, 0)
+			FROM precast_stock ps
+			LEFT JOIN element e ON e.id = ps.element_id
+			LEFT JOIN stock_slot_placement sp ON sp.precast_stock_id = ps.id
+			LEFT JOIN stockyard_slot s ON s.id = sp.slot_id
+			WHERE ps.project_id = $1 AND ps.element_id = ANY($2) AND COALESCE(ps.dispatch_status, FALSE) = FALSE`,
+			req.ProjectID, pq.Array(ids))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch precast stock", "details": err.Error()})
+			return
+		}
+		type pick struct {
+			item           models.PickListItem
+			slotID         int
+			zone, rowLabel string
+			bay            int
+		}
+		var picks []pick
+		var slotIDs []int64
+		for rows.Next() {
+			var p pick
+			var slotID sql.NullInt64
+			if err := rows.Scan(&p.item.PrecastStockID, &p.item.ElementID, &p.item.ElementName, &p.item.StockyardID,
+				&p.item.Location, &slotID, &p.item.SlotCode, &p.zone, &p.rowLabel, &p.bay, &p.item.Tier); err != nil {
+				rows.Close()
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to read precast stock", "details": err.Error()})
+				return
+			}
+			if slotID.Valid {
+				p.slotID = int(slotID.Int64)
+				p.item.Location = ""
+				slotIDs = append(slotIDs, slotID.Int64)
+			} else {
+				p.item.Tier = 0
+			}
+			picks = append(picks, p)
+		}
+		rows.Close()
+
+		// Pieces above a picked one that aren't being picked have to be moved aside
+		slots, err := loadStockyardSlots(db, `WHERE id = ANY($1)`, pq.Array(slotIDs))
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch slots", "details": err.Error()})
+			return
+		}
+		stacks := make(map[int][]models.StockSlotPiece)
+		for _, s := range slots {
+			stacks[s.ID] = s.Pieces
+		}
+		for i := range picks {
+			if picks[i].slotID == 0 {
+				continue
+			}
+			for _, above := range stacks[picks[i].slotID] {
+				if above.Tier > picks[i].item.Tier && !picked[above.ElementID] {
+					picks[i].item.Rehandles = append(picks[i].item.Rehandles, above.ElementID)
+				}
+			}
+		}
+
+		sort.SliceStable(picks, func(i, j int) bool {
+			a, b := picks[i], picks[j]
+			if (a.slotID == 0) != (b.slotID == 0) {
+				return b.slotID == 0
+			}
+			if a.item.StockyardID != b.item.StockyardID {
+				return a.item.StockyardID < b.item.StockyardID
+			}
+			if a.zone != b.zone {
+				return a.zone < b.zone
+			}
+			if a.rowLabel != b.rowLabel {
+				return a.rowLabel < b.rowLabel
+			}
+			if a.bay != b.bay {
+				return a.bay < b.bay
+			}
+			if a.item.Tier != b.item.Tier {
+				return a.item.Tier > b.item.Tier
+			}
+			return a.item.ElementID < b.item.ElementID
+		})
+		list := make([]models.PickListItem, len(picks))
+		for i, p := range picks {
+			p.item.Sequence = i + 1
+			list[i] = p.item
+		}
+		c.JSON(http.StatusOK, list)
+	}
+}
@@ -0,0 +1,708 @@
+package handlers
+
+import (
+	"backend/models"
+	"backend/services"
+	"bytes"
+	"database/sql"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/jung-kurt/gofpdf"
+)
+
+// SQL statements for the drawing transmittal register
+const (
+	// selectTransmittalSQL reads a transmittal header; overdue is computed against today
+	selectTransmittalSQL = `
+		SELECT t.id, t.project_id, COALESCE(p.name, ''), t.transmittal_no, t.recipient_type, t.endclient_id,
+			t.recipient_name, t.recipient_email, t.purpose, t.remarks, t.due_date, t.reply_status, t.reply_comments,
+			t.replied_at, t.reply_recorded_by, t.issued_by, t.issued_at, t.last_reminder_at, t.reminder_count,
+			(t.reply_status = 'pending' AND t.due_date IS NOT NULL AND t.due_date < CURRENT_DATE)
+		FROM transmittal t
+		LEFT JOIN project p ON p.project_id = t.project_id`
+)
+
+// scanTransmittal scans a row produced by selectTransmittalSQL.
+func scanTransmittal(row interface{ Scan(...any) error }, t *models.Transmittal) error {
+	var endClientID sql.NullInt64
+	var dueDate, repliedAt, lastReminderAt sql.NullTime
+	err := row.Scan(&t.ID, &t.ProjectID, &t.ProjectName, &t.TransmittalNo, &t.RecipientType, &endClientID,
+		&t.RecipientName, &t.RecipientEmail, &t.Purpose, &t.Remarks, &dueDate, &t.ReplyStatus, &t.ReplyComments,
+		&repliedAt, &t.RecordedBy, &t.IssuedBy, &t.IssuedAt, &lastReminderAt, &t.ReminderCount, &t.Overdue)
+	if err != nil {
+		return err
+	}
+	if endClientID.Valid {
+		id := int(endClientID.Int64)
+		t.EndClientID = &id
+	}
+	if dueDate.Valid {
+		t.DueDate = &dueDate.Time
+	}
+	if repliedAt.Valid {
+		t.RepliedAt = &repliedAt.Time
+	}
+	if lastReminderAt.Valid {
+		t.LastReminderAt = &lastReminderAt.Time
+	}
+	return nil
+}
+
+// loadTransmittal reads a transmittal with its drawing list.
+func loadTransmittal(db *sql.DB, id int) (models.Transmittal, error) {
+	var t models.Transmittal
+	if err := scanTransmittal(db.QueryRow(selectTransmittalSQL+` WHERE t.id = $1`, id), &t); err != nil {
+		return t, err
+	}
+	rows, err := db.Query(`
+		SELECT id, transmittal_id, drawing_id, version, drawing_type_name, element_type_id, element_type, file
+		FROM transmittal_item WHERE transmittal_id = $1 ORDER BY id`, id)
+	if err != nil {
+		return t, err
+	}
+	defer rows.Close()
+	t.Items = []models.TransmittalItem{}
+	for rows.Next() {
+		var item models.TransmittalItem
+		if err := rows.Scan(&item.ID, &item.TransmittalID, &item.DrawingID, &item.Version, &item.DrawingTypeName,
+			&item.ElementTypeID, &item.ElementType, &item.File); err != nil {
+			return t, err
+		}
+		t.Items = append(t.Items, item)
+	}
+	return t, rows.Err()
+}
+
+// resolveTransmittalItem snapshots the requested drawing revision. An empty version selects
+// the current one; older versions are read from drawings_revision.
+func resolveTransmittalItem(db *sql.DB, projectID int, req models.TransmittalItemRequest) (models.TransmittalItem, error) {
+	item := models.TransmittalItem{DrawingID: req.DrawingID}
+	var drawingProjectID int
+	var currentVersion, file sql.NullString
+	var elementTypeID sql.NullInt64
+	err := db.QueryRow(`
+		SELECT d.project_id, d.current_version, d.file, d.element_type_id, COALESCE(dt.drawing_type_name, ''), COALESCE(et.element_type_name, '')
+		FROM drawings d
+		LEFT JOIN drawing_type dt ON dt.drawing_type_id = d.drawing_type_id
+		LEFT JOIN element_type et ON et.element_type_id = d.element_type_id
+		WHERE d.drawing_id = $1`, req.DrawingID).
+		Scan(&drawingProjectID, &currentVersion, &file, &elementTypeID, &item.DrawingTypeName, &item.ElementType)
+	if err == sql.ErrNoRows {
+		return item, fmt.Errorf("drawing %d not found", req.DrawingID)
+	} else if err != nil {
+		return item, err
+	}
+	if drawingProjectID != projectID {
+		return item, fmt.Errorf("drawing %d does not belong to project %d", req.DrawingID, projectID)
+	}
+	item.ElementTypeID = int(elementTypeID.Int64)
+
+	version := strings.TrimSpace(req.Version)
+	if version == "" || version == currentVersion.String {
+		item.Version, item.File = currentVersion.String, file.String
+		return item, nil
+	}
+	err = db.QueryRow(`
+		SELECT COALESCE(file, '') FROM drawings_revision
+		WHERE parent_drawing_id = $1 AND version = $2
+		ORDER BY created_at DESC LIMIT 1`, req.DrawingID, version).Scan(&item.File)
+	if err == sql.ErrNoRows {
+		return item, fmt.Errorf("drawing %d has no revision %s", req.DrawingID, version)
+	} else if err != nil {
+		return item, err
+	}
+	item.Version = version
+	return item, nil
+}
+
+// nextTransmittalNo numbers transmittals per project: TR-0001, TR-0002, ...
+func nextTransmittalNo(tx *sql.Tx, projectID int) (string, error) {
+	// Serialise numbering per project
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock(hashtext('transmittal'), $1)`, projectID); err != nil {
+		return "", err
+	}
+	var last int
+	err := tx.QueryRow(`
+		SELECT COALESCE(MAX(NULLIF(regexp_replace(transmittal_no, '\D', '', 'g'), '')::INT), 0)
+		FROM transmittal WHERE project_id = $1`, projectID).Scan(&last)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("TR-%04d", last+1), nil
+}
+
+// CreateTransmittal records drawing revisions sent to an end client or site team.
+// @Summary Create drawing transmittal
+// @Description Lists drawing revisions sent to an end client or site team. Drawings sent for construction must be approved when the revision is under review.
+// @Tags Transmittals
+// @Accept json
+// @Produce json
+// @Param request body models.CreateTransmittalRequest true "Transmittal"
+// @Success 201 {object} models.Transmittal
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/transmittals [post]
+func CreateTransmittal(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		var req models.CreateTransmittalRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		if _, ok := models.TransmittalPurposes[req.Purpose]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "purpose must be one of for_approval, for_construction, for_information"})
+			return
+		}
+		if len(req.Items) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "At least one drawing is required"})
+			return
+		}
+
+		var endClientID *int
+		switch req.RecipientType {
+		case models.TransmittalRecipientEndClient:
+			if req.EndClientID == 0 {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "endclient_id is required for end client transmittals"})
+				return
+			}
+			var email, contact, organization sql.NullString
+			err := db.QueryRow(`SELECT email, contact_person, organization_name FROM end_client WHERE id = $1`, req.EndClientID).
+				Scan(&email, &contact, &organization)
+			if err == sql.ErrNoRows {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "End client not found"})
+				return
+			} else if err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch end client", "details": err.Error()})
+				return
+			}
+			if req.RecipientName == "" {
+				req.RecipientName = organization.String
+				if contact.String != "" {
+					req.RecipientName = strings.TrimSpace(contact.String + ", " + organization.String)
+				}
+			}
+			if req.RecipientEmail == "" {
+				req.RecipientEmail = email.String
+			}
+			endClientID = &req.EndClientID
+		case models.TransmittalRecipientSite:
+			if strings.TrimSpace(req.RecipientName) == "" {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "recipient_name is required for site transmittals"})
+				return
+			}
+		default:
+			c.JSON(http.StatusBadRequest, gin.H{"error": "recipient_type must be end_client or site"})
+			return
+		}
+
+		var dueDate *time.Time
+		replyStatus := models.TransmittalReplyNotRequired
+		if req.DueDate != "" {
+			d, err := time.Parse("2006-01-02", req.DueDate)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "due_date must be in YYYY-MM-DD format"})
+				return
+			}
+			dueDate = &d
+			replyStatus = models.TransmittalReplyPending
+		} else if req.Purpose == models.TransmittalPurposeApproval {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "due_date is required for drawings sent for approval"})
+			return
+		}
+
+		seen := make(map[string]bool)
+		items := make([]models.TransmittalItem, 0, len(req.Items))
+		for _, itemReq := range req.Items {
+			item, err := resolveTransmittalItem(db, req.ProjectID, itemReq)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+				return
+			}
+			key := fmt.Sprintf("%d/%s", item.DrawingID, item.Version)
+			if seen[key] {
+				continue
+			}
+			seen[key] = true
+
+			// Construction issues follow the drawing review workflow
+			if req.Purpose == models.TransmittalPurposeConstruction {
+				var status string
+				err := db.QueryRow(`SELECT status FROM drawing_revision_review WHERE drawing_id = $1 AND version = $2`,
+					item.DrawingID, item.Version).Scan(&status)
+				if err == nil && status != models.DrawingRevisionApproved && status != models.DrawingRevisionIssued {
+					c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%s %s of %s is %s and cannot be sent for construction",
+						item.DrawingTypeName, item.Version, item.ElementType, status)})
+					return
+				}
+			}
+			items = append(items, item)
+		}
+
+		tx, err := db.Begin()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to start transaction"})
+			return
+		}
+		defer tx.Rollback()
+
+		transmittalNo, err := nextTransmittalNo(tx, req.ProjectID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to number transmittal", "details": err.Error()})
+			return
+		}
+		var transmittalID int
+		err = tx.QueryRow(`
+			INSERT INTO transmittal (project_id, transmittal_no, recipient_type, endclient_id, recipient_name, recipient_email,
+				purpose, remarks, due_date, reply_status, issued_by, issued_by_user_id)
+			VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9, $10, $11, $12)
+			RETURNING id`,
+			req.ProjectID, transmittalNo, req.RecipientType, endClientID, strings.TrimSpace(req.RecipientName),
+			strings.TrimSpace(req.RecipientEmail), req.Purpose, req.Remarks, dueDate, replyStatus, userName, session.UserID).
+			Scan(&transmittalID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to create transmittal", "details": err.Error()})
+			return
+		}
+		for _, item := range items {
+			if _, err := tx.Exec(`
+				INSERT INTO transmittal_item (transmittal_id, drawing_id, version, drawing_type_name, element_type_id, element_type, file)
+				VALUES ($1, $2, $3, $4, $5, $6, $7)`,
+				transmittalID, item.DrawingID, item.Version, item.DrawingTypeName, item.ElementTypeID, item.ElementType, item.File); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to add drawing to transmittal", "details": err.Error()})
+				return
+			}
+		}
+		if err := tx.Commit(); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to commit transaction", "details": err.Error()})
+			return
+		}
+
+		transmittal, err := loadTransmittal(db, transmittalID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load transmittal", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusCreated, transmittal)
+
+		activityLog := models.ActivityLog{
+			EventContext: "Transmittal",
+			EventName:    "Create",
+			Description:  fmt.Sprintf("Issued transmittal %s (%d drawings) to %s", transmittalNo, len(items), transmittal.RecipientName),
+			UserName:     userName,
+			HostName:     session.HostName,
+			IPAddress:    session.IPAddress,
+			CreatedAt:    time.Now(),
+			ProjectID:    req.ProjectID,
+		}
+		if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+			log.Printf("Failed to save activity log: %v", logErr)
+		}
+	}
+}
+
+// GetTransmittalsByProject lists the transmittal register of a project.
+// @Summary List transmittals
+// @Tags Transmittals
+// @Produce json
+// @Param project_id path int true "Project ID"
+// @Param reply_status query string false "Filter by reply status"
+// @Param overdue query bool false "Only transmittals whose reply is overdue"
+// @Param drawing_id query int false "Only transmittals that include this drawing"
+// @Success 200 {array} models.Transmittal
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Router /api/projects/{project_id}/transmittals [get]
+func GetTransmittalsByProject(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		projectID, err := strconv.Atoi(c.Param("project_id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "project_id must be a valid integer"})
+			return
+		}
+
+		query := selectTransmittalSQL + ` WHERE t.project_id = $1`
+		args := []interface{}{projectID}
+		if status := c.Query("reply_status"); status != "" {
+			args = append(args, status)
+			query += fmt.Sprintf(` AND t.reply_status = $%d`, len(args))
+		}
+		if c.Query("overdue") == "true" {
+			query += ` AND t.reply_status = 'pending' AND t.due_date < CURRENT_DATE`
+		}
+		if s := c.Query("drawing_id"); s != "" {
+			drawingID, err := strconv.Atoi(s)
+			if err != nil {
+				c.JSON(http.StatusBadRequest, gin.H{"error": "drawing_id must be a valid integer"})
+				return
+			}
+			args = append(args, drawingID)
+			query += fmt.Sprintf(` AND EXISTS (SELECT 1 FROM transmittal_item ti WHERE ti.transmittal_id = t.id AND ti.drawing_id = $%d)`, len(args))
+		}
+		query += ` ORDER BY t.issued_at DESC`
+
+		rows, err := db.Query(query, args...)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transmittals", "details": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		transmittals := []models.Transmittal{}
+		for rows.Next() {
+			var t models.Transmittal
+			if err := scanTransmittal(rows, &t); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan transmittal", "details": err.Error()})
+				return
+			}
+			transmittals = append(transmittals, t)
+		}
+
+		c.JSON(http.StatusOK, transmittals)
+	}
+}
+
+// GetTransmittal returns a transmittal with its drawing list.
+// @Summary Get transmittal
+// @Tags Transmittals
+// @Produce json
+// @Param id path int true "Transmittal ID"
+// @Success 200 {object} models.Transmittal
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/transmittals/{id} [get]
+func GetTransmittal(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transmittal id"})
+			return
+		}
+		transmittal, err := loadTransmittal(db, id)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transmittal not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transmittal", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, transmittal)
+	}
+}
+
+// RecordTransmittalReply records the recipient's reply to a transmittal.
+// @Summary Record transmittal reply
+// @Tags Transmittals
+// @Accept json
+// @Produce json
+// @Param id path int true "Transmittal ID"
+// @Param request body models.TransmittalReplyRequest true "Reply"
+// @Success 200 {object} models.Transmittal
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Failure 409 {object} models.ErrorResponse
+// @Router /api/transmittals/{id}/reply [put]
+func RecordTransmittalReply(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := sessionContext(c, db)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transmittal id"})
+			return
+		}
+		var req models.TransmittalReplyRequest
+		if err := c.ShouldBindJSON(&req); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid request data", "details": err.Error()})
+			return
+		}
+		if _, ok := models.TransmittalReplyStatuses[req.ReplyStatus]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid reply_status"})
+			return
+		}
+
+		result, err := db.Exec(`
+			UPDATE transmittal SET reply_status = $2, reply_comments = $3, replied_at = NOW(), reply_recorded_by = $4
+			WHERE id = $1 AND reply_status IN ('pending', 'not_required')`,
+			id, req.ReplyStatus, req.Comments, userName)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to record reply", "details": err.Error()})
+			return
+		}
+		if n, _ := result.RowsAffected(); n == 0 {
+			var status string
+			if err := db.QueryRow(`SELECT reply_status FROM transmittal WHERE id = $1`, id).Scan(&status); err == sql.ErrNoRows {
+				c.JSON(http.StatusNotFound, gin.H{"error": "Transmittal not found"})
+				return
+			}
+			c.JSON(http.StatusConflict, gin.H{"error": "A reply has already been recorded (" + status + ")"})
+			return
+		}
+
+		transmittal, err := loadTransmittal(db, id)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to load transmittal", "details": err.Error()})
+			return
+		}
+		c.JSON(http.StatusOK, transmittal)
+
+		activityLog := models.ActivityLog{
+			EventContext: "Transmittal",
+			EventName:    "Reply",
+			Description:  fmt.Sprintf("Recorded reply %s on transmittal %s", req.ReplyStatus, transmittal.TransmittalNo),
+			UserName:     userName,
+			HostName:     session.HostName,
+			IPAddress:    session.IPAddress,
+			CreatedAt:    time.Now(),
+			ProjectID:    transmittal.ProjectID,
+		}
+		if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+			log.Printf("Failed to save activity log: %v", logErr)
+		}
+	}
+}
+
+// GetTransmittalPDF renders the transmittal document.
+// @Summary Download transmittal PDF
+// @Tags Transmittals
+// @Produce application/pdf
+// @Param id path int true "Transmittal ID"
+// @Success 200 {file} file
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/transmittals/{id}/pdf [get]
+func GetTransmittalPDF(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := sessionContext(c, db); !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid transmittal id"})
+			return
+		}
+		transmittal, err := loadTransmittal(db, id)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Transmittal not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch transmittal", "details": err.Error()})
+			return
+		}
+
+		var buf bytes.Buffer
+		if err := writeTransmittalPDF(&buf, transmittal); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to generate PDF", "details": err.Error()})
+			return
+		}
+		c.Header("Content-Disposition", fmt.Sprintf("attachment; filename=%s.pdf", transmittal.TransmittalNo))
+		c.Data(http.StatusOK, "application/pdf", buf.Bytes())
+	}
+}
+
+// writeTransmittalPDF lays out the transmittal as a one-table A4 document with a reply box.
+func writeTransmittalPDF(buf *bytes.Buffer, t models.Transmittal) error {
+	pdf := gofpdf.New("P", "mm", "A4", "")
+	pdf.SetMargins(12, 12, 12)
+	pdf.AddPage()
+	tr := pdf.UnicodeTranslatorFromDescriptor("")
+
+	// Header band
+	pdf.SetFont("Arial", "B", 18)
+	pdf.SetFillColor(240, 240, 240)
+	pdf.Rect(12, 12, 186, 12, "F")
+	pdf.SetXY(12, 13)
+	pdf.Cell(120, 10, "Drawing Transmittal")
+	pdf.SetFont("Arial", "B", 12)
+	pdf.CellFormat(66, 10, t.TransmittalNo, "", 0, "R", false, 0, "")
+	pdf.Ln(16)
+
+	field := func(label, value string) {
+		pdf.SetFont("Arial", "", 10)
+		pdf.Cell(35, 6, label)
+		pdf.SetFont("Arial", "B", 10)
+		pdf.Cell(151, 6, tr(value))
+		pdf.Ln(6)
+	}
+	field("Project:", t.ProjectName)
+	recipient := t.RecipientName
+	if t.RecipientEmail != "" {
+		recipient += " <" + t.RecipientEmail + ">"
+	}
+	field("To:", recipient)
+	field("Purpose:", models.TransmittalPurposes[t.Purpose])
+	field("Issued on:", t.IssuedAt.Format("2006-01-02"))
+	field("Issued by:", t.IssuedBy)
+	if t.DueDate != nil {
+		field("Reply due:", t.DueDate.Format("2006-01-02"))
+	}
+	if t.Remarks != "" {
+		pdf.SetFont("Arial", "", 10)
+		pdf.Cell(35, 6, "Remarks:")
+		pdf.MultiCell(151, 6, tr(t.Remarks), "", "L", false)
+	}
+	pdf.Ln(4)
+
+	headers := []string{"#", "Element Type", "Drawing", "Revision", "File"}
+	widths := []float64{10, 52, 40, 22, 62}
+	tableHeader := func() {
+		pdf.SetFont("Arial", "B", 9)
+		pdf.SetFillColor(230, 230, 230)
+		for i, title := range headers {
+			pdf.CellFormat(widths[i], 8, title, "1", 0, "C", true, 0, "")
+		}
+		pdf.Ln(8)
+		pdf.SetFont("Arial", "", 9)
+	}
+	tableHeader()
+	for i, item := range t.Items {
+		if pdf.GetY() > 260 {
+			pdf.AddPage()
+			tableHeader()
+		}
+		file := item.File
+		if len(file) > 38 {
+			file = file[:35] + "..."
+		}
+		row := []string{strconv.Itoa(i + 1), tr(item.ElementType), tr(item.DrawingTypeName), item.Version, file}
+		for j, value := range row {
+			align := "L"
+			if j == 0 || j == 3 {
+				align = "C"
+			}
+			pdf.CellFormat(widths[j], 6, value, "1", 0, align, false, 0, "")
+		}
+		pdf.Ln(6)
+	}
+
+	// Reply box
+	if pdf.GetY() > 230 {
+		pdf.AddPage()
+	}
+	pdf.Ln(8)
+	pdf.SetFont("Arial", "B", 11)
+	pdf.Cell(186, 7, "Recipient Reply")
+	pdf.Ln(8)
+	pdf.SetFont("Arial", "", 9)
+	statuses := make([]string, 0, len(models.TransmittalReplyStatuses))
+	for _, label := range models.TransmittalReplyStatuses {
+		statuses = append(statuses, label)
+	}
+	sort.Strings(statuses)
+	for _, label := range statuses {
+		mark := "[  ]"
+		if models.TransmittalReplyStatuses[t.ReplyStatus] == label {
+			mark = "[X]"
+		}
+		pdf.Cell(37, 6, mark+" "+label)
+	}
+	pdf.Ln(8)
+	pdf.SetFont("Arial", "", 10)
+	if t.RepliedAt != nil {
+		field("Replied on:", t.RepliedAt.Format("2006-01-02"))
+		if t.ReplyComments != "" {
+			pdf.SetFont("Arial", "", 10)
+			pdf.Cell(35, 6, "Comments:")
+			pdf.MultiCell(151, 6, tr(t.ReplyComments), "", "L", false)
+		}
+	} else {
+		pdf.Cell(35, 6, "Comments:")
+		pdf.Rect(47, pdf.GetY(), 151, 24, "D")
+		pdf.Ln(28)
+		pdf.Cell(35, 6, "Signature / Date:")
+		pdf.Line(47, pdf.GetY()+6, 198, pdf.GetY()+6)
+	}
+
+	return pdf.Output(buf)
+}
+
+// SendOverdueTransmittalReminders emails recipients whose reply is past due and tells the
+// issuer in-app. Each transmittal is reminded at most once a day.
+func SendOverdueTransmittalReminders(db *sql.DB, cronLogger *log.Logger) error {
+	rows, err := db.Query(selectTransmittalSQL + `
+		WHERE t.reply_status = 'pending' AND t.due_date < CURRENT_DATE
+		  AND (t.last_reminder_at IS NULL OR t.last_reminder_at < NOW() - INTERVAL '20 hours')
+		ORDER BY t.due_date`)
+	if err != nil {
+		return fmt.Errorf("failed to fetch overdue transmittals: %v", err)
+	}
+	var overdue []models.Transmittal
+	for rows.Next() {
+		var t models.Transmittal
+		if err := scanTransmittal(rows, &t); err != nil {
+			rows.Close()
+			return err
+		}
+		overdue = append(overdue, t)
+	}
+	rows.Close()
+
+	emailService := services.NewEmailService(db)
+	for _, t := range overdue {
+		daysLate := int(time.Since(*t.DueDate).Hours() / 24)
+		if t.RecipientEmail != "" {
+			var drawingCount int
+			db.QueryRow(`SELECT COUNT(*) FROM transmittal_item WHERE transmittal_id = $1`, t.ID).Scan(&drawingCount)
+			emailData := models.EmailData{
+				Email:        t.RecipientEmail,
+				UserName:     t.RecipientName,
+				ProjectName:  t.ProjectName,
+				ProjectID:    strconv.Itoa(t.ProjectID),
+				AdminName:    t.IssuedBy,
+				LoginURL:     "https://precastezy.blueinvent.com/login",
+				SupportEmail: "support@blueinvent.com",
+			}
+			subject := fmt.Sprintf("Reminder: reply overdue on transmittal %s (%s)", t.TransmittalNo, t.ProjectName)
+			body := fmt.Sprintf("Dear %s,\n\nTransmittal %s for project %s, sent %s on %s with %d drawing(s), "+
+				"was due for a reply on %s and is now %d day(s) overdue.\n\nPlease send your response at the earliest.\n\nRegards,\n%s",
+				t.RecipientName, t.TransmittalNo, t.ProjectName, strings.ToLower(models.TransmittalPurposes[t.Purpose]),
+				t.IssuedAt.Format("2006-01-02"), drawingCount, t.DueDate.Format("2006-01-02"), daysLate, t.IssuedBy)
+			if err := emailService.SendTransmittalReminderEmail(emailData, subject, body); err != nil {
+				if cronLogger != nil {
+					cronLogger.Printf("Failed to send reminder for transmittal %d: %v", t.ID, err)
+				}
+				continue
+			}
+		}
+
+		var issuerID int
+		db.QueryRow(`SELECT issued_by_user_id FROM transmittal WHERE id = $1`, t.ID).Scan(&issuerID)
+		if issuerID != 0 {
+			sendUserNotification(db, issuerID,
+				fmt.Sprintf("Reply to transmittal %s from %s is %d day(s) overdue", t.TransmittalNo, t.RecipientName, daysLate),
+				fmt.Sprintf("https://precastezy.blueinvent.com/project/%d/drawing", t.ProjectID))
+		}
+
+		if _, err := db.Exec(`UPDATE transmittal SET last_reminder_at = NOW(), reminder_count = reminder_count + 1 WHERE id = $1`, t.ID); err != nil {
+			return err
+		}
+	}
+	if cronLogger != nil {
+		cronLogger.Printf("Sent %d overdue transmittal reminders", len(overdue))
+	}
+	return nil
+}
@@ -1,29 +1,41 @@
 package handlers
 
 import (
+	"backend/services"
+	"backend/storage"
+	"bytes"
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"os"
+	"path"
 	"path/filepath"
+	"strconv"
 	"strings"
 	"time"
 
 	"mime/multipart"
 
 	"github.com/gin-gonic/gin"
+	"github.com/xuri/excelize/v2"
 )
 
 const imageDir = "/var/www/dataprecast/"
 
 // ServeFile godoc
 // @Summary      Serve file
-// @Description  Serve a file by name from query param ?file=filename
+// @Description  Serve an uploaded file. Requires either a signed URL (expires + sig, see /api/files/url) or a session.
 // @Tags         upload
 // @Produce      application/octet-stream
-// @Param        file  query     string  true  "File name (path relative to storage)"
+// @Param        file     query     string  true   "File name returned by /api/upload"
+// @Param        expires  query     int     false  "Expiry (unix seconds) of a signed URL"
+// @Param        sig      query     string  false  "Signature of a signed URL"
+// @Param        size     query     string  false  "original (default), thumb or web; non-images are always served as is"
 // @Success      200   {file}   file    "File content"
 // @Failure      400   {object}  object
+// @Failure      401   {object}  object
 // @Failure      403   {object}  object
 // @Failure      404   {object}  object
 // @Failure      500   {object}  object
@@ -38,68 +50,71 @@ func ServeFile(c *gin.Context) {
 
 	// Secure the file path to prevent directory traversal attacks
 	cleanFileName := filepath.Clean(fileName)
-	if cleanFileName != fileName || strings.Contains(cleanFileName, "..") {
+	if cleanFileName != fileName || strings.Contains(cleanFileName, "..") || filepath.IsAbs(cleanFileName) {
 		c.JSON(http.StatusBadRequest, gin.H{"error": "invalid file path"})
 		return
 	}
-
-	// Construct full file path and ensure it's within imageDir
-	absoluteImageDir, err := filepath.Abs(imageDir)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
+	// Quarantined files, derivatives and import workbooks are only read by the server itself
+	if isInternalFileName(cleanFileName) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
 		return
 	}
 
-	filePath := filepath.Join(absoluteImageDir, cleanFileName)
-	if !strings.HasPrefix(filePath, absoluteImageDir+string(os.PathSeparator)) {
-		c.JSON(http.StatusForbidden, gin.H{"error": "access denied"})
-		return
-	}
+	db := storage.GetDB()
 
-	// Check if the file exists and is not a directory
-	info, err := os.Stat(filePath)
-	if os.IsNotExist(err) || info.IsDir() {
-		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
-		return
+	variant := c.Query("size")
+	if variant == "original" {
+		variant = ""
 	}
 
-	// Open the file to determine its MIME type
-	file, err := os.Open(filePath)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
-		return
+	// Either a valid signed URL or a logged-in session
+	if sig := c.Query("sig"); sig != "" {
+		if !verifyFileURLSignature(cleanFileName, variant, c.Query("expires"), sig) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "link is invalid or has expired"})
+			return
+		}
+	} else {
+		sessionID := c.GetHeader("Authorization")
+		if sessionID == "" {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "signed URL or session is required"})
+			return
+		}
+		if _, _, err := GetSessionDetails(db, sessionID); err != nil {
+			c.JSON(http.StatusUnauthorized, gin.H{"error": "Invalid session", "details": err.Error()})
+			return
+		}
 	}
-	defer file.Close()
 
-	// Read a small portion of the file to detect its MIME type
-	buffer := make([]byte, 512)
-	_, err = file.Read(buffer)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, gin.H{"error": "server error"})
-		return
+	switch variant {
+	case "":
+		ServeStoredFile(c, db, cleanFileName)
+	default:
+		if _, ok := services.ImageVariants[variant]; !ok {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "size must be original, thumb or web"})
+			return
+		}
+		ServeImageVariant(c, db, cleanFileName, variant)
 	}
-
-	// Detect the content type
-	contentType := http.DetectContentType(buffer)
-	c.Writer.Header().Set("Content-Type", contentType)
-
-	// Serve the file
-	c.File(filePath)
 }
 
 // UploadFile godoc
 // @Summary      Upload file
-// @Description  Upload a file (multipart form, field name: file)
+// @Description  Upload a file (multipart form, field name: file). The content is sniffed and checked against the allow-list and size limit of the purpose (see /api/uploads/policies); failing files are quarantined. Files are stored by content hash, so identical uploads share one object.
 // @Tags         upload
 // @Accept       multipart/form-data
 // @Produce      json
-// @Param        file  formData  file    true  "File to upload"
-// @Success      200   {object}  object  "message, file path, etc."
+// @Param        file       formData  file    true   "File to upload"
+// @Param        purpose    formData  string  false  "general, drawing, qc_photo or import. Without it the upload is checked as general; drawing and QC photo uploads go to /api/upload/{purpose}, where it is required"
+// @Param        strip_gps  formData  bool    false  "Remove GPS location from photo metadata"
+// @Success      200   {object}  object  "message, file name, signed url, etc."
 // @Failure      400   {object}  object
+// @Failure      413   {object}  object
+// @Failure      415   {object}  object
+// @Failure      422   {object}  object
 // @Failure      500   {object}  object
 // @Router       /api/upload [post]
 func UploadFile(c *gin.Context) {
-	// Get the uploaded file (accept any file type)
+	// Get the uploaded file
 	file, handler, err := c.Request.FormFile("file")
 	if err != nil {
 		c.JSON(http.StatusBadRequest, gin.H{
@@ -111,65 +126,112 @@ func UploadFile(c *gin.Context) {
 
 	// Validate and sanitize the file name
 	filename := filepath.Base(handler.Filename)
-	if filename == "" {
+	if filename == "" || filename == "." || filename == string(filepath.Separator) {
 		c.JSON(http.StatusBadRequest, gin.H{
 			"error": "Invalid file name",
 		})
 		return
 	}
 
-	// No file size restrictions - nginx configured to allow large uploads
+	// /api/upload/:purpose pins the purpose; the bare route falls back to the form, then general
+	purpose := c.Param("purpose")
+	if purpose == "" {
+		purpose = c.PostForm("purpose")
+	}
+	if purpose == "" {
+		purpose = c.Query("purpose")
+	}
+	policy, ok := uploadPolicy(purpose)
+	if !ok {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"error": fmt.Sprintf("Unknown upload purpose %q", purpose),
+		})
+		return
+	}
+	stripGPS, _ := strconv.ParseBool(c.DefaultPostForm("strip_gps", c.Query("strip_gps")))
+
+	db := storage.GetDB()
+	uploadedBy := ""
+	if sessionID := c.GetHeader("Authorization"); sessionID != "" {
+		if _, userName, err := GetSessionDetails(db, sessionID); err == nil {
+			uploadedBy = userName
+		}
+	}
 
-	// Ensure the directory exists
-	if _, err := os.Stat(imageDir); os.IsNotExist(err) {
-		if err := os.MkdirAll(imageDir, 0755); err != nil {
-			c.JSON(http.StatusInternalServerError, gin.H{
-				"error": "Unable to create directory",
+	// Size limit, content sniffing, allow-list and virus scan
+	upload, rejection := inspectUpload(db, file, filename, policy, uploadedBy)
+	if rejection != nil {
+		c.JSON(rejection.Status, rejection.JSON())
+		return
+	}
+	defer upload.Close()
+
+	gpsStripped := false
+	if stripGPS {
+		if gpsStripped, err = stripUploadGPS(upload); err != nil {
+			c.JSON(http.StatusUnprocessableEntity, gin.H{
+				"error":   "Unable to remove location data",
+				"details": err.Error(),
 			})
 			return
 		}
 	}
 
-	// Create a unique file name
-	uniqueName := fmt.Sprintf("%d-%s", time.Now().Unix(), filename)
-	dstPath := filepath.Join(imageDir, uniqueName)
-
-	// Attempt to create the file
-	dst, err := os.Create(dstPath)
+	stored, duplicate, err := storeSpooledFile(db, upload.File, upload.Ext, filename, upload.ContentType(), uploadedBy)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error":   "Unable to create the file",
+			"error":   "Unable to save the file",
 			"details": err.Error(),
-			"path":    dstPath,
 		})
 		return
 	}
-	defer dst.Close()
 
-	// Copy the file
-	if _, err := io.Copy(dst, file); err != nil {
+	signedURL, expiresAt, err := SignedFileURL(db, stored.FileName, "", defaultFileURLExpiry)
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
-			"error": "Unable to save the file",
+			"error":   "Unable to sign the file URL",
+			"details": err.Error(),
 		})
 		return
 	}
 
+	// Thumbnails and web-size copies so the app does not pull full-resolution photos
+	variants := gin.H{}
+	if isDerivableImage(stored) {
+		if _, err := ensureImageDerivative(db, stored, "thumb"); err == nil {
+			for name := range services.ImageVariants {
+				if variantURL, _, err := SignedFileURL(db, stored.FileName, name, defaultFileURLExpiry); err == nil {
+					variants[name] = variantURL
+				}
+			}
+		}
+	}
+
 	// Success response
 	c.JSON(http.StatusOK, gin.H{
-		"message":   "File uploaded successfully",
-		"file_name": uniqueName,
-		"file_path": dstPath,
-		"file_size": handler.Size,
-		"file_type": handler.Header.Get("Content-Type"),
+		"message":        "File uploaded successfully",
+		"file_name":      stored.FileName,
+		"file_path":      stored.StorageKey,
+		"file_size":      stored.Size,
+		"file_type":      stored.ContentType,
+		"detected_type":  upload.Kind,
+		"purpose":        policy.Purpose,
+		"gps_stripped":   gpsStripped,
+		"sha256":         stored.SHA256,
+		"duplicate":      duplicate,
+		"url":            signedURL,
+		"url_expires_at": expiresAt,
+		"variants":       variants,
 	})
 }
 
-// UploadFileToDirectory is a utility function that uploads a file to a specified directory
-// and returns the file path. This can be used by other handlers like import handlers.
+// UploadFileToDirectory stores an uploaded file in the object store under the given key prefix
+// and returns its storage key. Import handlers use it so a queued import can be picked up by
+// any instance; read the file back with openImportWorkbook.
 func UploadFileToDirectory(file *multipart.FileHeader, uploadDir string, maxSize int64) (string, error) {
 	// Validate and sanitize the file name
 	filename := filepath.Base(file.Filename)
-	if filename == "" {
+	if filename == "" || filename == "." || filename == string(filepath.Separator) {
 		return "", fmt.Errorf("invalid file name")
 	}
 
@@ -178,14 +240,13 @@ func UploadFileToDirectory(file *multipart.FileHeader, uploadDir string, maxSize
 		return "", fmt.Errorf("file size exceeds the allowed limit")
 	}
 
-	// Ensure the directory exists with proper permissions
-	if err := os.MkdirAll(uploadDir, 0755); err != nil {
-		return "", fmt.Errorf("unable to create directory %s: %w", uploadDir, err)
+	store := objectStore()
+	if store == nil {
+		return "", fmt.Errorf("no object store configured")
 	}
 
-	// Create a unique file name
-	uniqueName := fmt.Sprintf("%d-%s", time.Now().Unix(), filename)
-	dstPath := filepath.Join(uploadDir, uniqueName)
+	// Create a unique key
+	key := path.Join(strings.Trim(filepath.ToSlash(uploadDir), "/."), fmt.Sprintf("%d-%s", time.Now().UnixNano(), filename))
 
 	// Open the source file
 	src, err := file.Open()
@@ -194,19 +255,37 @@ func UploadFileToDirectory(file *multipart.FileHeader, uploadDir string, maxSize
 	}
 	defer src.Close()
 
-	// Attempt to create the file
-	dst, err := os.Create(dstPath)
-	if err != nil {
-		return "", fmt.Errorf("unable to create the file: %w", err)
-	}
-	defer dst.Close()
-
-	// Copy the file
-	if _, err := io.Copy(dst, src); err != nil {
+	contentType := file.Header.Get("Content-Type")
+	if err := store.Put(context.Background(), key, src, file.Size, contentType); err != nil {
 		return "", fmt.Errorf("unable to save the file: %w", err)
 	}
+	return key, nil
+}
+
+// errUnreadableWorkbook is returned by openImportWorkbook when the stored file isn't a workbook
+var errUnreadableWorkbook = errors.New("not a readable Excel workbook")
 
-	return dstPath, nil
+// openImportWorkbook opens a workbook stored by UploadFileToDirectory. The file is read in full first, so
+// errUnreadableWorkbook is only returned for a bad file and never for a failed read.
+func openImportWorkbook(key string) (*excelize.File, error) {
+	store := objectStore()
+	if store == nil {
+		return nil, fmt.Errorf("no object store configured")
+	}
+	rc, _, err := store.Get(context.Background(), key)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+	data, err := io.ReadAll(rc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", key, err)
+	}
+	f, err := excelize.OpenReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %v", errUnreadableWorkbook, err)
+	}
+	return f, nil
 }
 
 // CleanupFile removes a file from the server
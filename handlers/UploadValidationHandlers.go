@@ -0,0 +1,493 @@
+package handlers
+
+import (
+	"archive/zip"
+	"backend/models"
+	"backend/services"
+	"bytes"
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"mime/multipart"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Global upload scanners - set from main.go
+var GlobalFileScanners []services.FileScanner
+
+// SetFileScanners sets the scanners every validated upload goes through
+func SetFileScanners(scanners []services.FileScanner) {
+	GlobalFileScanners = scanners
+}
+
+// Content kinds recognised by sniffing, with the extensions and MIME type each is stored under
+var uploadKinds = map[string]struct {
+	extensions  []string
+	contentType string
+}{
+	"pdf":  {[]string{".pdf"}, "application/pdf"},
+	"dwg":  {[]string{".dwg"}, "image/vnd.dwg"},
+	"jpeg": {[]string{".jpg", ".jpeg"}, "image/jpeg"},
+	"png":  {[]string{".png"}, "image/png"},
+	"gif":  {[]string{".gif"}, "image/gif"},
+	"webp": {[]string{".webp"}, "image/webp"},
+	"xlsx": {[]string{".xlsx"}, "application/vnd.openxmlformats-officedocument.spreadsheetml.sheet"},
+	"docx": {[]string{".docx"}, "application/vnd.openxmlformats-officedocument.wordprocessingml.document"},
+	"csv":  {[]string{".csv"}, "text/csv; charset=utf-8"},
+	"text": {[]string{".txt"}, "text/plain; charset=utf-8"},
+	"zip":  {[]string{".zip"}, "application/zip"},
+}
+
+// uploadPolicies are the allow-lists and size limits per upload purpose
+var uploadPolicies = map[string]models.UploadPolicy{
+	models.UploadPurposeDrawing: {Purpose: models.UploadPurposeDrawing, AllowedTypes: []string{"pdf", "dwg"}, MaxSizeBytes: 50 << 20},
+	models.UploadPurposeQCPhoto: {Purpose: models.UploadPurposeQCPhoto, AllowedTypes: []string{"jpeg", "png"}, MaxSizeBytes: 15 << 20},
+	models.UploadPurposeImport:  {Purpose: models.UploadPurposeImport, AllowedTypes: []string{"xlsx", "csv"}, MaxSizeBytes: 10 << 20},
+	models.UploadPurposeGeneral: {Purpose: models.UploadPurposeGeneral, AllowedTypes: []string{"pdf", "dwg", "jpeg", "png", "gif", "webp", "xlsx", "docx", "csv", "text", "zip"}, MaxSizeBytes: 100 << 20},
+}
+
+// uploadPolicy returns the policy of a purpose, "" meaning general
+func uploadPolicy(purpose string) (models.UploadPolicy, bool) {
+	if purpose == "" {
+		purpose = models.UploadPurposeGeneral
+	}
+	policy, ok := uploadPolicies[purpose]
+	if !ok {
+		return policy, false
+	}
+	policy.Extensions = nil
+	for _, kind := range policy.AllowedTypes {
+		policy.Extensions = append(policy.Extensions, uploadKinds[kind].extensions...)
+	}
+	return policy, true
+}
+
+// UploadRejection explains why an upload was refused
+type UploadRejection struct {
+	Status       int
+	Reason       string
+	Purpose      string
+	DetectedType string
+	QuarantineID int // 0 when the file was not kept
+}
+
+func (r *UploadRejection) Error() string { return r.Reason }
+
+// JSON is the error body returned to the client
+func (r *UploadRejection) JSON() gin.H {
+	body := gin.H{"error": "Upload rejected", "details": r.Reason, "purpose": r.Purpose}
+	if r.DetectedType != "" {
+		body["detected_type"] = r.DetectedType
+	}
+	if r.QuarantineID > 0 {
+		body["quarantine_id"] = r.QuarantineID
+	}
+	return body
+}
+
+// inspectedUpload is an upload that passed validation, spooled to a temp file
+type inspectedUpload struct {
+	File *os.File
+	Size int64
+	Kind string
+	Ext  string // extension the file is stored under
+}
+
+func (u *inspectedUpload) Close() {
+	u.File.Close()
+	os.Remove(u.File.Name())
+}
+
+// ContentType is the MIME type of the sniffed kind
+func (u *inspectedUpload) ContentType() string {
+	return uploadKinds[u.Kind].contentType
+}
+
+// sniffUploadKind works out what a file really is from its content. The extension only
+// separates CSV from plain text, which look the same.
+func sniffUploadKind(f *os.File, size int64, ext string) (string, string) {
+	head := make([]byte, 512)
+	n, _ := f.ReadAt(head, 0)
+	head = head[:n]
+	detected := http.DetectContentType(head)
+
+	switch {
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return "pdf", detected
+	case isDWGHeader(head):
+		return "dwg", "image/vnd.dwg"
+	}
+
+	switch detected {
+	case "image/jpeg":
+		return "jpeg", detected
+	case "image/png":
+		return "png", detected
+	case "image/gif":
+		return "gif", detected
+	case "image/webp":
+		return "webp", detected
+	case "application/zip":
+		zr, err := zip.NewReader(f, size)
+		if err != nil {
+			return "", detected
+		}
+		names := map[string]bool{}
+		for _, zf := range zr.File {
+			names[zf.Name] = true
+		}
+		switch {
+		case names["[Content_Types].xml"] && names["xl/workbook.xml"]:
+			return "xlsx", detected
+		case names["[Content_Types].xml"] && names["word/document.xml"]:
+			return "docx", detected
+		}
+		return "zip", detected
+	}
+
+	if strings.HasPrefix(detected, "text/plain") && isUTF8Text(f) {
+		if ext == ".csv" {
+			return "csv", detected
+		}
+		return "text", detected
+	}
+	return "", detected
+}
+
+// isDWGHeader matches the AutoCAD version string a DWG starts with, e.g. AC1032
+func isDWGHeader(head []byte) bool {
+	if len(head) < 6 || !bytes.HasPrefix(head, []byte("AC1")) {
+		return false
+	}
+	for _, ch := range head[3:6] {
+		if ch < '0' || ch > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// isUTF8Text checks the first 64 KB are valid UTF-8 without NUL bytes
+func isUTF8Text(f *os.File) bool {
+	buf := make([]byte, 64*1024)
+	n, _ := f.ReadAt(buf, 0)
+	buf = buf[:n]
+	if bytes.IndexByte(buf, 0) >= 0 {
+		return false
+	}
+	// A multi-byte rune may be cut at the end of the sample
+	for i := 0; i < utf8.UTFMax && len(buf) > 0 && !utf8.Valid(buf); i++ {
+		buf = buf[:len(buf)-1]
+	}
+	return utf8.Valid(buf)
+}
+
+// inspectUpload spools an upload and runs the validation pipeline: size limit, content
+// sniffing against the purpose's allow-list, extension check and the configured scanners.
+// Files failing after they were read in full are quarantined, except when a scanner errors:
+// that says nothing about the file, so it is rejected for the client to retry.
+func inspectUpload(db *sql.DB, r io.Reader, originalName string, policy models.UploadPolicy, uploadedBy string) (*inspectedUpload, *UploadRejection) {
+	reject := func(status int, reason string) *UploadRejection {
+		return &UploadRejection{Status: status, Reason: reason, Purpose: policy.Purpose}
+	}
+
+	tmp, size, err := spoolUpload(r, policy.MaxSizeBytes)
+	if err != nil {
+		return nil, reject(http.StatusBadRequest, err.Error())
+	}
+	u := &inspectedUpload{File: tmp, Size: size}
+	if size > policy.MaxSizeBytes {
+		u.Close()
+		return nil, reject(http.StatusRequestEntityTooLarge,
+			fmt.Sprintf("file exceeds the %d MB limit for %s uploads", policy.MaxSizeBytes>>20, policy.Purpose))
+	}
+	if size == 0 {
+		u.Close()
+		return nil, reject(http.StatusBadRequest, "file is empty")
+	}
+
+	ext := strings.ToLower(filepath.Ext(filepath.Base(originalName)))
+	kind, detected := sniffUploadKind(tmp, size, ext)
+	u.Kind = kind
+
+	fail := func(status int, reason string) (*inspectedUpload, *UploadRejection) {
+		rejection := reject(status, reason)
+		rejection.DetectedType = kind
+		if kind == "" {
+			rejection.DetectedType = detected
+		}
+		id, err := quarantineUpload(db, u, originalName, policy.Purpose, reason, rejection.DetectedType, uploadedBy)
+		if err != nil {
+			log.Printf("Failed to quarantine upload %q: %v", originalName, err)
+		}
+		rejection.QuarantineID = id
+		u.Close()
+		return nil, rejection
+	}
+
+	if kind == "" {
+		return fail(http.StatusUnsupportedMediaType, fmt.Sprintf("unrecognised file content (%s)", detected))
+	}
+	if !slices.Contains(policy.AllowedTypes, kind) {
+		return fail(http.StatusUnsupportedMediaType,
+			fmt.Sprintf("%s files are not accepted for %s uploads (allowed: %s)", kind, policy.Purpose, strings.Join(policy.AllowedTypes, ", ")))
+	}
+	// A missing extension is fine, the stored name gets the right one
+	if ext != "" && !slices.Contains(uploadKinds[kind].extensions, ext) {
+		return fail(http.StatusUnprocessableEntity, fmt.Sprintf("file extension %s does not match its %s content", ext, kind))
+	}
+	u.Ext = uploadKinds[kind].extensions[0]
+	if ext != "" {
+		u.Ext = ext
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	target := services.ScanTarget{
+		Path:        tmp.Name(),
+		Kind:        kind,
+		TextAllowed: slices.Contains(policy.AllowedTypes, "text") || slices.Contains(policy.AllowedTypes, "csv"),
+	}
+	for _, scanner := range GlobalFileScanners {
+		verdict, err := scanner.Scan(ctx, target)
+		if err != nil {
+			u.Close()
+			rejection := reject(http.StatusServiceUnavailable, fmt.Sprintf("scanner %s failed, try again later: %v", scanner.Name(), err))
+			rejection.DetectedType = kind
+			return nil, rejection
+		}
+		if !verdict.Clean {
+			return fail(http.StatusUnprocessableEntity, fmt.Sprintf("threat detected by %s: %s", scanner.Name(), verdict.Threat))
+		}
+	}
+	return u, nil
+}
+
+// quarantineUpload keeps a rejected file out of the normal catalogue for later review
+func quarantineUpload(db *sql.DB, u *inspectedUpload, originalName, purpose, reason, detected, uploadedBy string) (int, error) {
+	store := objectStore()
+	if store == nil {
+		return 0, fmt.Errorf("no object store configured")
+	}
+	sum, size, err := hashFile(u.File)
+	if err != nil {
+		return 0, err
+	}
+	key := "quarantine/" + sum
+	if _, err := u.File.Seek(0, io.SeekStart); err != nil {
+		return 0, err
+	}
+	if err := store.Put(context.Background(), key, u.File, size, "application/octet-stream"); err != nil {
+		return 0, err
+	}
+
+	var id int
+	err = db.QueryRow(`
+		INSERT INTO upload_quarantine (original_name, purpose, reason, detected_type, sha256, size, storage_key, uploaded_by)
+		VALUES ($1, $2, $3, $4, $5, $6, $7, $8) RETURNING id`,
+		filepath.Base(originalName), purpose, reason, detected, sum, size, key, uploadedBy).Scan(&id)
+	return id, err
+}
+
+// stripUploadGPS removes location metadata from a JPEG or PNG upload in place
+func stripUploadGPS(u *inspectedUpload) (bool, error) {
+	var strip func([]byte) ([]byte, bool, error)
+	switch u.Kind {
+	case "jpeg":
+		strip = services.StripJPEGGPS
+	case "png":
+		strip = services.StripPNGGPS
+	default:
+		return false, nil
+	}
+
+	data, err := os.ReadFile(u.File.Name())
+	if err != nil {
+		return false, err
+	}
+	out, changed, err := strip(data)
+	if err != nil || !changed {
+		return false, err
+	}
+	if err := u.File.Truncate(0); err != nil {
+		return false, err
+	}
+	if _, err := u.File.WriteAt(out, 0); err != nil {
+		return false, err
+	}
+	u.Size = int64(len(out))
+	return true, nil
+}
+
+// ValidateUploadFile runs a multipart file through the validation pipeline without storing it.
+// Handlers that keep their own copy of the file (e.g. imports) call this first.
+func ValidateUploadFile(db *sql.DB, fh *multipart.FileHeader, purpose, uploadedBy string) *UploadRejection {
+	policy, ok := uploadPolicy(purpose)
+	if !ok {
+		return &UploadRejection{Status: http.StatusBadRequest, Reason: fmt.Sprintf("unknown upload purpose %q", purpose), Purpose: purpose}
+	}
+	src, err := fh.Open()
+	if err != nil {
+		return &UploadRejection{Status: http.StatusBadRequest, Reason: err.Error(), Purpose: policy.Purpose}
+	}
+	defer src.Close()
+
+	u, rejection := inspectUpload(db, src, fh.Filename, policy, uploadedBy)
+	if rejection != nil {
+		return rejection
+	}
+	u.Close()
+	return nil
+}
+
+// GetUploadPolicies lists the accepted types and size limits of each upload purpose.
+// @Summary Upload policies
+// @Tags upload
+// @Produce json
+// @Success 200 {array} models.UploadPolicy
+// @Router /api/uploads/policies [get]
+func GetUploadPolicies(c *gin.Context) {
+	purposes := make([]string, 0, len(uploadPolicies))
+	for purpose := range uploadPolicies {
+		purposes = append(purposes, purpose)
+	}
+	slices.Sort(purposes)
+
+	policies := []models.UploadPolicy{}
+	for _, purpose := range purposes {
+		policy, _ := uploadPolicy(purpose)
+		policies = append(policies, policy)
+	}
+	c.JSON(http.StatusOK, policies)
+}
+
+// quarantineAdmin is sessionContext for the quarantine endpoints, which only admins may use as
+// quarantined files can be malicious.
+func quarantineAdmin(c *gin.Context, db *sql.DB) (session models.Session, userName string, ok bool) {
+	session, userName, ok = sessionContext(c, db)
+	if !ok {
+		return session, "", false
+	}
+	admin, err := isGlobalAdmin(db, session.UserID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to check user role", "details": err.Error()})
+		return session, "", false
+	}
+	if !admin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "Only admins can manage quarantined uploads"})
+		return session, "", false
+	}
+	return session, userName, true
+}
+
+// GetQuarantinedUploads lists uploads that failed validation.
+// @Summary List quarantined uploads
+// @Tags upload
+// @Produce json
+// @Param Authorization header string true "Session ID"
+// @Success 200 {array} models.QuarantinedUpload
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 500 {object} models.ErrorResponse
+// @Router /api/uploads/quarantine [get]
+func GetQuarantinedUploads(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if _, _, ok := quarantineAdmin(c, db); !ok {
+			return
+		}
+
+		rows, err := db.Query(`
+			SELECT id, original_name, purpose, reason, detected_type, sha256, size, uploaded_by, created_at
+			FROM upload_quarantine ORDER BY created_at DESC LIMIT 500`)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to fetch quarantined uploads", "details": err.Error()})
+			return
+		}
+		defer rows.Close()
+
+		uploads := []models.QuarantinedUpload{}
+		for rows.Next() {
+			var q models.QuarantinedUpload
+			if err := rows.Scan(&q.ID, &q.OriginalName, &q.Purpose, &q.Reason, &q.DetectedType, &q.SHA256, &q.Size, &q.UploadedBy, &q.CreatedAt); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to scan quarantined upload", "details": err.Error()})
+				return
+			}
+			uploads = append(uploads, q)
+		}
+		c.JSON(http.StatusOK, uploads)
+	}
+}
+
+// DeleteQuarantinedUpload purges a quarantined file.
+// @Summary Purge a quarantined upload
+// @Tags upload
+// @Produce json
+// @Param Authorization header string true "Session ID"
+// @Param id path int true "Quarantine ID"
+// @Success 200 {object} models.MessageResponse
+// @Failure 400 {object} models.ErrorResponse
+// @Failure 401 {object} models.ErrorResponse
+// @Failure 403 {object} models.ErrorResponse
+// @Failure 404 {object} models.ErrorResponse
+// @Router /api/uploads/quarantine/{id} [delete]
+func DeleteQuarantinedUpload(db *sql.DB) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		session, userName, ok := quarantineAdmin(c, db)
+		if !ok {
+			return
+		}
+
+		id, err := strconv.Atoi(c.Param("id"))
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid quarantine id"})
+			return
+		}
+
+		var key, name, sum string
+		err = db.QueryRow(`DELETE FROM upload_quarantine WHERE id = $1 RETURNING storage_key, original_name, sha256`, id).Scan(&key, &name, &sum)
+		if err == sql.ErrNoRows {
+			c.JSON(http.StatusNotFound, gin.H{"error": "Quarantined upload not found"})
+			return
+		} else if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "Failed to delete quarantined upload", "details": err.Error()})
+			return
+		}
+
+		// The same content may have been quarantined more than once
+		var remaining int
+		db.QueryRow(`SELECT COUNT(*) FROM upload_quarantine WHERE sha256 = $1`, sum).Scan(&remaining)
+		if store := objectStore(); store != nil && remaining == 0 {
+			if err := store.Delete(context.Background(), key); err != nil && !errors.Is(err, services.ErrObjectNotFound) {
+				log.Printf("Failed to delete quarantined object %s: %v", key, err)
+			}
+		}
+
+		activityLog := models.ActivityLog{
+			EventContext: "Upload Quarantine",
+			EventName:    "Delete",
+			Description:  fmt.Sprintf("Purged quarantined upload %s", name),
+			UserName:     userName,
+			HostName:     session.HostName,
+			IPAddress:    session.IPAddress,
+			CreatedAt:    time.Now(),
+		}
+		if logErr := SaveActivityLog(db, activityLog); logErr != nil {
+			log.Printf("Failed to save activity log: %v", logErr)
+		}
+
+		c.JSON(http.StatusOK, gin.H{"message": "Quarantined upload deleted"})
+	}
+}
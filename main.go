@@ -31,6 +31,7 @@ import (
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"math/rand"
@@ -631,30 +632,23 @@ func ErectedHandler(db *sql.DB) error {
 			continue
 		}
 
-		res, err := tx.Exec(`
-			UPDATE precast_stock
-			SET stockyard = true,
-			    erected = true,
-			    order_by_erection = true,
-			    dispatch_status = true
-			WHERE element_id = ANY($1) AND project_id = $2
-		`, pq.Array(elementIDs), projectID)
-		if err != nil {
-			log.Printf("[ErectedHandler] update precast_stock failed project=%d: %v", projectID, err)
-			return err
-		}
-
-		affected, _ := res.RowsAffected()
-		log.Printf("[ErectedHandler] precast_stock updated rows=%d project=%d", affected, projectID)
-
-		res2, err := tx.Exec(`UPDATE element SET status = 'Erected' WHERE id = ANY($1)`, pq.Array(elementIDs))
-		if err != nil {
-			log.Printf("[ErectedHandler] update element status failed: %v", err)
-			return err
+		// One at a time, so an element that can't be erected yet doesn't hold back the rest
+		erected := 0
+		for _, eid := range elementIDs {
+			err := handlers.TransitionElements(tx, []int{eid}, models.LifecycleErected, "ErectedHandler", "system")
+			var illegalErr *models.ErrIllegalTransition
+			if errors.As(err, &illegalErr) {
+				log.Printf("[ErectedHandler] project=%d element=%d skipped: %v", projectID, eid, err)
+				continue
+			}
+			if err != nil {
+				log.Printf("[ErectedHandler] lifecycle update failed project=%d element=%d: %v", projectID, eid, err)
+				return err
+			}
+			erected++
 		}
 
-		affected2, _ := res2.RowsAffected()
-		log.Printf("[ErectedHandler] element updated rows=%d project=%d", affected2, projectID)
+		log.Printf("[ErectedHandler] elements erected=%d project=%d", erected, projectID)
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -687,6 +681,19 @@ func CompleteActivityToStockyard(db *sql.DB) error {
 	for _, a := range activities {
 		log.Printf("[CompleteActivityToStockyard] force completing activity_id=%d", a.ID)
 
+		// Move the element to the stockyard first: an illegal move skips the activity before
+		// anything else about it has changed
+		log.Printf("[CompleteActivityToStockyard] activity=%d moving to stockyard", a.ID)
+		if err := moveElementToStockyard(tx, a.ElementID); err != nil {
+			var illegalErr *models.ErrIllegalTransition
+			if errors.As(err, &illegalErr) {
+				log.Printf("[CompleteActivityToStockyard] activity=%d skipped: %v", a.ID, err)
+				continue
+			}
+			log.Printf("[CompleteActivityToStockyard] lifecycle update failed activity=%d: %v", a.ID, err)
+			return err
+		}
+
 		// 1️⃣ Force mark ALL statuses as completed
 		_, err := tx.Exec(`
 			UPDATE activity
@@ -704,9 +711,8 @@ func CompleteActivityToStockyard(db *sql.DB) error {
 			return err
 		}
 
-		// 2️⃣ Move element to stockyard (outside tx uses db)
-		log.Printf("[CompleteActivityToStockyard] activity=%d moving to stockyard", a.ID)
-		if _, err := CreateePrecastStock(db, a.ElementID, a.ProjectID, a.StockyardID); err != nil {
+		// 2️⃣ Move element to stockyard
+		if _, err := CreateePrecastStock(tx, a.ElementID, a.ProjectID, a.StockyardID); err != nil {
 			log.Printf("[CompleteActivityToStockyard] stockyard insert failed activity=%d: %v", a.ID, err)
 			return err
 		}
@@ -745,6 +751,24 @@ func CompleteActivityToStockyard(db *sql.DB) error {
 			log.Printf("[CompleteActivityToStockyard] complete_production insert failed activity=%d: %v", a.ID, err)
 			return err
 		}
+		// Casting is done: book the element's material against the BOM version in force now
+		if err := handlers.RecordElementBOMConsumption(tx, a.ElementID, time.Now()); err != nil {
+			log.Printf("[CompleteActivityToStockyard] BOM consumption failed activity=%d: %v", a.ID, err)
+			return err
+		}
+
+		// 5️⃣ Free the mould and group the element into the day's pour
+		activityID := a.ID
+		mouldCode, err := handlers.CompleteMouldCycle(tx, a.ID, time.Now(), "system")
+		if err != nil {
+			log.Printf("[CompleteActivityToStockyard] mould cycle failed activity=%d: %v", a.ID, err)
+			return err
+		}
+		pour := &models.PourCastingInput{Bed: mouldCode}
+		if _, err := handlers.RecordElementPour(tx, a.ProjectID, a.ElementID, &activityID, time.Now(), pour, "system"); err != nil {
+			log.Printf("[CompleteActivityToStockyard] pour record failed activity=%d: %v", a.ID, err)
+			return err
+		}
 	}
 
 	if err := tx.Commit(); err != nil {
@@ -756,7 +780,17 @@ func CompleteActivityToStockyard(db *sql.DB) error {
 	return nil
 }
 
-func CreateePrecastStock(db *sql.DB, elementID int, projectID int, stockyardID int) (int, error) {
+// moveElementToStockyard moves an element whose production is complete through QC into the stockyard.
+func moveElementToStockyard(tx *sql.Tx, elementID int) error {
+	for _, state := range []string{models.LifecycleQCPassed, models.LifecycleInYard} {
+		if err := handlers.TransitionElements(tx, []int{elementID}, state, "CompleteActivityToStockyard", "system"); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func CreateePrecastStock(tx *sql.Tx, elementID int, projectID int, stockyardID int) (int, error) {
 	log.Printf("[CreateePrecastStock] Creating precast stock for elementID=%d, projectID=%d, stockyardID=%d", elementID, projectID, stockyardID)
 	var elementTypeID, targetLocation int
 	var elementName string
@@ -764,7 +798,7 @@ func CreateePrecastStock(db *sql.DB, elementID int, projectID int, stockyardID i
 
 	// Fetch element details from the element table
 	query := "SELECT element_type_id, element_name, target_location, disable FROM element WHERE id = $1 AND project_id = $2 LIMIT 1"
-	err := db.QueryRow(query, elementID, projectID).Scan(&elementTypeID, &elementName, &targetLocation, &disable)
+	err := tx.QueryRow(query, elementID, projectID).Scan(&elementTypeID, &elementName, &targetLocation, &disable)
 	if err != nil {
 		log.Printf("[CreateePrecastStock]Failed to fetch element details: %v", err)
 		return 0, fmt.Errorf("element not found or database error: %w", err)
@@ -774,7 +808,7 @@ func CreateePrecastStock(db *sql.DB, elementID int, projectID int, stockyardID i
 	var thickness, length, height, weight, density float32
 	var elementTypeName, elementType string
 	query = "SELECT element_type, element_type_name, thickness, length, height, mass, density FROM element_type WHERE element_type_id = $1 AND project_id = $2 LIMIT 1"
-	err = db.QueryRow(query, elementTypeID, projectID).Scan(&elementType, &elementTypeName, &thickness, &length, &height, &weight, &density)
+	err = tx.QueryRow(query, elementTypeID, projectID).Scan(&elementType, &elementTypeName, &thickness, &length, &height, &weight, &density)
 	if err != nil {
 		log.Printf("[CreateePrecastStock] Failed to fetch element type details: %v", err)
 		return 0, fmt.Errorf("element type not found or database error: %w", err)
@@ -803,7 +837,7 @@ func CreateePrecastStock(db *sql.DB, elementID int, projectID int, stockyardID i
 		`
 
 	var insertedID int
-	err = db.QueryRow(query, elementID, elementType, elementTypeID, stockyardID,
+	err = tx.QueryRow(query, elementID, elementType, elementTypeID, stockyardID,
 		dimensions, elementWeight, productionDate, storageLocation,
 		dispatchStatus, projectID, targetLocation).Scan(&insertedID)
 
@@ -1316,21 +1350,20 @@ func computeStagedAggregates(tx *sql.Tx, workOrderID int, projectID int, start,
 	}
 
 	// Insert Handover
+	// Elements are billable at handover once the client has accepted their floor
 	if res, err2 := tx.Exec(`
 	       INSERT INTO tmp_stage_elements(element_id, stage, timestamp, floor_id)
-	       SELECT ps.element_id, 'handover', ps.updated_at, ps.target_location
-	       FROM precast_stock ps
-		   JOIN element e ON e.id = ps.element_id
-	       WHERE ps.project_id = $1
-	         AND e.billable = true\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\\
-	         AND COALESCE(ps.stockyard,false) = true
-	         AND COALESCE(ps.dispatch_status,true) = true
-	         AND COALESCE(ps.erected,false) = true
-	         AND COALESCE(ps.recieve_in_erection,false) = true
-	         AND ps.updated_at >= $2 AND ps.updated_at <= $3
+	       SELECT he.element_id, 'handover', ho.accepted_at, e.target_location
+	       FROM handover_element he
+	       JOIN handover ho ON ho.id = he.handover_id
+		   JOIN element e ON e.id = he.element_id
+	       WHERE ho.project_id = $1
+	         AND e.billable = true
+	         AND ho.status = 'accepted'
+	         AND ho.accepted_at >= $2 AND ho.accepted_at <= $3
 	         AND NOT EXISTS (
 	             SELECT 1 FROM element_invoice_history h
-	             WHERE h.element_id = ps.element_id AND h.stage = 'handover'
+	             WHERE h.element_id = he.element_id AND h.stage = 'handover'
 	               AND h.work_order_id = $4
 	         )
 	`, projectID, start, end, workOrderID); err2 != nil {
@@ -1341,6 +1374,7 @@ func computeStagedAggregates(tx *sql.Tx, workOrderID int, projectID int, start,
 	}
 
 	// Aggregate by matching materials
+	// Elements are billed by the volume of the element type revision they carry
 	log.Printf("[invoice-cron] aggregating staged elements → materials")
 	rows, err := tx.Query(`
         SELECT wom.id AS material_id,
@@ -1349,10 +1383,12 @@ func computeStagedAggregates(tx *sql.Tx, workOrderID int, projectID int, start,
                COALESCE(wom.tax,0) AS tax,
                wom.hsn_code,
                tse.stage,
-               SUM(et.volume) AS total_volume
+               SUM(COALESCE(rv.volume, et.volume)) AS total_volume
         FROM tmp_stage_elements tse
         JOIN element e ON e.id = tse.element_id
         JOIN element_type et ON et.element_type_id = e.element_type_id AND et.project_id = $1
+        LEFT JOIN element_type_revision rv ON rv.element_type_id = e.element_type_id
+            AND rv.element_type_version = e.element_type_version
         JOIN work_order_material wom ON wom.work_order_id = $2
             AND LOWER(wom.item_name) = LOWER(et.element_type)
             AND (
@@ -1403,10 +1439,12 @@ func recordElementHistory(tx *sql.Tx, workOrderID int, invoiceID int, start, end
 	// Insert history rows for all elements and their stages used in this cycle
 	res, err := tx.Exec(`
         INSERT INTO element_invoice_history (work_order_id, element_id, stage, volume, period_start, period_end, invoice_id)
-        SELECT $1, tse.element_id, tse.stage, et.volume, $2, $3, $4
+        SELECT $1, tse.element_id, tse.stage, COALESCE(rv.volume, et.volume), $2, $3, $4
         FROM tmp_stage_elements tse
         JOIN element e ON e.id = tse.element_id
         JOIN element_type et ON et.element_type_id = e.element_type_id AND et.project_id = $5
+        LEFT JOIN element_type_revision rv ON rv.element_type_id = e.element_type_id
+            AND rv.element_type_version = e.element_type_version
     `, workOrderID, start, end, invoiceID, projectID)
 	if err == nil {
 		if rc, e2 := res.RowsAffected(); e2 == nil {
@@ -1579,6 +1617,9 @@ func buildSwaggerFromRoutes(engine *gin.Engine) gin.HandlerFunc {
 
 func main() {
 	db := storage.InitDB()
+	if err := storage.RunMigrations(db, migrationFiles()); err != nil {
+		log.Fatalf("Failed to apply database migrations: %v", err)
+	}
 	// Initialize GORM database
 	_ = storage.InitGormDB()
 
@@ -1602,6 +1643,24 @@ func main() {
 	// Set global FCM service for handlers
 	handlers.SetFCMService(fcmService)
 
+	// Initialize object storage for uploads (STORAGE_BACKEND=local|s3)
+	objectStore, err := services.NewObjectStoreFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize object storage: %v", err)
+	}
+	log.Printf("Object storage initialized (%s)", objectStore.Name())
+	handlers.SetObjectStore(objectStore)
+	if err := handlers.SetFileURLSigningKey(os.Getenv("FILE_URL_SIGNING_KEY")); err != nil {
+		log.Fatalf("Failed to initialize file URL signing: %v", err)
+	}
+
+	// Upload scanners (UPLOAD_SCANNERS=signature|none)
+	fileScanners, err := services.NewFileScannersFromEnv()
+	if err != nil {
+		log.Fatalf("Failed to initialize upload scanners: %v", err)
+	}
+	handlers.SetFileScanners(fileScanners)
+
 	// Setup cron job to run maintenance daily at 12:30 PM
 	c := cron.New(
 		cron.WithLogger(cron.VerbosePrintfLogger(log.New(os.Stdout, "cron: ", log.LstdFlags))),
@@ -1665,6 +1724,19 @@ func main() {
 			return ErectedHandler(db)
 		}, cronLogger)
 
+		safeGo(ctx, &wg, "TransmittalReplyReminders", func(ctx context.Context) error {
+			return handlers.SendOverdueTransmittalReminders(db, cronLogger)
+		}, cronLogger)
+
+		safeGo(ctx, &wg, "StockAgeingAlerts", func(ctx context.Context) error {
+			return handlers.SendStockAgeingAlerts(db, cronLogger)
+		}, cronLogger)
+
+		safeGo(ctx, &wg, "ImageDerivativeBackfill", func(ctx context.Context) error {
+			_, err := handlers.BackfillImageDerivatives(ctx, db, 500)
+			return err
+		}, cronLogger)
+
 		// ------------------ WAIT WITH CANCELLATION ------------------
 
 		done := make(chan struct{})
@@ -1705,6 +1777,7 @@ func main() {
 	r.GET("/api/project/:project_id/status", CheckProjectSuspension(db), handlers.GetProjectStatus(db))
 
 	r.POST("/api/solve", handlers.SolveHandler)
+	r.POST("/api/solve-1d", handlers.Solve1DHandler(db))
 
 	// ==================== 1. AUTH & LOGIN ====================
 	r.POST("/api/login", handlers.LoginHandler(db))
@@ -1762,6 +1835,14 @@ func main() {
 	r.GET("/api/drawing", handlers.GetAllDrawings(db))
 	r.GET("/api/drawing_fetch/:project_id", CheckProjectSuspension(db), handlers.GetDrawingsByProjectID(db))
 	r.GET("/api/drawing_get/:drawing_id", handlers.GetDrawingByDrawingID(db))
+	r.GET("/api/drawings/:drawing_id/revisions", handlers.GetDrawingRevisionReviews(db))
+	r.GET("/api/drawings/:drawing_id/revisions/diff", handlers.GetDrawingRevisionDiff(db))
+	r.POST("/api/drawings/:drawing_id/revisions/:version/comments", handlers.AddDrawingRevisionComment(db))
+	r.POST("/api/drawings/:drawing_id/revisions/:version/submit", handlers.SubmitDrawingRevision(db))
+	r.POST("/api/drawings/:drawing_id/revisions/:version/approve", handlers.ApproveDrawingRevision(db))
+	r.POST("/api/drawings/:drawing_id/revisions/:version/reject", handlers.RejectDrawingRevision(db))
+	r.POST("/api/drawings/:drawing_id/revisions/:version/issue", handlers.IssueDrawingRevision(db))
+	r.GET("/api/element_types/:element_type_id/drawing_release", handlers.GetElementTypeDrawingRelease(db))
 
 	// ==================== 7. DRAWING TYPES ====================
 	r.POST("/api/drawingtype_create", handlers.CreateDrawingType(db))
@@ -1855,8 +1936,14 @@ func main() {
 
 	// ==================== 16. FILE UPLOAD ====================
 	r.POST("/api/upload", handlers.UploadFile)
+	r.POST("/api/upload/:purpose", handlers.UploadFile)
 
 	r.GET("/api/get-file", handlers.ServeFile)
+	r.GET("/api/files/url", handlers.GetSignedFileURL(db))
+	r.POST("/api/files/derivatives/backfill", handlers.StartImageDerivativeBackfill(db))
+	r.GET("/api/uploads/policies", handlers.GetUploadPolicies)
+	r.GET("/api/uploads/quarantine", handlers.GetQuarantinedUploads(db))
+	r.DELETE("/api/uploads/quarantine/:id", handlers.DeleteQuarantinedUpload(db))
 
 	// ==================== 17. BOM PRODUCTS ====================
 	r.POST("/api/create_bom_products", handlers.CreateBOMProduct(db))
@@ -1927,6 +2014,12 @@ func main() {
 
 	// ==================== 26. JOB MANAGEMENT ====================
 	jobManager := handlers.NewGormJobManager()
+	// Imports run from the durable job queue, so a restart resumes them
+	jobQueue := handlers.NewJobQueue(db, 4)
+	jobManager.RegisterJobHandlers(jobQueue)
+	if err := jobQueue.Start(); err != nil {
+		log.Printf("Warning: job queue not started: %v", err)
+	}
 	r.POST("/api/project/:project_id/jobs", func(c *gin.Context) {
 		jobID, err := jobManager.CreateImportJobAndGetID(c, nil)
 		if err != nil {
@@ -2103,6 +2196,9 @@ func main() {
 		questionGroup.PUT("/update_paper/:paper_id", handlers.UpdateQuestions(db))
 		questionGroup.DELETE("/delete/:question_id", handlers.DeleteSingleQuestionHandler(db))
 		questionGroup.DELETE("/paper_dalete/:paper_id", handlers.DeletePaperAndQuestionsHandler(db))
+		questionGroup.PUT("/spec/:question_id", handlers.SaveQCQuestionSpec(db))
+		questionGroup.DELETE("/spec/:question_id", handlers.DeleteQCQuestionSpec(db))
+		questionGroup.GET("/results/:activity_id", handlers.GetQCInspectionResults(db))
 	}
 
 	// ==================== 45. RECTIFICATION ====================
@@ -2356,7 +2452,139 @@ func main() {
 
 	r.PUT("/api/projects/:project_id/assign-stockyard/:element_id", handlers.AssignElementToStockyard(db))
 
-	// ==================== 79. SWAGGER ====================
+	// ==================== 79. STOCK COUNTS ====================
+	r.POST("/api/stock-counts", handlers.CreateStockCount(db))
+	r.GET("/api/projects/:project_id/stock-counts", CheckProjectSuspension(db), handlers.GetStockCountsByProject(db))
+	r.GET("/api/stock-counts/reason-codes", handlers.GetStockCountReasonCodes)
+	r.GET("/api/stock-counts/:id", handlers.GetStockCount(db))
+	r.POST("/api/stock-counts/:id/entries", handlers.SubmitStockCountEntries(db))
+	r.PUT("/api/stock-counts/:id/review", handlers.CloseStockCountCounting(db))
+	r.POST("/api/stock-counts/:id/approve", handlers.ApproveStockCountVariances(db))
+	r.PUT("/api/stock-counts/:id/cancel", handlers.CancelStockCount(db))
+	r.GET("/api/stock-counts/:id/logs", handlers.GetStockCountLogs(db))
+
+	// ==================== 80. ELEMENT TYPE BOM VERSIONS ====================
+	r.GET("/api/element_type_bom_versions/:element_type_id", handlers.GetElementTypeBOMVersions(db))
+	r.GET("/api/element_type_bom_versions/:element_type_id/diff", handlers.GetElementTypeBOMVersionDiff(db))
+	r.GET("/api/element_bom_consumption/:element_id", handlers.GetElementBOMConsumption(db))
+
+	// ==================== 81. REBAR / BAR BENDING SCHEDULE ====================
+	r.GET("/api/rebar/shape-codes", handlers.GetRebarShapeCodes)
+	r.GET("/api/element_type_rebar/:element_type_id", handlers.GetElementTypeRebar(db))
+	r.PUT("/api/element_type_rebar/:element_type_id", handlers.SaveElementTypeRebar(db))
+	r.GET("/api/element_type_bbs/:element_type_id", handlers.GetElementTypeBBS(db))
+	r.GET("/api/projects/:project_id/bbs", CheckProjectSuspension(db), handlers.GetCastingBatchBBS(db))
+
+	// ==================== 82. CUTTING REMNANTS ====================
+	r.POST("/api/remnants", handlers.CreateRemnant(db))
+	r.PUT("/api/remnants/:id/scrap", handlers.ScrapRemnant(db))
+	r.GET("/api/projects/:project_id/remnants", CheckProjectSuspension(db), handlers.GetProjectRemnants(db))
+	r.GET("/api/projects/:project_id/remnants/savings", CheckProjectSuspension(db), handlers.GetRemnantSavingsReport(db))
+
+	// ==================== 83. DRAWING TRANSMITTALS ====================
+	r.POST("/api/transmittals", handlers.CreateTransmittal(db))
+	r.GET("/api/projects/:project_id/transmittals", CheckProjectSuspension(db), handlers.GetTransmittalsByProject(db))
+	r.GET("/api/transmittals/:id", handlers.GetTransmittal(db))
+	r.PUT("/api/transmittals/:id/reply", handlers.RecordTransmittalReply(db))
+	r.GET("/api/transmittals/:id/pdf", handlers.GetTransmittalPDF(db))
+
+	// ==================== 84. NON-CONFORMANCE REPORTS ====================
+	r.POST("/api/ncrs", handlers.CreateNCR(db))
+	r.GET("/api/projects/:project_id/ncrs", CheckProjectSuspension(db), handlers.GetNCRsByProject(db))
+	r.GET("/api/projects/:project_id/ncrs/register", CheckProjectSuspension(db), handlers.GetNCRRegister(db))
+	r.GET("/api/ncrs/:id", handlers.GetNCR(db))
+	r.PUT("/api/ncrs/:id/disposition", handlers.DispositionNCR(db))
+	r.PUT("/api/ncrs/:id/action-complete", handlers.CompleteNCRAction(db))
+	r.PUT("/api/ncrs/:id/close", handlers.CloseNCR(db))
+
+	// ==================== 85. CONCRETE CUBE TESTS ====================
+	r.POST("/api/concrete_samples", handlers.CreateConcreteSample(db))
+	r.GET("/api/projects/:project_id/concrete_samples", CheckProjectSuspension(db), handlers.GetConcreteSamplesByProject(db))
+	r.GET("/api/projects/:project_id/concrete_holds", CheckProjectSuspension(db), handlers.GetConcreteHoldsByProject(db))
+	r.GET("/api/concrete_samples/:id", handlers.GetConcreteSample(db))
+	r.PUT("/api/concrete_samples/:id/elements", handlers.UpdateConcreteSampleElements(db))
+	r.PUT("/api/concrete_samples/:id/tests/:age_days", handlers.RecordConcreteTestResult(db))
+	r.PUT("/api/concrete_samples/:id/release", handlers.ReleaseConcreteSample(db))
+
+	// ==================== 86. POURS ====================
+	r.POST("/api/pours", handlers.CreatePour(db))
+	r.GET("/api/projects/:project_id/pours", CheckProjectSuspension(db), handlers.GetPoursByProject(db))
+	r.GET("/api/projects/:project_id/pours/reconciliation", CheckProjectSuspension(db), handlers.GetConcreteReconciliation(db))
+	r.GET("/api/pours/:id", handlers.GetPour(db))
+	r.POST("/api/pours/:id/tickets", handlers.AddPourTickets(db))
+	r.PUT("/api/pours/:id/elements", handlers.MovePourElements(db))
+
+	// ==================== 87. MOULDS ====================
+	r.POST("/api/moulds", handlers.CreateMould(db))
+	r.GET("/api/projects/:project_id/moulds", CheckProjectSuspension(db), handlers.GetMouldsByProject(db))
+	r.GET("/api/projects/:project_id/moulds/utilisation", CheckProjectSuspension(db), handlers.GetMouldUtilisation(db))
+	r.GET("/api/moulds/:id", handlers.GetMould(db))
+	r.PUT("/api/moulds/:id", handlers.UpdateMould(db))
+	r.PUT("/api/moulds/:id/status", handlers.UpdateMouldStatus(db))
+	r.PUT("/api/activities/:activity_id/mould", handlers.AssignActivityMould(db))
+	r.DELETE("/api/activities/:activity_id/mould", handlers.UnassignActivityMould(db))
+
+	// ==================== 88. STOCKYARD SLOTS ====================
+	r.POST("/api/stockyards/:id/slots", handlers.CreateStockyardSlots(db))
+	r.GET("/api/stockyards/:id/slots", handlers.GetStockyardSlots(db))
+	r.PUT("/api/stockyard_slots/:id", handlers.UpdateStockyardSlot(db))
+	r.DELETE("/api/stockyard_slots/:id", handlers.DeleteStockyardSlot(db))
+	r.GET("/api/stockyard_slots/suggest", handlers.SuggestStockyardSlot(db))
+	r.POST("/api/stockyard_slots/put_away", handlers.PutAwayStock(db))
+	r.POST("/api/stockyard_slots/pick_list", handlers.GetStockPickList(db))
+
+	// ==================== 89. STOCK TRANSFERS ====================
+	r.POST("/api/stock_transfers", handlers.CreateStockTransfer(db))
+	r.GET("/api/projects/:project_id/stock_transfers", CheckProjectSuspension(db), handlers.GetStockTransfersByProject(db))
+	r.GET("/api/projects/:project_id/stock_transfers/in_transit", CheckProjectSuspension(db), handlers.GetInTransitStock(db))
+	r.GET("/api/stock_transfers/:id", handlers.GetStockTransfer(db))
+	r.POST("/api/stock_transfers/:id/in_transit", handlers.MarkStockTransferInTransit(db))
+	r.POST("/api/stock_transfers/:id/receive", handlers.ReceiveStockTransfer(db))
+	r.POST("/api/stock_transfers/:id/cancel", handlers.CancelStockTransfer(db))
+
+	// ==================== 90. STOCK AGEING ====================
+	r.GET("/api/stock_ageing", handlers.GetStockAgeingReport(db))
+	r.GET("/api/stockyards/:id/storage_rate", handlers.GetStockyardStorageRate(db))
+	r.PUT("/api/stockyards/:id/storage_rate", handlers.SetStockyardStorageRate(db))
+
+	// ==================== 91. ELEMENT LIFECYCLE ====================
+	r.GET("/api/elements/:id/lifecycle", handlers.GetElementLifecycle(db))
+	r.GET("/api/admin/element_lifecycle/check", handlers.CheckElementLifecycle(db))
+	r.POST("/api/admin/element_lifecycle/repair", handlers.RepairElementLifecycle(db))
+
+	// ==================== 92. HANDOVERS ====================
+	r.POST("/api/projects/:project_id/handovers", CheckProjectSuspension(db), handlers.CreateHandover(db))
+	r.GET("/api/projects/:project_id/handovers", CheckProjectSuspension(db), handlers.GetHandoversByProject(db))
+	r.GET("/api/handovers/:id", handlers.GetHandover(db))
+	r.POST("/api/handovers/:id/inspection", handlers.RecordHandoverInspection(db))
+	r.POST("/api/handovers/:id/snags/:snag_id/close", handlers.CloseHandoverSnag(db))
+	r.POST("/api/handovers/:id/accept", handlers.AcceptHandover(db))
+	r.GET("/api/handovers/:id/certificate", handlers.GetHandoverCertificate(db))
+
+	// ==================== 93. ELEMENT NAMING ====================
+	r.GET("/api/projects/:project_id/element_id_template", CheckProjectSuspension(db), handlers.GetElementIDTemplate(db))
+	r.PUT("/api/projects/:project_id/element_id_template", CheckProjectSuspension(db), handlers.SetElementIDTemplate(db))
+	r.POST("/api/projects/:project_id/element_id_template/preview", CheckProjectSuspension(db), handlers.PreviewElementIDs(db))
+	r.POST("/api/element_id_template/validate", handlers.ValidateElementIDTemplate(db))
+
+	// ==================== 94. HIERARCHY BULK OPERATIONS ====================
+	r.POST("/api/projects/:project_id/precast/generate", CheckProjectSuspension(db), handlers.GenerateHierarchy(db))
+	r.POST("/api/precast/:id/clone", handlers.CloneHierarchy(db))
+	r.POST("/api/precast/:id/move", handlers.MoveHierarchy(db))
+	r.DELETE("/api/precast/:id/subtree", handlers.DeleteHierarchy(db))
+
+	// ==================== 95. ELEMENT TYPE REVISIONS ====================
+	r.POST("/api/elementtype/:element_type_id/impact", handlers.GetElementTypeChangeImpact(db))
+	r.GET("/api/elementtype/:element_type_id/revisions", handlers.GetElementTypeRevisions(db))
+	r.POST("/api/elementtype/:element_type_id/revisions", handlers.ApplyElementTypeRevision(db))
+
+	// ==================== 96. JOB QUEUE ====================
+	r.GET("/api/job_queue", handlers.GetQueuedJobs(db))
+	r.GET("/api/job_queue/:id", handlers.GetQueuedJob(db))
+	r.POST("/api/job_queue/:id/cancel", handlers.CancelQueuedJob(db))
+	r.POST("/api/job_queue/:id/retry", handlers.RetryQueuedJob(db))
+
+	// ==================== 97. SWAGGER ====================
 	r.GET("/swagger/*any", func(c *gin.Context) {
 		// Handle specific routes first to avoid conflicts
 		if c.Param("any") == "/custom.css" {
@@ -2594,6 +2822,11 @@ func main() {
 		log.Printf("Warning: Job manager shutdown error: %v", err)
 	}
 
+	// Hand the queued jobs still running back to the queue
+	if err := jobQueue.Stop(5 * time.Second); err != nil {
+		log.Printf("Warning: Job queue shutdown error: %v", err)
+	}
+
 	// Shutdown HTTP server
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Fatalf("Server forced to shutdown: %v", err)
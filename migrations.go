@@ -0,0 +1,21 @@
+package main
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// The numbered migrations are applied at startup by storage.RunMigrations. The unnumbered
+// scripts in migrations/ predate the runner and are still run by hand.
+//
+//go:embed migrations/[0-9]*.sql
+var migrationScripts embed.FS
+
+// migrationFiles returns the numbered migrations with the directory stripped.
+func migrationFiles() fs.FS {
+	sub, err := fs.Sub(migrationScripts, "migrations")
+	if err != nil {
+		panic(err)
+	}
+	return sub
+}
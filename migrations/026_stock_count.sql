@@ -0,0 +1,54 @@
+-- Migration: Stock count (cycle count) tables
+
+CREATE TABLE IF NOT EXISTS stock_count (
+    id           SERIAL PRIMARY KEY,
+    project_id   INT NOT NULL,
+    warehouse_id INT NOT NULL,
+    title        TEXT NOT NULL DEFAULT '',
+    status       VARCHAR(20) NOT NULL DEFAULT 'open',
+    notes        TEXT NOT NULL DEFAULT '',
+    created_by   TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    frozen_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    closed_at    TIMESTAMPTZ
+);
+-- Only one count may be active per warehouse
+CREATE UNIQUE INDEX IF NOT EXISTS idx_stock_count_active_warehouse
+    ON stock_count (warehouse_id, project_id) WHERE status IN ('open', 'review');
+CREATE TABLE IF NOT EXISTS stock_count_item (
+    id             SERIAL PRIMARY KEY,
+    stock_count_id INT NOT NULL REFERENCES stock_count(id) ON DELETE CASCADE,
+    bom_id         INT NOT NULL,
+    bom_name       TEXT NOT NULL DEFAULT '',
+    system_qty     DOUBLE PRECISION NOT NULL DEFAULT 0,
+    counted_qty    DOUBLE PRECISION,
+    counters       INT NOT NULL DEFAULT 0,
+    disputed       BOOLEAN NOT NULL DEFAULT FALSE,
+    status         VARCHAR(20) NOT NULL DEFAULT 'pending',
+    reason_code    VARCHAR(40) NOT NULL DEFAULT '',
+    comments       TEXT NOT NULL DEFAULT '',
+    decided_by     TEXT NOT NULL DEFAULT '',
+    decided_at     TIMESTAMPTZ,
+    adjustment_id  INT,
+    UNIQUE (stock_count_id, bom_id)
+);
+CREATE TABLE IF NOT EXISTS stock_count_entry (
+    id             SERIAL PRIMARY KEY,
+    stock_count_id INT NOT NULL REFERENCES stock_count(id) ON DELETE CASCADE,
+    bom_id         INT NOT NULL,
+    counted_qty    DOUBLE PRECISION NOT NULL,
+    counted_by     INT NOT NULL,
+    counter_name   TEXT NOT NULL DEFAULT '',
+    source         VARCHAR(20) NOT NULL DEFAULT 'web',
+    counted_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_stock_count_entry_count ON stock_count_entry (stock_count_id, bom_id, counted_by);
+CREATE TABLE IF NOT EXISTS stock_count_log (
+    id             SERIAL PRIMARY KEY,
+    stock_count_id INT NOT NULL REFERENCES stock_count(id) ON DELETE CASCADE,
+    item_id        INT,
+    action         VARCHAR(30) NOT NULL,
+    acted_by       TEXT NOT NULL DEFAULT '',
+    comments       TEXT NOT NULL DEFAULT '',
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
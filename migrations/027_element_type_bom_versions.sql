@@ -0,0 +1,37 @@
+-- Migration: Element type BOM versions and per-element consumption
+
+CREATE TABLE IF NOT EXISTS element_type_bom_version (
+    id                   SERIAL PRIMARY KEY,
+    element_type_id      INT NOT NULL,
+    project_id           INT NOT NULL,
+    version_no           INT NOT NULL,
+    element_type_version TEXT NOT NULL DEFAULT '',
+    effective_from       TIMESTAMPTZ NOT NULL,
+    effective_to         TIMESTAMPTZ,
+    created_by           TEXT NOT NULL DEFAULT '',
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (element_type_id, version_no)
+);
+CREATE TABLE IF NOT EXISTS element_type_bom_version_item (
+    id             SERIAL PRIMARY KEY,
+    bom_version_id INT NOT NULL REFERENCES element_type_bom_version(id) ON DELETE CASCADE,
+    product_id     INT NOT NULL,
+    product_name   TEXT NOT NULL DEFAULT '',
+    quantity       DOUBLE PRECISION NOT NULL DEFAULT 0,
+    unit           TEXT NOT NULL DEFAULT '',
+    rate           DOUBLE PRECISION NOT NULL DEFAULT 0
+);
+CREATE TABLE IF NOT EXISTS element_bom_consumption (
+    id              SERIAL PRIMARY KEY,
+    element_id      INT NOT NULL,
+    element_type_id INT NOT NULL,
+    project_id      INT NOT NULL,
+    bom_version_id  INT NOT NULL REFERENCES element_type_bom_version(id),
+    product_id      INT NOT NULL,
+    product_name    TEXT NOT NULL DEFAULT '',
+    quantity        DOUBLE PRECISION NOT NULL DEFAULT 0,
+    unit            TEXT NOT NULL DEFAULT '',
+    cast_at         TIMESTAMPTZ NOT NULL,
+    UNIQUE (element_id, product_id)
+);
+CREATE INDEX IF NOT EXISTS idx_element_bom_consumption_project ON element_bom_consumption (project_id, cast_at);
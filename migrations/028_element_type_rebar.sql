@@ -0,0 +1,22 @@
+-- Migration: Rebar details of element types for bar bending schedules
+
+CREATE TABLE IF NOT EXISTS element_type_rebar (
+    id              SERIAL PRIMARY KEY,
+    element_type_id INT NOT NULL,
+    project_id      INT NOT NULL,
+    bar_mark        TEXT NOT NULL,
+    diameter_mm     INT NOT NULL,
+    shape_code      TEXT NOT NULL,
+    dim_a           DOUBLE PRECISION NOT NULL DEFAULT 0,
+    dim_b           DOUBLE PRECISION NOT NULL DEFAULT 0,
+    dim_c           DOUBLE PRECISION NOT NULL DEFAULT 0,
+    dim_d           DOUBLE PRECISION NOT NULL DEFAULT 0,
+    dim_e           DOUBLE PRECISION NOT NULL DEFAULT 0,
+    bar_count       INT NOT NULL,
+    remarks         TEXT NOT NULL DEFAULT '',
+    created_by      TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at      TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (element_type_id, bar_mark)
+);
+CREATE INDEX IF NOT EXISTS idx_element_type_rebar_project ON element_type_rebar (project_id);
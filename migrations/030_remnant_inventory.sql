@@ -0,0 +1,22 @@
+-- Migration: Remnant inventory of cut bars
+
+CREATE TABLE IF NOT EXISTS cutting_remnant (
+    id           SERIAL PRIMARY KEY,
+    project_id   INT NOT NULL,
+    kind         TEXT NOT NULL,
+    diameter_mm  INT NOT NULL DEFAULT 0,
+    material     TEXT NOT NULL DEFAULT '',
+    length       DOUBLE PRECISION NOT NULL DEFAULT 0,
+    width        DOUBLE PRECISION NOT NULL DEFAULT 0,
+    height       DOUBLE PRECISION NOT NULL DEFAULT 0,
+    location     TEXT NOT NULL DEFAULT '',
+    warehouse_id INT,
+    status       TEXT NOT NULL DEFAULT 'available',
+    source       TEXT NOT NULL DEFAULT '',
+    created_by   TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    reused_size  DOUBLE PRECISION NOT NULL DEFAULT 0,
+    consumed_by  TEXT NOT NULL DEFAULT '',
+    consumed_at  TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_cutting_remnant_project ON cutting_remnant (project_id, kind, status);
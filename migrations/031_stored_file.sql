@@ -0,0 +1,14 @@
+-- Migration: Object store file registry
+
+CREATE TABLE IF NOT EXISTS stored_file (
+    file_name     TEXT PRIMARY KEY,
+    sha256        TEXT NOT NULL,
+    storage_key   TEXT NOT NULL,
+    size          BIGINT NOT NULL DEFAULT 0,
+    content_type  TEXT NOT NULL DEFAULT '',
+    original_name TEXT NOT NULL DEFAULT '',
+    backend       TEXT NOT NULL DEFAULT 'local',
+    uploaded_by   TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_stored_file_sha256 ON stored_file (sha256);
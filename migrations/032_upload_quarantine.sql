@@ -0,0 +1,14 @@
+-- Migration: Quarantined uploads
+
+CREATE TABLE IF NOT EXISTS upload_quarantine (
+    id            SERIAL PRIMARY KEY,
+    original_name TEXT NOT NULL DEFAULT '',
+    purpose       TEXT NOT NULL DEFAULT '',
+    reason        TEXT NOT NULL,
+    detected_type TEXT NOT NULL DEFAULT '',
+    sha256        TEXT NOT NULL,
+    size          BIGINT NOT NULL DEFAULT 0,
+    storage_key   TEXT NOT NULL,
+    uploaded_by   TEXT NOT NULL DEFAULT '',
+    created_at    TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,13 @@
+-- Migration: Thumbnails and previews of stored images
+
+CREATE TABLE IF NOT EXISTS image_derivative (
+    source_key  TEXT NOT NULL,
+    variant     TEXT NOT NULL,
+    storage_key TEXT NOT NULL DEFAULT '',
+    width       INT NOT NULL DEFAULT 0,
+    height      INT NOT NULL DEFAULT 0,
+    size        BIGINT NOT NULL DEFAULT 0,
+    error       TEXT NOT NULL DEFAULT '',
+    created_at  TIMESTAMP NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (source_key, variant)
+);
@@ -0,0 +1,30 @@
+-- Migration: Drawing revision reviews
+
+CREATE TABLE IF NOT EXISTS drawing_revision_review (
+    id              SERIAL PRIMARY KEY,
+    drawing_id      INT NOT NULL,
+    version         TEXT NOT NULL,
+    project_id      INT NOT NULL DEFAULT 0,
+    element_type_id INT NOT NULL DEFAULT 0,
+    drawing_type_id INT NOT NULL DEFAULT 0,
+    file            TEXT NOT NULL DEFAULT '',
+    status          TEXT NOT NULL DEFAULT 'draft',
+    created_by      TEXT NOT NULL DEFAULT '',
+    created_at      TIMESTAMP NOT NULL DEFAULT NOW(),
+    submitted_by    TEXT NOT NULL DEFAULT '',
+    submitted_at    TIMESTAMP,
+    approved_by     TEXT NOT NULL DEFAULT '',
+    approved_at     TIMESTAMP,
+    issued_by       TEXT NOT NULL DEFAULT '',
+    issued_at       TIMESTAMP,
+    UNIQUE (drawing_id, version)
+);
+CREATE INDEX IF NOT EXISTS idx_drawing_revision_review_element_type ON drawing_revision_review (element_type_id);
+CREATE TABLE IF NOT EXISTS drawing_revision_comment (
+    id         SERIAL PRIMARY KEY,
+    review_id  INT NOT NULL REFERENCES drawing_revision_review(id) ON DELETE CASCADE,
+    action     TEXT NOT NULL DEFAULT 'comment',
+    comment    TEXT NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMP NOT NULL DEFAULT NOW()
+);
@@ -0,0 +1,35 @@
+-- Migration: Drawing transmittal register
+
+CREATE TABLE IF NOT EXISTS transmittal (
+    id                SERIAL PRIMARY KEY,
+    project_id        INT NOT NULL,
+    transmittal_no    TEXT NOT NULL,
+    recipient_type    VARCHAR(20) NOT NULL,
+    endclient_id      INT,
+    recipient_name    TEXT NOT NULL DEFAULT '',
+    recipient_email   TEXT NOT NULL DEFAULT '',
+    purpose           VARCHAR(30) NOT NULL,
+    remarks           TEXT NOT NULL DEFAULT '',
+    due_date          DATE,
+    reply_status      VARCHAR(30) NOT NULL DEFAULT 'pending',
+    reply_comments    TEXT NOT NULL DEFAULT '',
+    replied_at        TIMESTAMPTZ,
+    reply_recorded_by TEXT NOT NULL DEFAULT '',
+    issued_by         TEXT NOT NULL DEFAULT '',
+    issued_by_user_id INT NOT NULL DEFAULT 0,
+    issued_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_reminder_at  TIMESTAMPTZ,
+    reminder_count    INT NOT NULL DEFAULT 0,
+    UNIQUE (project_id, transmittal_no)
+);
+CREATE TABLE IF NOT EXISTS transmittal_item (
+    id                SERIAL PRIMARY KEY,
+    transmittal_id    INT NOT NULL REFERENCES transmittal(id) ON DELETE CASCADE,
+    drawing_id        INT NOT NULL,
+    version           TEXT NOT NULL DEFAULT '',
+    drawing_type_name TEXT NOT NULL DEFAULT '',
+    element_type_id   INT NOT NULL DEFAULT 0,
+    element_type      TEXT NOT NULL DEFAULT '',
+    file              TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_transmittal_item_drawing ON transmittal_item (drawing_id);
@@ -0,0 +1,48 @@
+-- Migration: Typed QC checklists
+
+CREATE TABLE IF NOT EXISTS qc_question_spec (
+    question_id     INT PRIMARY KEY,
+    answer_type     VARCHAR(20) NOT NULL DEFAULT 'choice',
+    severity        VARCHAR(20) NOT NULL DEFAULT 'minor',
+    unit            TEXT NOT NULL DEFAULT '',
+    reference_field VARCHAR(20) NOT NULL DEFAULT '',
+    target_value    DOUBLE PRECISION,
+    tolerance_minus DOUBLE PRECISION,
+    tolerance_plus  DOUBLE PRECISION,
+    min_value       DOUBLE PRECISION,
+    max_value       DOUBLE PRECISION,
+    expected_yes    BOOLEAN NOT NULL DEFAULT TRUE,
+    fail_options    TEXT[] NOT NULL DEFAULT '{}',
+    photo_required  BOOLEAN NOT NULL DEFAULT FALSE,
+    comment_on_fail BOOLEAN NOT NULL DEFAULT FALSE
+);
+CREATE TABLE IF NOT EXISTS qc_inspection_result (
+    id                SERIAL PRIMARY KEY,
+    activity_id       INT NOT NULL,
+    element_id        INT NOT NULL DEFAULT 0,
+    stage_id          INT NOT NULL DEFAULT 0,
+    paper_id          INT NOT NULL DEFAULT 0,
+    qc_id             INT NOT NULL DEFAULT 0,
+    result            VARCHAR(20) NOT NULL,
+    critical_failures INT NOT NULL DEFAULT 0,
+    minor_failures    INT NOT NULL DEFAULT 0,
+    status            TEXT NOT NULL DEFAULT '',
+    created_at        TIMESTAMP NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_qc_inspection_result_activity ON qc_inspection_result (activity_id);
+CREATE TABLE IF NOT EXISTS qc_answer_result (
+    id            SERIAL PRIMARY KEY,
+    inspection_id INT NOT NULL REFERENCES qc_inspection_result(id) ON DELETE CASCADE,
+    question_id   INT NOT NULL,
+    answer_type   VARCHAR(20) NOT NULL,
+    severity      VARCHAR(20) NOT NULL,
+    numeric_value DOUBLE PRECISION,
+    bool_value    BOOLEAN,
+    option_text   TEXT NOT NULL DEFAULT '',
+    expected_min  DOUBLE PRECISION,
+    expected_max  DOUBLE PRECISION,
+    passed        BOOLEAN NOT NULL,
+    reason        TEXT NOT NULL DEFAULT '',
+    comment       TEXT NOT NULL DEFAULT '',
+    image_path    TEXT NOT NULL DEFAULT ''
+);
@@ -0,0 +1,42 @@
+-- Migration: Non-conformance reports
+
+CREATE TABLE IF NOT EXISTS ncr (
+    id                  SERIAL PRIMARY KEY,
+    project_id          INT NOT NULL,
+    ncr_no              TEXT NOT NULL,
+    element_id          INT NOT NULL,
+    element_type_id     INT NOT NULL DEFAULT 0,
+    activity_id         INT,
+    stage_id            INT,
+    inspection_id       INT,
+    source              VARCHAR(20) NOT NULL,
+    title               TEXT NOT NULL,
+    description         TEXT NOT NULL DEFAULT '',
+    severity            VARCHAR(20) NOT NULL DEFAULT 'critical',
+    status              VARCHAR(20) NOT NULL DEFAULT 'open',
+    root_cause_category VARCHAR(30) NOT NULL DEFAULT '',
+    disposition         VARCHAR(20) NOT NULL DEFAULT '',
+    concession_ref      TEXT NOT NULL DEFAULT '',
+    corrective_action   TEXT NOT NULL DEFAULT '',
+    action_owner_id     INT,
+    action_due_date     DATE,
+    raised_by           TEXT NOT NULL DEFAULT '',
+    raised_at           TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    dispositioned_by    TEXT NOT NULL DEFAULT '',
+    dispositioned_at    TIMESTAMPTZ,
+    action_completed_by TEXT NOT NULL DEFAULT '',
+    action_completed_at TIMESTAMPTZ,
+    closed_by           TEXT NOT NULL DEFAULT '',
+    closed_by_user_id   INT,
+    closed_at           TIMESTAMPTZ,
+    UNIQUE (project_id, ncr_no)
+);
+CREATE INDEX IF NOT EXISTS idx_ncr_element ON ncr (element_id);
+CREATE TABLE IF NOT EXISTS ncr_event (
+    id         SERIAL PRIMARY KEY,
+    ncr_id     INT NOT NULL REFERENCES ncr(id) ON DELETE CASCADE,
+    action     VARCHAR(30) NOT NULL,
+    comments   TEXT NOT NULL DEFAULT '',
+    acted_by   TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
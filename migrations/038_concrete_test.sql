@@ -0,0 +1,43 @@
+-- Migration: Concrete cube test register
+
+CREATE TABLE IF NOT EXISTS concrete_sample (
+    id                   SERIAL PRIMARY KEY,
+    project_id           INT NOT NULL,
+    sample_no            TEXT NOT NULL,
+    pour_ref             TEXT NOT NULL,
+    grade                TEXT NOT NULL,
+    specimen_type        VARCHAR(10) NOT NULL DEFAULT 'cube',
+    characteristic_mpa   DOUBLE PRECISION NOT NULL,
+    release_strength_mpa DOUBLE PRECISION NOT NULL,
+    specimens_per_age    INT NOT NULL DEFAULT 3,
+    cast_at              TIMESTAMPTZ NOT NULL,
+    status               VARCHAR(20) NOT NULL DEFAULT 'testing',
+    released_at          TIMESTAMPTZ,
+    released_by          TEXT NOT NULL DEFAULT '',
+    release_note         TEXT NOT NULL DEFAULT '',
+    remarks              TEXT NOT NULL DEFAULT '',
+    created_by           TEXT NOT NULL DEFAULT '',
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (project_id, sample_no)
+);
+CREATE TABLE IF NOT EXISTS concrete_sample_element (
+    sample_id  INT NOT NULL REFERENCES concrete_sample(id) ON DELETE CASCADE,
+    element_id INT NOT NULL,
+    PRIMARY KEY (sample_id, element_id)
+);
+CREATE INDEX IF NOT EXISTS idx_concrete_sample_element ON concrete_sample_element (element_id);
+CREATE TABLE IF NOT EXISTS concrete_test (
+    id            SERIAL PRIMARY KEY,
+    sample_id     INT NOT NULL REFERENCES concrete_sample(id) ON DELETE CASCADE,
+    age_days      INT NOT NULL,
+    due_date      DATE NOT NULL,
+    strengths_mpa DOUBLE PRECISION[] NOT NULL DEFAULT '{}',
+    mean_mpa      DOUBLE PRECISION,
+    target_mpa    DOUBLE PRECISION NOT NULL DEFAULT 0,
+    result        VARCHAR(10) NOT NULL DEFAULT 'pending',
+    reason        TEXT NOT NULL DEFAULT '',
+    tested_at     TIMESTAMPTZ,
+    tested_by     TEXT NOT NULL DEFAULT '',
+    remarks       TEXT NOT NULL DEFAULT '',
+    UNIQUE (sample_id, age_days)
+);
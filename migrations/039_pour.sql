@@ -0,0 +1,34 @@
+-- Migration: Pour / batch records
+
+CREATE TABLE IF NOT EXISTS pour (
+    id         SERIAL PRIMARY KEY,
+    project_id INT NOT NULL,
+    pour_no    TEXT NOT NULL,
+    pour_date  DATE NOT NULL,
+    bed        TEXT NOT NULL DEFAULT '',
+    grade      TEXT NOT NULL DEFAULT '',
+    remarks    TEXT NOT NULL DEFAULT '',
+    created_by TEXT NOT NULL DEFAULT '',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (project_id, pour_no)
+);
+CREATE INDEX IF NOT EXISTS idx_pour_project_date ON pour (project_id, pour_date);
+CREATE TABLE IF NOT EXISTS pour_element (
+    element_id  INT PRIMARY KEY,
+    pour_id     INT NOT NULL REFERENCES pour(id) ON DELETE CASCADE,
+    activity_id INT,
+    volume_m3   DOUBLE PRECISION NOT NULL DEFAULT 0,
+    added_at    TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_pour_element_pour ON pour_element (pour_id);
+CREATE TABLE IF NOT EXISTS pour_ticket (
+    id           SERIAL PRIMARY KEY,
+    pour_id      INT NOT NULL REFERENCES pour(id) ON DELETE CASCADE,
+    ticket_no    TEXT NOT NULL,
+    volume_m3    DOUBLE PRECISION NOT NULL,
+    grade        TEXT NOT NULL DEFAULT '',
+    delivered_at TIMESTAMPTZ,
+    created_by   TEXT NOT NULL DEFAULT '',
+    created_at   TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (pour_id, ticket_no)
+);
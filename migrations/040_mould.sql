@@ -0,0 +1,44 @@
+-- Migration: Mould / casting bed registry
+
+CREATE TABLE IF NOT EXISTS mould (
+    id                       SERIAL PRIMARY KEY,
+    project_id               INT NOT NULL,
+    code                     TEXT NOT NULL,
+    name                     TEXT NOT NULL DEFAULT '',
+    length_mm                DOUBLE PRECISION NOT NULL DEFAULT 0,
+    width_mm                 DOUBLE PRECISION NOT NULL DEFAULT 0,
+    height_mm                DOUBLE PRECISION NOT NULL DEFAULT 0,
+    element_type_ids         INT[] NOT NULL DEFAULT '{}',
+    location                 TEXT NOT NULL DEFAULT '',
+    status                   VARCHAR(20) NOT NULL DEFAULT 'available',
+    reuse_count              INT NOT NULL DEFAULT 0,
+    cycles_since_maintenance INT NOT NULL DEFAULT 0,
+    maintenance_threshold    INT NOT NULL DEFAULT 0,
+    last_maintained_at       TIMESTAMPTZ,
+    current_activity_id      INT,
+    remarks                  TEXT NOT NULL DEFAULT '',
+    created_by               TEXT NOT NULL DEFAULT '',
+    created_at               TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at               TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (project_id, code)
+);
+CREATE TABLE IF NOT EXISTS mould_cycle (
+    id          SERIAL PRIMARY KEY,
+    mould_id    INT NOT NULL REFERENCES mould(id) ON DELETE CASCADE,
+    activity_id INT NOT NULL UNIQUE,
+    element_id  INT NOT NULL,
+    assigned_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    assigned_by TEXT NOT NULL DEFAULT '',
+    cast_at     TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_mould_cycle_mould ON mould_cycle (mould_id, cast_at);
+CREATE TABLE IF NOT EXISTS mould_status_log (
+    id          SERIAL PRIMARY KEY,
+    mould_id    INT NOT NULL REFERENCES mould(id) ON DELETE CASCADE,
+    status      VARCHAR(20) NOT NULL,
+    activity_id INT,
+    note        TEXT NOT NULL DEFAULT '',
+    changed_by  TEXT NOT NULL DEFAULT '',
+    changed_at  TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_mould_status_log ON mould_status_log (mould_id, changed_at);
@@ -0,0 +1,27 @@
+-- Migration: Stockyard slot map
+
+CREATE TABLE IF NOT EXISTS stockyard_slot (
+    id            SERIAL PRIMARY KEY,
+    stockyard_id  INT NOT NULL,
+    zone          TEXT NOT NULL,
+    row_label     TEXT NOT NULL,
+    bay           INT NOT NULL,
+    code          TEXT NOT NULL,
+    max_weight_kg DOUBLE PRECISION NOT NULL DEFAULT 0,
+    max_stack     INT NOT NULL DEFAULT 1,
+    length_mm     DOUBLE PRECISION NOT NULL DEFAULT 0,
+    width_mm      DOUBLE PRECISION NOT NULL DEFAULT 0,
+    active        BOOLEAN NOT NULL DEFAULT TRUE,
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (stockyard_id, code)
+);
+CREATE TABLE IF NOT EXISTS stock_slot_placement (
+    precast_stock_id  INT PRIMARY KEY,
+    slot_id           INT REFERENCES stockyard_slot(id) ON DELETE SET NULL,
+    tier              INT NOT NULL DEFAULT 0,
+    needed_by         DATE,
+    suggested_slot_id INT,
+    placed_at         TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    placed_by         TEXT NOT NULL DEFAULT ''
+);
+CREATE INDEX IF NOT EXISTS idx_stock_slot_placement_slot ON stock_slot_placement (slot_id);
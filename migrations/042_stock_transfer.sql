@@ -0,0 +1,29 @@
+-- Migration: Stock transfers between warehouses
+
+CREATE TABLE IF NOT EXISTS stock_transfer (
+    id                SERIAL PRIMARY KEY,
+    project_id        INT NOT NULL,
+    transfer_no       TEXT NOT NULL UNIQUE,
+    from_stockyard_id INT NOT NULL,
+    to_stockyard_id   INT NOT NULL,
+    vehicle_id        INT,
+    driver_name       TEXT NOT NULL DEFAULT '',
+    status            TEXT NOT NULL,
+    remarks           TEXT NOT NULL DEFAULT '',
+    created_by        TEXT NOT NULL DEFAULT '',
+    dispatched_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    in_transit_at     TIMESTAMPTZ,
+    received_at       TIMESTAMPTZ,
+    cancelled_at      TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_stock_transfer_project ON stock_transfer (project_id, status);
+CREATE TABLE IF NOT EXISTS stock_transfer_item (
+    transfer_id      INT NOT NULL REFERENCES stock_transfer(id) ON DELETE CASCADE,
+    precast_stock_id INT NOT NULL,
+    element_id       INT NOT NULL,
+    status           TEXT NOT NULL,
+    closed_at        TIMESTAMPTZ,
+    closed_by        TEXT NOT NULL DEFAULT '',
+    PRIMARY KEY (transfer_id, precast_stock_id)
+);
+CREATE INDEX IF NOT EXISTS idx_stock_transfer_item_stock ON stock_transfer_item (precast_stock_id, status);
@@ -0,0 +1,17 @@
+-- Migration: Stock ageing
+
+CREATE TABLE IF NOT EXISTS stockyard_storage_rate (
+    stockyard_id       INT PRIMARY KEY,
+    rate_per_piece_day DOUBLE PRECISION NOT NULL DEFAULT 0,
+    rate_per_tonne_day DOUBLE PRECISION NOT NULL DEFAULT 0,
+    currency           TEXT NOT NULL DEFAULT '',
+    alert_after_days   INT NOT NULL DEFAULT 90,
+    updated_by         TEXT NOT NULL DEFAULT '',
+    updated_at         TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE TABLE IF NOT EXISTS stock_ageing_alert (
+    precast_stock_id INT NOT NULL,
+    stockyard_id     INT NOT NULL,
+    alerted_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (precast_stock_id, stockyard_id)
+);
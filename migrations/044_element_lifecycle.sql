@@ -0,0 +1,20 @@
+-- Migration: Element lifecycle
+
+CREATE TABLE IF NOT EXISTS element_lifecycle (
+    element_id INT PRIMARY KEY,
+    project_id INT NOT NULL,
+    state      TEXT NOT NULL,
+    changed_by TEXT NOT NULL DEFAULT '',
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_element_lifecycle_project ON element_lifecycle (project_id, state);
+CREATE TABLE IF NOT EXISTS element_lifecycle_log (
+    id         SERIAL PRIMARY KEY,
+    element_id INT NOT NULL,
+    from_state TEXT NOT NULL DEFAULT '',
+    to_state   TEXT NOT NULL,
+    source     TEXT NOT NULL DEFAULT '',
+    changed_by TEXT NOT NULL DEFAULT '',
+    changed_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE INDEX IF NOT EXISTS idx_element_lifecycle_log_element ON element_lifecycle_log (element_id, changed_at);
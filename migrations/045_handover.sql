@@ -0,0 +1,40 @@
+-- Migration: Floor handovers
+
+CREATE TABLE IF NOT EXISTS handover (
+    id                   SERIAL PRIMARY KEY,
+    project_id           INT NOT NULL,
+    certificate_no       TEXT NOT NULL,
+    floor_id             INT NOT NULL,
+    status               TEXT NOT NULL,
+    remarks              TEXT NOT NULL DEFAULT '',
+    created_by           TEXT NOT NULL DEFAULT '',
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    inspected_by         TEXT NOT NULL DEFAULT '',
+    inspected_at         TIMESTAMPTZ,
+    accepted_by          TEXT NOT NULL DEFAULT '',
+    accepted_designation TEXT NOT NULL DEFAULT '',
+    accepted_at          TIMESTAMPTZ,
+    recorded_by          TEXT NOT NULL DEFAULT '',
+    signature            BYTEA,
+    signature_type       TEXT NOT NULL DEFAULT '',
+    certificate_digest   TEXT NOT NULL DEFAULT '',
+    UNIQUE (project_id, certificate_no)
+);
+CREATE INDEX IF NOT EXISTS idx_handover_project ON handover (project_id, status);
+CREATE TABLE IF NOT EXISTS handover_element (
+    handover_id INT NOT NULL REFERENCES handover(id) ON DELETE CASCADE,
+    element_id  INT NOT NULL UNIQUE,
+    PRIMARY KEY (handover_id, element_id)
+);
+CREATE TABLE IF NOT EXISTS handover_snag (
+    id              SERIAL PRIMARY KEY,
+    handover_id     INT NOT NULL REFERENCES handover(id) ON DELETE CASCADE,
+    element_id      INT,
+    description     TEXT NOT NULL,
+    status          TEXT NOT NULL DEFAULT 'open',
+    raised_by       TEXT NOT NULL DEFAULT '',
+    raised_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    closed_by       TEXT NOT NULL DEFAULT '',
+    closed_at       TIMESTAMPTZ,
+    closing_remarks TEXT NOT NULL DEFAULT ''
+);
@@ -0,0 +1,14 @@
+-- Migration: Element ID templates and sequences
+
+CREATE TABLE IF NOT EXISTS element_id_template (
+    project_id INT PRIMARY KEY,
+    template   TEXT NOT NULL,
+    updated_by TEXT NOT NULL DEFAULT '',
+    updated_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+CREATE TABLE IF NOT EXISTS element_id_sequence (
+    project_id INT NOT NULL,
+    scope      TEXT NOT NULL,
+    last_value BIGINT NOT NULL,
+    PRIMARY KEY (project_id, scope)
+);
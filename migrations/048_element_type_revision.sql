@@ -0,0 +1,20 @@
+-- Migration: Applied element type revisions
+
+CREATE TABLE IF NOT EXISTS element_type_revision (
+    id                   SERIAL PRIMARY KEY,
+    element_type_id      INT NOT NULL,
+    project_id           INT NOT NULL,
+    element_type_version TEXT NOT NULL,
+    thickness            DOUBLE PRECISION NOT NULL DEFAULT 0,
+    length               DOUBLE PRECISION NOT NULL DEFAULT 0,
+    height               DOUBLE PRECISION NOT NULL DEFAULT 0,
+    width                DOUBLE PRECISION NOT NULL DEFAULT 0,
+    volume               DOUBLE PRECISION NOT NULL DEFAULT 0,
+    mass                 DOUBLE PRECISION NOT NULL DEFAULT 0,
+    area                 DOUBLE PRECISION NOT NULL DEFAULT 0,
+    density              DOUBLE PRECISION NOT NULL DEFAULT 0,
+    comments             TEXT NOT NULL DEFAULT '',
+    created_by           TEXT NOT NULL DEFAULT '',
+    created_at           TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    UNIQUE (element_type_id, element_type_version)
+);
@@ -0,0 +1,27 @@
+-- Migration: Durable job queue
+
+CREATE TABLE IF NOT EXISTS job_queue (
+    id               BIGSERIAL PRIMARY KEY,
+    kind             TEXT NOT NULL,
+    project_id       INT NOT NULL DEFAULT 0,
+    status           TEXT NOT NULL DEFAULT 'queued',
+    attempts         INT NOT NULL DEFAULT 0,
+    max_attempts     INT NOT NULL DEFAULT 3,
+    run_after        TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    lease_owner      TEXT,
+    lease_expires_at TIMESTAMPTZ,
+    heartbeat_at     TIMESTAMPTZ,
+    cancel_requested BOOLEAN NOT NULL DEFAULT FALSE,
+    import_job_id    INT,
+    payload          JSONB NOT NULL DEFAULT '{}',
+    checkpoint       JSONB,
+    last_error       TEXT NOT NULL DEFAULT '',
+    created_by       TEXT NOT NULL DEFAULT '',
+    created_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    updated_at       TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    started_at       TIMESTAMPTZ,
+    finished_at      TIMESTAMPTZ
+);
+CREATE INDEX IF NOT EXISTS idx_job_queue_ready ON job_queue (status, run_after);
+CREATE INDEX IF NOT EXISTS idx_job_queue_project ON job_queue (project_id, created_at);
+CREATE INDEX IF NOT EXISTS idx_job_queue_import_job ON job_queue (import_job_id);
@@ -0,0 +1,61 @@
+package models
+
+import "time"
+
+// ElementTypeBOMVersion is an immutable snapshot of an element type's BOM.
+// A version is in force from EffectiveFrom until EffectiveTo (nil while it is current).
+type ElementTypeBOMVersion struct {
+	ID                 int                         `json:"id" example:"1"`
+	ElementTypeID      int                         `json:"element_type_id" example:"1"`
+	ProjectID          int                         `json:"project_id" example:"1"`
+	VersionNo          int                         `json:"version_no" example:"2"`
+	ElementTypeVersion string                      `json:"element_type_version" example:"RV-02"`
+	EffectiveFrom      time.Time                   `json:"effective_from" example:"2024-01-15T10:30:00Z"`
+	EffectiveTo        *time.Time                  `json:"effective_to"`
+	CreatedBy          string                      `json:"created_by" example:"admin"`
+	CreatedAt          time.Time                   `json:"created_at" example:"2024-01-15T10:30:00Z"`
+	ElementsCast       int                         `json:"elements_cast" example:"12"`
+	Items              []ElementTypeBOMVersionItem `json:"items"`
+}
+
+// ElementTypeBOMVersionItem is one product line of a BOM version
+type ElementTypeBOMVersionItem struct {
+	ProductID   int     `json:"product_id" example:"1"`
+	ProductName string  `json:"product_name" example:"TMT 12mm"`
+	Quantity    float64 `json:"quantity" example:"45.5"`
+	Unit        string  `json:"unit" example:"kg"`
+	Rate        float64 `json:"rate" example:"62.5"`
+}
+
+// BOMVersionDiffLine describes how one product changed between two BOM versions
+type BOMVersionDiffLine struct {
+	ProductID    int     `json:"product_id" example:"1"`
+	ProductName  string  `json:"product_name" example:"TMT 12mm"`
+	Change       string  `json:"change" example:"changed"` // added, removed, changed, unchanged
+	FromQuantity float64 `json:"from_quantity" example:"40"`
+	ToQuantity   float64 `json:"to_quantity" example:"45.5"`
+	Delta        float64 `json:"delta" example:"5.5"`
+	FromUnit     string  `json:"from_unit,omitempty" example:"kg"`
+	ToUnit       string  `json:"to_unit,omitempty" example:"kg"`
+}
+
+// BOMVersionDiff is the response of the BOM version diff endpoint
+type BOMVersionDiff struct {
+	ElementTypeID int                   `json:"element_type_id" example:"1"`
+	From          ElementTypeBOMVersion `json:"from"`
+	To            ElementTypeBOMVersion `json:"to"`
+	Lines         []BOMVersionDiffLine  `json:"lines"`
+}
+
+// ElementBOMConsumption is material consumed by one cast element, recorded against the BOM version in force at casting
+type ElementBOMConsumption struct {
+	ElementID     int       `json:"element_id" example:"1"`
+	ElementTypeID int       `json:"element_type_id" example:"1"`
+	BOMVersionID  int       `json:"bom_version_id" example:"1"`
+	VersionNo     int       `json:"version_no" example:"2"`
+	ProductID     int       `json:"product_id" example:"1"`
+	ProductName   string    `json:"product_name" example:"TMT 12mm"`
+	Quantity      float64   `json:"quantity" example:"45.5"`
+	Unit          string    `json:"unit" example:"kg"`
+	CastAt        time.Time `json:"cast_at" example:"2024-01-15T10:30:00Z"`
+}
@@ -0,0 +1,131 @@
+package models
+
+import (
+	"fmt"
+	"time"
+)
+
+// Concrete sample statuses. Elements cast from a sample in testing or failed are held in the stockyard.
+const (
+	ConcreteSampleTesting  = "testing"  // release strength not reached yet
+	ConcreteSampleReleased = "released" // release strength reached, 28-day result pending
+	ConcreteSamplePassed   = "passed"   // 28-day strength meets the grade
+	ConcreteSampleFailed   = "failed"   // 28-day strength below the grade
+)
+
+// Concrete test results
+const (
+	ConcreteTestPending = "pending"
+	ConcreteTestPass    = "pass"
+	ConcreteTestFail    = "fail"
+)
+
+// Concrete specimen types
+const (
+	ConcreteSpecimenCube     = "cube"
+	ConcreteSpecimenCylinder = "cylinder"
+)
+
+// DefaultConcreteTestAges are the ages in days tests are scheduled at when none are given
+var DefaultConcreteTestAges = []int{1, 3, 7, 28}
+
+// ConcreteSample is a set of test specimens taken from one pour or batch
+type ConcreteSample struct {
+	ID                 int             `json:"id" example:"1"`
+	ProjectID          int             `json:"project_id" example:"1"`
+	SampleNo           string          `json:"sample_no" example:"CS-0001"`
+	PourRef            string          `json:"pour_ref" example:"BATCH-2024-0115-02"`
+	Grade              string          `json:"grade" example:"M40"`
+	SpecimenType       string          `json:"specimen_type" example:"cube"`
+	CharacteristicMPa  float64         `json:"characteristic_strength_mpa" example:"40"`
+	ReleaseStrengthMPa float64         `json:"release_strength_mpa" example:"28"`
+	SpecimensPerAge    int             `json:"specimens_per_age" example:"3"`
+	CastAt             time.Time       `json:"cast_at" example:"2024-01-15T10:30:00Z"`
+	Status             string          `json:"status" example:"testing"`
+	ReleasedAt         *time.Time      `json:"released_at,omitempty"`
+	ReleasedBy         string          `json:"released_by,omitempty" example:"system"`
+	ReleaseNote        string          `json:"release_note,omitempty" example:""`
+	Remarks            string          `json:"remarks" example:""`
+	CreatedBy          string          `json:"created_by" example:"qc1"`
+	CreatedAt          time.Time       `json:"created_at" example:"2024-01-15T10:30:00Z"`
+	ElementCount       int             `json:"element_count" example:"6"`
+	NextTestDue        *time.Time      `json:"next_test_due,omitempty"`
+	LatestStrengthMPa  *float64        `json:"latest_strength_mpa,omitempty" example:"31.5"`
+	Tests              []ConcreteTest  `json:"tests,omitempty"`
+	Elements           []SampleElement `json:"elements,omitempty"`
+}
+
+// ConcreteTest is the crushing test of a sample at one age
+type ConcreteTest struct {
+	ID             int        `json:"id" example:"1"`
+	SampleID       int        `json:"sample_id" example:"1"`
+	AgeDays        int        `json:"age_days" example:"7"`
+	DueDate        time.Time  `json:"due_date" example:"2024-01-22T00:00:00Z"`
+	StrengthsMPa   []float64  `json:"strengths_mpa" example:"27.5,28.1,26.9"`
+	MeanMPa        *float64   `json:"mean_mpa,omitempty" example:"27.5"`
+	TargetMPa      float64    `json:"target_mpa" example:"26"` // expected strength at this age
+	PercentOfGrade *float64   `json:"percent_of_grade,omitempty" example:"68.75"`
+	Result         string     `json:"result" example:"pass"`
+	Reason         string     `json:"reason,omitempty" example:""`
+	TestedAt       *time.Time `json:"tested_at,omitempty"`
+	TestedBy       string     `json:"tested_by,omitempty" example:"lab1"`
+	Remarks        string     `json:"remarks" example:""`
+}
+
+// SampleElement is an element cast from a sampled pour
+type SampleElement struct {
+	ElementID   int    `json:"element_id" example:"1024"`
+	ElementName string `json:"element_name" example:"C1-01"`
+}
+
+// CreateConcreteSampleRequest registers a sample and schedules its tests
+type CreateConcreteSampleRequest struct {
+	ProjectID          int     `json:"project_id" binding:"required" example:"1"`
+	PourRef            string  `json:"pour_ref" binding:"required" example:"BATCH-2024-0115-02"`
+	Grade              string  `json:"grade" binding:"required" example:"M40"`
+	SpecimenType       string  `json:"specimen_type" example:"cube"`
+	CharacteristicMPa  float64 `json:"characteristic_strength_mpa" example:"40"` // derived from the grade when omitted
+	ReleaseStrengthMPa float64 `json:"release_strength_mpa" example:"28"`        // 70% of the grade when omitted
+	SpecimensPerAge    int     `json:"specimens_per_age" example:"3"`
+	CastAt             string  `json:"cast_at" example:"2024-01-15T10:30:00Z"` // RFC3339, defaults to now
+	TestAges           []int   `json:"test_ages" example:"1,3,7,28"`
+	ElementIDs         []int   `json:"element_ids" example:"1024,1025"` // defaults to the elements of a recorded pour named by pour_ref
+	Remarks            string  `json:"remarks" example:""`
+}
+
+// SampleElementsRequest replaces the elements linked to a sample
+type SampleElementsRequest struct {
+	ElementIDs []int `json:"element_ids" binding:"required" example:"1024,1025"`
+}
+
+// ConcreteTestResultRequest enters the crushing strengths of one test age
+type ConcreteTestResultRequest struct {
+	StrengthsMPa []float64 `json:"strengths_mpa" binding:"required" example:"27.5,28.1,26.9"`
+	TestedAt     string    `json:"tested_at" example:"2024-01-22T09:00:00Z"` // RFC3339, defaults to now
+	Remarks      string    `json:"remarks" example:""`
+}
+
+// ConcreteReleaseRequest releases a sample's elements without the release strength
+type ConcreteReleaseRequest struct {
+	Reason string `json:"reason" binding:"required" example:"Released under concession CON-021"`
+}
+
+// ConcreteHold is an element held in the stockyard by an unreleased sample
+type ConcreteHold struct {
+	ElementID          int      `json:"element_id" example:"1024"`
+	ElementName        string   `json:"element_name" example:"C1-01"`
+	SampleID           int      `json:"sample_id" example:"1"`
+	SampleNo           string   `json:"sample_no" example:"CS-0001"`
+	Status             string   `json:"status" example:"testing"`
+	ReleaseStrengthMPa float64  `json:"release_strength_mpa" example:"28"`
+	LatestStrengthMPa  *float64 `json:"latest_strength_mpa,omitempty" example:"21.4"`
+}
+
+// ErrConcreteStrengthHold is returned when elements held by an unreleased sample are moved
+type ErrConcreteStrengthHold struct {
+	Holds []ConcreteHold
+}
+
+func (e *ErrConcreteStrengthHold) Error() string {
+	return fmt.Sprintf("%d elements have not reached their release strength", len(e.Holds))
+}
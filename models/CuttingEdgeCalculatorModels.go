@@ -10,6 +10,15 @@ type Request struct {
 	StockSheets          []StockSheet  `json:"stock_sheets"`
 	Panels               []PanelDemand `json:"panels"`
 	Mode                 string        `json:"mode"` // "2d" or "3d"
+
+	// Remnant inventory (needs project_id and a session)
+	ProjectID        int     `json:"project_id"`
+	Material         string  `json:"material"`           // remnants are matched on material
+	UseRemnants      bool    `json:"use_remnants"`       // try available remnants before stock sheets
+	SaveRemnants     bool    `json:"save_remnants"`      // consume used remnants and store new leftovers
+	MinRemnantWidth  float64 `json:"min_remnant_width"`  // smaller leftovers are not stored, defaults to 300
+	MinRemnantHeight float64 `json:"min_remnant_height"` // defaults to 300
+	RemnantLocation  string  `json:"remnant_location"`
 }
 
 type StockSheet struct {
@@ -49,6 +58,9 @@ type Response struct {
 	// Visualization outputs for frontend:
 	Placements []PlacementOut `json:"placements"`
 	Leftovers  []LeftoverOut  `json:"leftovers"`
+
+	ConsumedRemnants []int `json:"consumed_remnants,omitempty"`
+	SavedRemnants    []int `json:"saved_remnants,omitempty"`
 }
 
 type UsedStockSheet struct {
@@ -121,3 +133,91 @@ type LeftoverOut struct {
 	Width   float64 `json:"width"`
 	Height  float64 `json:"height"`
 }
+
+// ---------- 1D cutting stock (rebar) ----------
+type Request1D struct {
+	OptimizationPriority string      `json:"optimization_priority"` // "least_waste" (default) or "least_bars"
+	CutThickness         float64     `json:"cut_thickness"`         // kerf per cut, mm
+	MinUsableOffcut      float64     `json:"min_usable_offcut"`     // shorter leftovers are scrap, defaults to 1000 mm
+	StockBars            []StockBar  `json:"stock_bars"`
+	Offcuts              []StockBar  `json:"offcuts"` // remnants cut before any new stock bar
+	Cuts                 []CutDemand `json:"cuts"`
+
+	// Optional demand sources, added to Cuts
+	ElementTypeID int   `json:"element_type_id"` // bar bending schedule of this element type
+	Elements      int   `json:"elements"`        // elements to cut for, defaults to 1
+	TaskIDs       []int `json:"task_ids"`        // unfinished elements of these tasks
+
+	// Remnant inventory (needs project_id and a session)
+	ProjectID       int    `json:"project_id"`
+	UseRemnants     bool   `json:"use_remnants"`  // add available remnants to offcuts
+	SaveRemnants    bool   `json:"save_remnants"` // consume used remnants and store reusable leftovers
+	RemnantLocation string `json:"remnant_location"`
+}
+
+type StockBar struct {
+	Length     float64 `json:"length"`
+	DiameterMM int     `json:"diameter_mm"` // 0 => any diameter
+	Qty        int     `json:"qty"`         // 0 => unlimited for stock bars
+	ID         string  `json:"id,omitempty"`
+}
+
+type CutDemand struct {
+	Length     float64 `json:"length"`
+	DiameterMM int     `json:"diameter_mm"`
+	Qty        int     `json:"qty"`
+	ID         string  `json:"id,omitempty"` // e.g. bar mark
+}
+
+type Response1D struct {
+	GeneratedAt           time.Time          `json:"generated_at"`
+	OptimizationPriority  string             `json:"optimization_priority"`
+	CutThickness          float64            `json:"cut_thickness"`
+	TotalRequestedCuts    int                `json:"total_requested_cuts"`
+	TotalCuts             int                `json:"total_cuts"`
+	StockBarsUsed         int                `json:"stock_bars_used"`
+	OffcutsUsed           int                `json:"offcuts_used"`
+	TotalStockLength      float64            `json:"total_stock_length"`
+	TotalCutLength        float64            `json:"total_cut_length"`
+	TotalKerfLoss         float64            `json:"total_kerf_loss"`
+	TotalReusableLeftover float64            `json:"total_reusable_leftover"`
+	TotalScrap            float64            `json:"total_scrap"`
+	WastePercent          float64            `json:"waste_percent"`
+	Patterns              []CuttingPattern   `json:"patterns"`
+	Leftovers             []LeftoverPiece    `json:"leftovers"`
+	UnableToFit           []UnableToFitCut1D `json:"unable_to_fit"`
+	ConsumedRemnants      []int              `json:"consumed_remnants,omitempty"`
+	SavedRemnants         []int              `json:"saved_remnants,omitempty"`
+}
+
+type CuttingPattern struct {
+	DiameterMM       int            `json:"diameter_mm"`
+	StockLength      float64        `json:"stock_length"`
+	FromOffcut       bool           `json:"from_offcut"`
+	StockID          string         `json:"stock_id,omitempty"`
+	Repeat           int            `json:"repeat"`
+	Pieces           []PatternPiece `json:"pieces"`
+	UsedLength       float64        `json:"used_length"`
+	KerfLoss         float64        `json:"kerf_loss"`
+	Leftover         float64        `json:"leftover"`
+	LeftoverReusable bool           `json:"leftover_reusable"`
+}
+
+type PatternPiece struct {
+	CutID  string  `json:"cut_id"`
+	Length float64 `json:"length"`
+	Qty    int     `json:"qty"`
+}
+
+type LeftoverPiece struct {
+	DiameterMM int     `json:"diameter_mm"`
+	Length     float64 `json:"length"`
+	Qty        int     `json:"qty"`
+	Reusable   bool    `json:"reusable"`
+}
+
+type UnableToFitCut1D struct {
+	Cut    CutDemand `json:"cut"`
+	Qty    int       `json:"qty"`
+	Reason string    `json:"reason"`
+}
@@ -0,0 +1,74 @@
+package models
+
+import "time"
+
+// Drawing revision lifecycle: draft -> submitted -> approved -> issued. A rejected submission
+// goes back to draft. Versions that predate the workflow are reported as legacy.
+const (
+	DrawingRevisionDraft     = "draft"
+	DrawingRevisionSubmitted = "submitted"
+	DrawingRevisionApproved  = "approved"
+	DrawingRevisionIssued    = "issued"
+	DrawingRevisionLegacy    = "legacy"
+)
+
+// DrawingRevisionReview is the review state of one version of a drawing
+type DrawingRevisionReview struct {
+	ID              int                      `json:"id" example:"1"`
+	DrawingID       int                      `json:"drawing_id" example:"1"`
+	ProjectID       int                      `json:"project_id" example:"1"`
+	ElementTypeID   int                      `json:"element_type_id" example:"1"`
+	DrawingTypeID   int                      `json:"drawing_type_id" example:"1"`
+	DrawingTypeName string                   `json:"drawing_type_name" example:"GA"`
+	Version         string                   `json:"version" example:"RV-02"`
+	File            string                   `json:"file" example:"9f86d081884c7d659a2feaa0c55ad015.pdf"`
+	Status          string                   `json:"status" example:"submitted"`
+	IsCurrent       bool                     `json:"is_current" example:"true"`
+	CreatedBy       string                   `json:"created_by" example:"admin"`
+	CreatedAt       time.Time                `json:"created_at" example:"2024-01-15T10:30:00Z"`
+	SubmittedBy     string                   `json:"submitted_by,omitempty" example:"designer"`
+	SubmittedAt     *time.Time               `json:"submitted_at,omitempty"`
+	ApprovedBy      string                   `json:"approved_by,omitempty" example:"lead"`
+	ApprovedAt      *time.Time               `json:"approved_at,omitempty"`
+	IssuedBy        string                   `json:"issued_by,omitempty" example:"lead"`
+	IssuedAt        *time.Time               `json:"issued_at,omitempty"`
+	Comments        []DrawingRevisionComment `json:"comments,omitempty"`
+}
+
+// DrawingRevisionComment is a review comment or a recorded lifecycle action on a revision
+type DrawingRevisionComment struct {
+	ID        int       `json:"id" example:"1"`
+	ReviewID  int       `json:"review_id" example:"1"`
+	Action    string    `json:"action" example:"comment"` // comment, submit, approve, reject, issue
+	Comment   string    `json:"comment" example:"Cover to links is 25, should be 30"`
+	CreatedBy string    `json:"created_by" example:"lead"`
+	CreatedAt time.Time `json:"created_at" example:"2024-01-15T10:30:00Z"`
+}
+
+// DrawingRevisionActionRequest carries the optional comment of a lifecycle action
+type DrawingRevisionActionRequest struct {
+	Comment string `json:"comment" example:"Ready for review"`
+}
+
+// DrawingRevisionFieldChange is one field that differs between two revisions
+type DrawingRevisionFieldChange struct {
+	Field string `json:"field" example:"file"`
+	From  string `json:"from" example:"a.pdf"`
+	To    string `json:"to" example:"b.pdf"`
+}
+
+// DrawingRevisionDiff compares two versions of a drawing
+type DrawingRevisionDiff struct {
+	DrawingID   int                          `json:"drawing_id" example:"1"`
+	From        DrawingRevisionReview        `json:"from"`
+	To          DrawingRevisionReview        `json:"to"`
+	Changes     []DrawingRevisionFieldChange `json:"changes"`
+	SameContent *bool                        `json:"same_content,omitempty"` // nil when either file is not content-addressed
+}
+
+// DrawingReleaseStatus tells whether an element type's drawings allow casting
+type DrawingReleaseStatus struct {
+	ElementTypeID int                     `json:"element_type_id" example:"1"`
+	Released      bool                    `json:"released" example:"false"`
+	Blocking      []DrawingRevisionReview `json:"blocking"`
+}
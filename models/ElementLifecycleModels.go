@@ -0,0 +1,91 @@
+package models
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Element lifecycle states, in order. An element only moves one state forward at a time.
+const (
+	LifecyclePlanned      = "planned"
+	LifecycleInProduction = "in_production"
+	LifecycleQCPassed     = "qc_passed"
+	LifecycleInYard       = "in_yard"
+	LifecycleDispatched   = "dispatched"
+	LifecycleReceived     = "received"
+	LifecycleErected      = "erected"
+	LifecycleHandedOver   = "handed_over"
+)
+
+// LifecycleStates lists the lifecycle states in order
+var LifecycleStates = []string{
+	LifecyclePlanned, LifecycleInProduction, LifecycleQCPassed, LifecycleInYard,
+	LifecycleDispatched, LifecycleReceived, LifecycleErected, LifecycleHandedOver,
+}
+
+// ElementStateConflict is an element that can't make a requested transition
+type ElementStateConflict struct {
+	ElementID int    `json:"element_id" example:"2001"`
+	State     string `json:"state" example:"in_yard"`
+}
+
+// ErrIllegalTransition is returned when elements can't move to the requested state from the state
+// they are in. No element is moved.
+type ErrIllegalTransition struct {
+	To       string
+	Elements []ElementStateConflict
+}
+
+func (e *ErrIllegalTransition) Error() string {
+	parts := make([]string, 0, len(e.Elements))
+	for _, el := range e.Elements {
+		parts = append(parts, fmt.Sprintf("%d (%s)", el.ElementID, el.State))
+	}
+	return fmt.Sprintf("elements can't move to %s: %s", e.To, strings.Join(parts, ", "))
+}
+
+// ElementLifecycleLog is a state change of an element
+type ElementLifecycleLog struct {
+	FromState string    `json:"from_state" example:"in_yard"` // empty when first recorded from existing data
+	ToState   string    `json:"to_state" example:"dispatched"`
+	Source    string    `json:"source" example:"Dispatch order"`
+	ChangedBy string    `json:"changed_by" example:"yard1"`
+	ChangedAt time.Time `json:"changed_at" example:"2024-01-15T10:30:00Z"`
+}
+
+// ElementLifecycle is the lifecycle state of an element with its history
+type ElementLifecycle struct {
+	ElementID    int                   `json:"element_id" example:"2001"`
+	ProjectID    int                   `json:"project_id" example:"1"`
+	State        string                `json:"state" example:"dispatched"`
+	StoredState  string                `json:"stored_state" example:"dispatched"`  // as last recorded, empty if never
+	DerivedState string                `json:"derived_state" example:"dispatched"` // as the stock and erection tables show
+	History      []ElementLifecycleLog `json:"history"`
+}
+
+// LifecycleIssue is an element whose stock, erection and status rows disagree
+type LifecycleIssue struct {
+	ElementID    int      `json:"element_id" example:"2001"`
+	ElementName  string   `json:"element_name" example:"C1-01"`
+	StoredState  string   `json:"stored_state" example:"in_yard"`
+	DerivedState string   `json:"derived_state" example:"received"`
+	TargetState  string   `json:"target_state" example:"received"` // the state a repair sets
+	Problems     []string `json:"problems"`
+	Repairable   bool     `json:"repairable" example:"true"`
+}
+
+// LifecycleCheckReport is the result of a consistency check or repair of a project
+type LifecycleCheckReport struct {
+	ProjectID    int              `json:"project_id" example:"1"`
+	Checked      int              `json:"checked" example:"1200"`
+	Inconsistent int              `json:"inconsistent" example:"14"`
+	Repaired     int              `json:"repaired" example:"0"`
+	Issues       []LifecycleIssue `json:"issues"`
+}
+
+// LifecycleRepairRequest repairs the inconsistent elements of a project, or only those given
+type LifecycleRepairRequest struct {
+	ProjectID  int   `json:"project_id" binding:"required" example:"1"`
+	ElementIDs []int `json:"element_ids" example:"2001,2002"`
+}
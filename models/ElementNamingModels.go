@@ -0,0 +1,55 @@
+package models
+
+import "time"
+
+// DefaultElementIDTemplate is the element ID scheme of a project without a template of its own
+const DefaultElementIDTemplate = "{type:upper}/{naming}/{seq:4}"
+
+// ElementIDToken describes a token of the element ID template language
+type ElementIDToken struct {
+	Token       string `json:"token" example:"{floor}"`
+	Description string `json:"description" example:"Prefix of the element's hierarchy level, e.g. F03"`
+}
+
+// ElementIDTemplate is the element ID marking scheme of a project
+type ElementIDTemplate struct {
+	ProjectID int              `json:"project_id" example:"1"`
+	Template  string           `json:"template" example:"{project}-{tower}-{floor}-{type}{seq:3}"`
+	IsDefault bool             `json:"is_default" example:"false"` // the project has no template of its own
+	UpdatedBy string           `json:"updated_by,omitempty" example:"admin"`
+	UpdatedAt *time.Time       `json:"updated_at,omitempty"`
+	Tokens    []ElementIDToken `json:"tokens"`
+}
+
+// SetElementIDTemplateRequest sets the element ID template of a project
+type SetElementIDTemplateRequest struct {
+	Template string `json:"template" binding:"required" example:"{project}-{tower}-{floor}-{type}{seq:3}"`
+}
+
+// ValidateElementIDTemplateRequest checks a template without saving it
+type ValidateElementIDTemplateRequest struct {
+	Template string `json:"template" binding:"required" example:"{project}-{tower}-{floor}-{type}{seq:3}"`
+}
+
+// ElementIDTemplateValidation is the result of checking a template
+type ElementIDTemplateValidation struct {
+	Template string   `json:"template" example:"{project}-{tower}-{floor}-{type}{seq:3}"`
+	Valid    bool     `json:"valid" example:"true"`
+	Errors   []string `json:"errors"`
+	Warnings []string `json:"warnings"` // valid but probably not intended
+}
+
+// ElementIDPreviewRequest previews the next element IDs of an element type on a hierarchy level
+type ElementIDPreviewRequest struct {
+	Template      string `json:"template" example:""` // empty for the project's template
+	ElementTypeID int    `json:"element_type_id" binding:"required" example:"1"`
+	HierarchyID   int    `json:"hierarchy_id" binding:"required" example:"12"`
+	Count         int    `json:"count" example:"5"` // default 5, at most 50
+}
+
+// ElementIDPreview is the element IDs the next elements would get. Nothing is reserved.
+type ElementIDPreview struct {
+	ElementIDTemplateValidation
+	NextSequence int      `json:"next_sequence" example:"14"`
+	ElementIDs   []string `json:"element_ids"`
+}
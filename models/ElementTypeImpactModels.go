@@ -0,0 +1,120 @@
+package models
+
+import "time"
+
+// ElementTypeChange is a proposed change of an element type. Dimensions left at 0 and an empty
+// product list keep what the element type has now.
+type ElementTypeChange struct {
+	Thickness float64   `json:"thickness" example:"150.0"`
+	Length    float64   `json:"length" example:"3200.0"`
+	Height    float64   `json:"height" example:"400.0"`
+	Width     float64   `json:"width" example:"200.0"`
+	Volume    float64   `json:"volume" example:"0.192"`
+	Mass      float64   `json:"mass" example:"480.0"`
+	Area      float64   `json:"area" example:"1.28"`
+	Density   float64   `json:"density" example:"2500"`
+	Products  []Product `json:"products"`
+	Drawings  []int     `json:"drawing_type_ids"` // drawing types being revised; the drawings themselves are uploaded as before
+	Comments  string    `json:"comments" example:"Beam lengthened for the revised grid"`
+}
+
+// ElementTypeDimensionChange is one dimension the change alters
+type ElementTypeDimensionChange struct {
+	Field string  `json:"field" example:"length"`
+	From  float64 `json:"from" example:"3000"`
+	To    float64 `json:"to" example:"3200"`
+}
+
+// ElementTypeImpactState counts the elements of a lifecycle state and says what the revision does to them
+type ElementTypeImpactState struct {
+	State      string `json:"state" example:"qc_passed"`
+	Elements   int    `json:"elements" example:"14"`
+	GetsChange bool   `json:"gets_change" example:"false"` // false: the elements keep their current revision
+}
+
+// ElementTypeInventoryDelta is the change in material needed for the elements that get the revision
+type ElementTypeInventoryDelta struct {
+	BOMVersionDiffLine
+	Elements       int     `json:"elements" example:"30"`          // elements not yet cast
+	RequiredBefore float64 `json:"required_before" example:"1200"` // for those elements under the current BOM
+	RequiredAfter  float64 `json:"required_after" example:"1365"`  // under the proposed BOM
+	RequiredDelta  float64 `json:"required_delta" example:"165"`
+	OnHand         float64 `json:"on_hand" example:"900"`   // project stock
+	Shortfall      float64 `json:"shortfall" example:"465"` // required after less on hand, if positive
+}
+
+// ElementTypeImpactTask is an open production task that has to be re-issued
+type ElementTypeImpactTask struct {
+	ActivityID  int    `json:"activity_id" example:"501"`
+	ElementID   int    `json:"element_id" example:"2001"`
+	ElementName string `json:"element_name" example:"B1-01"`
+	StageID     int    `json:"stage_id" example:"3"`
+	StageName   string `json:"stage_name" example:"Reinforcement"`
+	Status      string `json:"status" example:"Inprogress"`
+	AssignedTo  int    `json:"assigned_to" example:"12"`
+}
+
+// ElementTypeInvoiceImpact is the change in the amount still to be invoiced for one work order line
+// and stage
+type ElementTypeInvoiceImpact struct {
+	WorkOrderID   int     `json:"work_order_id" example:"7"`
+	WONumber      string  `json:"wo_number" example:"WO-2024-007"`
+	MaterialID    int     `json:"material_id" example:"31"`
+	ItemName      string  `json:"item_name" example:"B1"`
+	Stage         string  `json:"stage" example:"erection"`
+	Percentage    float64 `json:"percentage" example:"25"`
+	Elements      int     `json:"elements" example:"30"` // elements that get the revision and are not billed for the stage
+	CurrentAmount float64 `json:"current_amount" example:"81000"`
+	NewAmount     float64 `json:"new_amount" example:"86400"`
+	Delta         float64 `json:"delta" example:"5400"`
+	// DeltaInPlace is the change if every unbilled element took the new volume, as an in-place update does
+	DeltaInPlace float64 `json:"delta_in_place" example:"9000"`
+}
+
+// ElementTypeImpact is the what-if report of a proposed element type change
+type ElementTypeImpact struct {
+	ElementTypeID       int                          `json:"element_type_id" example:"1"`
+	ProjectID           int                          `json:"project_id" example:"1"`
+	CurrentVersion      string                       `json:"current_version" example:"RV-02"`
+	NewVersion          string                       `json:"new_version" example:"RV-03"`
+	Dimensions          []ElementTypeDimensionChange `json:"dimensions"`
+	BOMChanged          bool                         `json:"bom_changed" example:"true"`
+	DrawingsChanged     []int                        `json:"drawing_type_ids"`
+	States              []ElementTypeImpactState     `json:"states"`
+	ElementsChanged     int                          `json:"elements_changed" example:"30"` // planned and in production
+	ElementsKept        int                          `json:"elements_kept" example:"70"`    // cast and later
+	Inventory           []ElementTypeInventoryDelta  `json:"inventory"`
+	TasksToReissue      []ElementTypeImpactTask      `json:"tasks_to_reissue"`
+	Invoices            []ElementTypeInvoiceImpact   `json:"invoices"`
+	InvoiceDelta        float64                      `json:"invoice_delta" example:"5400"`
+	InvoiceDeltaInPlace float64                      `json:"invoice_delta_in_place" example:"9000"`
+}
+
+// ElementTypeVersionDimensions is the dimensions an element type had at one of its versions
+type ElementTypeVersionDimensions struct {
+	ElementTypeID      int       `json:"element_type_id" example:"1"`
+	ElementTypeVersion string    `json:"element_type_version" example:"RV-02"`
+	Thickness          float64   `json:"thickness" example:"150.0"`
+	Length             float64   `json:"length" example:"3000.0"`
+	Height             float64   `json:"height" example:"400.0"`
+	Width              float64   `json:"width" example:"200.0"`
+	Volume             float64   `json:"volume" example:"0.18"`
+	Mass               float64   `json:"mass" example:"450.0"`
+	Area               float64   `json:"area" example:"1.2"`
+	Density            float64   `json:"density" example:"2500"`
+	Elements           int       `json:"elements" example:"70"` // elements carrying this version
+	Comments           string    `json:"comments" example:""`
+	CreatedBy          string    `json:"created_by" example:"admin"`
+	CreatedAt          time.Time `json:"created_at" example:"2024-01-15T10:30:00Z"`
+}
+
+// ElementTypeRevisionResult is the response of applying a revision
+type ElementTypeRevisionResult struct {
+	ElementTypeID   int    `json:"element_type_id" example:"1"`
+	PreviousVersion string `json:"previous_version" example:"RV-02"`
+	NewVersion      string `json:"new_version" example:"RV-03"`
+	ElementsChanged int    `json:"elements_changed" example:"30"`
+	ElementsKept    int    `json:"elements_kept" example:"70"`
+	TasksReissued   int    `json:"tasks_reissued" example:"4"`
+	BOMVersionID    int    `json:"bom_version_id" example:"9"`
+}
@@ -0,0 +1,95 @@
+package models
+
+import "time"
+
+// Handover statuses
+const (
+	HandoverStatusInspection = "inspection" // raised, awaiting the client's inspection
+	HandoverStatusSnagging   = "snagging"   // inspected, snags open
+	HandoverStatusReady      = "ready"      // inspected, no open snags, awaiting acceptance
+	HandoverStatusAccepted   = "accepted"   // accepted and certified
+)
+
+// Snag statuses
+const (
+	SnagStatusOpen   = "open"
+	SnagStatusClosed = "closed"
+)
+
+// Handover is the client acceptance of the erected elements of one floor
+type Handover struct {
+	ID                  int               `json:"id" example:"1"`
+	ProjectID           int               `json:"project_id" example:"1"`
+	ProjectName         string            `json:"project_name,omitempty" example:"Tower A"`
+	CertificateNo       string            `json:"certificate_no" example:"HO-0001"`
+	FloorID             int               `json:"floor_id" example:"12"`
+	FloorName           string            `json:"floor_name" example:"Floor 3"`
+	TowerName           string            `json:"tower_name" example:"Tower A"`
+	Status              string            `json:"status" example:"snagging"`
+	Remarks             string            `json:"remarks" example:""`
+	CreatedBy           string            `json:"created_by" example:"site1"`
+	CreatedAt           time.Time         `json:"created_at" example:"2024-01-15T10:30:00Z"`
+	InspectedBy         string            `json:"inspected_by,omitempty" example:"R. Mehta"`
+	InspectedAt         *time.Time        `json:"inspected_at,omitempty"`
+	AcceptedBy          string            `json:"accepted_by,omitempty" example:"R. Mehta"`
+	AcceptedDesignation string            `json:"accepted_designation,omitempty" example:"Client Engineer"`
+	AcceptedAt          *time.Time        `json:"accepted_at,omitempty"`
+	RecordedBy          string            `json:"recorded_by,omitempty" example:"site1"` // user who recorded the acceptance
+	CertificateDigest   string            `json:"certificate_digest,omitempty" example:"9f2c…"`
+	ElementCount        int               `json:"element_count" example:"24"`
+	OpenSnags           int               `json:"open_snags" example:"2"`
+	Elements            []HandoverElement `json:"elements,omitempty"`
+	Snags               []HandoverSnag    `json:"snags,omitempty"`
+}
+
+// HandoverElement is an erected element on a handover
+type HandoverElement struct {
+	ElementID   int    `json:"element_id" example:"2001"`
+	ElementName string `json:"element_name" example:"C1-01"`
+	ElementType string `json:"element_type" example:"Column"`
+}
+
+// HandoverSnag is a defect the client found on inspection that must be put right before acceptance
+type HandoverSnag struct {
+	ID             int        `json:"id" example:"1"`
+	ElementID      *int       `json:"element_id,omitempty" example:"2001"` // empty for a snag on the floor as a whole
+	ElementName    string     `json:"element_name,omitempty" example:"C1-01"`
+	Description    string     `json:"description" example:"Chipped corner at grid B2"`
+	Status         string     `json:"status" example:"open"`
+	RaisedBy       string     `json:"raised_by" example:"R. Mehta"`
+	RaisedAt       time.Time  `json:"raised_at" example:"2024-01-16T10:30:00Z"`
+	ClosedBy       string     `json:"closed_by,omitempty" example:"site1"`
+	ClosedAt       *time.Time `json:"closed_at,omitempty"`
+	ClosingRemarks string     `json:"closing_remarks,omitempty" example:"Patched and cured"`
+}
+
+// CreateHandoverRequest offers the erected elements of a floor for acceptance
+type CreateHandoverRequest struct {
+	FloorID int    `json:"floor_id" binding:"required" example:"12"`
+	Remarks string `json:"remarks" example:""`
+}
+
+// HandoverSnagInput is a snag raised on inspection
+type HandoverSnagInput struct {
+	ElementID   int    `json:"element_id" example:"2001"` // 0 for the floor as a whole
+	Description string `json:"description" binding:"required" example:"Chipped corner at grid B2"`
+}
+
+// HandoverInspectionRequest records the client's inspection of a handover with the snags found
+type HandoverInspectionRequest struct {
+	InspectedBy string              `json:"inspected_by" binding:"required" example:"R. Mehta"`
+	Remarks     string              `json:"remarks" example:""`
+	Snags       []HandoverSnagInput `json:"snags"`
+}
+
+// CloseSnagRequest records that a snag was put right
+type CloseSnagRequest struct {
+	Remarks string `json:"remarks" binding:"required" example:"Patched and cured"`
+}
+
+// AcceptHandoverRequest records the client engineer's acceptance with their signature
+type AcceptHandoverRequest struct {
+	AcceptedBy  string `json:"accepted_by" binding:"required" example:"R. Mehta"`
+	Designation string `json:"designation" example:"Client Engineer"`
+	Signature   string `json:"signature" binding:"required" example:"data:image/png;base64,iVBORw0KGgo…"` // PNG or JPEG, base64
+}
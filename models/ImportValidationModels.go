@@ -0,0 +1,49 @@
+package models
+
+// Problems an import validation reports
+const (
+	ImportIssueFile               = "file"                   // the workbook itself can't be read as an import
+	ImportIssueMissingValue       = "missing_value"          // a required cell or column is empty
+	ImportIssueNonNumeric         = "non_numeric"            // a dimension or quantity that isn't a number
+	ImportIssueDuplicateElement   = "duplicate_element_type" // repeated in the sheet or already in the project
+	ImportIssueDuplicateRecord    = "duplicate_record"       // a BOM product or precast ID repeated in the file or already stored
+	ImportIssueUnknownHierarchy   = "unknown_hierarchy"
+	ImportIssueMissingDrawingType = "missing_drawing_type"
+	ImportIssueUnknownStage       = "unknown_stage"
+	ImportIssueUnknownProduct     = "unknown_product" // BOM product not in the product master
+	ImportIssueNoHierarchy        = "no_hierarchy"    // a row that would create an element type without elements
+)
+
+// Severities of an import validation issue
+const (
+	ImportIssueError   = "error"   // the import would skip or misread the value
+	ImportIssueWarning = "warning" // the import goes ahead, but probably not as meant
+)
+
+// ImportValidationIssue is a problem with one cell, or with the whole row when Column is empty
+type ImportValidationIssue struct {
+	Row      int    `json:"row" example:"12"` // sheet row, 1-based as Excel shows it; 0 for the whole file
+	Column   string `json:"column,omitempty" example:"N"`
+	Cell     string `json:"cell,omitempty" example:"N12"`
+	Header   string `json:"header,omitempty" example:"Tower A_Floor 3"`
+	Value    string `json:"value,omitempty" example:"4"`
+	Code     string `json:"code" example:"unknown_hierarchy"`
+	Severity string `json:"severity" example:"error"`
+	Message  string `json:"message" example:"Hierarchy \"Tower_A.Floor_3\" is not in the project"`
+}
+
+// ImportValidationReport is the result of a dry run of an import: what it would create and every
+// problem found, without changing anything
+type ImportValidationReport struct {
+	ProjectID      int                     `json:"project_id" example:"1"`
+	FileName       string                  `json:"file_name" example:"element_types.xlsx"`
+	Sheet          string                  `json:"sheet" example:"Element Types"`
+	Rows           int                     `json:"rows" example:"2000"`           // data rows, headers and sample row excluded
+	ValidRows      int                     `json:"valid_rows" example:"1968"`     // rows without errors
+	RowsWithErrors int                     `json:"rows_with_errors" example:"32"` // rows with at least one error
+	ElementTypes   int                     `json:"element_types" example:"2000"`  // element types the import would create
+	Errors         int                     `json:"errors" example:"41"`
+	Warnings       int                     `json:"warnings" example:"3"`
+	Valid          bool                    `json:"valid" example:"false"` // no errors
+	Issues         []ImportValidationIssue `json:"issues"`
+}
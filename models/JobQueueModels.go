@@ -0,0 +1,41 @@
+package models
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Statuses of a queued job
+const (
+	JobStatusQueued    = "queued"
+	JobStatusRunning   = "running"
+	JobStatusCompleted = "completed"
+	JobStatusFailed    = "failed"
+	JobStatusCancelled = "cancelled"
+)
+
+// QueuedJob is a long running task in the durable job queue. A server leases a job while it runs it and
+// keeps the lease alive with heartbeats; a job whose lease runs out is picked up again and resumes from
+// its last checkpoint.
+type QueuedJob struct {
+	ID              int64           `json:"id" example:"42"`
+	Kind            string          `json:"kind" example:"element_type_excel_import"`
+	ProjectID       int             `json:"project_id" example:"1"`
+	Status          string          `json:"status" example:"running"`
+	Attempts        int             `json:"attempts" example:"1"`
+	MaxAttempts     int             `json:"max_attempts" example:"3"`
+	RunAfter        time.Time       `json:"run_after"` // not before this time, set by retry backoff
+	LeaseOwner      string          `json:"lease_owner,omitempty" example:"api-1:4121"`
+	LeaseExpiresAt  *time.Time      `json:"lease_expires_at,omitempty"`
+	HeartbeatAt     *time.Time      `json:"heartbeat_at,omitempty"`
+	CancelRequested bool            `json:"cancel_requested" example:"false"`
+	ImportJobID     *int            `json:"import_job_id,omitempty" example:"318"` // import_jobs row the job reports to
+	Payload         json.RawMessage `json:"payload"`
+	Checkpoint      json.RawMessage `json:"checkpoint,omitempty"`
+	LastError       string          `json:"last_error,omitempty" example:""`
+	CreatedBy       string          `json:"created_by" example:"admin"`
+	CreatedAt       time.Time       `json:"created_at"`
+	UpdatedAt       time.Time       `json:"updated_at"`
+	StartedAt       *time.Time      `json:"started_at,omitempty"`
+	FinishedAt      *time.Time      `json:"finished_at,omitempty"`
+}
@@ -0,0 +1,129 @@
+package models
+
+import "time"
+
+// Mould statuses. Moulds are put in use by assigning them to a casting activity and go to cleaning
+// once the casting is completed.
+const (
+	MouldAvailable = "available"
+	MouldInUse     = "in_use"
+	MouldCleaning  = "cleaning"
+	MouldRepair    = "repair"
+)
+
+// Mould is a mould or casting bed of a project
+type Mould struct {
+	ID                     int              `json:"id" example:"1"`
+	ProjectID              int              `json:"project_id" example:"1"`
+	Code                   string           `json:"code" example:"MB-03"`
+	Name                   string           `json:"name" example:"Column mould 600x600"`
+	LengthMM               float64          `json:"length_mm" example:"12000"`
+	WidthMM                float64          `json:"width_mm" example:"600"`
+	HeightMM               float64          `json:"height_mm" example:"600"`
+	ElementTypeIDs         []int            `json:"element_type_ids" example:"3,4"` // compatible element types, empty for any
+	Location               string           `json:"location" example:"Bay 2"`
+	Status                 string           `json:"status" example:"available"`
+	ReuseCount             int              `json:"reuse_count" example:"148"`
+	CyclesSinceMaintenance int              `json:"cycles_since_maintenance" example:"38"`
+	MaintenanceThreshold   int              `json:"maintenance_threshold" example:"50"` // 0 for none
+	MaintenanceDue         bool             `json:"maintenance_due" example:"false"`
+	LastMaintainedAt       *time.Time       `json:"last_maintained_at,omitempty"`
+	CurrentActivityID      *int             `json:"current_activity_id,omitempty" example:"88"`
+	CurrentElementID       *int             `json:"current_element_id,omitempty" example:"1024"`
+	Remarks                string           `json:"remarks" example:""`
+	CreatedBy              string           `json:"created_by" example:"planner1"`
+	CreatedAt              time.Time        `json:"created_at" example:"2024-01-15T10:30:00Z"`
+	UpdatedAt              time.Time        `json:"updated_at" example:"2024-01-15T10:30:00Z"`
+	Cycles                 []MouldCycle     `json:"cycles,omitempty"`
+	StatusLog              []MouldStatusLog `json:"status_log,omitempty"`
+}
+
+// MouldCycle is one use of a mould, from assignment to casting
+type MouldCycle struct {
+	ID          int        `json:"id" example:"1"`
+	MouldID     int        `json:"mould_id" example:"1"`
+	ActivityID  int        `json:"activity_id" example:"88"`
+	ElementID   int        `json:"element_id" example:"1024"`
+	ElementName string     `json:"element_name" example:"C1-01"`
+	AssignedAt  time.Time  `json:"assigned_at" example:"2024-01-15T07:00:00Z"`
+	AssignedBy  string     `json:"assigned_by" example:"planner1"`
+	CastAt      *time.Time `json:"cast_at,omitempty"`
+}
+
+// MouldStatusLog records a status change of a mould
+type MouldStatusLog struct {
+	Status     string    `json:"status" example:"cleaning"`
+	ActivityID *int      `json:"activity_id,omitempty" example:"88"`
+	Note       string    `json:"note" example:""`
+	ChangedBy  string    `json:"changed_by" example:"supervisor1"`
+	ChangedAt  time.Time `json:"changed_at" example:"2024-01-15T14:00:00Z"`
+}
+
+// CreateMouldRequest registers a mould
+type CreateMouldRequest struct {
+	ProjectID            int     `json:"project_id" binding:"required" example:"1"`
+	Code                 string  `json:"code" binding:"required" example:"MB-03"`
+	Name                 string  `json:"name" example:"Column mould 600x600"`
+	LengthMM             float64 `json:"length_mm" example:"12000"`
+	WidthMM              float64 `json:"width_mm" example:"600"`
+	HeightMM             float64 `json:"height_mm" example:"600"`
+	ElementTypeIDs       []int   `json:"element_type_ids" example:"3,4"`
+	Location             string  `json:"location" example:"Bay 2"`
+	ReuseCount           int     `json:"reuse_count" example:"0"` // uses before it was registered
+	MaintenanceThreshold int     `json:"maintenance_threshold" example:"50"`
+	Remarks              string  `json:"remarks" example:""`
+}
+
+// UpdateMouldRequest edits the details of a mould
+type UpdateMouldRequest struct {
+	Code                 string  `json:"code" binding:"required" example:"MB-03"`
+	Name                 string  `json:"name" example:"Column mould 600x600"`
+	LengthMM             float64 `json:"length_mm" example:"12000"`
+	WidthMM              float64 `json:"width_mm" example:"600"`
+	HeightMM             float64 `json:"height_mm" example:"600"`
+	ElementTypeIDs       []int   `json:"element_type_ids" example:"3,4"`
+	Location             string  `json:"location" example:"Bay 2"`
+	MaintenanceThreshold int     `json:"maintenance_threshold" example:"50"`
+	Remarks              string  `json:"remarks" example:""`
+}
+
+// MouldStatusRequest sets a mould to available, cleaning or repair
+type MouldStatusRequest struct {
+	Status string `json:"status" binding:"required" example:"repair"`
+	Note   string `json:"note" example:"Side shutter re-welded"`
+}
+
+// MouldAssignmentRequest assigns a mould to a casting activity
+type MouldAssignmentRequest struct {
+	MouldID int `json:"mould_id" binding:"required" example:"1"`
+}
+
+// MouldUtilisation is the use of one mould over a period
+type MouldUtilisation struct {
+	MouldID            int     `json:"mould_id" example:"1"`
+	Code               string  `json:"code" example:"MB-03"`
+	Name               string  `json:"name" example:"Column mould 600x600"`
+	Status             string  `json:"status" example:"available"`
+	Cycles             int     `json:"cycles" example:"24"`
+	CyclesPerDay       float64 `json:"cycles_per_day" example:"0.8"`
+	AverageCycleHours  float64 `json:"average_cycle_hours" example:"9.5"` // assignment to casting
+	InUseHours         float64 `json:"in_use_hours" example:"228"`
+	CleaningHours      float64 `json:"cleaning_hours" example:"48"`
+	RepairHours        float64 `json:"repair_hours" example:"24"`
+	IdleHours          float64 `json:"idle_hours" example:"420"`
+	UtilisationPercent float64 `json:"utilisation_percent" example:"31.67"`
+	IdlePercent        float64 `json:"idle_percent" example:"58.33"`
+	MaintenanceDue     bool    `json:"maintenance_due" example:"false"`
+}
+
+// MouldUtilisationReport is the mould utilisation report of a project
+type MouldUtilisationReport struct {
+	ProjectID          int                `json:"project_id" example:"1"`
+	From               string             `json:"from" example:"2024-01-01"`
+	To                 string             `json:"to" example:"2024-01-30"`
+	Days               float64            `json:"days" example:"30"`
+	Cycles             int                `json:"cycles" example:"310"`
+	CyclesPerDay       float64            `json:"cycles_per_day" example:"10.33"`
+	UtilisationPercent float64            `json:"utilisation_percent" example:"41.2"`
+	Moulds             []MouldUtilisation `json:"moulds"`
+}
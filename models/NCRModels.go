@@ -0,0 +1,131 @@
+package models
+
+import "time"
+
+// NCR statuses
+const (
+	NCRStatusOpen            = "open"             // raised, awaiting disposition
+	NCRStatusDispositioned   = "dispositioned"    // disposition decided, corrective action pending
+	NCRStatusActionCompleted = "action_completed" // corrective action done, awaiting sign-off
+	NCRStatusClosed          = "closed"           // signed off
+)
+
+// NCR dispositions
+const (
+	NCRDispositionRepair     = "repair"
+	NCRDispositionReject     = "reject"
+	NCRDispositionAcceptAsIs = "accept_as_is" // accepted under a concession
+)
+
+// NCR sources
+const (
+	NCRSourceQCFailure = "qc_failure"
+	NCRSourceManual    = "manual"
+	NCRSourceCubeTest  = "cube_test" // 28-day strength below the grade
+)
+
+// NCRRootCauseCategories lists the accepted root-cause categories
+var NCRRootCauseCategories = map[string]string{
+	"material":      "Material / mix",
+	"workmanship":   "Workmanship",
+	"design":        "Design / drawing error",
+	"formwork":      "Mould / formwork",
+	"reinforcement": "Reinforcement placement",
+	"curing":        "Curing",
+	"handling":      "Handling / storage damage",
+	"equipment":     "Equipment",
+	"supplier":      "Supplier",
+	"other":         "Other",
+}
+
+// NCR is a non-conformance report against an element
+type NCR struct {
+	ID                int        `json:"id" example:"1"`
+	ProjectID         int        `json:"project_id" example:"1"`
+	NCRNo             string     `json:"ncr_no" example:"NCR-0001"`
+	ElementID         int        `json:"element_id" example:"1024"`
+	ElementName       string     `json:"element_name,omitempty" example:"C1-01"`
+	ElementTypeID     int        `json:"element_type_id" example:"4"`
+	ActivityID        *int       `json:"activity_id,omitempty" example:"88"`
+	StageID           *int       `json:"stage_id,omitempty" example:"3"`
+	StageName         string     `json:"stage_name,omitempty" example:"Casting"`
+	InspectionID      *int       `json:"inspection_id,omitempty" example:"12"`
+	Source            string     `json:"source" example:"qc_failure"`
+	Title             string     `json:"title" example:"Honeycombing on face"`
+	Description       string     `json:"description" example:""`
+	Severity          string     `json:"severity" example:"critical"`
+	Status            string     `json:"status" example:"open"`
+	RootCauseCategory string     `json:"root_cause_category,omitempty" example:"workmanship"`
+	Disposition       string     `json:"disposition,omitempty" example:"repair"`
+	ConcessionRef     string     `json:"concession_ref,omitempty" example:""`
+	CorrectiveAction  string     `json:"corrective_action,omitempty" example:"Patch repair with approved mortar"`
+	ActionOwnerID     *int       `json:"action_owner_id,omitempty" example:"7"`
+	ActionOwnerName   string     `json:"action_owner_name,omitempty" example:"Ravi Kumar"`
+	ActionDueDate     *time.Time `json:"action_due_date,omitempty"`
+	RaisedBy          string     `json:"raised_by" example:"qc1"`
+	RaisedAt          time.Time  `json:"raised_at" example:"2024-01-15T10:30:00Z"`
+	DispositionedBy   string     `json:"dispositioned_by,omitempty" example:"qa_manager"`
+	DispositionedAt   *time.Time `json:"dispositioned_at,omitempty"`
+	ActionCompletedBy string     `json:"action_completed_by,omitempty" example:""`
+	ActionCompletedAt *time.Time `json:"action_completed_at,omitempty"`
+	ClosedBy          string     `json:"closed_by,omitempty" example:""`
+	ClosedAt          *time.Time `json:"closed_at,omitempty"`
+	Events            []NCREvent `json:"events,omitempty"`
+}
+
+// NCREvent is one step in the history of an NCR
+type NCREvent struct {
+	ID        int       `json:"id" example:"1"`
+	NCRID     int       `json:"ncr_id" example:"1"`
+	Action    string    `json:"action" example:"disposition"`
+	Comments  string    `json:"comments" example:""`
+	ActedBy   string    `json:"acted_by" example:"qa_manager"`
+	CreatedAt time.Time `json:"created_at" example:"2024-01-15T10:30:00Z"`
+}
+
+// CreateNCRRequest raises an NCR manually
+type CreateNCRRequest struct {
+	ElementID         int    `json:"element_id" binding:"required" example:"1024"`
+	Title             string `json:"title" binding:"required" example:"Chipped corner after demoulding"`
+	Description       string `json:"description" example:""`
+	Severity          string `json:"severity" example:"minor"`
+	RootCauseCategory string `json:"root_cause_category" example:"handling"`
+}
+
+// NCRDispositionRequest decides what happens to the non-conforming element
+type NCRDispositionRequest struct {
+	Disposition       string `json:"disposition" binding:"required" example:"repair"`
+	RootCauseCategory string `json:"root_cause_category" binding:"required" example:"workmanship"`
+	CorrectiveAction  string `json:"corrective_action" example:"Patch repair with approved mortar"`
+	ActionOwnerID     int    `json:"action_owner_id" example:"7"`
+	ActionDueDate     string `json:"action_due_date" example:"2024-01-20"`
+	ConcessionRef     string `json:"concession_ref" example:"CON-014"` // required to accept as is
+	Comments          string `json:"comments" example:""`
+}
+
+// NCRCommentRequest carries the remarks of a lifecycle step
+type NCRCommentRequest struct {
+	Comments string `json:"comments" example:"Repair inspected and accepted"`
+}
+
+// NCRRegisterRow counts NCRs of one root-cause category
+type NCRRegisterRow struct {
+	RootCauseCategory string `json:"root_cause_category" example:"workmanship"`
+	Label             string `json:"label" example:"Workmanship"`
+	Total             int    `json:"total" example:"5"`
+	Open              int    `json:"open" example:"2"`
+	Closed            int    `json:"closed" example:"3"`
+	Repair            int    `json:"repair" example:"3"`
+	Reject            int    `json:"reject" example:"1"`
+	AcceptAsIs        int    `json:"accept_as_is" example:"1"`
+}
+
+// NCRRegister is the NCR register report of a project
+type NCRRegister struct {
+	ProjectID  int              `json:"project_id" example:"1"`
+	Total      int              `json:"total" example:"5"`
+	Open       int              `json:"open" example:"2"`
+	Closed     int              `json:"closed" example:"3"`
+	ByCategory []NCRRegisterRow `json:"by_category"`
+	NCRs       []NCR            `json:"ncrs"`
+}
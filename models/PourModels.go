@@ -0,0 +1,102 @@
+package models
+
+import "time"
+
+// Pour groups the elements cast together in one concreting operation
+type Pour struct {
+	ID                  int           `json:"id" example:"1"`
+	ProjectID           int           `json:"project_id" example:"1"`
+	PourNo              string        `json:"pour_no" example:"PR-0001"`
+	PourDate            time.Time     `json:"pour_date" example:"2024-01-15T00:00:00Z"`
+	Bed                 string        `json:"bed" example:"Bed 3"`
+	Grade               string        `json:"grade" example:"M40"`
+	Remarks             string        `json:"remarks" example:""`
+	ElementCount        int           `json:"element_count" example:"6"`
+	TheoreticalVolumeM3 float64       `json:"theoretical_volume_m3" example:"7.2"` // sum of the element volumes
+	TicketVolumeM3      float64       `json:"ticket_volume_m3" example:"7.5"`      // sum of the batch tickets
+	VarianceM3          *float64      `json:"variance_m3,omitempty" example:"0.3"` // tickets minus theoretical, when ticketed
+	VariancePercent     *float64      `json:"variance_percent,omitempty" example:"4.17"`
+	CreatedBy           string        `json:"created_by" example:"supervisor1"`
+	CreatedAt           time.Time     `json:"created_at" example:"2024-01-15T10:30:00Z"`
+	Elements            []PourElement `json:"elements,omitempty"`
+	Tickets             []PourTicket  `json:"tickets,omitempty"`
+	SampleNos           []string      `json:"sample_nos,omitempty" example:"CS-0004"` // concrete samples taken from this pour
+}
+
+// PourElement is an element cast in a pour, with its volume at the time
+type PourElement struct {
+	ElementID   int       `json:"element_id" example:"1024"`
+	ElementName string    `json:"element_name" example:"C1-01"`
+	ElementType string    `json:"element_type" example:"Column C1"`
+	ActivityID  *int      `json:"activity_id,omitempty" example:"88"`
+	VolumeM3    float64   `json:"volume_m3" example:"1.2"`
+	AddedAt     time.Time `json:"added_at" example:"2024-01-15T10:30:00Z"`
+}
+
+// PourTicket is a batch-plant delivery ticket used in a pour
+type PourTicket struct {
+	ID          int        `json:"id" example:"1"`
+	PourID      int        `json:"pour_id" example:"1"`
+	TicketNo    string     `json:"ticket_no" example:"BP-88231"`
+	VolumeM3    float64    `json:"volume_m3" example:"6"`
+	Grade       string     `json:"grade" example:"M40"`
+	DeliveredAt *time.Time `json:"delivered_at,omitempty"`
+	CreatedBy   string     `json:"created_by" example:"supervisor1"`
+}
+
+// PourTicketInput adds a batch ticket to a pour
+type PourTicketInput struct {
+	TicketNo    string  `json:"ticket_no" binding:"required" example:"BP-88231"`
+	VolumeM3    float64 `json:"volume_m3" binding:"required" example:"6"`
+	Grade       string  `json:"grade" example:"M40"`                         // defaults to the pour grade
+	DeliveredAt string  `json:"delivered_at" example:"2024-01-15T09:10:00Z"` // RFC3339
+}
+
+// PourCastingInput describes the pour an element is cast in when casting is completed.
+// Without a pour_id the element joins the day's pour on the same bed and grade, or starts one.
+type PourCastingInput struct {
+	PourID  int               `json:"pour_id" example:"0"`
+	Bed     string            `json:"bed" example:"Bed 3"`
+	Grade   string            `json:"grade" example:"M40"`
+	Tickets []PourTicketInput `json:"tickets"`
+}
+
+// CreatePourRequest opens a pour ahead of casting
+type CreatePourRequest struct {
+	ProjectID int               `json:"project_id" binding:"required" example:"1"`
+	PourDate  string            `json:"pour_date" example:"2024-01-15"` // defaults to today
+	Bed       string            `json:"bed" example:"Bed 3"`
+	Grade     string            `json:"grade" binding:"required" example:"M40"`
+	Remarks   string            `json:"remarks" example:""`
+	Tickets   []PourTicketInput `json:"tickets"`
+}
+
+// PourElementsRequest moves elements into a pour
+type PourElementsRequest struct {
+	ElementIDs []int `json:"element_ids" binding:"required" example:"1024,1025"`
+}
+
+// PourTicketsRequest adds batch tickets to a pour
+type PourTicketsRequest struct {
+	Tickets []PourTicketInput `json:"tickets" binding:"required"`
+}
+
+// ConcreteReconciliationRow compares theoretical and delivered volume for one grade
+type ConcreteReconciliationRow struct {
+	Grade               string   `json:"grade" example:"M40"`
+	Pours               int      `json:"pours" example:"12"`
+	TicketedPours       int      `json:"ticketed_pours" example:"10"`
+	TheoreticalVolumeM3 float64  `json:"theoretical_volume_m3" example:"84.6"`
+	TicketVolumeM3      float64  `json:"ticket_volume_m3" example:"88.0"`
+	VarianceM3          float64  `json:"variance_m3" example:"3.4"` // over ticketed pours only
+	VariancePercent     *float64 `json:"variance_percent,omitempty" example:"4.02"`
+}
+
+// ConcreteReconciliation is the concrete reconciliation report of a project
+type ConcreteReconciliation struct {
+	ProjectID int                         `json:"project_id" example:"1"`
+	From      string                      `json:"from" example:"2024-01-01"`
+	To        string                      `json:"to" example:"2024-01-31"`
+	ByGrade   []ConcreteReconciliationRow `json:"by_grade"`
+	Pours     []Pour                      `json:"pours"`
+}
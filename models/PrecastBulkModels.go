@@ -0,0 +1,51 @@
+package models
+
+// HierarchyLevelTemplate describes one level of a generated hierarchy, e.g. the floors of each tower.
+// Name and Prefix are patterns: {n} is the running number, {n:2} the number padded to 2 digits,
+// {a} a letter (A, B, ... Z, AA) and {name} the entry of Names.
+type HierarchyLevelTemplate struct {
+	Count       int      `json:"count" example:"40"` // nodes under each parent; ignored when names are given
+	Start       *int     `json:"start" example:"1"`  // first running number, default 1
+	Names       []string `json:"names"`              // explicit names, e.g. Basement, Ground
+	Name        string   `json:"name" example:"Floor {n:2}"`
+	Prefix      string   `json:"prefix" example:"F{n:2}"`
+	Description string   `json:"description" example:""`
+}
+
+// GenerateHierarchyRequest builds a hierarchy level by level, every node of one level getting all the
+// nodes of the next, e.g. 4 towers x 40 floors x 2 zones
+type GenerateHierarchyRequest struct {
+	ParentID *int64                   `json:"parent_id" example:"5"` // existing node to build under, empty for the top
+	Levels   []HierarchyLevelTemplate `json:"levels"`
+	Preview  bool                     `json:"preview" example:"false"` // return the tree without saving it
+}
+
+// HierarchyNode is a node of a generated, cloned or moved hierarchy
+type HierarchyNode struct {
+	ID               int             `json:"id" example:"120"` // 0 in a preview
+	Name             string          `json:"name" example:"Floor 03"`
+	Prefix           string          `json:"prefix" example:"F03"`
+	Path             string          `json:"path" example:"tower_a.floor_03"`
+	NamingConvention string          `json:"naming_convention" example:"TA_F03"`
+	Children         []HierarchyNode `json:"children,omitempty"`
+}
+
+// CloneHierarchyRequest copies a node with everything under it. Elements are not copied.
+type CloneHierarchyRequest struct {
+	ParentID *int64 `json:"parent_id" example:"5"` // parent of the copy, empty for the top
+	Name     string `json:"name" binding:"required" example:"Tower B"`
+	Prefix   string `json:"prefix" binding:"required" example:"TB"`
+}
+
+// MoveHierarchyRequest moves a node with everything under it to a new parent
+type MoveHierarchyRequest struct {
+	ParentID *int64 `json:"parent_id" example:"7"` // new parent, empty for the top
+}
+
+// HierarchyBlocker is an element that stops a hierarchy node from being deleted
+type HierarchyBlocker struct {
+	ElementID   int    `json:"element_id" example:"2001"`
+	ElementName string `json:"element_name" example:"C1-01"`
+	HierarchyID int    `json:"hierarchy_id" example:"120"`
+	State       string `json:"state" example:"qc_passed"`
+}
@@ -0,0 +1,98 @@
+package models
+
+import "time"
+
+// QC question answer types. Questions without a spec are plain multiple choice.
+const (
+	QCAnswerChoice  = "choice"
+	QCAnswerNumeric = "numeric"
+	QCAnswerYesNo   = "yes_no"
+	QCAnswerText    = "text"
+)
+
+// QC question severities
+const (
+	QCSeverityCritical = "critical"
+	QCSeverityMinor    = "minor"
+)
+
+// QC inspection results computed from the answers
+const (
+	QCResultPass          = "pass"
+	QCResultPassWithMinor = "pass_with_minor" // only minor checks failed
+	QCResultFail          = "fail"            // at least one critical check failed
+)
+
+// QCReferenceFields are the element type dimensions a numeric check can be measured against
+var QCReferenceFields = map[string]string{
+	"length":    "length",
+	"width":     "width",
+	"height":    "height",
+	"thickness": "thickness",
+	"mass":      "mass",
+	"volume":    "volume",
+}
+
+// QCQuestionSpec types a QC question and defines when its answer passes
+type QCQuestionSpec struct {
+	QuestionID     int      `json:"question_id" example:"1"`
+	AnswerType     string   `json:"answer_type" example:"numeric"`
+	Severity       string   `json:"severity" example:"critical"`
+	Unit           string   `json:"unit,omitempty" example:"mm"`
+	ReferenceField string   `json:"reference_field,omitempty" example:"length"` // element type dimension used as the target
+	TargetValue    *float64 `json:"target_value,omitempty" example:"3000"`      // fixed target when no reference field
+	ToleranceMinus *float64 `json:"tolerance_minus,omitempty" example:"5"`
+	TolerancePlus  *float64 `json:"tolerance_plus,omitempty" example:"5"`
+	MinValue       *float64 `json:"min_value,omitempty"` // absolute bounds when there is no target
+	MaxValue       *float64 `json:"max_value,omitempty"`
+	ExpectedYes    *bool    `json:"expected_yes,omitempty" example:"true"`       // yes/no questions pass on this answer (default yes)
+	FailOptions    []string `json:"fail_options,omitempty" example:"No,Damaged"` // choice questions fail on these options
+	PhotoRequired  bool     `json:"photo_required" example:"false"`
+	CommentOnFail  bool     `json:"comment_on_fail" example:"true"`
+}
+
+// QCAnswerResult is the evaluation of one typed answer
+type QCAnswerResult struct {
+	QuestionID   int      `json:"question_id" example:"1"`
+	QuestionText string   `json:"question_text,omitempty" example:"Overall length"`
+	AnswerType   string   `json:"answer_type" example:"numeric"`
+	Severity     string   `json:"severity" example:"critical"`
+	NumericValue *float64 `json:"numeric_value,omitempty" example:"3004"`
+	BoolValue    *bool    `json:"bool_value,omitempty"`
+	OptionText   string   `json:"option_text,omitempty" example:""`
+	ExpectedMin  *float64 `json:"expected_min,omitempty" example:"2995"`
+	ExpectedMax  *float64 `json:"expected_max,omitempty" example:"3005"`
+	Passed       bool     `json:"passed" example:"true"`
+	Reason       string   `json:"reason,omitempty" example:""`
+	Comment      string   `json:"comment,omitempty" example:""`
+	ImagePath    string   `json:"image_path,omitempty" example:""`
+}
+
+// QCInspectionResult is the overall outcome of one QC submission
+type QCInspectionResult struct {
+	ID               int              `json:"id" example:"1"`
+	ActivityID       int              `json:"activity_id" example:"1"`
+	ElementID        int              `json:"element_id" example:"1"`
+	StageID          int              `json:"stage_id" example:"1"`
+	PaperID          int              `json:"paper_id" example:"1"`
+	QCID             int              `json:"qc_id" example:"1"`
+	Result           string           `json:"result" example:"pass"`
+	CriticalFailures int              `json:"critical_failures" example:"0"`
+	MinorFailures    int              `json:"minor_failures" example:"1"`
+	Status           string           `json:"status" example:"completed"` // activity status applied
+	CreatedAt        time.Time        `json:"created_at" example:"2024-01-15T10:30:00Z"`
+	Answers          []QCAnswerResult `json:"answers"`
+}
+
+// QCAnswerInput is one answer in a QC submission
+type QCAnswerInput struct {
+	ProjectID    int      `json:"project_id"`
+	QuestionID   int      `json:"question_id"`
+	OptionID     int      `json:"option_id"`
+	TaskID       int      `json:"task_id"`
+	StageID      int      `json:"stage_id"`
+	Comment      string   `json:"comment"`
+	ImagePath    string   `json:"image_path"`
+	NumericValue *float64 `json:"numeric_value"`
+	BoolValue    *bool    `json:"bool_value"`
+}
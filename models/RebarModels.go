@@ -0,0 +1,93 @@
+package models
+
+import "time"
+
+// ElementTypeRebar is one bar mark of an element type's reinforcement detail.
+// Dimensions A-E are in millimetres and follow the shape code's dimension convention.
+type ElementTypeRebar struct {
+	ID            int       `json:"id" example:"1"`
+	ElementTypeID int       `json:"element_type_id" example:"1"`
+	ProjectID     int       `json:"project_id" example:"1"`
+	BarMark       string    `json:"bar_mark" example:"B1"`
+	DiameterMM    int       `json:"diameter_mm" example:"12"`
+	ShapeCode     string    `json:"shape_code" example:"21"`
+	A             float64   `json:"a" example:"2950"`
+	B             float64   `json:"b" example:"300"`
+	C             float64   `json:"c" example:"300"`
+	D             float64   `json:"d" example:"0"`
+	E             float64   `json:"e" example:"0"`
+	BarCount      int       `json:"bar_count" example:"4"`
+	Remarks       string    `json:"remarks" example:"Bottom main bars"`
+	CreatedBy     string    `json:"created_by" example:"admin"`
+	CreatedAt     time.Time `json:"created_at" example:"2024-01-15T10:30:00Z"`
+	UpdatedAt     time.Time `json:"updated_at" example:"2024-01-15T10:30:00Z"`
+}
+
+// ElementTypeRebarRequest replaces the reinforcement detail of an element type
+type ElementTypeRebarRequest struct {
+	Bars []ElementTypeRebar `json:"bars" binding:"required"`
+}
+
+// RebarShapeCode describes a bending shape and the dimensions it needs
+type RebarShapeCode struct {
+	Code       string   `json:"code" example:"21"`
+	Name       string   `json:"name" example:"U-bar"`
+	Dimensions []string `json:"dimensions" example:"A,B,C"`
+	Formula    string   `json:"formula" example:"A + B + C - 4d"`
+}
+
+// BBSLine is one bar mark of a bar bending schedule
+type BBSLine struct {
+	ElementTypeID    int     `json:"element_type_id" example:"1"`
+	ElementType      string  `json:"element_type" example:"B1"`
+	BarMark          string  `json:"bar_mark" example:"B1"`
+	DiameterMM       int     `json:"diameter_mm" example:"12"`
+	ShapeCode        string  `json:"shape_code" example:"21"`
+	ShapeName        string  `json:"shape_name" example:"U-bar"`
+	A                float64 `json:"a" example:"2950"`
+	B                float64 `json:"b" example:"300"`
+	C                float64 `json:"c" example:"300"`
+	D                float64 `json:"d" example:"0"`
+	E                float64 `json:"e" example:"0"`
+	BarsPerElement   int     `json:"bars_per_element" example:"4"`
+	Elements         int     `json:"elements" example:"10"`
+	TotalBars        int     `json:"total_bars" example:"40"`
+	CutLengthMM      float64 `json:"cut_length_mm" example:"3502"`
+	TotalLengthM     float64 `json:"total_length_m" example:"140.08"`
+	UnitWeightKgPerM float64 `json:"unit_weight_kg_per_m" example:"0.889"`
+	WeightKg         float64 `json:"weight_kg" example:"124.52"`
+	Remarks          string  `json:"remarks,omitempty" example:"Bottom main bars"`
+}
+
+// BBSDiameterTotal is the total length and weight of one bar diameter
+type BBSDiameterTotal struct {
+	DiameterMM   int     `json:"diameter_mm" example:"12"`
+	TotalBars    int     `json:"total_bars" example:"40"`
+	TotalLengthM float64 `json:"total_length_m" example:"140.08"`
+	WeightKg     float64 `json:"weight_kg" example:"124.52"`
+}
+
+// BBSShapeTotal groups the cut lengths of one shape code
+type BBSShapeTotal struct {
+	ShapeCode    string    `json:"shape_code" example:"21"`
+	ShapeName    string    `json:"shape_name" example:"U-bar"`
+	CutLengthsMM []float64 `json:"cut_lengths_mm"`
+	TotalBars    int       `json:"total_bars" example:"40"`
+	TotalLengthM float64   `json:"total_length_m" example:"140.08"`
+	WeightKg     float64   `json:"weight_kg" example:"124.52"`
+}
+
+// BarBendingSchedule is a bar bending schedule for one element type or for a casting batch
+type BarBendingSchedule struct {
+	ProjectID     int                `json:"project_id" example:"1"`
+	ProjectName   string             `json:"project_name" example:"Tower A"`
+	Title         string             `json:"title" example:"BBS - B1"`
+	From          *time.Time         `json:"from,omitempty"`
+	To            *time.Time         `json:"to,omitempty"`
+	Elements      int                `json:"elements" example:"10"`
+	Lines         []BBSLine          `json:"lines"`
+	ByDiameter    []BBSDiameterTotal `json:"by_diameter"`
+	ByShape       []BBSShapeTotal    `json:"by_shape"`
+	TotalWeightKg float64            `json:"total_weight_kg" example:"124.52"`
+	GeneratedAt   time.Time          `json:"generated_at" example:"2024-01-15T10:30:00Z"`
+}
@@ -0,0 +1,72 @@
+package models
+
+import "time"
+
+// Remnant kinds and statuses
+const (
+	RemnantKindBar   = "bar"   // 1D: rebar and sections, measured by length
+	RemnantKindSheet = "sheet" // 2D: sheets and boards, measured by width x height
+
+	RemnantStatusAvailable = "available"
+	RemnantStatusConsumed  = "consumed"
+	RemnantStatusScrapped  = "scrapped"
+)
+
+// Remnant is a reusable offcut kept in inventory
+type Remnant struct {
+	ID          int        `json:"id" example:"1"`
+	ProjectID   int        `json:"project_id" example:"1"`
+	Kind        string     `json:"kind" example:"bar"`
+	DiameterMM  int        `json:"diameter_mm" example:"12"`
+	Material    string     `json:"material" example:""`
+	Length      float64    `json:"length" example:"2450"`
+	Width       float64    `json:"width" example:"0"`
+	Height      float64    `json:"height" example:"0"`
+	Location    string     `json:"location" example:"Rack 3"`
+	WarehouseID *int       `json:"warehouse_id,omitempty" example:"1"`
+	Status      string     `json:"status" example:"available"`
+	Source      string     `json:"source" example:"solve-1d"`
+	CreatedBy   string     `json:"created_by" example:"admin"`
+	CreatedAt   time.Time  `json:"created_at" example:"2024-01-15T10:30:00Z"`
+	ReusedSize  float64    `json:"reused_size" example:"2400"` // length (bar) or area (sheet) cut from it
+	ConsumedBy  string     `json:"consumed_by,omitempty" example:"admin"`
+	ConsumedAt  *time.Time `json:"consumed_at,omitempty"`
+}
+
+// CreateRemnantRequest adds a remnant to inventory by hand
+type CreateRemnantRequest struct {
+	ProjectID   int     `json:"project_id" binding:"required" example:"1"`
+	Kind        string  `json:"kind" binding:"required" example:"bar"`
+	DiameterMM  int     `json:"diameter_mm" example:"12"`
+	Material    string  `json:"material" example:""`
+	Length      float64 `json:"length" example:"2450"`
+	Width       float64 `json:"width" example:"0"`
+	Height      float64 `json:"height" example:"0"`
+	Location    string  `json:"location" example:"Rack 3"`
+	WarehouseID *int    `json:"warehouse_id" example:"1"`
+}
+
+// RemnantDiameterSaving is the bar length reused for one diameter
+type RemnantDiameterSaving struct {
+	DiameterMM     int     `json:"diameter_mm" example:"12"`
+	ReusedLengthM  float64 `json:"reused_length_m" example:"24.5"`
+	ReusedWeightKg float64 `json:"reused_weight_kg" example:"21.8"`
+}
+
+// RemnantSavingsReport shows how much waste remnant reuse has saved for a project
+type RemnantSavingsReport struct {
+	ProjectID          int                     `json:"project_id" example:"1"`
+	BarsStored         int                     `json:"bars_stored" example:"40"`
+	BarsAvailable      int                     `json:"bars_available" example:"12"`
+	BarsAvailableM     float64                 `json:"bars_available_m" example:"30.2"`
+	BarsConsumed       int                     `json:"bars_consumed" example:"25"`
+	BarsScrapped       int                     `json:"bars_scrapped" example:"3"`
+	BarsReusedM        float64                 `json:"bars_reused_m" example:"55.1"`
+	BarsReusedWeightKg float64                 `json:"bars_reused_weight_kg" example:"49"`
+	ByDiameter         []RemnantDiameterSaving `json:"by_diameter"`
+	SheetsStored       int                     `json:"sheets_stored" example:"10"`
+	SheetsAvailable    int                     `json:"sheets_available" example:"4"`
+	SheetsConsumed     int                     `json:"sheets_consumed" example:"6"`
+	SheetsScrapped     int                     `json:"sheets_scrapped" example:"0"`
+	SheetsReusedArea   float64                 `json:"sheets_reused_area" example:"1250000"`
+}
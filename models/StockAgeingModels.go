@@ -0,0 +1,76 @@
+package models
+
+import "time"
+
+// StockAgeingBuckets are the age bands of the stock ageing report, in days since production
+var StockAgeingBuckets = []string{"0-30", "31-60", "61-90", "90+"}
+
+// StockyardStorageRate is the storage cost of a stockyard and the age at which its managers are
+// alerted about a piece
+type StockyardStorageRate struct {
+	StockyardID     int       `json:"stockyard_id" example:"1"`
+	YardName        string    `json:"yard_name" example:"Factory Yard"`
+	RatePerPieceDay float64   `json:"rate_per_piece_day" example:"25"`
+	RatePerTonneDay float64   `json:"rate_per_tonne_day" example:"4.5"`
+	Currency        string    `json:"currency" example:"INR"`
+	AlertAfterDays  int       `json:"alert_after_days" example:"90"`
+	UpdatedBy       string    `json:"updated_by" example:"admin"`
+	UpdatedAt       time.Time `json:"updated_at" example:"2024-01-15T10:30:00Z"`
+}
+
+// StorageRateRequest sets the storage cost of a stockyard
+type StorageRateRequest struct {
+	RatePerPieceDay float64 `json:"rate_per_piece_day" example:"25"`
+	RatePerTonneDay float64 `json:"rate_per_tonne_day" example:"4.5"`
+	Currency        string  `json:"currency" example:"INR"`
+	AlertAfterDays  int     `json:"alert_after_days" example:"90"` // defaults to 90
+}
+
+// StockAgeingBucket is the stock in one age band
+type StockAgeingBucket struct {
+	Label    string  `json:"label" example:"31-60"`
+	Pieces   int     `json:"pieces" example:"12"`
+	WeightKg float64 `json:"weight_kg" example:"48600"`
+}
+
+// StockAgeingRow is the ageing of one element type of a project in one stockyard
+type StockAgeingRow struct {
+	StockyardID    int                 `json:"stockyard_id" example:"1"`
+	YardName       string              `json:"yard_name" example:"Factory Yard"`
+	ProjectID      int                 `json:"project_id" example:"1"`
+	ProjectName    string              `json:"project_name" example:"Tower A"`
+	ElementType    string              `json:"element_type" example:"Column"`
+	Buckets        []StockAgeingBucket `json:"buckets"`
+	Pieces         int                 `json:"pieces" example:"30"`
+	WeightKg       float64             `json:"weight_kg" example:"120500"`
+	AverageAgeDays float64             `json:"average_age_days" example:"41.3"`
+	OldestAgeDays  int                 `json:"oldest_age_days" example:"118"`
+	StorageCost    float64             `json:"storage_cost" example:"38250"` // storage to date at the stockyard's rates
+}
+
+// AgedStockPiece is a piece that has been in the stockyard longer than asked for
+type AgedStockPiece struct {
+	PrecastStockID int       `json:"precast_stock_id" example:"101"`
+	ElementID      int       `json:"element_id" example:"2001"`
+	ElementName    string    `json:"element_name" example:"C1-01"`
+	ElementType    string    `json:"element_type" example:"Column"`
+	ProjectID      int       `json:"project_id" example:"1"`
+	StockyardID    int       `json:"stockyard_id" example:"1"`
+	YardName       string    `json:"yard_name" example:"Factory Yard"`
+	ProductionDate time.Time `json:"production_date" example:"2024-01-15T00:00:00Z"`
+	AgeDays        int       `json:"age_days" example:"96"`
+	WeightKg       float64   `json:"weight_kg" example:"7100"`
+	StorageCost    float64   `json:"storage_cost" example:"3670"`
+	Location       string    `json:"location" example:"A-R1-03"`
+}
+
+// StockAgeingReport is the ageing of the stock lying in stockyards on a date
+type StockAgeingReport struct {
+	AsOf        string              `json:"as_of" example:"2024-03-31"`
+	Currency    string              `json:"currency,omitempty" example:"INR"` // empty when stockyards use different currencies
+	Rows        []StockAgeingRow    `json:"rows"`
+	Totals      []StockAgeingBucket `json:"totals"`
+	Pieces      int                 `json:"pieces" example:"240"`
+	StorageCost float64             `json:"storage_cost" example:"185400"`
+	AgedPieces  []AgedStockPiece    `json:"aged_pieces,omitempty"` // when over_days is given
+}
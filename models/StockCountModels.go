@@ -0,0 +1,120 @@
+package models
+
+import "time"
+
+// Stock count (cycle count) statuses
+const (
+	StockCountStatusOpen      = "open"      // snapshot frozen, counters may submit entries
+	StockCountStatusReview    = "review"    // counting closed, variances awaiting approval
+	StockCountStatusClosed    = "closed"    // every variance decided, adjustments posted
+	StockCountStatusCancelled = "cancelled" // abandoned, nothing posted
+)
+
+// Stock count item statuses
+const (
+	StockCountItemPending  = "pending"
+	StockCountItemApproved = "approved"
+	StockCountItemRejected = "rejected"
+)
+
+// StockCountReasonCodes lists the reason codes accepted when approving a variance
+var StockCountReasonCodes = map[string]string{
+	"damaged":            "Damaged / unusable material",
+	"lost":               "Lost or stolen material",
+	"unrecorded_issue":   "Material issued without a transaction",
+	"unrecorded_receipt": "Material received without a purchase entry",
+	"unit_error":         "Unit of measure or booking error",
+	"expired":            "Expired / shelf life exceeded",
+	"other":              "Other (see comments)",
+}
+
+// StockCount represents a physical stock-take of one warehouse
+type StockCount struct {
+	ID            int              `json:"id" example:"1"`
+	ProjectID     int              `json:"project_id" example:"1"`
+	WarehouseID   int              `json:"warehouse_id" example:"1"`
+	WarehouseName string           `json:"warehouse_name,omitempty" example:"Main Warehouse"`
+	Title         string           `json:"title" example:"March cycle count"`
+	Status        string           `json:"status" example:"open"`
+	Notes         string           `json:"notes" example:""`
+	CreatedBy     string           `json:"created_by" example:"admin"`
+	CreatedAt     time.Time        `json:"created_at" example:"2024-01-15T10:30:00Z"`
+	FrozenAt      time.Time        `json:"frozen_at" example:"2024-01-15T10:30:00Z"`
+	ClosedAt      *time.Time       `json:"closed_at,omitempty"`
+	ItemCount     int              `json:"item_count" example:"12"`
+	CountedItems  int              `json:"counted_items" example:"10"`
+	Items         []StockCountItem `json:"items,omitempty"`
+}
+
+// StockCountItem is one BOM line of a stock count with its frozen system quantity
+type StockCountItem struct {
+	ID           int        `json:"id" example:"1"`
+	StockCountID int        `json:"stock_count_id" example:"1"`
+	BomID        int        `json:"bom_id" example:"1"`
+	BomName      string     `json:"bom_name" example:"Cement"`
+	SystemQty    float64    `json:"system_qty" example:"50"`
+	CountedQty   *float64   `json:"counted_qty"`
+	Variance     *float64   `json:"variance"`
+	Counters     int        `json:"counters" example:"2"`
+	Disputed     bool       `json:"disputed" example:"false"` // the counters' latest figures disagree
+	Status       string     `json:"status" example:"pending"`
+	ReasonCode   string     `json:"reason_code,omitempty" example:"damaged"`
+	Comments     string     `json:"comments,omitempty" example:""`
+	DecidedBy    string     `json:"decided_by,omitempty" example:"admin"`
+	DecidedAt    *time.Time `json:"decided_at,omitempty"`
+	AdjustmentID *int       `json:"adjustment_id,omitempty"`
+}
+
+// StockCountEntry is a counted quantity submitted by one counter
+type StockCountEntry struct {
+	ID           int       `json:"id" example:"1"`
+	StockCountID int       `json:"stock_count_id" example:"1"`
+	BomID        int       `json:"bom_id" example:"1"`
+	CountedQty   float64   `json:"counted_qty" example:"48"`
+	CountedBy    int       `json:"counted_by" example:"7"`
+	CounterName  string    `json:"counter_name" example:"Ravi Kumar"`
+	Source       string    `json:"source" example:"app"`
+	CountedAt    time.Time `json:"counted_at" example:"2024-01-15T10:30:00Z"`
+}
+
+// StockCountLog is one entry of the approval trail of a stock count
+type StockCountLog struct {
+	ID           int       `json:"id" example:"1"`
+	StockCountID int       `json:"stock_count_id" example:"1"`
+	ItemID       *int      `json:"item_id,omitempty" example:"1"`
+	Action       string    `json:"action" example:"approved"`
+	ActedBy      string    `json:"acted_by" example:"admin"`
+	Comments     string    `json:"comments" example:""`
+	CreatedAt    time.Time `json:"created_at" example:"2024-01-15T10:30:00Z"`
+}
+
+// CreateStockCountRequest starts a stock count for a warehouse
+type CreateStockCountRequest struct {
+	ProjectID   int    `json:"project_id" binding:"required" example:"1"`
+	WarehouseID int    `json:"warehouse_id" binding:"required" example:"1"`
+	Title       string `json:"title" example:"March cycle count"`
+	Notes       string `json:"notes" example:""`
+	BomIDs      []int  `json:"bom_ids"` // optional: restrict the count to these products
+}
+
+// StockCountEntryRequest carries the quantities counted by one counter
+type StockCountEntryRequest struct {
+	Source  string `json:"source" example:"app"`
+	Entries []struct {
+		BomID      int     `json:"bom_id" binding:"required" example:"1"`
+		CountedQty float64 `json:"counted_qty" example:"48"`
+	} `json:"entries" binding:"required"`
+}
+
+// StockCountDecision approves or rejects the variance of one item
+type StockCountDecision struct {
+	ItemID     int    `json:"item_id" binding:"required" example:"1"`
+	Approve    bool   `json:"approve" example:"true"`
+	ReasonCode string `json:"reason_code" example:"damaged"`
+	Comments   string `json:"comments" example:""`
+}
+
+// StockCountApprovalRequest carries the decisions for a stock count under review
+type StockCountApprovalRequest struct {
+	Decisions []StockCountDecision `json:"decisions" binding:"required"`
+}
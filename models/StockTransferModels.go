@@ -0,0 +1,80 @@
+package models
+
+import "time"
+
+// StockTransfer moves finished elements of a project from one stockyard to another
+type StockTransfer struct {
+	ID              int                   `json:"id" example:"1"`
+	ProjectID       int                   `json:"project_id" example:"1"`
+	TransferNo      string                `json:"transfer_no" example:"TRF-00001"`
+	FromStockyardID int                   `json:"from_stockyard_id" example:"1"`
+	FromYardName    string                `json:"from_yard_name" example:"Factory Yard"`
+	ToStockyardID   int                   `json:"to_stockyard_id" example:"2"`
+	ToYardName      string                `json:"to_yard_name" example:"Site Buffer Yard"`
+	VehicleID       int                   `json:"vehicle_id" example:"4"`
+	VehicleNumber   string                `json:"vehicle_number" example:"MH-01-AB-1234"`
+	DriverName      string                `json:"driver_name" example:"Raj Kumar"`
+	Status          string                `json:"status" example:"In Transit"`
+	Remarks         string                `json:"remarks" example:""`
+	CreatedBy       string                `json:"created_by" example:"yard1"`
+	DispatchedAt    time.Time             `json:"dispatched_at" example:"2024-01-15T10:30:00Z"`
+	InTransitAt     *time.Time            `json:"in_transit_at,omitempty"`
+	ReceivedAt      *time.Time            `json:"received_at,omitempty"`
+	CancelledAt     *time.Time            `json:"cancelled_at,omitempty"`
+	ItemCount       int                   `json:"item_count" example:"8"`
+	ReceivedCount   int                   `json:"received_count" example:"0"`
+	Items           []StockTransferItem   `json:"items,omitempty"`
+	TrackingLog     []DispatchTrackingLog `json:"tracking_log,omitempty"`
+}
+
+// StockTransferItem is an element on a transfer
+type StockTransferItem struct {
+	PrecastStockID int        `json:"precast_stock_id" example:"101"`
+	ElementID      int        `json:"element_id" example:"2001"`
+	ElementName    string     `json:"element_name" example:"C1-01"`
+	ElementType    string     `json:"element_type" example:"Column"`
+	Status         string     `json:"status" example:"In Transit"` // In Transit, Received or Returned
+	ClosedAt       *time.Time `json:"closed_at,omitempty"`
+	ClosedBy       string     `json:"closed_by,omitempty" example:"yard2"`
+}
+
+// CreateStockTransferRequest dispatches elements from one stockyard to another. The vehicle is found
+// or registered by its number, as for dispatch orders.
+type CreateStockTransferRequest struct {
+	ProjectID               int    `json:"project_id" binding:"required" example:"1"`
+	FromStockyardID         int    `json:"from_stockyard_id" binding:"required" example:"1"`
+	ToStockyardID           int    `json:"to_stockyard_id" binding:"required" example:"2"`
+	Items                   []int  `json:"items" binding:"required" example:"2001,2002"` // element IDs
+	VehicleID               int    `json:"vehicle_id" example:"0"`                       // an existing vehicle, instead of the details below
+	VehicleNumber           string `json:"vehicle_number" example:"MH-01-AB-1234"`
+	Capacity                int    `json:"capacity" example:"20"`
+	TransporterID           int    `json:"transporter_id" example:"1"`
+	TruckType               string `json:"truck_type" example:"Trailer"`
+	DriverName              string `json:"driver_name" binding:"required" example:"Raj Kumar"`
+	DriverPhoneNo           string `json:"driver_phone_no" example:"9876543210"`
+	EmergencyContactPhoneNo string `json:"emergency_contact_phone_no" example:""`
+	Remarks                 string `json:"remarks" example:"Buffer stock for tower B"`
+}
+
+// ReceiveStockTransferRequest confirms receipt at the destination stockyard
+type ReceiveStockTransferRequest struct {
+	Items      []int            `json:"items" example:"2001,2002"` // element IDs received, all when empty
+	Slots      []StockSlotInput `json:"slots"`                     // slots the pieces were put in
+	ForceSlots bool             `json:"force_slots" example:"false"`
+	Remarks    string           `json:"remarks" example:""`
+}
+
+// StockTransferCancelRequest cancels a transfer nothing has been received from
+type StockTransferCancelRequest struct {
+	Reason string `json:"reason" binding:"required" example:"Truck breakdown"`
+}
+
+// InTransitRoute is the quantity in transit between two stockyards
+type InTransitRoute struct {
+	FromStockyardID int    `json:"from_stockyard_id" example:"1"`
+	FromYardName    string `json:"from_yard_name" example:"Factory Yard"`
+	ToStockyardID   int    `json:"to_stockyard_id" example:"2"`
+	ToYardName      string `json:"to_yard_name" example:"Site Buffer Yard"`
+	Transfers       int    `json:"transfers" example:"2"`
+	Elements        int    `json:"elements" example:"14"`
+}
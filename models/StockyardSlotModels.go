@@ -0,0 +1,111 @@
+package models
+
+import "time"
+
+// StockyardSlot is a bay of a stockyard row where pieces are stacked
+type StockyardSlot struct {
+	ID           int              `json:"id" example:"1"`
+	StockyardID  int              `json:"stockyard_id" example:"1"`
+	Zone         string           `json:"zone" example:"A"`
+	Row          string           `json:"row" example:"R1"`
+	Bay          int              `json:"bay" example:"3"`
+	Code         string           `json:"code" example:"A-R1-03"`
+	MaxWeightKg  float64          `json:"max_weight_kg" example:"40000"` // 0 for no limit
+	MaxStack     int              `json:"max_stack" example:"4"`
+	LengthMM     float64          `json:"length_mm" example:"8000"` // footprint, 0 for no limit
+	WidthMM      float64          `json:"width_mm" example:"3000"`
+	Active       bool             `json:"active" example:"true"`
+	StackCount   int              `json:"stack_count" example:"2"`
+	UsedWeightKg float64          `json:"used_weight_kg" example:"14200"`
+	Pieces       []StockSlotPiece `json:"pieces,omitempty"` // bottom first
+	CreatedAt    time.Time        `json:"created_at" example:"2024-01-15T10:30:00Z"`
+}
+
+// StockSlotPiece is a piece of precast stock placed in a slot
+type StockSlotPiece struct {
+	PrecastStockID int        `json:"precast_stock_id" example:"101"`
+	ElementID      int        `json:"element_id" example:"2001"`
+	ElementName    string     `json:"element_name" example:"C1-01"`
+	ElementType    string     `json:"element_type" example:"Column"`
+	ProjectID      int        `json:"project_id" example:"1"`
+	WeightKg       float64    `json:"weight_kg" example:"7100"`
+	Tier           int        `json:"tier" example:"1"` // 1 is the bottom of the stack
+	NeededBy       *time.Time `json:"needed_by,omitempty"`
+	PlacedAt       time.Time  `json:"placed_at" example:"2024-01-15T10:30:00Z"`
+	PlacedBy       string     `json:"placed_by" example:"yard1"`
+}
+
+// CreateStockyardSlotsRequest lays out the bays of a zone: every row gets bays numbered from
+// first_bay with the same capacity
+type CreateStockyardSlotsRequest struct {
+	Zone        string   `json:"zone" binding:"required" example:"A"`
+	Rows        []string `json:"rows" binding:"required" example:"R1,R2"`
+	Bays        int      `json:"bays" binding:"required" example:"10"`
+	FirstBay    int      `json:"first_bay" example:"1"`
+	MaxWeightKg float64  `json:"max_weight_kg" example:"40000"`
+	MaxStack    int      `json:"max_stack" example:"4"` // defaults to 1
+	LengthMM    float64  `json:"length_mm" example:"8000"`
+	WidthMM     float64  `json:"width_mm" example:"3000"`
+}
+
+// UpdateStockyardSlotRequest changes the capacity of a slot or takes it out of use
+type UpdateStockyardSlotRequest struct {
+	MaxWeightKg float64 `json:"max_weight_kg" example:"40000"`
+	MaxStack    int     `json:"max_stack" binding:"required" example:"4"`
+	LengthMM    float64 `json:"length_mm" example:"8000"`
+	WidthMM     float64 `json:"width_mm" example:"3000"`
+	Active      bool    `json:"active" example:"true"`
+}
+
+// StockSlotInput records the slot a received element was actually put in
+type StockSlotInput struct {
+	ElementID int    `json:"element_id" binding:"required" example:"2001"`
+	SlotID    int    `json:"slot_id" binding:"required" example:"1"`
+	NeededBy  string `json:"needed_by" example:"2024-02-10"` // planned dispatch date (YYYY-MM-DD)
+}
+
+// PutAwayRequest records the slots of pieces already in the stockyard
+type PutAwayRequest struct {
+	ProjectID int              `json:"project_id" binding:"required" example:"1"`
+	Slots     []StockSlotInput `json:"slots" binding:"required"`
+	Force     bool             `json:"force" example:"false"` // record even when a capacity limit is exceeded
+}
+
+// SlotSuggestion is a slot a piece can be put away in
+type SlotSuggestion struct {
+	SlotID     int     `json:"slot_id" example:"1"`
+	Code       string  `json:"code" example:"A-R1-03"`
+	Tier       int     `json:"tier" example:"2"`
+	StackCount int     `json:"stack_count" example:"1"`
+	FreeWeight float64 `json:"free_weight_kg" example:"25800"`
+	Reason     string  `json:"reason" example:"Stacks on a heavier piece needed later"`
+}
+
+// PutAwayResult is where a received element was put, or where it should go
+type PutAwayResult struct {
+	ElementID   int              `json:"element_id" example:"2001"`
+	SlotID      *int             `json:"slot_id,omitempty" example:"1"`
+	Code        string           `json:"code,omitempty" example:"A-R1-03"`
+	Tier        int              `json:"tier,omitempty" example:"2"`
+	Warnings    []string         `json:"warnings,omitempty"`
+	Suggestions []SlotSuggestion `json:"suggestions,omitempty"`
+}
+
+// PickListRequest asks for the pick order of elements about to be dispatched
+type PickListRequest struct {
+	ProjectID  int   `json:"project_id" binding:"required" example:"1"`
+	ElementIDs []int `json:"element_ids" binding:"required" example:"2001,2002"`
+}
+
+// PickListItem is one piece to pick, in slot order
+type PickListItem struct {
+	Sequence       int    `json:"sequence" example:"1"`
+	ElementID      int    `json:"element_id" example:"2001"`
+	ElementName    string `json:"element_name" example:"C1-01"`
+	PrecastStockID int    `json:"precast_stock_id" example:"101"`
+	StockyardID    int    `json:"stockyard_id" example:"1"`
+	SlotCode       string `json:"slot_code" example:"A-R1-03"`
+	Tier           int    `json:"tier,omitempty" example:"2"`
+	Rehandles      []int  `json:"rehandles,omitempty" example:"2010"`            // elements on top to move aside first
+	Location       string `json:"location,omitempty" example:"default_location"` // for pieces without a slot
+}
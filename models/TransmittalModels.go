@@ -0,0 +1,105 @@
+package models
+
+import "time"
+
+// Transmittal purposes
+const (
+	TransmittalPurposeApproval     = "for_approval"
+	TransmittalPurposeConstruction = "for_construction"
+	TransmittalPurposeInformation  = "for_information"
+)
+
+// Transmittal recipient types
+const (
+	TransmittalRecipientEndClient = "end_client"
+	TransmittalRecipientSite      = "site"
+)
+
+// Transmittal reply statuses. Transmittals without a due date need no reply.
+const (
+	TransmittalReplyPending           = "pending"
+	TransmittalReplyApproved          = "approved"
+	TransmittalReplyApprovedWithNotes = "approved_with_comments"
+	TransmittalReplyReviseAndResubmit = "revise_and_resubmit"
+	TransmittalReplyRejected          = "rejected"
+	TransmittalReplyAcknowledged      = "acknowledged"
+	TransmittalReplyNotRequired       = "not_required"
+)
+
+// TransmittalPurposes lists the accepted purposes with their printed labels
+var TransmittalPurposes = map[string]string{
+	TransmittalPurposeApproval:     "For Approval",
+	TransmittalPurposeConstruction: "For Construction",
+	TransmittalPurposeInformation:  "For Information",
+}
+
+// TransmittalReplyStatuses lists the statuses a reply can be recorded with
+var TransmittalReplyStatuses = map[string]string{
+	TransmittalReplyApproved:          "Approved",
+	TransmittalReplyApprovedWithNotes: "Approved with comments",
+	TransmittalReplyReviseAndResubmit: "Revise and resubmit",
+	TransmittalReplyRejected:          "Rejected",
+	TransmittalReplyAcknowledged:      "Acknowledged",
+}
+
+// Transmittal records a set of drawing revisions sent to an end client or site team
+type Transmittal struct {
+	ID             int               `json:"id" example:"1"`
+	ProjectID      int               `json:"project_id" example:"1"`
+	ProjectName    string            `json:"project_name,omitempty" example:"Tower A"`
+	TransmittalNo  string            `json:"transmittal_no" example:"TR-0001"`
+	RecipientType  string            `json:"recipient_type" example:"end_client"`
+	EndClientID    *int              `json:"endclient_id,omitempty" example:"3"`
+	RecipientName  string            `json:"recipient_name" example:"ABC Developers"`
+	RecipientEmail string            `json:"recipient_email" example:"design@abc.com"`
+	Purpose        string            `json:"purpose" example:"for_approval"`
+	Remarks        string            `json:"remarks" example:""`
+	DueDate        *time.Time        `json:"due_date,omitempty" example:"2024-01-22T00:00:00Z"`
+	ReplyStatus    string            `json:"reply_status" example:"pending"`
+	ReplyComments  string            `json:"reply_comments" example:""`
+	RepliedAt      *time.Time        `json:"replied_at,omitempty"`
+	RecordedBy     string            `json:"reply_recorded_by,omitempty" example:"admin"`
+	Overdue        bool              `json:"overdue" example:"false"`
+	IssuedBy       string            `json:"issued_by" example:"admin"`
+	IssuedAt       time.Time         `json:"issued_at" example:"2024-01-15T10:30:00Z"`
+	LastReminderAt *time.Time        `json:"last_reminder_at,omitempty"`
+	ReminderCount  int               `json:"reminder_count" example:"0"`
+	Items          []TransmittalItem `json:"items,omitempty"`
+}
+
+// TransmittalItem is one drawing revision on a transmittal, frozen at the time of sending
+type TransmittalItem struct {
+	ID              int    `json:"id" example:"1"`
+	TransmittalID   int    `json:"transmittal_id" example:"1"`
+	DrawingID       int    `json:"drawing_id" example:"12"`
+	Version         string `json:"version" example:"RV-02"`
+	DrawingTypeName string `json:"drawing_type_name" example:"GA"`
+	ElementTypeID   int    `json:"element_type_id" example:"4"`
+	ElementType     string `json:"element_type" example:"Column C1"`
+	File            string `json:"file" example:"9f86d081884c7d659a2feaa0c55ad015.pdf"`
+}
+
+// TransmittalItemRequest selects a drawing revision; an empty version means the current one
+type TransmittalItemRequest struct {
+	DrawingID int    `json:"drawing_id" binding:"required" example:"12"`
+	Version   string `json:"version" example:"RV-02"`
+}
+
+// CreateTransmittalRequest is the body of a new transmittal
+type CreateTransmittalRequest struct {
+	ProjectID      int                      `json:"project_id" binding:"required" example:"1"`
+	RecipientType  string                   `json:"recipient_type" binding:"required" example:"end_client"`
+	EndClientID    int                      `json:"endclient_id" example:"3"`
+	RecipientName  string                   `json:"recipient_name" example:"Site team - Block B"`
+	RecipientEmail string                   `json:"recipient_email" example:"site.b@example.com"`
+	Purpose        string                   `json:"purpose" binding:"required" example:"for_approval"`
+	DueDate        string                   `json:"due_date" example:"2024-01-22"`
+	Remarks        string                   `json:"remarks" example:""`
+	Items          []TransmittalItemRequest `json:"items" binding:"required"`
+}
+
+// TransmittalReplyRequest records the recipient's reply
+type TransmittalReplyRequest struct {
+	ReplyStatus string `json:"reply_status" binding:"required" example:"approved_with_comments"`
+	Comments    string `json:"comments" example:"Revise lifting insert positions"`
+}
@@ -0,0 +1,32 @@
+package models
+
+import "time"
+
+// Upload purposes, each with its own allow-list and size limit
+const (
+	UploadPurposeGeneral = "general"
+	UploadPurposeDrawing = "drawing"
+	UploadPurposeQCPhoto = "qc_photo"
+	UploadPurposeImport  = "import"
+)
+
+// UploadPolicy describes what an upload purpose accepts
+type UploadPolicy struct {
+	Purpose      string   `json:"purpose" example:"drawing"`
+	AllowedTypes []string `json:"allowed_types" example:"pdf,dwg"`
+	Extensions   []string `json:"extensions" example:".pdf,.dwg"`
+	MaxSizeBytes int64    `json:"max_size_bytes" example:"52428800"`
+}
+
+// QuarantinedUpload is an upload that failed validation and was set aside for review
+type QuarantinedUpload struct {
+	ID           int       `json:"id" example:"1"`
+	OriginalName string    `json:"original_name" example:"GA-101.pdf"`
+	Purpose      string    `json:"purpose" example:"drawing"`
+	Reason       string    `json:"reason" example:"threat detected: PDF.JavaScript"`
+	DetectedType string    `json:"detected_type" example:"pdf"`
+	SHA256       string    `json:"sha256" example:"9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"`
+	Size         int64     `json:"size" example:"204800"`
+	UploadedBy   string    `json:"uploaded_by" example:"admin"`
+	CreatedAt    time.Time `json:"created_at" example:"2024-01-15T10:30:00Z"`
+}
@@ -768,12 +768,13 @@ type Paper struct {
 }
 
 type Question struct {
-	ID           int       `json:"id" example:"1"`
-	ProjectID    int       `json:"project_id" example:"1"`
-	PaperID      int       `json:"paper_id" example:"1"`
-	QuestionText string    `json:"question_text" example:"Is surface finish acceptable?"`
-	CreatedAt    time.Time `json:"created_at" example:"2024-01-15T10:30:00Z"`
-	Options      []Option  `json:"options"`
+	ID           int             `json:"id" example:"1"`
+	ProjectID    int             `json:"project_id" example:"1"`
+	PaperID      int             `json:"paper_id" example:"1"`
+	QuestionText string          `json:"question_text" example:"Is surface finish acceptable?"`
+	CreatedAt    time.Time       `json:"created_at" example:"2024-01-15T10:30:00Z"`
+	Options      []Option        `json:"options"`
+	Spec         *QCQuestionSpec `json:"spec,omitempty"` // nil for plain multiple choice
 }
 
 // Option represents a possible answer for a question.
@@ -975,8 +976,10 @@ type DispatchIncidence struct {
 	UpdatedAt        time.Time `json:"updated_at"`
 }
 type UpdateReceivedRequest struct {
-	ElementIDs []int `json:"element_ids" binding:"required"`
-	ProjectID  int   `json:"project_id" binding:"required"`
+	ElementIDs []int            `json:"element_ids" binding:"required"`
+	ProjectID  int              `json:"project_id" binding:"required"`
+	Slots      []StockSlotInput `json:"slots"`       // slots the pieces were put in; the others get suggestions
+	ForceSlots bool             `json:"force_slots"` // record the slots even over their capacity
 }
 
 type StockErected struct {
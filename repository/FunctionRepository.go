@@ -39,19 +39,6 @@ func GenerateRandomCode() string {
 	return fmt.Sprintf("%s%d", prefix, number)
 }
 
-func GenerateElementID(elementType string, NamingConvention string, sequenceNumber int) string {
-	// Ensure the element type is converted to uppercase
-	formattedElementType := strings.ToUpper(elementType)
-
-	// Format the sequence number as a 4-digit string with leading zeros (e.g., 0001, 0002, ...)
-	formattedSequence := fmt.Sprintf("%04d", sequenceNumber)
-
-	// Combine to form the final Element ID in the format "elementType/0001"
-	elementID := formattedElementType + "/" + NamingConvention + "/" + formattedSequence
-
-	return elementID
-}
-
 func GenerateVersionCode(previousVersion string) string {
 	if previousVersion == "" {
 		return "RV-01"
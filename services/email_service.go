@@ -242,6 +242,15 @@ func (es *EmailService) SendWelcomeAdminEmail(user models.User, customTemplateID
 	return es.SendTemplatedEmail("welcome_user", emailData, customTemplateID)
 }
 
+// SendTransmittalReminderEmail reminds a transmittal recipient that a reply is overdue. The default
+// "transmittal_reminder" template is used when one is configured, the given message otherwise.
+func (es *EmailService) SendTransmittalReminderEmail(emailData models.EmailData, subject, body string) error {
+	if _, err := models.GetDefaultTemplate(es.db, "transmittal_reminder"); err == nil {
+		return es.SendTemplatedEmail("transmittal_reminder", emailData, nil)
+	}
+	return es.sendEmail(emailData.Email, subject, body, nil, nil)
+}
+
 // ValidateTemplate validates a template string for syntax errors
 func (es *EmailService) ValidateTemplate(templateStr string) error {
 	// Check for unmatched braces
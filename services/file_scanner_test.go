@@ -0,0 +1,62 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignatureScanner(t *testing.T) {
+	chunkEdge := bytes.Repeat([]byte{'a'}, 64*1024-5)
+
+	tests := []struct {
+		name        string
+		content     []byte
+		kind        string
+		textAllowed bool
+		wantThreat  string
+	}{
+		{"clean pdf", []byte("%PDF-1.7\n1 0 obj << /Type /Catalog >> endobj"), "pdf", false, ""},
+		{"eicar anywhere", append([]byte("header "), eicarTestString...), "text", true, "EICAR-Test-File"},
+		{"windows executable", []byte("MZ\x90\x00\x03"), "", false, "Executable.PE"},
+		{"elf executable", []byte("\x7fELF\x02\x01"), "", true, "Executable.ELF"},
+		{"MZ later in the file is fine", []byte("data MZ data"), "text", true, ""},
+		{"shebang where text is refused", []byte("#!/bin/sh\nrm -rf /"), "text", false, "Script.Shebang"},
+		{"shebang where text is accepted", []byte("#!/bin/sh\nrm -rf /"), "text", true, ""},
+		{"pdf javascript", []byte("%PDF-1.4\n<< /S /JavaScript /JS (app.alert(1)) >>"), "pdf", false, "PDF.JavaScript"},
+		{"pdf launch action", []byte("%PDF-1.4\n<< /S /Launch /F (cmd.exe) >>"), "pdf", false, "PDF.Launch"},
+		{"launch in a workbook is not a pdf action", []byte("PK\x03\x04 sheet text /Launch"), "xlsx", false, ""},
+		{"javascript in a csv is not a pdf action", []byte("name,note\nx,/JavaScript\n"), "csv", true, ""},
+		{"marker across a chunk boundary", append(append([]byte("%PDF-1.4\n"), chunkEdge...), "/JavaScript"...), "pdf", false, "PDF.JavaScript"},
+	}
+	scanner := NewSignatureScanner()
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "upload")
+			if err := os.WriteFile(path, tt.content, 0o600); err != nil {
+				t.Fatal(err)
+			}
+			verdict, err := scanner.Scan(context.Background(), ScanTarget{Path: path, Kind: tt.kind, TextAllowed: tt.textAllowed})
+			if err != nil {
+				t.Fatalf("scan: %v", err)
+			}
+			if verdict.Clean != (tt.wantThreat == "") || verdict.Threat != tt.wantThreat {
+				t.Errorf("verdict = %+v, want threat %q", verdict, tt.wantThreat)
+			}
+		})
+	}
+}
+
+func TestSignatureScannerCancelled(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "upload")
+	if err := os.WriteFile(path, []byte("hello"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := NewSignatureScanner().Scan(ctx, ScanTarget{Path: path}); err == nil {
+		t.Error("scan with a cancelled context succeeded")
+	}
+}
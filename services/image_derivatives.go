@@ -0,0 +1,106 @@
+package services
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	"image/jpeg"
+	_ "image/png"
+
+	"golang.org/x/image/draw"
+	_ "golang.org/x/image/webp"
+)
+
+// ImageVariant is a derivative size served instead of the full-resolution original
+type ImageVariant struct {
+	Name    string
+	MaxSide int // longest side in pixels; smaller images are not upscaled
+	Quality int // JPEG quality
+}
+
+// ImageVariants are the derivative sizes selectable with ?size=
+var ImageVariants = map[string]ImageVariant{
+	"thumb": {Name: "thumb", MaxSide: 320, Quality: 75},
+	"web":   {Name: "web", MaxSide: 1600, Quality: 82},
+}
+
+// maxDecodePixels guards against decompression bombs
+const maxDecodePixels = 80_000_000
+
+// RenderImageVariant decodes a JPEG, PNG, GIF or WebP image and re-encodes it as a JPEG that
+// fits the variant's bounding box. EXIF orientation is applied so phone photos come out upright,
+// and transparency is flattened onto white.
+func RenderImageVariant(data []byte, v ImageVariant) ([]byte, int, int, error) {
+	cfg, format, err := image.DecodeConfig(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("unsupported image: %w", err)
+	}
+	if cfg.Width <= 0 || cfg.Height <= 0 || cfg.Width*cfg.Height > maxDecodePixels {
+		return nil, 0, 0, fmt.Errorf("image dimensions %dx%d are out of range", cfg.Width, cfg.Height)
+	}
+	src, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, 0, 0, fmt.Errorf("unable to decode image: %w", err)
+	}
+	orientation := 1
+	if format == "jpeg" {
+		orientation = JPEGOrientation(data)
+	}
+
+	b := src.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if longest := max(w, h); longest > v.MaxSide {
+		w = max(1, (w*v.MaxSide+longest/2)/longest)
+		h = max(1, (h*v.MaxSide+longest/2)/longest)
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.Draw(dst, dst.Bounds(), image.NewUniform(color.White), image.Point{}, draw.Src)
+	draw.CatmullRom.Scale(dst, dst.Bounds(), src, b, draw.Over, nil)
+	out := orientImage(dst, orientation)
+
+	var buf bytes.Buffer
+	if err := jpeg.Encode(&buf, out, &jpeg.Options{Quality: v.Quality}); err != nil {
+		return nil, 0, 0, err
+	}
+	ob := out.Bounds()
+	return buf.Bytes(), ob.Dx(), ob.Dy(), nil
+}
+
+// orientImage applies an EXIF orientation (1-8) to an image
+func orientImage(img *image.RGBA, orientation int) *image.RGBA {
+	if orientation <= 1 || orientation > 8 {
+		return img
+	}
+	w, h := img.Bounds().Dx(), img.Bounds().Dy()
+	outW, outH := w, h
+	if orientation >= 5 {
+		outW, outH = h, w
+	}
+	out := image.NewRGBA(image.Rect(0, 0, outW, outH))
+	for y := 0; y < outH; y++ {
+		for x := 0; x < outW; x++ {
+			var sx, sy int
+			switch orientation {
+			case 2: // mirrored
+				sx, sy = w-1-x, y
+			case 3: // rotated 180
+				sx, sy = w-1-x, h-1-y
+			case 4: // mirrored vertically
+				sx, sy = x, h-1-y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // rotate 90 clockwise
+				sx, sy = y, h-1-x
+			case 7: // transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotate 90 counter-clockwise
+				sx, sy = w-1-y, x
+			}
+			out.SetRGBA(x, y, img.RGBAAt(sx, sy))
+		}
+	}
+	return out
+}
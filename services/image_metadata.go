@@ -0,0 +1,208 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+)
+
+// StripJPEGGPS removes location data from a JPEG: the GPS IFD inside the EXIF block is
+// emptied in place (so offsets of the other tags stay valid) and XMP packets carrying GPS
+// fields are dropped. The rest of the metadata, e.g. orientation and capture time, is kept.
+// The bool reports whether anything was removed.
+func StripJPEGGPS(data []byte) ([]byte, bool, error) {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil, false, errors.New("not a JPEG file")
+	}
+
+	out := make([]byte, 0, len(data))
+	out = append(out, data[:2]...)
+	changed := false
+	pos := 2
+	for pos+4 <= len(data) {
+		if data[pos] != 0xFF {
+			return nil, false, errors.New("corrupt JPEG segment")
+		}
+		marker := data[pos+1]
+		// Start of scan: the remainder is entropy-coded image data
+		if marker == 0xDA {
+			break
+		}
+		if marker == 0x01 || (marker >= 0xD0 && marker <= 0xD7) || marker == 0xFF {
+			out = append(out, data[pos:pos+2]...)
+			pos += 2
+			continue
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil, false, errors.New("corrupt JPEG segment length")
+		}
+		segment := data[pos:end]
+		payload := segment[4:]
+
+		if marker == 0xE1 && bytes.HasPrefix(payload, []byte("Exif\x00\x00")) {
+			seg := append([]byte(nil), segment...)
+			if stripTIFFGPS(seg[10:]) {
+				changed = true
+			}
+			out = append(out, seg...)
+		} else if marker == 0xE1 && bytes.HasPrefix(payload, []byte("http://ns.adobe.com/xap/1.0/")) && bytes.Contains(payload, []byte("GPS")) {
+			changed = true // drop the XMP packet
+		} else {
+			out = append(out, segment...)
+		}
+		pos = end
+	}
+	out = append(out, data[pos:]...)
+	return out, changed, nil
+}
+
+// stripTIFFGPS empties the GPS IFD of a TIFF/EXIF structure in place
+func stripTIFFGPS(tiff []byte) bool {
+	if len(tiff) < 8 {
+		return false
+	}
+	var bo binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return false
+	}
+	ifd0 := int(bo.Uint32(tiff[4:]))
+	if ifd0+2 > len(tiff) {
+		return false
+	}
+
+	gpsIFD := -1
+	n := int(bo.Uint16(tiff[ifd0:]))
+	for i := 0; i < n; i++ {
+		e := ifd0 + 2 + i*12
+		if e+12 > len(tiff) {
+			return false
+		}
+		if bo.Uint16(tiff[e:]) == 0x8825 {
+			gpsIFD = int(bo.Uint32(tiff[e+8:]))
+			break
+		}
+	}
+	if gpsIFD < 0 || gpsIFD+2 > len(tiff) {
+		return false
+	}
+
+	typeSize := map[uint16]int{1: 1, 2: 1, 3: 2, 4: 4, 5: 8, 6: 1, 7: 1, 8: 2, 9: 4, 10: 8, 11: 4, 12: 8}
+	count := int(bo.Uint16(tiff[gpsIFD:]))
+	for i := 0; i < count; i++ {
+		e := gpsIFD + 2 + i*12
+		if e+12 > len(tiff) {
+			break
+		}
+		size := typeSize[bo.Uint16(tiff[e+2:])] * int(bo.Uint32(tiff[e+4:]))
+		if size > 4 {
+			off := int(bo.Uint32(tiff[e+8:]))
+			if off >= 0 && off+size <= len(tiff) {
+				clear(tiff[off : off+size])
+			}
+		}
+		clear(tiff[e : e+12])
+	}
+	// An empty IFD whose next-IFD pointer (now zeroed) follows the count
+	bo.PutUint16(tiff[gpsIFD:], 0)
+	if next := gpsIFD + 2 + count*12; next+4 <= len(tiff) {
+		clear(tiff[next : next+4])
+	}
+	return true
+}
+
+// StripPNGGPS drops the eXIf chunk and XMP text chunks carrying GPS fields from a PNG
+func StripPNGGPS(data []byte) ([]byte, bool, error) {
+	signature := []byte("\x89PNG\r\n\x1a\n")
+	if !bytes.HasPrefix(data, signature) {
+		return nil, false, errors.New("not a PNG file")
+	}
+	out := make([]byte, 0, len(data))
+	out = append(out, signature...)
+	changed := false
+	pos := len(signature)
+	for pos+12 <= len(data) {
+		length := int(binary.BigEndian.Uint32(data[pos:]))
+		end := pos + 12 + length
+		if end > len(data) {
+			return nil, false, errors.New("corrupt PNG chunk length")
+		}
+		chunkType := string(data[pos+4 : pos+8])
+		body := data[pos+8 : pos+8+length]
+		if chunkType == "eXIf" || (chunkType == "iTXt" && bytes.HasPrefix(body, []byte("XML:com.adobe.xmp\x00")) && bytes.Contains(body, []byte("GPS"))) {
+			changed = true
+		} else {
+			out = append(out, data[pos:end]...)
+		}
+		pos = end
+		if chunkType == "IEND" {
+			return out, changed, nil
+		}
+	}
+	return nil, false, errors.New("truncated PNG: no IEND chunk")
+}
+
+// jpegExifTIFF returns the TIFF structure of a JPEG's EXIF block, or nil
+func jpegExifTIFF(data []byte) []byte {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return nil
+	}
+	pos := 2
+	for pos+4 <= len(data) && data[pos] == 0xFF {
+		marker := data[pos+1]
+		if marker == 0xDA {
+			return nil
+		}
+		length := int(binary.BigEndian.Uint16(data[pos+2:]))
+		end := pos + 2 + length
+		if length < 2 || end > len(data) {
+			return nil
+		}
+		if marker == 0xE1 && bytes.HasPrefix(data[pos+4:end], []byte("Exif\x00\x00")) {
+			return data[pos+10 : end]
+		}
+		pos = end
+	}
+	return nil
+}
+
+// JPEGOrientation returns the EXIF orientation (1-8) of a JPEG, 1 when absent
+func JPEGOrientation(data []byte) int {
+	tiff := jpegExifTIFF(data)
+	if len(tiff) < 8 {
+		return 1
+	}
+	var bo binary.ByteOrder
+	switch string(tiff[:2]) {
+	case "II":
+		bo = binary.LittleEndian
+	case "MM":
+		bo = binary.BigEndian
+	default:
+		return 1
+	}
+	ifd0 := int(bo.Uint32(tiff[4:]))
+	if ifd0+2 > len(tiff) {
+		return 1
+	}
+	n := int(bo.Uint16(tiff[ifd0:]))
+	for i := 0; i < n; i++ {
+		e := ifd0 + 2 + i*12
+		if e+12 > len(tiff) {
+			break
+		}
+		if bo.Uint16(tiff[e:]) == 0x0112 {
+			if o := int(bo.Uint16(tiff[e+8:])); o >= 1 && o <= 8 {
+				return o
+			}
+			break
+		}
+	}
+	return 1
+}
@@ -0,0 +1,200 @@
+package services
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+// testExifTIFF builds a little-endian TIFF block with an orientation tag and, when withGPS is
+// set, a GPS IFD holding a latitude reference and a latitude stored outside the entry
+func testExifTIFF(orientation uint16, withGPS bool) []byte {
+	bo := binary.LittleEndian
+	entry := func(tag, typ uint16, count, value uint32) []byte {
+		e := make([]byte, 12)
+		bo.PutUint16(e, tag)
+		bo.PutUint16(e[2:], typ)
+		bo.PutUint32(e[4:], count)
+		bo.PutUint32(e[8:], value)
+		return e
+	}
+
+	tiff := []byte("II*\x00\x08\x00\x00\x00")
+	entries := 1
+	if withGPS {
+		entries = 2
+	}
+	ifd0 := binary.LittleEndian.AppendUint16(nil, uint16(entries))
+	ifd0 = append(ifd0, entry(0x0112, 3, 1, uint32(orientation))...)
+	gpsOffset := uint32(8 + 2 + entries*12 + 4)
+	if withGPS {
+		ifd0 = append(ifd0, entry(0x8825, 4, 1, gpsOffset)...)
+	}
+	ifd0 = append(ifd0, 0, 0, 0, 0)
+	tiff = append(tiff, ifd0...)
+	if !withGPS {
+		return tiff
+	}
+
+	latOffset := gpsOffset + 2 + 2*12 + 4
+	gps := binary.LittleEndian.AppendUint16(nil, 2)
+	gps = append(gps, entry(0x0001, 2, 2, uint32('N'))...)
+	gps = append(gps, entry(0x0002, 5, 3, latOffset)...)
+	gps = append(gps, 0, 0, 0, 0)
+	tiff = append(tiff, gps...)
+	for _, v := range []uint32{12, 1, 58, 1, 3000, 100} {
+		tiff = bo.AppendUint32(tiff, v)
+	}
+	return tiff
+}
+
+func testJPEGSegment(marker byte, payload []byte) []byte {
+	seg := []byte{0xFF, marker, 0, 0}
+	binary.BigEndian.PutUint16(seg[2:], uint16(len(payload)+2))
+	return append(seg, payload...)
+}
+
+func testJPEG(segments ...[]byte) []byte {
+	data := []byte{0xFF, 0xD8}
+	for _, s := range segments {
+		data = append(data, s...)
+	}
+	// Start of scan followed by entropy-coded data that happens to contain GPS
+	data = append(data, testJPEGSegment(0xDA, []byte{1, 2, 3, 4})...)
+	data = append(data, []byte("GPS\xFF\x00 image data")...)
+	return append(data, 0xFF, 0xD9)
+}
+
+func TestStripJPEGGPS(t *testing.T) {
+	exifWithGPS := testJPEGSegment(0xE1, append([]byte("Exif\x00\x00"), testExifTIFF(6, true)...))
+	exifWithoutGPS := testJPEGSegment(0xE1, append([]byte("Exif\x00\x00"), testExifTIFF(3, false)...))
+	xmpWithGPS := testJPEGSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta exif:GPSLatitude=\"12,58N\"/>"))
+	xmpPlain := testJPEGSegment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta dc:title=\"Bay 4\"/>"))
+	comment := testJPEGSegment(0xFE, []byte("site photo"))
+
+	tests := []struct {
+		name            string
+		in              []byte
+		wantChanged     bool
+		wantOrientation int
+		wantDropped     [][]byte // segments that must be gone
+		wantKept        [][]byte // segments that must be untouched
+	}{
+		{"exif gps", testJPEG(exifWithGPS, comment), true, 6, nil, [][]byte{comment}},
+		{"xmp gps", testJPEG(xmpWithGPS, xmpPlain), true, 1, [][]byte{xmpWithGPS}, [][]byte{xmpPlain}},
+		{"exif and xmp gps", testJPEG(exifWithGPS, xmpWithGPS), true, 6, [][]byte{xmpWithGPS}, nil},
+		{"no gps", testJPEG(exifWithoutGPS, xmpPlain, comment), false, 3, nil, [][]byte{exifWithoutGPS, xmpPlain, comment}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, changed, err := StripJPEGGPS(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if !tt.wantChanged && !bytes.Equal(out, tt.in) {
+				t.Error("unchanged image was rewritten")
+			}
+			if got := JPEGOrientation(out); got != tt.wantOrientation {
+				t.Errorf("orientation = %d, want %d", got, tt.wantOrientation)
+			}
+			for _, seg := range tt.wantDropped {
+				if bytes.Contains(out, seg) {
+					t.Errorf("segment %q is still there", seg[4:])
+				}
+			}
+			for _, seg := range tt.wantKept {
+				if !bytes.Contains(out, seg) {
+					t.Errorf("segment %q was lost", seg[4:])
+				}
+			}
+			if !bytes.HasSuffix(out, []byte("GPS\xFF\x00 image data\xFF\xD9")) {
+				t.Error("image data after the start of scan was changed")
+			}
+
+			if tiff := jpegExifTIFF(out); tiff != nil && bytes.Contains(tt.in, exifWithGPS) {
+				// The GPS IFD is emptied in place and the latitude values are wiped
+				gpsOffset := int(binary.LittleEndian.Uint32(tiff[8+2+12+8:]))
+				if n := binary.LittleEndian.Uint16(tiff[gpsOffset:]); n != 0 {
+					t.Errorf("GPS IFD still has %d entries", n)
+				}
+				if !bytes.Equal(tiff[gpsOffset:], make([]byte, len(tiff)-gpsOffset)) {
+					t.Error("GPS values were not cleared")
+				}
+				if len(out) != len(tt.in)-countLen(tt.wantDropped) {
+					t.Error("EXIF block changed size")
+				}
+			}
+		})
+	}
+
+	if _, _, err := StripJPEGGPS([]byte("\x89PNG\r\n\x1a\n")); err == nil {
+		t.Error("a PNG was accepted as JPEG")
+	}
+	if _, _, err := StripJPEGGPS([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF, 0xFF}); err == nil {
+		t.Error("a truncated segment was accepted")
+	}
+}
+
+func countLen(segments [][]byte) int {
+	n := 0
+	for _, s := range segments {
+		n += len(s)
+	}
+	return n
+}
+
+func testPNGChunk(chunkType string, body []byte) []byte {
+	c := binary.BigEndian.AppendUint32(nil, uint32(len(body)))
+	c = append(c, chunkType...)
+	c = append(c, body...)
+	return append(c, 0, 0, 0, 0) // CRC is not checked
+}
+
+func TestStripPNGGPS(t *testing.T) {
+	signature := []byte("\x89PNG\r\n\x1a\n")
+	ihdr := testPNGChunk("IHDR", make([]byte, 13))
+	idat := testPNGChunk("IDAT", []byte("pixels"))
+	iend := testPNGChunk("IEND", nil)
+	exif := testPNGChunk("eXIf", testExifTIFF(1, true))
+	xmpGPS := testPNGChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00<exif:GPSLatitude>12,58N</exif:GPSLatitude>"))
+	xmpPlain := testPNGChunk("iTXt", []byte("XML:com.adobe.xmp\x00\x00\x00\x00\x00<dc:title>Bay 4</dc:title>"))
+	text := testPNGChunk("tEXt", []byte("Comment\x00GPS not recorded"))
+
+	join := func(chunks ...[]byte) []byte { return bytes.Join(append([][]byte{signature}, chunks...), nil) }
+
+	tests := []struct {
+		name        string
+		in          []byte
+		want        []byte
+		wantChanged bool
+	}{
+		{"exif chunk", join(ihdr, exif, idat, iend), join(ihdr, idat, iend), true},
+		{"xmp with gps", join(ihdr, xmpGPS, xmpPlain, idat, iend), join(ihdr, xmpPlain, idat, iend), true},
+		{"other text mentioning gps is kept", join(ihdr, text, idat, iend), join(ihdr, text, idat, iend), false},
+		{"trailing bytes after IEND are dropped", append(join(ihdr, idat, iend), "junk"...), join(ihdr, idat, iend), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			out, changed, err := StripPNGGPS(tt.in)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("changed = %v, want %v", changed, tt.wantChanged)
+			}
+			if !bytes.Equal(out, tt.want) {
+				t.Errorf("output differs:\n got %q\nwant %q", out, tt.want)
+			}
+		})
+	}
+
+	if _, _, err := StripPNGGPS([]byte{0xFF, 0xD8, 0xFF}); err == nil {
+		t.Error("a JPEG was accepted as PNG")
+	}
+	if _, _, err := StripPNGGPS(append(append([]byte{}, signature...), 0, 0, 1, 0, 'I', 'D', 'A', 'T')); err == nil {
+		t.Error("a truncated chunk was accepted")
+	}
+}
@@ -0,0 +1,305 @@
+package services
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+const (
+	testS3Bucket    = "precast"
+	testS3Region    = "ap-south-1"
+	testS3AccessKey = "minio-access"
+	testS3SecretKey = "minio-secret"
+)
+
+type standInObject struct {
+	body        []byte
+	contentType string
+}
+
+// s3StandIn is a MinIO-style stand-in: a path-style bucket that checks the SigV4 signature of
+// every request, header-signed or presigned, with its own implementation of the algorithm.
+type s3StandIn struct {
+	t       *testing.T
+	mu      sync.Mutex
+	objects map[string]standInObject
+}
+
+func (s *s3StandIn) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if err := s.verify(r); err != nil {
+		s.t.Logf("rejected %s %s: %v", r.Method, r.URL.Path, err)
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, "<Error><Code>SignatureDoesNotMatch</Code></Error>")
+		return
+	}
+	prefix := "/" + testS3Bucket + "/"
+	if !strings.HasPrefix(r.URL.Path, prefix) {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(r.URL.Path, prefix)
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch r.Method {
+	case http.MethodPut:
+		body, _ := io.ReadAll(r.Body)
+		s.objects[key] = standInObject{body: body, contentType: r.Header.Get("Content-Type")}
+	case http.MethodGet, http.MethodHead:
+		obj, ok := s.objects[key]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", obj.contentType)
+		w.Header().Set("Content-Length", fmt.Sprint(len(obj.body)))
+		if r.Method == http.MethodGet {
+			w.Write(obj.body)
+		}
+	case http.MethodDelete:
+		delete(s.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func standInEscape(s string, keepSlash bool) string {
+	var b strings.Builder
+	for _, ch := range []byte(s) {
+		switch {
+		case 'A' <= ch && ch <= 'Z', 'a' <= ch && ch <= 'z', '0' <= ch && ch <= '9',
+			ch == '-', ch == '_', ch == '.', ch == '~', keepSlash && ch == '/':
+			b.WriteByte(ch)
+		default:
+			fmt.Fprintf(&b, "%%%02X", ch)
+		}
+	}
+	return b.String()
+}
+
+func standInMAC(key []byte, data string) []byte {
+	m := hmac.New(sha256.New, key)
+	m.Write([]byte(data))
+	return m.Sum(nil)
+}
+
+// verify recomputes the signature of a request from what arrived on the wire
+func (s *s3StandIn) verify(r *http.Request) error {
+	query := r.URL.Query()
+	var credential, signedHeaders, signature, amzDate, payloadHash string
+	if auth := r.Header.Get("Authorization"); auth != "" {
+		if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 ") {
+			return errors.New("not a SigV4 authorization")
+		}
+		for _, part := range strings.Split(strings.TrimPrefix(auth, "AWS4-HMAC-SHA256 "), ", ") {
+			k, v, _ := strings.Cut(part, "=")
+			switch k {
+			case "Credential":
+				credential = v
+			case "SignedHeaders":
+				signedHeaders = v
+			case "Signature":
+				signature = v
+			}
+		}
+		amzDate = r.Header.Get("X-Amz-Date")
+		payloadHash = r.Header.Get("X-Amz-Content-Sha256")
+	} else {
+		credential = query.Get("X-Amz-Credential")
+		signedHeaders = query.Get("X-Amz-SignedHeaders")
+		signature = query.Get("X-Amz-Signature")
+		amzDate = query.Get("X-Amz-Date")
+		payloadHash = "UNSIGNED-PAYLOAD"
+		signedAt, err := time.Parse("20060102T150405Z", amzDate)
+		if err != nil {
+			return err
+		}
+		var expires int
+		fmt.Sscan(query.Get("X-Amz-Expires"), &expires)
+		if time.Now().After(signedAt.Add(time.Duration(expires) * time.Second)) {
+			return errors.New("presigned URL expired")
+		}
+		query.Del("X-Amz-Signature")
+	}
+	if signature == "" {
+		return errors.New("request is not signed")
+	}
+
+	parts := strings.Split(credential, "/")
+	if len(parts) != 5 || parts[0] != testS3AccessKey || parts[2] != testS3Region || parts[3] != "s3" || parts[4] != "aws4_request" {
+		return fmt.Errorf("bad credential scope %q", credential)
+	}
+	date := parts[1]
+
+	var canonicalHeaders strings.Builder
+	for _, h := range strings.Split(signedHeaders, ";") {
+		v := r.Header.Get(h)
+		if h == "host" {
+			v = r.Host
+		}
+		canonicalHeaders.WriteString(h + ":" + strings.TrimSpace(v) + "\n")
+	}
+
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	var canonicalQuery []string
+	for _, k := range keys {
+		for _, v := range query[k] {
+			canonicalQuery = append(canonicalQuery, standInEscape(k, false)+"="+standInEscape(v, false))
+		}
+	}
+
+	canonicalRequest := strings.Join([]string{
+		r.Method,
+		standInEscape(r.URL.Path, true),
+		strings.Join(canonicalQuery, "&"),
+		canonicalHeaders.String(),
+		signedHeaders,
+		payloadHash,
+	}, "\n")
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + date + "/" + testS3Region + "/s3/aws4_request\n" + hex.EncodeToString(hash[:])
+
+	key := standInMAC([]byte("AWS4"+testS3SecretKey), date)
+	key = standInMAC(key, testS3Region)
+	key = standInMAC(key, "s3")
+	key = standInMAC(key, "aws4_request")
+	if expected := hex.EncodeToString(standInMAC(key, stringToSign)); !hmac.Equal([]byte(expected), []byte(signature)) {
+		return errors.New("signature mismatch")
+	}
+	return nil
+}
+
+func newTestS3Store(t *testing.T, secret string) (*S3Store, *s3StandIn) {
+	t.Helper()
+	standIn := &s3StandIn{t: t, objects: map[string]standInObject{}}
+	server := httptest.NewServer(standIn)
+	t.Cleanup(server.Close)
+
+	store, err := NewS3Store(S3Config{
+		Endpoint:  server.URL,
+		Region:    testS3Region,
+		Bucket:    testS3Bucket,
+		AccessKey: testS3AccessKey,
+		SecretKey: secret,
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return store, standIn
+}
+
+func TestS3StorePutGetDelete(t *testing.T) {
+	store, standIn := newTestS3Store(t, testS3SecretKey)
+	ctx := context.Background()
+
+	keys := []string{
+		"objects/ab/cd/abcdef0123",
+		"imports/1700000000-element types (v2).xlsx",
+	}
+	for _, key := range keys {
+		t.Run(key, func(t *testing.T) {
+			content := []byte("content of " + key)
+			if err := store.Put(ctx, key, bytes.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+				t.Fatalf("put: %v", err)
+			}
+			if got := standIn.objects[key].contentType; got != "text/plain" {
+				t.Errorf("stored content type = %q", got)
+			}
+
+			info, err := store.Stat(ctx, key)
+			if err != nil {
+				t.Fatalf("stat: %v", err)
+			}
+			if info.Size != int64(len(content)) {
+				t.Errorf("stat size = %d, want %d", info.Size, len(content))
+			}
+
+			rc, _, err := store.Get(ctx, key)
+			if err != nil {
+				t.Fatalf("get: %v", err)
+			}
+			got, _ := io.ReadAll(rc)
+			rc.Close()
+			if !bytes.Equal(got, content) {
+				t.Errorf("get = %q, want %q", got, content)
+			}
+
+			presigned, err := store.PresignedURL(ctx, key, time.Minute)
+			if err != nil {
+				t.Fatalf("presign: %v", err)
+			}
+			resp, err := http.Get(presigned)
+			if err != nil {
+				t.Fatalf("presigned get: %v", err)
+			}
+			got, _ = io.ReadAll(resp.Body)
+			resp.Body.Close()
+			if resp.StatusCode != http.StatusOK || !bytes.Equal(got, content) {
+				t.Errorf("presigned get = %d %q", resp.StatusCode, got)
+			}
+
+			if err := store.Delete(ctx, key); err != nil {
+				t.Fatalf("delete: %v", err)
+			}
+			if _, err := store.Stat(ctx, key); !errors.Is(err, ErrObjectNotFound) {
+				t.Errorf("stat after delete = %v, want ErrObjectNotFound", err)
+			}
+			if _, _, err := store.Get(ctx, key); !errors.Is(err, ErrObjectNotFound) {
+				t.Errorf("get after delete = %v, want ErrObjectNotFound", err)
+			}
+		})
+	}
+}
+
+func TestS3StoreRejectedSignature(t *testing.T) {
+	store, standIn := newTestS3Store(t, "wrong-secret")
+	ctx := context.Background()
+
+	err := store.Put(ctx, "objects/x", strings.NewReader("x"), 1, "text/plain")
+	if err == nil || errors.Is(err, ErrObjectNotFound) {
+		t.Fatalf("put with a wrong secret = %v, want a signature error", err)
+	}
+	if len(standIn.objects) != 0 {
+		t.Errorf("stand-in stored %d objects", len(standIn.objects))
+	}
+}
+
+func TestS3StoreTamperedPresignedURL(t *testing.T) {
+	store, standIn := newTestS3Store(t, testS3SecretKey)
+	standIn.objects["objects/a"] = standInObject{body: []byte("a")}
+	standIn.objects["objects/b"] = standInObject{body: []byte("b")}
+
+	presigned, _ := store.PresignedURL(context.Background(), "objects/a", time.Minute)
+	u, _ := url.Parse(presigned)
+	u.Path = strings.Replace(u.Path, "objects/a", "objects/b", 1)
+	u.RawPath = ""
+
+	resp, err := http.Get(u.String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusForbidden {
+		t.Errorf("tampered presigned URL = %d, want 403", resp.StatusCode)
+	}
+}
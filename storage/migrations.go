@@ -0,0 +1,74 @@
+package storage
+
+import (
+	"database/sql"
+	"fmt"
+	"io/fs"
+	"log"
+	"sort"
+)
+
+// migrationLockID is the advisory lock that keeps two instances from migrating at the same time
+const migrationLockID = 4_801_226
+
+// RunMigrations applies the .sql files of migrations that haven't been applied yet, in name
+// order, each in its own transaction, and records them in schema_migrations.
+func RunMigrations(db *sql.DB, migrations fs.FS) error {
+	if _, err := db.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_migrations (
+			name       TEXT PRIMARY KEY,
+			applied_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+		)`); err != nil {
+		return fmt.Errorf("failed to create schema_migrations: %v", err)
+	}
+
+	names, err := fs.Glob(migrations, "*.sql")
+	if err != nil {
+		return err
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		applied, err := applyMigration(db, migrations, name)
+		if err != nil {
+			return fmt.Errorf("migration %s: %v", name, err)
+		}
+		if applied {
+			log.Printf("Applied migration %s", name)
+		}
+	}
+	return nil
+}
+
+// applyMigration runs one migration unless it is already recorded.
+func applyMigration(db *sql.DB, migrations fs.FS, name string) (bool, error) {
+	script, err := fs.ReadFile(migrations, name)
+	if err != nil {
+		return false, err
+	}
+
+	tx, err := db.Begin()
+	if err != nil {
+		return false, err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(`SELECT pg_advisory_xact_lock($1)`, migrationLockID); err != nil {
+		return false, err
+	}
+	var done bool
+	if err := tx.QueryRow(`SELECT EXISTS(SELECT 1 FROM schema_migrations WHERE name = $1)`, name).Scan(&done); err != nil {
+		return false, err
+	}
+	if done {
+		return false, nil
+	}
+
+	if _, err := tx.Exec(string(script)); err != nil {
+		return false, err
+	}
+	if _, err := tx.Exec(`INSERT INTO schema_migrations (name) VALUES ($1)`, name); err != nil {
+		return false, err
+	}
+	return true, tx.Commit()
+}